@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 管理接口鉴权：/admin/*、/analytics、/eval/history、/debug/embeddings需在Authorization请求头中带上
+// Bearer <ADMIN_TOKEN>（可以写成密钥引用），令牌错误时返回401；未配置ADMIN_TOKEN时管理接口一律返回403
+type AdminConfig struct {
+	Token string
+}
+
+func loadAdminConfig() AdminConfig {
+	return AdminConfig{Token: getEnv("ADMIN_TOKEN", "")}
+}
+
+// 校验管理令牌，不通过时写入错误响应并返回false
+func (s *apiServer) authorizeAdmin(w http.ResponseWriter, req *http.Request) bool {
+	token := s.rag.config.Admin.Token
+	if token == "" {
+		writeError(w, http.StatusForbidden, fmt.Errorf("管理接口需配置ADMIN_TOKEN"))
+		return false
+	}
+	given, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("管理令牌无效"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 精确检索基准需要把全部向量读到内存，超过该数量时只探测延迟
+const maxExactProbeVectors = 50000
+
+// 读取集合的行数、分段和向量索引参数
+func (r *RAGSystem) inspectIndex(ctx context.Context) (*indexStats, error) {
+	collectionName := r.config.CollectionName
+	stats := &indexStats{Name: collectionName, Params: make(map[string]string)}
+
+	collection, err := r.milvusClient.DescribeCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("查询集合信息失败: %w", err)
+	}
+	for _, field := range collection.Schema.Fields {
+		if field.Name == "vector" {
+			stats.Dim, _ = strconv.Atoi(field.TypeParams["dim"])
+		}
+	}
+
+	statistics, err := r.milvusClient.GetCollectionStatistics(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("查询集合统计失败: %w", err)
+	}
+	stats.Vectors, _ = strconv.ParseInt(statistics["row_count"], 10, 64)
+
+	segments, err := r.milvusClient.GetPersistentSegmentInfo(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("查询分段信息失败: %w", err)
+	}
+	stats.Segments = len(segments)
+
+	indexes, err := r.milvusClient.DescribeIndex(ctx, collectionName, "vector")
+	if err != nil {
+		return nil, fmt.Errorf("查询向量索引失败: %w", err)
+	}
+	if len(indexes) > 0 {
+		params := indexes[0].Params()
+		stats.IndexType = params["index_type"]
+		stats.Metric = params["metric_type"]
+		// 构建参数在params中以JSON保存，例如 {"M":"8","efConstruction":"64"}
+		var build map[string]interface{}
+		if err := json.Unmarshal([]byte(params["params"]), &build); err == nil {
+			for key, value := range build {
+				stats.Params[key] = fmt.Sprint(value)
+			}
+		}
+	}
+
+	m, _ := strconv.Atoi(stats.Params["M"])
+	stats.MemoryBytes = stats.Vectors * int64(stats.Dim*4)
+	if stats.IndexType == string(entity.HNSW) {
+		stats.MemoryBytes = estimateHNSWMemory(stats.Vectors, stats.Dim, m)
+	}
+	return stats, nil
+}
+
+// 对HNSW探测不同ef、对IVF探测不同nprobe，以应用内暴力计算的L2距离为精确基准
+func (r *RAGSystem) probeIndex(ctx context.Context, stats *indexStats, queries []string, topK int) ([]indexProbe, error) {
+	collectionName := r.config.CollectionName
+	if err := r.milvusClient.LoadCollection(ctx, collectionName, false); err != nil {
+		return nil, fmt.Errorf("加载集合失败: %w", err)
+	}
+
+	var exact func(vector []float32) []string
+	if stats.Vectors <= maxExactProbeVectors {
+		ids, vectors, err := r.allVectors(ctx)
+		if err != nil {
+			return nil, err
+		}
+		exact = func(vector []float32) []string { return exactNearest(vector, ids, vectors, topK) }
+	} else {
+		fmt.Printf("⚠️  向量数超过 %d，跳过召回率计算，只探测延迟\n", maxExactProbeVectors)
+	}
+
+	type candidate struct {
+		setting string
+		value   int
+		param   entity.SearchParam
+		current bool
+	}
+	var candidates []candidate
+	switch {
+	case stats.IndexType == string(entity.HNSW):
+		for _, ef := range []int{16, 32, 64, 128, 256} {
+			if ef < topK {
+				continue
+			}
+			param, _ := entity.NewIndexHNSWSearchParam(ef)
+			candidates = append(candidates, candidate{fmt.Sprintf("ef=%d", ef), ef, param, ef == milvusProfileEf[r.settings().Retrieval.Profile]})
+		}
+	case strings.HasPrefix(stats.IndexType, "IVF"):
+		for _, nprobe := range []int{1, 4, 8, 16, 32, 64} {
+			param, _ := entity.NewIndexIvfFlatSearchParam(nprobe)
+			candidates = append(candidates, candidate{fmt.Sprintf("nprobe=%d", nprobe), nprobe, param, false})
+		}
+	default:
+		param, _ := entity.NewIndexFlatSearchParam()
+		candidates = append(candidates, candidate{stats.IndexType, 0, param, true})
+	}
+
+	var probes []indexProbe
+	for _, c := range candidates {
+		probe := indexProbe{Setting: c.setting, Value: c.value, Current: c.current}
+		var recall float64
+		var elapsed time.Duration
+		for _, query := range queries {
+			vector, err := r.embedWith(ctx, "", query)
+			if err != nil {
+				return nil, fmt.Errorf("问题向量化失败: %w", err)
+			}
+			start := time.Now()
+			results, err := r.milvusClient.Search(ctx, collectionName, nil, "", []string{},
+				[]entity.Vector{entity.FloatVector(vector)}, "vector", entity.L2, topK, c.param)
+			elapsed += time.Since(start)
+			if err != nil {
+				return nil, fmt.Errorf("探测搜索失败: %w", err)
+			}
+			if exact == nil || len(results) == 0 {
+				continue
+			}
+			var approx []string
+			if idCol, ok := results[0].IDs.(*entity.ColumnVarChar); ok {
+				approx = idCol.Data()
+			}
+			recall += recallAt(approx, exact(vector))
+		}
+		probe.Latency = elapsed / time.Duration(len(queries))
+		probe.Recall = recall / float64(len(queries))
+		if exact == nil {
+			probe.Recall = -1
+		}
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+// 读取集合中全部分块的ID和向量
+func (r *RAGSystem) allVectors(ctx context.Context) ([]string, [][]float32, error) {
+	resultSet, err := r.milvusClient.Query(ctx, r.config.CollectionName, nil, `id != ""`, []string{"id", "vector"},
+		client.WithLimit(maxExactProbeVectors))
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取向量失败: %w", err)
+	}
+	idCol, ok := resultSet.GetColumn("id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, nil, fmt.Errorf("ID列类型错误")
+	}
+	vectorCol, ok := resultSet.GetColumn("vector").(*entity.ColumnFloatVector)
+	if !ok {
+		return nil, nil, fmt.Errorf("vector列类型错误")
+	}
+	return idCol.Data(), vectorCol.Data(), nil
+}
+
+// 按L2距离暴力计算最近的topK个分块
+func exactNearest(query []float32, ids []string, vectors [][]float32, topK int) []string {
+	type scored struct {
+		id       string
+		distance float64
+	}
+	all := make([]scored, len(ids))
+	for i, vector := range vectors {
+		var distance float64
+		for j := range query {
+			d := float64(query[j] - vector[j])
+			distance += d * d
+		}
+		all[i] = scored{ids[i], distance}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].distance < all[j].distance })
+	if len(all) > topK {
+		all = all[:topK]
+	}
+	nearest := make([]string, len(all))
+	for i, s := range all {
+		nearest[i] = s.id
+	}
+	return nearest
+}
+
+// 根据统计和探测结果给出Milvus索引参数建议
+func (r *RAGSystem) adviseIndex(stats *indexStats, probes []indexProbe) []string {
+	var advice []string
+	if stats.Vectors < 10000 && stats.IndexType != string(entity.Flat) {
+		advice = append(advice, fmt.Sprintf("向量数只有 %d，FLAT索引即可精确检索，延迟也足够低", stats.Vectors))
+	}
+
+	current, best := currentProbe(probes), bestProbe(probes)
+	switch {
+	case stats.Vectors > maxExactProbeVectors:
+	case best == nil && len(probes) > 1:
+		advice = append(advice, fmt.Sprintf("所有搜索参数都达不到 %.0f%% 召回率，提高M或efConstruction后重建索引", targetRecall*100))
+	case best != nil && current != nil && best.Value != current.Value:
+		advice = append(advice, fmt.Sprintf("搜索参数从 %s 调整为 %s（ACCURACY_PROFILE或请求中的accuracy.ef）：召回率 %.1f%% → %.1f%%，延迟 %v → %v",
+			current.Setting, best.Setting, current.Recall*100, best.Recall*100,
+			current.Latency.Round(10*time.Microsecond), best.Latency.Round(10*time.Microsecond)))
+	case best != nil && current == nil:
+		advice = append(advice, fmt.Sprintf("搜索参数建议使用 %s：召回率 %.1f%%，延迟 %v", best.Setting, best.Recall*100, best.Latency.Round(10*time.Microsecond)))
+	}
+
+	if stats.Segments > 1 && stats.Vectors/int64(stats.Segments) < 10000 {
+		advice = append(advice, fmt.Sprintf("%d 个分段平均只有 %d 行，执行compact合并小分段可以降低搜索延迟",
+			stats.Segments, stats.Vectors/int64(stats.Segments)))
+	}
+	if m, _ := strconv.Atoi(stats.Params["M"]); stats.IndexType == string(entity.HNSW) && stats.Vectors > 1000000 && m < 16 {
+		advice = append(advice, fmt.Sprintf("向量数超过百万，M=%d 偏小，建议M取16~32重建索引", m))
+	}
+	if stats.MemoryBytes > 8<<30 {
+		advice = append(advice, fmt.Sprintf("向量索引约需 %s 内存，考虑IVF_PQ或DISKANN降低内存占用", formatBytes(stats.MemoryBytes)))
+	}
+	return advice
+}
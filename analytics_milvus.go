@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// Milvus没有聚合查询：过滤条件翻译成表达式，分组计数在应用内完成
+func (r *RAGSystem) runAnalytics(query analyticsQuery) (*analyticsResult, error) {
+	ctx := context.Background()
+	collectionName := r.config.CollectionName
+
+	conditions := []string{`id != ""`}
+	for _, filter := range query.Filters {
+		op := filter.Op
+		if op == "=" {
+			op = "=="
+		}
+		conditions = append(conditions, fmt.Sprintf("%s %s %q", milvusAnalyticsField(filter.Field), op, filter.Value))
+	}
+	expr := strings.Join(conditions, " && ")
+
+	milvusClient, primary := r.readClient()
+	if err := milvusClient.LoadCollection(ctx, collectionName, false); err != nil {
+		r.recordRead(primary, err)
+		return nil, fmt.Errorf("加载集合失败: %w", err)
+	}
+	resultSet, err := milvusClient.Query(ctx, collectionName, nil, expr, []string{"doc_id", "meta"})
+	r.recordRead(primary, err)
+	if err != nil {
+		return nil, fmt.Errorf("查询分块失败: %w", err)
+	}
+
+	docIDCol, ok := resultSet.GetColumn("doc_id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("doc_id列类型错误")
+	}
+	metaCol, ok := resultSet.GetColumn("meta").(*entity.ColumnJSONBytes)
+	if !ok {
+		return nil, fmt.Errorf("meta列类型错误")
+	}
+
+	// 每个分组下的分块数和去重后的文档
+	chunkCounts := make(map[string]int64)
+	docSets := make(map[string]map[string]bool)
+	allDocs := make(map[string]bool)
+	var totalChunks int64
+	for i, docID := range docIDCol.Data() {
+		totalChunks++
+		allDocs[docID] = true
+		if query.GroupBy == "" {
+			continue
+		}
+
+		key := docID
+		if query.GroupBy != "doc_id" {
+			var meta map[string]interface{}
+			_ = json.Unmarshal(metaCol.Data()[i], &meta)
+			value, ok := meta[query.GroupBy]
+			if !ok || value == nil {
+				continue
+			}
+			key = fmt.Sprint(value)
+		}
+		chunkCounts[key]++
+		if docSets[key] == nil {
+			docSets[key] = make(map[string]bool)
+		}
+		docSets[key][docID] = true
+	}
+
+	result := &analyticsResult{GroupBy: query.GroupBy, Unit: query.unit(), Total: totalChunks}
+	if query.Documents {
+		result.Total = int64(len(allDocs))
+	}
+	for key, count := range chunkCounts {
+		if query.Documents {
+			count = int64(len(docSets[key]))
+		}
+		result.Rows = append(result.Rows, analyticsRow{Key: key, Count: count})
+	}
+	sort.Slice(result.Rows, func(i, j int) bool {
+		if result.Rows[i].Count != result.Rows[j].Count {
+			return result.Rows[i].Count > result.Rows[j].Count
+		}
+		return result.Rows[i].Key < result.Rows[j].Key
+	})
+	if len(result.Rows) > query.Limit {
+		result.Rows = result.Rows[:query.Limit]
+	}
+	return result, nil
+}
+
+// 元数据字段在Milvus表达式中的写法
+func milvusAnalyticsField(field string) string {
+	if field == "doc_id" {
+		return field
+	}
+	return fmt.Sprintf("meta[%q]", field)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 答案缓存配置
+type AnswerCacheConfig struct {
+	Enabled    bool
+	TTL        time.Duration
+	MaxEntries int
+	Similarity float64 // 语义命中的余弦相似度阈值，<=0时只做精确匹配
+}
+
+func loadAnswerCacheConfig() AnswerCacheConfig {
+	return AnswerCacheConfig{
+		Enabled:    getEnvAsBool("ANSWER_CACHE", true),
+		TTL:        time.Duration(getEnvAsInt("ANSWER_CACHE_TTL_SECONDS", 3600)) * time.Second,
+		MaxEntries: getEnvAsInt("ANSWER_CACHE_SIZE", 1000),
+		Similarity: getEnvAsFloat("ANSWER_CACHE_SIMILARITY", 0.88),
+	}
+}
+
+type cachedAnswer struct {
+	Question   string
+	Answer     string
+	Sources    []SearchResult
+	Similarity float64 // 与当前问题的相似度，精确命中为1
+	vector     []float32
+	createdAt  time.Time
+}
+
+// 答案缓存：问题完全相同直接命中，否则按问题向量的余弦相似度查找最相近的已回答问题
+type answerCache struct {
+	mu       sync.Mutex
+	config   AnswerCacheConfig
+	embedder embedder
+	entries  []*cachedAnswer
+	exact    map[string]*cachedAnswer
+}
+
+func newAnswerCache(config AnswerCacheConfig, e embedder) *answerCache {
+	if !config.Enabled {
+		return nil
+	}
+	return &answerCache{config: config, embedder: e, exact: make(map[string]*cachedAnswer)}
+}
+
+func normalizeQuestion(question string) string {
+	return strings.ToLower(strings.Join(strings.Fields(question), " "))
+}
+
+// 查找缓存的答案
+func (c *answerCache) Lookup(ctx context.Context, question string) (*cachedAnswer, bool) {
+	if c == nil {
+		return nil, false
+	}
+	key := normalizeQuestion(question)
+
+	c.mu.Lock()
+	c.evictExpired()
+	if entry, ok := c.exact[key]; ok {
+		c.mu.Unlock()
+		hit := *entry
+		hit.Similarity = 1
+		return &hit, true
+	}
+	c.mu.Unlock()
+
+	if c.config.Similarity <= 0 {
+		return nil, false
+	}
+	vector, err := c.embedder.Embed(ctx, key)
+	if err != nil {
+		fmt.Printf("⚠️  答案缓存向量化失败: %v\n", err)
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var best *cachedAnswer
+	bestScore := c.config.Similarity
+	for _, entry := range c.entries {
+		if score := cosineSimilarity(vector, entry.vector); score >= bestScore {
+			best, bestScore = entry, score
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	hit := *best
+	hit.Similarity = bestScore
+	return &hit, true
+}
+
+// 写入缓存，超出容量时淘汰最早的条目
+func (c *answerCache) Store(ctx context.Context, question, answer string, sources []SearchResult) {
+	if c == nil || answer == "" {
+		return
+	}
+	key := normalizeQuestion(question)
+	entry := &cachedAnswer{Question: question, Answer: answer, Sources: sources, createdAt: time.Now()}
+	if c.config.Similarity > 0 {
+		vector, err := c.embedder.Embed(ctx, key)
+		if err != nil {
+			fmt.Printf("⚠️  答案缓存向量化失败: %v\n", err)
+		}
+		entry.vector = vector
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.exact[key]; ok {
+		c.remove(old)
+	}
+	c.exact[key] = entry
+	c.entries = append(c.entries, entry)
+	for len(c.entries) > c.config.MaxEntries {
+		c.remove(c.entries[0])
+	}
+}
+
+// 删除引用了这些文档的缓存答案，返回删除的条数
+func (c *answerCache) InvalidateDocuments(docIDs []string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var stale []*cachedAnswer
+	for _, entry := range c.entries {
+		for _, source := range entry.Sources {
+			if containsString(docIDs, source.DocID) {
+				stale = append(stale, entry)
+				break
+			}
+		}
+	}
+	for _, entry := range stale {
+		c.remove(entry)
+	}
+	return len(stale)
+}
+
+// 清空全部缓存的答案
+func (c *answerCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	c.exact = make(map[string]*cachedAnswer)
+}
+
+func (c *answerCache) evictExpired() {
+	for len(c.entries) > 0 && time.Since(c.entries[0].createdAt) > c.config.TTL {
+		c.remove(c.entries[0])
+	}
+}
+
+func (c *answerCache) remove(entry *cachedAnswer) {
+	for i, e := range c.entries {
+		if e == entry {
+			c.entries = append(c.entries[:i], c.entries[i+1:]...)
+			break
+		}
+	}
+	delete(c.exact, normalizeQuestion(entry.Question))
+}
+
+// 带缓存的RAG回答；fresh为true时跳过缓存强制重新生成，结果仍会写入缓存。
+// 缓存只保存默认模型、默认长度档位生成的回答，指定其他模型、抽取式回答、分批总结、直接回答或问题带查询操作符时不读写缓存；
+// 缓存和FAQ按问题文本匹配，请求限定分类或实体、使用非默认精度档位或指定ef、nprobe、num_candidates时不读写，
+// 避免与其他检索条件下生成的回答混用；问题含相对时间时不读写；缓存中没有思考过程，需要思考过程时不读缓存
+// 缓存未命中时，同一问题的并发请求合并为一次检索和生成；降级的回答和需要人工审核的回答不写入缓存；
+// 开启人工审核时先查找FAQ
+func (r *RAGSystem) AnswerQuestion(ctx context.Context, question string, fresh bool, opts searchOptions) (string, float64, []SearchResult, bool, error) {
+	// 固定回答不受fresh和回答模式影响，原样返回
+	if override, ok := r.overrides.Lookup(ctx, question); ok {
+		fmt.Printf("📜 命中固定回答 #%d: %s\n", override.ID, question)
+		opts.Degraded.mark(tierOverride)
+		return override.Answer, 0, nil, false, nil
+	}
+	cacheable := (opts.Model == "" || opts.Model == r.config.DeepSeekModel) && r.config.AnswerLength.cacheable(opts.Length) && !opts.Extractive && !opts.Summarize && !opts.Direct && !opts.hasExclusions() && len(opts.Operators) == 0 && opts.LanguageFilter == "" && len(opts.History) == 0 &&
+		opts.Category == "" && opts.Entity == "" &&
+		(opts.Profile == "" || opts.Profile == r.settings().Retrieval.Profile) && opts.EF == 0 && opts.NProbe == 0 && opts.NumCandidates == 0 &&
+		!r.config.Dates.relativeQuestion(question)
+	if cacheable {
+		r.trending.Record(question)
+	}
+	if !fresh && cacheable && opts.Reasoning == nil {
+		if faq, similarity, ok := r.reviews.LookupFAQ(ctx, question); ok {
+			fmt.Printf("📌 命中FAQ（相似度 %.2f）: %s\n", similarity, faq.Question)
+			return faq.Answer, 0, faq.Sources, true, nil
+		}
+		if hit, ok := r.answers.Lookup(ctx, question); ok {
+			fmt.Printf("⚡ 命中答案缓存（相似度 %.2f）: %s\n", hit.Similarity, hit.Question)
+			return hit.Answer, 0, hit.Sources, true, nil
+		}
+	}
+
+	if opts.Degraded == nil {
+		opts.Degraded = &degradation{}
+	}
+	answer, elapsed, sources, err := r.flights.do(ctx, question, opts, func(ctx context.Context, opts searchOptions) (string, float64, []SearchResult, error) {
+		return r.GetRAGAnswer(ctx, question, opts)
+	})
+	if err != nil {
+		return answer, elapsed, sources, false, err
+	}
+	if cacheable && len(opts.Degraded.Tiers()) == 0 && !r.reviews.needsReview(sources) {
+		r.answers.Store(ctx, question, answer, sources)
+	}
+	return answer, elapsed, sources, false, nil
+}
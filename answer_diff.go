@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 单个问题在两个快照上的回答对比
+type answerDiff struct {
+	Question   string
+	Base       string
+	Candidate  string
+	Similarity float64  // 两个回答的词重合度
+	BaseRecall float64  // 基线回答的答案召回率
+	CandRecall float64  // 候选回答的答案召回率
+	Removed    []string // 只在基线中被引用的文档
+	Added      []string // 只在候选中被引用的文档
+	Err        string
+}
+
+// 答案召回率下降超过该值视为退化
+const diffRecallDrop = 0.2
+
+// 变化类型
+func (d answerDiff) status(threshold float64) string {
+	switch {
+	case d.Err != "":
+		return "失败"
+	case d.CandRecall < d.BaseRecall-diffRecallDrop:
+		return "退化"
+	case d.CandRecall > d.BaseRecall+diffRecallDrop:
+		return "改善"
+	case d.Similarity < threshold || len(d.Removed) > 0 || len(d.Added) > 0:
+		return "变化"
+	}
+	return "不变"
+}
+
+// diff命令：用评测集分别在基线和候选两个集合/索引上问答，生成回答变化的对照报告，
+// 用于大批量入库或删除后、切换到新快照前的验证
+func runAnswerDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	setPath := fs.String("set", getEnv("EVAL_SET", "eval_set.json"), "评测集文件")
+	base := fs.String("base", "", "基线集合/索引，默认使用当前配置的集合/索引")
+	candidate := fs.String("candidate", "", "候选集合/索引")
+	threshold := fs.Float64("threshold", 0.8, "回答词重合度低于该值视为有变化")
+	out := fs.String("out", "answer_diff.md", "Markdown对照报告")
+	failOnRegression := fs.Bool("fail-on-regression", false, "存在退化的问题时返回错误，便于在流水线中拦截")
+	reportDir := fs.String("report-dir", "", "另把逐题结果（CSV）和对照报告（Markdown）写入该目录，文件名带运行时间")
+	_ = fs.Parse(args)
+
+	if *candidate == "" {
+		return fmt.Errorf("请通过 -candidate 指定候选集合/索引")
+	}
+	set, err := loadEvalSet(*setPath)
+	if err != nil {
+		return err
+	}
+
+	baseRAG, err := newSnapshotRAG(*base)
+	if err != nil {
+		return err
+	}
+	defer baseRAG.Close()
+	candidateRAG, err := newSnapshotRAG(*candidate)
+	if err != nil {
+		return err
+	}
+	defer candidateRAG.Close()
+
+	runAt := time.Now()
+	diffs := make([]answerDiff, 0, len(set.Cases))
+	counts := make(map[string]int)
+	for i, c := range set.Cases {
+		d := answerDiff{Question: c.Question}
+		baseAnswer, _, baseSources, err := baseRAG.GetRAGAnswer(context.Background(), c.Question, searchOptions{})
+		if err == nil {
+			var candidateSources []SearchResult
+			d.Candidate, _, candidateSources, err = candidateRAG.GetRAGAnswer(context.Background(), c.Question, searchOptions{})
+			d.Base = baseAnswer
+			d.Similarity = answerSimilarity(baseAnswer, d.Candidate)
+			d.BaseRecall = answerRecall(c.Answer, baseAnswer)
+			d.CandRecall = answerRecall(c.Answer, d.Candidate)
+			d.Removed, d.Added = diffSourceDocs(baseSources, candidateSources)
+		}
+		if err != nil {
+			d.Err = err.Error()
+		}
+		status := d.status(*threshold)
+		counts[status]++
+		diffs = append(diffs, d)
+		fmt.Printf("%s [%d/%d] %s（重合度 %.0f%%）\n", diffStatusIcon[status], i+1, len(set.Cases), c.Question, d.Similarity*100)
+	}
+
+	if err := os.WriteFile(*out, []byte(formatAnswerDiff(diffs, *threshold)), 0o644); err != nil {
+		return fmt.Errorf("保存对照报告失败: %w", err)
+	}
+	fmt.Printf("\n📊 共 %d 个问题：不变 %d，变化 %d，改善 %d，退化 %d，失败 %d\n",
+		len(diffs), counts["不变"], counts["变化"], counts["改善"], counts["退化"], counts["失败"])
+	fmt.Printf("📄 对照报告: %s\n", *out)
+	if *reportDir != "" {
+		if err := exportReports(*reportDir, "diff", runAt, diffCSV(diffs, *threshold), formatAnswerDiff(diffs, *threshold)); err != nil {
+			return err
+		}
+	}
+
+	if *failOnRegression && counts["退化"] > 0 {
+		return fmt.Errorf("%d 个问题的回答退化", counts["退化"])
+	}
+	return nil
+}
+
+var diffStatusIcon = map[string]string{
+	"不变": "✅",
+	"变化": "🔀",
+	"改善": "📈",
+	"退化": "📉",
+	"失败": "❌",
+}
+
+// 只检索指定集合/索引的RAG系统，复用联合检索的单索引路径
+func newSnapshotRAG(name string) (*RAGSystem, error) {
+	config := loadConfig()
+	if name != "" {
+		config.Federation = []FederatedIndex{{Name: name, Weight: 1}}
+	}
+	return NewRAGSystem(config)
+}
+
+// 两个回答的词重合度（Jaccard）
+func answerSimilarity(a, b string) float64 {
+	tokensA, tokensB := recallTokens(a), recallTokens(b)
+	if len(tokensA) == 0 && len(tokensB) == 0 {
+		return 1
+	}
+	shared := 0
+	for token := range tokensA {
+		if tokensB[token] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(tokensA)+len(tokensB)-shared)
+}
+
+// 对比两次回答引用的文档
+func diffSourceDocs(base, candidate []SearchResult) (removed, added []string) {
+	baseDocs, candidateDocs := make(map[string]bool), make(map[string]bool)
+	for _, source := range base {
+		baseDocs[source.DocID] = true
+	}
+	for _, source := range candidate {
+		candidateDocs[source.DocID] = true
+	}
+	for docID := range baseDocs {
+		if !candidateDocs[docID] {
+			removed = append(removed, docID)
+		}
+	}
+	for docID := range candidateDocs {
+		if !baseDocs[docID] {
+			added = append(added, docID)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return removed, added
+}
+
+// 生成Markdown对照报告，有变化的问题排在前面
+func formatAnswerDiff(diffs []answerDiff, threshold float64) string {
+	order := map[string]int{"退化": 0, "失败": 1, "变化": 2, "改善": 3, "不变": 4}
+	sorted := append([]answerDiff(nil), diffs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return order[sorted[i].status(threshold)] < order[sorted[j].status(threshold)]
+	})
+
+	counts := make(map[string]int)
+	for _, d := range diffs {
+		counts[d.status(threshold)]++
+	}
+	var builder strings.Builder
+	builder.WriteString("# 回答对照报告\n\n")
+	builder.WriteString("| 状态 | 问题数 |\n| --- | --- |\n")
+	for _, status := range []string{"不变", "变化", "改善", "退化", "失败"} {
+		builder.WriteString(fmt.Sprintf("| %s %s | %d |\n", diffStatusIcon[status], status, counts[status]))
+	}
+	builder.WriteString("\n| 状态 | 问题 | 基线回答 | 候选回答 | 重合度 | 答案召回 | 引用变化 |\n")
+	builder.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, d := range sorted {
+		status := d.status(threshold)
+		candidate := d.Candidate
+		if d.Err != "" {
+			candidate = "错误: " + d.Err
+		}
+		var sources []string
+		for _, docID := range d.Removed {
+			sources = append(sources, "-"+docID)
+		}
+		for _, docID := range d.Added {
+			sources = append(sources, "+"+docID)
+		}
+		builder.WriteString(fmt.Sprintf("| %s %s | %s | %s | %s | %.0f%% | %.0f%% → %.0f%% | %s |\n",
+			diffStatusIcon[status], status, markdownCell(d.Question), markdownCell(d.Base), markdownCell(candidate),
+			d.Similarity*100, d.BaseRecall*100, d.CandRecall*100, strings.Join(sources, " ")))
+	}
+	return builder.String()
+}
+
+// 表格单元格中不能有换行和竖线
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.ReplaceAll(strings.TrimSpace(text), "\n", "<br>")
+}
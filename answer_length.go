@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 回答长度档位：short、standard、detailed分别在提示词末尾加上篇幅要求，并把max_tokens设为该档位的硬上限（不续写，
+// 超出时按截断处理）。请求的length优先，其次是渠道的默认档位ANSWER_LENGTH_CHANNELS（ask、stream、telegram），
+// 最后是ANSWER_LENGTH；都为空时不限定档位，沿用max_tokens 500和ANSWER_MAX_CONTINUATIONS续写。
+// 答案缓存只保存和命中ANSWER_LENGTH档位的回答
+type AnswerLengthConfig struct {
+	Default   string
+	Channels  map[string]string // 渠道 -> 档位
+	MaxTokens map[string]int    // 档位 -> max_tokens
+}
+
+func loadAnswerLengthConfig() AnswerLengthConfig {
+	config := AnswerLengthConfig{
+		Default:   getEnv("ANSWER_LENGTH", ""),
+		Channels:  make(map[string]string),
+		MaxTokens: map[string]int{answerLengthShort: 200, answerLengthStandard: 500, answerLengthDetailed: 1500},
+	}
+	if err := validAnswerLength(config.Default); err != nil {
+		fmt.Printf("⚠️  忽略ANSWER_LENGTH: %v\n", err)
+		config.Default = ""
+	}
+	for _, item := range splitEnvList("ANSWER_LENGTH_CHANNELS") {
+		channel, length, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		if length = strings.TrimSpace(length); validAnswerLength(length) != nil {
+			fmt.Printf("⚠️  忽略渠道 %s 的长度档位: %s\n", channel, length)
+			continue
+		}
+		config.Channels[strings.TrimSpace(channel)] = length
+	}
+	for _, item := range splitEnvList("ANSWER_LENGTH_MAX_TOKENS") {
+		length, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n > 0 {
+			config.MaxTokens[strings.TrimSpace(length)] = n
+		}
+	}
+	return config
+}
+
+const (
+	answerLengthShort    = "short"
+	answerLengthStandard = "standard"
+	answerLengthDetailed = "detailed"
+)
+
+// 回答渠道，用于选择默认的长度档位
+const (
+	channelAsk      = "ask"      // POST /ask
+	channelStream   = "stream"   // POST /ask/stream
+	channelTelegram = "telegram" // Telegram机器人
+)
+
+// 各档位追加在问题后的篇幅要求
+var answerLengthInstructions = map[string]string{
+	answerLengthShort:    "请用不超过三句话简要回答，只给出结论。",
+	answerLengthStandard: "",
+	answerLengthDetailed: "请详细回答，分点说明，并给出上下文中相关的细节和例子。",
+}
+
+func validAnswerLength(length string) error {
+	if _, ok := answerLengthInstructions[length]; ok || length == "" {
+		return nil
+	}
+	return fmt.Errorf("未知的length: %s，可选 short、standard、detailed", length)
+}
+
+// 请求使用的档位：请求指定的优先，其次是渠道的默认档位和ANSWER_LENGTH
+func (c AnswerLengthConfig) resolve(requested, channel string) string {
+	if requested != "" {
+		return requested
+	}
+	if length, ok := c.Channels[channel]; ok {
+		return length
+	}
+	return c.Default
+}
+
+// 档位为ANSWER_LENGTH时才读写答案缓存
+func (c AnswerLengthConfig) cacheable(length string) bool {
+	return length == c.Default
+}
+
+// 按档位调整请求：最后一条消息追加篇幅要求，max_tokens设为档位上限
+func (c AnswerLengthConfig) apply(request openai.ChatCompletionRequest, length string) openai.ChatCompletionRequest {
+	if length == "" {
+		return request
+	}
+	if instruction := answerLengthInstructions[length]; instruction != "" {
+		messages := append([]openai.ChatCompletionMessage(nil), request.Messages...)
+		messages[len(messages)-1].Content += "\n\n" + instruction
+		request.Messages = messages
+	}
+	request.MaxTokens = c.MaxTokens[length]
+	return request
+}
+
+type hardLimitKey struct{}
+
+// 指定了长度档位时max_tokens是硬上限，达到上限时不续写
+func withAnswerLength(ctx context.Context, length string) context.Context {
+	if length == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, hardLimitKey{}, true)
+}
+
+// 达到长度上限时最多续写的次数
+func (r *RAGSystem) continuations(ctx context.Context) int {
+	if hard, _ := ctx.Value(hardLimitKey{}).(bool); hard {
+		return 0
+	}
+	return r.config.Continuations
+}
+
+// 按长度档位生成回答
+func (r *RAGSystem) completeWithLength(ctx context.Context, request openai.ChatCompletionRequest, length string) (string, error) {
+	return r.completeAnswer(withAnswerLength(ctx, length), r.config.AnswerLength.apply(request, length))
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// 默认抓取的中文维基百科词条，围绕RAG相关主题
+var defaultWikiTitles = []string{
+	"检索增强生成", "大型语言模型", "向量数据库", "词嵌入", "余弦相似性",
+	"Elasticsearch", "Transformer模型", "深度求索", "Go", "微信公众平台",
+}
+
+// 维基百科数据集的演示问题
+var defaultWikiQuestions = []string{
+	"什么是检索增强生成？",
+	"向量数据库适合哪些应用场景？",
+	"Elasticsearch是用什么语言开发的？",
+	"深度求索公司成立于哪一年？",
+}
+
+// bootstrap命令下载并保存的数据集，存在时替换内置示例文档
+type bootstrapDataset struct {
+	Source    string          `json:"source"`
+	CreatedAt time.Time       `json:"created_at"`
+	Questions []string        `json:"questions"`
+	Records   []datasetRecord `json:"documents"`
+}
+
+// 数据集中的一篇文档，自定义数据集使用相同字段的JSONL格式，每行一篇
+type datasetRecord struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Category string `json:"category,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Date     string `json:"date,omitempty"`
+	URL      string `json:"url,omitempty"`
+	// 内容许可和署名，回答引用CC BY等要求署名的来源时会附上署名
+	License     string `json:"license,omitempty"`
+	Attribution string `json:"attribution,omitempty"`
+}
+
+func (d datasetRecord) document() Document {
+	meta := map[string]interface{}{}
+	for key, value := range map[string]string{
+		"category":    d.Category,
+		"source":      d.Source,
+		"date":        d.Date,
+		"source_url":  d.URL,
+		"license":     d.License,
+		"attribution": d.Attribution,
+	} {
+		if value != "" {
+			meta[key] = value
+		}
+	}
+	return Document{ID: d.ID, Title: d.Title, Content: d.Content, Meta: meta}
+}
+
+// 读取已保存的数据集，文件不存在时返回nil
+func loadBootstrapDataset(path string) (*bootstrapDataset, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dataset bootstrapDataset
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return nil, fmt.Errorf("解析数据集 %s 失败: %w", path, err)
+	}
+	return &dataset, nil
+}
+
+func (d *bootstrapDataset) documents() []Document {
+	if d == nil {
+		return nil
+	}
+	documents := make([]Document, 0, len(d.Records))
+	for _, record := range d.Records {
+		documents = append(documents, record.document())
+	}
+	return documents
+}
+
+func saveBootstrapDataset(path string, dataset *bootstrapDataset) error {
+	data, err := json.MarshalIndent(dataset, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// bootstrap命令：下载公开数据集入库并运行演示，替代内置的两篇示例文档
+func runBootstrap(args []string) error {
+	fs := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	source := fs.String("dataset", getEnv("BOOTSTRAP_DATASET", "wikipedia"), "数据集: wikipedia，或JSONL数据集的URL/本地路径")
+	titles := fs.String("titles", strings.Join(defaultWikiTitles, ","), "wikipedia数据集抓取的词条，逗号分隔")
+	questions := fs.String("questions", "", "演示问题，逗号分隔；wikipedia数据集默认使用内置问题")
+	demo := fs.Bool("demo", true, "入库后运行对比演示")
+	_ = fs.Parse(args)
+
+	config := loadConfig()
+	ctx := context.Background()
+	client := &http.Client{Timeout: config.Crawler.Timeout}
+
+	dataset := &bootstrapDataset{Source: *source, CreatedAt: time.Now()}
+	var err error
+	fmt.Printf("📥 正在下载数据集: %s\n", *source)
+	if *source == "wikipedia" {
+		dataset.Records, err = fetchWikipedia(ctx, client, config.Crawler.UserAgent, strings.Split(*titles, ","))
+		dataset.Questions = defaultWikiQuestions
+	} else {
+		dataset.Records, err = fetchJSONLDataset(ctx, client, *source)
+	}
+	if err != nil {
+		return err
+	}
+	if len(dataset.Records) == 0 {
+		return fmt.Errorf("数据集为空")
+	}
+	var custom []string
+	for _, q := range strings.Split(*questions, ",") {
+		if q = strings.TrimSpace(q); q != "" {
+			custom = append(custom, q)
+		}
+	}
+	if len(custom) > 0 {
+		dataset.Questions = custom
+	}
+
+	if err := saveBootstrapDataset(config.BootstrapFile, dataset); err != nil {
+		return fmt.Errorf("保存数据集失败: %w", err)
+	}
+	fmt.Printf("✅ 已下载 %d 篇文档，保存到 %s\n", len(dataset.Records), config.BootstrapFile)
+
+	rag, err := NewRAGSystem(config)
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	fmt.Println("\n📚 正在初始化知识库...")
+	if err := rag.InitializeKnowledgeBase(); err != nil {
+		return fmt.Errorf("初始化知识库失败: %w", err)
+	}
+	fmt.Println("✅ 知识库初始化完成")
+
+	if *demo && len(dataset.Questions) > 0 {
+		runComparison(rag, dataset.Questions)
+	}
+	return nil
+}
+
+// 通过MediaWiki API获取中文维基百科词条的纯文本
+func fetchWikipedia(ctx context.Context, client *http.Client, userAgent string, titles []string) ([]datasetRecord, error) {
+	today := time.Now().Format("2006-01-02")
+	var records []datasetRecord
+	for _, title := range titles {
+		title = strings.TrimSpace(title)
+		if title == "" {
+			continue
+		}
+
+		params := url.Values{
+			"action":        {"query"},
+			"format":        {"json"},
+			"formatversion": {"2"},
+			"prop":          {"extracts|info"},
+			"inprop":        {"url"},
+			"explaintext":   {"1"},
+			"redirects":     {"1"},
+			"variant":       {"zh-cn"},
+			"titles":        {title},
+		}
+		var response struct {
+			Query struct {
+				Pages []struct {
+					PageID  int64  `json:"pageid"`
+					Title   string `json:"title"`
+					Extract string `json:"extract"`
+					FullURL string `json:"fullurl"`
+					Missing bool   `json:"missing"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := getJSON(ctx, client, userAgent, "https://zh.wikipedia.org/w/api.php?"+params.Encode(), &response); err != nil {
+			return nil, fmt.Errorf("获取词条 %s 失败: %w", title, err)
+		}
+
+		for _, page := range response.Query.Pages {
+			if page.Missing || strings.TrimSpace(page.Extract) == "" {
+				fmt.Printf("⚠️  词条不存在，跳过: %s\n", title)
+				continue
+			}
+			records = append(records, datasetRecord{
+				ID:       fmt.Sprintf("wiki_%d", page.PageID),
+				Title:    page.Title,
+				Content:  strings.TrimSpace(page.Extract),
+				Category: "维基百科",
+				Source:   "wikipedia",
+				Date:     today,
+				URL:      page.FullURL,
+				License:  "CC-BY-SA-4.0",
+			})
+		}
+	}
+	return records, nil
+}
+
+// 读取JSONL格式的数据集，location可以是URL或本地路径
+func fetchJSONLDataset(ctx context.Context, client *http.Client, location string) ([]datasetRecord, error) {
+	var reader io.Reader
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("下载数据集失败: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("下载数据集失败: HTTP %d", resp.StatusCode)
+		}
+		reader = resp.Body
+	} else {
+		file, err := os.Open(location)
+		if err != nil {
+			return nil, fmt.Errorf("打开数据集失败: %w", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var records []datasetRecord
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record datasetRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("解析第 %d 行失败: %w", line, err)
+		}
+		if record.ID == "" || record.Content == "" {
+			return nil, fmt.Errorf("第 %d 行缺少id或content", line)
+		}
+		if record.Title == "" {
+			record.Title = record.ID
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取数据集失败: %w", err)
+	}
+	return records, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, userAgent, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 同一问题的流式回答广播：相同问题正在流式生成时，后到的请求订阅同一个生成过程，先收到目前为止的内容，
+// 之后与首个请求同步收到增量，而不是各自重新生成或只拿到最终结果。生成使用独立于请求的ctx，
+// 全部订阅者断开后才取消；随REQUEST_COALESCING开启
+type answerBroadcasts struct {
+	mu      sync.Mutex
+	streams map[string]*answerStream
+	joined  atomic.Int64 // 订阅进行中生成的请求数
+}
+
+// 一次流式生成，同时作为生成过程的输出端，把内容广播给订阅者
+type answerStream struct {
+	mu          sync.Mutex
+	text        string        // 目前为止的完整答案
+	changed     chan struct{} // 内容变化或生成结束时关闭并换新
+	done        bool
+	answer      string
+	sources     []SearchResult
+	reviewID    int64 // 回答进入人工审核时的审核ID
+	err         error
+	subscribers int // 由answerBroadcasts.mu保护
+	cancel      context.CancelFunc
+}
+
+func newAnswerBroadcasts(enabled bool) *answerBroadcasts {
+	if !enabled {
+		return nil
+	}
+	return &answerBroadcasts{streams: make(map[string]*answerStream)}
+}
+
+// 执行或订阅同一问题的流式生成，sink收到的内容与直接生成时相同
+func (g *answerBroadcasts) do(ctx context.Context, key string, sink replySink, generate func(ctx context.Context, sink replySink) (string, []SearchResult, int64, error)) (string, []SearchResult, int64, error) {
+	if g == nil {
+		return generate(ctx, sink)
+	}
+
+	g.mu.Lock()
+	stream, ok := g.streams[key]
+	if ok {
+		g.joined.Add(1)
+		fmt.Println("📡 订阅进行中的相同问题的流式回答")
+	} else {
+		streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		stream = &answerStream{changed: make(chan struct{}), cancel: cancel}
+		g.streams[key] = stream
+		go g.run(streamCtx, key, stream, generate)
+	}
+	stream.subscribers++
+	g.mu.Unlock()
+
+	answer, sources, reviewID, err := stream.follow(ctx, sink)
+
+	// 最后一个订阅者离开时生成还没结束（断开或输出失败），取消生成
+	g.mu.Lock()
+	if stream.subscribers--; stream.subscribers == 0 {
+		stream.cancel()
+		g.forget(key, stream)
+	}
+	g.mu.Unlock()
+	return answer, sources, reviewID, err
+}
+
+func (g *answerBroadcasts) run(ctx context.Context, key string, stream *answerStream, generate func(ctx context.Context, sink replySink) (string, []SearchResult, int64, error)) {
+	defer stream.cancel()
+	answer, sources, reviewID, err := generate(ctx, stream)
+	// 先移除再通知，之后到达的相同问题重新生成或命中缓存
+	g.mu.Lock()
+	g.forget(key, stream)
+	g.mu.Unlock()
+
+	stream.mu.Lock()
+	stream.answer, stream.sources, stream.reviewID, stream.err, stream.done = answer, sources, reviewID, err, true
+	close(stream.changed)
+	stream.mu.Unlock()
+}
+
+func (g *answerBroadcasts) forget(key string, stream *answerStream) {
+	if g.streams[key] == stream {
+		delete(g.streams, key)
+	}
+}
+
+// 订阅进行中流式回答的请求数，未开启时为0
+func (g *answerBroadcasts) Joined() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.joined.Load()
+}
+
+func (s *answerStream) Update(text string) error {
+	s.publish(text)
+	return nil
+}
+
+func (s *answerStream) Finish(text string) error {
+	s.publish(text)
+	return nil
+}
+
+func (s *answerStream) publish(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.text = text
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// 把生成过程输出到sink直到结束；sink跟不上时跳过中间内容，只输出最新的完整答案
+func (s *answerStream) follow(ctx context.Context, sink replySink) (string, []SearchResult, int64, error) {
+	sent := ""
+	for {
+		s.mu.Lock()
+		text, changed, done := s.text, s.changed, s.done
+		answer, sources, reviewID, err := s.answer, s.sources, s.reviewID, s.err
+		s.mu.Unlock()
+
+		if done {
+			// 出错时与直接生成一样不再输出，已经输出的部分回答随错误返回
+			if err != nil {
+				return answer, sources, reviewID, err
+			}
+			return answer, sources, reviewID, sink.Finish(answer)
+		}
+		if text != sent {
+			if err := sink.Update(text); err != nil {
+				return "", nil, 0, err
+			}
+			sent = text
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return "", nil, 0, ctx.Err()
+		}
+	}
+}
+
+type askStreamRequest struct {
+	Question string `json:"question"`
+	Fresh    bool   `json:"fresh"`            // 跳过答案缓存，强制重新生成
+	Length   string `json:"length,omitempty"` // 回答长度档位：short、standard、detailed，不填时使用渠道的默认档位
+}
+
+// 流式回答结束时的done事件
+type askStreamDone struct {
+	Answer    string         `json:"answer"`
+	Sources   []SearchResult `json:"sources"`
+	Truncated bool           `json:"truncated,omitempty"`
+	ReviewID  int64          `json:"review_id,omitempty"` // 回答进入人工审核时的审核ID，answer为等待提示，通过 GET /review/item 查询审核后的回答
+}
+
+// 以SSE输出流式回答：delta为新增的文本，答案被整体替换（例如降级为分块摘录）时发送reset和完整答案
+type sseSink struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	last    string
+}
+
+func (s *sseSink) Update(text string) error {
+	if text == s.last {
+		return nil
+	}
+	event, data := "delta", strings.TrimPrefix(text, s.last)
+	if !strings.HasPrefix(text, s.last) {
+		event, data = "reset", text
+	}
+	s.last = text
+	return s.event(event, map[string]string{"text": data})
+}
+
+func (s *sseSink) Finish(text string) error {
+	return s.Update(text)
+}
+
+func (s *sseSink) event(name string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// 流式提问（SSE）：依次发送delta/reset事件，结束时发送done（完整答案和来源）或error；
+// 同一问题正在生成时订阅同一个生成过程
+func (s *apiServer) handleAskStream(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("只支持%s请求", http.MethodPost))
+		return
+	}
+	var body askStreamRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if body.Question == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("question不能为空"))
+		return
+	}
+	if err := validAnswerLength(body.Length); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("当前连接不支持流式输出"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	sink := &sseSink{w: w, flusher: flusher}
+	_, rag := s.ragFor(body.Question)
+	answer, sources, reviewID, err := rag.StreamRAGAnswer(req.Context(), body.Question, body.Fresh, s.rag.config.AnswerLength.resolve(body.Length, channelStream), sink)
+	if err != nil {
+		kind := classifyError(http.StatusInternalServerError, err)
+		_ = sink.event("error", errorResponse{Error: err.Error(), Code: kind.code, Retryable: kind.retryable})
+		return
+	}
+	_ = sink.event("done", askStreamDone{Answer: answer, Sources: sources, Truncated: isTruncated(answer), ReviewID: reviewID})
+}
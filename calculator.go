@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 工具调用最大轮数，防止模型反复调用
+const maxCalculatorRounds = 5
+
+// 问题中表示需要计算的关键词
+var calculationKeywords = []string{
+	"多少", "总共", "一共", "合计", "总和", "相加", "相差", "差距", "增长", "增长率", "增幅", "涨幅", "下降",
+	"平均", "比例", "占比", "百分之", "倍", "计算", "翻了", "sum", "total", "growth", "average", "ratio", "percent",
+}
+
+var numberPattern = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// 一次计算步骤
+type calcStep struct {
+	Expression string
+	Result     string
+}
+
+// 问题需要计算且上下文中有可计算的数字时，交给计算器工具处理
+func needsCalculation(question string, results []SearchResult) bool {
+	lower := strings.ToLower(question)
+	asked := false
+	for _, keyword := range calculationKeywords {
+		if strings.Contains(lower, keyword) {
+			asked = true
+			break
+		}
+	}
+	if !asked {
+		return false
+	}
+
+	numbers := len(numberPattern.FindAllString(question, -1))
+	for _, result := range results {
+		numbers += len(numberPattern.FindAllString(result.Content, -1))
+	}
+	return numbers >= 2
+}
+
+// 计算器工具定义
+var calculatorTool = openai.Tool{
+	Type: openai.ToolTypeFunction,
+	Function: openai.FunctionDefinition{
+		Name:        "calculator",
+		Description: "计算算术表达式，支持 + - * / ^ 括号和百分号，例如 (2300-2000)/2000*100",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"expression": map[string]interface{}{
+					"type":        "string",
+					"description": "只包含数字和运算符的算术表达式",
+				},
+			},
+			"required": []string{"expression"},
+		},
+	},
+}
+
+// 通过工具调用完成计算：模型负责从上下文中取数和列式，计算交给calculator工具
+func (r *RAGSystem) answerWithCalculator(ctx context.Context, question string, results []SearchResult, model string) (string, []calcStep, error) {
+	request := r.ragChatRequest(question, results, model)
+	request.Tools = []openai.Tool{calculatorTool}
+	// 指令追加在用户消息末尾，不改动系统提示词，避免破坏上下文缓存前缀
+	last := len(request.Messages) - 1
+	request.Messages[last].Content += "\n涉及数值计算时，必须调用calculator工具计算，不要自己心算。"
+
+	recordPrompt(ctx, request)
+	var steps []calcStep
+	for round := 0; round < maxCalculatorRounds; round++ {
+		if err := r.faults.inject(ctx, faultTargetLLM, "DeepSeek计算器工具调用"); err != nil {
+			return "", steps, llmError(ctx, err)
+		}
+		resp, err := r.chatClient(request.Model).CreateChatCompletion(ctx, request)
+		if err != nil {
+			return "", steps, llmError(ctx, err)
+		}
+		if len(resp.Choices) == 0 {
+			return "", steps, fmt.Errorf("%w: 未收到回答", ErrLLMUnavailable)
+		}
+
+		message := resp.Choices[0].Message
+		if len(message.ToolCalls) == 0 {
+			return message.Content, steps, nil
+		}
+
+		// 执行工具调用并把结果回传给模型
+		request.Messages = append(request.Messages, message)
+		for _, call := range message.ToolCalls {
+			var args struct {
+				Expression string `json:"expression"`
+			}
+			output := ""
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				output = "参数解析失败: " + err.Error()
+			} else if value, err := evaluateExpression(args.Expression); err != nil {
+				output = "计算失败: " + err.Error()
+			} else {
+				output = formatNumber(value)
+				steps = append(steps, calcStep{Expression: args.Expression, Result: output})
+			}
+			request.Messages = append(request.Messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    output,
+				ToolCallID: call.ID,
+			})
+		}
+	}
+	return "", steps, fmt.Errorf("计算器工具调用超过 %d 轮", maxCalculatorRounds)
+}
+
+// 将计算步骤附加到答案后面
+func appendCalcSteps(answer string, steps []calcStep) string {
+	if len(steps) == 0 {
+		return answer
+	}
+	var builder strings.Builder
+	builder.WriteString(answer)
+	builder.WriteString("\n\n🧮 计算过程:\n")
+	for i, step := range steps {
+		builder.WriteString(fmt.Sprintf("%d. %s = %s\n", i+1, step.Expression, step.Result))
+	}
+	return builder.String()
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// 计算算术表达式
+func evaluateExpression(expression string) (float64, error) {
+	p := &exprParser{input: strings.ReplaceAll(expression, ",", "")}
+	value, err := p.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("无法识别的字符 %q", p.input[p.pos:])
+	}
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("计算结果无效")
+	}
+	return value, nil
+}
+
+// 递归下降表达式解析器
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// expr := term (('+'|'-') term)*
+func (p *exprParser) parseExpr() (float64, error) {
+	value, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			rhs, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			value += rhs
+		case '-':
+			p.pos++
+			rhs, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			value -= rhs
+		default:
+			return value, nil
+		}
+	}
+}
+
+// term := unary (('*'|'/') unary)*
+func (p *exprParser) parseTerm() (float64, error) {
+	value, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '*':
+			p.pos++
+			rhs, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			value *= rhs
+		case '/':
+			p.pos++
+			rhs, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			if rhs == 0 {
+				return 0, fmt.Errorf("除数为0")
+			}
+			value /= rhs
+		default:
+			return value, nil
+		}
+	}
+}
+
+// unary := '-' unary | power，负号的优先级低于乘方，-2^2 = -4
+func (p *exprParser) parseUnary() (float64, error) {
+	if p.peek() == '-' {
+		p.pos++
+		value, err := p.parseUnary()
+		return -value, err
+	}
+	return p.parsePower()
+}
+
+// power := percent ('^' unary)?，右结合，2^3^2 = 2^9，指数可以带负号
+func (p *exprParser) parsePower() (float64, error) {
+	base, err := p.parsePercent()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() == '^' {
+		p.pos++
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exponent), nil
+	}
+	return base, nil
+}
+
+// percent := primary '%'?
+func (p *exprParser) parsePercent() (float64, error) {
+	value, err := p.parsePrimary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() == '%' {
+		p.pos++
+		value /= 100
+	}
+	return value, nil
+}
+
+// primary := number | '(' expr ')'
+func (p *exprParser) parsePrimary() (float64, error) {
+	if p.peek() == '(' {
+		p.pos++
+		value, err := p.parseExpr()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("缺少右括号")
+		}
+		p.pos++
+		return value, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, fmt.Errorf("位置 %d 处缺少数字", start)
+	}
+	return strconv.ParseFloat(p.input[start:p.pos], 64)
+}
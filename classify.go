@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 文档分类配置：入库时由大模型把没有分类的文档归入分类体系，分类写入元数据category，
+// 检索时可按分类过滤，开启路由时先对问题分类再只检索该分类
+type ClassifyConfig struct {
+	Taxonomy     []string // 分类体系，为空时不分类
+	ExamplesFile string   // 少样本示例
+	Routing      bool     // 按问题分类路由检索
+}
+
+func loadClassifyConfig() ClassifyConfig {
+	return ClassifyConfig{
+		Taxonomy:     splitEnvList(getEnv("CLASSIFY_TAXONOMY", "人物介绍,公众号介绍,技术文章,生活随笔,其他")),
+		ExamplesFile: getEnv("CLASSIFY_EXAMPLES", "classify_examples.json"),
+		Routing:      getEnvAsBool("CLASSIFY_ROUTING", false),
+	}
+}
+
+// 少样本示例
+type classifyExample struct {
+	Text     string `json:"text"`
+	Category string `json:"category"`
+}
+
+// 分类器
+type classifier struct {
+	taxonomy []string
+	examples []classifyExample
+	routing  bool
+}
+
+// 创建分类器，未配置分类体系时返回nil；示例文件不存在时不使用示例
+func newClassifier(config ClassifyConfig) (*classifier, error) {
+	if len(config.Taxonomy) == 0 {
+		return nil, nil
+	}
+	c := &classifier{taxonomy: config.Taxonomy, routing: config.Routing}
+	if config.ExamplesFile == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(config.ExamplesFile)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.examples); err != nil {
+		return nil, fmt.Errorf("解析分类示例 %s 失败: %w", config.ExamplesFile, err)
+	}
+	return c, nil
+}
+
+// 分类使用的文档开头长度（字符数）
+const classifyTextLimit = 800
+
+// 对文本分类，模型给出的分类不在分类体系中时返回空
+func (r *RAGSystem) classify(ctx context.Context, text string) (string, error) {
+	c := r.classifier
+	if runes := []rune(text); len(runes) > classifyTextLimit {
+		text = string(runes[:classifyTextLimit])
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role: openai.ChatMessageRoleSystem,
+			Content: fmt.Sprintf("你负责给知识库文档分类。可选分类：%s。只输出一个分类名称，不要输出其他内容；都不合适时输出最接近的分类。",
+				strings.Join(c.taxonomy, "、")),
+		},
+	}
+	for _, example := range c.examples {
+		messages = append(messages,
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: example.Text},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: example.Category},
+		)
+	}
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: text})
+
+	if err := r.faults.inject(ctx, faultTargetLLM, "DeepSeek文档分类"); err != nil {
+		return "", err
+	}
+	resp, err := r.openAIClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:       r.config.DeepSeekModel,
+		Messages:    messages,
+		Temperature: 0,
+		MaxTokens:   20,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("未收到分类结果")
+	}
+	return c.match(resp.Choices[0].Message.Content), nil
+}
+
+// 把模型输出对应到分类体系，兼容带标点或多余说明的输出
+func (c *classifier) match(output string) string {
+	output = strings.Trim(trimCodeFence(output), " \n\"'“”。.")
+	for _, category := range c.taxonomy {
+		if output == category {
+			return category
+		}
+	}
+	for _, category := range c.taxonomy {
+		if strings.Contains(output, category) {
+			return category
+		}
+	}
+	return ""
+}
+
+// 为没有分类的文档分类，分类失败只告警不影响入库
+func (r *RAGSystem) classifyDocuments(documents []Document) {
+	if r.classifier == nil {
+		return
+	}
+	ctx := context.Background()
+	classified := 0
+	for i := range documents {
+		doc := &documents[i]
+		if category, _ := doc.Meta["category"].(string); category != "" {
+			continue
+		}
+		category, err := r.classify(ctx, doc.Title+"\n"+doc.Content)
+		if err != nil {
+			fmt.Printf("⚠️  文档 %s 分类失败: %v\n", doc.ID, err)
+			continue
+		}
+		if category == "" {
+			continue
+		}
+		if doc.Meta == nil {
+			doc.Meta = make(map[string]interface{})
+		}
+		doc.Meta["category"] = category
+		doc.Meta["category_by"] = "llm"
+		classified++
+	}
+	if classified > 0 {
+		fmt.Printf("🏷️  自动分类了 %d 个文档\n", classified)
+	}
+}
+
+// 开启路由时对问题分类，失败时返回空即全库检索
+func (r *RAGSystem) routeQuestion(ctx context.Context, question string) string {
+	if r.classifier == nil || !r.classifier.routing {
+		return ""
+	}
+	category, err := r.classify(ctx, question)
+	if err != nil {
+		fmt.Printf("⚠️  问题分类失败: %v\n", err)
+		return ""
+	}
+	if category != "" {
+		fmt.Printf("🧭 问题路由到分类: %s\n", category)
+	}
+	return category
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 维护命令
+var commands = map[string]func(args []string) error{
+	"advise":      runAdvise,
+	"analytics":   runAnalytics,
+	"bootstrap":   runBootstrap,
+	"diff":        runAnswerDiff,
+	"eval":        runEval,
+	"expire":      runExpire,
+	"gaps":        runGaps,
+	"gc":          runGC,
+	"imap":        runIMAP,
+	"ingestbench": runIngestBench,
+	"kafka":       runKafka,
+	"loadtest":    runLoadtest,
+	"maintain":    runMaintain,
+	"openapi":     runOpenAPI,
+	"projector":   runProjector,
+	"rechunk":     runRechunk,
+	"reembed":     runReembed,
+	"rollout":     runRollout,
+	"s3sync":      runS3Sync,
+	"schema":      runSchema,
+	"secrets":     runSecrets,
+	"serve":       runServe,
+	"sitemap":     runSitemap,
+	"sqlsync":     runSQLSync,
+	"telegram":    runTelegram,
+}
+
+// 执行子命令
+func runCommand(name string, args []string) error {
+	command, ok := commands[name]
+	if !ok {
+		var names []string
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("未知命令: %s，可用命令: %s", name, strings.Join(names, ", "))
+	}
+	return command(args)
+}
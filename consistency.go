@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// 写入一致性：eventual写入后立即返回，新文档要等ES的索引刷新（默认1秒）或Milvus的有界过期时间后才能检索到；
+// strong在返回前刷新索引（ES refresh，Milvus flush后重新加载），写入后马上检索也能查到，代价是写入变慢
+const (
+	consistencyEventual = "eventual"
+	consistencyStrong   = "strong"
+)
+
+// 请求未指定时使用WRITE_CONSISTENCY配置
+func resolveConsistency(requested, fallback string) (string, error) {
+	if requested == "" {
+		requested = fallback
+	}
+	switch requested {
+	case consistencyEventual, consistencyStrong:
+		return requested, nil
+	}
+	return "", fmt.Errorf("consistency只能是%s或%s", consistencyEventual, consistencyStrong)
+}
+
+// 替换文档，strong一致性时等写入可检索后再返回
+func (r *RAGSystem) replaceDocumentsWith(documents []Document, consistency string) error {
+	if err := r.ReplaceDocuments(documents); err != nil {
+		return err
+	}
+	return r.awaitVisible(consistency)
+}
+
+// strong一致性时刷新，使刚写入的分块可检索
+func (r *RAGSystem) awaitVisible(consistency string) error {
+	if consistency != consistencyStrong {
+		return nil
+	}
+	return r.makeVisible(context.Background())
+}
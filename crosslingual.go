@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 跨语言检索配置：问题语言与语料语言不同时，先由大模型把问题翻译成语料语言再检索，回答仍使用原问题。
+// 示例的稠密向量按字符生成，译文同时用于向量检索和关键词检索；答案缓存和抽取式回答的问题向量化
+// 需要配置多语言的embedding模型（EMBEDDING_PROVIDER=openai）才能跨语言匹配
+type CrossLingualConfig struct {
+	Translate bool
+	Corpus    string // 语料语言：zh、en
+}
+
+func loadCrossLingualConfig() CrossLingualConfig {
+	return CrossLingualConfig{
+		Translate: getEnvAsBool("CROSS_LINGUAL_TRANSLATE", false),
+		Corpus:    getEnv("CORPUS_LANGUAGE", "zh"),
+	}
+}
+
+var languageNames = map[string]string{"zh": "简体中文", "en": "英文"}
+
+// 粗略判断文本语言：含汉字视为中文，只有拉丁字母视为英文，其余无法判断
+func detectLanguage(text string) string {
+	latin := false
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			return "zh"
+		}
+		if unicode.In(r, unicode.Latin) {
+			latin = true
+		}
+	}
+	if latin {
+		return "en"
+	}
+	return ""
+}
+
+// 最多缓存的译文数，写满后清空
+const maxCachedTranslations = 1000
+
+// 问题译文缓存，相同问题不重复调用大模型
+type translationCache struct {
+	mu    sync.Mutex
+	texts map[string]string
+}
+
+func (c *translationCache) get(question string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	text, ok := c.texts[question]
+	return text, ok
+}
+
+func (c *translationCache) put(question, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.texts == nil || len(c.texts) >= maxCachedTranslations {
+		c.texts = make(map[string]string)
+	}
+	c.texts[question] = text
+}
+
+// 检索使用的问题：语言与语料不同时翻译成语料语言，翻译失败时使用原问题
+func (r *RAGSystem) searchQuery(ctx context.Context, question string) string {
+	config := r.config.CrossLingual
+	target, ok := languageNames[config.Corpus]
+	if !config.Translate || !ok {
+		return question
+	}
+	if lang := detectLanguage(question); lang == "" || lang == config.Corpus {
+		return question
+	}
+	if text, ok := r.translations.get(question); ok {
+		return text
+	}
+
+	text, err := r.translate(ctx, question, target)
+	if err != nil {
+		fmt.Printf("⚠️  问题翻译失败: %v\n", err)
+		return question
+	}
+	fmt.Printf("🌐 问题翻译为%s: %s\n", target, text)
+	r.translations.put(question, text)
+	return text
+}
+
+func (r *RAGSystem) translate(ctx context.Context, question, target string) (string, error) {
+	if err := r.faults.inject(ctx, faultTargetLLM, "DeepSeek问题翻译"); err != nil {
+		return "", err
+	}
+	resp, err := r.openAIClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: r.config.DeepSeekModel,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: fmt.Sprintf("把用户的问题翻译成%s，用于检索知识库。人名、产品名等专有名词使用%s中的常见写法。只输出译文，不要回答问题。", target, target),
+			},
+			{Role: openai.ChatMessageRoleUser, Content: question},
+		},
+		Temperature: 0,
+		MaxTokens:   200,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("未收到译文")
+	}
+	text := strings.TrimSpace(trimCodeFence(resp.Choices[0].Message.Content))
+	if text == "" {
+		return "", fmt.Errorf("译文为空")
+	}
+	return text, nil
+}
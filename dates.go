@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // 容器镜像中可能没有时区数据库
+)
+
+// 日期配置：入库时把元数据date中的各种写法（2024-05-01、2024/5/1、2024年5月1日、May 1, 2024、RFC3339等）
+// 解析为时间戳写入date_ts；问题中的相对时间（"最近一个月""上周""去年"）按TIMEZONE解析为时间范围，只检索date在范围内的文档。
+// 没有时区的日期按TIMEZONE理解
+type DateConfig struct {
+	Timezone string
+	Location *time.Location
+}
+
+func loadDateConfig() DateConfig {
+	name := getEnv("TIMEZONE", "Asia/Shanghai")
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		fmt.Printf("⚠️  无法加载时区 %s，使用系统时区: %v\n", name, err)
+		location = time.Local
+	}
+	return DateConfig{Timezone: name, Location: location}
+}
+
+const (
+	dateKey          = "date"
+	dateTimestampKey = "date_ts" // 入库时由date解析的Unix时间戳（秒）
+)
+
+// 数字日期：年月日之间用-、/、.或年月日分隔，可以只有年月，后面可以跟时间
+var numericDatePattern = regexp.MustCompile(`^(\d{4})\s*[-/.年]\s*(\d{1,2})\s*(?:[-/.月]\s*(\d{1,2})\s*[日号]?|月)?(?:[T\s]+(\d{1,2}):(\d{1,2})(?::(\d{1,2}))?)?$`)
+
+// 中文的时间写法（10时30分、10点30分）换成冒号分隔
+var chineseTimeReplacer = strings.NewReplacer("时", ":", "点", ":", "分", ":", "秒", "")
+
+var yearOnlyPattern = regexp.MustCompile(`^(\d{4})\s*年?$`)
+
+// 其他常见写法，没有时区的按TIMEZONE理解
+var dateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.ANSIC,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Jan 2006",
+	"January 2006",
+	"20060102",
+}
+
+// 解析元数据中的日期，返回该日期（只有年月或年时为开始时刻）
+func parseDocumentDate(value string, location *time.Location) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if m := numericDatePattern.FindStringSubmatch(strings.TrimSuffix(chineseTimeReplacer.Replace(value), ":")); m != nil {
+		parts := make([]int, len(m)-1)
+		for i, s := range m[1:] {
+			parts[i], _ = strconv.Atoi(s)
+		}
+		year, month, day := parts[0], parts[1], max(parts[2], 1)
+		t := time.Date(year, time.Month(month), day, parts[3], parts[4], parts[5], 0, location)
+		// time.Date会把超出范围的值进位，不一致说明不是合法日期
+		if month < 1 || month > 12 || t.Day() != day || parts[3] > 23 || parts[4] > 59 || parts[5] > 59 {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	if m := yearOnlyPattern.FindStringSubmatch(value); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		return time.Date(year, 1, 1, 0, 0, 0, 0, location), true
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// 入库前解析文档元数据中的date，写入date_ts；无法解析时只告警，date原样保留
+func (c DateConfig) normalizeDocuments(documents []Document) {
+	for i := range documents {
+		doc := &documents[i]
+		value, ok := doc.Meta[dateKey].(string)
+		if !ok || value == "" {
+			continue
+		}
+		t, ok := parseDocumentDate(value, c.Location)
+		if !ok {
+			fmt.Printf("⚠️  文档 %s 的日期无法解析: %s\n", doc.ID, value)
+			continue
+		}
+		doc.Meta[dateTimestampKey] = t.Unix()
+	}
+}
+
+// 分块元数据中的日期：优先使用入库时解析的date_ts，旧数据没有时解析date（按UTC理解）
+func metaTime(meta map[string]interface{}) (time.Time, bool) {
+	switch ts := meta[dateTimestampKey].(type) {
+	case float64:
+		return time.Unix(int64(ts), 0), true
+	case int64:
+		return time.Unix(ts, 0), true
+	case int:
+		return time.Unix(int64(ts), 0), true
+	}
+	return parseDocumentDate(metaString(meta, dateKey), time.UTC)
+}
+
+// 检索的时间范围[From, To)，由问题中的相对时间解析，范围按天对齐
+type dateRange struct {
+	From   time.Time
+	To     time.Time
+	Phrase string // 问题中的相对时间表述
+}
+
+func (p *dateRange) contains(t time.Time) bool {
+	return !t.Before(p.From) && t.Before(p.To)
+}
+
+// 用于缓存和合并请求的键，没有时间范围时为空
+func (p *dateRange) key() string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", p.From.Unix(), p.To.Unix())
+}
+
+func (p *dateRange) String() string {
+	return fmt.Sprintf("%s至%s", p.From.Format("2006-01-02"), p.To.Add(-time.Second).Format("2006-01-02"))
+}
+
+// 相对时间表述及其对应的范围，today为当天零点，范围的结束默认为明天零点
+type relativeDateRule struct {
+	pattern *regexp.Regexp
+	resolve func(m []string, today time.Time) (from, to time.Time, ok bool)
+}
+
+func untilTomorrow(from time.Time, today time.Time) (time.Time, time.Time, bool) {
+	return from, today.AddDate(0, 0, 1), true
+}
+
+// 向前推n个单位，"半"为0.5个单位；按天和周计算时包含今天，"最近三天"是前天到今天
+func shiftBack(today time.Time, n float64, unit string) time.Time {
+	switch unit {
+	case "天", "日", "day":
+		return today.AddDate(0, 0, 1-int(math.Ceil(n)))
+	case "周", "星期", "礼拜", "week":
+		return today.AddDate(0, 0, 1-int(math.Ceil(n*7)))
+	case "月", "month":
+		if n != math.Trunc(n) {
+			return today.AddDate(0, -int(n), -15)
+		}
+		return today.AddDate(0, -int(n), 0)
+	default: // 年
+		return today.AddDate(-int(n), -int((n-math.Trunc(n))*12), 0)
+	}
+}
+
+// 本周从周一开始
+func startOfWeek(today time.Time) time.Time {
+	return today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+}
+
+func startOfMonth(today time.Time) time.Time {
+	return time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+}
+
+func startOfYear(today time.Time) time.Time {
+	return time.Date(today.Year(), 1, 1, 0, 0, 0, 0, today.Location())
+}
+
+// 按顺序匹配，"最近N天"这类带数量的表述在前
+var relativeDateRules = []relativeDateRule{
+	{regexp.MustCompile(`(?:最近|近|过去)\s*(\d+|[一二两三四五六七八九十]+|半)\s*个?\s*(天|日|周|星期|礼拜|月|年)`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		n, ok := parseCount(m[1])
+		if !ok || n <= 0 {
+			return time.Time{}, time.Time{}, false
+		}
+		return untilTomorrow(shiftBack(today, n, m[2]), today)
+	}},
+	{regexp.MustCompile(`(?i)\b(?:last|past)\s+(\d+)\s+(day|week|month|year)s?\b`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		n, _ := strconv.Atoi(m[1])
+		if n <= 0 {
+			return time.Time{}, time.Time{}, false
+		}
+		return untilTomorrow(shiftBack(today, float64(n), strings.ToLower(m[2])), today)
+	}},
+	{regexp.MustCompile(`今天|今日|(?i)\btoday\b`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		return untilTomorrow(today, today)
+	}},
+	{regexp.MustCompile(`昨天|昨日|(?i)\byesterday\b`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		return today.AddDate(0, 0, -1), today, true
+	}},
+	{regexp.MustCompile(`前天`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		return today.AddDate(0, 0, -2), today.AddDate(0, 0, -1), true
+	}},
+	{regexp.MustCompile(`本周|这周|这个?星期|本星期|(?i)\bthis week\b`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		return untilTomorrow(startOfWeek(today), today)
+	}},
+	{regexp.MustCompile(`上周|上个?星期|(?i)\blast week\b`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		week := startOfWeek(today)
+		return week.AddDate(0, 0, -7), week, true
+	}},
+	{regexp.MustCompile(`本月|这个月|(?i)\bthis month\b`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		return untilTomorrow(startOfMonth(today), today)
+	}},
+	{regexp.MustCompile(`上个?月|(?i)\blast month\b`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		month := startOfMonth(today)
+		return month.AddDate(0, -1, 0), month, true
+	}},
+	{regexp.MustCompile(`今年|本年|(?i)\bthis year\b`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		return untilTomorrow(startOfYear(today), today)
+	}},
+	{regexp.MustCompile(`去年|(?i)\blast year\b`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		year := startOfYear(today)
+		return year.AddDate(-1, 0, 0), year, true
+	}},
+	{regexp.MustCompile(`前年`), func(m []string, today time.Time) (time.Time, time.Time, bool) {
+		year := startOfYear(today)
+		return year.AddDate(-2, 0, 0), year.AddDate(-1, 0, 0), true
+	}},
+}
+
+var chineseDigits = map[rune]int{'一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+
+// 解析数量：阿拉伯数字、一百以内的中文数字或"半"
+func parseCount(s string) (float64, bool) {
+	if s == "半" {
+		return 0.5, true
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return float64(n), true
+	}
+	tens, units := 0, 0
+	for _, r := range s {
+		if r == '十' {
+			if tens > 0 {
+				return 0, false
+			}
+			tens = max(units, 1)
+			units = 0
+			continue
+		}
+		digit, ok := chineseDigits[r]
+		if !ok || units > 0 {
+			return 0, false
+		}
+		units = digit
+	}
+	return float64(tens*10 + units), true
+}
+
+// 问题含相对时间（上周、上个月等）时检索范围随日期变化，这类问题不读写答案缓存
+func (c DateConfig) relativeQuestion(question string) bool {
+	return c.relativePeriod(question, time.Now()) != nil
+}
+
+// 解析问题中的相对时间，按TIMEZONE的当前日期计算范围；没有相对时间时返回nil
+func (c DateConfig) relativePeriod(question string, now time.Time) *dateRange {
+	local := now.In(c.Location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.Location)
+	for _, rule := range relativeDateRules {
+		m := rule.pattern.FindStringSubmatch(question)
+		if m == nil {
+			continue
+		}
+		if from, to, ok := rule.resolve(m, today); ok {
+			return &dateRange{From: from, To: to, Phrase: m[0]}
+		}
+	}
+	return nil
+}
+
+// 去掉日期不在时间范围内的检索结果，用于无法在存储端过滤的检索路径；没有日期的分块也去掉
+func (o searchOptions) dropOutOfPeriod(results []SearchResult) []SearchResult {
+	if o.Period == nil {
+		return results
+	}
+	kept := results[:0]
+	for _, result := range results {
+		if t, ok := metaTime(result.Meta); ok && o.Period.contains(t) {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+// 问题中有相对时间时附在问题后的说明，让大模型按同一时区理解"今年""上周"等表述
+func (c DateConfig) questionNote(question string, now time.Time) string {
+	period := c.relativePeriod(question, now)
+	if period == nil {
+		return ""
+	}
+	return fmt.Sprintf("（今天是%s，\"%s\"指%s）", now.In(c.Location).Format("2006-01-02"), period.Phrase, period)
+}
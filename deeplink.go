@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 原文定位链接配置：按分块元数据生成指向原文具体位置的稳定链接，随检索结果返回（deep_link），
+// 界面和机器人可以直接把用户带到引用的位置
+type DeepLinkConfig struct {
+	FileBase       string // 本地文件的浏览地址前缀，例如 https://github.com/org/docs/blob/main，路径取相对DOCS_DIR的路径；为空时生成file://链接
+	ConfluenceBase string // Confluence站点地址，元数据带confluence_page_id时生成页面链接
+	NotionBase     string // 元数据带notion_page_id时生成页面链接
+}
+
+func loadDeepLinkConfig() DeepLinkConfig {
+	return DeepLinkConfig{
+		FileBase:       getEnv("DEEP_LINK_FILE_BASE", ""),
+		ConfluenceBase: getEnv("CONFLUENCE_BASE_URL", ""),
+		NotionBase:     getEnv("NOTION_BASE_URL", "https://www.notion.so"),
+	}
+}
+
+// 文本片段最多使用的字符数，取分块开头的一段，足以在页面中唯一定位
+const maxFragmentRunes = 30
+
+// 为检索结果附上定位到原文位置的链接
+func (r *RAGSystem) linkLocations(results []SearchResult) {
+	for i := range results {
+		results[i].DeepLink = r.deepLink(results[i])
+	}
+}
+
+// 按来源生成链接，依次尝试Confluence页面（标题锚点）、Notion页面、网页（文本片段）和本地文件（标题锚点）
+func (r *RAGSystem) deepLink(result SearchResult) string {
+	config := r.config.DeepLink
+	heading := metaString(result.Meta, "heading")
+
+	if pageID := metaString(result.Meta, "confluence_page_id"); pageID != "" && config.ConfluenceBase != "" {
+		link := strings.TrimSuffix(config.ConfluenceBase, "/") + "/pages/viewpage.action?pageId=" + url.QueryEscape(pageID)
+		if heading != "" {
+			link += "#" + confluenceAnchor(result.Title, heading)
+		}
+		return link
+	}
+	if pageID := metaString(result.Meta, "notion_page_id"); pageID != "" && config.NotionBase != "" {
+		return strings.TrimSuffix(config.NotionBase, "/") + "/" + strings.ReplaceAll(pageID, "-", "")
+	}
+	if source := metaString(result.Meta, "source_url"); strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return withTextFragment(source, result.Content)
+	}
+	if path := metaString(result.Meta, "source_path"); path != "" {
+		link := r.fileLink(path)
+		if heading != "" {
+			link += "#" + headingSlug(heading)
+		}
+		return link
+	}
+	return ""
+}
+
+// 本地文件的链接：配置了浏览地址时拼接相对DOCS_DIR的路径，否则为file://绝对路径
+func (r *RAGSystem) fileLink(path string) string {
+	base := strings.TrimSuffix(r.config.DeepLink.FileBase, "/")
+	if base == "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	}
+	if r.config.DocsDir != "" {
+		if rel, err := filepath.Rel(r.config.DocsDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	segments := strings.Split(strings.TrimPrefix(filepath.ToSlash(path), "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return base + "/" + strings.Join(segments, "/")
+}
+
+// GitHub风格的标题锚点：转小写，去掉标点，空格换成连字符，保留中文
+func headingSlug(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// Confluence的标题锚点：页面标题和标题文字去掉空格后用连字符连接
+func confluenceAnchor(title, heading string) string {
+	strip := func(s string) string { return strings.Join(strings.Fields(s), "") }
+	return url.PathEscape(strip(title) + "-" + strip(heading))
+}
+
+// 网页链接加上文本片段（#:~:text=），支持的浏览器打开后滚动到分块开头并高亮
+func withTextFragment(rawURL, content string) string {
+	if strings.Contains(rawURL, ":~:") {
+		return rawURL
+	}
+	text := fragmentText(content)
+	if text == "" {
+		return rawURL
+	}
+	if strings.Contains(rawURL, "#") {
+		return rawURL + ":~:text=" + escapeFragmentText(text)
+	}
+	return rawURL + "#:~:text=" + escapeFragmentText(text)
+}
+
+// 分块的第一个完整句子，去掉句末标点，过长时截取开头
+func fragmentText(content string) string {
+	for _, sentence := range splitSentences(content) {
+		sentence = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(sentence), sentenceDelimiters))
+		if sentence == "" || markdownHeading(sentence) != "" {
+			continue
+		}
+		if runes := []rune(sentence); len(runes) > maxFragmentRunes {
+			sentence = strings.TrimSpace(string(runes[:maxFragmentRunes]))
+		}
+		return sentence
+	}
+	return ""
+}
+
+// 文本片段中除字母数字和 ._~ 外都需要百分号编码，连字符、逗号和&在片段语法中有特殊含义
+func escapeFragmentText(text string) string {
+	var b strings.Builder
+	for _, c := range []byte(text) {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// 元数据中的字符串或数字值，例如页面ID
+func metaString(meta map[string]interface{}, key string) string {
+	switch v := meta[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// 分块元数据加上所在的标题，用于生成标题锚点
+func withHeading(meta map[string]interface{}, heading string) map[string]interface{} {
+	if heading == "" {
+		return meta
+	}
+	enriched := make(map[string]interface{}, len(meta)+1)
+	for key, value := range meta {
+		enriched[key] = value
+	}
+	enriched["heading"] = heading
+	return enriched
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 降级档位
+const (
+	tierKeyword    = "keyword"    // 向量检索失败，改用关键词检索（ES）
+	tierLLMOnly    = "llm_only"   // 知识库不可用，大模型不参考知识库直接回答
+	tierExtractive = "extractive" // 大模型不可用，返回检索到的分块摘录
+)
+
+// 服务降级配置：大模型不可用时返回检索到的分块摘录；知识库不可用时可选让大模型直接回答，
+// 直接回答没有知识库依据，默认关闭。降级的回答标注所用档位，不写入答案缓存
+type DegradeConfig struct {
+	Extractive bool
+	LLMOnly    bool
+	Snippets   int // 摘录的分块数
+}
+
+func loadDegradeConfig() DegradeConfig {
+	return DegradeConfig{
+		Extractive: getEnvAsBool("DEGRADE_EXTRACTIVE", true),
+		LLMOnly:    getEnvAsBool("DEGRADE_LLM_ONLY", false),
+		Snippets:   getEnvAsInt("DEGRADE_SNIPPETS", 3),
+	}
+}
+
+// 单次请求经历的降级档位，联合检索时多个索引可能并发写入
+type degradation struct {
+	mu    sync.Mutex
+	tiers []string
+}
+
+func (d *degradation) mark(tiers ...string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, tier := range tiers {
+		if !containsString(d.tiers, tier) {
+			d.tiers = append(d.tiers, tier)
+		}
+	}
+}
+
+func (d *degradation) Tiers() []string {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.tiers...)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 检索失败时按配置改由大模型直接回答，不能降级时原样返回检索错误；请求已取消或要求抽取式回答时不降级
+func (r *RAGSystem) answerWithoutRetrieval(ctx context.Context, question string, opts searchOptions, err error) (string, error) {
+	if !r.config.Degrade.LLMOnly || opts.Extractive || ctx.Err() != nil {
+		return "", err
+	}
+	fmt.Printf("⚠️  检索失败: %v\n", err)
+	opts.Degraded.mark(tierLLMOnly)
+	answer, err := r.completeAnswer(withReasoning(ctx, opts.Reasoning), r.directChatRequest(question, opts.Model))
+	if err != nil {
+		return "", err
+	}
+	return llmOnlyNotice + answer, nil
+}
+
+// 生成失败时退回分块摘录，不能降级时原样返回生成错误
+func (r *RAGSystem) answerExtractive(ctx context.Context, results []SearchResult, opts searchOptions, err error) (string, error) {
+	if !r.config.Degrade.Extractive || len(results) == 0 || ctx.Err() != nil {
+		return "", err
+	}
+	fmt.Printf("⚠️  生成回答失败: %v\n", err)
+	opts.Degraded.mark(tierExtractive)
+	return appendAttribution(r.extractiveAnswer(results), results), nil
+}
+
+// 知识库不可用时的提问，要求模型说明回答未经知识库核实
+func (r *RAGSystem) directChatRequest(question, model string) openai.ChatCompletionRequest {
+	if model == "" {
+		model = r.config.DeepSeekModel
+	}
+	return openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("知识库暂时不可用，请根据你自己的知识简要回答，不确定时直接说明不知道。\n\n问题：%s", question),
+			},
+		},
+		Temperature: 0.1,
+		MaxTokens:   500,
+	}
+}
+
+const (
+	llmOnlyNotice    = "（知识库暂时不可用，以下回答未参考知识库）\n\n"
+	extractiveNotice = "（大模型暂时不可用，以下为知识库中最相关的内容摘录）\n\n"
+	snippetRunes     = 200
+)
+
+// 大模型不可用时的回答：列出得分最高的几个分块的开头部分
+func (r *RAGSystem) extractiveAnswer(results []SearchResult) string {
+	var b strings.Builder
+	b.WriteString(extractiveNotice)
+	for i, result := range results {
+		if i == r.config.Degrade.Snippets {
+			break
+		}
+		snippet := []rune(strings.TrimSpace(result.Content))
+		if len(snippet) > snippetRunes {
+			snippet = append(snippet[:snippetRunes], []rune("……")...)
+		}
+		fmt.Fprintf(&b, "%d. %s（%s）：%s\n", i+1, result.Title, citationSource(result), string(snippet))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
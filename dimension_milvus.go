@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 集合中各稠密向量字段的维度，集合不存在时ok为false
+func (r *RAGSystem) indexedVectorDims(ctx context.Context) (string, map[string]int, bool, error) {
+	collectionName := r.config.CollectionName
+	exists, err := r.milvusClient.HasCollection(ctx, collectionName)
+	if err != nil {
+		return "", nil, false, storeError(ctx, "检查集合存在失败", err)
+	}
+	if !exists {
+		return "", nil, false, nil
+	}
+	collection, err := r.milvusClient.DescribeCollection(ctx, collectionName)
+	if err != nil {
+		return "", nil, false, storeError(ctx, "查询集合信息失败", err)
+	}
+	name := "集合 " + collectionName
+	dims := make(map[string]int)
+	for _, field := range collection.Schema.Fields {
+		if field.DataType != entity.FieldTypeFloatVector {
+			continue
+		}
+		dim, err := strconv.Atoi(field.TypeParams["dim"])
+		if err != nil {
+			return "", nil, false, fmt.Errorf("%s 的%s字段维度无效: %q", name, field.Name, field.TypeParams["dim"])
+		}
+		dims[field.Name] = dim
+	}
+	return name, dims, true, nil
+}
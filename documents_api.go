@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// POST /documents 的限制
+type DocumentLimits struct {
+	MaxBodyBytes int64 // 请求体大小上限
+	MaxDocuments int   // 单次请求的文档数上限
+	MaxContent   int   // 单个文档正文的字符数上限
+}
+
+func loadDocumentLimits() DocumentLimits {
+	return DocumentLimits{
+		MaxBodyBytes: int64(getEnvAsInt("DOCUMENTS_MAX_BODY_MB", 10)) << 20,
+		MaxDocuments: getEnvAsInt("DOCUMENTS_MAX_BATCH", 100),
+		MaxContent:   getEnvAsInt("DOCUMENT_MAX_CHARS", 100000),
+	}
+}
+
+// 外部系统推送的文档
+type documentPayload struct {
+	ID      string                 `json:"id,omitempty"` // 不填时按标题和正文生成，重复推送同一内容会替换而不是新增
+	Title   string                 `json:"title"`
+	Content string                 `json:"content"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+	Vector  []float32              `json:"vector,omitempty"` // 预计算的向量，维度与分类使用的向量模型一致，不填时入库时生成
+}
+
+type documentsRequest struct {
+	Documents   []documentPayload `json:"documents"`
+	Consistency string            `json:"consistency,omitempty"` // eventual、strong，不填时使用WRITE_CONSISTENCY
+}
+
+type documentsResponse struct {
+	Documents int      `json:"documents"`
+	IDs       []string `json:"ids"`
+}
+
+const (
+	maxDocumentIDLen = 90 // 分块ID在文档ID后追加#序号，集合中id字段最长100
+	maxTitleLen      = 200
+	maxMetaKeys      = 50
+)
+
+var documentIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:\-]+$`)
+
+// 校验失败的字段，一次返回所有问题
+type validationErrors []string
+
+func (v validationErrors) Error() string {
+	return "文档校验失败: " + strings.Join(v, "；")
+}
+
+// 校验并转换为入库文档，vectorDim返回分类使用的向量模型的维度
+func (l DocumentLimits) validate(payloads []documentPayload, vectorDim func(category string) int) ([]Document, error) {
+	if len(payloads) == 0 {
+		return nil, fmt.Errorf("documents不能为空")
+	}
+	if len(payloads) > l.MaxDocuments {
+		return nil, fmt.Errorf("单次最多提交 %d 个文档，收到 %d 个", l.MaxDocuments, len(payloads))
+	}
+
+	var problems validationErrors
+	seen := make(map[string]int)
+	documents := make([]Document, 0, len(payloads))
+	for i, payload := range payloads {
+		field := func(name string) string { return fmt.Sprintf("documents[%d].%s", i, name) }
+		title, content := strings.TrimSpace(payload.Title), strings.TrimSpace(payload.Content)
+
+		switch {
+		case title == "":
+			problems = append(problems, field("title")+"不能为空")
+		case utf8.RuneCountInString(title) > maxTitleLen:
+			problems = append(problems, fmt.Sprintf("%s超过 %d 个字符", field("title"), maxTitleLen))
+		}
+		switch {
+		case content == "":
+			problems = append(problems, field("content")+"不能为空")
+		case utf8.RuneCountInString(content) > l.MaxContent:
+			problems = append(problems, fmt.Sprintf("%s超过 %d 个字符", field("content"), l.MaxContent))
+		}
+
+		id := payload.ID
+		if id == "" {
+			id = "api_" + hashText(title+"\n"+content)
+		}
+		switch {
+		case len(id) > maxDocumentIDLen:
+			problems = append(problems, fmt.Sprintf("%s超过 %d 个字节", field("id"), maxDocumentIDLen))
+		case !documentIDPattern.MatchString(id):
+			problems = append(problems, field("id")+"只能包含字母、数字和 _ . : -")
+		}
+		if first, ok := seen[id]; ok {
+			problems = append(problems, fmt.Sprintf("%s与documents[%d]重复", field("id"), first))
+		}
+		seen[id] = i
+
+		if err := validateMeta(payload.Meta); err != nil {
+			problems = append(problems, field("meta")+err.Error())
+		}
+		if payload.Vector != nil {
+			category, _ := payload.Meta["category"].(string)
+			if err := validateVector(payload.Vector, vectorDim(category)); err != nil {
+				problems = append(problems, field("vector")+err.Error())
+			}
+		}
+
+		documents = append(documents, Document{ID: id, Title: title, Content: content, Meta: payload.Meta, Vector: payload.Vector})
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return documents, nil
+}
+
+// 元数据只允许标量和标量数组，嵌套对象会让动态映射和统计查询出错
+func validateMeta(meta map[string]interface{}) error {
+	if len(meta) > maxMetaKeys {
+		return fmt.Errorf("最多 %d 个字段", maxMetaKeys)
+	}
+	for key, value := range meta {
+		if strings.TrimSpace(key) == "" || strings.ContainsAny(key, ".\"") {
+			return fmt.Errorf("字段名 %q 不合法", key)
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			switch v.(type) {
+			case string, float64, bool:
+			default:
+				return fmt.Errorf("字段 %s 只支持字符串、数字、布尔值及其数组", key)
+			}
+		}
+		if key == expiresAtKey {
+			if err := validateExpiresAt(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateVector(vector []float32, dim int) error {
+	if len(vector) != dim {
+		return fmt.Errorf("维度应为 %d，收到 %d", dim, len(vector))
+	}
+	var norm float64
+	for _, v := range vector {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("包含NaN或Inf")
+		}
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return fmt.Errorf("不能是零向量")
+	}
+	return nil
+}
+
+// POST /documents：校验后分块、生成向量并写入，已存在的同ID文档会被替换；任一文档校验失败时整批不写入
+func (s *apiServer) handleDocuments(w http.ResponseWriter, req *http.Request) {
+	var body documentsRequest
+	if !s.decodeDocumentsBody(w, req, &body) {
+		return
+	}
+
+	consistency, err := resolveConsistency(body.Consistency, s.rag.config.Consistency)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	documents, err := s.rag.config.DocLimits.validate(body.Documents, s.rag.categoryVectorDim)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.rag.replaceDocumentsWith(documents, consistency); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ids := make([]string, 0, len(documents))
+	for _, doc := range documents {
+		ids = append(ids, doc.ID)
+	}
+	writeJSON(w, http.StatusOK, documentsResponse{Documents: len(documents), IDs: ids})
+}
+
+// 按大小上限严格解析文档请求体，不认识的字段视为错误，避免拼错的字段名被静默忽略
+func (s *apiServer) decodeDocumentsBody(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	limit := s.rag.config.DocLimits.MaxBodyBytes
+	decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, limit))
+	decoder.DisallowUnknownFields()
+	var tooLarge *http.MaxBytesError
+	if err := decoder.Decode(v); errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("请求体超过 %d MB", limit>>20))
+		return false
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("解析请求失败: %w", err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 文本向量化配置：hash为本地字符n-gram哈希向量，openai为任意兼容OpenAI接口的embedding服务
+type EmbeddingConfig struct {
+	Provider   string
+	BaseURL    string
+	APIKey     string
+	APIKeyEnv  string // API key所在的环境变量，是密钥引用时每次请求取当前值
+	Model      string
+	Dim        int // hash向量维度
+	Dimensions int // openai请求的向量维度（模型需支持dimensions参数），0为模型默认维度
+	VectorDim  int // 大于0时检索使用的vector字段也由该模型生成，为模型输出的维度；0时使用内置简化向量
+}
+
+func loadEmbeddingConfig() EmbeddingConfig {
+	return EmbeddingConfig{
+		Provider:  getEnv("EMBEDDING_PROVIDER", "hash"),
+		BaseURL:   getEnv("EMBEDDING_BASE_URL", "https://api.openai.com/v1"),
+		APIKey:    getEnv("EMBEDDING_API_KEY", ""),
+		APIKeyEnv: "EMBEDDING_API_KEY",
+		Model:     getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		Dim:       getEnvAsInt("EMBEDDING_DIM", 256),
+		VectorDim: getEnvAsInt("EMBEDDING_VECTOR_DIM", 0),
+	}
+}
+
+// 文本向量化
+type embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+func newEmbedder(config EmbeddingConfig) (embedder, error) {
+	switch config.Provider {
+	case "hash":
+		return &hashEmbedder{dim: config.Dim}, nil
+	case "openai":
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("EMBEDDING_API_KEY不能为空")
+		}
+		client := &http.Client{Transport: rotatingAuth(http.DefaultTransport, config.APIKeyEnv, bearerAuth), Timeout: 30 * time.Second}
+		return &apiEmbedder{config: config, client: client}, nil
+	default:
+		return nil, fmt.Errorf("未知的EMBEDDING_PROVIDER: %s", config.Provider)
+	}
+}
+
+// 生成vector字段向量的模型，VectorDim为0时返回nil，使用内置简化向量
+func newVectorEmbedder(config EmbeddingConfig) (embedder, error) {
+	if config.VectorDim <= 0 {
+		return nil, nil
+	}
+	config.Dim = config.VectorDim
+	return newEmbedder(config)
+}
+
+// 本地哈希向量：把字符和相邻字符对哈希到固定维度，字面相近的文本向量相近，不需要外部服务
+type hashEmbedder struct {
+	dim int
+}
+
+func (e *hashEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	vector := make([]float32, e.dim)
+	var runes []rune
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+	add := func(gram string, weight float32) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(gram))
+		vector[h.Sum32()%uint32(e.dim)] += weight
+	}
+	for i, r := range runes {
+		add(string(r), 1)
+		if i > 0 {
+			add(string(runes[i-1:i+1]), 2)
+		}
+	}
+	normalize(vector)
+	return vector, nil
+}
+
+// 调用兼容OpenAI的/embeddings接口；go-openai的EmbeddingModel是枚举，无法指定任意模型名，这里直接发请求
+type apiEmbedder struct {
+	config EmbeddingConfig
+	client *http.Client
+}
+
+func (e *apiEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	request := map[string]interface{}{"model": e.config.Model, "input": []string{text}}
+	if e.config.Dimensions > 0 {
+		request["dimensions"] = e.config.Dimensions
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(e.config.BaseURL, "/")+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.config.APIKey)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbeddingFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: HTTP %d", ErrEmbeddingFailed, resp.StatusCode)
+	}
+
+	var result struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: 解析响应失败: %w", ErrEmbeddingFailed, err)
+	}
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("%w: 未收到向量", ErrEmbeddingFailed)
+	}
+	return result.Data[0].Embedding, nil
+}
+
+func normalize(vector []float32) {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+}
+
+// 余弦相似度
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
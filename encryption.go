@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// 静态加密：ENCRYPTION_KEY（base64编码的16、24或32字节AES密钥，可以写成密钥引用从Vault或KMS读取）不为空时，
+// 写入SQLite前用AES-GCM加密敏感字段：查询日志的问题和反馈说明，人工审核的问题、回答和引用，FAQ的问答、引用和问题向量。
+// 密文记录密钥指纹，更换密钥时把旧密钥放入ENCRYPTION_OLD_KEYS，旧记录仍可读取；未加密的历史记录原样读取。
+// 答案缓存和会话只保存在进程内存中，不落盘。FAQ的问题是主键，用由明文派生的nonce加密，相同问题的密文相同
+type EncryptionConfig struct {
+	Key     string
+	OldKeys []string
+}
+
+func loadEncryptionConfig() EncryptionConfig {
+	config := EncryptionConfig{Key: getEnv("ENCRYPTION_KEY", "")}
+	for _, key := range splitEnvList("ENCRYPTION_OLD_KEYS") {
+		config.OldKeys = append(config.OldKeys, resolveSecret("ENCRYPTION_OLD_KEYS", key))
+	}
+	return config
+}
+
+// 密文前缀，后接密钥指纹和base64编码的nonce+密文
+const encryptedPrefix = "enc:"
+
+// 按密钥加解密字段，未配置ENCRYPTION_KEY时为nil，nil时原样读写
+type fieldCipher struct {
+	current  string // 当前密钥的指纹
+	aeads    map[string]cipher.AEAD
+	nonceMAC []byte // 派生确定性nonce的HMAC密钥
+}
+
+func newFieldCipher(config EncryptionConfig) (*fieldCipher, error) {
+	if config.Key == "" {
+		return nil, nil
+	}
+	c := &fieldCipher{aeads: make(map[string]cipher.AEAD)}
+	for i, encoded := range append([]string{config.Key}, config.OldKeys...) {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || (len(key) != 16 && len(key) != 24 && len(key) != 32) {
+			return nil, fmt.Errorf("ENCRYPTION_KEY、ENCRYPTION_OLD_KEYS应为base64编码的16、24或32字节密钥")
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(key)
+		id := hex.EncodeToString(sum[:4])
+		c.aeads[id] = aead
+		if i == 0 {
+			c.current = id
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte("nonce"))
+			c.nonceMAC = mac.Sum(nil)
+		}
+	}
+	return c, nil
+}
+
+// 用随机nonce加密
+func (c *fieldCipher) Seal(text string) string {
+	if c == nil {
+		return text
+	}
+	nonce := make([]byte, c.aeads[c.current].NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Sprintf("生成nonce失败: %v", err))
+	}
+	return c.seal(nonce, text)
+}
+
+// 用明文派生的nonce加密，相同明文得到相同密文，用于需要按值查找或去重的字段
+func (c *fieldCipher) SealDeterministic(text string) string {
+	if c == nil {
+		return text
+	}
+	mac := hmac.New(sha256.New, c.nonceMAC)
+	mac.Write([]byte(text))
+	return c.seal(mac.Sum(nil)[:c.aeads[c.current].NonceSize()], text)
+}
+
+func (c *fieldCipher) seal(nonce []byte, text string) string {
+	sealed := c.aeads[c.current].Seal(nonce, nonce, []byte(text), nil)
+	return encryptedPrefix + c.current + ":" + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// 解密字段，没有密文前缀的按未加密的历史记录原样返回
+func (c *fieldCipher) Open(text string) (string, error) {
+	if !strings.HasPrefix(text, encryptedPrefix) {
+		return text, nil
+	}
+	if c == nil {
+		return "", fmt.Errorf("记录已加密，需配置ENCRYPTION_KEY")
+	}
+	id, encoded, _ := strings.Cut(strings.TrimPrefix(text, encryptedPrefix), ":")
+	aead, ok := c.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("记录使用的密钥 %s 不在ENCRYPTION_KEY、ENCRYPTION_OLD_KEYS中", id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("密文格式错误")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %w", err)
+	}
+	return string(plain), nil
+}
+
+// 依次解密多个字段，遇到错误时停止
+func (c *fieldCipher) OpenAll(fields ...*string) error {
+	for _, field := range fields {
+		plain, err := c.Open(*field)
+		if err != nil {
+			return err
+		}
+		*field = plain
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 实体抽取配置：入库时用规则和实体词典从分块中识别人物、机构和日期，写入分块元数据的people、organizations、dates，
+// 检索时可按实体过滤；开启路由时问题中提到人物或机构会先只检索提及该实体的分块
+type EntityConfig struct {
+	Enabled bool
+	File    string // 实体词典，登记常见称呼对应的规范名称
+	Routing bool
+}
+
+func loadEntityConfig() EntityConfig {
+	return EntityConfig{
+		Enabled: getEnvAsBool("ENTITY_EXTRACTION", true),
+		File:    getEnv("ENTITY_FILE", "entities.json"),
+		Routing: getEnvAsBool("ENTITY_ROUTING", false),
+	}
+}
+
+// 实体类型，对应元数据中的字段名
+const (
+	entityPerson       = "people"
+	entityOrganization = "organizations"
+	entityDate         = "dates"
+)
+
+// 实体词典条目
+type entityEntry struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"` // person、organization
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// 规则实体抽取器，不调用大模型，入库时每个分块都会执行
+type entityExtractor struct {
+	entries []entityEntry
+	routing bool
+}
+
+// 创建抽取器，未开启时返回nil；词典文件不存在时只使用规则
+func newEntityExtractor(config EntityConfig) (*entityExtractor, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	e := &entityExtractor{routing: config.Routing}
+	if config.File == "" {
+		return e, nil
+	}
+	data, err := os.ReadFile(config.File)
+	if os.IsNotExist(err) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &e.entries); err != nil {
+		return nil, fmt.Errorf("解析实体词典 %s 失败: %w", config.File, err)
+	}
+	return e, nil
+}
+
+// 常见单字姓氏，人名规则要求以姓氏开头，避免"我的同学"之类的误识别
+const commonSurnames = "王李张刘陈杨黄赵吴周徐孙马朱胡郭何高林罗郑梁谢宋唐许韩冯邓曹彭曾肖田董袁潘于蒋蔡余杜叶程苏魏吕丁任沈姚卢姜崔钟谭陆汪范金石廖贾夏韦付方白邹孟熊秦邱江尹薛闫阎段雷侯龙史陶黎贺顾毛郝龚邵万钱严覃武戴莫孔向汤"
+
+var (
+	// 姓氏加称谓，例如"闫同学""王建国教授"
+	personPattern = regexp.MustCompile(`[` + commonSurnames + `]\p{Han}{0,2}(?:同学|先生|女士|老师|教授|博士|总监|经理)`)
+	// 机构后缀，匹配到的前缀在介词等处截断，例如"毕业于清华大学"取"清华大学"
+	organizationPattern = regexp.MustCompile(`\p{Han}{2,10}(?:股份有限公司|有限公司|集团|大学|学院|研究院|研究所|银行|基金会|协会)|[A-Z][A-Za-z0-9&]*(?: [A-Z][A-Za-z0-9&]*)* (?:Inc|Corp|Ltd|LLC|Foundation)\b`)
+	// 年、年月、年月日，统一为2024、2024-05、2024-05-01
+	datePattern = regexp.MustCompile(`(\d{4})(?:年(?:(\d{1,2})月(?:(\d{1,2})[日号])?)?|[-/.](\d{1,2})[-/.](\d{1,2}))`)
+)
+
+// 机构名前缀的截断位置，截断字符及其之前的内容不属于机构名
+const organizationBreaks = "在于是的和与及到入自从为任职"
+
+// 每种实体最多保留的数量
+const maxEntitiesPerType = 20
+
+// 从文本中识别实体，按类型返回规范名称，按首次出现的顺序去重
+func (e *entityExtractor) Extract(text string) map[string][]string {
+	found := make(map[string][]string)
+	seen := make(map[string]bool)
+	add := func(kind, name string) {
+		key := kind + "\x00" + name
+		if name == "" || seen[key] || len(found[kind]) >= maxEntitiesPerType {
+			return
+		}
+		seen[key] = true
+		found[kind] = append(found[kind], name)
+	}
+
+	// 词典优先，别名统一为规范名称
+	lower := strings.ToLower(text)
+	for _, entry := range e.entries {
+		kind := entityPerson
+		if entry.Type == "organization" {
+			kind = entityOrganization
+		}
+		for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+			if name != "" && strings.Contains(lower, strings.ToLower(name)) {
+				add(kind, entry.Name)
+				break
+			}
+		}
+	}
+
+	for _, name := range personPattern.FindAllString(text, -1) {
+		add(entityPerson, e.canonical(name))
+	}
+	for _, name := range organizationPattern.FindAllString(text, -1) {
+		if i := strings.LastIndexAny(name, organizationBreaks); i >= 0 {
+			_, size := utf8.DecodeRuneInString(name[i:])
+			name = name[i+size:]
+		}
+		if len([]rune(name)) > 3 {
+			add(entityOrganization, e.canonical(name))
+		}
+	}
+	for _, match := range datePattern.FindAllStringSubmatch(text, -1) {
+		add(entityDate, normalizeDate(match))
+	}
+	return found
+}
+
+// 规则识别出的名称命中词典别名时换成规范名称
+func (e *entityExtractor) canonical(name string) string {
+	for _, entry := range e.entries {
+		for _, alias := range entry.Aliases {
+			if strings.EqualFold(alias, name) {
+				return entry.Name
+			}
+		}
+	}
+	return name
+}
+
+// 把日期匹配结果统一为年、年-月、年-月-日，年份或月日不合理时返回空
+func normalizeDate(match []string) string {
+	year, _ := strconv.Atoi(match[1])
+	if year < 1900 || year > 2100 {
+		return ""
+	}
+	month, day := match[2], match[3]
+	if match[4] != "" {
+		month, day = match[4], match[5]
+	}
+	if month == "" {
+		return match[1]
+	}
+	if m, _ := strconv.Atoi(month); m < 1 || m > 12 {
+		return ""
+	}
+	if day == "" {
+		return fmt.Sprintf("%s-%02s", match[1], month)
+	}
+	if d, _ := strconv.Atoi(day); d < 1 || d > 31 {
+		return ""
+	}
+	return fmt.Sprintf("%s-%02s-%02s", match[1], month, day)
+}
+
+// 分块的元数据：文档元数据加上分块中识别出的实体，文档已自带的同名字段不覆盖；没有识别出实体时原样返回
+func (e *entityExtractor) enrich(meta map[string]interface{}, text string) map[string]interface{} {
+	if e == nil {
+		return meta
+	}
+	found := e.Extract(text)
+	if len(found) == 0 {
+		return meta
+	}
+	enriched := make(map[string]interface{}, len(meta)+len(found))
+	for key, value := range meta {
+		enriched[key] = value
+	}
+	for kind, names := range found {
+		if _, ok := enriched[kind]; !ok {
+			enriched[kind] = names
+		}
+	}
+	return enriched
+}
+
+// 开启路由时取问题中提到的第一个人物，其次是机构
+func (e *entityExtractor) route(question string) string {
+	if e == nil || !e.routing {
+		return ""
+	}
+	found := e.Extract(question)
+	for _, kind := range []string{entityPerson, entityOrganization} {
+		if names := found[kind]; len(names) > 0 {
+			fmt.Printf("🧭 问题路由到实体: %s\n", names[0])
+			return names[0]
+		}
+	}
+	return ""
+}
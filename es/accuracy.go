@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 检索精度档位，由各存储映射为具体的搜索参数
+const (
+	accuracyFast     = "fast"
+	accuracyBalanced = "balanced"
+	accuracyAccurate = "accurate"
+)
+
+// 单次检索的精度参数，零值字段使用档位的默认值
+type searchOptions struct {
+	Profile        string           `json:"profile,omitempty"`        // fast、balanced、accurate，默认使用ACCURACY_PROFILE
+	EF             int              `json:"ef,omitempty"`             // Milvus HNSW搜索的ef
+	NProbe         int              `json:"nprobe,omitempty"`         // Milvus IVF索引搜索的nprobe
+	NumCandidates  int              `json:"num_candidates,omitempty"` // ES kNN的num_candidates
+	Category       string           `json:"-"`                        // 只检索该分类的文档，由请求的category或问题路由设置
+	Routed         string           `json:"-"`                        // 转发判断时已路由到的分类，检索时不再重复分类
+	Forwarded      bool             `json:"-"`                        // 由对等实例转发的请求，不再转发
+	Entity         string           `json:"-"`                        // 只检索提及该实体的分块，由请求的entity或问题路由设置
+	Exclude        []metaExclusion  `json:"-"`                        // 排除命中这些元数据取值的分块，由请求的exclude设置
+	ExcludeDocs    []string         `json:"-"`                        // 排除这些文档，由请求的exclude_docs和会话展示过的来源设置
+	Period         *dateRange       `json:"-"`                        // 只检索date在该范围内的文档，由问题中的相对时间设置
+	Features       featureSet       `json:"-"`                        // 开启的检索特性，由特性开关按问题和请求的分类确定
+	EmbeddingModel string           `json:"-"`                        // 查询向量使用的命名空间向量模型，为空时使用内置向量
+	LanguageFilter string           `json:"-"`                        // 按问题语言过滤的方式，由请求的language_filter设置，为空时使用LANGUAGE_FILTER
+	Language       string           `json:"-"`                        // 只检索该语言的分块，由语言过滤设置
+	Session        string           `json:"-"`                        // 会话ID，由请求的session设置
+	History        []SearchResult   `json:"-"`                        // 会话中与问题相关的历史问答，与检索结果一起作为上下文
+	Model          string           `json:"-"`                        // 生成回答的模型，由请求的model设置，为空时使用DEEPSEEK_MODEL
+	Reasoning      *strings.Builder `json:"-"`                        // 不为nil时收集推理模型的思考过程
+	Extractive     bool             `json:"-"`                        // 不调用大模型，从检索结果中摘句作答
+	Summarize      bool             `json:"-"`                        // 分批总结检索到的大量分块后综合回答
+	Direct         bool             `json:"-"`                        // 不检索知识库，由大模型直接回答
+	Length         string           `json:"-"`                        // 回答长度档位，由请求的length或渠道的默认档位设置
+	TopK           int              `json:"-"`                        // 检索的分块数，由问题中的topk操作符设置，0时使用检索配置
+	Operators      []string         `json:"-"`                        // 问题中解析出的查询操作符
+	Page           *searchPage      `json:"-"`                        // 分页检索的位置，nil时不分页
+	Degraded       *degradation     `json:"-"`                        // 不为nil时记录本次请求的降级档位
+	Prompts        *promptLog       `json:"-"`                        // 不为nil时记录发给大模型的提示词哈希，用于溯源清单
+}
+
+// 补全档位并校验参数
+func (o searchOptions) resolve(defaultProfile string) (searchOptions, error) {
+	if o.Profile == "" {
+		o.Profile = defaultProfile
+	}
+	switch o.Profile {
+	case accuracyFast, accuracyBalanced, accuracyAccurate:
+	default:
+		return o, fmt.Errorf("未知的精度档位: %s，可选 fast、balanced、accurate", o.Profile)
+	}
+	if o.EF < 0 || o.NProbe < 0 || o.NumCandidates < 0 {
+		return o, fmt.Errorf("ef、nprobe、num_candidates不能为负数")
+	}
+	return o, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// 调优建议的目标召回率
+const targetRecall = 0.95
+
+// 向量索引统计
+type indexStats struct {
+	Name        string
+	IndexType   string
+	Metric      string
+	Params      map[string]string // 构建参数，如M、efConstruction
+	Vectors     int64
+	Dim         int
+	Segments    int
+	StoreBytes  int64 // 磁盘占用，未知时为0
+	MemoryBytes int64 // 估算的向量索引内存
+}
+
+// 一组搜索参数的探测结果：召回率以精确检索为基准
+type indexProbe struct {
+	Setting string // 例如 ef=32、num_candidates=100
+	Value   int
+	Recall  float64       // 无法计算精确基准时为-1
+	Latency time.Duration // 平均延迟
+	Current bool          // 当前检索使用的参数
+}
+
+// advise命令：检查向量索引的统计信息，探测不同搜索参数的召回率和延迟，输出调优建议
+func runAdvise(args []string) error {
+	fs := flag.NewFlagSet("advise", flag.ExitOnError)
+	queries := fs.Int("queries", 20, "探测用的查询数，从知识库分块中抽取")
+	topK := fs.Int("k", 0, "探测的topK，默认使用TOP_K")
+	_ = fs.Parse(args)
+
+	config := loadConfig()
+	if *topK <= 0 {
+		*topK = config.Retrieval.TopK
+	}
+	rag, err := NewRAGSystem(config)
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	ctx := context.Background()
+	stats, err := rag.inspectIndex(ctx)
+	if err != nil {
+		return err
+	}
+	printIndexStats(stats)
+
+	documents, err := rag.sourceDocuments()
+	if err != nil {
+		return fmt.Errorf("加载源文档失败: %w", err)
+	}
+	probeTexts := probeQueries(documents, config.ChunkSize, *queries)
+	if len(probeTexts) == 0 {
+		return fmt.Errorf("知识库为空，无法探测")
+	}
+
+	fmt.Printf("\n🔬 使用 %d 个查询探测召回率和延迟（top%d）...\n", len(probeTexts), *topK)
+	probes, err := rag.probeIndex(ctx, stats, probeTexts, *topK)
+	if err != nil {
+		return err
+	}
+	for _, probe := range probes {
+		mark := "  "
+		if probe.Current {
+			mark = "👉"
+		}
+		recall := "     -"
+		if probe.Recall >= 0 {
+			recall = fmt.Sprintf("%5.1f%%", probe.Recall*100)
+		}
+		fmt.Printf("  %s %-22s 召回率 %s  平均延迟 %v\n", mark, probe.Setting, recall, probe.Latency.Round(10*time.Microsecond))
+	}
+
+	fmt.Println("\n💡 调优建议:")
+	advice := rag.adviseIndex(stats, probes)
+	if len(advice) == 0 {
+		advice = []string{"当前索引参数合理，无需调整"}
+	}
+	for _, item := range advice {
+		fmt.Printf("  - %s\n", item)
+	}
+	return nil
+}
+
+func printIndexStats(stats *indexStats) {
+	fmt.Printf("📊 索引 %s:\n", stats.Name)
+	fmt.Printf("  - 类型: %s（%s）\n", stats.IndexType, stats.Metric)
+	if len(stats.Params) > 0 {
+		keys := make([]string, 0, len(stats.Params))
+		for key := range stats.Params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Print("  - 构建参数:")
+		for _, key := range keys {
+			fmt.Printf(" %s=%s", key, stats.Params[key])
+		}
+		fmt.Println()
+	}
+	fmt.Printf("  - 向量数: %d（%d维）\n", stats.Vectors, stats.Dim)
+	fmt.Printf("  - 分段数: %d\n", stats.Segments)
+	if stats.StoreBytes > 0 {
+		fmt.Printf("  - 磁盘占用: %s\n", formatBytes(stats.StoreBytes))
+	}
+	fmt.Printf("  - 向量索引内存估算: %s\n", formatBytes(stats.MemoryBytes))
+}
+
+// 从知识库分块中抽取探测查询，取分块开头一段文本
+func probeQueries(documents []Document, chunkSize, n int) []string {
+	var texts []string
+	for _, doc := range documents {
+		for _, chunk := range splitDocument(doc, chunkSize) {
+			runes := []rune(chunk.Content)
+			if len(runes) > 40 {
+				runes = runes[:40]
+			}
+			texts = append(texts, string(runes))
+		}
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(texts), func(i, j int) {
+		texts[i], texts[j] = texts[j], texts[i]
+	})
+	if len(texts) > n {
+		texts = texts[:n]
+	}
+	return texts
+}
+
+// HNSW内存估算：原始向量 + 每层约2M个邻居链接
+func estimateHNSWMemory(vectors int64, dim, m int) int64 {
+	if m <= 0 {
+		m = 16
+	}
+	return vectors * int64(dim*4+m*2*4)
+}
+
+// 近似结果相对精确结果的召回率
+func recallAt(approx, exact []string) float64 {
+	if len(exact) == 0 {
+		return 1
+	}
+	found := make(map[string]bool, len(approx))
+	for _, id := range approx {
+		found[id] = true
+	}
+	hits := 0
+	for _, id := range exact {
+		if found[id] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(exact))
+}
+
+// 达到目标召回率的最小参数；探测按参数从小到大进行，参数越小延迟越低
+func bestProbe(probes []indexProbe) *indexProbe {
+	for i := range probes {
+		if probes[i].Recall >= targetRecall {
+			return &probes[i]
+		}
+	}
+	return nil
+}
+
+func currentProbe(probes []indexProbe) *indexProbe {
+	for i := range probes {
+		if probes[i].Current {
+			return &probes[i]
+		}
+	}
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, suffix := float64(n), "B"
+	for _, s := range []string{"KB", "MB", "GB", "TB"} {
+		if value < unit {
+			break
+		}
+		value /= unit
+		suffix = s
+	}
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// 读取索引的文档数、分段、磁盘占用和vector字段的mapping
+func (r *RAGSystem) inspectIndex(ctx context.Context) (*indexStats, error) {
+	indexName := r.config.IndexName
+	stats := &indexStats{Name: indexName, Params: make(map[string]string)}
+
+	res, err := r.elasticClient.Indices.Stats(
+		r.elasticClient.Indices.Stats.WithContext(ctx),
+		r.elasticClient.Indices.Stats.WithIndex(indexName),
+		r.elasticClient.Indices.Stats.WithMetric("docs", "segments", "store"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询索引统计失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("查询索引统计错误: %s", res.String())
+	}
+	var statsResponse struct {
+		Indices map[string]struct {
+			Primaries struct {
+				Docs struct {
+					Count int64 `json:"count"`
+				} `json:"docs"`
+				Segments struct {
+					Count int `json:"count"`
+				} `json:"segments"`
+				Store struct {
+					SizeInBytes int64 `json:"size_in_bytes"`
+				} `json:"store"`
+			} `json:"primaries"`
+		} `json:"indices"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&statsResponse); err != nil {
+		return nil, fmt.Errorf("解析索引统计失败: %w", err)
+	}
+	for _, index := range statsResponse.Indices {
+		stats.Vectors += index.Primaries.Docs.Count
+		stats.Segments += index.Primaries.Segments.Count
+		stats.StoreBytes += index.Primaries.Store.SizeInBytes
+	}
+
+	mappingRes, err := r.elasticClient.Indices.GetMapping(
+		r.elasticClient.Indices.GetMapping.WithContext(ctx),
+		r.elasticClient.Indices.GetMapping.WithIndex(indexName),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询mapping失败: %w", err)
+	}
+	defer mappingRes.Body.Close()
+	if mappingRes.IsError() {
+		return nil, fmt.Errorf("查询mapping错误: %s", mappingRes.String())
+	}
+	var mappingResponse map[string]struct {
+		Mappings struct {
+			Properties struct {
+				Vector struct {
+					Dims         int                    `json:"dims"`
+					Index        *bool                  `json:"index"`
+					Similarity   string                 `json:"similarity"`
+					IndexOptions map[string]interface{} `json:"index_options"`
+				} `json:"vector"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(mappingRes.Body).Decode(&mappingResponse); err != nil {
+		return nil, fmt.Errorf("解析mapping失败: %w", err)
+	}
+	for _, index := range mappingResponse {
+		vector := index.Mappings.Properties.Vector
+		stats.Dim = vector.Dims
+		stats.Metric = vector.Similarity
+		// 8.x中dense_vector默认开启索引，类型为hnsw
+		stats.IndexType = "hnsw"
+		if vector.Index != nil && !*vector.Index {
+			stats.IndexType = "none"
+		}
+		for key, value := range vector.IndexOptions {
+			if key == "type" {
+				stats.IndexType = fmt.Sprint(value)
+				continue
+			}
+			stats.Params[key] = fmt.Sprint(value)
+		}
+	}
+
+	m, _ := strconv.Atoi(stats.Params["m"])
+	stats.MemoryBytes = estimateHNSWMemory(stats.Vectors, stats.Dim, m)
+	if stats.IndexType == "none" {
+		stats.MemoryBytes = 0
+	}
+	return stats, nil
+}
+
+// 以当前使用的script_score暴力检索为精确基准，探测kNN查询在不同num_candidates下的召回率和延迟
+func (r *RAGSystem) probeIndex(ctx context.Context, stats *indexStats, queries []string, topK int) ([]indexProbe, error) {
+	profileCandidates := esNumCandidates(searchOptions{Profile: r.settings().Retrieval.Profile}, topK)
+	exact := make([][]string, len(queries))
+	current := indexProbe{Setting: "script_score（精确）", Recall: 1, Current: profileCandidates == 0}
+	var elapsed time.Duration
+	for i, query := range queries {
+		vector, err := r.embedWith(ctx, "", query)
+		if err != nil {
+			return nil, fmt.Errorf("问题向量化失败: %w", err)
+		}
+		scriptQuery, err := scriptScoreQuery(nil, "vector", vector)
+		if err != nil {
+			return nil, err
+		}
+		req := search.NewRequest()
+		req.Size = &topK
+		req.Query = scriptQuery
+		ids, took, err := r.probeSearch(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		exact[i] = ids
+		elapsed += took
+	}
+	current.Latency = elapsed / time.Duration(len(queries))
+	probes := []indexProbe{current}
+
+	if stats.IndexType == "none" {
+		return probes, nil
+	}
+	// num_candidates不能小于k，探测按从小到大进行
+	settings := []int{topK, 2 * topK, 20, 50, 100, 200, profileCandidates}
+	sort.Ints(settings)
+	last := 0
+	for _, candidates := range settings {
+		if candidates < topK || candidates == last {
+			continue
+		}
+		last = candidates
+		probe := indexProbe{Setting: fmt.Sprintf("knn num_candidates=%d", candidates), Value: candidates, Current: candidates == profileCandidates}
+		var recall float64
+		var elapsed time.Duration
+		for i, query := range queries {
+			vector, err := r.embedWith(ctx, "", query)
+			if err != nil {
+				return nil, fmt.Errorf("问题向量化失败: %w", err)
+			}
+			req := search.NewRequest()
+			req.Knn = []types.KnnSearch{knnSearch("vector", vector, topK, candidates, nil)}
+			ids, took, err := r.probeSearch(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			recall += recallAt(ids, exact[i])
+			elapsed += took
+		}
+		probe.Recall = recall / float64(len(queries))
+		probe.Latency = elapsed / time.Duration(len(queries))
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+// 执行一次探测搜索，返回命中的分块ID和耗时
+func (r *RAGSystem) probeSearch(ctx context.Context, req *search.Request) ([]string, time.Duration, error) {
+	req.Source_ = false
+	start := time.Now()
+	res, err := r.typedClient.Search().Index(r.config.IndexName).Request(req).Do(ctx)
+	took := time.Since(start)
+	if err != nil {
+		return nil, 0, fmt.Errorf("探测搜索失败: %w", err)
+	}
+
+	ids := make([]string, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		if hit.Id_ != nil {
+			ids = append(ids, *hit.Id_)
+		}
+	}
+	return ids, took, nil
+}
+
+// 根据统计和探测结果给出ES kNN参数建议
+func (r *RAGSystem) adviseIndex(stats *indexStats, probes []indexProbe) []string {
+	var advice []string
+	current, best := currentProbe(probes), bestProbe(probes[1:])
+	switch {
+	case stats.IndexType == "none":
+		advice = append(advice, "vector字段未开启索引，只能暴力检索；数据量增长后把mapping改为 \"index\": true 并重建索引")
+	case stats.Vectors < 10000 && !probes[0].Current:
+		advice = append(advice, fmt.Sprintf("向量数只有 %d，ACCURACY_PROFILE=accurate 的script_score精确检索也足够快", stats.Vectors))
+	case best == nil:
+		advice = append(advice, fmt.Sprintf("kNN查询达不到 %.0f%% 召回率，提高index_options中的m或ef_construction后重建索引", targetRecall*100))
+	case current != nil && current.Setting != best.Setting && (current.Recall < targetRecall || best.Latency < current.Latency):
+		advice = append(advice, fmt.Sprintf("检索参数从 %s 调整为 %s（请求中的accuracy.num_candidates）：召回率 %.1f%% → %.1f%%，延迟 %v → %v",
+			current.Setting, best.Setting, current.Recall*100, best.Recall*100,
+			current.Latency.Round(10*time.Microsecond), best.Latency.Round(10*time.Microsecond)))
+	}
+
+	if stats.Segments > 10 {
+		advice = append(advice, fmt.Sprintf("共有 %d 个分段，每个分段各有一张HNSW图，执行 POST /%s/_forcemerge?max_num_segments=1 可以降低kNN延迟",
+			stats.Segments, stats.Name))
+	}
+	if m, _ := strconv.Atoi(stats.Params["m"]); m > 0 && m < 16 && stats.Vectors > 1000000 {
+		advice = append(advice, fmt.Sprintf("向量数超过百万，m=%d 偏小，建议m取16~32重建索引", m))
+	}
+	if stats.MemoryBytes > 1<<30 && stats.IndexType == "hnsw" {
+		advice = append(advice, fmt.Sprintf("向量索引约需 %s 堆外内存，可改用int8_hnsw量化索引（8.12+）减少约75%%", formatBytes(stats.MemoryBytes)))
+	}
+	return advice
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 默认返回的分组数
+const defaultAnalyticsLimit = 20
+
+// 可用于分组和过滤的元数据字段
+var analyticsFields = map[string]bool{
+	"category":     true,
+	"source":       true,
+	"date":         true,
+	"content_type": true,
+	"encoding":     true,
+	"doc_id":       true,
+}
+
+// 支持的SQL子集：
+// SELECT [字段,] COUNT(*) FROM documents|chunks [WHERE 字段 操作符 '值' [AND ...]] [GROUP BY 字段] [LIMIT n]
+var (
+	analyticsPattern = regexp.MustCompile(`(?is)^\s*SELECT\s+(?:(\w+)\s*,\s*)?COUNT\(\s*\*\s*\)\s+FROM\s+(\w+)` +
+		`(?:\s+WHERE\s+(.+?))?(?:\s+GROUP\s+BY\s+(\w+))?(?:\s+LIMIT\s+(\d+))?\s*;?\s*$`)
+	conditionPattern = regexp.MustCompile(`^\s*(\w+)\s*(=|!=|>=|<=|>|<)\s*'([^']*)'\s*$`)
+	andPattern       = regexp.MustCompile(`(?i)\s+AND\s+`)
+)
+
+// 解析后的分析查询
+type analyticsQuery struct {
+	Documents bool // true按文档去重计数（FROM documents），false按分块计数（FROM chunks）
+	GroupBy   string
+	Filters   []analyticsFilter
+	Limit     int
+}
+
+type analyticsFilter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// 分析查询结果
+type analyticsResult struct {
+	GroupBy string         `json:"group_by,omitempty"`
+	Unit    string         `json:"unit"`
+	Total   int64          `json:"total"`
+	Rows    []analyticsRow `json:"rows,omitempty"`
+}
+
+type analyticsRow struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// 解析SQL查询，只允许只读的计数聚合
+func parseAnalyticsQuery(sql string) (analyticsQuery, error) {
+	m := analyticsPattern.FindStringSubmatch(sql)
+	if m == nil {
+		return analyticsQuery{}, fmt.Errorf("不支持的查询，格式为: SELECT [字段,] COUNT(*) FROM documents|chunks [WHERE 字段 = '值' [AND ...]] [GROUP BY 字段] [LIMIT n]")
+	}
+
+	query := analyticsQuery{GroupBy: strings.ToLower(m[4]), Limit: defaultAnalyticsLimit}
+	switch strings.ToLower(m[2]) {
+	case "documents":
+		query.Documents = true
+	case "chunks":
+	default:
+		return analyticsQuery{}, fmt.Errorf("未知的表: %s，可用: documents, chunks", m[2])
+	}
+
+	selected := strings.ToLower(m[1])
+	if selected != query.GroupBy {
+		return analyticsQuery{}, fmt.Errorf("SELECT的字段必须与GROUP BY一致")
+	}
+	if query.GroupBy != "" && !analyticsFields[query.GroupBy] {
+		return analyticsQuery{}, fmt.Errorf("未知字段: %s", query.GroupBy)
+	}
+
+	if m[3] != "" {
+		for _, part := range andPattern.Split(m[3], -1) {
+			cond := conditionPattern.FindStringSubmatch(part)
+			if cond == nil {
+				return analyticsQuery{}, fmt.Errorf("无法解析条件: %s", strings.TrimSpace(part))
+			}
+			field := strings.ToLower(cond[1])
+			if !analyticsFields[field] {
+				return analyticsQuery{}, fmt.Errorf("未知字段: %s", cond[1])
+			}
+			query.Filters = append(query.Filters, analyticsFilter{Field: field, Op: cond[2], Value: cond[3]})
+		}
+	}
+
+	if m[5] != "" {
+		limit, err := strconv.Atoi(m[5])
+		if err != nil || limit <= 0 {
+			return analyticsQuery{}, fmt.Errorf("LIMIT必须为正整数")
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+func (q analyticsQuery) unit() string {
+	if q.Documents {
+		return "documents"
+	}
+	return "chunks"
+}
+
+// 执行SQL分析查询
+func (r *RAGSystem) Analytics(sql string) (*analyticsResult, error) {
+	query, err := parseAnalyticsQuery(sql)
+	if err != nil {
+		return nil, err
+	}
+	return r.runAnalytics(query)
+}
+
+// analytics命令：对文档元数据执行只读的统计查询
+func runAnalytics(args []string) error {
+	fs := flag.NewFlagSet("analytics", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "以JSON格式输出")
+	_ = fs.Parse(args)
+
+	sql := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(sql) == "" {
+		return fmt.Errorf("请提供查询，例如: analytics \"SELECT category, COUNT(*) FROM documents GROUP BY category\"")
+	}
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	result, err := rag.Analytics(sql)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	fmt.Printf("📊 共 %d 个%s\n", result.Total, unitName(result.Unit))
+	if result.GroupBy != "" {
+		fmt.Printf("%-30s %s\n", result.GroupBy, "count")
+		for _, row := range result.Rows {
+			fmt.Printf("%-30s %d\n", row.Key, row.Count)
+		}
+	}
+	return nil
+}
+
+func unitName(unit string) string {
+	if unit == "documents" {
+		return "文档"
+	}
+	return "分块"
+}
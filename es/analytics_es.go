@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// 将分析查询翻译成ES的bool过滤和terms聚合
+func (r *RAGSystem) runAnalytics(query analyticsQuery) (*analyticsResult, error) {
+	filters, mustNot := []interface{}{}, []interface{}{}
+	for _, filter := range query.Filters {
+		field := esAnalyticsField(filter.Field)
+		switch filter.Op {
+		case "=":
+			filters = append(filters, map[string]interface{}{"term": map[string]interface{}{field: filter.Value}})
+		case "!=":
+			mustNot = append(mustNot, map[string]interface{}{"term": map[string]interface{}{field: filter.Value}})
+		default:
+			ops := map[string]string{">": "gt", ">=": "gte", "<": "lt", "<=": "lte"}
+			filters = append(filters, map[string]interface{}{
+				"range": map[string]interface{}{field: map[string]interface{}{ops[filter.Op]: filter.Value}},
+			})
+		}
+	}
+
+	aggs := map[string]interface{}{}
+	if query.Documents {
+		aggs["docs"] = map[string]interface{}{"cardinality": map[string]interface{}{"field": "doc_id"}}
+	}
+	if query.GroupBy != "" {
+		terms := map[string]interface{}{
+			"field": esAnalyticsField(query.GroupBy),
+			"size":  query.Limit,
+		}
+		if query.GroupBy == "date" {
+			terms["format"] = "yyyy-MM-dd"
+		}
+		group := map[string]interface{}{"terms": terms}
+		if query.Documents {
+			terms["order"] = map[string]interface{}{"docs": "desc"}
+			group["aggs"] = map[string]interface{}{
+				"docs": map[string]interface{}{"cardinality": map[string]interface{}{"field": "doc_id"}},
+			}
+		}
+		aggs["groups"] = group
+	}
+
+	searchQuery := map[string]interface{}{
+		"size":             0,
+		"track_total_hits": true,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter":   filters,
+				"must_not": mustNot,
+			},
+		},
+		"aggs": aggs,
+	}
+
+	searchJSON, _ := json.Marshal(searchQuery)
+	esClient, primary := r.readClient()
+	// 聚合结构随分析查询变化，以原始请求体执行，按需解码响应
+	res, err := esClient.Search().Index(r.config.IndexName).Raw(bytes.NewReader(searchJSON)).Perform(context.Background())
+	if err != nil {
+		r.recordRead(primary, err)
+		return nil, fmt.Errorf("执行聚合查询失败: %w", err)
+	}
+	defer res.Body.Close()
+	r.recordRead(primary, readError(res.StatusCode))
+
+	if res.StatusCode >= 300 {
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("聚合查询错误: [%d] %s", res.StatusCode, body)
+	}
+
+	var searchResponse struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Docs struct {
+				Value int64 `json:"value"`
+			} `json:"docs"`
+			Groups struct {
+				Buckets []struct {
+					Key         interface{} `json:"key"`
+					KeyAsString string      `json:"key_as_string"`
+					DocCount    int64       `json:"doc_count"`
+					Docs        struct {
+						Value int64 `json:"value"`
+					} `json:"docs"`
+				} `json:"buckets"`
+			} `json:"groups"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&searchResponse); err != nil {
+		return nil, fmt.Errorf("解析聚合结果失败: %w", err)
+	}
+
+	result := &analyticsResult{GroupBy: query.GroupBy, Unit: query.unit(), Total: searchResponse.Hits.Total.Value}
+	if query.Documents {
+		result.Total = searchResponse.Aggregations.Docs.Value
+	}
+	for _, bucket := range searchResponse.Aggregations.Groups.Buckets {
+		key := bucket.KeyAsString
+		if key == "" {
+			key = fmt.Sprint(bucket.Key)
+		}
+		count := bucket.DocCount
+		if query.Documents {
+			count = bucket.Docs.Value
+		}
+		result.Rows = append(result.Rows, analyticsRow{Key: key, Count: count})
+	}
+	return result, nil
+}
+
+// 元数据字段在ES中的字段名：字符串元数据由动态映射生成keyword子字段，日期自动识别为date类型
+func esAnalyticsField(field string) string {
+	switch field {
+	case "doc_id":
+		return field
+	case "date":
+		return "meta.date"
+	default:
+		return "meta." + field + ".keyword"
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 解压限制，防止压缩炸弹
+type archiveLimits struct {
+	MaxBytes int64 // 解压后的总大小
+	MaxFiles int   // 文件数
+	MaxDepth int   // 压缩包嵌套层数
+}
+
+func loadArchiveLimits() archiveLimits {
+	return archiveLimits{
+		MaxBytes: int64(getEnvAsInt("ARCHIVE_MAX_MB", 200)) << 20,
+		MaxFiles: getEnvAsInt("ARCHIVE_MAX_FILES", 10000),
+		MaxDepth: getEnvAsInt("ARCHIVE_MAX_DEPTH", 2),
+	}
+}
+
+// 是否为支持的压缩包（zip、tar.gz、单文件gz）
+func isArchive(contentType string) bool {
+	return contentType == contentTypeZip || contentType == contentTypeGzip
+}
+
+// 加载压缩包中的文档：解压到独立的临时目录，按实际类型交给对应的加载器，嵌套的压缩包递归处理。
+// sourcePath为磁盘上的压缩包，archivePath为逻辑路径，嵌套时形如 bundle.zip!docs/inner.tar.gz
+func loadArchive(sourcePath, archivePath string, data []byte, depth int, limits archiveLimits) ([]Document, error) {
+	if depth >= limits.MaxDepth {
+		return nil, fmt.Errorf("压缩包嵌套超过 %d 层", limits.MaxDepth)
+	}
+
+	dir, err := os.MkdirTemp("", "rag-archive-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	extractor := &archiveExtractor{dir: dir, limits: limits}
+	switch sniffContentType(data) {
+	case contentTypeZip:
+		err = extractor.extractZip(data)
+	case contentTypeGzip:
+		err = extractor.extractGzip(data, strings.TrimSuffix(filepath.Base(archivePath), ".gz"))
+	default:
+		err = fmt.Errorf("不是压缩包")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解压 %s 失败: %w", archivePath, err)
+	}
+
+	var documents []Document
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		entry, _ := filepath.Rel(dir, path)
+		entry = filepath.ToSlash(entry)
+		entryPath := archivePath + "!" + entry
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if isArchive(sniffContentType(content)) {
+			nested, err := loadArchive(sourcePath, entryPath, content, depth+1, limits)
+			if err != nil {
+				fmt.Printf("⚠️  跳过压缩包 %s: %v\n", entryPath, err)
+				return nil
+			}
+			documents = append(documents, nested...)
+			return nil
+		}
+
+		doc, err := loadDocument(entry, content)
+		if err != nil {
+			fmt.Printf("⚠️  跳过文件 %s: %v\n", entryPath, err)
+			return nil
+		}
+		// source_path指向磁盘上的压缩包，gc据此判断源文件是否还在
+		doc.ID = documentIDFromPath(entryPath)
+		doc.Meta["source_path"] = sourcePath
+		doc.Meta["archive_path"] = archivePath
+		doc.Meta["archive_entry"] = entry
+		documents = append(documents, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// 解压到临时目录，统计已解压的大小和文件数
+type archiveExtractor struct {
+	dir    string
+	limits archiveLimits
+	bytes  int64
+	files  int
+}
+
+func (e *archiveExtractor) extractZip(data []byte) error {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = e.write(file.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gzip解压后是tar包时逐个解出文件，否则作为单个文件
+func (e *archiveExtractor) extractGzip(data []byte, name string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	decompressed, err := io.ReadAll(io.LimitReader(gz, e.limits.MaxBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(decompressed)) > e.limits.MaxBytes {
+		return fmt.Errorf("解压后超过 %d MB", e.limits.MaxBytes>>20)
+	}
+
+	// tar头的257字节处为ustar魔数
+	if len(decompressed) < 262 || string(decompressed[257:262]) != "ustar" {
+		return e.write(name, bytes.NewReader(decompressed))
+	}
+	reader := tar.NewReader(bytes.NewReader(decompressed))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// 只解出普通文件，忽略目录、符号链接和设备文件
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := e.write(header.Name, reader); err != nil {
+			return err
+		}
+	}
+}
+
+// 写出一个文件，拒绝跳出临时目录的路径（zip slip）并检查限制
+func (e *archiveExtractor) write(name string, r io.Reader) error {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("非法的文件路径: %s", name)
+	}
+	e.files++
+	if e.files > e.limits.MaxFiles {
+		return fmt.Errorf("文件数超过 %d", e.limits.MaxFiles)
+	}
+
+	path := filepath.Join(e.dir, clean)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	remaining := e.limits.MaxBytes - e.bytes
+	n, err := io.Copy(file, io.LimitReader(r, remaining+1))
+	e.bytes += n
+	if err != nil {
+		return err
+	}
+	if e.bytes > e.limits.MaxBytes {
+		return fmt.Errorf("解压后超过 %d MB", e.limits.MaxBytes>>20)
+	}
+	return nil
+}
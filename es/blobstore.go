@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 原文存储配置：向量库只保存分块和元数据，完整的原始文档存放在本地目录或S3，引用中附带查看原文的链接
+type BlobStoreConfig struct {
+	Backend   string // none、local、s3
+	Dir       string // local：存放目录
+	Bucket    string // s3：存储桶
+	Prefix    string // s3：对象键前缀
+	S3        S3Config
+	PublicURL string // 查看原文链接的服务地址，即serve对外的地址
+}
+
+func loadBlobStoreConfig() BlobStoreConfig {
+	return BlobStoreConfig{
+		Backend:   getEnv("BLOB_STORE", "none"),
+		Dir:       getEnv("BLOB_DIR", ".blobs"),
+		Bucket:    getEnv("BLOB_BUCKET", "rag-originals"),
+		Prefix:    getEnv("BLOB_PREFIX", "originals/"),
+		S3:        loadS3Config(),
+		PublicURL: getEnv("PUBLIC_BASE_URL", "http://localhost:8080"),
+	}
+}
+
+// 原文不存在
+var errBlobNotFound = errors.New("原文不存在")
+
+// 原文存储
+type blobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+func newBlobStore(config BlobStoreConfig) (blobStore, error) {
+	switch config.Backend {
+	case "none", "":
+		return nil, nil
+	case "local":
+		return &localBlobStore{dir: config.Dir}, nil
+	case "s3":
+		s3Client, err := newS3Client(config.S3)
+		if err != nil {
+			return nil, err
+		}
+		return &s3BlobStore{client: s3Client, bucket: config.Bucket, prefix: config.Prefix}, nil
+	default:
+		return nil, fmt.Errorf("未知的BLOB_STORE: %s", config.Backend)
+	}
+}
+
+// 文档ID可能含有路径分隔符等字符，按哈希生成存储键，前两位作为子目录避免单目录文件过多
+func blobKey(docID string) string {
+	sum := sha1.Sum([]byte(docID))
+	name := hex.EncodeToString(sum[:])
+	return name[:2] + "/" + name + ".json"
+}
+
+// 本地目录存储
+type localBlobStore struct {
+	dir string
+}
+
+func (s *localBlobStore) Put(_ context.Context, key string, data []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (s *localBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil, errBlobNotFound
+	}
+	return data, err
+}
+
+// S3/MinIO存储
+type s3BlobStore struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func (s *s3BlobStore) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.prefix+key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	return err
+}
+
+func (s *s3BlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.prefix+key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, errBlobNotFound
+	}
+	return data, err
+}
+
+// 入库时保存完整的原始文档
+func (r *RAGSystem) storeOriginals(documents []Document) error {
+	if r.blobs == nil {
+		return nil
+	}
+	ctx := context.Background()
+	for _, doc := range documents {
+		data, err := json.Marshal(ingestDocument{ID: doc.ID, Title: doc.Title, Content: doc.Content, Meta: doc.Meta})
+		if err != nil {
+			return err
+		}
+		if err := r.blobs.Put(ctx, blobKey(doc.ID), data); err != nil {
+			return fmt.Errorf("保存原文 %s 失败: %w", doc.ID, err)
+		}
+	}
+	return nil
+}
+
+// 读取原始文档
+func (r *RAGSystem) loadOriginal(ctx context.Context, docID string) (*ingestDocument, error) {
+	if r.blobs == nil {
+		return nil, fmt.Errorf("未配置原文存储（BLOB_STORE）")
+	}
+	data, err := r.blobs.Get(ctx, blobKey(docID))
+	if err != nil {
+		return nil, err
+	}
+	var doc ingestDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析原文失败: %w", err)
+	}
+	return &doc, nil
+}
+
+// 为检索结果附上查看原文的链接
+func (r *RAGSystem) linkOriginals(results []SearchResult) {
+	if r.blobs == nil {
+		return
+	}
+	base := strings.TrimSuffix(r.config.Blob.PublicURL, "/")
+	for i := range results {
+		results[i].Link = base + "/documents/original?id=" + url.QueryEscape(results[i].DocID)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 运算符优先级：乘方高于负号，负号高于乘除，乘方右结合
+func TestEvaluateExpression(t *testing.T) {
+	for _, tc := range []struct {
+		expression string
+		want       float64
+	}{
+		{"1+2*3", 7},
+		{"(1+2)*3", 9},
+		{"10-4-3", 3},
+		{"24/4/2", 3},
+		{"2*3^2", 18},
+		{"-2^2", -4},
+		{"(-2)^2", 4},
+		{"2^-1", 0.5},
+		{"2^3^2", 512},
+		{"-3*-2", 6},
+		{"--2", 2},
+		{"50%*200", 100},
+		{"(2300-2000)/2000*100", 15},
+		{"1,200+300", 1500},
+		{" 1.5 * 4 ", 6},
+	} {
+		got, err := evaluateExpression(tc.expression)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expression, err)
+			continue
+		}
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s = %v，应为 %v", tc.expression, got, tc.want)
+		}
+	}
+}
+
+// 除数为0、括号不匹配、缺少操作数和无法识别的字符都返回错误
+func TestEvaluateExpressionErrors(t *testing.T) {
+	for _, expression := range []string{
+		"1/0",
+		"1/(2-2)",
+		"0^-1",
+		"",
+		"(1+2",
+		"1+2)",
+		"1+",
+		"*2",
+		"2^",
+		"1..2",
+		"3x",
+		"abc",
+	} {
+		if got, err := evaluateExpression(expression); err == nil {
+			t.Errorf("%q 应返回错误，实际结果 %v", expression, got)
+		}
+	}
+}
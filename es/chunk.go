@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 文档分块
+type Chunk struct {
+	ID      string
+	DocID   string
+	Index   int
+	Title   string
+	Content string
+	Heading string // 分块开头所在的Markdown标题，没有时为空
+}
+
+// 句子结束符，分块时优先在这些位置切分
+const sentenceDelimiters = "。！？；!?;\n"
+
+// 将文档按句子切分成不超过chunkSize个字符的分块
+func splitDocument(doc Document, chunkSize int) []Chunk {
+	if chunkSize <= 0 {
+		chunkSize = 500
+	}
+
+	var chunks []Chunk
+	var current []rune
+	var heading, chunkHeading string
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		chunks = append(chunks, Chunk{
+			ID:      fmt.Sprintf("%s#%d", doc.ID, len(chunks)),
+			DocID:   doc.ID,
+			Index:   len(chunks),
+			Title:   doc.Title,
+			Content: strings.TrimSpace(string(current)),
+			Heading: chunkHeading,
+		})
+		current = current[:0]
+	}
+
+	for _, sentence := range splitSentences(doc.Content) {
+		if h := markdownHeading(sentence); h != "" {
+			heading = h
+		}
+		runes := []rune(sentence)
+		if len(current)+len(runes) > chunkSize {
+			flush()
+		}
+		// 单句超长时按长度硬切
+		for len(runes) > chunkSize {
+			if len(current) == 0 {
+				chunkHeading = heading
+			}
+			current = append(current, runes[:chunkSize]...)
+			flush()
+			runes = runes[chunkSize:]
+		}
+		if len(current) == 0 {
+			chunkHeading = heading
+		}
+		current = append(current, runes...)
+	}
+	flush()
+
+	// 空文档也保留一个空分块，便于质量报告发现问题
+	if len(chunks) == 0 {
+		chunks = append(chunks, Chunk{ID: doc.ID + "#0", DocID: doc.ID, Title: doc.Title})
+	}
+	return chunks
+}
+
+// Markdown标题行的文字，例如 "## 安装步骤" 返回 "安装步骤"，不是标题行时返回空
+func markdownHeading(line string) string {
+	line = strings.TrimSpace(line)
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 || !strings.HasPrefix(line[level:], " ") {
+		return ""
+	}
+	text := strings.TrimSpace(line[level:])
+	// 去掉可选的结尾#序列，例如 "## 安装 ##"
+	if i := strings.LastIndex(text, " #"); i >= 0 && strings.Trim(text[i:], " #") == "" {
+		text = strings.TrimSpace(text[:i])
+	}
+	return text
+}
+
+// 按句子结束符切分文本，结束符保留在句尾
+func splitSentences(text string) []string {
+	var sentences []string
+	var builder strings.Builder
+	for _, ch := range text {
+		builder.WriteRune(ch)
+		if strings.ContainsRune(sentenceDelimiters, ch) {
+			sentences = append(sentences, builder.String())
+			builder.Reset()
+		}
+	}
+	if builder.Len() > 0 {
+		sentences = append(sentences, builder.String())
+	}
+	return sentences
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 分块上下文：GET /chunks/context?id=<分块ID>&window=2 返回该分块、同一文档中前后各window个分块和所属文档的元数据，
+// 供界面的"查看更多上下文"使用，调用方不需要访问向量库。分块按ID中的序号（<文档ID>#<序号>，#在查询参数中编码为%23）排列；
+// 受许可限制或已过期的分块不返回，请求的分块本身不可用时返回404
+const (
+	defaultContextWindow = 1
+	maxContextWindow     = 10
+)
+
+var errChunkNotFound = errors.New("分块不存在")
+
+type chunkContextResponse struct {
+	Chunk    SearchResult   `json:"chunk"`
+	Before   []SearchResult `json:"before"` // 前面的分块，按原文顺序
+	After    []SearchResult `json:"after"`  // 后面的分块，按原文顺序
+	Document chunkDocument  `json:"document"`
+}
+
+type chunkDocument struct {
+	ID       string                 `json:"id"`
+	Title    string                 `json:"title"`
+	Chunks   int                    `json:"chunks"`   // 文档可返回的分块数
+	Position int                    `json:"position"` // 请求的分块在其中的位置，从0开始
+	Link     string                 `json:"link,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"` // 文档全部分块取值相同的元数据，不含标题、语言等分块级字段
+}
+
+// 解析分块ID中的文档ID和序号
+func parseChunkID(id string) (string, int, bool) {
+	i := strings.LastIndex(id, "#")
+	if i <= 0 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(id[i+1:])
+	if err != nil || n < 0 {
+		return "", 0, false
+	}
+	return id[:i], n, true
+}
+
+// 读取分块和前后各window个分块
+func (r *RAGSystem) chunkContext(ctx context.Context, chunkID string, window int) (*chunkContextResponse, error) {
+	docID, _, ok := parseChunkID(chunkID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errChunkNotFound, chunkID)
+	}
+	chunks, err := r.documentChunks(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range chunks {
+		chunks[i].Trust = r.config.Trust.weight(chunks[i].Meta)
+	}
+	chunks = applyLicense(chunks, r.config.License)
+	chunks = dropExpired(chunks, time.Now())
+	sort.SliceStable(chunks, func(i, j int) bool {
+		_, a, _ := parseChunkID(chunks[i].ID)
+		_, b, _ := parseChunkID(chunks[j].ID)
+		return a < b
+	})
+
+	position := -1
+	for i, chunk := range chunks {
+		if chunk.ID == chunkID {
+			position = i
+			break
+		}
+	}
+	if position < 0 {
+		return nil, fmt.Errorf("%w: %s", errChunkNotFound, chunkID)
+	}
+	from, to := max(position-window, 0), min(position+window+1, len(chunks))
+	neighborhood := append([]SearchResult(nil), chunks[from:to]...)
+	r.linkOriginals(neighborhood)
+	r.linkLocations(neighborhood)
+
+	current := neighborhood[position-from]
+	return &chunkContextResponse{
+		Chunk:  current,
+		Before: neighborhood[:position-from],
+		After:  neighborhood[position-from+1:],
+		Document: chunkDocument{
+			ID: docID, Title: current.Title, Chunks: len(chunks), Position: position,
+			Link: current.Link, Meta: documentMeta(chunks),
+		},
+	}, nil
+}
+
+// 文档级元数据：全部分块取值相同的字段
+func documentMeta(chunks []SearchResult) map[string]interface{} {
+	meta := make(map[string]interface{})
+	for key, value := range chunks[0].Meta {
+		shared := true
+		for _, chunk := range chunks[1:] {
+			if other, ok := chunk.Meta[key]; !ok || fmt.Sprint(other) != fmt.Sprint(value) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			meta[key] = value
+		}
+	}
+	delete(meta, "heading")
+	delete(meta, languageKey)
+	return meta
+}
+
+// GET /chunks/context?id=doc_001%232&window=2
+func (s *apiServer) handleChunkContext(w http.ResponseWriter, req *http.Request) {
+	chunkID := req.URL.Query().Get("id")
+	if chunkID == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("缺少查询参数id"))
+		return
+	}
+	window := defaultContextWindow
+	if value := req.URL.Query().Get("window"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxContextWindow {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("window应为0到%d的整数: %s", maxContextWindow, value))
+			return
+		}
+		window = n
+	}
+
+	resp, err := s.rag.chunkContext(req.Context(), chunkID, window)
+	if errors.Is(err, errChunkNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
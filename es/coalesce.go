@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 同一问题的并发回答合并：检索参数相同的问题正在生成时，后到的请求等待同一次检索和生成，
+// 避免热点问题同时涌入时重复调用大模型。等待的请求全部断开后才取消生成
+type answerFlights struct {
+	mu        sync.Mutex
+	flights   map[string]*answerFlight
+	coalesced atomic.Int64 // 合并到已有生成的请求数
+}
+
+type answerFlight struct {
+	done      chan struct{}
+	waiters   int
+	cancel    context.CancelFunc
+	answer    string
+	elapsed   float64
+	sources   []SearchResult
+	reasoning string
+	degraded  []string
+	prompts   []string
+	err       error
+}
+
+func newAnswerFlights(enabled bool) *answerFlights {
+	if !enabled {
+		return nil
+	}
+	return &answerFlights{flights: make(map[string]*answerFlight)}
+}
+
+// 合并的键：规范化后的问题加上影响检索和生成的参数；带会话历史时只合并同一会话的请求
+func flightKey(question string, opts searchOptions) string {
+	session := ""
+	if len(opts.History) > 0 {
+		session = opts.Session
+	}
+	return strings.Join([]string{
+		normalizeQuestion(question), opts.Model, opts.Length, opts.Category, opts.Entity, opts.exclusionKey(), opts.Period.key(), opts.LanguageFilter, session, opts.Profile,
+		fmt.Sprint(opts.EF, opts.NProbe, opts.NumCandidates, opts.Reasoning != nil, opts.Extractive, opts.Summarize, opts.Direct, opts.Forwarded), strings.Join(opts.Operators, " "),
+	}, "\x00")
+}
+
+// 执行或等待同一问题的回答。生成使用独立于请求的ctx，保留ctx中的值；
+// 请求断开时只退出等待，最后一个等待的请求断开时取消生成
+func (g *answerFlights) do(ctx context.Context, question string, opts searchOptions, generate func(ctx context.Context, opts searchOptions) (string, float64, []SearchResult, error)) (string, float64, []SearchResult, error) {
+	if g == nil {
+		return generate(ctx, opts)
+	}
+	key := flightKey(question, opts)
+
+	g.mu.Lock()
+	f, ok := g.flights[key]
+	if ok {
+		g.coalesced.Add(1)
+		fmt.Printf("🔗 合并进行中的相同问题: %s\n", question)
+	} else {
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &answerFlight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+		go g.run(flightCtx, key, f, opts, generate)
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if opts.Reasoning != nil {
+			opts.Reasoning.WriteString(f.reasoning)
+		}
+		opts.Degraded.mark(f.degraded...)
+		opts.Prompts.add(f.prompts...)
+		return f.answer, f.elapsed, f.sources, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		if f.waiters--; f.waiters == 0 {
+			f.cancel()
+			g.forget(key, f)
+		}
+		g.mu.Unlock()
+		return "", 0, nil, ctx.Err()
+	}
+}
+
+func (g *answerFlights) run(ctx context.Context, key string, f *answerFlight, opts searchOptions, generate func(ctx context.Context, opts searchOptions) (string, float64, []SearchResult, error)) {
+	defer f.cancel()
+	if opts.Reasoning != nil {
+		opts.Reasoning = &strings.Builder{}
+	}
+	opts.Degraded = &degradation{}
+	if opts.Prompts != nil {
+		opts.Prompts = &promptLog{}
+	}
+	f.answer, f.elapsed, f.sources, f.err = generate(ctx, opts)
+	if opts.Reasoning != nil {
+		f.reasoning = opts.Reasoning.String()
+	}
+	f.degraded = opts.Degraded.Tiers()
+	f.prompts = opts.Prompts.Hashes()
+
+	g.mu.Lock()
+	g.forget(key, f)
+	g.mu.Unlock()
+	close(f.done)
+}
+
+// 移除已结束或已取消的生成，之后到达的相同问题重新生成
+func (g *answerFlights) forget(key string, f *answerFlight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// 合并到已有生成的请求数，未开启时为0
+func (g *answerFlights) Coalesced() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.coalesced.Load()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 同一问题的direct请求和RAG请求同时到达时各自生成，不互相拿到对方模式的回答
+func TestAnswerFlightsSeparateDirectMode(t *testing.T) {
+	if flightKey("闫同学是谁？", searchOptions{}) == flightKey("闫同学是谁？", searchOptions{Direct: true}) {
+		t.Fatal("direct模式与RAG模式的合并键相同")
+	}
+
+	flights := newAnswerFlights(true)
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	generate := func(ctx context.Context, opts searchOptions) (string, float64, []SearchResult, error) {
+		started <- struct{}{}
+		<-release
+		if opts.Direct {
+			return "direct", 0, nil, nil
+		}
+		return "rag", 0, nil, nil
+	}
+
+	var wg sync.WaitGroup
+	answers := make([]string, 2)
+	for i, opts := range []searchOptions{{}, {Direct: true}} {
+		wg.Add(1)
+		go func(i int, opts searchOptions) {
+			defer wg.Done()
+			answer, _, _, err := flights.do(context.Background(), "闫同学是谁？", opts, generate)
+			if err != nil {
+				t.Error(err)
+			}
+			answers[i] = answer
+		}(i, opts)
+	}
+	// 两个请求都开始生成后才放行，确认没有合并到同一次生成
+	<-started
+	<-started
+	close(release)
+	wg.Wait()
+
+	if answers[0] != "rag" || answers[1] != "direct" {
+		t.Fatalf("回答为 %q，应为 [rag direct]", answers)
+	}
+	if n := flights.Coalesced(); n != 0 {
+		t.Fatalf("合并了 %d 个请求，应为0", n)
+	}
+}
+
+// 参数相同的并发请求合并为一次生成
+func TestAnswerFlightsCoalesceSameMode(t *testing.T) {
+	flights := newAnswerFlights(true)
+	release := make(chan struct{})
+	calls := 0
+	var mu sync.Mutex
+	generate := func(ctx context.Context, opts searchOptions) (string, float64, []SearchResult, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		return "direct", 0, nil, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, _, err := flights.do(context.Background(), "闫同学是谁？", searchOptions{Direct: true}, generate); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// 等第二个请求加入进行中的生成
+	for flights.Coalesced() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("生成了 %d 次，应为1", calls)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 压缩后写入文本字段的分块正文带此前缀，读取时据此判断是否需要解压
+const compressedPrefix = "zstd:"
+
+// EncodeAll/DecodeAll可以并发调用，编解码器全局共用
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func compressChunk(content string) []byte {
+	return zstdEncoder.EncodeAll([]byte(content), nil)
+}
+
+func decompressChunk(data []byte) (string, error) {
+	decoded, err := zstdDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return "", fmt.Errorf("解压分块失败: %w", err)
+	}
+	return string(decoded), nil
+}
+
+// 压缩为可写入文本字段的形式；短文本压缩后反而更长，此时保留原文
+func encodeCompressed(content string) string {
+	encoded := compressedPrefix + base64.StdEncoding.EncodeToString(compressChunk(content))
+	if len(encoded) >= len(content) {
+		return content
+	}
+	return encoded
+}
+
+// 读取文本字段中的分块正文，未压缩的原样返回
+func decodeCompressed(stored string) (string, error) {
+	if !strings.HasPrefix(stored, compressedPrefix) {
+		return stored, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, compressedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("解压分块失败: %w", err)
+	}
+	return decompressChunk(data)
+}
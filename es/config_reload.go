@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// 配置热更新：serve每CONFIG_RELOAD_SECONDS秒检查.env（CONFIG_FILE）、术语表、回答策略、词表和特性开关文件的修改时间，变化时重新加载并校验，
+// 只应用可以安全热更新的配置：提示词、检索参数、REVIEW_THRESHOLD、特性开关、术语表、回答策略、屏蔽词表和发布配置的分流；其他配置的变化只记录，重启后生效。
+// 进程环境变量优先于.env，启动时已由环境变量设置的配置不会被.env覆盖。每次重新加载向CONFIG_AUDIT_LOG追加一条JSONL审计记录。
+// 依赖中没有fsnotify，按修改时间轮询；CONFIG_RELOAD_SECONDS=0时关闭
+type ReloadConfig struct {
+	EnvFile  string
+	Interval time.Duration
+	AuditLog string
+}
+
+func loadReloadConfig() ReloadConfig {
+	return ReloadConfig{
+		EnvFile:  getEnv("CONFIG_FILE", ".env"),
+		Interval: time.Duration(getEnvAsInt("CONFIG_RELOAD_SECONDS", 10)) * time.Second,
+		AuditLog: getEnv("CONFIG_AUDIT_LOG", "config_audit.jsonl"),
+	}
+}
+
+// 可以热更新的环境变量
+var hotReloadKeys = []string{
+	"RAG_SYSTEM_PROMPT",
+	"TOP_K", "TOP_K_MODE", "TOP_K_MAX", "TOP_K_SCORE_GAP", "CONTEXT_TOKEN_BUDGET", "ACCURACY_PROFILE", "MAX_CHUNKS_PER_DOC", "CONTEXT_ORDER",
+	"REVIEW_THRESHOLD",
+	"FEATURE_FLAGS", "MMR_LAMBDA",
+}
+
+// 可热更新的配置，整体替换，读取方每次拿到一致的快照
+type liveSettings struct {
+	SystemPrompt string
+	Retrieval    RetrievalConfig
+	glossary     *glossary
+	policies     *answerPolicies // 回答策略，未配置时为nil
+	lexicon      *lexicon        // 屏蔽词和替换词表，未配置时为nil
+	MMRLambda    float64
+	flags        featureFlags // 检索特性开关
+}
+
+func (r *RAGSystem) settings() *liveSettings {
+	return r.live.Load()
+}
+
+// 一项配置的变化
+type configChange struct {
+	Key     string `json:"key"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+	Applied bool   `json:"applied"`
+	Note    string `json:"note,omitempty"` // 未应用的原因
+}
+
+// 一次重新加载的审计记录
+type configAudit struct {
+	Time    time.Time      `json:"time"`
+	File    string         `json:"file"`
+	Changes []configChange `json:"changes,omitempty"`
+	Error   string         `json:"error,omitempty"` // 校验失败时整个文件的变化都不应用
+}
+
+// 监视配置文件，变化时重新加载
+type configWatcher struct {
+	rag       *RAGSystem
+	config    ReloadConfig
+	inherited map[string]bool   // 启动时已由进程环境变量设置的键，.env不覆盖
+	env       map[string]string // 上次加载的.env内容
+	modTimes  map[string]time.Time
+	rollout   *rolloutRouter // 启用了发布配置时同时监视ROLLOUT_FILE
+}
+
+// 包初始化时（加载.env之前）由进程环境变量设置的可热更新键
+var inheritedEnv = func() map[string]bool {
+	keys := make(map[string]bool)
+	for _, key := range hotReloadKeys {
+		if _, ok := os.LookupEnv(key); ok {
+			keys[key] = true
+		}
+	}
+	return keys
+}()
+
+// 启动配置热更新，rollout不为nil时发布配置文件的变化也重新加载；CONFIG_RELOAD_SECONDS=0时不启动
+func (r *RAGSystem) startConfigWatcher(rollout *rolloutRouter) bool {
+	config := r.config.Reload
+	if config.Interval <= 0 {
+		return false
+	}
+	w := &configWatcher{rag: r, config: config, inherited: inheritedEnv, modTimes: make(map[string]time.Time), rollout: rollout}
+	w.env, _ = godotenv.Read(config.EnvFile)
+	for _, path := range w.files() {
+		w.modTimes[path] = modTime(path)
+	}
+	go func() {
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			w.poll()
+		}
+	}()
+	return true
+}
+
+func (w *configWatcher) files() []string {
+	files := []string{w.config.EnvFile, w.rag.config.GlossaryFile, w.rag.config.PolicyFile, w.rag.config.LexiconFile, w.rag.config.Features.File}
+	if w.rollout != nil {
+		files = append(files, w.rollout.path)
+	}
+	return files
+}
+
+// 文件的修改时间，文件不存在时为零值
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func (w *configWatcher) poll() {
+	for _, path := range w.files() {
+		mtime := modTime(path)
+		if mtime.Equal(w.modTimes[path]) {
+			continue
+		}
+		w.modTimes[path] = mtime
+		var audit configAudit
+		switch {
+		case path == w.config.EnvFile:
+			audit = w.reloadEnv()
+		case path == w.rag.config.GlossaryFile:
+			audit = w.reloadGlossary()
+		case path == w.rag.config.Features.File:
+			audit = w.reloadFlags()
+		case path == w.rag.config.LexiconFile:
+			audit = w.reloadLexicon()
+		case w.rollout != nil && path == w.rollout.path:
+			audit = w.reloadRollout()
+		default:
+			audit = w.reloadPolicies()
+		}
+		audit.Time, audit.File = time.Now(), path
+		w.record(audit)
+	}
+}
+
+// 重新读取.env，应用可热更新的键；校验失败时恢复环境变量，不应用任何变化
+func (w *configWatcher) reloadEnv() configAudit {
+	env, err := godotenv.Read(w.config.EnvFile)
+	if err != nil && !os.IsNotExist(err) {
+		return configAudit{Error: fmt.Sprintf("读取配置文件失败: %v", err)}
+	}
+	var audit configAudit
+	keys := make(map[string]bool)
+	for key := range env {
+		keys[key] = true
+	}
+	for key := range w.env {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		if env[key] != w.env[key] {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+
+	previous := make(map[string]*string)
+	for _, key := range sorted {
+		change := configChange{Key: key}
+		switch {
+		case !containsString(hotReloadKeys, key):
+			// 其他配置可能包含密钥，审计记录中不保存取值
+			change.Note = "需重启生效"
+		case w.inherited[key]:
+			change.Note = "已由进程环境变量设置"
+		case key == "REVIEW_THRESHOLD" && w.rag.reviews == nil:
+			change.Note = "未开启人工审核，需重启生效"
+		default:
+			change.Old, change.New, change.Applied = w.env[key], env[key], true
+			if value, ok := os.LookupEnv(key); ok {
+				previous[key] = &value
+			} else {
+				previous[key] = nil
+			}
+			if value, ok := env[key]; ok {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		audit.Changes = append(audit.Changes, change)
+	}
+	w.env = env
+
+	if len(previous) == 0 {
+		return audit
+	}
+	if err := w.rag.applyEnv(); err != nil {
+		for key, value := range previous {
+			if value != nil {
+				os.Setenv(key, *value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		for i := range audit.Changes {
+			if audit.Changes[i].Applied {
+				audit.Changes[i].Applied = false
+				audit.Changes[i].Note = "校验失败"
+			}
+		}
+		audit.Error = err.Error()
+	}
+	return audit
+}
+
+// 按当前环境变量重新生成可热更新的配置，校验通过后整体替换
+func (r *RAGSystem) applyEnv() error {
+	retrieval := loadRetrievalConfig()
+	if retrieval.TopK <= 0 || retrieval.MaxK <= 0 || retrieval.TokenBudget <= 0 {
+		return fmt.Errorf("TOP_K、TOP_K_MAX、CONTEXT_TOKEN_BUDGET必须大于0")
+	}
+	if retrieval.ScoreGap < 0 || retrieval.MaxPerDoc < 0 {
+		return fmt.Errorf("TOP_K_SCORE_GAP、MAX_CHUNKS_PER_DOC不能为负数")
+	}
+	if _, err := (searchOptions{}).resolve(retrieval.Profile); err != nil {
+		return err
+	}
+	if err := validateContextOrder(retrieval.Order); err != nil {
+		return err
+	}
+	features := loadFeatureConfig()
+	flags, err := loadFeatureFlags(features)
+	if err != nil {
+		return err
+	}
+	if features.MMRLambda < 0 || features.MMRLambda > 1 {
+		return fmt.Errorf("MMR_LAMBDA应在0-1之间")
+	}
+	threshold := loadReviewConfig().Threshold
+	if r.reviews != nil && threshold <= 0 {
+		return fmt.Errorf("REVIEW_THRESHOLD必须大于0，关闭人工审核需重启")
+	}
+
+	settings := *r.settings()
+	// 缓存的回答按默认精度档位生成，默认档位变化后清空
+	if retrieval.Profile != settings.Retrieval.Profile {
+		r.answers.Clear()
+	}
+	settings.SystemPrompt = getEnv("RAG_SYSTEM_PROMPT", ragSystemPrompt)
+	settings.Retrieval = retrieval
+	settings.flags = flags
+	settings.MMRLambda = features.MMRLambda
+	r.live.Store(&settings)
+	r.reviews.SetThreshold(threshold)
+	return nil
+}
+
+func (w *configWatcher) reloadGlossary() configAudit {
+	terms, err := loadGlossary(w.rag.config.GlossaryFile)
+	if err != nil {
+		return configAudit{Error: err.Error()}
+	}
+	old := w.rag.settings()
+	settings := *old
+	settings.glossary = terms
+	w.rag.live.Store(&settings)
+	return configAudit{Changes: []configChange{{
+		Key: "glossary", Old: strconv.Itoa(len(old.glossary.entries)) + "条", New: strconv.Itoa(len(terms.entries)) + "条", Applied: true,
+	}}}
+}
+
+func (w *configWatcher) reloadPolicies() configAudit {
+	policies, err := loadAnswerPolicies(w.rag.config.PolicyFile)
+	if err != nil {
+		return configAudit{Error: err.Error()}
+	}
+	settings := *w.rag.settings()
+	settings.policies = policies
+	w.rag.live.Store(&settings)
+	return configAudit{Changes: []configChange{{Key: "answer_policy", Applied: true}}}
+}
+
+func (w *configWatcher) reloadLexicon() configAudit {
+	words, err := loadLexicon(w.rag.config.LexiconFile)
+	if err != nil {
+		return configAudit{Error: err.Error()}
+	}
+	settings := *w.rag.settings()
+	settings.lexicon = words
+	w.rag.live.Store(&settings)
+	return configAudit{Changes: []configChange{{Key: "lexicon", Applied: true}}}
+}
+
+func (w *configWatcher) reloadFlags() configAudit {
+	// FEATURE_FLAGS可能已随.env热更新，重新读取环境变量
+	flags, err := loadFeatureFlags(loadFeatureConfig())
+	if err != nil {
+		return configAudit{Error: err.Error()}
+	}
+	settings := *w.rag.settings()
+	settings.flags = flags
+	w.rag.live.Store(&settings)
+	return configAudit{Changes: []configChange{{Key: "feature_flags", Applied: true}}}
+}
+
+// 重新加载发布配置，切换分流比例、candidate和active；新profile的RAGSystem创建失败时保持原配置
+func (w *configWatcher) reloadRollout() configAudit {
+	old, state, err := w.rollout.reload()
+	if err != nil {
+		return configAudit{Error: err.Error()}
+	}
+	printRollout(state)
+	return configAudit{Changes: []configChange{{Key: "rollout", Old: old.summary(), New: state.summary(), Applied: true}}}
+}
+
+// 输出并追加审计记录，写入失败只告警
+func (w *configWatcher) record(audit configAudit) {
+	if audit.Error != "" {
+		fmt.Printf("⚠️  重新加载 %s 失败: %s\n", audit.File, audit.Error)
+	}
+	for _, change := range audit.Changes {
+		if change.Applied {
+			fmt.Printf("🔄 配置已更新 %s: %q -> %q\n", change.Key, change.Old, change.New)
+		} else {
+			fmt.Printf("⏸️  配置 %s 已修改但未应用（%s）\n", change.Key, change.Note)
+		}
+	}
+	if w.config.AuditLog == "" {
+		return
+	}
+	data, err := json.Marshal(audit)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(w.config.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		fmt.Printf("⚠️  写入配置审计日志失败: %v\n", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		fmt.Printf("⚠️  写入配置审计日志失败: %v\n", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 上下文中分块的排列方式
+const (
+	orderByID            = "id"             // 按分块ID，同一批检索结果总能生成相同的前缀，便于命中上下文缓存（默认）
+	orderRelevanceFirst  = "relevance"      // 最相关的在前
+	orderRelevanceLast   = "relevance_last" // 最相关的在后，紧挨问题，缓解长上下文中间内容被忽略（lost in the middle）
+	orderChronological   = "chronological"  // 按元数据中的日期从早到晚，较新的内容靠近问题；没有日期的排在最前
+	orderGroupByDocument = "document"       // 同一文档的分块放在一起并按原文顺序排列，文档按最相关分块的分数排列
+)
+
+var contextOrders = []string{orderByID, orderRelevanceFirst, orderRelevanceLast, orderChronological, orderGroupByDocument}
+
+func validateContextOrder(order string) error {
+	if containsString(contextOrders, order) {
+		return nil
+	}
+	return fmt.Errorf("未知的上下文排列方式: %s，可选 %s", order, strings.Join(contextOrders, "、"))
+}
+
+// 按排列方式返回重新排序的副本，不修改检索结果
+func orderContext(results []SearchResult, order string) []SearchResult {
+	ordered := append([]SearchResult(nil), results...)
+	byID := func(i, j int) bool { return ordered[i].ID < ordered[j].ID }
+
+	switch order {
+	case orderRelevanceFirst:
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Score > ordered[j].Score })
+	case orderRelevanceLast:
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Score < ordered[j].Score })
+	case orderChronological:
+		sort.SliceStable(ordered, byID)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			a, aok := metaTime(ordered[i].Meta)
+			b, bok := metaTime(ordered[j].Meta)
+			if aok != bok {
+				return !aok
+			}
+			return a.Before(b)
+		})
+	case orderGroupByDocument:
+		best := make(map[string]float64)
+		for _, result := range ordered {
+			if score, ok := best[result.DocID]; !ok || result.Score > score {
+				best[result.DocID] = result.Score
+			}
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			a, b := ordered[i], ordered[j]
+			if a.DocID != b.DocID {
+				if best[a.DocID] != best[b.DocID] {
+					return best[a.DocID] > best[b.DocID]
+				}
+				return a.DocID < b.DocID
+			}
+			return chunkSeq(a.ID) < chunkSeq(b.ID)
+		})
+	default:
+		sort.SliceStable(ordered, byID)
+	}
+	return ordered
+}
+
+// 分块在文档中的序号，分块ID格式为 文档ID#序号
+func chunkSeq(chunkID string) int {
+	i := strings.LastIndex(chunkID, "#")
+	if i < 0 {
+		return 0
+	}
+	seq, _ := strconv.Atoi(chunkID[i+1:])
+	return seq
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 续写次数用完仍被截断时附在回答末尾的说明，缓存的回答也据此识别是否被截断
+const truncatedNotice = "（回答过长，已截断）"
+
+const continuePrompt = "请紧接着上文中断处继续回答，不要重复已经输出的内容，也不要添加过渡语。"
+
+// 续写请求：把目前已生成的回答作为助手消息，要求模型从中断处继续
+func continuationRequest(request openai.ChatCompletionRequest, partial string) openai.ChatCompletionRequest {
+	next := request
+	next.Messages = append(append([]openai.ChatCompletionMessage(nil), request.Messages...),
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: partial},
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: continuePrompt},
+	)
+	return next
+}
+
+func markTruncated(answer string) string {
+	return strings.TrimRight(answer, " \n") + "……\n\n" + truncatedNotice
+}
+
+func isTruncated(answer string) bool {
+	return strings.Contains(answer, truncatedNotice)
+}
+
+// 生成回答：模型因MaxTokens停止（finish_reason为length）时自动续写，最多ANSWER_MAX_CONTINUATIONS次（指定长度档位时不续写）；
+// 续写次数用完或续写失败时返回已生成的部分并标注截断
+func (r *RAGSystem) completeAnswer(ctx context.Context, request openai.ChatCompletionRequest) (string, error) {
+	request = r.adaptForReasoning(request)
+	recordPrompt(ctx, request)
+	var answer strings.Builder
+	for round := 0; ; round++ {
+		next := request
+		if round > 0 {
+			next = continuationRequest(request, answer.String())
+		}
+		resp, err := r.chatClient(next.Model).CreateChatCompletion(ctx, next)
+		if err != nil {
+			// 请求已取消时不返回部分回答，避免被当作截断的回答写入缓存
+			if round == 0 || ctx.Err() != nil {
+				return "", llmError(ctx, err)
+			}
+			fmt.Printf("⚠️  续写回答失败: %v\n", err)
+			break
+		}
+		if len(resp.Choices) == 0 {
+			if round == 0 {
+				return "", fmt.Errorf("%w: 未收到回答", ErrLLMUnavailable)
+			}
+			break
+		}
+		choice := resp.Choices[0]
+		answer.WriteString(choice.Message.Content)
+		if choice.FinishReason != openai.FinishReasonLength {
+			return answer.String(), nil
+		}
+		if round >= r.continuations(ctx) {
+			break
+		}
+		fmt.Printf("✂️  回答达到长度上限，第 %d 次续写\n", round+1)
+	}
+	return markTruncated(answer.String()), nil
+}
+
+// 流式生成回答，截断时的续写与completeAnswer相同，续写内容接在同一个回答后面输出
+func (r *RAGSystem) streamAnswer(ctx context.Context, request openai.ChatCompletionRequest, sink replySink) (string, error) {
+	request = r.adaptForReasoning(request)
+	recordPrompt(ctx, request)
+	request.Stream = true
+	var answer strings.Builder
+	for round := 0; ; round++ {
+		next := request
+		if round > 0 {
+			next = continuationRequest(request, answer.String())
+		}
+		finishReason, err := r.streamRound(ctx, next, &answer, sink)
+		if err != nil {
+			if round == 0 || ctx.Err() != nil {
+				return answer.String(), llmError(ctx, err)
+			}
+			fmt.Printf("⚠️  续写回答失败: %v\n", err)
+			break
+		}
+		if finishReason != openai.FinishReasonLength {
+			return answer.String(), nil
+		}
+		if round >= r.continuations(ctx) {
+			break
+		}
+		fmt.Printf("✂️  回答达到长度上限，第 %d 次续写\n", round+1)
+	}
+	return markTruncated(answer.String()), nil
+}
+
+// 执行一次流式请求，增量内容追加到answer，返回结束原因
+func (r *RAGSystem) streamRound(ctx context.Context, request openai.ChatCompletionRequest, answer *strings.Builder, sink replySink) (openai.FinishReason, error) {
+	stream, err := r.chatClient(request.Model).CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var finishReason openai.FinishReason
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return finishReason, nil
+		}
+		if err != nil {
+			return finishReason, err
+		}
+		if len(resp.Choices) == 0 {
+			continue
+		}
+		if resp.Choices[0].FinishReason != "" {
+			finishReason = resp.Choices[0].FinishReason
+		}
+		if resp.Choices[0].Delta.Content == "" {
+			continue
+		}
+		answer.WriteString(resp.Choices[0].Delta.Content)
+		if err := sink.Update(answer.String()); err != nil {
+			return finishReason, err
+		}
+	}
+}
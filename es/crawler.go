@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// 网页抓取配置
+type CrawlerConfig struct {
+	SeedURLs      []string
+	MaxPages      int
+	MaxDepth      int
+	Concurrency   int           // 全局并发上限
+	DomainDelay   time.Duration // 同一域名两次请求的最小间隔
+	Allow         []string      // URL白名单正则，为空表示只限种子域名
+	Deny          []string      // URL黑名单正则
+	RespectRobots bool
+	MaxRetries    int // 429/503时的最大重试次数
+	UserAgent     string
+	Timeout       time.Duration
+}
+
+// 加载网页抓取配置
+func loadCrawlerConfig() CrawlerConfig {
+	return CrawlerConfig{
+		SeedURLs:      splitEnvList("CRAWL_URLS"),
+		MaxPages:      getEnvAsInt("CRAWL_MAX_PAGES", 50),
+		MaxDepth:      getEnvAsInt("CRAWL_MAX_DEPTH", 1),
+		Concurrency:   getEnvAsInt("CRAWL_CONCURRENCY", 2),
+		DomainDelay:   time.Duration(getEnvAsInt("CRAWL_DOMAIN_DELAY_MS", 1000)) * time.Millisecond,
+		Allow:         splitEnvList("CRAWL_ALLOW"),
+		Deny:          splitEnvList("CRAWL_DENY"),
+		RespectRobots: getEnvAsBool("CRAWL_RESPECT_ROBOTS", true),
+		MaxRetries:    getEnvAsInt("CRAWL_MAX_RETRIES", 3),
+		UserAgent:     getEnv("CRAWL_USER_AGENT", "rag-demo-crawler/1.0"),
+		Timeout:       time.Duration(getEnvAsInt("CRAWL_TIMEOUT_SECONDS", 15)) * time.Second,
+	}
+}
+
+// 读取逗号分隔的环境变量列表
+func splitEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// 礼貌的网页抓取器：遵守robots.txt、按域名限速、限制并发、429时退避重试
+type crawler struct {
+	config    CrawlerConfig
+	client    *http.Client
+	allow     []*regexp.Regexp
+	deny      []*regexp.Regexp
+	seedHosts map[string]bool
+	sem       chan struct{}
+
+	mu        sync.Mutex
+	robots    map[string]*robotsRules
+	nextFetch map[string]time.Time
+}
+
+// 创建网页抓取器
+func newCrawler(config CrawlerConfig) (*crawler, error) {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+
+	c := &crawler{
+		config:    config,
+		client:    &http.Client{Timeout: config.Timeout},
+		seedHosts: make(map[string]bool),
+		sem:       make(chan struct{}, config.Concurrency),
+		robots:    make(map[string]*robotsRules),
+		nextFetch: make(map[string]time.Time),
+	}
+	for _, pattern := range config.Allow {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("CRAWL_ALLOW正则 %q 无效: %w", pattern, err)
+		}
+		c.allow = append(c.allow, re)
+	}
+	for _, pattern := range config.Deny {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("CRAWL_DENY正则 %q 无效: %w", pattern, err)
+		}
+		c.deny = append(c.deny, re)
+	}
+	for _, seed := range config.SeedURLs {
+		if u, err := url.Parse(seed); err == nil {
+			c.seedHosts[u.Host] = true
+		}
+	}
+	return c, nil
+}
+
+// 抓取页面结果
+type crawledPage struct {
+	URL   string
+	Body  []byte
+	Links []string
+}
+
+// 从种子URL开始按层抓取，返回解析后的文档
+func (c *crawler) Crawl(ctx context.Context) ([]Document, error) {
+	visited := make(map[string]bool)
+	frontier := c.config.SeedURLs
+	var documents []Document
+
+	for depth := 0; depth <= c.config.MaxDepth && len(frontier) > 0; depth++ {
+		var batch []string
+		for _, link := range frontier {
+			if visited[link] || len(visited) >= c.config.MaxPages || !c.allowed(link) {
+				continue
+			}
+			visited[link] = true
+			batch = append(batch, link)
+		}
+
+		pages := c.fetchAll(ctx, batch)
+		frontier = nil
+		for _, page := range pages {
+			doc, err := loadDocument(page.URL, page.Body)
+			if err != nil {
+				fmt.Printf("⚠️  跳过页面 %s: %v\n", page.URL, err)
+				continue
+			}
+			// 网页没有本地文件，用source_url代替source_path
+			delete(doc.Meta, "source_path")
+			doc.Meta["source_url"] = page.URL
+			documents = append(documents, doc)
+			frontier = append(frontier, page.Links...)
+		}
+	}
+
+	fmt.Printf("🕷️  抓取完成: 访问 %d 个URL，得到 %d 个文档\n", len(visited), len(documents))
+	return documents, nil
+}
+
+// 并发抓取一批URL，结果按输入顺序返回
+func (c *crawler) fetchAll(ctx context.Context, links []string) []crawledPage {
+	results := make([]*crawledPage, len(links))
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func(i int, link string) {
+			defer wg.Done()
+			c.sem <- struct{}{}
+			defer func() { <-c.sem }()
+
+			page, err := c.fetch(ctx, link)
+			if err != nil {
+				fmt.Printf("⚠️  抓取 %s 失败: %v\n", link, err)
+				return
+			}
+			results[i] = page
+		}(i, link)
+	}
+	wg.Wait()
+
+	var pages []crawledPage
+	for _, page := range results {
+		if page != nil {
+			pages = append(pages, *page)
+		}
+	}
+	return pages
+}
+
+// 抓取单个页面，遵守robots.txt并在429/503时退避重试
+func (c *crawler) fetch(ctx context.Context, link string) (*crawledPage, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.RespectRobots && !c.robotsFor(ctx, u).allowed(u.RequestURI()) {
+		return nil, fmt.Errorf("robots.txt禁止抓取")
+	}
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		if err := c.waitTurn(ctx, u.Host); err != nil {
+			return nil, err
+		}
+
+		body, retryAfter, err := c.get(ctx, link)
+		if err == nil {
+			return &crawledPage{URL: link, Body: body, Links: c.extractLinks(u, body)}, nil
+		}
+		if retryAfter < 0 || attempt >= c.config.MaxRetries {
+			return nil, err
+		}
+
+		// 优先使用服务端给出的Retry-After，否则指数退避
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		backoff *= 2
+		fmt.Printf("⏳ %s 被限流，%s 后重试\n", link, wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// 发起GET请求，retryAfter<0表示不可重试
+func (c *crawler) get(ctx context.Context, link string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, -1, err
+	}
+	req.Header.Set("User-Agent", c.config.UserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("状态码 %d", resp.StatusCode)
+	case resp.StatusCode >= 400:
+		return nil, -1, fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, -1, err
+	}
+	return body, 0, nil
+}
+
+// 解析Retry-After头（秒数或HTTP日期）
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(t); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// 按域名限速：预约下一个可用时间片并等待
+func (c *crawler) waitTurn(ctx context.Context, host string) error {
+	delay := c.config.DomainDelay
+	if rules := c.cachedRobots(host); rules != nil && rules.crawlDelay > delay {
+		delay = rules.crawlDelay
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	slot := c.nextFetch[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	c.nextFetch[host] = slot.Add(delay)
+	c.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(slot)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// URL是否在允许抓取的范围内
+func (c *crawler) allowed(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	for _, re := range c.deny {
+		if re.MatchString(link) {
+			return false
+		}
+	}
+	if len(c.allow) == 0 {
+		return c.seedHosts[u.Host]
+	}
+	for _, re := range c.allow {
+		if re.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// 提取页面中的链接并转换为绝对地址
+func (c *crawler) extractLinks(base *url.URL, body []byte) []string {
+	var links []string
+	tokenizer := html.NewTokenizer(strings.NewReader(string(body)))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) != "a" || !hasAttr {
+				continue
+			}
+			for {
+				key, value, more := tokenizer.TagAttr()
+				if string(key) == "href" {
+					if ref, err := base.Parse(string(value)); err == nil {
+						ref.Fragment = ""
+						links = append(links, ref.String())
+					}
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	}
+}
+
+// robots.txt中适用于本抓取器的规则
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+}
+
+// 路径是否允许抓取，按最长匹配规则判断
+func (r *robotsRules) allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	longestAllow, longestDisallow := -1, -1
+	for _, prefix := range r.allow {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longestAllow {
+			longestAllow = len(prefix)
+		}
+	}
+	for _, prefix := range r.disallow {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longestDisallow {
+			longestDisallow = len(prefix)
+		}
+	}
+	return longestDisallow < 0 || longestAllow >= longestDisallow
+}
+
+func (c *crawler) cachedRobots(host string) *robotsRules {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.robots[host]
+}
+
+// 获取并缓存站点的robots.txt规则，获取失败时视为不限制
+func (c *crawler) robotsFor(ctx context.Context, u *url.URL) *robotsRules {
+	c.mu.Lock()
+	rules, ok := c.robots[u.Host]
+	c.mu.Unlock()
+	if ok {
+		return rules
+	}
+
+	robotsURL := fmt.Sprintf("%s://%s/robots.txt", u.Scheme, u.Host)
+	body, _, err := c.get(ctx, robotsURL)
+	if err == nil {
+		rules = parseRobots(string(body), c.config.UserAgent)
+	}
+
+	c.mu.Lock()
+	c.robots[u.Host] = rules
+	c.mu.Unlock()
+	return rules
+}
+
+// 解析robots.txt，优先使用匹配本抓取器User-agent的分组，否则使用*分组
+func parseRobots(content, userAgent string) *robotsRules {
+	groups := make(map[string]*robotsRules)
+	var current []string
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if !lastWasAgent {
+				current = nil
+			}
+			agent := strings.ToLower(value)
+			current = append(current, agent)
+			if groups[agent] == nil {
+				groups[agent] = &robotsRules{}
+			}
+			lastWasAgent = true
+			continue
+		}
+		lastWasAgent = false
+
+		for _, agent := range current {
+			rules := groups[agent]
+			switch key {
+			case "allow":
+				if value != "" {
+					rules.allow = append(rules.allow, value)
+				}
+			case "disallow":
+				if value != "" {
+					rules.disallow = append(rules.disallow, value)
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+					rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	name := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+	for agent, rules := range groups {
+		if agent != "*" && strings.Contains(name, agent) {
+			return rules
+		}
+	}
+	return groups["*"]
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// 向量空间
+const (
+	vectorSpaceIndex    = "index"    // 检索使用的向量，与知识库中分块的向量可比
+	vectorSpaceSemantic = "semantic" // EMBEDDING_PROVIDER生成的向量，用于答案缓存、FAQ、会话历史和抽取式回答
+)
+
+const (
+	maxDebugTexts = 20
+	maxDebugTopK  = 20
+)
+
+type debugEmbeddingsRequest struct {
+	Texts          []string `json:"texts"`
+	Space          string   `json:"space,omitempty"` // index（默认）或semantic，两两相似度按该向量空间计算
+	TopK           int      `json:"top_k,omitempty"` // 每段文本返回的最近分块数，默认3，为负数时只比较向量不检索
+	IncludeVectors bool     `json:"include_vectors,omitempty"`
+}
+
+type debugEmbedding struct {
+	Text    string         `json:"text"`
+	Query   string         `json:"query,omitempty"`  // 实际检索的文本，开启跨语言检索时为翻译后的问题
+	Vector  []float32      `json:"vector,omitempty"` // 仅在请求include_vectors时返回
+	Nearest []SearchResult `json:"nearest"`          // 检索向量最相近的分块，不做分类路由、过滤和可信度加权
+}
+
+type debugEmbeddingsResponse struct {
+	Space        string           `json:"space"`
+	Dim          int              `json:"dim"`
+	Texts        []debugEmbedding `json:"texts"`
+	Similarities [][]float64      `json:"similarities"` // 两两余弦相似度，与texts的顺序一致
+}
+
+// 向量调试：对任意文本生成向量，返回两两相似度和最相近的分块，用于排查问题为什么匹配不到预期的文档
+func (s *apiServer) handleDebugEmbeddings(w http.ResponseWriter, req *http.Request) {
+	var body debugEmbeddingsRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if len(body.Texts) == 0 || len(body.Texts) > maxDebugTexts {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("texts需包含1到%d段文本", maxDebugTexts))
+		return
+	}
+	if body.Space == "" {
+		body.Space = vectorSpaceIndex
+	}
+	if body.Space != vectorSpaceIndex && body.Space != vectorSpaceSemantic {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("space只支持%s或%s", vectorSpaceIndex, vectorSpaceSemantic))
+		return
+	}
+	if body.TopK == 0 {
+		body.TopK = 3
+	}
+	if body.TopK > maxDebugTopK {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("top_k不能超过%d", maxDebugTopK))
+		return
+	}
+	opts, err := (searchOptions{}).resolve(s.rag.settings().Retrieval.Profile)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx := req.Context()
+	response := debugEmbeddingsResponse{Space: body.Space, Texts: make([]debugEmbedding, len(body.Texts))}
+	vectors := make([][]float32, len(body.Texts))
+	for i, text := range body.Texts {
+		if body.Space == vectorSpaceSemantic {
+			vectors[i], err = s.rag.embedder.Embed(ctx, text)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		} else if vectors[i], err = s.rag.embedWith(ctx, "", text); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		item := debugEmbedding{Text: text, Nearest: []SearchResult{}}
+		if body.IncludeVectors {
+			item.Vector = vectors[i]
+		}
+		if body.TopK > 0 {
+			query := s.rag.searchQuery(ctx, text)
+			if query != text {
+				item.Query = query
+			}
+			if item.Nearest, err = s.rag.searchScope(ctx, query, body.TopK, opts); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		response.Texts[i] = item
+	}
+	response.Dim = len(vectors[0])
+
+	response.Similarities = make([][]float64, len(vectors))
+	for i := range vectors {
+		response.Similarities[i] = make([]float64, len(vectors))
+		for j := range vectors {
+			response.Similarities[i][j] = cosineSimilarity(vectors[i], vectors[j])
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// 向量维度与索引不一致：切换了向量模型或维度后继续写入旧索引，Milvus/ES只会在写入或检索中途返回难以理解的错误
+var ErrDimensionMismatch = errors.New("向量维度与索引不一致")
+
+// 内置简化向量的维度
+const simpleVectorDim = 4
+
+// 写入和检索vector字段使用的向量维度：配置了EMBEDDING_VECTOR_DIM时由EMBEDDING_*模型生成，否则为内置简化向量；
+// 新建集合/索引时vector字段使用同一维度。命名空间模型的向量写入各自的字段，维度见vectorFields
+func (r *RAGSystem) embeddingDim() int {
+	if r.config.Embedding.VectorDim > 0 {
+		return r.config.Embedding.VectorDim
+	}
+	return simpleVectorDim
+}
+
+// 对比各向量字段的维度与集合/索引中的字段，不一致或缺少字段时返回包含维度和修复方法的错误；索引还不存在时不检查，创建时会使用当前维度
+func (r *RAGSystem) checkVectorDim(ctx context.Context) error {
+	name, indexed, ok, err := r.indexedVectorDims(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	for _, field := range r.vectorFields() {
+		dim, found := indexed[field.Name]
+		switch {
+		case !found && field.Model == "":
+			return fmt.Errorf("%s 缺少vector字段，运行 %s schema 查看差异", name, commandPrefix)
+		case !found:
+			return fmt.Errorf("%w: %s 缺少向量模型 %s 的%s字段（%d 维），运行 %s schema 查看迁移步骤，补上字段后用 %s reembed 重新向量化已有文档",
+				ErrDimensionMismatch, name, field.Model, field.Name, field.Dim, commandPrefix, commandPrefix)
+		case dim != field.Dim && field.Model == "":
+			return fmt.Errorf("%w: 当前向量为 %d 维，%s 的vector字段为 %d 维；需要按当前维度新建索引，再重新入库或用 %s reembed 重新向量化，运行 %s schema 查看迁移步骤",
+				ErrDimensionMismatch, field.Dim, name, dim, commandPrefix, commandPrefix)
+		case dim != field.Dim:
+			return fmt.Errorf("%w: 向量模型 %s 为 %d 维，%s 的%s字段为 %d 维；需要按当前维度新建索引，再重新入库或用 %s reembed 重新向量化，运行 %s schema 查看迁移步骤",
+				ErrDimensionMismatch, field.Model, field.Dim, name, field.Name, dim, commandPrefix, commandPrefix)
+		}
+	}
+	return nil
+}
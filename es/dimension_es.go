@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// 索引mapping中各dense_vector字段的维度，索引不存在时ok为false
+func (r *RAGSystem) indexedVectorDims(ctx context.Context) (string, map[string]int, bool, error) {
+	indexName := r.config.IndexName
+	res, err := r.elasticClient.Indices.GetMapping(
+		r.elasticClient.Indices.GetMapping.WithContext(ctx),
+		r.elasticClient.Indices.GetMapping.WithIndex(indexName),
+	)
+	if err != nil {
+		return "", nil, false, storeError(ctx, "查询mapping失败", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return "", nil, false, nil
+	}
+	if res.IsError() {
+		return "", nil, false, storeError(ctx, "查询mapping失败", fmt.Errorf("%s", res.String()))
+	}
+	var response map[string]struct {
+		Mappings struct {
+			Properties map[string]struct {
+				Type string `json:"type"`
+				Dims int    `json:"dims"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return "", nil, false, fmt.Errorf("解析mapping失败: %w", err)
+	}
+	for _, index := range response {
+		dims := make(map[string]int)
+		for name, property := range index.Mappings.Properties {
+			if property.Type == "dense_vector" {
+				dims[name] = property.Dims
+			}
+		}
+		return "索引 " + indexName, dims, true, nil
+	}
+	return "", nil, false, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 错误分类：流水线各环节的失败包装为以下错误，便于调用方用errors.Is识别；
+// 接口按分类返回稳定的错误码和HTTP状态码，客户端据此决定是否重试，不必解析错误信息
+var (
+	ErrStoreUnavailable = errors.New("知识库不可用")
+	ErrEmbeddingFailed  = errors.New("向量化失败")
+	ErrLLMRateLimited   = errors.New("大模型请求被限流")
+	ErrLLMUnavailable   = errors.New("大模型不可用")
+	ErrNoRelevantDocs   = errors.New("知识库中没有相关文档")
+)
+
+// 错误分类对应的错误码和HTTP状态码
+type errorKind struct {
+	err       error
+	code      string
+	status    int
+	retryable bool
+}
+
+// 按顺序匹配，同时命中多个分类时取靠前的
+var errorKinds = []errorKind{
+	{ErrLLMRateLimited, "llm_rate_limited", http.StatusTooManyRequests, true},
+	{ErrLLMUnavailable, "llm_unavailable", http.StatusBadGateway, true},
+	{ErrEmbeddingFailed, "embedding_failed", http.StatusBadGateway, true},
+	{ErrStoreUnavailable, "store_unavailable", http.StatusServiceUnavailable, true},
+	{ErrNoRelevantDocs, "no_relevant_docs", http.StatusNotFound, false},
+	{ErrIdempotencyKeyReused, "idempotency_key_reused", http.StatusUnprocessableEntity, false},
+	{ErrDimensionMismatch, "dimension_mismatch", http.StatusConflict, false},
+}
+
+// 未分类的错误按HTTP状态码给出错误码
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "invalid_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusPreconditionRequired:  "precondition_required",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// 错误的分类，未分类时沿用调用方给出的状态码
+func classifyError(status int, err error) errorKind {
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind.err) {
+			return kind
+		}
+	}
+	code, ok := statusCodes[status]
+	if !ok {
+		code = "internal"
+	}
+	return errorKind{code: code, status: status, retryable: status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable}
+}
+
+// 知识库调用失败时包装为ErrStoreUnavailable；请求已取消时原样返回，不算知识库故障
+func storeError(ctx context.Context, op string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%w: %s: %w", ErrStoreUnavailable, op, err)
+}
+
+// 大模型调用失败时按状态码包装为ErrLLMRateLimited或ErrLLMUnavailable；请求已取消时原样返回
+func llmError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	if (errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusTooManyRequests) ||
+		(errors.As(err, &reqErr) && reqErr.HTTPStatusCode == http.StatusTooManyRequests) {
+		return fmt.Errorf("%w: %w", ErrLLMRateLimited, err)
+	}
+	return fmt.Errorf("%w: %w", ErrLLMUnavailable, err)
+}
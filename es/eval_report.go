@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 评测报告导出：指定-report-dir时，逐题结果写成CSV（便于脚本处理、跨次运行diff），汇总和逐题表格写成Markdown（便于分享），
+// 文件名为 <名称>-<运行时间>.csv/.md，例如 eval-20260115-083000.csv
+func exportReports(dir, name string, runAt time.Time, rows [][]string, markdown string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建报告目录失败: %w", err)
+	}
+	base := filepath.Join(dir, name+"-"+runAt.Format("20060102-150405"))
+	file, err := os.Create(base + ".csv")
+	if err != nil {
+		return fmt.Errorf("保存CSV报告失败: %w", err)
+	}
+	writer := csv.NewWriter(file)
+	_ = writer.WriteAll(rows)
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("保存CSV报告失败: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("保存CSV报告失败: %w", err)
+	}
+	if err := os.WriteFile(base+".md", []byte(markdown), 0o644); err != nil {
+		return fmt.Errorf("保存Markdown报告失败: %w", err)
+	}
+	fmt.Printf("📄 报告: %s.csv、%s.md\n", base, base)
+	return nil
+}
+
+var evalCSVHeader = []string{"question", "doc_id", "chunk_id", "doc_hit", "chunk_hit", "answer_recall", "sources", "error", "answer"}
+
+func evalCSVRow(result evalCaseResult) []string {
+	return []string{
+		result.Question, result.DocID, result.ChunkID,
+		strconv.FormatBool(result.DocHit), strconv.FormatBool(result.ChunkHit), formatRatio(result.Recall),
+		strings.Join(result.Sources, " "), result.Err, result.Answer,
+	}
+}
+
+func evalCSV(report *evalReport) [][]string {
+	rows := [][]string{evalCSVHeader}
+	for _, result := range report.Results {
+		rows = append(rows, evalCSVRow(result))
+	}
+	return rows
+}
+
+// 对比评测的CSV：每种排列方式的每个问题一行
+func compareCSV(orders []string, reports []*evalReport) [][]string {
+	rows := [][]string{append([]string{"order"}, evalCSVHeader...)}
+	for i, report := range reports {
+		for _, result := range report.Results {
+			rows = append(rows, append([]string{orders[i]}, evalCSVRow(result)...))
+		}
+	}
+	return rows
+}
+
+func evalMarkdown(setPath string, runAt time.Time, report *evalReport) string {
+	var b strings.Builder
+	b.WriteString("# 评测报告\n\n")
+	writeEvalHeader(&b, setPath, runAt, report.Holdout)
+	b.WriteString("| 指标 | 值 |\n| --- | --- |\n")
+	fmt.Fprintf(&b, "| 用例数 | %d |\n| 失败 | %d |\n| 文档命中率 | %s |\n", report.Cases, report.Failed, percent(report.DocHitRate))
+	if report.Holdout {
+		b.WriteString("| 分块命中率 | -（留出模式） |\n")
+	} else {
+		fmt.Fprintf(&b, "| 分块命中率 | %s |\n", percent(report.ChunkHitRate))
+	}
+	fmt.Fprintf(&b, "| 答案召回率 | %s |\n\n", percent(report.AnswerRecall))
+
+	b.WriteString("## 逐题结果\n\n| # | 问题 | 文档命中 | 分块命中 | 答案召回 | 引用文档 |\n| --- | --- | --- | --- | --- | --- |\n")
+	for i, result := range report.Results {
+		if result.Err != "" {
+			fmt.Fprintf(&b, "| %d | %s | ❌ | ❌ | - | 错误: %s |\n", i+1, markdownCell(result.Question), markdownCell(result.Err))
+			continue
+		}
+		fmt.Fprintf(&b, "| %d | %s | %s | %s | %s | %s |\n", i+1, markdownCell(result.Question),
+			hitMark(result.DocHit), hitMark(result.ChunkHit), percent(result.Recall), strings.Join(result.Sources, " "))
+	}
+	return b.String()
+}
+
+// 对比评测的Markdown：各排列方式的汇总，以及每个问题在各排列方式下的答案召回率
+func compareMarkdown(setPath string, runAt time.Time, orders []string, reports []*evalReport) string {
+	var b strings.Builder
+	b.WriteString("# 上下文排列方式对比\n\n")
+	writeEvalHeader(&b, setPath, runAt, len(reports) > 0 && reports[0].Holdout)
+	b.WriteString("| 排列方式 | 答案召回率 | 文档命中率 | 失败 |\n| --- | --- | --- | --- |\n")
+	for i, report := range reports {
+		fmt.Fprintf(&b, "| %s | %s | %s | %d |\n", orders[i], percent(report.AnswerRecall), percent(report.DocHitRate), report.Failed)
+	}
+	if len(reports) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("\n## 逐题答案召回率\n\n| # | 问题 | " + strings.Join(orders, " | ") + " |\n|" + strings.Repeat(" --- |", len(orders)+2) + "\n")
+	for i, result := range reports[0].Results {
+		cells := make([]string, len(reports))
+		for j, report := range reports {
+			cells[j] = "-"
+			if i < len(report.Results) && report.Results[i].Err == "" {
+				cells[j] = percent(report.Results[i].Recall)
+			}
+		}
+		fmt.Fprintf(&b, "| %d | %s | %s |\n", i+1, markdownCell(result.Question), strings.Join(cells, " | "))
+	}
+	return b.String()
+}
+
+func writeEvalHeader(b *strings.Builder, setPath string, runAt time.Time, holdout bool) {
+	mode := "否"
+	if holdout {
+		mode = "是"
+	}
+	fmt.Fprintf(b, "- 运行时间: %s\n- 评测集: %s\n- 留出模式: %s\n\n", runAt.Format(time.RFC3339), setPath, mode)
+}
+
+func diffCSV(diffs []answerDiff, threshold float64) [][]string {
+	rows := [][]string{{"status", "question", "similarity", "base_recall", "candidate_recall", "removed", "added", "error", "base_answer", "candidate_answer"}}
+	for _, d := range diffs {
+		rows = append(rows, []string{
+			d.status(threshold), d.Question, formatRatio(d.Similarity), formatRatio(d.BaseRecall), formatRatio(d.CandRecall),
+			strings.Join(d.Removed, " "), strings.Join(d.Added, " "), d.Err, d.Base, d.Candidate,
+		})
+	}
+	return rows
+}
+
+func formatRatio(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+func percent(v float64) string {
+	return fmt.Sprintf("%.1f%%", v*100)
+}
+
+func hitMark(hit bool) string {
+	if hit {
+		return "✅"
+	}
+	return "⚠️"
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 文档过期配置：元数据expires_at（RFC3339时间，或2006-01-02表示当天结束后过期）早于当前时间的文档不再参与检索，
+// 适用于公告、促销等有时效的内容。serve每EXPIRY_SWEEP_MINUTES分钟清理一次过期文档，EXPIRY_ACTION=delete直接删除，
+// archive先把原文写入EXPIRY_ARCHIVE_DIR下的JSONL（可通过 POST /ingest 恢复）再删除；EXPIRY_SWEEP_MINUTES=0时不清理
+type ExpiryConfig struct {
+	Interval   time.Duration
+	Action     string
+	ArchiveDir string
+}
+
+func loadExpiryConfig() ExpiryConfig {
+	return ExpiryConfig{
+		Interval:   time.Duration(getEnvAsInt("EXPIRY_SWEEP_MINUTES", 60)) * time.Minute,
+		Action:     getEnv("EXPIRY_ACTION", expiryDelete),
+		ArchiveDir: getEnv("EXPIRY_ARCHIVE_DIR", "expired"),
+	}
+}
+
+const (
+	expiresAtKey  = "expires_at"
+	expiryDelete  = "delete"
+	expiryArchive = "archive"
+)
+
+// 解析元数据中的过期时间，只有日期时到当天结束（本地时间）才过期
+func parseExpiresAt(value interface{}) (time.Time, bool) {
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t.AddDate(0, 0, 1), true
+	}
+	return time.Time{}, false
+}
+
+func validateExpiresAt(value interface{}) error {
+	if _, ok := parseExpiresAt(value); !ok {
+		return fmt.Errorf("字段 %s 应为RFC3339时间或2006-01-02格式的日期", expiresAtKey)
+	}
+	return nil
+}
+
+func isExpired(meta map[string]interface{}, now time.Time) bool {
+	expiresAt, ok := parseExpiresAt(meta[expiresAtKey])
+	return ok && !now.Before(expiresAt)
+}
+
+// 原地过滤掉已过期的分块；清理是定期进行的，两次清理之间过期的文档在检索时就不再引用
+func dropExpired(results []SearchResult, now time.Time) []SearchResult {
+	kept := results[:0]
+	for _, result := range results {
+		if !isExpired(result.Meta, now) {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+// 一篇过期文档及其分块
+type expiredDocument struct {
+	DocID     string
+	Title     string
+	ExpiresAt time.Time
+	Chunks    []SearchResult
+}
+
+// 按文档归并过期的分块，按过期时间排列
+func groupExpired(chunks []SearchResult, now time.Time) []expiredDocument {
+	index := make(map[string]int)
+	var docs []expiredDocument
+	for _, chunk := range chunks {
+		if !isExpired(chunk.Meta, now) {
+			continue
+		}
+		i, ok := index[chunk.DocID]
+		if !ok {
+			expiresAt, _ := parseExpiresAt(chunk.Meta[expiresAtKey])
+			i = len(docs)
+			index[chunk.DocID] = i
+			docs = append(docs, expiredDocument{DocID: chunk.DocID, Title: chunk.Title, ExpiresAt: expiresAt})
+		}
+		docs[i].Chunks = append(docs[i].Chunks, chunk)
+	}
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].ExpiresAt.Before(docs[j].ExpiresAt) })
+	return docs
+}
+
+// 清理过期文档：按配置归档后删除，并清掉引用了这些文档的缓存答案
+func (r *RAGSystem) sweepExpired(ctx context.Context, now time.Time) ([]expiredDocument, error) {
+	docs, err := r.findExpired(ctx, now)
+	if err != nil || len(docs) == 0 {
+		return nil, err
+	}
+	if r.config.Expiry.Action == expiryArchive {
+		if err := r.archiveExpired(ctx, docs, now); err != nil {
+			return nil, err
+		}
+	}
+	docIDs := make([]string, len(docs))
+	for i, doc := range docs {
+		docIDs[i] = doc.DocID
+	}
+	if err := r.DeleteDocuments(docIDs); err != nil {
+		return nil, err
+	}
+	r.answers.InvalidateDocuments(docIDs)
+	return docs, nil
+}
+
+// 把过期文档追加到归档目录下当天的JSONL文件，每行为一篇 /ingest 格式的文档。
+// 配置了原文存储时归档原文，否则按分块顺序拼接分块内容（分块重叠的部分会重复）
+func (r *RAGSystem) archiveExpired(ctx context.Context, docs []expiredDocument, now time.Time) error {
+	dir := r.config.Expiry.ArchiveDir
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("expired-%s.jsonl", now.Format("20060102")))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, doc := range docs {
+		archived, err := r.loadOriginal(ctx, doc.DocID)
+		if err != nil {
+			archived = joinChunks(doc)
+		}
+		if err := encoder.Encode(archived); err != nil {
+			return fmt.Errorf("归档文档 %s 失败: %w", doc.DocID, err)
+		}
+	}
+	return nil
+}
+
+// 由分块还原文档：内容按分块序号拼接，元数据取首个分块的
+func joinChunks(doc expiredDocument) *ingestDocument {
+	chunks := append([]SearchResult(nil), doc.Chunks...)
+	sort.SliceStable(chunks, func(i, j int) bool { return chunkSeq(chunks[i].ID) < chunkSeq(chunks[j].ID) })
+	contents := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		contents[i] = chunk.Content
+	}
+	return &ingestDocument{ID: doc.DocID, Title: doc.Title, Content: strings.Join(contents, "\n"), Meta: chunks[0].Meta}
+}
+
+// 启动过期文档的定期清理，EXPIRY_SWEEP_MINUTES=0时不启动
+func (r *RAGSystem) startExpirySweeper() bool {
+	if r.config.Expiry.Interval <= 0 {
+		return false
+	}
+	go func() {
+		ticker := time.NewTicker(r.config.Expiry.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			docs, err := r.sweepExpired(context.Background(), time.Now())
+			if err != nil {
+				fmt.Printf("⚠️  清理过期文档失败: %v\n", err)
+				continue
+			}
+			for _, doc := range docs {
+				fmt.Printf("⌛ 文档已过期（%s）: %s %s\n", r.config.Expiry.Action, doc.DocID, doc.Title)
+			}
+		}
+	}()
+	return true
+}
+
+// expire命令：列出并清理已过期的文档
+func runExpire(args []string) error {
+	fs := flag.NewFlagSet("expire", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "只列出过期文档，不删除")
+	_ = fs.Parse(args)
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	docs, err := rag.findExpired(ctx, now)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		fmt.Println("✅ 没有过期的文档")
+		return nil
+	}
+	fmt.Printf("⌛ 发现 %d 篇过期文档:\n", len(docs))
+	for _, doc := range docs {
+		fmt.Printf("  - %s %s（%d 个分块，过期时间 %s）\n", doc.DocID, doc.Title, len(doc.Chunks), doc.ExpiresAt.Format(time.RFC3339))
+	}
+	if *dryRun {
+		fmt.Println("💡 dry-run模式，未删除任何文档")
+		return nil
+	}
+
+	if docs, err = rag.sweepExpired(ctx, now); err != nil {
+		return err
+	}
+	if rag.config.Expiry.Action == expiryArchive {
+		fmt.Printf("📦 已归档到 %s 并删除 %d 篇过期文档\n", rag.config.Expiry.ArchiveDir, len(docs))
+	} else {
+		fmt.Printf("🗑️  已删除 %d 篇过期文档\n", len(docs))
+	}
+	return nil
+}
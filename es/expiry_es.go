@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// 单次清理最多读取的过期分块数，超出的部分下次清理时处理
+const maxExpiredChunks = 10000
+
+// 查询设置了expires_at且已过期的文档；动态映射可能把expires_at识别为日期或文本，过期判断在应用内完成
+func (r *RAGSystem) findExpired(ctx context.Context, now time.Time) ([]expiredDocument, error) {
+	req := chunkSearchRequest(maxExpiredChunks)
+	req.Query = &types.Query{Exists: &types.ExistsQuery{Field: "meta." + expiresAtKey}}
+	chunks, err := searchChunks(ctx, r.typedClient, r.config.IndexName, req, 1, nil)
+	if err != nil {
+		return nil, fmt.Errorf("查询过期分块失败: %w", err)
+	}
+	return groupExpired(chunks, now), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 回答方式
+const (
+	answerModeGenerate   = "generate"   // 大模型基于检索结果生成回答
+	answerModeExtractive = "extractive" // 不调用大模型，从检索结果中摘出与问题最相近的句子
+	answerModeSummarize  = "summarize"  // 检索大量分块，分批总结后综合回答，适合范围很广的问题
+	answerModeDirect     = "direct"     // 不检索知识库，由大模型直接回答
+)
+
+// 句子的最少字符数，过短的句子信息量太少
+const minSentenceRunes = 6
+
+// 抽取式回答中的一个句子
+type extractedSentence struct {
+	Text   string
+	Source int // 所在分块在检索结果中的序号
+	Score  float64
+}
+
+// 从检索结果中选出与问题向量最相近的n个句子，按相似度排序
+func (r *RAGSystem) extractSentences(ctx context.Context, question string, results []SearchResult, n int) ([]extractedSentence, error) {
+	questionVector, err := r.embedder.Embed(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("问题向量化失败: %w", err)
+	}
+
+	var candidates []extractedSentence
+	seen := make(map[string]bool)
+	for i, result := range results {
+		for _, sentence := range splitSentences(result.Content) {
+			sentence = strings.TrimSpace(sentence)
+			if utf8.RuneCountInString(sentence) < minSentenceRunes || seen[sentence] {
+				continue
+			}
+			seen[sentence] = true
+			vector, err := r.embedder.Embed(ctx, sentence)
+			if err != nil {
+				return nil, fmt.Errorf("句子向量化失败: %w", err)
+			}
+			candidates = append(candidates, extractedSentence{Text: sentence, Source: i, Score: cosineSimilarity(questionVector, vector)})
+		}
+	}
+
+	// 相似度相同时排名靠前的分块优先
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates, nil
+}
+
+// 抽取式回答：列出摘出的句子并标注出处，零成本且不会编造内容，适合简单的事实查询
+func (r *RAGSystem) answerBySentences(ctx context.Context, question string, results []SearchResult) (string, error) {
+	sentences, err := r.extractSentences(ctx, question, results, r.config.Extractive)
+	if err != nil {
+		return "", err
+	}
+	if len(sentences) == 0 {
+		return "知识库中没有找到相关内容。", nil
+	}
+
+	var b strings.Builder
+	cited := make(map[int]int) // 检索结果序号 -> 引用编号
+	var sources []int
+	for _, sentence := range sentences {
+		ref, ok := cited[sentence.Source]
+		if !ok {
+			sources = append(sources, sentence.Source)
+			ref = len(sources)
+			cited[sentence.Source] = ref
+		}
+		fmt.Fprintf(&b, "%s[%d]\n", sentence.Text, ref)
+	}
+	b.WriteString("\n")
+	for i, source := range sources {
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, citationSource(results[source]))
+	}
+	return appendAttribution(strings.TrimRight(b.String(), "\n"), results), nil
+}
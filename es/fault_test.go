@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFaults(config FaultConfig) *faultInjector {
+	config.Enabled = true
+	return newFaultInjector(config)
+}
+
+// 同一种子下注入的故障序列相同
+func TestFaultInjectorDeterministic(t *testing.T) {
+	config := FaultConfig{ErrorRate: 0.5, Seed: 7, Targets: []string{faultTargetStore}}
+	run := func() []bool {
+		faults := newTestFaults(config)
+		failed := make([]bool, 40)
+		for i := range failed {
+			failed[i] = faults.inject(context.Background(), faultTargetStore, "测试") != nil
+		}
+		return failed
+	}
+	first, second := run(), run()
+	failures := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("第 %d 次调用的故障不同", i+1)
+		}
+		if first[i] {
+			failures++
+		}
+	}
+	if failures == 0 || failures == len(first) {
+		t.Fatalf("错误率0.5时 %d 次调用失败 %d 次", len(first), failures)
+	}
+}
+
+// 只对配置的目标注入，未开启时不注入
+func TestFaultInjectorTargets(t *testing.T) {
+	faults := newTestFaults(FaultConfig{ErrorRate: 1, Targets: []string{faultTargetStore}})
+	if err := faults.inject(context.Background(), faultTargetStore, "Milvus搜索"); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("store应注入错误，实际为 %v", err)
+	}
+	if err := faults.inject(context.Background(), faultTargetLLM, "DeepSeek RAG回答"); err != nil {
+		t.Fatalf("llm不在注入目标中，实际返回 %v", err)
+	}
+	if err := newFaultInjector(FaultConfig{ErrorRate: 1, Targets: []string{faultTargetStore}}).inject(context.Background(), faultTargetStore, "Milvus搜索"); err != nil {
+		t.Fatalf("未开启时不应注入，实际返回 %v", err)
+	}
+}
+
+// 注入的延迟超过调用方的超时时按超时返回，不等满延迟
+func TestFaultInjectorDelayRespectsTimeout(t *testing.T) {
+	faults := newTestFaults(FaultConfig{DelayRate: 1, Delay: time.Hour, Targets: []string{faultTargetLLM}})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := faults.inject(ctx, faultTargetLLM, "DeepSeek RAG回答")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("应返回超时，实际为 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("超时后仍等待了 %s", elapsed)
+	}
+}
+
+// 大模型调用被注入错误时降级为分块摘录
+func TestInjectedLLMFaultFallsBackToExtractive(t *testing.T) {
+	r := &RAGSystem{faults: newTestFaults(FaultConfig{ErrorRate: 1, Targets: []string{faultTargetLLM}})}
+	r.config.Degrade, r.config.Dates = DegradeConfig{Extractive: true, Snippets: 2}, loadDateConfig()
+	r.live.Store(&liveSettings{})
+	results := []SearchResult{
+		{ID: "a#0", DocID: "a", Title: "发布说明", Content: "v2.1 的营收为 2300 万元", Score: 0.9},
+		{ID: "b#0", DocID: "b", Title: "年报", Content: "v2.0 的营收为 2000 万元", Score: 0.8},
+	}
+
+	_, _, err := r.answerWithCalculator(context.Background(), "营收增长了多少？", results, "")
+	if !errors.Is(err, ErrInjectedFault) || !errors.Is(err, ErrLLMUnavailable) {
+		t.Fatalf("应返回注入的大模型不可用错误，实际为 %v", err)
+	}
+	opts := searchOptions{Degraded: &degradation{}}
+	answer, err := r.answerExtractive(context.Background(), results, opts, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(answer, extractiveNotice) || !strings.Contains(answer, "2300") {
+		t.Fatalf("降级回答为 %q", answer)
+	}
+	if tiers := opts.Degraded.Tiers(); !containsString(tiers, tierExtractive) {
+		t.Fatalf("降级档位为 %v，应包含 %s", tiers, tierExtractive)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 检索特性开关：新的检索行为先按百分比放给一部分流量，效果确认后再成为默认。FEATURE_FLAGS=rerank=10,mmr=50设置各开关的放量百分比，
+// FEATURE_FLAGS_FILE（JSON）中的同名开关优先，并可按命名空间（请求的category）覆盖百分比，随配置热更新重新加载。
+// 同一问题总是落在同一侧，缓存和用户看到的结果保持稳定；各开关独立分桶。没有配置的开关：rrf沿用HYBRID_SEARCH，rerank和mmr关闭
+type FeatureConfig struct {
+	File      string
+	Defaults  map[string]int
+	MMRLambda float64 // mmr选取时相关度的权重，越小结果越分散
+}
+
+func loadFeatureConfig() FeatureConfig {
+	config := FeatureConfig{
+		File:      getEnv("FEATURE_FLAGS_FILE", "flags.json"),
+		Defaults:  make(map[string]int),
+		MMRLambda: getEnvAsFloat("MMR_LAMBDA", 0.7),
+	}
+	for _, item := range splitEnvList("FEATURE_FLAGS") {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		percent, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		config.Defaults[strings.TrimSpace(name)] = percent
+	}
+	return config
+}
+
+const (
+	featureRRF    = "rrf"    // 稠密向量与关键词两路召回按RRF融合
+	featureRerank = "rerank" // 用问题向量化模型重新计算候选与问题的相似度并排序
+	featureMMR    = "mmr"    // 按最大边际相关性选取，减少内容重复的分块
+)
+
+var featureNames = []string{featureRRF, featureRerank, featureMMR}
+
+// 一个开关的放量配置
+type featureFlag struct {
+	Percent    int            `json:"percent"`
+	Namespaces map[string]int `json:"namespaces,omitempty"` // 按命名空间覆盖的百分比
+}
+
+type featureFlags map[string]featureFlag
+
+// 合并FEATURE_FLAGS和开关文件，文件不存在时只使用FEATURE_FLAGS
+func loadFeatureFlags(config FeatureConfig) (featureFlags, error) {
+	flags := make(featureFlags)
+	for name, percent := range config.Defaults {
+		flags[name] = featureFlag{Percent: percent}
+	}
+	if config.File != "" {
+		data, err := os.ReadFile(config.File)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("读取特性开关 %s 失败: %w", config.File, err)
+		}
+		if err == nil {
+			var file featureFlags
+			if err := json.Unmarshal(data, &file); err != nil {
+				return nil, fmt.Errorf("解析特性开关 %s 失败: %w", config.File, err)
+			}
+			for name, flag := range file {
+				flags[name] = flag
+			}
+		}
+	}
+	for name, flag := range flags {
+		if !containsString(featureNames, name) {
+			return nil, fmt.Errorf("未知的特性开关: %s，可选 %s", name, strings.Join(featureNames, "、"))
+		}
+		if err := validatePercent(flag.Percent); err != nil {
+			return nil, fmt.Errorf("特性开关 %s: %w", name, err)
+		}
+		for namespace, percent := range flag.Namespaces {
+			if err := validatePercent(percent); err != nil {
+				return nil, fmt.Errorf("特性开关 %s 的命名空间 %s: %w", name, namespace, err)
+			}
+		}
+	}
+	return flags, nil
+}
+
+func validatePercent(percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("百分比应在0-100之间，当前为 %d", percent)
+	}
+	return nil
+}
+
+// 开关在命名空间下的放量百分比，没有配置该开关时ok为false
+func (f featureFlags) percent(name, namespace string) (int, bool) {
+	flag, ok := f[name]
+	if !ok {
+		return 0, false
+	}
+	if percent, ok := flag.Namespaces[namespace]; ok {
+		return percent, true
+	}
+	return flag.Percent, true
+}
+
+// 问题是否落在开关的放量范围内，按开关名和归一化的问题分桶
+func (f featureFlags) enabled(name, namespace, question string, fallback bool) bool {
+	percent, ok := f.percent(name, namespace)
+	if !ok {
+		return fallback
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name + "\x00" + normalizeQuestion(question)))
+	return int(h.Sum32()%100) < percent
+}
+
+// 一次检索开启的特性，nil表示尚未确定
+type featureSet map[string]bool
+
+// 按问题和请求的分类确定开启的特性，同一问题和分类的结果总是相同
+func (r *RAGSystem) resolveFeatures(question, namespace string) featureSet {
+	flags := r.settings().flags
+	features := make(featureSet)
+	for _, name := range featureNames {
+		if flags.enabled(name, namespace, question, r.featureDefault(name)) {
+			features[name] = true
+		}
+	}
+	return features
+}
+
+// 开启的特性名，按名称排序
+func (s featureSet) names() []string {
+	var names []string
+	for name, on := range s {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// 孤儿分块：所属文档已不存在或源文件已消失
+type orphanChunk struct {
+	ID     string `json:"id"`
+	DocID  string `json:"doc_id"`
+	Reason string `json:"reason"`
+}
+
+// gc命令：清理孤儿分块
+func runGC(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "只列出孤儿分块，不删除")
+	_ = fs.Parse(args)
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	orphans, err := rag.FindOrphanChunks()
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		fmt.Println("✅ 未发现孤儿分块")
+		return nil
+	}
+
+	fmt.Printf("🔍 发现 %d 个孤儿分块:\n", len(orphans))
+	for _, orphan := range orphans {
+		fmt.Printf("  - %s (文档: %s, 原因: %s)\n", orphan.ID, orphan.DocID, orphan.Reason)
+	}
+
+	if *dryRun {
+		fmt.Println("💡 dry-run模式，未删除任何分块")
+		return nil
+	}
+
+	ids := make([]string, 0, len(orphans))
+	for _, orphan := range orphans {
+		ids = append(ids, orphan.ID)
+	}
+	if err := rag.DeleteChunks(ids); err != nil {
+		return err
+	}
+	fmt.Printf("🗑️  已删除 %d 个孤儿分块\n", len(ids))
+	return nil
+}
+
+// 查找所属文档已不存在或源文件已消失的分块
+func (r *RAGSystem) FindOrphanChunks() ([]orphanChunk, error) {
+	documents, err := r.sourceDocuments()
+	if err != nil {
+		return nil, fmt.Errorf("加载源文档失败: %w", err)
+	}
+	known := make(map[string]bool, len(documents))
+	for _, doc := range documents {
+		known[doc.ID] = true
+	}
+
+	// sitemap增量同步写入的页面
+	state, err := loadSitemapState(sitemapStatePath())
+	if err != nil {
+		return nil, fmt.Errorf("加载sitemap同步状态失败: %w", err)
+	}
+	for _, docID := range state.Pages {
+		known[docID] = true
+	}
+
+	// S3增量同步写入的对象
+	s3State, err := loadS3SyncState(s3SyncStatePath())
+	if err != nil {
+		return nil, fmt.Errorf("加载S3同步状态失败: %w", err)
+	}
+	for _, object := range s3State.Objects {
+		known[object.DocID] = true
+	}
+
+	// 数据表同步写入的行
+	sqlState, err := loadSQLSyncState(sqlSyncStatePath())
+	if err != nil {
+		return nil, fmt.Errorf("加载SQL同步状态失败: %w", err)
+	}
+	for _, docID := range sqlState.Rows {
+		known[docID] = true
+	}
+
+	// 邮箱同步写入的邮件
+	mailState, err := loadIMAPState(imapStatePath())
+	if err != nil {
+		return nil, fmt.Errorf("加载邮箱同步状态失败: %w", err)
+	}
+	for _, docID := range mailState.Documents {
+		known[docID] = true
+	}
+
+	searchQuery := map[string]interface{}{
+		"size": 10000,
+		"query": map[string]interface{}{
+			"match_all": map[string]interface{}{},
+		},
+		"_source": []string{"doc_id", "meta.source_path"},
+	}
+
+	searchJSON, _ := json.Marshal(searchQuery)
+	res, err := r.elasticClient.Search(
+		r.elasticClient.Search.WithIndex(r.config.IndexName),
+		r.elasticClient.Search.WithBody(bytes.NewReader(searchJSON)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询分块失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("查询分块错误: %s", res.String())
+	}
+
+	var searchResponse struct {
+		Hits struct {
+			Hits []struct {
+				ID     string `json:"_id"`
+				Source struct {
+					DocID string `json:"doc_id"`
+					Meta  struct {
+						SourcePath string `json:"source_path"`
+					} `json:"meta"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&searchResponse); err != nil {
+		return nil, fmt.Errorf("解析分块失败: %w", err)
+	}
+
+	var orphans []orphanChunk
+	for _, hit := range searchResponse.Hits.Hits {
+		docID := hit.Source.DocID
+		if docID == "" {
+			// 分块功能之前写入的数据，文档ID即为_id
+			docID = hit.ID
+		}
+
+		if !known[docID] {
+			orphans = append(orphans, orphanChunk{ID: hit.ID, DocID: docID, Reason: "源文档不存在"})
+			continue
+		}
+		if path := hit.Source.Meta.SourcePath; path != "" {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				orphans = append(orphans, orphanChunk{ID: hit.ID, DocID: docID, Reason: "源文件已消失: " + path})
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// 按分块ID批量删除并刷新索引
+func (r *RAGSystem) DeleteChunks(ids []string) error {
+	indexName := r.config.IndexName
+	defer r.retrievals.Clear()
+
+	var bulkBuffer bytes.Buffer
+	for _, id := range ids {
+		meta := map[string]interface{}{
+			"delete": map[string]interface{}{
+				"_index": indexName,
+				"_id":    id,
+			},
+		}
+		metaJSON, _ := json.Marshal(meta)
+		bulkBuffer.Write(metaJSON)
+		bulkBuffer.WriteByte('\n')
+	}
+
+	res, err := r.elasticClient.Bulk(
+		bytes.NewReader(bulkBuffer.Bytes()),
+		r.elasticClient.Bulk.WithIndex(indexName),
+		r.elasticClient.Bulk.WithRefresh("true"),
+	)
+	if err != nil {
+		return fmt.Errorf("删除分块失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("删除分块错误: %s", res.String())
+	}
+	return nil
+}
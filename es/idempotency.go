@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 幂等配置：写入类接口和/ask接受Idempotency-Key请求头，同一个键的重试直接返回首次请求的响应，
+// 客户端超时重试不会重复入库或重复消耗token。键在IDEMPOTENCY_TTL_MINUTES内有效，超过IDEMPOTENCY_MAX个时淘汰最早的；
+// 响应保存在内存中，服务重启后丢失；IDEMPOTENCY_TTL_MINUTES=0时关闭
+type IdempotencyConfig struct {
+	TTL     time.Duration
+	MaxKeys int
+}
+
+func loadIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		TTL:     time.Duration(getEnvAsInt("IDEMPOTENCY_TTL_MINUTES", 1440)) * time.Minute,
+		MaxKeys: getEnvAsInt("IDEMPOTENCY_MAX", 10000),
+	}
+}
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen      = 255
+)
+
+// 同一个Idempotency-Key用于不同的请求
+var ErrIdempotencyKeyReused = errors.New("Idempotency-Key已用于不同的请求")
+
+// 一个幂等键对应的请求和响应
+type idempotentEntry struct {
+	fingerprint string
+	done        chan struct{} // 首次请求处理完后关闭
+	saved       bool          // 是否保存了响应；5xx和429不保存，重试时重新处理
+	status      int
+	header      http.Header
+	body        []byte
+	createdAt   time.Time
+}
+
+// 幂等键和响应，内存保存
+type idempotencyStore struct {
+	config  IdempotencyConfig
+	mu      sync.Mutex
+	entries map[string]*idempotentEntry
+}
+
+// 未开启时返回nil
+func newIdempotencyStore(config IdempotencyConfig) *idempotencyStore {
+	if config.TTL <= 0 {
+		return nil
+	}
+	return &idempotencyStore{config: config, entries: make(map[string]*idempotentEntry)}
+}
+
+// 登记一个请求；键已存在时返回已有的记录，请求内容不同时返回ErrIdempotencyKeyReused
+func (s *idempotencyStore) begin(key, fingerprint string) (*idempotentEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if entry, ok := s.entries[key]; ok && now.Sub(entry.createdAt) <= s.config.TTL {
+		if entry.fingerprint != fingerprint {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		return entry, true, nil
+	}
+	s.evict(now)
+	entry := &idempotentEntry{fingerprint: fingerprint, done: make(chan struct{}), createdAt: now}
+	s.entries[key] = entry
+	return entry, false, nil
+}
+
+// 记录首次请求的响应；不保存时删除该键，之后的重试重新处理。需用defer调用：处理函数panic时按失败处理，
+// 唤醒等待中的重试后继续panic，交给net/http
+func (s *idempotencyStore) finish(key string, entry *idempotentEntry, recorder *responseCapture) {
+	failure := recover()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if failure != nil || recorder.status >= http.StatusInternalServerError || recorder.status == http.StatusTooManyRequests {
+		if s.entries[key] == entry {
+			delete(s.entries, key)
+		}
+	} else {
+		entry.saved = true
+		entry.status, entry.header, entry.body = recorder.status, recorder.Header().Clone(), recorder.body.Bytes()
+	}
+	close(entry.done)
+	if failure != nil {
+		panic(failure)
+	}
+}
+
+// 清理过期的键，仍然超出上限时淘汰最早的已完成请求；调用方需持有锁
+func (s *idempotencyStore) evict(now time.Time) {
+	if len(s.entries) < s.config.MaxKeys {
+		return
+	}
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range s.entries {
+		if now.Sub(entry.createdAt) > s.config.TTL {
+			delete(s.entries, key)
+			continue
+		}
+		if entry.saved && (oldestKey == "" || entry.createdAt.Before(oldest)) {
+			oldestKey, oldest = key, entry.createdAt
+		}
+	}
+	if len(s.entries) >= s.config.MaxKeys && oldestKey != "" {
+		delete(s.entries, oldestKey)
+	}
+}
+
+// 记录状态码和响应体，同时照常写给客户端
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseCapture) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseCapture) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// 请求指纹：方法、路径、查询参数、If-Match和请求体都相同才视为同一个请求的重试
+func requestFingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", req.Method, req.URL.Path, req.URL.RawQuery, req.Header.Get("If-Match"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// 按Idempotency-Key处理请求：首次请求照常处理并保存响应，重试时返回保存的响应；
+// 首次请求还在处理时，重试等待其完成。没有带该请求头或未开启时直接处理
+func (s *apiServer) serveIdempotent(w http.ResponseWriter, req *http.Request, route apiRoute) {
+	key := req.Header.Get(idempotencyKeyHeader)
+	if key == "" || s.idempotency == nil {
+		route.handle(s, w, req)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%s不能超过%d个字符", idempotencyKeyHeader, maxIdempotencyKeyLen))
+		return
+	}
+	limit := s.rag.config.DocLimits.MaxBodyBytes
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, limit))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("请求体超过 %d MB", limit>>20))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("读取请求失败: %w", err))
+		return
+	}
+	// 不同接口的键互不影响
+	scoped := route.Path + " " + key
+	fingerprint := requestFingerprint(req, body)
+
+	for {
+		entry, exists, err := s.idempotency.begin(scoped, fingerprint)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if !exists {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			recorder := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+			defer s.idempotency.finish(scoped, entry, recorder)
+			route.handle(s, recorder, req)
+			return
+		}
+		select {
+		case <-entry.done:
+		case <-req.Context().Done():
+			return
+		}
+		if !entry.saved {
+			// 首次请求失败，本次重新处理
+			continue
+		}
+		for name, values := range entry.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set(idempotencyReplayedHeader, "true")
+		w.WriteHeader(entry.status)
+		_, _ = w.Write(entry.body)
+		return
+	}
+}
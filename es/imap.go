@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+)
+
+// 邮箱同步状态：UIDVALIDITY不变时UID单调递增，只需拉取LastUID之后的邮件
+type imapState struct {
+	Folder      string   `json:"folder"`
+	UIDValidity uint32   `json:"uid_validity"`
+	LastUID     uint32   `json:"last_uid"`
+	Documents   []string `json:"documents"` // 已入库的文档ID
+}
+
+// 邮箱同步状态文件路径
+func imapStatePath() string {
+	return getEnv("IMAP_STATE_FILE", ".imap_state_es.json")
+}
+
+// 读取同步状态，文件不存在时返回空状态
+func loadIMAPState(path string) (*imapState, error) {
+	state := &imapState{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("解析同步状态失败: %w", err)
+	}
+	return state, nil
+}
+
+func saveIMAPState(path string, state *imapState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// imap命令：增量同步邮箱文件夹，邮件正文和可解析的附件分别入库，带发件人、日期和会话元数据
+func runIMAP(args []string) error {
+	fs := flag.NewFlagSet("imap", flag.ExitOnError)
+	addr := fs.String("addr", getEnv("IMAP_ADDR", ""), "IMAP服务器地址，例如 imap.example.com:993")
+	user := fs.String("user", getEnv("IMAP_USER", ""), "用户名")
+	password := fs.String("password", getEnv("IMAP_PASSWORD", ""), "密码或应用专用密码")
+	folder := fs.String("folder", getEnv("IMAP_FOLDER", "INBOX"), "同步的文件夹")
+	useTLS := fs.Bool("tls", getEnvAsBool("IMAP_TLS", true), "使用TLS连接")
+	attachments := fs.Bool("attachments", getEnvAsBool("IMAP_ATTACHMENTS", true), "同时入库文本和HTML附件")
+	statePath := fs.String("state", imapStatePath(), "同步状态文件")
+	_ = fs.Parse(args)
+
+	if *addr == "" || *user == "" {
+		return fmt.Errorf("请通过 -addr、-user 或 IMAP_ADDR、IMAP_USER 指定邮箱")
+	}
+
+	state, err := loadIMAPState(*statePath)
+	if err != nil {
+		return err
+	}
+
+	var c *client.Client
+	if *useTLS {
+		c, err = client.DialTLS(*addr, nil)
+	} else {
+		c, err = client.Dial(*addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接IMAP服务器失败: %w", err)
+	}
+	defer c.Logout()
+	if err := c.Login(*user, *password); err != nil {
+		return fmt.Errorf("登录邮箱失败: %w", err)
+	}
+
+	mailbox, err := c.Select(*folder, true)
+	if err != nil {
+		return fmt.Errorf("打开文件夹 %s 失败: %w", *folder, err)
+	}
+	// 换了文件夹或UIDVALIDITY变化（文件夹被重建）时UID不再可比，从头同步
+	if state.Folder != *folder || state.UIDValidity != mailbox.UidValidity {
+		state.Folder, state.UIDValidity, state.LastUID = *folder, mailbox.UidValidity, 0
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = new(imap.SeqSet)
+	criteria.Uid.AddRange(state.LastUID+1, 0)
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return fmt.Errorf("搜索邮件失败: %w", err)
+	}
+	// "n:*" 在没有新邮件时也会返回最后一封
+	var newUIDs []uint32
+	for _, uid := range uids {
+		if uid > state.LastUID {
+			newUIDs = append(newUIDs, uid)
+		}
+	}
+	fmt.Printf("📬 %s 中有 %d 封新邮件\n", *folder, len(newUIDs))
+	if len(newUIDs) == 0 {
+		return saveIMAPState(*statePath, state)
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(newUIDs...)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqSet, []imap.FetchItem{section.FetchItem(), imap.FetchUid}, messages)
+	}()
+
+	var documents []Document
+	lastUID := state.LastUID
+	for message := range messages {
+		body := message.GetBody(section)
+		if body == nil {
+			continue
+		}
+		docs, err := parseEmail(body, *folder, *attachments)
+		if err != nil {
+			fmt.Printf("⚠️  跳过邮件 UID %d: %v\n", message.Uid, err)
+		} else {
+			documents = append(documents, docs...)
+		}
+		lastUID = max(lastUID, message.Uid)
+	}
+	if err := <-done; err != nil {
+		return fmt.Errorf("拉取邮件失败: %w", err)
+	}
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	if err := rag.ReplaceDocuments(documents); err != nil {
+		return err
+	}
+
+	for _, doc := range documents {
+		state.Documents = append(state.Documents, doc.ID)
+	}
+	state.LastUID = lastUID
+	if err := saveIMAPState(*statePath, state); err != nil {
+		return fmt.Errorf("保存同步状态失败: %w", err)
+	}
+	fmt.Printf("✅ 同步了 %d 封邮件，共 %d 个文档\n", len(newUIDs), len(documents))
+	return nil
+}
+
+// 解析一封邮件：正文为一个文档，每个可解析的附件各为一个文档，共享会话元数据
+func parseEmail(r io.Reader, folder string, withAttachments bool) ([]Document, error) {
+	reader, err := mail.CreateReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("解析邮件失败: %w", err)
+	}
+	header := reader.Header
+
+	subject, _ := header.Subject()
+	messageID, _ := header.MessageID()
+	date, _ := header.Date()
+	var from string
+	if addresses, err := header.AddressList("From"); err == nil && len(addresses) > 0 {
+		from = addresses[0].String()
+	}
+	if messageID == "" {
+		// 没有Message-ID时用关键头部生成稳定ID
+		messageID = from + "|" + subject + "|" + date.Format(time.RFC3339)
+	}
+
+	// 会话ID取References中的第一封（会话起始邮件），没有时取回复的邮件，都没有则为自身
+	threadID := messageID
+	if references, _ := header.MsgIDList("References"); len(references) > 0 {
+		threadID = references[0]
+	} else if replyTo, _ := header.MsgIDList("In-Reply-To"); len(replyTo) > 0 {
+		threadID = replyTo[0]
+	}
+
+	meta := func() map[string]interface{} {
+		return map[string]interface{}{
+			"source":     "邮件: " + subject,
+			"from":       from,
+			"date":       date.Format(time.RFC3339),
+			"subject":    subject,
+			"message_id": messageID,
+			"thread_id":  threadID,
+			"folder":     folder,
+		}
+	}
+	docID := emailDocumentID(messageID)
+
+	var plain, html string
+	var documents []Document
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析邮件正文失败: %w", err)
+		}
+		data, err := io.ReadAll(part.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		switch h := part.Header.(type) {
+		case *mail.InlineHeader:
+			contentType, _, _ := h.ContentType()
+			switch {
+			case contentType == "text/plain" && plain == "":
+				plain = string(data)
+			case contentType == "text/html" && html == "":
+				html = string(data)
+			}
+		case *mail.AttachmentHeader:
+			if !withAttachments {
+				continue
+			}
+			filename, _ := h.Filename()
+			doc, err := loadDocument(filename, data)
+			if err != nil {
+				// PDF、图片等暂不支持的附件跳过
+				continue
+			}
+			doc.ID = docID + "_" + emailDocumentID(filename)[5:]
+			doc.Meta = meta()
+			doc.Meta["attachment"] = filename
+			doc.Title = subject + " - " + doc.Title
+			documents = append(documents, doc)
+		}
+	}
+
+	content := plain
+	if content == "" && html != "" {
+		_, content = extractHTMLText(html)
+	}
+	content = strings.TrimSpace(content)
+	if content != "" {
+		body := Document{ID: docID, Title: subject, Content: content, Meta: meta()}
+		documents = append([]Document{body}, documents...)
+	}
+	return documents, nil
+}
+
+// 根据Message-ID生成稳定的文档ID
+func emailDocumentID(messageID string) string {
+	sum := sha1.Sum([]byte(messageID))
+	return "mail_" + hex.EncodeToString(sum[:8])
+}
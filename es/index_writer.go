@@ -0,0 +1,61 @@
+package main
+
+import "sync"
+
+// 批次写入：最多workers个批次同时写入存储，workers<=1时在调用方同步写入。
+// 任一批次失败后不再提交新批次，Wait返回第一个错误
+type indexWriter struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	err   error
+}
+
+func newIndexWriter(workers int) *indexWriter {
+	if workers <= 1 {
+		return &indexWriter{}
+	}
+	return &indexWriter{slots: make(chan struct{}, workers)}
+}
+
+// 并发写入时提交的批次由写入协程持有，调用方需要为下一批换新的缓冲
+func (w *indexWriter) concurrent() bool {
+	return w.slots != nil
+}
+
+// 提交一个批次，写入协程都在忙时等待；返回之前批次的错误
+func (w *indexWriter) Go(write func() error) error {
+	if !w.concurrent() {
+		return write()
+	}
+	w.slots <- struct{}{}
+	if err := w.failed(); err != nil {
+		<-w.slots
+		return err
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() { <-w.slots }()
+		if err := write(); err != nil {
+			w.mu.Lock()
+			if w.err == nil {
+				w.err = err
+			}
+			w.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (w *indexWriter) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// 等待已提交的批次全部写完
+func (w *indexWriter) Wait() error {
+	w.wg.Wait()
+	return w.failed()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 一种批次大小和写入并发组合的吞吐
+type ingestBenchResult struct {
+	Batch   int
+	Workers int
+	Docs    int
+	Chunks  int // 每个分块写入一个向量
+	Elapsed time.Duration
+	Err     error
+}
+
+func (r ingestBenchResult) docsPerSecond() float64 {
+	return float64(r.Docs) / r.Elapsed.Seconds()
+}
+
+func (r ingestBenchResult) vectorsPerSecond() float64 {
+	return float64(r.Chunks) / r.Elapsed.Seconds()
+}
+
+// 入库吞吐基准：在临时集合/索引上按各批次大小和并发组合写入同一批合成文档，
+// 统计分块编码和批量写入的文档数/秒、向量数/秒，用于确定INDEX_BATCH_SIZE和INDEX_WORKERS。
+// 不经过分类、原文存储和缓存，每个组合前重建临时集合/索引，结束后删除
+func runIngestBench(args []string) error {
+	fs := flag.NewFlagSet("ingestbench", flag.ExitOnError)
+	docs := fs.Int("docs", 2000, "每个组合写入的合成文档数")
+	docChars := fs.Int("doc-chars", 1500, "每篇合成文档的字符数，按CHUNK_SIZE分块")
+	batches := fs.String("batches", "100,250,500,1000,2000", "比较的批次大小（分块数），逗号分隔")
+	workers := fs.String("workers", "1,2,4,8", "比较的写入并发数，逗号分隔")
+	rounds := fs.Int("rounds", 1, "每个组合重复的次数，取最快的一次")
+	_ = fs.Parse(args)
+
+	if *docs <= 0 || *docChars <= 0 || *rounds <= 0 {
+		return fmt.Errorf("-docs、-doc-chars、-rounds必须大于0")
+	}
+	batchSizes, err := parsePositiveInts(*batches)
+	if err != nil {
+		return fmt.Errorf("-batches: %w", err)
+	}
+	workerCounts, err := parsePositiveInts(*workers)
+	if err != nil {
+		return fmt.Errorf("-workers: %w", err)
+	}
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	ctx := context.Background()
+	target := rag.benchTargetName()
+	fmt.Printf("🏋️ 入库吞吐基准: %s，%d 篇文档 × %d 字符，%d 种组合，临时目标 %s\n",
+		benchBackend, *docs, *docChars, len(batchSizes)*len(workerCounts), target)
+	defer func() {
+		if err := rag.dropBenchTarget(ctx, target); err != nil {
+			fmt.Printf("⚠️  删除临时目标 %s 失败: %v\n", target, err)
+		}
+	}()
+
+	var results []ingestBenchResult
+	for _, batch := range batchSizes {
+		for _, count := range workerCounts {
+			var best ingestBenchResult
+			for round := 0; round < *rounds; round++ {
+				// 写入过程会补充元数据，每轮重新生成文档
+				result := rag.benchIngest(ctx, target, benchDocuments(*docs, *docChars), batch, count)
+				if result.Err != nil || best.Elapsed == 0 || result.Elapsed < best.Elapsed {
+					best = result
+				}
+				if result.Err != nil {
+					break
+				}
+			}
+			if best.Err != nil {
+				fmt.Printf("  - batch=%d workers=%d: ❌ %v\n", batch, count, best.Err)
+			} else {
+				fmt.Printf("  - batch=%d workers=%d: %.1f 文档/秒，%.1f 向量/秒（%v）\n", batch, count, best.docsPerSecond(), best.vectorsPerSecond(), best.Elapsed.Round(time.Millisecond))
+			}
+			results = append(results, best)
+		}
+	}
+	printIngestBenchReport(results)
+	return nil
+}
+
+// 重建临时目标后写入一轮，只计写入分块的耗时
+func (r *RAGSystem) benchIngest(ctx context.Context, target string, documents []Document, batch, workers int) ingestBenchResult {
+	result := ingestBenchResult{Batch: batch, Workers: workers, Docs: len(documents)}
+	if result.Err = r.createBenchTarget(ctx, target); result.Err != nil {
+		return result
+	}
+	start := time.Now()
+	chunks, err := r.writeChunks(ctx, target, documents, batch, workers, newStageProfiler(false))
+	result.Elapsed, result.Chunks, result.Err = time.Since(start), len(chunks), err
+	return result
+}
+
+// 合成文档：按段落循环拼接固定的中文语料，每篇错开起始段落
+func benchDocuments(count, chars int) []Document {
+	paragraphs := []string{
+		"闫同学是一名技术博主，擅长Go语言和分布式系统，日常在公众号分享后端开发的经验。",
+		"向量数据库把文本的语义表示为高维向量，检索时按向量的距离找出与问题最接近的内容。",
+		"批量写入可以摊薄每次请求的网络往返和索引开销，但单批过大会占用更多内存并拉长单次请求的耗时。",
+		"检索增强生成先从知识库中找出相关的分块，再把它们和问题一起交给大模型生成回答。",
+		"羽毛球是一项讲究步法和反应的运动，业余爱好者每周打两到三次就能明显提高体能。",
+	}
+	documents := make([]Document, count)
+	for i := range documents {
+		var content strings.Builder
+		for j, runes := i, 0; runes < chars; j++ {
+			content.WriteString(paragraphs[j%len(paragraphs)])
+			runes += utf8.RuneCountInString(paragraphs[j%len(paragraphs)])
+		}
+		documents[i] = Document{
+			ID:      fmt.Sprintf("bench_%06d", i),
+			Title:   fmt.Sprintf("入库基准文档 %d", i),
+			Content: content.String(),
+			Meta:    map[string]interface{}{"source": "ingestbench", "category": "基准"},
+		}
+	}
+	return documents
+}
+
+// 按向量数/秒排序输出，推荐最快且没有出错的组合
+func printIngestBenchReport(results []ingestBenchResult) {
+	var ok []ingestBenchResult
+	for _, result := range results {
+		if result.Err == nil {
+			ok = append(ok, result)
+		}
+	}
+	if len(ok) == 0 {
+		fmt.Println("❌ 所有组合都写入失败")
+		return
+	}
+	sort.SliceStable(ok, func(i, j int) bool { return ok[i].vectorsPerSecond() > ok[j].vectorsPerSecond() })
+	fmt.Println("📊 吞吐排名（向量数/秒）:")
+	fmt.Printf("  %-8s %-8s %12s %12s %10s\n", "batch", "workers", "文档/秒", "向量/秒", "耗时")
+	for _, result := range ok {
+		fmt.Printf("  %-8d %-8d %12.1f %12.1f %10v\n", result.Batch, result.Workers, result.docsPerSecond(), result.vectorsPerSecond(), result.Elapsed.Round(time.Millisecond))
+	}
+	best := ok[0]
+	fmt.Printf("🏆 建议配置: INDEX_BATCH_SIZE=%d INDEX_WORKERS=%d\n", best.Batch, best.Workers)
+}
+
+// 解析逗号分隔的正整数列表
+func parsePositiveInts(value string) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%q 不是正整数", item)
+		}
+		values = append(values, n)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("至少需要一个取值")
+	}
+	return values, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const benchBackend = "ElasticSearch"
+
+func (r *RAGSystem) benchTargetName() string {
+	return r.config.IndexName + "_ingestbench"
+}
+
+// 按当前mapping重建临时索引
+func (r *RAGSystem) createBenchTarget(ctx context.Context, name string) error {
+	if err := r.dropBenchTarget(ctx, name); err != nil {
+		return err
+	}
+	mappingJSON, err := json.Marshal(r.indexMapping())
+	if err != nil {
+		return fmt.Errorf("序列化mapping失败: %w", err)
+	}
+	res, err := r.elasticClient.Indices.Create(
+		name,
+		r.elasticClient.Indices.Create.WithBody(bytes.NewReader(mappingJSON)),
+		r.elasticClient.Indices.Create.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("创建临时索引失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("创建临时索引错误: %s", res.String())
+	}
+	return nil
+}
+
+func (r *RAGSystem) dropBenchTarget(ctx context.Context, name string) error {
+	res, err := r.elasticClient.Indices.Delete(
+		[]string{name},
+		r.elasticClient.Indices.Delete.WithIgnoreUnavailable(true),
+		r.elasticClient.Indices.Delete.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("删除临时索引失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("删除临时索引错误: %s", res.String())
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka文档事件类型
+const (
+	documentEventCreate = "create"
+	documentEventUpdate = "update"
+	documentEventDelete = "delete"
+)
+
+// Kafka中的文档事件，delete只需要id
+type documentEvent struct {
+	Op      string                 `json:"op"` // create、update、delete
+	ID      string                 `json:"id"`
+	Title   string                 `json:"title,omitempty"`
+	Content string                 `json:"content,omitempty"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+}
+
+// 无法通过重试解决的事件（格式错误等），直接写入死信队列
+var errInvalidEvent = errors.New("无效的文档事件")
+
+// 解析并校验事件
+func parseDocumentEvent(value []byte) (*documentEvent, error) {
+	var event documentEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidEvent, err)
+	}
+	if event.ID == "" {
+		return nil, fmt.Errorf("%w: id不能为空", errInvalidEvent)
+	}
+	switch event.Op {
+	case documentEventCreate, documentEventUpdate:
+		if event.Content == "" {
+			return nil, fmt.Errorf("%w: %s事件的content不能为空", errInvalidEvent, event.Op)
+		}
+	case documentEventDelete:
+	default:
+		return nil, fmt.Errorf("%w: 未知的op: %s", errInvalidEvent, event.Op)
+	}
+	return &event, nil
+}
+
+// 把事件应用到索引
+func (r *RAGSystem) applyDocumentEvent(event *documentEvent) error {
+	if event.Op == documentEventDelete {
+		return r.DeleteDocuments([]string{event.ID})
+	}
+	return r.ReplaceDocuments([]Document{{ID: event.ID, Title: event.Title, Content: event.Content, Meta: event.Meta}})
+}
+
+// kafka命令：消费文档事件写入知识库，失败按指数退避重试，重试耗尽或格式错误的事件写入死信队列
+func runKafka(args []string) error {
+	fs := flag.NewFlagSet("kafka", flag.ExitOnError)
+	brokers := fs.String("brokers", getEnv("KAFKA_BROKERS", "localhost:9092"), "Kafka地址，逗号分隔")
+	topic := fs.String("topic", getEnv("KAFKA_TOPIC", "rag-documents"), "文档事件topic")
+	group := fs.String("group", getEnv("KAFKA_GROUP", "rag-demo"), "消费组")
+	dlqTopic := fs.String("dlq", getEnv("KAFKA_DLQ_TOPIC", "rag-documents-dlq"), "死信队列topic，为空时只记录日志")
+	retries := fs.Int("retries", getEnvAsInt("KAFKA_MAX_RETRIES", 3), "单个事件的最大重试次数")
+	_ = fs.Parse(args)
+
+	brokerList := splitEnvList(*brokers)
+	if len(brokerList) == 0 {
+		return fmt.Errorf("请通过 -brokers 或 KAFKA_BROKERS 指定Kafka地址")
+	}
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: brokerList, Topic: *topic, GroupID: *group})
+	defer reader.Close()
+	var dlq *kafka.Writer
+	if *dlqTopic != "" {
+		dlq = &kafka.Writer{Addr: kafka.TCP(brokerList...), Topic: *dlqTopic, AllowAutoTopicCreation: true}
+		defer dlq.Close()
+	}
+
+	// 收到退出信号后处理完当前事件再退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("📨 开始消费 %s（消费组 %s）...\n", *topic, *group)
+	return rag.consumeEvents(ctx, reader, dlq, *retries, parseDocumentEvent)
+}
+
+// 消费循环：逐条处理并提交位点，处理失败的消息写入死信队列后继续
+func (r *RAGSystem) consumeEvents(ctx context.Context, reader *kafka.Reader, dlq *kafka.Writer, retries int, parse func([]byte) (*documentEvent, error)) error {
+	for {
+		message, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Println("👋 已停止消费")
+				return nil
+			}
+			return fmt.Errorf("读取消息失败: %w", err)
+		}
+
+		attempts, err := r.consumeDocumentEvent(ctx, message, retries, parse)
+		if err != nil {
+			if ctx.Err() != nil {
+				// 未提交位点，重启后重新消费
+				fmt.Println("👋 已停止消费")
+				return nil
+			}
+			fmt.Printf("☠️  事件处理失败（offset %d，尝试 %d 次）: %v\n", message.Offset, attempts, err)
+			if err := writeDeadLetter(ctx, dlq, message, err, attempts); err != nil {
+				return err
+			}
+		}
+		if err := reader.CommitMessages(ctx, message); err != nil {
+			return fmt.Errorf("提交位点失败: %w", err)
+		}
+	}
+}
+
+// 处理一条消息，返回尝试次数；格式错误的事件不重试
+func (r *RAGSystem) consumeDocumentEvent(ctx context.Context, message kafka.Message, retries int, parse func([]byte) (*documentEvent, error)) (int, error) {
+	event, err := parse(message.Value)
+	if err != nil {
+		return 1, err
+	}
+	if event == nil {
+		// 无需处理的消息，例如Debezium删除后的墓碑消息
+		return 1, nil
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := r.applyDocumentEvent(event)
+		if err == nil {
+			fmt.Printf("✅ %s %s\n", event.Op, event.ID)
+			return attempt, nil
+		}
+		if attempt > retries {
+			return attempt, err
+		}
+		fmt.Printf("⚠️  %s %s 失败，%v 后重试: %v\n", event.Op, event.ID, backoff, err)
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// 把失败的消息原样写入死信队列，失败原因放在header中
+func writeDeadLetter(ctx context.Context, dlq *kafka.Writer, message kafka.Message, cause error, attempts int) error {
+	if dlq == nil {
+		return nil
+	}
+	headers := append(message.Headers,
+		kafka.Header{Key: "dlq-error", Value: []byte(cause.Error())},
+		kafka.Header{Key: "dlq-attempts", Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: "dlq-source", Value: []byte(fmt.Sprintf("%s/%d/%d", message.Topic, message.Partition, message.Offset))},
+	)
+	err := dlq.WriteMessages(ctx, kafka.Message{Key: message.Key, Value: message.Value, Headers: headers})
+	if err != nil {
+		return fmt.Errorf("写入死信队列失败: %w", err)
+	}
+	return nil
+}
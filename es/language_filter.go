@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"unicode"
+)
+
+// 按语言检索：入库时为每个分块检测语言（按汉字和英文单词的数量），记录在元数据language中；
+// 检索时按问题（跨语言检索时为译文）的语言处理，避免混合语料中一种语言的分块挤掉另一种。
+// off：不按语言过滤；prefer：先检索同语言的分块，不足topK时用其他语言的分块补足；require：只检索同语言的分块。
+// 请求的language_filter优先于LANGUAGE_FILTER；问题语言无法判断时不过滤，分页检索的prefer按off处理
+type LanguageFilterConfig struct {
+	Mode string
+}
+
+func loadLanguageFilterConfig() LanguageFilterConfig {
+	return LanguageFilterConfig{Mode: getEnv("LANGUAGE_FILTER", languageFilterOff)}
+}
+
+const (
+	languageFilterOff     = "off"
+	languageFilterPrefer  = "prefer"
+	languageFilterRequire = "require"
+)
+
+const languageKey = "language"
+
+func validLanguageFilter(mode string) error {
+	switch mode {
+	case "", languageFilterOff, languageFilterPrefer, languageFilterRequire:
+		return nil
+	}
+	return fmt.Errorf("未知的language_filter: %s，可选 off、prefer、require", mode)
+}
+
+// 分块语言：汉字数不少于英文单词数时为中文，否则有英文单词时为英文，都没有时无法判断
+func chunkLanguage(text string) string {
+	han, words, inWord := 0, 0, false
+	for _, r := range text {
+		isLatin := unicode.In(r, unicode.Latin)
+		if isLatin && !inWord {
+			words++
+		}
+		inWord = isLatin
+		if unicode.Is(unicode.Han, r) {
+			han++
+		}
+	}
+	switch {
+	case han > 0 && han >= words:
+		return "zh"
+	case words > 0:
+		return "en"
+	}
+	return ""
+}
+
+// 分块元数据加上分块语言
+func withLanguage(meta map[string]interface{}, content string) map[string]interface{} {
+	language := chunkLanguage(content)
+	if language == "" {
+		return meta
+	}
+	enriched := make(map[string]interface{}, len(meta)+1)
+	for key, value := range meta {
+		enriched[key] = value
+	}
+	enriched[languageKey] = language
+	return enriched
+}
+
+// 按语言过滤方式检索配置的集合或联合索引
+func (r *RAGSystem) searchScope(ctx context.Context, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	mode := opts.LanguageFilter
+	if mode == "" {
+		mode = r.config.LanguageFilter.Mode
+	}
+	language := detectLanguage(query)
+	if language == "" || mode == languageFilterOff || (mode == languageFilterPrefer && opts.Page != nil) {
+		return r.searchTargets(ctx, query, topK, opts)
+	}
+
+	filtered := opts
+	filtered.Language = language
+	results, err := r.searchTargets(ctx, query, topK, filtered)
+	if err != nil || mode == languageFilterRequire || len(results) >= topK {
+		return results, err
+	}
+	// 同语言的分块不足topK，其他语言的分块排在后面补足
+	others, err := r.searchTargets(ctx, query, topK, opts)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(results))
+	for _, result := range results {
+		seen[result.ID] = true
+	}
+	for _, result := range others {
+		if len(results) >= topK {
+			break
+		}
+		if !seen[result.ID] {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 屏蔽词和替换词表：LEXICON_FILE（JSON）按部署配置，作用于查询日志记录的问题和返回给用户的回答（/ask、/ask/stream、Telegram），
+// 在回答策略之外满足各地区的用语和品牌安全要求。default为默认词表，namespaces按分类（命名空间）追加屏蔽词、覆盖替换词，
+// 命名空间取请求的分类，未指定时取最相关分块的分类。屏蔽词逐字替换为mask（默认*），替换词整体替换，英文不区分大小写。
+// 答案缓存和人工审核保存原文，返回时才处理，修改词表后serve热更新即时生效
+type lexiconRules struct {
+	Block   []string          `json:"block,omitempty"`
+	Replace map[string]string `json:"replace,omitempty"`
+	Mask    string            `json:"mask,omitempty"`
+}
+
+type lexiconFile struct {
+	Default    lexiconRules            `json:"default"`
+	Namespaces map[string]lexiconRules `json:"namespaces"`
+}
+
+// 编译后的词表，按命名空间合并了默认词表
+type lexicon struct {
+	matchers map[string]*lexiconMatcher // 命名空间 -> 匹配器，默认词表的键为空
+}
+
+type lexiconMatcher struct {
+	pattern      *regexp.Regexp
+	replacements map[string]string // 小写的词 -> 替换文本
+	mask         string
+	blocked      map[string]bool
+	terms        []string // 小写的词，流式输出时判断末尾是否可能是词的开头
+}
+
+// 从JSON文件加载词表，文件不存在或没有任何词时返回nil，不做处理
+func loadLexicon(path string) (*lexicon, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file lexiconFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析词表 %s 失败: %w", path, err)
+	}
+
+	l := &lexicon{matchers: make(map[string]*lexiconMatcher)}
+	if m := newLexiconMatcher(file.Default); m != nil {
+		l.matchers[""] = m
+	}
+	for namespace, rules := range file.Namespaces {
+		if m := newLexiconMatcher(file.Default.merge(rules)); m != nil {
+			l.matchers[namespace] = m
+		}
+	}
+	if len(l.matchers) == 0 {
+		return nil, nil
+	}
+	return l, nil
+}
+
+// 命名空间的词表：屏蔽词追加，替换词和mask覆盖默认词表
+func (r lexiconRules) merge(override lexiconRules) lexiconRules {
+	merged := lexiconRules{Block: append(append([]string(nil), r.Block...), override.Block...), Replace: make(map[string]string), Mask: r.Mask}
+	for term, replacement := range r.Replace {
+		merged.Replace[term] = replacement
+	}
+	for term, replacement := range override.Replace {
+		merged.Replace[term] = replacement
+	}
+	if override.Mask != "" {
+		merged.Mask = override.Mask
+	}
+	return merged
+}
+
+func newLexiconMatcher(rules lexiconRules) *lexiconMatcher {
+	m := &lexiconMatcher{replacements: make(map[string]string), blocked: make(map[string]bool), mask: rules.Mask}
+	if m.mask == "" {
+		m.mask = "*"
+	}
+	for _, term := range rules.Block {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			m.blocked[term] = true
+		}
+	}
+	for term, replacement := range rules.Replace {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" && !m.blocked[term] {
+			m.replacements[term] = replacement
+		}
+	}
+	for term := range m.blocked {
+		m.terms = append(m.terms, term)
+	}
+	for term := range m.replacements {
+		m.terms = append(m.terms, term)
+	}
+	if len(m.terms) == 0 {
+		return nil
+	}
+	// 较长的词优先匹配
+	sort.Slice(m.terms, func(i, j int) bool {
+		if len(m.terms[i]) != len(m.terms[j]) {
+			return len(m.terms[i]) > len(m.terms[j])
+		}
+		return m.terms[i] < m.terms[j]
+	})
+	quoted := make([]string, len(m.terms))
+	for i, term := range m.terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	m.pattern = regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+	return m
+}
+
+func (m *lexiconMatcher) apply(text string) string {
+	return m.pattern.ReplaceAllStringFunc(text, func(match string) string {
+		term := strings.ToLower(match)
+		if m.blocked[term] {
+			return strings.Repeat(m.mask, utf8.RuneCountInString(match))
+		}
+		if replacement, ok := m.replacements[term]; ok {
+			return replacement
+		}
+		return match
+	})
+}
+
+// 文本末尾可能是某个词开头的部分的字节数，流式输出时先不发送，避免词的前半部分在补全前露出
+func (m *lexiconMatcher) pending(text string) int {
+	// terms按长度降序，第一个最长
+	for i := max(len(text)-len(m.terms[0]), 0); i < len(text); i++ {
+		if !utf8.RuneStart(text[i]) {
+			continue
+		}
+		tail := strings.ToLower(text[i:])
+		for _, term := range m.terms {
+			if len(term) > len(tail) && strings.HasPrefix(term, tail) {
+				return len(text) - i
+			}
+		}
+	}
+	return 0
+}
+
+func (l *lexicon) matcher(namespace string) *lexiconMatcher {
+	if l == nil {
+		return nil
+	}
+	if m, ok := l.matchers[namespace]; ok {
+		return m
+	}
+	return l.matchers[""]
+}
+
+// 按命名空间的词表处理文本，没有配置词表时原样返回
+func (l *lexicon) apply(namespace, text string) string {
+	if m := l.matcher(namespace); m != nil {
+		return m.apply(text)
+	}
+	return text
+}
+
+// 按词表处理流式回答，命名空间在检索后确定
+type lexiconSink struct {
+	sink      replySink
+	lexicon   *lexicon
+	namespace string
+}
+
+func (s *lexiconSink) Update(text string) error {
+	if m := s.lexicon.matcher(s.namespace); m != nil {
+		text = m.apply(text[:len(text)-m.pending(text)])
+	}
+	return s.sink.Update(text)
+}
+
+func (s *lexiconSink) Finish(text string) error {
+	return s.sink.Finish(s.lexicon.apply(s.namespace, text))
+}
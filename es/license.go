@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// 内容许可配置：SOURCE_LICENSE=wikipedia=CC-BY-SA-4.0,内部文档=proprietary，键与SOURCE_TRUST相同；
+// 文档元数据中的license、attribution字段优先于配置
+type LicenseConfig struct {
+	Licenses           map[string]string
+	NonRedistributable map[string]bool // 不可再分发的许可
+	ExcludeRestricted  bool            // 生成时完全排除不可再分发的来源
+}
+
+func loadLicenseConfig() LicenseConfig {
+	config := LicenseConfig{
+		Licenses:           make(map[string]string),
+		NonRedistributable: make(map[string]bool),
+		ExcludeRestricted:  getEnvAsBool("LICENSE_EXCLUDE_RESTRICTED", false),
+	}
+	for _, item := range splitEnvList("SOURCE_LICENSE") {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		config.Licenses[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	restricted := splitEnvList("LICENSE_NON_REDISTRIBUTABLE")
+	if len(restricted) == 0 {
+		restricted = []string{"proprietary", "all-rights-reserved", "internal"}
+	}
+	for _, license := range restricted {
+		config.NonRedistributable[strings.ToLower(license)] = true
+	}
+	return config
+}
+
+// 分块的许可：元数据中的license优先，其次按source、category、来源域名查配置
+func (c LicenseConfig) license(meta map[string]interface{}) string {
+	if license, ok := meta["license"].(string); ok && license != "" {
+		return license
+	}
+	for _, key := range []string{"source", "category"} {
+		if value, ok := meta[key].(string); ok {
+			if license, ok := c.Licenses[value]; ok {
+				return license
+			}
+		}
+	}
+	if rawURL, ok := meta["source_url"].(string); ok {
+		if u, err := url.Parse(rawURL); err == nil {
+			if license, ok := c.Licenses[u.Hostname()]; ok {
+				return license
+			}
+		}
+	}
+	return ""
+}
+
+func (c LicenseConfig) redistributable(license string) bool {
+	return !c.NonRedistributable[strings.ToLower(license)]
+}
+
+// 标注检索结果的许可；开启排除时去掉不可再分发的来源
+func applyLicense(results []SearchResult, config LicenseConfig) []SearchResult {
+	kept := results[:0]
+	excluded := 0
+	for _, result := range results {
+		result.License = config.license(result.Meta)
+		if config.ExcludeRestricted && !config.redistributable(result.License) {
+			excluded++
+			continue
+		}
+		kept = append(kept, result)
+	}
+	if excluded > 0 {
+		fmt.Printf("🔒 已排除 %d 个不可再分发来源的分块\n", excluded)
+	}
+	return kept
+}
+
+// 许可是否要求署名：CC BY系列，或文档元数据中给出了署名
+func requiresAttribution(result SearchResult) bool {
+	if attribution, ok := result.Meta["attribution"].(string); ok && attribution != "" {
+		return true
+	}
+	license := strings.ToUpper(strings.ReplaceAll(result.License, " ", "-"))
+	return strings.HasPrefix(license, "CC-BY")
+}
+
+// 署名说明：元数据中的attribution优先，否则由标题、来源和许可组成
+func attributionLine(result SearchResult) string {
+	if attribution, ok := result.Meta["attribution"].(string); ok && attribution != "" {
+		return attribution
+	}
+	line := "《" + result.Title + "》"
+	if source, ok := result.Meta["source_url"].(string); ok && source != "" {
+		line += "，" + source
+	} else if source, ok := result.Meta["source"].(string); ok && source != "" {
+		line += "，来源: " + source
+	}
+	return line + "，许可: " + result.License
+}
+
+// 回答末尾署名部分的开头
+const attributionHeader = "\n\n📜 署名:\n"
+
+// 在回答末尾附上所引用来源要求的署名，同一文档只署名一次
+func appendAttribution(answer string, results []SearchResult) string {
+	if answer == "" {
+		return answer
+	}
+	seen := make(map[string]bool)
+	var lines []string
+	for _, result := range results {
+		if !requiresAttribution(result) || seen[result.DocID] {
+			continue
+		}
+		seen[result.DocID] = true
+		lines = append(lines, "- "+attributionLine(result))
+	}
+	if len(lines) == 0 {
+		return answer
+	}
+	return answer + attributionHeader + strings.Join(lines, "\n")
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// 嗅探出的文件类型
+const (
+	contentTypeText   = "text"
+	contentTypeHTML   = "html"
+	contentTypePDF    = "pdf"
+	contentTypeZip    = "zip"
+	contentTypeGzip   = "gzip"
+	contentTypeBinary = "binary"
+)
+
+// 从目录加载文档，按实际内容而非扩展名判断文件类型，并统一转换为UTF-8；
+// 目录中的zip、tar.gz压缩包会解压后加载，dir也可以直接是一个压缩包
+func loadDocumentsFromDir(dir string) ([]Document, error) {
+	limits := loadArchiveLimits()
+	var documents []Document
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取文件 %s 失败: %w", path, err)
+		}
+
+		if isArchive(sniffContentType(data)) {
+			loaded, err := loadArchive(path, path, data, 0, limits)
+			if err != nil {
+				fmt.Printf("⚠️  跳过压缩包 %s: %v\n", path, err)
+				return nil
+			}
+			documents = append(documents, loaded...)
+			return nil
+		}
+
+		doc, err := loadDocument(path, data)
+		if err != nil {
+			fmt.Printf("⚠️  跳过文件 %s: %v\n", path, err)
+			return nil
+		}
+		documents = append(documents, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// 将单个文件内容解析为文档
+func loadDocument(path string, data []byte) (Document, error) {
+	contentType := sniffContentType(data)
+	if contentType != contentTypeText && contentType != contentTypeHTML {
+		return Document{}, fmt.Errorf("不支持的文件类型: %s", contentType)
+	}
+
+	text, encodingName, err := decodeText(data)
+	if err != nil {
+		return Document{}, err
+	}
+
+	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	content := text
+	if contentType == contentTypeHTML {
+		htmlTitle, htmlText := extractHTMLText(text)
+		if htmlTitle != "" {
+			title = htmlTitle
+		}
+		content = htmlText
+	}
+
+	return Document{
+		ID:      documentIDFromPath(path),
+		Title:   title,
+		Content: strings.TrimSpace(content),
+		Meta: map[string]interface{}{
+			"source":       title,
+			"source_path":  path,
+			"encoding":     encodingName,
+			"content_type": contentType,
+		},
+	}, nil
+}
+
+// 根据路径生成稳定的文档ID
+func documentIDFromPath(path string) string {
+	sum := sha1.Sum([]byte(filepath.ToSlash(path)))
+	return "file_" + hex.EncodeToString(sum[:8])
+}
+
+// 根据文件头嗅探实际类型
+func sniffContentType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return contentTypePDF
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return contentTypeZip
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return contentTypeGzip
+	case hasUTF16BOM(data) || looksLikeUTF16(data):
+		// UTF-16文本包含大量0字节，会被误判为二进制
+		return contentTypeText
+	}
+
+	detected := http.DetectContentType(data)
+	switch {
+	case strings.HasPrefix(detected, "text/html"):
+		return contentTypeHTML
+	case strings.HasPrefix(detected, "text/"):
+		return contentTypeText
+	case detected == "application/octet-stream" && !bytes.Contains(data, []byte{0}):
+		// GBK等非UTF-8编码的文本会被识别为octet-stream，不含0字节时按文本处理
+		return contentTypeText
+	}
+	return contentTypeBinary
+}
+
+// 检测编码并转换为UTF-8，返回使用的编码名
+func decodeText(data []byte) (string, string, error) {
+	var decoder *encoding.Decoder
+	var name string
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		return string(data[3:]), "utf-8", nil
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		decoder, name = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder(), "utf-16le"
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		decoder, name = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder(), "utf-16be"
+	case looksLikeUTF16(data):
+		decoder, name = unicode.UTF16(utf16Endianness(data), unicode.IgnoreBOM).NewDecoder(), "utf-16"
+	case utf8.Valid(data):
+		return string(data), "utf-8", nil
+	default:
+		// GB18030兼容GBK和GB2312
+		decoder, name = simplifiedchinese.GB18030.NewDecoder(), "gb18030"
+	}
+
+	decoded, err := decoder.Bytes(data)
+	if err != nil {
+		return "", "", fmt.Errorf("按 %s 解码失败: %w", name, err)
+	}
+	return string(decoded), name, nil
+}
+
+func hasUTF16BOM(data []byte) bool {
+	return bytes.HasPrefix(data, []byte{0xff, 0xfe}) || bytes.HasPrefix(data, []byte{0xfe, 0xff})
+}
+
+// 无BOM的UTF-16：ASCII字符的高位字节为0，偶数或奇数位上0字节占比很高
+func looksLikeUTF16(data []byte) bool {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return false
+	}
+	even, odd := countZeroBytes(data)
+	half := len(data) / 2
+	return even > half*3/10 || odd > half*3/10
+}
+
+func utf16Endianness(data []byte) unicode.Endianness {
+	even, odd := countZeroBytes(data)
+	if even > odd {
+		return unicode.BigEndian
+	}
+	return unicode.LittleEndian
+}
+
+func countZeroBytes(data []byte) (even, odd int) {
+	for i, b := range data {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	return even, odd
+}
+
+// 提取HTML的标题和正文文本，忽略脚本和样式
+func extractHTMLText(content string) (string, string) {
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	var title string
+	var builder strings.Builder
+	skip := 0
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(title), strings.TrimSpace(builder.String())
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style", "noscript":
+				skip++
+			case "title":
+				inTitle = true
+			case "p", "div", "br", "li", "h1", "h2", "h3", "h4", "h5", "h6", "tr":
+				builder.WriteByte('\n')
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style", "noscript":
+				if skip > 0 {
+					skip--
+				}
+			case "title":
+				inTitle = false
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := strings.TrimSpace(string(tokenizer.Text()))
+			if text == "" {
+				continue
+			}
+			if inTitle {
+				title += text
+				continue
+			}
+			builder.WriteString(text)
+			builder.WriteByte(' ')
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 压测中一次请求的结果
+type loadtestSample struct {
+	Latency  time.Duration
+	Err      string
+	Cached   bool
+	Degraded bool
+}
+
+// 发送一次提问，本地流水线和远程服务各有一种实现
+type loadtestTarget interface {
+	Ask(ctx context.Context, question string) loadtestSample
+	// 压测期间的token用量，无法获取时返回false
+	Usage(ctx context.Context) (tokenUsage, bool)
+}
+
+// 直接调用本地流水线，与serve的/ask走同样的缓存、合并和降级逻辑
+type localTarget struct {
+	rag   *RAGSystem
+	fresh bool
+}
+
+func (t *localTarget) Ask(ctx context.Context, question string) loadtestSample {
+	opts := searchOptions{Degraded: &degradation{}}
+	start := time.Now()
+	_, _, _, cached, err := t.rag.AnswerQuestion(ctx, question, t.fresh, opts)
+	sample := loadtestSample{Latency: time.Since(start), Cached: cached, Degraded: len(opts.Degraded.Tiers()) > 0}
+	if err != nil {
+		sample.Err = err.Error()
+	}
+	return sample
+}
+
+func (t *localTarget) Usage(ctx context.Context) (tokenUsage, bool) {
+	return t.rag.usage.Snapshot(), true
+}
+
+// 请求远程服务的/ask，token用量取自/admin/stats压测前后的差值（包含同期其他请求的用量）
+type remoteTarget struct {
+	baseURL string
+	fresh   bool
+	client  *http.Client
+}
+
+func (t *remoteTarget) Ask(ctx context.Context, question string) loadtestSample {
+	body, _ := json.Marshal(askRequest{Question: question, Fresh: t.fresh})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/ask", bytes.NewReader(body))
+	if err != nil {
+		return loadtestSample{Err: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return loadtestSample{Latency: time.Since(start), Err: err.Error()}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	sample := loadtestSample{Latency: time.Since(start)}
+	switch {
+	case err != nil:
+		sample.Err = err.Error()
+	case resp.StatusCode != http.StatusOK:
+		// 按状态码归类，不同请求的错误详情不同
+		sample.Err = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	default:
+		var answer askResponse
+		if err := json.Unmarshal(data, &answer); err != nil {
+			sample.Err = fmt.Sprintf("解析响应失败: %v", err)
+		}
+		sample.Cached, sample.Degraded = answer.Cached, len(answer.Degraded) > 0
+	}
+	return sample
+}
+
+func (t *remoteTarget) Usage(ctx context.Context) (tokenUsage, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.baseURL+"/admin/stats", nil)
+	if err != nil {
+		return tokenUsage{}, false
+	}
+	// 管理接口需要令牌，使用与被测服务相同的ADMIN_TOKEN
+	if token := getEnv("ADMIN_TOKEN", ""); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return tokenUsage{}, false
+	}
+	defer resp.Body.Close()
+	var stats statsResponse
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&stats) != nil {
+		return tokenUsage{}, false
+	}
+	return stats.Usage, true
+}
+
+// 读取压测问题：JSONL每行 {"question": "..."}，不是JSON的行整行作为问题
+func loadLoadtestQuestions(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取问题文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var questions []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "{") {
+			var item struct {
+				Question string `json:"question"`
+			}
+			if err := json.Unmarshal([]byte(text), &item); err != nil || item.Question == "" {
+				return nil, fmt.Errorf("问题文件第%d行格式错误，应为 {\"question\": \"...\"}", line)
+			}
+			text = item.Question
+		}
+		questions = append(questions, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取问题文件失败: %w", err)
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("问题文件 %s 为空", path)
+	}
+	return questions, nil
+}
+
+// 按固定速率发起请求，不等待前一个请求完成（开环），更接近真实流量；
+// 进行中的请求达到上限时丢弃本次请求，说明目标已经跟不上该速率
+func runLoad(ctx context.Context, target loadtestTarget, questions []string, qps float64, duration time.Duration, maxInflight int) ([]loadtestSample, int) {
+	var (
+		mu      sync.Mutex
+		samples []loadtestSample
+		wg      sync.WaitGroup
+		dropped int
+	)
+	inflight := make(chan struct{}, maxInflight)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / qps))
+	defer ticker.Stop()
+	deadline := time.After(duration)
+	progress := time.NewTicker(10 * time.Second)
+	defer progress.Stop()
+
+	for sent := 0; ; {
+		select {
+		case <-deadline:
+			wg.Wait()
+			return samples, dropped
+		case <-progress.C:
+			mu.Lock()
+			fmt.Printf("⏱️  已发送 %d，已完成 %d，丢弃 %d\n", sent, len(samples), dropped)
+			mu.Unlock()
+		case <-ticker.C:
+			select {
+			case inflight <- struct{}{}:
+			default:
+				mu.Lock()
+				dropped++
+				mu.Unlock()
+				continue
+			}
+			question := questions[sent%len(questions)]
+			sent++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-inflight }()
+				sample := target.Ask(ctx, question)
+				mu.Lock()
+				samples = append(samples, sample)
+				mu.Unlock()
+			}()
+		}
+	}
+}
+
+// 输出压测报告
+func printLoadtestReport(samples []loadtestSample, dropped int, elapsed time.Duration) {
+	var latencies []time.Duration
+	errors := make(map[string]int)
+	cached, degraded := 0, 0
+	for _, sample := range samples {
+		if sample.Err != "" {
+			errors[sample.Err]++
+			continue
+		}
+		latencies = append(latencies, sample.Latency)
+		if sample.Cached {
+			cached++
+		}
+		if sample.Degraded {
+			degraded++
+		}
+	}
+
+	fmt.Println("\n📊 压测结果:")
+	fmt.Printf("  - 请求数: %d（成功 %d，失败 %d，丢弃 %d）\n", len(samples), len(latencies), len(samples)-len(latencies), dropped)
+	fmt.Printf("  - 实际QPS: %.2f\n", float64(len(samples))/elapsed.Seconds())
+	if len(samples) > 0 {
+		fmt.Printf("  - 错误率: %.2f%%\n", float64(len(samples)-len(latencies))/float64(len(samples))*100)
+	}
+	if len(latencies) > 0 {
+		fmt.Printf("  - 延迟: p50 %v，p90 %v，p95 %v，p99 %v，最大 %v\n",
+			latencyPercentile(latencies, 50).Round(time.Millisecond), latencyPercentile(latencies, 90).Round(time.Millisecond),
+			latencyPercentile(latencies, 95).Round(time.Millisecond), latencyPercentile(latencies, 99).Round(time.Millisecond),
+			latencyPercentile(latencies, 100).Round(time.Millisecond))
+		fmt.Printf("  - 缓存命中: %d（%.1f%%），降级回答: %d\n", cached, float64(cached)/float64(len(latencies))*100, degraded)
+	}
+	if len(errors) > 0 {
+		messages := make([]string, 0, len(errors))
+		for message := range errors {
+			messages = append(messages, message)
+		}
+		sort.Slice(messages, func(i, j int) bool { return errors[messages[i]] > errors[messages[j]] })
+		fmt.Println("  - 错误分布:")
+		for _, message := range messages {
+			fmt.Printf("    %5d  %s\n", errors[message], message)
+		}
+	}
+}
+
+// loadtest命令：按固定QPS对本地流水线或远程服务（-url）压测，输出延迟分位数、错误率和token成本
+func runLoadtest(args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	qps := fs.Float64("qps", 20, "每秒发起的请求数")
+	duration := fs.Duration("duration", 2*time.Minute, "压测时长")
+	questionsPath := fs.String("questions", "", "问题文件，JSONL每行 {\"question\": \"...\"}，按顺序循环使用")
+	url := fs.String("url", "", "远程服务地址，例如 http://localhost:8080；不填时直接调用本地流水线")
+	fresh := fs.Bool("fresh", false, "跳过答案缓存，衡量完整检索和生成的容量")
+	maxInflight := fs.Int("max-inflight", 200, "进行中的请求上限，超出时丢弃新请求")
+	timeout := fs.Duration("timeout", time.Minute, "单个请求的超时时间")
+	_ = fs.Parse(args)
+
+	if *questionsPath == "" {
+		return fmt.Errorf("请通过 -questions 指定问题文件")
+	}
+	if *qps <= 0 || *duration <= 0 || *maxInflight <= 0 {
+		return fmt.Errorf("-qps、-duration、-max-inflight必须大于0")
+	}
+	questions, err := loadLoadtestQuestions(*questionsPath)
+	if err != nil {
+		return err
+	}
+
+	var target loadtestTarget
+	pricing := loadPricingConfig()
+	if *url != "" {
+		target = &remoteTarget{baseURL: strings.TrimRight(*url, "/"), fresh: *fresh, client: &http.Client{Timeout: *timeout}}
+		fmt.Printf("🎯 压测 %s，%.1f QPS，持续 %v，%d 个问题\n", *url, *qps, *duration, len(questions))
+	} else {
+		rag, err := NewRAGSystem(loadConfig())
+		if err != nil {
+			return err
+		}
+		defer rag.Close()
+		pricing = rag.config.Pricing
+		target = &localTarget{rag: rag, fresh: *fresh}
+		fmt.Printf("🎯 压测本地流水线，%.1f QPS，持续 %v，%d 个问题\n", *qps, *duration, len(questions))
+	}
+
+	ctx := context.Background()
+	before, usageOK := target.Usage(ctx)
+	start := time.Now()
+	samples, dropped := runLoad(ctx, &timeoutTarget{target, *timeout}, questions, *qps, *duration, *maxInflight)
+	elapsed := time.Since(start)
+	printLoadtestReport(samples, dropped, elapsed)
+
+	after, ok := target.Usage(ctx)
+	if !usageOK || !ok {
+		fmt.Println("⚠️  无法获取token用量，未统计成本")
+		return nil
+	}
+	usage := after.since(before)
+	printCostReport(usage, pricing)
+	if len(samples) > 0 && usage.Calls > 0 {
+		fmt.Printf("  - 平均每个请求: ¥%.6f\n", usage.Cost(pricing)/float64(len(samples)))
+	}
+	return nil
+}
+
+// 为每个请求加上超时
+type timeoutTarget struct {
+	loadtestTarget
+	timeout time.Duration
+}
+
+func (t *timeoutTarget) Ask(ctx context.Context, question string) loadtestSample {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.loadtestTarget.Ask(ctx, question)
+}
@@ -7,47 +7,169 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
 	"github.com/joho/godotenv"
 	"github.com/sashabaranov/go-openai"
+	"golang.org/x/sync/errgroup"
 )
 
 // 配置结构体
 type Config struct {
-	ElasticHost    string
-	ElasticPort    int
-	DeepSeekAPIKey string
-	DeepSeekModel  string
-	IndexName      string
+	ElasticHost        string
+	ElasticPort        int
+	ElasticUsername    string
+	ElasticPassword    string
+	ElasticAPIKey      string // 设置时优先于用户名密码
+	DeepSeekAPIKey     string
+	DeepSeekModel      string
+	Models             []chatModel // 请求可以指定的模型
+	SystemPrompt       string      // RAG系统提示词，发布profile可覆盖
+	IndexName          string
+	ChunkSize          int
+	IndexBatch         int  // 每批写入的分块数
+	IndexWorkers       int  // 同时写入的批次数
+	Profiling          bool // 输出入库分阶段的耗时和内存分配，serve挂载pprof接口
+	Trace              TraceConfig
+	Classify           ClassifyConfig
+	Entity             EntityConfig
+	Compression        bool // 分块正文以zstd压缩存储，检索时透明解压
+	Retrieval          RetrievalConfig
+	Tokenizer          TokenizerConfig
+	ChineseScript      string // 匹配前统一的中文字形：simplified、traditional、none
+	CrossLingual       CrossLingualConfig
+	Pinyin             PinyinConfig
+	Trust              TrustConfig
+	License            LicenseConfig
+	Federation         []FederatedIndex
+	Peers              PeerConfig
+	Blob               BlobStoreConfig
+	DocLimits          DocumentLimits
+	Consistency        string // 写入接口默认的一致性：eventual、strong
+	DocsDir            string
+	BootstrapFile      string // bootstrap命令保存的数据集，存在时替换内置示例文档
+	Crawler            CrawlerConfig
+	FollowUps          bool // 回答后生成追问建议
+	GlossaryFile       string
+	PolicyFile         string // 低置信度和超出范围时的回答策略
+	LexiconFile        string // 屏蔽词和替换词表
+	OversizedLog       string // 超出上下文预算的分块记录，由rechunk命令汇总
+	Calculator         bool   // 需要数值计算的问题交给计算器工具
+	Continuations      int    // 回答因长度上限被截断时最多自动续写的次数
+	Extractive         int    // 抽取式回答选取的句子数
+	Coalescing         bool   // 合并同一问题的并发回答请求
+	Reasoning          ReasoningConfig
+	Degrade            DegradeConfig
+	Summarize          SummarizeConfig
+	Provenance         ProvenanceConfig
+	Features           FeatureConfig
+	Rules              RulesConfig
+	Overrides          OverrideConfig
+	Maintenance        MaintenanceConfig
+	NamespaceEmbedding NamespaceEmbeddingConfig
+	LanguageFilter     LanguageFilterConfig
+	AnswerLength       AnswerLengthConfig
+	Dates              DateConfig
+	Embedding          EmbeddingConfig
+	AnswerCache        AnswerCacheConfig
+	RetrievalCache     RetrievalCacheConfig
+	DeepLink           DeepLinkConfig
+	Warm               WarmConfig
+	Pricing            PricingConfig
+	EvalSchedule       EvalScheduleConfig
+	Review             ReviewConfig
+	QueryLog           QueryLogConfig
+	Encryption         EncryptionConfig
+	SLO                SLOConfig
+	Session            SessionConfig
+	Expiry             ExpiryConfig
+	Reload             ReloadConfig
+	Idempotency        IdempotencyConfig
+	Admin              AdminConfig
+	Fault              FaultConfig
+	Failover           FailoverConfig
 }
 
 // 文档结构体
 type Document struct {
-	ID      string                 `json:"id"`
-	Title   string                 `json:"title"`
-	Content string                 `json:"content"`
-	Vector  []float32              `json:"vector,omitempty"`
-	Meta    map[string]interface{} `json:"meta,omitempty"`
+	ID         string                 `json:"id"`
+	DocID      string                 `json:"doc_id,omitempty"` // 分块所属的原始文档ID
+	ChunkIndex int                    `json:"chunk_index"`
+	Title      string                 `json:"title"`
+	Content    string                 `json:"content"`
+	Compressed []byte                 `json:"content_zstd,omitempty"` // 开启压缩时的正文，content只建索引不存储
+	Vector     []float32              `json:"vector,omitempty"`
+	Meta       map[string]interface{} `json:"meta,omitempty"`
 }
 
 // 搜索结果
 type SearchResult struct {
-	Title   string  `json:"title"`
-	Content string  `json:"content"`
-	Score   float64 `json:"score"`
+	ID       string                 `json:"id"`     // 分块ID
+	DocID    string                 `json:"doc_id"` // 所属文档ID
+	Title    string                 `json:"title"`
+	Content  string                 `json:"content"`
+	Score    float64                `json:"score"`
+	Trust    float64                `json:"trust"`               // 来源可信度
+	License  string                 `json:"license,omitempty"`   // 内容许可
+	Index    string                 `json:"index,omitempty"`     // 多索引联合检索时的来源索引
+	Link     string                 `json:"link,omitempty"`      // 查看原文的链接
+	DeepLink string                 `json:"deep_link,omitempty"` // 定位到原文具体位置的链接：标题锚点、网页文本片段或页面链接
+	Meta     map[string]interface{} `json:"meta,omitempty"`
 }
 
 // RAG系统
 type RAGSystem struct {
-	elasticClient *elasticsearch.Client
-	openAIClient  *openai.Client
-	config        Config
+	elasticClient       *elasticsearch.Client      // 入库、删除和索引管理
+	typedClient         *elasticsearch.TypedClient // 检索使用类型化API
+	replicaTyped        *elasticsearch.TypedClient // 备节点，仅用于读请求
+	openAIClient        *openai.Client
+	localClients        map[string]*openai.Client // LLM_MODELS中的本地模型
+	config              Config
+	faults              *faultInjector
+	failover            *failover
+	tokens              tokenCounter     // 按对话模型的分词器估算token数
+	script              *scriptConverter // 繁简统一，CHINESE_SCRIPT=none时为nil
+	encryption          *fieldCipher     // 查询日志、审核库的静态加密，未配置ENCRYPTION_KEY时为nil
+	usage               *usageTracker
+	answers             *answerCache
+	retrievals          *retrievalCache              // 检索结果缓存，关闭时为nil
+	embedder            embedder                     // 问题向量化，用于答案缓存和抽取式回答
+	vectorEmbedder      embedder                     // 生成vector字段的向量，未配置EMBEDDING_VECTOR_DIM时为nil
+	translations        translationCache             // 跨语言检索的问题译文
+	flights             *answerFlights               // 进行中的回答，未开启请求合并时为nil
+	broadcasts          *answerBroadcasts            // 进行中的流式回答，未开启请求合并时为nil
+	trending            *questionTracker             // 问题频次，未开启热门问题预生成时为nil
+	blobs               blobStore                    // 原文存储，未配置时为nil
+	classifier          *classifier                  // 文档分类，未配置分类体系时为nil
+	peers               *peerInstances               // 负责其他分类的对等实例，未配置时为nil
+	entities            *entityExtractor             // 实体抽取，关闭时为nil
+	traces              *traceWriter                 // 检索轨迹，未配置TRACE_DIR时为nil
+	reviews             *reviewQueue                 // 人工审核队列和FAQ，serve开启REVIEW_THRESHOLD时设置
+	rules               *ruleStore                   // 检索规则，serve配置RULES_DB时设置
+	overrides           *overrideStore               // 固定回答，serve配置OVERRIDES_DB时设置
+	maintenance         maintenanceState             // 存储维护的运行状态
+	namespaceEmbeddings *namespaceEmbeddings         // 命名空间单独配置的向量模型，没有配置时为nil
+	sessions            *sessionStore                // 会话展示过的来源
+	oversized           *oversizedLog                // 超出上下文预算的分块
+	live                atomic.Pointer[liveSettings] // 可热更新的配置：提示词、检索参数、术语表和回答策略
+	revisionMu          sync.Mutex                   // 串行化修订号的读取和写入：带版本校验的更新和ReplaceDocuments
 }
 
 func main() {
+	// 维护类子命令，例如: go run ./es gc -dry-run
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("执行命令失败: %v", err)
+		}
+		return
+	}
+
 	fmt.Println("🚀 ElasticSearch 8.x RAG Demo启动...")
 	fmt.Println("=====")
 
@@ -59,9 +181,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("创建RAG系统失败: %v", err)
 	}
-	defer func() {
-		_ = rag.elasticClient.Close(context.Background())
-	}()
+	defer rag.Close()
 
 	// 初始化知识库
 	fmt.Println("\n📚 正在初始化知识库...")
@@ -76,41 +196,80 @@ func main() {
 		"闫同学是谁？",
 		"介绍一下扯编程的淡公众号",
 	}
+	// bootstrap数据集替换了示例文档时，改用数据集的演示问题
+	if dataset, err := loadBootstrapDataset(config.BootstrapFile); err == nil && dataset != nil && len(dataset.Questions) > 0 {
+		testQuestions = dataset.Questions
+	}
 
 	// 运行对比测试
+	runComparison(rag, testQuestions)
+}
+
+// 逐个问题对比纯DeepSeek回答和RAG增强回答
+func runComparison(rag *RAGSystem, questions []string) {
 	fmt.Println("\n" + strings.Repeat("=", 50))
 	fmt.Println("🧪 开始对比测试")
 	fmt.Println(strings.Repeat("=", 50))
 
-	for i, question := range testQuestions {
-		fmt.Printf("\n📝 测试 %d/%d\n", i+1, len(testQuestions))
+	for i, question := range questions {
+		fmt.Printf("\n📝 测试 %d/%d\n", i+1, len(questions))
 		fmt.Printf("❓ 问题: %s\n", question)
 
-		// 获取直接答案
-		fmt.Println("\n🔍 获取纯DeepSeek回答...")
-		directAnswer, directTime, err := rag.GetDirectAnswer(question)
-		if err != nil {
-			fmt.Printf("❌ 获取直接答案失败: %v\n", err)
+		// 并行获取纯DeepSeek回答和RAG增强回答，输出顺序保持不变
+		var (
+			directAnswer, ragAnswer string
+			directTime, ragTime     float64
+			sources                 []SearchResult
+		)
+		start := time.Now()
+		var g errgroup.Group
+		g.Go(func() error {
+			var err error
+			directAnswer, directTime, err = rag.GetDirectAnswer(question)
+			if err != nil {
+				return fmt.Errorf("获取直接答案失败: %w", err)
+			}
+			return nil
+		})
+		g.Go(func() error {
+			var err error
+			ragAnswer, ragTime, sources, err = rag.GetRAGAnswer(context.Background(), question, searchOptions{})
+			if err != nil {
+				return fmt.Errorf("获取RAG答案失败: %w", err)
+			}
+			return nil
+		})
+		if err := g.Wait(); err != nil {
+			fmt.Printf("❌ %v\n", err)
 			continue
 		}
+		wallTime := time.Since(start).Seconds()
+
+		fmt.Println("\n🔍 纯DeepSeek回答...")
 		fmt.Printf("⏱️  响应时间: %.2f秒\n", directTime)
 		fmt.Printf("💬 回答: %s\n", directAnswer)
 
-		// 获取RAG答案
-		fmt.Println("\n🔍 获取RAG增强回答...")
-		ragAnswer, ragTime, sources, err := rag.GetRAGAnswer(question)
-		if err != nil {
-			fmt.Printf("❌ 获取RAG答案失败: %v\n", err)
-			continue
-		}
+		fmt.Println("\n🔍 RAG增强回答...")
 		fmt.Printf("⏱️  响应时间: %.2f秒\n", ragTime)
 		fmt.Printf("💬 回答: %s\n", ragAnswer)
 
+		// 问题和回答中出现的术语
+		if terms := rag.settings().glossary.Match(question, ragAnswer); len(terms) > 0 {
+			fmt.Println("\n📖 术语解释:")
+			fmt.Print(formatGlossary(terms))
+		}
+
 		// 显示检索到的文档
 		if len(sources) > 0 {
 			fmt.Println("\n📄 检索到的相关文档:")
 			for j, source := range sources {
-				fmt.Printf("  %d. [相似度: %.2f] %s\n", j+1, source.Score, source.Title)
+				fmt.Printf("  %d. [相似度: %.2f] %s（%s）\n", j+1, source.Score, source.Title, citationSource(source))
+				if source.Link != "" {
+					fmt.Printf("     原文: %s\n", source.Link)
+				}
+				if source.DeepLink != "" {
+					fmt.Printf("     位置: %s\n", source.DeepLink)
+				}
 				if j == 0 { // 只显示最相关文档的片段
 					content := source.Content
 					if len(content) > 100 {
@@ -121,16 +280,32 @@ func main() {
 			}
 		}
 
+		// 追问建议
+		if rag.config.FollowUps {
+			followUps, err := rag.SuggestFollowUps(context.Background(), question, ragAnswer, sources)
+			if err != nil {
+				fmt.Printf("⚠️  生成追问建议失败: %v\n", err)
+			} else if len(followUps) > 0 {
+				fmt.Println("\n💡 你可能还想问:")
+				for j, followUp := range followUps {
+					fmt.Printf("  %d. %s\n", j+1, followUp)
+				}
+			}
+		}
+
 		// 简单对比分析
 		fmt.Println("\n📊 对比分析:")
 		fmt.Printf("  - 时间开销: RAG比纯DeepSeek慢 %.2f秒\n", ragTime-directTime)
+		fmt.Printf("  - 并行耗时: %.2f秒，比顺序执行节省 %.2f秒\n", wallTime, directTime+ragTime-wallTime)
 		fmt.Printf("  - 信息质量: RAG基于 %d 个相关文档生成\n", len(sources))
 
-		if i < len(testQuestions)-1 {
+		if i < len(questions)-1 {
 			fmt.Println("\n" + strings.Repeat("-", 50))
 		}
 	}
 
+	printCostReport(rag.usage.Snapshot(), rag.config.Pricing)
+
 	fmt.Println("\n" + strings.Repeat("=", 50))
 	fmt.Println("🎉 测试完成!")
 	fmt.Println("💡 总结: ElasticSearch RAG在需要混合搜索的场景表现更好")
@@ -142,17 +317,84 @@ func loadConfig() Config {
 	godotenv.Load()
 
 	return Config{
-		ElasticHost:    getEnv("ELASTIC_HOST", "localhost"),
-		ElasticPort:    getEnvAsInt("ELASTIC_PORT", 9200),
-		DeepSeekAPIKey: getEnv("DEEPSEEK_API_KEY", ""),
-		DeepSeekModel:  getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
-		IndexName:      getEnv("INDEX_NAME", "rag_documents"),
+		ElasticHost:        getEnv("ELASTIC_HOST", "localhost"),
+		ElasticPort:        getEnvAsInt("ELASTIC_PORT", 9200),
+		ElasticUsername:    getEnv("ELASTIC_USERNAME", ""),
+		ElasticPassword:    getEnv("ELASTIC_PASSWORD", ""),
+		ElasticAPIKey:      getEnv("ELASTIC_API_KEY", ""),
+		DeepSeekAPIKey:     getEnv("DEEPSEEK_API_KEY", ""),
+		DeepSeekModel:      getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
+		Models:             loadChatModels(),
+		SystemPrompt:       getEnv("RAG_SYSTEM_PROMPT", ragSystemPrompt),
+		IndexName:          getEnv("INDEX_NAME", "rag_documents"),
+		ChunkSize:          getEnvAsInt("CHUNK_SIZE", 500),
+		IndexBatch:         getEnvAsInt("INDEX_BATCH_SIZE", 500),
+		IndexWorkers:       getEnvAsInt("INDEX_WORKERS", 4),
+		Profiling:          getEnvAsBool("PROFILING", false),
+		Trace:              loadTraceConfig(),
+		Classify:           loadClassifyConfig(),
+		Entity:             loadEntityConfig(),
+		Compression:        getEnv("CHUNK_COMPRESSION", "none") == "zstd",
+		Retrieval:          loadRetrievalConfig(),
+		Tokenizer:          loadTokenizerConfig(),
+		ChineseScript:      getEnv("CHINESE_SCRIPT", scriptSimplified),
+		CrossLingual:       loadCrossLingualConfig(),
+		Pinyin:             loadPinyinConfig(),
+		Trust:              loadTrustConfig(),
+		License:            loadLicenseConfig(),
+		Federation:         loadFederationConfig(),
+		Peers:              loadPeerConfig(),
+		Blob:               loadBlobStoreConfig(),
+		DocLimits:          loadDocumentLimits(),
+		Consistency:        getEnv("WRITE_CONSISTENCY", consistencyEventual),
+		DocsDir:            getEnv("DOCS_DIR", ""),
+		BootstrapFile:      getEnv("BOOTSTRAP_FILE", ".bootstrap_dataset.json"),
+		Crawler:            loadCrawlerConfig(),
+		FollowUps:          getEnvAsBool("FOLLOW_UP_SUGGESTIONS", true),
+		GlossaryFile:       getEnv("GLOSSARY_FILE", "glossary.json"),
+		PolicyFile:         getEnv("ANSWER_POLICY_FILE", "policy.json"),
+		LexiconFile:        getEnv("LEXICON_FILE", "lexicon.json"),
+		OversizedLog:       getEnv("OVERSIZED_CHUNK_LOG", "oversized_chunks.jsonl"),
+		Calculator:         getEnvAsBool("CALCULATOR_TOOL", true),
+		Continuations:      getEnvAsInt("ANSWER_MAX_CONTINUATIONS", 2),
+		Extractive:         getEnvAsInt("EXTRACTIVE_SENTENCES", 3),
+		Coalescing:         getEnvAsBool("REQUEST_COALESCING", true),
+		Reasoning:          loadReasoningConfig(),
+		Degrade:            loadDegradeConfig(),
+		Summarize:          loadSummarizeConfig(),
+		Provenance:         loadProvenanceConfig(),
+		Features:           loadFeatureConfig(),
+		Rules:              loadRulesConfig(),
+		Overrides:          loadOverrideConfig(),
+		Maintenance:        loadMaintenanceConfig(),
+		NamespaceEmbedding: loadNamespaceEmbeddingConfig(),
+		LanguageFilter:     loadLanguageFilterConfig(),
+		AnswerLength:       loadAnswerLengthConfig(),
+		Dates:              loadDateConfig(),
+		Embedding:          loadEmbeddingConfig(),
+		AnswerCache:        loadAnswerCacheConfig(),
+		RetrievalCache:     loadRetrievalCacheConfig(),
+		DeepLink:           loadDeepLinkConfig(),
+		Warm:               loadWarmConfig(),
+		Pricing:            loadPricingConfig(),
+		EvalSchedule:       loadEvalScheduleConfig(),
+		Review:             loadReviewConfig(),
+		QueryLog:           loadQueryLogConfig(),
+		Encryption:         loadEncryptionConfig(),
+		SLO:                loadSLOConfig(),
+		Session:            loadSessionConfig(),
+		Expiry:             loadExpiryConfig(),
+		Reload:             loadReloadConfig(),
+		Idempotency:        loadIdempotencyConfig(),
+		Admin:              loadAdminConfig(),
+		Fault:              loadFaultConfig(),
+		Failover:           loadFailoverConfig("ELASTIC", 9200),
 	}
 }
 
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
-		return value
+		return resolveSecret(key, value)
 	}
 	return defaultValue
 }
@@ -167,16 +409,98 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return result
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
 // 创建RAG系统
 func NewRAGSystem(config Config) (*RAGSystem, error) {
 	if config.DeepSeekAPIKey == "" {
 		return nil, fmt.Errorf("DEEPSEEK_API_KEY不能为空")
 	}
 
+	// 问题向量化，用于答案缓存的语义匹配
+	questionEmbedder, err := newEmbedder(config.Embedding)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := newTokenCounter(config.Tokenizer, config.DeepSeekModel)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateContextOrder(config.Retrieval.Order); err != nil {
+		return nil, err
+	}
+	if config.Expiry.Action != expiryDelete && config.Expiry.Action != expiryArchive {
+		return nil, fmt.Errorf("未知的EXPIRY_ACTION: %s，可选 delete、archive", config.Expiry.Action)
+	}
+	if err := config.Failover.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.SLO.validate(); err != nil {
+		return nil, err
+	}
+	script, err := newScriptConverter(config.ChineseScript)
+	if err != nil {
+		return nil, err
+	}
+	encryption, err := newFieldCipher(config.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	// 原文存储（可选）
+	blobs, err := newBlobStore(config.Blob)
+	if err != nil {
+		return nil, err
+	}
+
+	// 文档分类（可选）
+	docClassifier, err := newClassifier(config.Classify)
+	if err != nil {
+		return nil, err
+	}
+
+	// 实体抽取（可选）
+	entities, err := newEntityExtractor(config.Entity)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检索轨迹（可选）
+	traces, err := newTraceWriter(config.Trace)
+	if err != nil {
+		return nil, err
+	}
+
 	// 连接ElasticSearch 8.x
 	elasticURL := fmt.Sprintf("http://%s:%d", config.ElasticHost, config.ElasticPort)
 	cfg := elasticsearch.Config{
 		Addresses: []string{elasticURL},
+		Username:  config.ElasticUsername,
+		Password:  config.ElasticPassword,
+		APIKey:    config.ElasticAPIKey,
+		Transport: elasticAuthTransport(config),
 	}
 
 	client, err := elasticsearch.NewClient(cfg)
@@ -184,6 +508,11 @@ func NewRAGSystem(config Config) (*RAGSystem, error) {
 		return nil, fmt.Errorf("连接ElasticSearch失败: %w", err)
 	}
 
+	typedClient, err := elasticsearch.NewTypedClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("连接ElasticSearch失败: %w", err)
+	}
+
 	// 测试连接
 	res, err := client.Info()
 	if err != nil {
@@ -195,15 +524,103 @@ func NewRAGSystem(config Config) (*RAGSystem, error) {
 		return nil, fmt.Errorf("ElasticSearch连接错误: %s", res.String())
 	}
 
+	// 连接ElasticSearch备节点（可选）
+	var replicaTyped *elasticsearch.TypedClient
+	var fo *failover
+	if config.Failover.ReplicaHost != "" {
+		replicaTyped, err = elasticsearch.NewTypedClient(elasticsearch.Config{
+			Addresses: []string{fmt.Sprintf("http://%s:%d", config.Failover.ReplicaHost, config.Failover.ReplicaPort)},
+			Username:  config.ElasticUsername,
+			Password:  config.ElasticPassword,
+			APIKey:    config.ElasticAPIKey,
+			Transport: elasticAuthTransport(config),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("连接ElasticSearch备节点失败: %w", err)
+		}
+		fo = newFailover(config.Failover, func(ctx context.Context) error {
+			res, err := client.Ping(client.Ping.WithContext(ctx))
+			if err != nil {
+				return err
+			}
+			defer res.Body.Close()
+			if res.IsError() {
+				return fmt.Errorf("ElasticSearch主节点不可用: %s", res.Status())
+			}
+			return nil
+		})
+	}
+
+	// 加载术语表
+	terms, err := loadGlossary(config.GlossaryFile)
+	if err != nil {
+		fo.Close()
+		return nil, err
+	}
+
+	// 加载回答策略
+	policies, err := loadAnswerPolicies(config.PolicyFile)
+	if err != nil {
+		fo.Close()
+		return nil, err
+	}
+
+	// 加载屏蔽词和替换词表
+	words, err := loadLexicon(config.LexiconFile)
+	if err != nil {
+		fo.Close()
+		return nil, err
+	}
+
+	// 加载检索特性开关
+	flags, err := loadFeatureFlags(config.Features)
+	if err != nil {
+		fo.Close()
+		return nil, err
+	}
+
 	// 创建OpenAI客户端
 	conf := openai.DefaultConfig(config.DeepSeekAPIKey)
 	conf.BaseURL = "https://api.deepseek.com"
+	usage := &usageTracker{}
+	conf.HTTPClient = newUsageHTTPClient(usage)
+	conf.HTTPClient.Transport = rotatingAuth(conf.HTTPClient.Transport, "DEEPSEEK_API_KEY", bearerAuth)
 
-	return &RAGSystem{
+	r := &RAGSystem{
 		elasticClient: client,
+		typedClient:   typedClient,
+		replicaTyped:  replicaTyped,
 		openAIClient:  openai.NewClientWithConfig(conf),
+		localClients:  newLocalChatClients(config.Models),
 		config:        config,
-	}, nil
+		faults:        newFaultInjector(config.Fault),
+		failover:      fo,
+		tokens:        tokens,
+		script:        script,
+		encryption:    encryption,
+		usage:         usage,
+		answers:       newAnswerCache(config.AnswerCache, questionEmbedder),
+		retrievals:    newRetrievalCache(config.RetrievalCache),
+		embedder:      questionEmbedder,
+		flights:       newAnswerFlights(config.Coalescing),
+		broadcasts:    newAnswerBroadcasts(config.Coalescing),
+		trending:      newQuestionTracker(config.Warm),
+		blobs:         blobs,
+		classifier:    docClassifier,
+		peers:         newPeerInstances(config.Peers),
+		entities:      entities,
+		traces:        traces,
+		sessions:      newSessionStore(config.Session, questionEmbedder),
+		oversized:     newOversizedLog(config.OversizedLog),
+	}
+	if r.namespaceEmbeddings, err = loadNamespaceEmbeddings(config.NamespaceEmbedding, config.Embedding); err != nil {
+		return nil, err
+	}
+	if r.vectorEmbedder, err = newVectorEmbedder(config.Embedding); err != nil {
+		return nil, err
+	}
+	r.live.Store(&liveSettings{SystemPrompt: config.SystemPrompt, Retrieval: config.Retrieval, MMRLambda: config.Features.MMRLambda, glossary: terms, policies: policies, lexicon: words, flags: flags})
+	return r, nil
 }
 
 // 初始化知识库
@@ -230,7 +647,51 @@ func (r *RAGSystem) InitializeKnowledgeBase() error {
 		}
 	}
 
-	// 创建索引 mapping - ElasticSearch 8.x 格式
+	// 序列化mapping为JSON
+	mappingJSON, err := json.Marshal(r.indexMapping())
+	if err != nil {
+		return fmt.Errorf("序列化mapping失败: %w", err)
+	}
+
+	// 创建索引
+	res, err = r.elasticClient.Indices.Create(
+		indexName,
+		r.elasticClient.Indices.Create.WithBody(bytes.NewReader(mappingJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("创建索引错误: %s", res.String())
+	}
+
+	// 插入示例文档
+	err = r.insertSampleDocuments()
+	if err != nil {
+		return fmt.Errorf("插入文档失败: %w", err)
+	}
+
+	// 等待索引刷新
+	res, err = r.elasticClient.Indices.Refresh(
+		r.elasticClient.Indices.Refresh.WithIndex(indexName),
+	)
+	if err != nil {
+		return fmt.Errorf("刷新索引失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("刷新索引错误: %s", res.String())
+	}
+
+	fmt.Printf("✅ 索引 %s 创建成功\n", indexName)
+	return nil
+}
+
+// 当前配置下索引应有的settings和mapping - ElasticSearch 8.x 格式
+func (r *RAGSystem) indexMapping() map[string]interface{} {
 	mapping := map[string]interface{}{
 		"settings": map[string]interface{}{
 			"number_of_shards":   1,
@@ -248,6 +709,12 @@ func (r *RAGSystem) InitializeKnowledgeBase() error {
 				"id": map[string]interface{}{
 					"type": "keyword",
 				},
+				"doc_id": map[string]interface{}{
+					"type": "keyword",
+				},
+				"chunk_index": map[string]interface{}{
+					"type": "integer",
+				},
 				"title": map[string]interface{}{
 					"type":     "text",
 					"analyzer": "standard",
@@ -258,13 +725,22 @@ func (r *RAGSystem) InitializeKnowledgeBase() error {
 				},
 				"vector": map[string]interface{}{
 					"type":       "dense_vector",
-					"dims":       4,
+					"dims":       r.embeddingDim(),
 					"index":      true,
 					"similarity": "cosine",
 				},
+				"content_zstd": map[string]interface{}{
+					"type": "binary",
+				},
 				"meta": map[string]interface{}{
 					"type":    "object",
 					"dynamic": true,
+					// 实体按规范名称精确过滤，不分词
+					"properties": map[string]interface{}{
+						"people":        map[string]interface{}{"type": "keyword"},
+						"organizations": map[string]interface{}{"type": "keyword"},
+						"dates":         map[string]interface{}{"type": "keyword"},
+					},
 				},
 				"timestamp": map[string]interface{}{
 					"type": "date",
@@ -273,64 +749,67 @@ func (r *RAGSystem) InitializeKnowledgeBase() error {
 		},
 	}
 
-	// 序列化mapping为JSON
-	mappingJSON, err := json.Marshal(mapping)
-	if err != nil {
-		return fmt.Errorf("序列化mapping失败: %w", err)
-	}
-
-	// 创建索引
-	res, err = r.elasticClient.Indices.Create(
-		indexName,
-		r.elasticClient.Indices.Create.WithBody(bytes.NewReader(mappingJSON)),
-	)
-	if err != nil {
-		return fmt.Errorf("创建索引失败: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return fmt.Errorf("创建索引错误: %s", res.String())
+	if r.script != nil {
+		// 繁简统一：title和content分词前先转换字形，查询时使用同一分析器
+		analysis := mapping["settings"].(map[string]interface{})["analysis"].(map[string]interface{})
+		analysis["char_filter"] = map[string]interface{}{
+			"chinese_script": map[string]interface{}{
+				"type":     "mapping",
+				"mappings": r.script.charMappings(),
+			},
+		}
+		analysis["analyzer"].(map[string]interface{})["rag_text"] = map[string]interface{}{
+			"type":        "custom",
+			"tokenizer":   "standard",
+			"char_filter": []string{"chinese_script"},
+			"filter":      []string{"lowercase"},
+		}
+		properties := mapping["mappings"].(map[string]interface{})["properties"].(map[string]interface{})
+		for _, field := range []string{"title", "content"} {
+			properties[field].(map[string]interface{})["analyzer"] = "rag_text"
+		}
 	}
 
-	// 插入示例文档
-	err = r.insertSampleDocuments()
-	if err != nil {
-		return fmt.Errorf("插入文档失败: %w", err)
+	// 命名空间模型的向量按各自的维度单独建字段
+	properties := mapping["mappings"].(map[string]interface{})["properties"].(map[string]interface{})
+	for _, field := range r.vectorFields()[1:] {
+		properties[field.Name] = map[string]interface{}{
+			"type":       "dense_vector",
+			"dims":       field.Dim,
+			"index":      true,
+			"similarity": "cosine",
+		}
 	}
 
-	// 等待索引刷新
-	res, err = r.elasticClient.Indices.Refresh(
-		r.elasticClient.Indices.Refresh.WithIndex(indexName),
-	)
-	if err != nil {
-		return fmt.Errorf("刷新索引失败: %w", err)
+	if r.config.Pinyin.Enabled {
+		// 标题增加拼音子字段
+		addPinyinAnalysis(mapping["settings"].(map[string]interface{})["analysis"].(map[string]interface{}))
+		properties := mapping["mappings"].(map[string]interface{})["properties"].(map[string]interface{})
+		properties["title"].(map[string]interface{})["fields"] = map[string]interface{}{
+			"pinyin": map[string]interface{}{"type": "text", "analyzer": pinyinAnalyzer},
+		}
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		return fmt.Errorf("刷新索引错误: %s", res.String())
+	if r.config.Compression {
+		// 正文仍建倒排索引供文本检索，但不存入_source，检索时从content_zstd解压
+		mapping["mappings"].(map[string]interface{})["_source"] = map[string]interface{}{
+			"excludes": []string{"content"},
+		}
 	}
-
-	fmt.Printf("✅ 索引 %s 创建成功\n", indexName)
-	return nil
+	return mapping
 }
 
-// 插入示例文档
-func (r *RAGSystem) insertSampleDocuments() error {
-	indexName := r.config.IndexName
-
-	// 示例文档数据
-	documents := []Document{
+// 示例文档数据
+func (r *RAGSystem) sampleDocuments() []Document {
+	return []Document{
 		{
 			ID:      "doc_001",
 			Title:   "闫同学人物介绍",
 			Content: "闫同学，男，来自中国，26岁，天蝎座，是知名技术博主、摄影博主、技术爱好者，擅长写Go语言，喜欢打羽毛球。",
 			Vector:  r.generateSimpleVector("闫同学人物介绍"),
 			Meta: map[string]interface{}{
-				"category": "人物介绍",
-				"source":   "闫同学人物介绍",
-				"date":     "2026-02-04",
+				"source": "闫同学人物介绍",
+				"date":   "2026-02-04",
 			},
 		},
 		{
@@ -339,44 +818,277 @@ func (r *RAGSystem) insertSampleDocuments() error {
 			Content: "扯编程的淡，科技领域知名微信公众号，由闫同学运营，内容多为技术博客，日常生活感想，截止2026年1月，已有粉丝2000+。",
 			Vector:  r.generateSimpleVector("扯编程的淡公众号介绍"),
 			Meta: map[string]interface{}{
-				"category": "公众号介绍",
-				"source":   "扯编程的淡公众号介绍",
-				"date":     "2026-02-04",
+				"source": "扯编程的淡公众号介绍",
+				"date":   "2026-02-04",
+			},
+		},
+	}
+}
+
+// 知识库中应当存在的源文档：示例文档（或bootstrap数据集）、DOCS_DIR目录中的文件和抓取的网页
+func (r *RAGSystem) sourceDocuments() ([]Document, error) {
+	dataset, err := loadBootstrapDataset(r.config.BootstrapFile)
+	if err != nil {
+		return nil, err
+	}
+	documents := r.sampleDocuments()
+	if dataset != nil {
+		documents = dataset.documents()
+	}
+
+	var loaded []Document
+	if r.config.DocsDir != "" {
+		docs, err := loadDocumentsFromDir(r.config.DocsDir)
+		if err != nil {
+			return nil, fmt.Errorf("加载目录 %s 失败: %w", r.config.DocsDir, err)
+		}
+		loaded = append(loaded, docs...)
+	}
+	if len(r.config.Crawler.SeedURLs) > 0 {
+		c, err := newCrawler(r.config.Crawler)
+		if err != nil {
+			return nil, err
+		}
+		docs, err := c.Crawl(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("抓取网页失败: %w", err)
+		}
+		loaded = append(loaded, docs...)
+	}
+	return append(documents, loaded...), nil
+}
+
+// 插入示例文档
+func (r *RAGSystem) insertSampleDocuments() error {
+	documents, err := r.sourceDocuments()
+	if err != nil {
+		return err
+	}
+	return r.IndexDocuments(documents)
+}
+
+// 替换文档：先删除文档已有的分块再重新写入，用于增量同步。修订号在已存储的修订号上加一，
+// 读取修订号到写入完成之间持有revisionMu，与带版本校验的更新互斥
+func (r *RAGSystem) ReplaceDocuments(documents []Document) error {
+	if len(documents) == 0 {
+		return nil
+	}
+	r.revisionMu.Lock()
+	defer r.revisionMu.Unlock()
+	if err := r.stampRevisions(context.Background(), documents); err != nil {
+		return err
+	}
+	return r.rewriteDocuments(documents)
+}
+
+// 删除文档已有的分块后按documents重新写入，修订号由调用方确定
+func (r *RAGSystem) rewriteDocuments(documents []Document) error {
+	docIDs := make([]string, 0, len(documents))
+	for _, doc := range documents {
+		docIDs = append(docIDs, doc.ID)
+	}
+	if err := r.DeleteDocuments(docIDs); err != nil {
+		return err
+	}
+	return r.IndexDocuments(documents)
+}
+
+// 刷新索引，使刚写入的分块对检索可见
+func (r *RAGSystem) makeVisible(ctx context.Context) error {
+	res, err := r.elasticClient.Indices.Refresh(
+		r.elasticClient.Indices.Refresh.WithContext(ctx),
+		r.elasticClient.Indices.Refresh.WithIndex(r.config.IndexName),
+	)
+	if err != nil {
+		return fmt.Errorf("刷新索引失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("刷新索引错误: %s", res.String())
+	}
+	return nil
+}
+
+// 按文档ID删除文档的全部分块
+func (r *RAGSystem) DeleteDocuments(docIDs []string) error {
+	if len(docIDs) == 0 {
+		return nil
+	}
+	defer r.retrievals.Clear()
+
+	deleteQuery := map[string]interface{}{
+		"query": map[string]interface{}{
+			"terms": map[string]interface{}{
+				"doc_id": docIDs,
 			},
 		},
 	}
 
-	// 批量插入文档
-	var bulkBuffer bytes.Buffer
+	deleteJSON, _ := json.Marshal(deleteQuery)
+	res, err := r.elasticClient.DeleteByQuery(
+		[]string{r.config.IndexName},
+		bytes.NewReader(deleteJSON),
+		r.elasticClient.DeleteByQuery.WithRefresh(true),
+	)
+	if err != nil {
+		return fmt.Errorf("删除旧分块失败: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("删除旧分块错误: %s", res.String())
+	}
+	return nil
+}
+
+// 将文档分块后批量写入索引，每INDEX_BATCH_SIZE个分块提交一次，最多INDEX_WORKERS批同时提交
+func (r *RAGSystem) IndexDocuments(documents []Document) error {
+	defer trackIngest()()
+	profiler := newStageProfiler(r.config.Profiling)
+	defer profiler.Finish()
+	// 写入后缓存的检索结果可能已过时，无论成功与否都清空
+	defer r.retrievals.Clear()
+
+	// 写入前确认向量维度与索引一致
+	if err := r.checkVectorDim(context.Background()); err != nil {
+		return err
+	}
+
+	// 没有分类的文档由大模型自动分类，元数据中的日期解析为时间戳
+	r.classifyDocuments(documents)
+	r.config.Dates.normalizeDocuments(documents)
+	profiler.Mark("分类")
+
+	// 完整原文存入原文存储，向量库只保存分块和元数据
+	if err := r.storeOriginals(documents); err != nil {
+		return err
+	}
+	profiler.Mark("原文存储")
+
+	chunks, err := r.writeChunks(context.Background(), r.config.IndexName, documents, r.config.IndexBatch, r.config.IndexWorkers, profiler)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ 成功插入 %d 个文档（%d 个分块）到ElasticSearch\n", len(documents), len(chunks))
+	printIngestReport(buildIngestReport(documents, chunks, r.tokens))
+	return nil
+}
+
+// 分块、编码并批量写入indexName，返回写入的分块。分块逐个编码进池化的缓冲区，同步提交时缓冲区在批次间复用，
+// 并发提交时每批换一个，写完后放回池中，避免大批量入库时内存膨胀
+func (r *RAGSystem) writeChunks(ctx context.Context, indexName string, documents []Document, batchSize, workers int, profiler *stageProfiler) ([]Chunk, error) {
+	batchSize = max(batchSize, 1)
+	buffer := getBulkBuffer()
+	defer func() { putBulkBuffer(buffer) }()
+	encoder := json.NewEncoder(buffer)
+	pending := 0
+	writer := newIndexWriter(workers)
+	defer writer.Wait()
+	defer func() { ingestCounters.chunksHeld.Add(-int64(pending)) }()
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+		profiler.Mark("分块编码")
+		body, held, concurrent := buffer, int64(pending), writer.concurrent()
+		pending = 0
+		err := writer.Go(func() error {
+			defer ingestCounters.chunksHeld.Add(-held)
+			if concurrent {
+				defer putBulkBuffer(body)
+			}
+			return r.bulkIndex(ctx, indexName, body.Bytes())
+		})
+		profiler.Mark("批量写入")
+		if concurrent {
+			buffer = getBulkBuffer()
+			encoder = json.NewEncoder(buffer)
+		} else {
+			buffer.Reset()
+		}
+		return err
+	}
+
+	// 将文档分块，每个分块作为一条ES文档
+	var chunks []Chunk
 	for _, doc := range documents {
 		// 添加时间戳
 		if doc.Meta == nil {
 			doc.Meta = make(map[string]interface{})
 		}
 		doc.Meta["timestamp"] = time.Now()
+		// 按分类的向量模型向量化标题（默认为内置简化向量），文档自带预计算向量时直接使用
+		model := r.tagEmbeddingModel(&doc)
+		if doc.Vector == nil {
+			vector, err := r.embedWith(ctx, model, doc.Title)
+			if err != nil {
+				return nil, fmt.Errorf("文档 %s 向量化失败: %w", doc.ID, err)
+			}
+			doc.Vector = vector
+		} else if dim := r.vectorDim(model); len(doc.Vector) != dim {
+			return nil, fmt.Errorf("%w: 文档 %s 的预计算向量为 %d 维，%s字段为 %d 维", ErrDimensionMismatch, doc.ID, len(doc.Vector), embeddingField(model), dim)
+		}
 
-		// 添加操作行
-		meta := map[string]interface{}{
-			"index": map[string]interface{}{
-				"_index": indexName,
-				"_id":    doc.ID,
-			},
+		for _, chunk := range splitDocument(doc, r.config.ChunkSize) {
+			chunks = append(chunks, chunk)
+			chunkDoc := Document{
+				ID:         chunk.ID,
+				DocID:      chunk.DocID,
+				ChunkIndex: chunk.Index,
+				Title:      chunk.Title,
+				Content:    chunk.Content,
+				Vector:     doc.Vector,
+				Meta:       r.entities.enrich(withLanguage(withHeading(doc.Meta, chunk.Heading), chunk.Content), chunk.Title+"\n"+chunk.Content),
+			}
+			if r.config.Compression {
+				chunkDoc.Compressed = compressChunk(chunk.Content)
+			}
+
+			// 操作行和文档行，Encoder每次输出后自带换行
+			action := map[string]interface{}{"index": map[string]interface{}{"_index": indexName, "_id": chunkDoc.ID}}
+			if err := encoder.Encode(action); err != nil {
+				return nil, err
+			}
+			var source interface{} = chunkDoc
+			if model != "" {
+				source = namespacedChunk{Document: chunkDoc, field: embeddingField(model)}
+			}
+			if err := encoder.Encode(source); err != nil {
+				return nil, fmt.Errorf("序列化分块 %s 失败: %w", chunkDoc.ID, err)
+			}
+			pending++
+			ingestCounters.chunksHeld.Add(1)
+			if pending >= batchSize {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
 		}
+	}
 
-		metaJSON, _ := json.Marshal(meta)
-		bulkBuffer.Write(metaJSON)
-		bulkBuffer.WriteByte('\n')
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if err := writer.Wait(); err != nil {
+		return nil, err
+	}
+	profiler.Mark("批量写入")
+	return chunks, nil
+}
 
-		// 添加文档数据行
-		docJSON, _ := json.Marshal(doc)
-		bulkBuffer.Write(docJSON)
-		bulkBuffer.WriteByte('\n')
+// 提交一批bulk请求
+func (r *RAGSystem) bulkIndex(ctx context.Context, indexName string, body []byte) error {
+	if err := r.faults.inject(ctx, faultTargetStore, "ES批量插入"); err != nil {
+		return fmt.Errorf("批量插入失败: %w", err)
 	}
 
 	// 执行批量插入
 	res, err := r.elasticClient.Bulk(
-		bytes.NewReader(bulkBuffer.Bytes()),
+		bytes.NewReader(body),
 		r.elasticClient.Bulk.WithIndex(indexName),
+		r.elasticClient.Bulk.WithContext(ctx),
 	)
 	if err != nil {
 		return fmt.Errorf("批量插入失败: %w", err)
@@ -400,15 +1112,14 @@ func (r *RAGSystem) insertSampleDocuments() error {
 	if bulkResponse["errors"] == true {
 		return fmt.Errorf("批量插入存在错误")
 	}
-
-	fmt.Printf("✅ 成功插入 %d 个文档到ElasticSearch\n", len(documents))
 	return nil
 }
 
-// 生成简化向量（4维向量）
+// 生成内置简化向量（simpleVectorDim维）
 func (r *RAGSystem) generateSimpleVector(text string) []float32 {
-	vector := make([]float32, 4)
-	for i := 0; i < 4; i++ {
+	text = r.script.Convert(text) // 繁简统一后计算
+	vector := make([]float32, simpleVectorDim)
+	for i := 0; i < simpleVectorDim; i++ {
 		hash := float32(0)
 		for j, ch := range text {
 			if j >= 10 {
@@ -439,7 +1150,10 @@ func (r *RAGSystem) GetDirectAnswer(question string) (string, float64, error) {
 	start := time.Now()
 
 	ctx := context.Background()
-	resp, err := r.openAIClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+	if err := r.faults.inject(ctx, faultTargetLLM, "DeepSeek直接回答"); err != nil {
+		return "", 0, err
+	}
+	answer, err := r.completeAnswer(ctx, openai.ChatCompletionRequest{
 		Model: r.config.DeepSeekModel,
 		Messages: []openai.ChatCompletionMessage{
 			{
@@ -459,261 +1173,399 @@ func (r *RAGSystem) GetDirectAnswer(question string) (string, float64, error) {
 		return "", 0, err
 	}
 
-	elapsed := time.Since(start).Seconds()
-
-	if len(resp.Choices) == 0 {
-		return "", elapsed, fmt.Errorf("未收到回答")
-	}
-
-	return resp.Choices[0].Message.Content, elapsed, nil
+	return answer, time.Since(start).Seconds(), nil
 }
 
 // 获取RAG增强答案
-func (r *RAGSystem) GetRAGAnswer(question string) (string, float64, []SearchResult, error) {
+func (r *RAGSystem) GetRAGAnswer(ctx context.Context, question string, opts searchOptions) (string, float64, []SearchResult, error) {
 	start := time.Now()
+	ctx = withPromptLog(ctx, opts.Prompts)
+
+	// 指定直接回答时不检索知识库
+	if opts.Direct {
+		answer, err := r.answerDirect(ctx, question, opts)
+		return answer, time.Since(start).Seconds(), nil, err
+	}
+
+	// 问题由对等实例负责时转发给对方，merge模式下对方的分块与本地检索结果合并
+	peerAnswer, remote, delegated := r.delegateToPeer(ctx, question, &opts)
+	if delegated {
+		return peerAnswer, time.Since(start).Seconds(), remote, nil
+	}
+
+	// 范围很广的问题分批总结后综合回答，大模型不可用时降级为分块摘录
+	if answer, results, handled, err := r.answerBySummaries(ctx, question, opts); handled {
+		if err != nil && len(results) > 0 {
+			answer, err = r.answerExtractive(ctx, results, opts, err)
+		}
+		return answer, time.Since(start).Seconds(), results, err
+	}
+
+	// 1. 检索相关文档，知识库不可用时按配置降级为大模型直接回答
+	results, err := r.retrieve(ctx, question, opts)
+	if err != nil {
+		answer, err := r.answerWithoutRetrieval(ctx, question, opts, err)
+		return answer, time.Since(start).Seconds(), nil, err
+	}
+	results = mergePeerResults(results, remote)
+	// 会话中相关的历史问答作为额外的来源
+	results = append(results, opts.History...)
+
+	// 低置信度或超出知识库范围时按回答策略拒答、转人工或直接回答
+	if answer, handled, err := r.applyPolicy(ctx, question, opts, results); handled {
+		return answer, time.Since(start).Seconds(), nil, err
+	}
+	// 没有可参考的内容时不调用大模型
+	if len(results) == 0 {
+		return "", time.Since(start).Seconds(), nil, ErrNoRelevantDocs
+	}
+
+	// 2. 抽取式回答不调用大模型，向量化服务不可用时降级为分块摘录
+	if opts.Extractive {
+		answer, err := r.answerBySentences(ctx, question, results)
+		if err != nil {
+			answer, err = r.answerExtractive(ctx, results, opts, err)
+		}
+		return answer, time.Since(start).Seconds(), results, err
+	}
+
+	// 超出上下文预算的分块只把相关片段放进提示词
+	prompted := r.fitOversized(ctx, question, results)
+
+	// 3. 需要数值计算时走计算器工具，避免模型心算出错
+	if r.useCalculator(opts.Model) && needsCalculation(question, prompted) {
+		answer, steps, err := r.answerWithCalculator(ctx, question, prompted, opts.Model)
+		if err != nil {
+			answer, err := r.answerExtractive(ctx, results, opts, err)
+			return answer, time.Since(start).Seconds(), results, err
+		}
+		return appendAttribution(appendCalcSteps(answer, steps), results), time.Since(start).Seconds(), results, nil
+	}
+
+	// 4. 调用DeepSeek生成答案，推理模型的思考过程收集到opts.Reasoning；大模型不可用时降级为分块摘录
+	ctx = withReasoning(ctx, opts.Reasoning)
+	var answer string
+	err = llmError(ctx, r.faults.inject(ctx, faultTargetLLM, "DeepSeek RAG回答"))
+	if err == nil {
+		answer, err = r.completeWithLength(ctx, r.ragChatRequest(question, prompted, opts.Model), opts.Length)
+	}
+
+	elapsed := time.Since(start).Seconds()
 
-	// 1. 检索相关文档
-	results, err := r.SearchDocuments(question, 3)
 	if err != nil {
-		return "", 0, nil, err
+		answer, err := r.answerExtractive(ctx, results, opts, err)
+		return answer, elapsed, results, err
 	}
 
-	// 2. 构建上下文
+	return appendAttribution(answer, results), elapsed, results, nil
+}
+
+// 默认的RAG系统提示词，保持不变以便命中上下文缓存
+const ragSystemPrompt = "你是一个严谨的AI助手，必须严格基于提供的上下文信息回答问题。如果上下文信息不足，请如实告知。不要编造上下文之外的信息。" +
+	"每个文档标注了来源和可信度，信息冲突时以可信度高的来源为准，引用可信度低的来源时需说明。"
+
+// 构建RAG请求：将检索到的文档作为上下文，model为空时使用DEEPSEEK_MODEL
+func (r *RAGSystem) ragChatRequest(question string, results []SearchResult, model string) openai.ChatCompletionRequest {
+	// DeepSeek按前缀命中上下文缓存：固定的系统提示词在前，上下文默认按分块ID排序（CONTEXT_ORDER），
+	// 同一批检索结果总能生成相同的前缀，随问题变化的内容放在最后
+	ordered := orderContext(results, r.settings().Retrieval.Order)
+
 	var contextBuilder strings.Builder
 	contextBuilder.WriteString("以下是相关文档信息：\n\n")
 
-	for i, result := range results {
-		contextBuilder.WriteString(fmt.Sprintf("文档%d: %s\n", i+1, result.Title))
+	for i, result := range ordered {
+		contextBuilder.WriteString(fmt.Sprintf("文档%d: %s（%s）\n", i+1, result.Title, citationSource(result)))
 		contextBuilder.WriteString(fmt.Sprintf("内容: %s\n\n", result.Content))
 	}
 
-	ctx := contextBuilder.String()
+	// 问题中出现的术语附上释义，帮助模型理解行话
+	if terms := r.settings().glossary.Match(question); len(terms) > 0 {
+		contextBuilder.WriteString("术语表：\n")
+		contextBuilder.WriteString(formatGlossary(terms))
+	}
 
-	// 3. 调用DeepSeek生成答案
-	resp, err := r.openAIClient.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
-		Model: r.config.DeepSeekModel,
+	if model == "" {
+		model = r.config.DeepSeekModel
+	}
+
+	return openai.ChatCompletionRequest{
+		Model: model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
-				Content: "你是一个严谨的AI助手，必须严格基于提供的上下文信息回答问题。如果上下文信息不足，请如实告知。不要编造上下文之外的信息。",
+				Content: r.settings().SystemPrompt,
 			},
 			{
 				Role:    openai.ChatMessageRoleUser,
-				Content: fmt.Sprintf("上下文信息：\n%s\n\n问题：%s\n\n请基于上述上下文信息回答问题：", ctx, question),
+				Content: fmt.Sprintf("上下文信息：\n%s\n\n问题：%s%s\n\n请基于上述上下文信息回答问题：", contextBuilder.String(), question, r.config.Dates.questionNote(question, time.Now())),
 			},
 		},
 		Temperature: 0.1,
 		MaxTokens:   500,
-	})
+	}
+}
 
-	elapsed := time.Since(start).Seconds()
+// 搜索相关文档；配置了多索引联合检索时跨索引检索并按权重合并
+func (r *RAGSystem) SearchDocuments(ctx context.Context, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	results, _, err := r.searchDocuments(ctx, query, topK, opts)
+	return results, err
+}
 
+// 同SearchDocuments，另外返回实际使用的检索条件：档位、特性、路由到的分类和实体
+func (r *RAGSystem) searchDocuments(ctx context.Context, query string, topK int, opts searchOptions) ([]SearchResult, searchOptions, error) {
+	opts, err := opts.resolve(r.settings().Retrieval.Profile)
 	if err != nil {
-		return "", elapsed, results, err
+		return nil, opts, err
 	}
-
-	if len(resp.Choices) == 0 {
-		return "", elapsed, results, fmt.Errorf("未收到回答")
+	// 相对时间按原问题解析，改写后的问题可能已不含原来的表述
+	if opts.Period == nil {
+		if opts.Period = r.config.Dates.relativePeriod(query, time.Now()); opts.Period != nil {
+			fmt.Printf("🗓️  按时间范围检索: %s（%s）\n", opts.Period.Phrase, opts.Period)
+		}
 	}
-
-	return resp.Choices[0].Message.Content, elapsed, results, nil
+	if opts.Features == nil {
+		opts.Features = r.resolveFeatures(query, opts.Category)
+	}
+	query = r.searchQuery(ctx, query)
+	if opts.Category == "" {
+		route := opts.Routed
+		if route == "" {
+			route = r.routeQuestion(ctx, query)
+		}
+		if opts.Category = route; opts.Category != "" {
+			results, err := r.searchScope(ctx, query, topK, opts)
+			if err != nil || len(results) > 0 {
+				return results, opts, err
+			}
+			// 路由到的分类下没有命中，退回全库检索
+			opts.Category = ""
+		}
+	}
+	if opts.Entity == "" {
+		if opts.Entity = r.entities.route(query); opts.Entity != "" {
+			results, err := r.searchScope(ctx, query, topK, opts)
+			if err != nil || len(results) > 0 {
+				return results, opts, err
+			}
+			// 没有分块提及问题中的实体，不按实体过滤
+			opts.Entity = ""
+		}
+	}
+	results, err := r.searchScope(ctx, query, topK, opts)
+	return results, opts, err
 }
 
-// 搜索相关文档 - 使用ElasticSearch 8.x 向量搜索
-func (r *RAGSystem) SearchDocuments(query string, topK int) ([]SearchResult, error) {
-	indexName := r.config.IndexName
-
-	// 生成查询向量
-	queryVector := r.generateSimpleVector(query)
-
-	// 方法1：使用ElasticSearch 8.x的script_score进行向量搜索
-	// 将float32转换为float64
-	vector64 := make([]float64, len(queryVector))
-	for i, v := range queryVector {
-		vector64[i] = float64(v)
+// 在配置的集合或联合索引中检索
+func (r *RAGSystem) searchTargets(ctx context.Context, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	if len(r.config.Federation) > 0 {
+		return r.federatedSearch(ctx, query, topK, opts)
 	}
+	return r.searchIndex(ctx, r.config.IndexName, query, topK, opts)
+}
 
-	// 构建搜索查询
-	searchQuery := map[string]interface{}{
-		"size": topK,
-		"query": map[string]interface{}{
-			"script_score": map[string]interface{}{
-				"query": map[string]interface{}{
-					"match_all": map[string]interface{}{},
-				},
-				"script": map[string]interface{}{
-					"source": "cosineSimilarity(params.query_vector, 'vector') + 1.0",
-					"params": map[string]interface{}{
-						"query_vector": vector64,
-					},
-				},
-			},
-		},
-		"_source": []string{"title", "content"},
+// 精度档位对应的kNN num_candidates；accurate档位返回0，使用script_score精确检索
+func esNumCandidates(opts searchOptions, topK int) int {
+	if opts.NumCandidates > 0 {
+		return max(opts.NumCandidates, topK)
 	}
-
-	// 执行搜索
-	searchJSON, _ := json.Marshal(searchQuery)
-	res, err := r.elasticClient.Search(
-		r.elasticClient.Search.WithIndex(indexName),
-		r.elasticClient.Search.WithBody(bytes.NewReader(searchJSON)),
-		r.elasticClient.Search.WithTrackTotalHits(false),
-	)
-	if err != nil {
-		// 如果向量搜索失败，尝试混合搜索
-		return r.HybridSearch(query, topK)
+	switch opts.Profile {
+	case accuracyFast:
+		return max(2*topK, 20)
+	case accuracyBalanced:
+		return max(10*topK, 100)
 	}
-	defer res.Body.Close()
+	return 0
+}
 
-	if res.IsError() {
-		// 尝试混合搜索作为降级策略
-		return r.HybridSearch(query, topK)
+// 在单个索引的一个向量空间中搜索，相同的查询向量和过滤条件在RETRIEVAL_CACHE_TTL_SECONDS内直接使用缓存的结果。
+// 分页检索和降级为文本检索的结果不缓存；问题按拼音匹配标题时查询文本也计入缓存键
+func (r *RAGSystem) searchSpace(ctx context.Context, indexName, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	if r.retrievals == nil || opts.Page != nil {
+		return r.searchStore(ctx, indexName, query, topK, opts)
 	}
-
-	// 解析搜索结果
-	var searchResponse map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&searchResponse); err != nil {
-		return nil, fmt.Errorf("解析搜索结果失败: %w", err)
+	text := ""
+	if r.config.Pinyin.Enabled && looksLikePinyin(query) {
+		text = query
 	}
-
-	var results []SearchResult
-
-	// 检查是否有命中结果
-	hits, ok := searchResponse["hits"].(map[string]interface{})
-	if !ok {
+	key := retrievalKey(indexName, r.generateSimpleVector(query), text, topK, opts)
+	if results, ok := r.retrievals.Get(key); ok {
+		fmt.Printf("⚡ 命中检索缓存: %s（%d条）\n", indexName, len(results))
 		return results, nil
 	}
 
-	hitsList, ok := hits["hits"].([]interface{})
-	if !ok {
-		return results, nil
+	// 单独记录本次检索的降级，再转交给调用方
+	degraded := &degradation{}
+	tracked := opts
+	tracked.Degraded = degraded
+	results, err := r.searchStore(ctx, indexName, query, topK, tracked)
+	tiers := degraded.Tiers()
+	opts.Degraded.mark(tiers...)
+	if err == nil && len(tiers) == 0 {
+		r.retrievals.Put(key, results)
 	}
+	return results, err
+}
 
-	for _, hit := range hitsList {
-		hitMap, ok := hit.(map[string]interface{})
-		if !ok {
-			continue
+// 在单个索引中搜索 - 使用ElasticSearch 8.x 向量搜索
+func (r *RAGSystem) searchStore(ctx context.Context, indexName, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	filters := append(categoryFilters(opts.Category), entityFilters(opts.Entity)...)
+	filters = append(filters, periodFilters(opts.Period)...)
+	if opts.Language != "" {
+		filters = append(filters, termQuery("meta."+languageKey+".keyword", opts.Language))
+	}
+	filters = append(filters, exclusionFilters(opts)...)
+	filters = append(filters, embeddingFilters(opts.EmbeddingModel, r.namespaceEmbeddings.names())...)
+
+	// 问题像拼音时先按拼音匹配标题，没有命中或失败时继续向量检索；分页检索不走拼音
+	if r.config.Pinyin.Enabled && opts.Page == nil && looksLikePinyin(query) {
+		results, err := r.pinyinSearchIndex(ctx, indexName, query, topK, filters)
+		if err == nil && len(results) > 0 {
+			return results, nil
 		}
-
-		// 获取分数
-		score, ok := hitMap["_score"].(float64)
-		if !ok {
-			score = 0
+		if err != nil {
+			fmt.Printf("⚠️  %v\n", err)
 		}
+	}
 
-		// 计算相似度分数（归一化）
-		normalizedScore := score / 2.0 // cosineSimilarity返回-1到1，+1后为0-2
-		if normalizedScore > 1.0 {
-			normalizedScore = 1.0
-		}
+	// 生成查询向量
+	queryVector, err := r.embedWith(ctx, opts.EmbeddingModel, query)
+	if err != nil {
+		return nil, fmt.Errorf("问题向量化失败: %w", err)
+	}
 
-		// 获取源文档
-		source, ok := hitMap["_source"].(map[string]interface{})
-		if !ok {
-			continue
+	// 方法1：使用ElasticSearch 8.x的script_score精确向量搜索，fast/balanced档位改用kNN近似搜索
+	req := chunkSearchRequest(topK)
+	// 分页检索时kNN需取到offset+topK个近邻
+	k := topK
+	if opts.Page != nil {
+		k += opts.Page.Offset
+	}
+	// script_score返回cosineSimilarity+1，范围0-2；kNN的cosine分数已是(1+cos)/2
+	scoreScale := 2.0
+	if candidates := esNumCandidates(opts, k); candidates > 0 {
+		req.Size = nil
+		req.Knn = []types.KnnSearch{knnSearch(embeddingField(opts.EmbeddingModel), queryVector, k, candidates, filters)}
+		scoreScale = 1
+	} else {
+		scriptQuery, err := scriptScoreQuery(filters, embeddingField(opts.EmbeddingModel), queryVector)
+		if err != nil {
+			return nil, fmt.Errorf("构建搜索请求失败: %w", err)
 		}
+		req.Query = scriptQuery
+	}
+	if opts.Page != nil {
+		applySearchPage(req, opts.Page, topK)
+	}
 
-		// 提取标题和内容
-		title, _ := source["title"].(string)
-		content, _ := source["content"].(string)
+	// 按主备状态选择读节点
+	esClient, primary := r.readClient()
 
-		results = append(results, SearchResult{
-			Title:   title,
-			Content: content,
-			Score:   normalizedScore,
-		})
+	// 注入的故障同样走混合搜索降级
+	if err := r.faults.inject(ctx, faultTargetStore, "ES向量搜索"); err != nil {
+		r.recordRead(primary, err)
+		if opts.Page != nil {
+			return nil, storeError(ctx, "分页检索失败", err)
+		}
+		return r.keywordFallback(ctx, indexName, query, topK, opts)
+	}
 
-		// 调试输出
-		fmt.Printf("找到文档: Title=%s, Score=%.2f\n", title, normalizedScore)
+	// 执行搜索
+	results, err := searchChunks(ctx, esClient, indexName, req, scoreScale, opts.Page)
+	if err != nil {
+		// 如果向量搜索失败，尝试混合搜索作为降级策略；请求已取消时不再降级，
+		// 分页检索也不降级，否则各页的排序依据不一致
+		r.recordRead(primary, readFailure(err))
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if opts.Page != nil {
+			return nil, storeError(ctx, "分页检索失败", err)
+		}
+		return r.keywordFallback(ctx, indexName, query, topK, opts)
+	}
+	r.recordRead(primary, nil)
+	if opts.Features[featureRRF] && opts.Page == nil {
+		results = r.fuseTextResults(ctx, indexName, query, topK, filters, results)
 	}
 
+	// 调试输出
+	for _, result := range results {
+		fmt.Printf("找到文档: Title=%s, Score=%.2f\n", result.Title, result.Score)
+	}
 	return results, nil
 }
 
+// 向量搜索失败时改用文本搜索，成功时记录keyword降级档位
+func (r *RAGSystem) keywordFallback(ctx context.Context, indexName, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	results, err := r.hybridSearchIndex(ctx, indexName, query, topK)
+	if err == nil {
+		opts.Degraded.mark(tierKeyword)
+	}
+	// 文本搜索不带过滤条件，排除条件和时间范围在结果上补做
+	return opts.dropOutOfPeriod(opts.dropExcluded(results)), err
+}
+
 // 混合搜索：向量搜索 + 文本搜索
 func (r *RAGSystem) HybridSearch(query string, topK int) ([]SearchResult, error) {
-	indexName := r.config.IndexName
+	return r.hybridSearchIndex(context.Background(), r.config.IndexName, query, topK)
+}
+
+func (r *RAGSystem) hybridSearchIndex(ctx context.Context, indexName, query string, topK int) ([]SearchResult, error) {
 
 	// 方法2：文本搜索（降级策略）
-	searchQuery := map[string]interface{}{
-		"size": topK,
-		"query": map[string]interface{}{
-			"multi_match": map[string]interface{}{
-				"query":    query,
-				"fields":   []string{"title", "content"},
-				"type":     "best_fields",
-				"operator": "and",
-			},
-		},
-		"_source": []string{"title", "content"},
+	req := chunkSearchRequest(topK)
+	req.Query = multiMatchQuery(query, "title", "content")
+
+	esClient, primary := r.readClient()
+
+	if err := r.faults.inject(ctx, faultTargetStore, "ES混合搜索"); err != nil {
+		r.recordRead(primary, err)
+		return nil, storeError(ctx, "混合搜索失败", err)
 	}
 
-	searchJSON, _ := json.Marshal(searchQuery)
-	res, err := r.elasticClient.Search(
-		r.elasticClient.Search.WithIndex(indexName),
-		r.elasticClient.Search.WithBody(bytes.NewReader(searchJSON)),
-	)
+	// 文本相关度分数除以100归一化
+	results, err := searchChunks(ctx, esClient, indexName, req, 100, nil)
 	if err != nil {
-		return nil, fmt.Errorf("混合搜索失败: %w", err)
+		r.recordRead(primary, readFailure(err))
+		return nil, storeError(ctx, "混合搜索失败", err)
 	}
-	defer res.Body.Close()
+	r.recordRead(primary, nil)
+	return results, nil
+}
 
-	if res.IsError() {
-		return nil, fmt.Errorf("混合搜索错误: %s", res.String())
+// 选择读请求使用的客户端，第二个返回值表示是否为主节点
+func (r *RAGSystem) readClient() (*elasticsearch.TypedClient, bool) {
+	if r.replicaTyped != nil && r.failover.useReplica() {
+		return r.replicaTyped, false
 	}
+	return r.typedClient, true
+}
 
-	// 解析搜索结果
-	var searchResponse map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&searchResponse); err != nil {
-		return nil, fmt.Errorf("解析混合搜索结果失败: %w", err)
+// 记录主节点读请求结果，用于判断是否切换
+func (r *RAGSystem) recordRead(primary bool, err error) {
+	if primary {
+		r.failover.record(err)
 	}
+}
 
-	var results []SearchResult
-
-	// 检查是否有命中结果
-	hits, ok := searchResponse["hits"].(map[string]interface{})
-	if !ok {
-		return results, nil
+// 只有服务端错误才视为节点故障，4xx属于请求本身的问题
+func readError(statusCode int) error {
+	if statusCode >= 500 {
+		return fmt.Errorf("ElasticSearch返回状态码 %d", statusCode)
 	}
+	return nil
+}
 
-	hitsList, ok := hits["hits"].([]interface{})
-	if !ok {
-		return results, nil
+func (r *RAGSystem) Close() {
+	r.failover.Close()
+	r.traces.Close()
+	if r.replicaTyped != nil {
+		_ = r.replicaTyped.Close(context.Background())
 	}
-
-	for _, hit := range hitsList {
-		hitMap, ok := hit.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// 获取分数
-		score, ok := hitMap["_score"].(float64)
-		if !ok {
-			score = 0
-		}
-
-		// 归一化处理
-		normalizedScore := score / 100.0
-		if normalizedScore > 1.0 {
-			normalizedScore = 1.0
-		}
-
-		// 获取源文档
-		source, ok := hitMap["_source"].(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// 提取标题和内容
-		title, _ := source["title"].(string)
-		content, _ := source["content"].(string)
-
-		results = append(results, SearchResult{
-			Title:   title,
-			Content: content,
-			Score:   normalizedScore,
-		})
+	if r.typedClient != nil {
+		_ = r.typedClient.Close(context.Background())
+	}
+	if r.elasticClient != nil {
+		_ = r.elasticClient.Close(context.Background())
 	}
-	return results, nil
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const maintenanceAction = "forcemerge"
+
+func (r *RAGSystem) storeTarget() string {
+	return r.config.IndexName
+}
+
+// 写入（索引和删除）占读写操作的比例超过该值时索引不是以读为主，不合并到单段
+const readMostlyWriteShare = 0.2
+
+// 索引的读写统计，计数从分片启动时开始累计
+type indexActivity struct {
+	Indexing struct {
+		IndexCurrent int64 `json:"index_current"`
+		IndexTotal   int64 `json:"index_total"`
+		DeleteTotal  int64 `json:"delete_total"`
+	} `json:"indexing"`
+	Merges struct {
+		Current int64 `json:"current"`
+	} `json:"merges"`
+	Search struct {
+		QueryTotal int64 `json:"query_total"`
+	} `json:"search"`
+}
+
+// 写入占读写操作的比例
+func (a indexActivity) writeShare() float64 {
+	writes := a.Indexing.IndexTotal + a.Indexing.DeleteTotal
+	if writes == 0 {
+		return 0
+	}
+	return float64(writes) / float64(writes+a.Search.QueryTotal)
+}
+
+// 读取索引全部分片的读写统计
+func (r *RAGSystem) indexActivity(ctx context.Context) (indexActivity, error) {
+	res, err := r.elasticClient.Indices.Stats(
+		r.elasticClient.Indices.Stats.WithContext(ctx),
+		r.elasticClient.Indices.Stats.WithIndex(r.config.IndexName),
+		r.elasticClient.Indices.Stats.WithMetric("indexing", "merge", "search"),
+	)
+	if err != nil {
+		return indexActivity{}, fmt.Errorf("查询索引统计失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return indexActivity{}, fmt.Errorf("查询索引统计错误: %s", res.String())
+	}
+	var body struct {
+		All struct {
+			Total indexActivity `json:"total"`
+		} `json:"_all"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return indexActivity{}, fmt.Errorf("解析索引统计失败: %w", err)
+	}
+	return body.All.Total, nil
+}
+
+// 索引上有进行中的写入或段合并（包括其他进程的入库），或集群有排队的任务（创建索引、更新mapping等）时不合并
+func (r *RAGSystem) storeActivity(ctx context.Context) (string, error) {
+	activity, err := r.indexActivity(ctx)
+	if err != nil {
+		return "", err
+	}
+	if activity.Indexing.IndexCurrent > 0 {
+		return fmt.Sprintf("%d 个写入正在进行", activity.Indexing.IndexCurrent), nil
+	}
+	if activity.Merges.Current > 0 {
+		return fmt.Sprintf("%d 个段合并正在进行", activity.Merges.Current), nil
+	}
+
+	res, err := r.elasticClient.Cluster.PendingTasks(r.elasticClient.Cluster.PendingTasks.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("查询集群任务失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", fmt.Errorf("查询集群任务错误: %s", res.String())
+	}
+	var pending struct {
+		Tasks []json.RawMessage `json:"tasks"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&pending); err != nil {
+		return "", fmt.Errorf("解析集群任务失败: %w", err)
+	}
+	if len(pending.Tasks) > 0 {
+		return fmt.Sprintf("集群有 %d 个排队的任务", len(pending.Tasks)), nil
+	}
+	return "", nil
+}
+
+// 强制合并索引的段，请求在合并完成后返回。合并到单段后新写入会产生新的小段，且大段中删除的文档很久才能清除，
+// 只适合以读为主的索引：写入占比超过readMostlyWriteShare时只清除已删除的文档，不合并到单段
+func (r *RAGSystem) optimizeStore(ctx context.Context) (string, error) {
+	segments := max(r.config.Maintenance.MaxSegments, 1)
+	note := ""
+	if segments == 1 {
+		activity, err := r.indexActivity(ctx)
+		if err != nil {
+			return "", err
+		}
+		if share := activity.writeShare(); share > readMostlyWriteShare {
+			note = fmt.Sprintf("写入占读写操作的 %.0f%%，不是以读为主", share*100)
+		}
+	}
+	target := r.elasticClient.Indices.Forcemerge.WithMaxNumSegments(segments)
+	if note != "" {
+		target = r.elasticClient.Indices.Forcemerge.WithOnlyExpungeDeletes(true)
+	}
+	res, err := r.elasticClient.Indices.Forcemerge(
+		r.elasticClient.Indices.Forcemerge.WithIndex(r.config.IndexName),
+		target,
+		r.elasticClient.Indices.Forcemerge.WithContext(ctx),
+	)
+	if err != nil {
+		return "", fmt.Errorf("强制合并失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", fmt.Errorf("强制合并错误: %s", res.String())
+	}
+	var body struct {
+		Shards struct {
+			Total      int `json:"total"`
+			Successful int `json:"successful"`
+		} `json:"_shards"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("解析强制合并响应失败: %w", err)
+	}
+	if note != "" {
+		return fmt.Sprintf("%s，%d/%d 个分片只清除已删除的文档", note, body.Shards.Successful, body.Shards.Total), nil
+	}
+	return fmt.Sprintf("%d/%d 个分片合并到 %d 段", body.Shards.Successful, body.Shards.Total, segments), nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 分批总结配置：范围很广的问题（"总结一下知识库里关于Go的内容"）涉及的分块远多于TOP_K，
+// 改为检索最多SUMMARIZE_MAX_CHUNKS个分块，按文档分组切成不超过CONTEXT_TOKEN_BUDGET的批次，
+// 并发（SUMMARIZE_CONCURRENCY）提炼每批中与问题相关的要点，再由各批要点综合出最终回答。
+// 请求mode=summarize时总是使用；SUMMARIZE_AUTO=true时，问题含有"总结""概括"等词且涉及至少SUMMARIZE_MIN_DOCS篇文档时自动使用
+type SummarizeConfig struct {
+	Auto        bool
+	MaxChunks   int
+	MinDocs     int
+	Concurrency int
+}
+
+func loadSummarizeConfig() SummarizeConfig {
+	return SummarizeConfig{
+		Auto:        getEnvAsBool("SUMMARIZE_AUTO", true),
+		MaxChunks:   getEnvAsInt("SUMMARIZE_MAX_CHUNKS", 40),
+		MinDocs:     getEnvAsInt("SUMMARIZE_MIN_DOCS", 4),
+		Concurrency: getEnvAsInt("SUMMARIZE_CONCURRENCY", 4),
+	}
+}
+
+// 要求总结、盘点的问题
+var broadQuestionPattern = regexp.MustCompile(`(?i)总结|概括|汇总|归纳|梳理|综述|盘点|所有|全部|summari[sz]e|overview`)
+
+// 某批分块中没有相关内容时提炼步骤的回复
+const noRelevantPoints = "无"
+
+// 分批总结后综合回答；handled为false时不适用，调用方按常规流程回答。
+// 检索失败时也返回false，由常规流程按配置降级
+func (r *RAGSystem) answerBySummaries(ctx context.Context, question string, opts searchOptions) (string, []SearchResult, bool, error) {
+	config := r.config.Summarize
+	if opts.Extractive || config.MaxChunks <= 0 {
+		return "", nil, false, nil
+	}
+	if !opts.Summarize && (!config.Auto || !broadQuestionPattern.MatchString(question)) {
+		return "", nil, false, nil
+	}
+
+	candidates, results, used, err := r.searchCandidates(ctx, question, config.MaxChunks, opts)
+	if err != nil {
+		return "", nil, false, nil
+	}
+	docs := make(map[string]bool)
+	for _, result := range results {
+		docs[result.DocID] = true
+	}
+	if !opts.Summarize && len(docs) < config.MinDocs {
+		return "", nil, false, nil
+	}
+	r.recordTrace(question, used, "summarize", config.MaxChunks, candidates, results)
+	results = append(results, opts.History...)
+
+	if answer, handled, err := r.applyPolicy(ctx, question, opts, results); handled {
+		return answer, nil, true, err
+	}
+	if len(results) == 0 {
+		return "", nil, true, ErrNoRelevantDocs
+	}
+
+	batches := r.summaryBatches(ctx, question, results)
+	fmt.Printf("🗂️  分批总结: %d 个分块（%d 篇文档），分为 %d 批\n", len(results), len(docs), len(batches))
+	if err := llmError(ctx, r.faults.inject(ctx, faultTargetLLM, "DeepSeek 分批总结")); err != nil {
+		return "", results, true, err
+	}
+	// 只有一批时直接回答
+	if len(batches) == 1 {
+		answer, err := r.completeWithLength(withReasoning(ctx, opts.Reasoning), r.ragChatRequest(question, batches[0], opts.Model), opts.Length)
+		if err != nil {
+			return "", results, true, err
+		}
+		return appendAttribution(answer, results), results, true, nil
+	}
+
+	points, err := r.summarizeBatches(ctx, question, batches, opts.Model)
+	if err != nil {
+		return "", results, true, err
+	}
+	if len(points) == 0 {
+		return "", results, true, ErrNoRelevantDocs
+	}
+	answer, err := r.completeWithLength(withReasoning(ctx, opts.Reasoning), r.synthesisChatRequest(question, points, opts.Model), opts.Length)
+	if err != nil {
+		return "", results, true, err
+	}
+	return appendAttribution(answer, results), results, true, nil
+}
+
+// 按文档分组后依次装批，每批不超过上下文预算；超出预算的单个分块先按预算重新切分
+func (r *RAGSystem) summaryBatches(ctx context.Context, question string, results []SearchResult) [][]SearchResult {
+	budget := r.settings().Retrieval.TokenBudget
+	var batches [][]SearchResult
+	var batch []SearchResult
+	used := 0
+	for _, result := range orderContext(r.fitOversized(ctx, question, results), orderGroupByDocument) {
+		tokens := r.tokens.Count(result.Content)
+		if len(batch) > 0 && used+tokens > budget {
+			batches = append(batches, batch)
+			batch, used = nil, 0
+		}
+		batch = append(batch, result)
+		used += tokens
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// 并发提炼每批分块的要点，按批次顺序返回；没有相关内容的批次跳过，部分批次失败时只用成功的批次，全部失败时返回错误
+func (r *RAGSystem) summarizeBatches(ctx context.Context, question string, batches [][]SearchResult, model string) ([]string, error) {
+	points := make([]string, len(batches))
+	errs := make([]error, len(batches))
+	sem := make(chan struct{}, max(r.config.Summarize.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch []SearchResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			points[i], errs[i] = r.completeAnswer(ctx, r.summaryChatRequest(question, batch, model))
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var kept []string
+	var lastErr error
+	failed := 0
+	for i, point := range points {
+		if errs[i] != nil {
+			fmt.Printf("⚠️  第 %d 批总结失败: %v\n", i+1, errs[i])
+			lastErr = errs[i]
+			failed++
+			continue
+		}
+		point = strings.TrimSpace(point)
+		if point != "" && point != noRelevantPoints {
+			kept = append(kept, point)
+		}
+	}
+	if failed == len(batches) {
+		return nil, lastErr
+	}
+	return kept, nil
+}
+
+// 提炼一批分块中与问题相关的要点
+func (r *RAGSystem) summaryChatRequest(question string, batch []SearchResult, model string) openai.ChatCompletionRequest {
+	request := r.ragChatRequest(question, batch, model)
+	content := request.Messages[1].Content
+	content = strings.TrimSuffix(content, "请基于上述上下文信息回答问题：")
+	request.Messages[1].Content = content + fmt.Sprintf("请从上述文档中提炼与问题相关的要点，逐条列出并注明出自哪篇文档（写文档标题）；没有相关内容时只回答\"%s\"：", noRelevantPoints)
+	return request
+}
+
+// 由各批要点综合出最终回答
+func (r *RAGSystem) synthesisChatRequest(question string, points []string, model string) openai.ChatCompletionRequest {
+	var builder strings.Builder
+	builder.WriteString("以下是从知识库中分批提炼的要点：\n\n")
+	for i, point := range points {
+		builder.WriteString(fmt.Sprintf("第%d批：\n%s\n\n", i+1, point))
+	}
+	if model == "" {
+		model = r.config.DeepSeekModel
+	}
+	return openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: r.settings().SystemPrompt},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("%s问题：%s\n\n请综合上述要点回答问题，合并重复的内容，按主题分条组织，保留文档标题作为出处：", builder.String(), question),
+			},
+		},
+		Temperature: 0.1,
+		MaxTokens:   1000,
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "encoding/json"
+
+// 写入命名空间模型向量字段的分块：向量不写入vector，而是写入模型的字段
+type namespacedChunk struct {
+	Document
+	field string
+}
+
+func (c namespacedChunk) MarshalJSON() ([]byte, error) {
+	vector := c.Vector
+	c.Document.Vector = nil
+	data, err := json.Marshal(c.Document)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(map[string][]float32{c.field: vector})
+	if err != nil {
+		return nil, err
+	}
+	// 两个JSON对象合并为一个，分块至少有id字段
+	return append(append(data[:len(data)-1], ','), encoded[1:]...), nil
+}
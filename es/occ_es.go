@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/mget"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// 读取文档首个分块元数据中的修订号；GET按ID读取是实时的，不受索引刷新间隔影响
+func (r *RAGSystem) currentRevision(ctx context.Context, docID string) (int, bool, error) {
+	res, err := r.typedClient.Get(r.config.IndexName, docID+"#0").SourceIncludes_("meta").Do(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("查询文档修订号失败: %w", err)
+	}
+	if !res.Found {
+		return 0, false, nil
+	}
+	meta, err := sourceMeta(docID, res.Source_)
+	if err != nil {
+		return 0, false, err
+	}
+	return metaRevision(meta), true, nil
+}
+
+// 批量读取文档首个分块的修订号，mget同样是实时的；不存在的文档不在结果中
+func (r *RAGSystem) currentRevisions(ctx context.Context, docIDs []string) (map[string]int, error) {
+	revisions := make(map[string]int, len(docIDs))
+	if len(docIDs) == 0 {
+		return revisions, nil
+	}
+	ids := make([]string, len(docIDs))
+	for i, docID := range docIDs {
+		ids[i] = docID + "#0"
+	}
+	res, err := r.typedClient.Mget().Index(r.config.IndexName).Request(&mget.Request{Ids: ids}).SourceIncludes_("meta").Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询文档修订号失败: %w", err)
+	}
+	for i, item := range res.Docs {
+		hit, ok := item.(*types.GetResult)
+		if !ok {
+			return nil, fmt.Errorf("查询文档 %s 修订号失败: %v", docIDs[i], item)
+		}
+		if !hit.Found {
+			continue
+		}
+		meta, err := sourceMeta(docIDs[i], hit.Source_)
+		if err != nil {
+			return nil, err
+		}
+		revisions[docIDs[i]] = metaRevision(meta)
+	}
+	return revisions, nil
+}
+
+// 以首个分块的_seq_no和_primary_term作条件把修订号改为revision+1：读到的修订号不是revision，
+// 或读取之后分块被其他实例或写入方改写过时返回errRevisionConflict。新文档没有可作条件的分块，不做校验
+func (r *RAGSystem) claimRevision(ctx context.Context, docID string, revision int, exists bool) error {
+	if !exists {
+		return nil
+	}
+	res, err := r.typedClient.Get(r.config.IndexName, docID+"#0").SourceIncludes_("meta").Do(ctx)
+	if err != nil {
+		return fmt.Errorf("查询文档修订号失败: %w", err)
+	}
+	if !res.Found || res.SeqNo_ == nil || res.PrimaryTerm_ == nil {
+		return errRevisionConflict
+	}
+	meta, err := sourceMeta(docID, res.Source_)
+	if err != nil {
+		return err
+	}
+	if metaRevision(meta) != revision {
+		return errRevisionConflict
+	}
+	_, err = r.typedClient.Update(r.config.IndexName, docID+"#0").
+		IfSeqNo(strconv.FormatInt(*res.SeqNo_, 10)).
+		IfPrimaryTerm(strconv.FormatInt(*res.PrimaryTerm_, 10)).
+		Doc(map[string]interface{}{"meta": map[string]interface{}{revisionKey: revision + 1}}).
+		Do(ctx)
+	var esErr *types.ElasticsearchError
+	if errors.As(err, &esErr) && esErr.Status == http.StatusConflict {
+		return errRevisionConflict
+	}
+	if err != nil {
+		return fmt.Errorf("更新文档修订号失败: %w", err)
+	}
+	return nil
+}
+
+func sourceMeta(docID string, source json.RawMessage) (map[string]interface{}, error) {
+	var chunk struct {
+		Meta map[string]interface{} `json:"meta"`
+	}
+	if err := json.Unmarshal(source, &chunk); err != nil {
+		return nil, fmt.Errorf("解析文档 %s 元数据失败: %w", docID, err)
+	}
+	return chunk.Meta, nil
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 由接口表生成OpenAPI 3文档
+func openAPISpec() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+
+	errorBody := map[string]interface{}{
+		"description": "错误",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(errorResponse{}), schemas)},
+		},
+	}
+
+	for _, route := range apiRoutes {
+		operation := map[string]interface{}{
+			"operationId": route.Name,
+			"summary":     route.Summary,
+			"tags":        []string{route.Tag},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "成功",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(route.Response), schemas)},
+					},
+				},
+				"default": errorBody,
+			},
+		}
+		if route.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(route.Request), schemas)},
+				},
+			}
+		}
+		var parameters []interface{}
+		for _, param := range route.Query {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.Name,
+				"in":          "query",
+				"description": param.Description,
+				"required":    param.Required,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		headers := route.Headers
+		if route.Idempotent {
+			// Go客户端通过WithIdempotencyKey设置，不作为方法参数
+			headers = append(headers[:len(headers):len(headers)], apiParam{Name: idempotencyKeyHeader, Description: "幂等键，重试时带上同一个键会返回首次请求的响应（响应头Idempotent-Replayed: true），不会重复执行"})
+		}
+		for _, param := range headers {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.Name,
+				"in":          "header",
+				"description": param.Description,
+				"required":    param.Required,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if route.Admin {
+			operation["security"] = []interface{}{map[string]interface{}{"adminToken": []interface{}{}}}
+		}
+		paths[route.Path] = map[string]interface{}{strings.ToLower(route.Method): operation}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "RAG Demo API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"adminToken": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "管理接口的ADMIN_TOKEN"},
+			},
+		},
+	}
+}
+
+// 根据Go类型生成JSON Schema，结构体登记到components中并返回引用
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		name := exportedName(t.Name())
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = nil // 先占位，防止递归
+			properties := make(map[string]interface{})
+			var required []string
+			for _, field := range jsonFields(t) {
+				properties[field.name] = schemaOf(field.typ, schemas)
+				if !field.omitempty {
+					required = append(required, field.name)
+				}
+			}
+			schema := map[string]interface{}{"type": "object", "properties": properties}
+			if len(required) > 0 {
+				schema["required"] = required
+			}
+			schemas[name] = schema
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+type jsonField struct {
+	goName    string
+	name      string
+	typ       reflect.Type
+	omitempty bool
+}
+
+// 结构体中参与JSON序列化的字段
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, jsonField{goName: field.Name, name: name, typ: field.Type, omitempty: strings.Contains(options, "omitempty")})
+	}
+	return fields
+}
+
+func exportedName(name string) string {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return name
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// 请求头在客户端方法中的参数名，例如 If-Match 对应 ifMatch
+func headerParamName(header string) string {
+	parts := strings.Split(header, "-")
+	for i, part := range parts {
+		part = strings.ToLower(part)
+		if i > 0 {
+			part = exportedName(part)
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, "")
+}
+
+// GET /openapi.json
+func (s *apiServer) handleOpenAPI(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, openAPISpec())
+}
+
+// GET /docs：Swagger UI
+func (s *apiServer) handleDocs(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>RAG Demo API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>`)
+}
+
+// openapi命令：导出OpenAPI文档并生成Go客户端
+func runOpenAPI(args []string) error {
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	specPath := fs.String("spec", "openapi.json", "OpenAPI文档输出路径，为空时不导出")
+	clientPath := fs.String("client", "ragclient/client.go", "Go客户端输出路径，为空时不生成")
+	_ = fs.Parse(args)
+
+	if *specPath != "" {
+		data, err := json.MarshalIndent(openAPISpec(), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*specPath, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("写入OpenAPI文档失败: %w", err)
+		}
+		fmt.Printf("✅ 已生成OpenAPI文档: %s\n", *specPath)
+	}
+
+	if *clientPath != "" {
+		source, err := generateClient(filepath.Base(filepath.Dir(*clientPath)))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(*clientPath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(*clientPath, source, 0644); err != nil {
+			return fmt.Errorf("写入客户端失败: %w", err)
+		}
+		fmt.Printf("✅ 已生成Go客户端: %s\n", *clientPath)
+	}
+	return nil
+}
+
+// 由接口表生成Go客户端源码
+func generateClient(pkg string) ([]byte, error) {
+	types := make(map[string]reflect.Type)
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map:
+			collect(t.Elem())
+		case reflect.Struct:
+			if t == reflect.TypeOf(time.Time{}) {
+				return
+			}
+			name := exportedName(t.Name())
+			if _, ok := types[name]; ok {
+				return
+			}
+			types[name] = t
+			for _, field := range jsonFields(t) {
+				collect(field.typ)
+			}
+		}
+	}
+	collect(reflect.TypeOf(errorResponse{}))
+	for _, route := range apiRoutes {
+		if route.Request != nil {
+			collect(reflect.TypeOf(route.Request))
+		}
+		collect(reflect.TypeOf(route.Response))
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`
+// Client 访问RAG Demo服务
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	AdminToken string // 调用管理接口时作为Bearer令牌发送
+}
+
+// New 创建客户端，baseURL例如 http://localhost:8080
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Error 服务端返回的错误，Code为稳定的错误码，可据此决定是否重试
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Retryable  bool
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey 为写入类接口和Ask设置幂等键，重试时使用同一个ctx，服务端返回首次请求的响应而不会重复执行
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if key, ok := ctx.Value(idempotencyKey{}).(string); ok && key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.AdminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AdminToken)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr ErrorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		return &Error{StatusCode: resp.StatusCode, Code: apiErr.Code, Message: apiErr.Error, Retryable: apiErr.Retryable}
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+`)
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n// %s 对应服务端的 %s\n", name, types[name].Name())
+		fmt.Fprintf(&b, "type %s struct {\n", name)
+		for _, field := range jsonFields(types[name]) {
+			tag := field.name
+			if field.omitempty {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s\"`\n", field.goName, goTypeName(field.typ), tag)
+		}
+		b.WriteString("}\n")
+	}
+
+	for _, route := range apiRoutes {
+		fmt.Fprintf(&b, "\n// %s %s（%s %s）\n", route.Name, route.Summary, route.Method, route.Path)
+		var params []string
+		params = append(params, "ctx context.Context")
+		for _, param := range route.Query {
+			params = append(params, param.Name+" string")
+		}
+		for _, param := range route.Headers {
+			params = append(params, headerParamName(param.Name)+" string")
+		}
+		body := "nil"
+		if route.Request != nil {
+			params = append(params, "req "+goTypeName(reflect.TypeOf(route.Request)))
+			body = "req"
+		}
+		response := goTypeName(reflect.TypeOf(route.Response))
+		fmt.Fprintf(&b, "func (c *Client) %s(%s) (*%s, error) {\n", route.Name, strings.Join(params, ", "), response)
+		b.WriteString("\tquery := url.Values{}\n")
+		for _, param := range route.Query {
+			if param.Required {
+				fmt.Fprintf(&b, "\tquery.Set(%q, %s)\n", param.Name, param.Name)
+			} else {
+				fmt.Fprintf(&b, "\tif %s != \"\" {\n\t\tquery.Set(%q, %s)\n\t}\n", param.Name, param.Name, param.Name)
+			}
+		}
+		header := "nil"
+		if len(route.Headers) > 0 {
+			header = "header"
+			b.WriteString("\theader := http.Header{}\n")
+			for _, param := range route.Headers {
+				name := headerParamName(param.Name)
+				fmt.Fprintf(&b, "\tif %s != \"\" {\n\t\theader.Set(%q, %s)\n\t}\n", name, param.Name, name)
+			}
+		}
+		fmt.Fprintf(&b, "\tvar result %s\n", response)
+		fmt.Fprintf(&b, "\tif err := c.do(ctx, %q, %q, query, %s, %s, &result); err != nil {\n\t\treturn nil, err\n\t}\n", route.Method, route.Path, header, body)
+		b.WriteString("\treturn &result, nil\n}\n")
+	}
+
+	imports := []string{"bytes", "context", "encoding/json", "fmt", "net/http", "net/url", "strings"}
+	if strings.Contains(b.String(), "time.Time") {
+		imports = append(imports, "time")
+	}
+	var header bytes.Buffer
+	fmt.Fprintf(&header, "// Code generated by \"go run . openapi\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&header, "// Package %s 是RAG Demo REST API的Go客户端\n", pkg)
+	fmt.Fprintf(&header, "package %s\n\nimport (\n", pkg)
+	for _, imp := range imports {
+		fmt.Fprintf(&header, "\t%q\n", imp)
+	}
+	header.WriteString(")\n")
+	return format.Source(append(header.Bytes(), b.Bytes()...))
+}
+
+// Go类型在客户端代码中的写法
+func goTypeName(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "time.Time"
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + goTypeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + goTypeName(t.Elem())
+	case reflect.Map:
+		return "map[" + goTypeName(t.Key()) + "]" + goTypeName(t.Elem())
+	case reflect.Interface:
+		return "interface{}"
+	case reflect.Struct:
+		return exportedName(t.Name())
+	}
+	return t.Kind().String()
+}
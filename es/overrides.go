@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 固定回答配置：OVERRIDES_DB不为空时serve从SQLite的overrides表加载人工撰写的固定回答，命中的问题不检索、不调用大模型，
+// 原样返回answer，用于必须逐字呈现的法律、合规文本。exact：归一化后与pattern相同；regex：问题匹配正则pattern；
+// semantic：与pattern的向量余弦相似度不低于OVERRIDE_SIMILARITY。按exact、regex、semantic的顺序匹配，同类按ID顺序，
+// 先于FAQ和答案缓存匹配，通过 /admin/overrides 增删后立即生效
+type OverrideConfig struct {
+	DB         string
+	Similarity float64 // semantic匹配的余弦相似度阈值
+}
+
+func loadOverrideConfig() OverrideConfig {
+	return OverrideConfig{
+		DB:         getEnv("OVERRIDES_DB", ""),
+		Similarity: getEnvAsFloat("OVERRIDE_SIMILARITY", 0.95),
+	}
+}
+
+const (
+	overrideMatchExact    = "exact"
+	overrideMatchRegex    = "regex"
+	overrideMatchSemantic = "semantic"
+)
+
+// 命中固定回答时记录在降级档位中，同样不写入答案缓存、不进入人工审核
+const tierOverride = "override"
+
+// 一条固定回答
+type answerOverride struct {
+	ID        int64     `json:"id"`
+	Match     string    `json:"match"`          // exact、regex、semantic
+	Pattern   string    `json:"pattern"`        // exact和semantic为问题，regex为正则表达式
+	Answer    string    `json:"answer"`         // 原样返回的回答
+	Note      string    `json:"note,omitempty"` // 备注，例如文本的出处和审批人
+	CreatedAt time.Time `json:"created_at"`
+
+	re     *regexp.Regexp
+	vector []float32
+}
+
+func (o *answerOverride) validate() error {
+	if o.Pattern == "" || o.Answer == "" {
+		return fmt.Errorf("pattern和answer不能为空")
+	}
+	switch o.Match {
+	case overrideMatchExact, overrideMatchSemantic:
+	case overrideMatchRegex:
+		re, err := regexp.Compile(o.Pattern)
+		if err != nil {
+			return fmt.Errorf("正则表达式无效: %w", err)
+		}
+		o.re = re
+	default:
+		return fmt.Errorf("未知的match: %s，可选 exact、regex、semantic", o.Match)
+	}
+	return nil
+}
+
+// 固定回答，存储在SQLite中，启动时全部加载到内存
+type overrideStore struct {
+	db       *sql.DB
+	config   OverrideConfig
+	embedder embedder
+
+	mu        sync.RWMutex
+	overrides []*answerOverride
+}
+
+var errOverrideNotFound = errors.New("固定回答不存在")
+
+// 未配置OVERRIDES_DB时返回nil
+func openOverrideStore(config OverrideConfig, embedder embedder) (*overrideStore, error) {
+	if config.DB == "" {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite3", config.DB)
+	if err != nil {
+		return nil, fmt.Errorf("打开固定回答库失败: %w", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS overrides (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		match TEXT NOT NULL,
+		pattern TEXT NOT NULL,
+		answer TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		vector TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化固定回答库失败: %w", err)
+	}
+	s := &overrideStore{db: db, config: config, embedder: embedder}
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *overrideStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *overrideStore) load() error {
+	rows, err := s.db.Query(`SELECT id, match, pattern, answer, note, vector, created_at FROM overrides ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("读取固定回答失败: %w", err)
+	}
+	defer rows.Close()
+	var overrides []*answerOverride
+	for rows.Next() {
+		o := &answerOverride{}
+		var vector string
+		var createdAt int64
+		if err := rows.Scan(&o.ID, &o.Match, &o.Pattern, &o.Answer, &o.Note, &vector, &createdAt); err != nil {
+			return fmt.Errorf("读取固定回答失败: %w", err)
+		}
+		if err := o.validate(); err != nil {
+			return fmt.Errorf("固定回答 %d: %w", o.ID, err)
+		}
+		_ = json.Unmarshal([]byte(vector), &o.vector)
+		o.CreatedAt = time.Unix(createdAt, 0)
+		overrides = append(overrides, o)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取固定回答失败: %w", err)
+	}
+	s.mu.Lock()
+	s.overrides = overrides
+	s.mu.Unlock()
+	return nil
+}
+
+// 全部固定回答，按ID排列
+func (s *overrideStore) List() []answerOverride {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]answerOverride, len(s.overrides))
+	for i, o := range s.overrides {
+		list[i] = *o
+	}
+	return list
+}
+
+// 新增固定回答，semantic在写入前向量化pattern，返回带ID的固定回答
+func (s *overrideStore) Add(ctx context.Context, o answerOverride) (answerOverride, error) {
+	if err := o.validate(); err != nil {
+		return o, err
+	}
+	if o.Match == overrideMatchSemantic {
+		vector, err := s.embedder.Embed(ctx, normalizeQuestion(o.Pattern))
+		if err != nil {
+			return o, fmt.Errorf("向量化pattern失败: %w", err)
+		}
+		o.vector = vector
+	}
+	vector, err := json.Marshal(o.vector)
+	if err != nil {
+		return o, err
+	}
+	o.CreatedAt = time.Now()
+	res, err := s.db.Exec(
+		`INSERT INTO overrides (match, pattern, answer, note, vector, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		o.Match, o.Pattern, o.Answer, o.Note, string(vector), o.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return o, fmt.Errorf("写入固定回答失败: %w", err)
+	}
+	if o.ID, err = res.LastInsertId(); err != nil {
+		return o, err
+	}
+	s.mu.Lock()
+	s.overrides = append(s.overrides, &o)
+	s.mu.Unlock()
+	return o, nil
+}
+
+// 删除固定回答，返回被删除的固定回答
+func (s *overrideStore) Delete(id int64) (answerOverride, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, o := range s.overrides {
+		if o.ID != id {
+			continue
+		}
+		if _, err := s.db.Exec(`DELETE FROM overrides WHERE id = ?`, id); err != nil {
+			return *o, fmt.Errorf("删除固定回答失败: %w", err)
+		}
+		s.overrides = append(s.overrides[:i:i], s.overrides[i+1:]...)
+		return *o, nil
+	}
+	return answerOverride{}, errOverrideNotFound
+}
+
+// 问题命中的固定回答；只有semantic需要向量化问题，向量化失败时告警并视为未命中
+func (s *overrideStore) Lookup(ctx context.Context, question string) (*answerOverride, bool) {
+	if s == nil {
+		return nil, false
+	}
+	key := normalizeQuestion(question)
+	s.mu.RLock()
+	overrides := append([]*answerOverride(nil), s.overrides...)
+	s.mu.RUnlock()
+
+	semantic := false
+	for _, o := range overrides {
+		if o.Match == overrideMatchExact && normalizeQuestion(o.Pattern) == key {
+			return o, true
+		}
+		semantic = semantic || o.Match == overrideMatchSemantic
+	}
+	for _, o := range overrides {
+		if o.Match == overrideMatchRegex && o.re.MatchString(question) {
+			return o, true
+		}
+	}
+	if !semantic || s.config.Similarity <= 0 {
+		return nil, false
+	}
+	vector, err := s.embedder.Embed(ctx, key)
+	if err != nil {
+		fmt.Printf("⚠️  固定回答匹配时问题向量化失败: %v\n", err)
+		return nil, false
+	}
+	var best *answerOverride
+	bestSimilarity := s.config.Similarity
+	for _, o := range overrides {
+		if o.Match != overrideMatchSemantic {
+			continue
+		}
+		if similarity := cosineSimilarity(vector, o.vector); similarity >= bestSimilarity {
+			best, bestSimilarity = o, similarity
+		}
+	}
+	return best, best != nil
+}
+
+type overridesResponse struct {
+	Overrides []answerOverride `json:"overrides"`
+}
+
+type deleteOverrideRequest struct {
+	ID int64 `json:"id"`
+}
+
+var errOverridesDisabled = errors.New("未开启固定回答，需配置OVERRIDES_DB")
+
+func (s *apiServer) handleOverrides(w http.ResponseWriter, req *http.Request) {
+	if s.rag.overrides == nil {
+		writeError(w, http.StatusNotFound, errOverridesDisabled)
+		return
+	}
+	writeJSON(w, http.StatusOK, overridesResponse{Overrides: s.rag.overrides.List()})
+}
+
+func (s *apiServer) handleAddOverride(w http.ResponseWriter, req *http.Request) {
+	if s.rag.overrides == nil {
+		writeError(w, http.StatusNotFound, errOverridesDisabled)
+		return
+	}
+	var body answerOverride
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if err := body.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	override, err := s.rag.overrides.Add(req.Context(), body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, override)
+}
+
+func (s *apiServer) handleDeleteOverride(w http.ResponseWriter, req *http.Request) {
+	if s.rag.overrides == nil {
+		writeError(w, http.StatusNotFound, errOverridesDisabled)
+		return
+	}
+	var body deleteOverrideRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	override, err := s.rag.overrides.Delete(body.ID)
+	if errors.Is(err, errOverrideNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, override)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// 分页检索的起始位置：kNN只能在前k个近邻中按from截取；精确检索按(_score, id)排序，
+// 带上一页最后一条的排序值时用search_after续翻，翻页期间写入的分块不会让结果错位
+func applySearchPage(req *search.Request, page *searchPage, size int) {
+	req.Size = &size
+	if req.Knn != nil {
+		req.From = &page.Offset
+		return
+	}
+	req.Sort = []types.SortCombinations{"_score", "id"}
+	if len(page.After) == 0 {
+		req.From = &page.Offset
+		return
+	}
+	after := make([]types.FieldValue, 0, len(page.After))
+	for _, value := range page.After {
+		after = append(after, value)
+	}
+	req.SearchAfter = after
+}
+
+// 记录本页最后一条命中的排序值，作为下一页的search_after
+func recordPageEnd(page *searchPage, last []interface{}) {
+	if page == nil {
+		return
+	}
+	page.next = last
+}
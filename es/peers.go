@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"rag-demo/ragclient"
+)
+
+// 对等实例转发：PEER_INSTANCES=人事制度=http://hr-rag:8080,财务=http://finance-rag:8080 为分类指定负责它的另一个rag-demo实例
+// （另一套语料）。请求的category或问题路由（CLASSIFY_ROUTING，分类体系中须包含这些分类）得到的分类配置了对等实例时，
+// PEER_MODE=relay 把问题转给该实例，原样返回对方的回答和引用；merge 向对方检索（POST /retrieve），对方的分块与本地检索结果
+// 按分数合并后在本地生成回答。引用的index标为 peer:<分类>；relay的回答在降级档位中记为peer，不写入本地答案缓存。
+// 对方不可用时告警并按本地知识库回答；转发的请求带forwarded，对方不再转发，避免实例间循环转发
+type PeerConfig struct {
+	Instances map[string]string // 分类 -> 实例地址
+	Mode      string
+	Timeout   time.Duration
+}
+
+func loadPeerConfig() PeerConfig {
+	config := PeerConfig{
+		Instances: make(map[string]string),
+		Mode:      getEnv("PEER_MODE", peerModeRelay),
+		Timeout:   time.Duration(getEnvAsInt("PEER_TIMEOUT_SECONDS", 30)) * time.Second,
+	}
+	if config.Mode != peerModeRelay && config.Mode != peerModeMerge {
+		fmt.Printf("⚠️  未知的PEER_MODE: %s，使用relay\n", config.Mode)
+		config.Mode = peerModeRelay
+	}
+	for _, item := range splitEnvList("PEER_INSTANCES") {
+		category, url, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(url) == "" {
+			continue
+		}
+		config.Instances[strings.TrimSpace(category)] = strings.TrimSpace(url)
+	}
+	return config
+}
+
+const (
+	peerModeRelay = "relay"
+	peerModeMerge = "merge"
+)
+
+// 转发给对等实例的回答记录在降级档位中，不写入答案缓存、不进入人工审核
+const tierPeer = "peer"
+
+// 分类到对等实例客户端，未配置时为nil
+type peerInstances struct {
+	clients map[string]*ragclient.Client
+	mode    string
+}
+
+func newPeerInstances(config PeerConfig) *peerInstances {
+	if len(config.Instances) == 0 {
+		return nil
+	}
+	p := &peerInstances{clients: make(map[string]*ragclient.Client), mode: config.Mode}
+	for category, url := range config.Instances {
+		client := ragclient.New(url)
+		client.HTTPClient = &http.Client{Timeout: config.Timeout}
+		p.clients[category] = client
+	}
+	return p
+}
+
+// 对等实例返回的引用，index标为 peer:<分类>
+func peerResults(category string, remote []ragclient.SearchResult) []SearchResult {
+	results := make([]SearchResult, len(remote))
+	for i, result := range remote {
+		results[i] = SearchResult{
+			ID: result.ID, DocID: result.DocID, Title: result.Title, Content: result.Content, Score: result.Score, Trust: result.Trust,
+			License: result.License, Index: "peer:" + category, Link: result.Link, DeepLink: result.DeepLink, Meta: result.Meta,
+		}
+	}
+	return results
+}
+
+// 问题所属的分类由对等实例负责时转发：relay返回对方的回答和引用（handled为true），merge返回对方检索到的分块，
+// 由调用方与本地检索结果合并。分类取请求的category，未指定时按问题路由，路由结果记入opts避免检索时重复分类
+func (r *RAGSystem) delegateToPeer(ctx context.Context, question string, opts *searchOptions) (string, []SearchResult, bool) {
+	if r.peers == nil || opts.Forwarded {
+		return "", nil, false
+	}
+	category := opts.Category
+	if category == "" {
+		category = r.routeQuestion(ctx, question)
+	}
+	opts.Routed = category
+	client, ok := r.peers.clients[category]
+	if !ok {
+		return "", nil, false
+	}
+
+	if r.peers.mode == peerModeMerge {
+		resp, err := client.Retrieve(ctx, ragclient.RetrieveRequest{Question: question})
+		if err != nil {
+			fmt.Printf("⚠️  对等实例 %s 检索失败，只使用本地知识库: %v\n", category, err)
+			return "", nil, false
+		}
+		fmt.Printf("🔗 合并对等实例 %s 的 %d 个分块\n", category, len(resp.Results))
+		return "", peerResults(category, resp.Results), false
+	}
+	resp, err := client.Ask(ctx, ragclient.AskRequest{Question: question, Length: opts.Length, Forwarded: true})
+	if err != nil {
+		fmt.Printf("⚠️  转发到对等实例 %s 失败，按本地知识库回答: %v\n", category, err)
+		return "", nil, false
+	}
+	fmt.Printf("🔗 问题转发到对等实例 %s: %s\n", category, question)
+	opts.Degraded.mark(tierPeer)
+	return resp.Answer, peerResults(category, resp.Sources), true
+}
+
+// 本地检索结果和对等实例的分块按分数合并
+func mergePeerResults(local, peer []SearchResult) []SearchResult {
+	if len(peer) == 0 {
+		return local
+	}
+	merged := append(append([]SearchResult(nil), local...), peer...)
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+	return merged
+}
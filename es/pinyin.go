@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// 拼音检索配置：开启后title增加pinyin子字段，由analysis-pinyin插件分析为单字全拼、连写全拼和首字母，
+// 问题只含字母时按拼音匹配标题，例如 "yan tongxue"、"yantongxue"、"ytx"；按Fuzziness容忍拼写差异（模糊拼音）。
+// 需要在ES中安装analysis-pinyin插件，开启或关闭后需重新初始化索引
+type PinyinConfig struct {
+	Enabled   bool
+	Fuzziness string // ES的fuzziness：AUTO、0、1、2
+}
+
+func loadPinyinConfig() PinyinConfig {
+	return PinyinConfig{
+		Enabled:   getEnvAsBool("ES_PINYIN", false),
+		Fuzziness: getEnv("ES_PINYIN_FUZZINESS", "AUTO"),
+	}
+}
+
+// 拼音分析器的名称，同时用作分词器名称
+const pinyinAnalyzer = "rag_pinyin"
+
+// 索引settings.analysis中的拼音分词器和分析器
+func addPinyinAnalysis(analysis map[string]interface{}) {
+	analysis["tokenizer"] = map[string]interface{}{
+		pinyinAnalyzer: map[string]interface{}{
+			"type":                    "pinyin",
+			"keep_full_pinyin":        true,
+			"keep_joined_full_pinyin": true,
+			"keep_first_letter":       true,
+			"keep_original":           false,
+			"lowercase":               true,
+			"remove_duplicated_term":  true,
+		},
+	}
+	analysis["analyzer"].(map[string]interface{})[pinyinAnalyzer] = map[string]interface{}{
+		"type":      "custom",
+		"tokenizer": pinyinAnalyzer,
+	}
+}
+
+// 问题是否像拼音：只含英文字母、空格和隔音符号，且不少于两个字母
+func looksLikePinyin(query string) bool {
+	letters := 0
+	for _, r := range query {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			letters++
+		case r == ' ' || r == '\'':
+		default:
+			return false
+		}
+	}
+	return letters >= 2
+}
+
+// 按拼音检索标题
+func (r *RAGSystem) pinyinSearchIndex(ctx context.Context, indexName, query string, topK int, filters []types.Query) ([]SearchResult, error) {
+	req := chunkSearchRequest(topK)
+	req.Query = &types.Query{Bool: &types.BoolQuery{
+		Must: []types.Query{{Match: map[string]types.MatchQuery{
+			"title.pinyin": {
+				Query:     strings.ToLower(query),
+				Fuzziness: r.config.Pinyin.Fuzziness,
+			},
+		}}},
+		Filter: filters,
+	}}
+
+	esClient, primary := r.readClient()
+	if err := r.faults.inject(ctx, faultTargetStore, "ES拼音搜索"); err != nil {
+		r.recordRead(primary, err)
+		return nil, fmt.Errorf("拼音搜索失败: %w", err)
+	}
+
+	// 文本相关度分数除以100归一化，与关键词检索一致
+	results, err := searchChunks(ctx, esClient, indexName, req, 100, nil)
+	if err != nil {
+		r.recordRead(primary, readFailure(err))
+		return nil, fmt.Errorf("拼音搜索失败: %w", err)
+	}
+	r.recordRead(primary, nil)
+	return results, nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 回答策略：最相关分块的分数过低（低置信度）或没有检索到任何分块（超出知识库范围）时的处理方式
+const (
+	policyAnswer   = "answer"   // 照常基于检索结果回答
+	policyRefuse   = "refuse"   // 按模板拒答
+	policyEscalate = "escalate" // 推送到人工处理队列（工单系统的webhook），按模板回复
+	policyDirect   = "direct"   // 不参考知识库，由大模型直接回答
+)
+
+// 按回答策略处理的结果记录在降级档位中，同样不写入答案缓存
+const (
+	tierRefused   = "refused"
+	tierEscalated = "escalated"
+	tierDirect    = "direct"
+)
+
+// 一个命名空间的回答策略，命名空间即文档分类
+type answerPolicy struct {
+	MinScore      float64 `json:"min_score"`      // 最相关分块的分数低于该值视为低置信度
+	LowConfidence string  `json:"low_confidence"` // 低置信度时的处理方式
+	OutOfScope    string  `json:"out_of_scope"`   // 没有检索到分块时的处理方式
+	Template      string  `json:"template"`       // 拒答和转人工的回复
+	Webhook       string  `json:"webhook"`        // 转人工时推送的地址
+}
+
+// 回答策略文件：default为默认策略，namespaces按分类覆盖，未填写的字段沿用default
+type answerPolicies struct {
+	Default    answerPolicy            `json:"default"`
+	Namespaces map[string]answerPolicy `json:"namespaces"`
+}
+
+const (
+	defaultRefuseTemplate   = "抱歉，知识库中没有足够的信息回答这个问题。"
+	defaultEscalateTemplate = "这个问题已转交人工处理，稍后会有同事回复您。"
+)
+
+// 从JSON文件加载回答策略，文件不存在时返回nil，照常回答
+func loadAnswerPolicies(path string) (*answerPolicies, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p answerPolicies
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("解析回答策略 %s 失败: %w", path, err)
+	}
+	for name, policy := range p.Namespaces {
+		for _, action := range []string{policy.LowConfidence, policy.OutOfScope} {
+			if err := validatePolicyAction(action, policy.Webhook != "" || p.Default.Webhook != ""); err != nil {
+				return nil, fmt.Errorf("回答策略 %s: %w", name, err)
+			}
+		}
+	}
+	for _, action := range []string{p.Default.LowConfidence, p.Default.OutOfScope} {
+		if err := validatePolicyAction(action, p.Default.Webhook != ""); err != nil {
+			return nil, fmt.Errorf("默认回答策略: %w", err)
+		}
+	}
+	return &p, nil
+}
+
+func validatePolicyAction(action string, hasWebhook bool) error {
+	switch action {
+	case "", policyAnswer, policyRefuse, policyDirect:
+		return nil
+	case policyEscalate:
+		if !hasWebhook {
+			return fmt.Errorf("escalate需要配置webhook")
+		}
+		return nil
+	}
+	return fmt.Errorf("未知的处理方式: %s，可选 answer、refuse、escalate、direct", action)
+}
+
+// 命名空间的策略，未填写的字段沿用默认策略
+func (p *answerPolicies) forNamespace(namespace string) answerPolicy {
+	policy := p.Default
+	override, ok := p.Namespaces[namespace]
+	if !ok {
+		return policy
+	}
+	if override.MinScore > 0 {
+		policy.MinScore = override.MinScore
+	}
+	if override.LowConfidence != "" {
+		policy.LowConfidence = override.LowConfidence
+	}
+	if override.OutOfScope != "" {
+		policy.OutOfScope = override.OutOfScope
+	}
+	if override.Template != "" {
+		policy.Template = override.Template
+	}
+	if override.Webhook != "" {
+		policy.Webhook = override.Webhook
+	}
+	return policy
+}
+
+// 检索结果对应的处理方式和原因，照常回答时action为空
+func (p *answerPolicies) decide(namespace string, results []SearchResult) (answerPolicy, string, string) {
+	policy := p.forNamespace(namespace)
+	if len(results) == 0 {
+		return policy, policy.OutOfScope, "out_of_scope"
+	}
+	if bestScore(results) < policy.MinScore {
+		return policy, policy.LowConfidence, "low_confidence"
+	}
+	return policy, "", ""
+}
+
+// 回答所属的命名空间：请求的分类，未指定时取最相关分块的分类
+func answerNamespace(category string, results []SearchResult) string {
+	if category == "" && len(results) > 0 {
+		category, _ = results[0].Meta["category"].(string)
+	}
+	return category
+}
+
+// 最相关分块的分数，results不能为空
+func bestScore(results []SearchResult) float64 {
+	best := results[0].Score
+	for _, result := range results[1:] {
+		best = max(best, result.Score)
+	}
+	return best
+}
+
+// 低置信度或超出范围时按命名空间的策略处理，返回是否已处理
+func (r *RAGSystem) applyPolicy(ctx context.Context, question string, opts searchOptions, results []SearchResult) (string, bool, error) {
+	policies := r.settings().policies
+	if policies == nil {
+		return "", false, nil
+	}
+	namespace := answerNamespace(opts.Category, results)
+	policy, action, reason := policies.decide(namespace, results)
+
+	switch action {
+	case policyRefuse:
+		fmt.Printf("🚫 按回答策略拒答（%s）: %s\n", reason, question)
+		opts.Degraded.mark(tierRefused)
+		return policyTemplate(policy, defaultRefuseTemplate), true, nil
+	case policyEscalate:
+		fmt.Printf("🙋 按回答策略转人工（%s）: %s\n", reason, question)
+		if err := escalate(ctx, policy.Webhook, question, namespace, reason, results); err != nil {
+			// 推送失败时退回拒答，不让提问者误以为已有人跟进
+			fmt.Printf("⚠️  转人工失败: %v\n", err)
+			opts.Degraded.mark(tierRefused)
+			return defaultRefuseTemplate, true, nil
+		}
+		opts.Degraded.mark(tierEscalated)
+		return policyTemplate(policy, defaultEscalateTemplate), true, nil
+	case policyDirect:
+		fmt.Printf("💭 按回答策略直接回答（%s）: %s\n", reason, question)
+		opts.Degraded.mark(tierDirect)
+		answer, err := r.completeAnswer(withReasoning(ctx, opts.Reasoning), r.outOfScopeChatRequest(question, opts.Model))
+		if err != nil {
+			return "", true, err
+		}
+		return outOfScopeNotice + answer, true, nil
+	}
+	return "", false, nil
+}
+
+func policyTemplate(policy answerPolicy, fallback string) string {
+	if policy.Template != "" {
+		return policy.Template
+	}
+	return fallback
+}
+
+const outOfScopeNotice = "（知识库中没有相关内容，以下回答未参考知识库）\n\n"
+
+// 知识库没有相关内容时的提问，要求模型说明回答未经知识库核实
+func (r *RAGSystem) outOfScopeChatRequest(question, model string) openai.ChatCompletionRequest {
+	req := r.directChatRequest(question, model)
+	req.Messages[0].Content = fmt.Sprintf("知识库中没有与问题相关的内容，请根据你自己的知识简要回答，不确定时直接说明不知道。\n\n问题：%s", question)
+	return req
+}
+
+// 请求指定直接回答（mode为direct）时不检索知识库，按direct档位记录
+func (r *RAGSystem) answerDirect(ctx context.Context, question string, opts searchOptions) (string, error) {
+	fmt.Printf("💭 按请求直接回答: %s\n", question)
+	opts.Degraded.mark(tierDirect)
+	req := r.directChatRequest(question, opts.Model)
+	req.Messages[0].Content = fmt.Sprintf("请根据你自己的知识简要回答，不确定时直接说明不知道。\n\n问题：%s", question)
+	return r.completeWithLength(withReasoning(ctx, opts.Reasoning), req, opts.Length)
+}
+
+// 转人工推送的内容，工单系统据此创建工单
+type escalation struct {
+	Question  string             `json:"question"`
+	Namespace string             `json:"namespace,omitempty"`
+	Reason    string             `json:"reason"` // low_confidence、out_of_scope
+	Sources   []escalationSource `json:"sources,omitempty"`
+	Time      time.Time          `json:"time"`
+}
+
+type escalationSource struct {
+	ID    string  `json:"id"`
+	Title string  `json:"title"`
+	Score float64 `json:"score"`
+}
+
+func escalate(ctx context.Context, webhook, question, namespace, reason string, results []SearchResult) error {
+	payload := escalation{Question: question, Namespace: namespace, Reason: reason, Time: time.Now()}
+	for _, result := range results {
+		payload.Sources = append(payload.Sources, escalationSource{ID: result.ID, Title: result.Title, Score: result.Score})
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("工单系统返回 %s", strings.TrimSpace(resp.Status))
+	}
+	return nil
+}
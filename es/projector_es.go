@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// 读取一个向量空间中最多limit个分块的向量，指定分类时只读取该分类；单次最多10000个
+func (r *RAGSystem) embeddingPoints(ctx context.Context, category string, limit int) ([]embeddingPoint, error) {
+	req := chunkSearchRequest(min(limit, 10000))
+	model := r.namespaceEmbeddings.modelOf(category)
+	field := embeddingField(model)
+	req.Source_ = []string{"doc_id", "title", "meta.category", field}
+	filters := append(categoryFilters(category), embeddingFilters(model, r.namespaceEmbeddings.names())...)
+	req.Query = &types.Query{Bool: &types.BoolQuery{Filter: filters}}
+	res, err := r.typedClient.Search().Index(r.config.IndexName).Request(req).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("读取向量失败: %w", err)
+	}
+
+	points := make([]embeddingPoint, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var source struct {
+			DocID string `json:"doc_id"`
+			Title string `json:"title"`
+			Meta  struct {
+				Category string `json:"category"`
+			} `json:"meta"`
+		}
+		var vectors map[string]json.RawMessage
+		if err := json.Unmarshal(hit.Source_, &source); err != nil {
+			return nil, fmt.Errorf("解析分块失败: %w", err)
+		}
+		if err := json.Unmarshal(hit.Source_, &vectors); err != nil {
+			return nil, fmt.Errorf("解析分块失败: %w", err)
+		}
+		point := embeddingPoint{DocID: source.DocID, Title: source.Title, Category: source.Meta.Category}
+		if raw, ok := vectors[field]; ok {
+			if err := json.Unmarshal(raw, &point.Vector); err != nil {
+				return nil, fmt.Errorf("解析分块%s字段失败: %w", field, err)
+			}
+		}
+		if hit.Id_ != nil {
+			point.ID = *hit.Id_
+		}
+		if point.DocID == "" {
+			point.DocID = point.ID
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
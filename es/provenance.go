@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 溯源清单配置：/ask 请求provenance时返回一份用PROVENANCE_SIGNING_KEY做HMAC-SHA256签名的JSON清单，
+// 记录问题、模型、提示词哈希、来源分块的ID和内容哈希以及时间，供需要合规存档的用户保存"哪些证据产生了这个回答"。
+// PROVENANCE_KEY_ID标识签名密钥，轮换密钥时修改；未配置密钥时不能导出清单
+type ProvenanceConfig struct {
+	SigningKey string
+	KeyID      string
+}
+
+func loadProvenanceConfig() ProvenanceConfig {
+	return ProvenanceConfig{
+		SigningKey: getEnv("PROVENANCE_SIGNING_KEY", ""),
+		KeyID:      getEnv("PROVENANCE_KEY_ID", "default"),
+	}
+}
+
+const provenanceVersion = 1
+
+// 一次回答的溯源清单
+type provenanceManifest struct {
+	Version    int               `json:"version"`
+	Question   string            `json:"question"`
+	Answer     string            `json:"answer_sha256"` // 返回的回答的哈希
+	Model      string            `json:"model"`
+	Mode       string            `json:"mode"`
+	Profile    string            `json:"profile,omitempty"`
+	Prompts    []string          `json:"prompt_sha256"` // 每次调用大模型时模型和消息的哈希，按调用顺序；命中缓存或未调用大模型时为空
+	Chunks     []provenanceChunk `json:"chunks"`
+	Cached     bool              `json:"cached"`
+	Degraded   []string          `json:"degraded,omitempty"`
+	AnsweredAt time.Time         `json:"answered_at"`
+	KeyID      string            `json:"key_id"`
+	Signature  string            `json:"signature"` // 除signature外其他字段的HMAC-SHA256，十六进制
+}
+
+// 回答引用的分块
+type provenanceChunk struct {
+	ID      string `json:"id"`
+	DocID   string `json:"doc_id"`
+	Title   string `json:"title"`
+	Index   string `json:"index,omitempty"`
+	Date    string `json:"date,omitempty"` // 文档元数据中的date
+	Content string `json:"content_sha256"`
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// 一次请求中发给大模型的提示词哈希，联合检索和分批总结时可能并发写入
+type promptLog struct {
+	mu     sync.Mutex
+	hashes []string
+}
+
+func (l *promptLog) add(hashes ...string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hashes = append(l.hashes, hashes...)
+}
+
+func (l *promptLog) Hashes() []string {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.hashes...)
+}
+
+type promptLogKey struct{}
+
+// 把提示词记录放入ctx，经该ctx生成回答时记录提示词哈希；log为nil时不记录
+func withPromptLog(ctx context.Context, log *promptLog) context.Context {
+	if log == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, promptLogKey{}, log)
+}
+
+// 记录实际发送的模型和消息的哈希，续写请求由首次请求和已生成的回答决定，不单独记录
+func recordPrompt(ctx context.Context, request openai.ChatCompletionRequest) {
+	log, ok := ctx.Value(promptLogKey{}).(*promptLog)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(struct {
+		Model    string                         `json:"model"`
+		Messages []openai.ChatCompletionMessage `json:"messages"`
+	}{request.Model, request.Messages})
+	if err != nil {
+		return
+	}
+	log.add(sha256Hex(data))
+}
+
+// 生成签名的溯源清单，未配置签名密钥时返回错误
+func newProvenanceManifest(config ProvenanceConfig, question, mode string, resp askResponse, prompts []string) (*provenanceManifest, error) {
+	if config.SigningKey == "" {
+		return nil, fmt.Errorf("未配置PROVENANCE_SIGNING_KEY，无法导出溯源清单")
+	}
+	if mode == "" {
+		mode = answerModeGenerate
+	}
+	manifest := &provenanceManifest{
+		Version:    provenanceVersion,
+		Question:   question,
+		Answer:     sha256Hex([]byte(resp.Answer)),
+		Model:      resp.Model,
+		Mode:       mode,
+		Profile:    resp.Profile,
+		Prompts:    prompts,
+		Chunks:     make([]provenanceChunk, len(resp.Sources)),
+		Cached:     resp.Cached,
+		Degraded:   resp.Degraded,
+		AnsweredAt: time.Now().UTC(),
+		KeyID:      config.KeyID,
+	}
+	if manifest.Prompts == nil {
+		manifest.Prompts = []string{}
+	}
+	for i, source := range resp.Sources {
+		manifest.Chunks[i] = provenanceChunk{
+			ID:      source.ID,
+			DocID:   source.DocID,
+			Title:   source.Title,
+			Index:   source.Index,
+			Date:    metaString(source.Meta, "date"),
+			Content: sha256Hex([]byte(source.Content)),
+		}
+	}
+	signature, err := manifest.sign(config.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Signature = signature
+	return manifest, nil
+}
+
+// 对去掉signature后的JSON签名
+func (m provenanceManifest) sign(key string) (string, error) {
+	m.Signature = ""
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("序列化溯源清单失败: %w", err)
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+type verifyProvenanceRequest struct {
+	Manifest provenanceManifest `json:"manifest"`
+	Answer   *string            `json:"answer,omitempty"` // 存档的回答原文，提供时同时核对answer_sha256
+}
+
+type verifyProvenanceResponse struct {
+	Valid         bool   `json:"valid"`                    // 签名有效，清单未被修改
+	AnswerMatches *bool  `json:"answer_matches,omitempty"` // 请求提供answer时，回答原文是否与清单一致
+	Reason        string `json:"reason,omitempty"`         // 校验不通过的原因
+}
+
+// 校验溯源清单的签名，存档方不持有签名密钥，由服务端核对
+func (s *apiServer) handleVerifyProvenance(w http.ResponseWriter, req *http.Request) {
+	var body verifyProvenanceRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	config := s.rag.config.Provenance
+	if config.SigningKey == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("未配置PROVENANCE_SIGNING_KEY，无法校验溯源清单"))
+		return
+	}
+
+	var resp verifyProvenanceResponse
+	expected, err := body.Manifest.sign(config.SigningKey)
+	switch {
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	case body.Manifest.KeyID != config.KeyID:
+		resp.Reason = fmt.Sprintf("清单由密钥 %s 签名，当前密钥为 %s", body.Manifest.KeyID, config.KeyID)
+	case !hmac.Equal([]byte(expected), []byte(body.Manifest.Signature)):
+		resp.Reason = "签名不匹配，清单已被修改或不是本服务签发的"
+	default:
+		resp.Valid = true
+	}
+	if body.Answer != nil {
+		matches := sha256Hex([]byte(*body.Answer)) == body.Manifest.Answer
+		resp.AnswerMatches = &matches
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/operator"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/textquerytype"
+)
+
+// 检索返回的_source字段
+var esChunkFields = []string{"doc_id", "title", "content", "content_zstd", "meta"}
+
+// script_score精确检索的打分脚本，返回cosineSimilarity+1，范围0-2
+const esCosineScript = "cosineSimilarity(params.query_vector, params.field) + 1.0"
+
+// 按分类过滤，meta为动态映射，字符串字段带keyword子字段；没有分类时不过滤
+func categoryFilters(category string) []types.Query {
+	if category == "" {
+		return nil
+	}
+	return []types.Query{termQuery("meta.category.keyword", category)}
+}
+
+// 按实体过滤，命中分块元数据中任一类实体即可；没有实体时不过滤
+func entityFilters(entity string) []types.Query {
+	if entity == "" {
+		return nil
+	}
+	return []types.Query{{Bool: &types.BoolQuery{
+		Should: []types.Query{
+			termQuery("meta."+entityPerson, entity),
+			termQuery("meta."+entityOrganization, entity),
+			termQuery("meta."+entityDate, entity),
+		},
+		MinimumShouldMatch: 1,
+	}}}
+}
+
+// 按时间范围过滤入库时解析的date_ts；没有时间范围时不过滤
+func periodFilters(period *dateRange) []types.Query {
+	if period == nil {
+		return nil
+	}
+	from, to := types.Float64(period.From.Unix()), types.Float64(period.To.Unix())
+	return []types.Query{{Range: map[string]types.RangeQuery{
+		"meta." + dateTimestampKey: types.NumberRangeQuery{Gte: &from, Lt: &to},
+	}}}
+}
+
+// 排除条件：命中任一排除的元数据取值或文档的分块不参与检索；实体字段是keyword，其他字符串字段取.keyword子字段
+func exclusionFilters(opts searchOptions) []types.Query {
+	var mustNot []types.Query
+	for _, exclusion := range opts.Exclude {
+		field := "meta." + exclusion.Field + ".keyword"
+		switch exclusion.Field {
+		case entityPerson, entityOrganization, entityDate:
+			field = "meta." + exclusion.Field
+		}
+		mustNot = append(mustNot, termQuery(field, exclusion.Value))
+	}
+	for _, docID := range opts.ExcludeDocs {
+		mustNot = append(mustNot, termQuery("doc_id", docID))
+	}
+	if len(mustNot) == 0 {
+		return nil
+	}
+	return []types.Query{{Bool: &types.BoolQuery{MustNot: mustNot}}}
+}
+
+// 向量空间过滤：命名空间模型只比较该模型写入的分块，内置向量排除各命名空间模型写入的分块
+func embeddingFilters(model string, models []string) []types.Query {
+	field := "meta." + embeddingModelKey + ".keyword"
+	if model != "" {
+		return []types.Query{termQuery(field, model)}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	mustNot := make([]types.Query, len(models))
+	for i, name := range models {
+		mustNot[i] = termQuery(field, name)
+	}
+	return []types.Query{{Bool: &types.BoolQuery{MustNot: mustNot}}}
+}
+
+func termQuery(field, value string) types.Query {
+	return types.Query{Term: map[string]types.TermQuery{field: {Value: value}}}
+}
+
+// 只有过滤条件的bool查询，没有条件时为match_all
+func filterQuery(filters []types.Query) types.Query {
+	if len(filters) == 0 {
+		return types.Query{MatchAll: types.NewMatchAllQuery()}
+	}
+	return types.Query{Bool: &types.BoolQuery{Filter: filters}}
+}
+
+// 在过滤结果上按field字段的余弦相似度暴力打分
+func scriptScoreQuery(filters []types.Query, field string, vector []float32) (*types.Query, error) {
+	params, err := json.Marshal(vector)
+	if err != nil {
+		return nil, err
+	}
+	fieldParam, err := json.Marshal(field)
+	if err != nil {
+		return nil, err
+	}
+	source := esCosineScript
+	return &types.Query{
+		ScriptScore: &types.ScriptScoreQuery{
+			Query: filterQuery(filters),
+			Script: types.Script{
+				Source: &source,
+				Params: map[string]json.RawMessage{"query_vector": params, "field": fieldParam},
+			},
+		},
+	}, nil
+}
+
+// 在field字段上kNN近似检索，过滤条件在候选集生成前生效
+func knnSearch(field string, vector []float32, k, numCandidates int, filters []types.Query) types.KnnSearch {
+	return types.KnnSearch{
+		Field:         field,
+		QueryVector:   vector,
+		K:             &k,
+		NumCandidates: &numCandidates,
+		Filter:        filters,
+	}
+}
+
+// 标题和正文的全文检索，所有词都需命中
+func multiMatchQuery(query string, fields ...string) *types.Query {
+	return &types.Query{
+		MultiMatch: &types.MultiMatchQuery{
+			Query:    query,
+			Fields:   fields,
+			Type:     &textquerytype.Bestfields,
+			Operator: &operator.And,
+		},
+	}
+}
+
+// 检索分块的请求，size为0时使用ES默认值
+func chunkSearchRequest(size int) *search.Request {
+	req := search.NewRequest()
+	if size > 0 {
+		req.Size = &size
+	}
+	req.Source_ = esChunkFields
+	req.TrackTotalHits = false
+	return req
+}
+
+// 执行检索并把命中转换为检索结果，分数除以scoreScale归一化到0-1；page不为nil时记录本页最后一条的排序值。
+// 响应不经过typedapi的search.Response，只解析命中需要的字段，检索是热路径，完整解析慢约3倍
+func searchChunks(ctx context.Context, client *elasticsearch.TypedClient, indexName string, req *search.Request, scoreScale float64, page *searchPage) ([]SearchResult, error) {
+	res, err := client.Search().Index(indexName).Request(req).Perform(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return nil, responseError(res)
+	}
+	return decodeSearchResults(res.Body, scoreScale, page)
+}
+
+// 检索响应中用到的部分
+type esSearchResponse struct {
+	Hits struct {
+		Hits []esHit `json:"hits"`
+	} `json:"hits"`
+}
+
+type esHit struct {
+	ID     string        `json:"_id"`
+	Score  *float64      `json:"_score"`
+	Source esChunkSource `json:"_source"`
+	Sort   []interface{} `json:"sort"`
+}
+
+func decodeSearchResults(body io.Reader, scoreScale float64, page *searchPage) ([]SearchResult, error) {
+	var response esSearchResponse
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("读取检索结果失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("解析检索结果失败: %w", err)
+	}
+	hits := response.Hits.Hits
+	if len(hits) > 0 {
+		recordPageEnd(page, hits[len(hits)-1].Sort)
+	}
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		result, err := chunkResult(hit, scoreScale)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// 与类型化API的Do一样把非2xx响应转换为ElasticsearchError，readFailure据此判断节点故障
+func responseError(res *http.Response) error {
+	esErr := types.NewElasticsearchError()
+	if err := json.NewDecoder(res.Body).Decode(esErr); err != nil {
+		return fmt.Errorf("检索失败: %s", res.Status)
+	}
+	if esErr.Status == 0 {
+		esErr.Status = res.StatusCode
+	}
+	return esErr
+}
+
+// 类型化API把非2xx响应转换为ElasticsearchError，只有服务端错误才视为节点故障
+func readFailure(err error) error {
+	var esErr *types.ElasticsearchError
+	if errors.As(err, &esErr) {
+		return readError(esErr.Status)
+	}
+	return err
+}
+
+// 分块的_source，content_zstd为base64编码的binary字段，解码为[]byte
+type esChunkSource struct {
+	DocID      string                 `json:"doc_id"`
+	Title      string                 `json:"title"`
+	Content    string                 `json:"content"`
+	Compressed []byte                 `json:"content_zstd"`
+	Meta       map[string]interface{} `json:"meta"`
+}
+
+// 转换为检索结果，分数除以scoreScale归一化到0-1
+func chunkResult(hit esHit, scoreScale float64) (SearchResult, error) {
+	id, source := hit.ID, hit.Source
+	var score float64
+	if hit.Score != nil {
+		score = *hit.Score
+	}
+
+	docID := source.DocID
+	if docID == "" {
+		docID = id // 分块功能之前写入的文档没有doc_id
+	}
+	content := source.Content
+	if len(source.Compressed) > 0 {
+		decompressed, err := decompressChunk(source.Compressed)
+		if err != nil {
+			return SearchResult{}, fmt.Errorf("分块 %s: %w", id, err)
+		}
+		content = decompressed
+	}
+	return SearchResult{
+		ID:      id,
+		DocID:   docID,
+		Title:   source.Title,
+		Content: content,
+		Score:   min(score/scoreScale, 1.0),
+		Meta:    source.Meta,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 查询操作符：问题中形如 key:value 的词（冒号也可以是全角），检索前从问题中去掉并转换为检索参数，
+// 不调用API也能控制检索：source:公众号（同category:，只检索该分类）、after:2026-01、before:2026-03-15
+// （按文档date过滤，after包含该年/月/日，before不包含）、topk:5（检索的分块数）、mode:direct（不参考知识库直接回答，
+// 也可以是generate、extractive、summarize）。不认识的key原样保留在问题中；带操作符的问题不读写答案缓存
+type queryOperators struct {
+	Category string
+	After    time.Time
+	Before   time.Time
+	TopK     int
+	Mode     string
+	Applied  []string // 问题中出现的操作符，按出现顺序
+}
+
+// 单次请求检索的分块数上限
+const maxOperatorTopK = 50
+
+// 时间范围只有一端时另一端取足够早或足够晚的时刻
+var (
+	periodMin = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodMax = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// 解析问题中的操作符，返回去掉操作符后的问题；日期按location理解
+func parseQueryOperators(question string, location *time.Location) (string, queryOperators, error) {
+	var ops queryOperators
+	var words []string
+	for _, word := range strings.Fields(question) {
+		key, value, ok := strings.Cut(strings.Replace(word, "：", ":", 1), ":")
+		if !ok || value == "" {
+			words = append(words, word)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "source", "category":
+			ops.Category = value
+		case "after", "before":
+			t, ok := parseDocumentDate(value, location)
+			if !ok {
+				return question, ops, fmt.Errorf("%s的日期无法解析: %s，格式为2026、2026-01或2026-01-15", key, value)
+			}
+			if strings.EqualFold(key, "after") {
+				ops.After = t
+			} else {
+				ops.Before = t
+			}
+		case "topk":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 || n > maxOperatorTopK {
+				return question, ops, fmt.Errorf("topk应为1到%d的整数: %s", maxOperatorTopK, value)
+			}
+			ops.TopK = n
+		case "mode":
+			switch value {
+			case answerModeGenerate, answerModeExtractive, answerModeSummarize, answerModeDirect:
+			default:
+				return question, ops, fmt.Errorf("未知的mode: %s，可选 generate、extractive、summarize、direct", value)
+			}
+			ops.Mode = value
+		default:
+			words = append(words, word)
+			continue
+		}
+		ops.Applied = append(ops.Applied, word)
+	}
+	if !ops.After.IsZero() && !ops.Before.IsZero() && !ops.After.Before(ops.Before) {
+		return question, ops, fmt.Errorf("after须早于before")
+	}
+	return strings.Join(words, " "), ops, nil
+}
+
+// 把操作符转换为检索参数，覆盖请求中的同名参数
+func (q queryOperators) apply(opts *searchOptions) {
+	if len(q.Applied) == 0 {
+		return
+	}
+	opts.Operators = q.Applied
+	if q.Category != "" {
+		opts.Category = q.Category
+	}
+	if !q.After.IsZero() || !q.Before.IsZero() {
+		period := &dateRange{From: periodMin, To: periodMax}
+		var phrases []string
+		if !q.After.IsZero() {
+			period.From = q.After
+			phrases = append(phrases, "after:"+q.After.Format("2006-01-02"))
+		}
+		if !q.Before.IsZero() {
+			period.To = q.Before
+			phrases = append(phrases, "before:"+q.Before.Format("2006-01-02"))
+		}
+		period.Phrase = strings.Join(phrases, " ")
+		opts.Period = period
+	}
+	if q.TopK > 0 {
+		opts.TopK = q.TopK
+	}
+	if q.Mode != "" {
+		opts.Extractive = q.Mode == answerModeExtractive
+		opts.Summarize = q.Mode == answerModeSummarize
+		opts.Direct = q.Mode == answerModeDirect
+	}
+}
+
+// 解析请求问题中的操作符，去掉操作符后问题不能为空
+func (s *apiServer) parseOperators(question string) (string, queryOperators, error) {
+	question, ops, err := parseQueryOperators(question, s.rag.config.Dates.Location)
+	if err == nil && question == "" {
+		err = fmt.Errorf("去掉查询操作符后问题为空")
+	}
+	return question, ops, err
+}
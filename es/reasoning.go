@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 推理模型配置：REASONING_MODELS中的模型（如deepseek-reasoner）先输出思考过程再给出回答，
+// 思考过程单独收集，不计入回答，也不参与引用署名；max_tokens包含思考过程，需要比普通模型大得多
+type ReasoningConfig struct {
+	Models    []string
+	MaxTokens int
+}
+
+func loadReasoningConfig() ReasoningConfig {
+	models := splitEnvList("REASONING_MODELS")
+	if len(models) == 0 {
+		models = []string{"deepseek-reasoner"}
+	}
+	return ReasoningConfig{
+		Models:    models,
+		MaxTokens: getEnvAsInt("REASONING_MAX_TOKENS", 4000),
+	}
+}
+
+func (c ReasoningConfig) isReasoningModel(model string) bool {
+	for _, name := range c.Models {
+		if name == model {
+			return true
+		}
+	}
+	return false
+}
+
+// 推理模型不支持工具调用，temperature也不生效；放宽max_tokens，避免思考过程占满长度上限
+func (r *RAGSystem) adaptForReasoning(request openai.ChatCompletionRequest) openai.ChatCompletionRequest {
+	if !r.config.Reasoning.isReasoningModel(request.Model) {
+		return request
+	}
+	request.Temperature = 0
+	if request.MaxTokens < r.config.Reasoning.MaxTokens {
+		request.MaxTokens = r.config.Reasoning.MaxTokens
+	}
+	return request
+}
+
+// 推理模型不支持工具调用，需要计算的问题也直接回答，由模型在思考过程中完成计算
+func (r *RAGSystem) useCalculator(model string) bool {
+	if model == "" {
+		model = r.config.DeepSeekModel
+	}
+	return r.config.Calculator && !r.config.Reasoning.isReasoningModel(model)
+}
+
+type reasoningKey struct{}
+
+// 把思考过程的收集器放入ctx，经该ctx发出的请求返回的reasoning_content追加到builder；builder为nil时不收集
+func withReasoning(ctx context.Context, builder *strings.Builder) context.Context {
+	if builder == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, reasoningKey{}, builder)
+}
+
+// go-openai没有reasoning_content字段，与usageTransport一样直接从响应中读取
+type reasoningTransport struct {
+	base http.RoundTripper
+}
+
+func (t *reasoningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	builder, ok := req.Context().Value(reasoningKey{}).(*strings.Builder)
+	if err != nil || !ok || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &reasoningStreamBody{ReadCloser: resp.Body, builder: builder}
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	var payload struct {
+		Choices []struct {
+			Message struct {
+				ReasoningContent string `json:"reasoning_content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if json.Unmarshal(body, &payload) == nil && len(payload.Choices) > 0 {
+		builder.WriteString(payload.Choices[0].Message.ReasoningContent)
+	}
+	return resp, nil
+}
+
+// 边读边保留流式响应内容，关闭时拼接各数据块的reasoning_content
+type reasoningStreamBody struct {
+	io.ReadCloser
+	builder *strings.Builder
+	buf     bytes.Buffer
+}
+
+func (b *reasoningStreamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *reasoningStreamBody) Close() error {
+	scanner := bufio.NewScanner(&b.buf)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					ReasoningContent string `json:"reasoning_content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		line := strings.TrimPrefix(scanner.Text(), "data: ")
+		if json.Unmarshal([]byte(line), &chunk) == nil && len(chunk.Choices) > 0 {
+			b.builder.WriteString(chunk.Choices[0].Delta.ReasoningContent)
+		}
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 重新向量化时读取的分块正文
+type reembedChunk struct {
+	ID      string
+	DocID   string
+	Content string
+	Model   string // 写入时使用的命名空间向量模型，内置向量为空
+}
+
+// 断点文件首行记录所属的存储和集合（索引），之后每行一个已完成的文档ID
+const reembedCheckpointHeader = "# target: "
+
+// 重新向量化的断点：记录已完成的文档ID，中断后重新运行时跳过这些文档。断点属于另一个存储或集合时拒绝续跑，
+// 避免把别处完成的文档当成已完成
+type reembedCheckpoint struct {
+	path    string
+	target  string
+	started bool // 文件已写入首行
+	done    map[string]bool
+}
+
+func loadReembedCheckpoint(path, target string) (*reembedCheckpoint, error) {
+	checkpoint := &reembedCheckpoint{path: path, target: target, done: make(map[string]bool)}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取断点文件失败: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	if scanner.Scan() {
+		owner, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), reembedCheckpointHeader)
+		if !ok {
+			owner = "未知"
+		}
+		if owner != target {
+			return nil, fmt.Errorf("断点文件 %s 属于 %s，当前为 %s；确认无误后加-restart从头开始", path, owner, target)
+		}
+		checkpoint.started = true
+	}
+	for scanner.Scan() {
+		if docID := strings.TrimSpace(scanner.Text()); docID != "" {
+			checkpoint.done[docID] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取断点文件失败: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// 记录一批已完成的文档，每批追加后立即落盘
+func (c *reembedCheckpoint) mark(docIDs []string) error {
+	file, err := os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("写入断点文件失败: %w", err)
+	}
+	defer file.Close()
+	lines := strings.Join(docIDs, "\n") + "\n"
+	if !c.started {
+		lines = reembedCheckpointHeader + c.target + "\n" + lines
+	}
+	if _, err := file.WriteString(lines); err != nil {
+		return fmt.Errorf("写入断点文件失败: %w", err)
+	}
+	c.started = true
+	for _, docID := range docIDs {
+		c.done[docID] = true
+	}
+	return file.Sync()
+}
+
+// 按文档切分批次
+func batchDocIDs(docIDs []string, size int) [][]string {
+	var batches [][]string
+	for start := 0; start < len(docIDs); start += size {
+		batches = append(batches, docIDs[start:min(start+size, len(docIDs))])
+	}
+	return batches
+}
+
+// reembed命令：按当前向量配置重新生成全部分块的向量，分块正文和元数据不变。先估算token数和费用，确认后（或-yes）
+// 按文档分批处理，每批完成后写入断点文件，中断后重新运行从断点继续，全部完成后删除断点文件。
+// 断点文件默认按存储和集合（索引）命名，不同集合各自续跑
+func runReembed(args []string) error {
+	fs := flag.NewFlagSet("reembed", flag.ExitOnError)
+	yes := fs.Bool("yes", false, "不确认直接执行")
+	price := fs.Float64("price", 0, "生成写入向量的向量模型价格（元/百万tokens），用于估算费用；内置的简化向量在本地计算，不产生费用")
+	batch := fs.Int("batch", 50, "每批处理的文档数，每批完成后记录断点")
+	checkpointPath := fs.String("checkpoint", "", "断点文件，记录已完成的文档，默认为.reembed_checkpoint_<存储>_<集合>")
+	restart := fs.Bool("restart", false, "忽略已有断点，从头开始")
+	_ = fs.Parse(args)
+
+	if *batch <= 0 {
+		return fmt.Errorf("-batch必须大于0")
+	}
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	target := rag.reembedTarget()
+	if *checkpointPath == "" {
+		*checkpointPath = ".reembed_checkpoint_" + target
+	}
+	if *restart {
+		if err := removeCheckpoint(*checkpointPath); err != nil {
+			return err
+		}
+	}
+	checkpoint, err := loadReembedCheckpoint(*checkpointPath, target)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if err := rag.checkVectorDim(ctx); err != nil {
+		return err
+	}
+	all, err := rag.corpusDocIDs(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, docID := range all {
+		if !checkpoint.done[docID] {
+			pending = append(pending, docID)
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Println("✅ 没有需要重新向量化的文档")
+		return removeCheckpoint(*checkpointPath)
+	}
+	batches := batchDocIDs(pending, *batch)
+
+	// 估算：逐批读取分块正文统计token数，不把整个语料留在内存中
+	chunks, tokens := 0, 0
+	for _, docIDs := range batches {
+		contents, err := rag.chunkContents(ctx, docIDs)
+		if err != nil {
+			return err
+		}
+		chunks += len(contents)
+		for _, chunk := range contents {
+			tokens += rag.tokens.Count(chunk.Content)
+		}
+	}
+	if len(pending) < len(all) {
+		fmt.Printf("⏯️  从断点继续，已完成 %d 篇，跳过\n", len(all)-len(pending))
+	}
+	fmt.Printf("📊 待重新向量化: %d 篇文档，%d 个分块，约 %d tokens\n", len(pending), chunks, tokens)
+	fmt.Printf("💰 预估费用: ¥%.4f（%.2f 元/百万tokens）\n", float64(tokens)/1e6*(*price), *price)
+	if !*yes && !confirm(bufio.NewReader(os.Stdin), "开始重新向量化？[y/N] ") {
+		fmt.Println("已取消")
+		return nil
+	}
+
+	start := time.Now()
+	done := 0
+	for i, docIDs := range batches {
+		n, err := rag.reembedDocuments(ctx, docIDs)
+		if err != nil {
+			return fmt.Errorf("重新向量化失败（已完成的文档记录在 %s，重新运行即可从断点继续）: %w", *checkpointPath, err)
+		}
+		if err := checkpoint.mark(docIDs); err != nil {
+			return err
+		}
+		done += n
+		fmt.Printf("🔁 第 %d/%d 批完成，%d/%d 个分块\n", i+1, len(batches), done, chunks)
+	}
+	fmt.Printf("✅ 已重新向量化 %d 篇文档（%d 个分块），耗时 %v\n", len(pending), done, time.Since(start).Round(time.Second))
+	return removeCheckpoint(*checkpointPath)
+}
+
+func removeCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除断点文件失败: %w", err)
+	}
+	return nil
+}
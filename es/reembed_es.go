@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// 断点所属的存储和索引
+func (r *RAGSystem) reembedTarget() string {
+	return "es_" + r.config.IndexName
+}
+
+// 索引中全部分块所属的文档ID，按ID排序
+func (r *RAGSystem) corpusDocIDs(ctx context.Context) ([]string, error) {
+	hits, err := r.searchChunkSources(ctx, map[string]interface{}{"match_all": map[string]interface{}{}}, []string{"doc_id"})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var docIDs []string
+	for _, hit := range hits {
+		if !seen[hit.DocID] {
+			seen[hit.DocID] = true
+			docIDs = append(docIDs, hit.DocID)
+		}
+	}
+	sort.Strings(docIDs)
+	return docIDs, nil
+}
+
+// 读取文档的分块正文，压缩存储的正文解压后返回
+func (r *RAGSystem) chunkContents(ctx context.Context, docIDs []string) ([]reembedChunk, error) {
+	// 分块功能之前写入的文档没有doc_id，文档ID即为_id
+	query := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []interface{}{
+				map[string]interface{}{"terms": map[string]interface{}{"doc_id": docIDs}},
+				map[string]interface{}{"ids": map[string]interface{}{"values": docIDs}},
+			},
+			"minimum_should_match": 1,
+		},
+	}
+	return r.searchChunkSources(ctx, query, []string{"doc_id", "content", "content_zstd", "meta." + embeddingModelKey})
+}
+
+// 按查询读取分块的_source，单次最多10000个
+func (r *RAGSystem) searchChunkSources(ctx context.Context, query map[string]interface{}, fields []string) ([]reembedChunk, error) {
+	searchJSON, _ := json.Marshal(map[string]interface{}{
+		"size":    10000,
+		"query":   query,
+		"_source": fields,
+	})
+	res, err := r.elasticClient.Search(
+		r.elasticClient.Search.WithContext(ctx),
+		r.elasticClient.Search.WithIndex(r.config.IndexName),
+		r.elasticClient.Search.WithBody(bytes.NewReader(searchJSON)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询分块失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("查询分块错误: %s", res.String())
+	}
+
+	var searchResponse struct {
+		Hits struct {
+			Hits []struct {
+				ID     string        `json:"_id"`
+				Source esChunkSource `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&searchResponse); err != nil {
+		return nil, fmt.Errorf("解析分块失败: %w", err)
+	}
+	chunks := make([]reembedChunk, len(searchResponse.Hits.Hits))
+	for i, hit := range searchResponse.Hits.Hits {
+		docID := hit.Source.DocID
+		if docID == "" {
+			docID = hit.ID
+		}
+		content := hit.Source.Content
+		if len(hit.Source.Compressed) > 0 {
+			if content, err = decompressChunk(hit.Source.Compressed); err != nil {
+				return nil, fmt.Errorf("分块 %s: %w", hit.ID, err)
+			}
+		}
+		model, _ := hit.Source.Meta[embeddingModelKey].(string)
+		chunks[i] = reembedChunk{ID: hit.ID, DocID: docID, Content: content, Model: model}
+	}
+	return chunks, nil
+}
+
+// 重新生成文档全部分块的向量，按分块部分更新所属模型的向量字段，返回处理的分块数
+func (r *RAGSystem) reembedDocuments(ctx context.Context, docIDs []string) (int, error) {
+	chunks, err := r.chunkContents(ctx, docIDs)
+	if err != nil || len(chunks) == 0 {
+		return 0, err
+	}
+	buffer := getBulkBuffer()
+	defer putBulkBuffer(buffer)
+	encoder := json.NewEncoder(buffer)
+	for _, chunk := range chunks {
+		action := map[string]interface{}{"update": map[string]interface{}{"_id": chunk.ID}}
+		vector, err := r.embedWith(ctx, chunk.Model, chunk.Content)
+		if err != nil {
+			return 0, fmt.Errorf("分块 %s 向量化失败: %w", chunk.ID, err)
+		}
+		update := map[string]interface{}{"doc": map[string]interface{}{embeddingField(chunk.Model): vector}}
+		if err := encoder.Encode(action); err != nil {
+			return 0, err
+		}
+		if err := encoder.Encode(update); err != nil {
+			return 0, err
+		}
+	}
+	if err := r.bulkIndex(ctx, r.config.IndexName, buffer.Bytes()); err != nil {
+		return 0, fmt.Errorf("更新向量失败: %w", err)
+	}
+	r.retrievals.Clear()
+	return len(chunks), nil
+}
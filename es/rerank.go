@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// 用问题向量化模型（EMBEDDING_PROVIDER）为候选分块向量化，任一分块失败时返回错误
+func (r *RAGSystem) embedResults(ctx context.Context, results []SearchResult) ([][]float32, error) {
+	vectors := make([][]float32, len(results))
+	for i, result := range results {
+		vector, err := r.embedder.Embed(ctx, result.Content)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// rerank和mmr：候选分块向量化后重新排序或按最大边际相关性选取；向量化失败时告警并保持检索的顺序
+func (r *RAGSystem) refineResults(ctx context.Context, question string, results []SearchResult, features featureSet) []SearchResult {
+	if len(results) < 2 || (!features[featureRerank] && !features[featureMMR]) {
+		return results
+	}
+	vectors, err := r.embedResults(ctx, results)
+	if err != nil {
+		fmt.Printf("⚠️  候选分块向量化失败，跳过重排: %v\n", err)
+		return results
+	}
+	if features[featureRerank] {
+		questionVector, err := r.embedder.Embed(ctx, question)
+		if err != nil {
+			fmt.Printf("⚠️  问题向量化失败，跳过重排: %v\n", err)
+		} else {
+			results, vectors = rerankResults(questionVector, results, vectors)
+		}
+	}
+	if features[featureMMR] {
+		results = selectDiverse(results, vectors, r.settings().MMRLambda)
+	}
+	return results
+}
+
+// 分数换成与问题的余弦相似度乘以可信度，按新分数排序，向量随结果一起重排
+func rerankResults(questionVector []float32, results []SearchResult, vectors [][]float32) ([]SearchResult, [][]float32) {
+	order := make([]int, len(results))
+	for i := range results {
+		results[i].Score = max(cosineSimilarity(questionVector, vectors[i]), 0) * results[i].Trust
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return results[order[a]].Score > results[order[b]].Score })
+	reranked := make([]SearchResult, len(results))
+	rerankedVectors := make([][]float32, len(results))
+	for i, index := range order {
+		reranked[i], rerankedVectors[i] = results[index], vectors[index]
+	}
+	return reranked, rerankedVectors
+}
+
+// 最大边际相关性：依次选取lambda*分数-(1-lambda)*与已选分块的最大相似度最高的分块，返回全部候选的新顺序
+func selectDiverse(results []SearchResult, vectors [][]float32, lambda float64) []SearchResult {
+	selected := make([]SearchResult, 0, len(results))
+	picked := make([]bool, len(results))
+	redundancy := make([]float64, len(results)) // 与已选分块的最大相似度
+	for len(selected) < len(results) {
+		best, bestValue := -1, 0.0
+		for i := range results {
+			if picked[i] {
+				continue
+			}
+			value := lambda*results[i].Score - (1-lambda)*redundancy[i]
+			if best < 0 || value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+		picked[best] = true
+		selected = append(selected, results[best])
+		for i := range results {
+			if !picked[i] {
+				redundancy[i] = max(redundancy[i], cosineSimilarity(vectors[i], vectors[best]))
+			}
+		}
+	}
+	return selected
+}
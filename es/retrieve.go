@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 检索配置
+type RetrievalConfig struct {
+	TopK        int     // 固定模式下的分块数
+	Adaptive    bool    // 自适应模式：按分数分布和token预算决定分块数
+	MaxK        int     // 自适应模式下最多检索的分块数
+	ScoreGap    float64 // 相邻分块分数差超过该值时截断
+	TokenBudget int     // 上下文token预算
+	Profile     string  // 默认精度档位：fast、balanced、accurate
+	MaxPerDoc   int     // 上下文中单个文档最多的分块数，0表示不限制
+	Order       string  // 上下文中分块的排列方式：id、relevance、relevance_last、chronological、document
+}
+
+func loadRetrievalConfig() RetrievalConfig {
+	return RetrievalConfig{
+		TopK:        getEnvAsInt("TOP_K", 3),
+		Adaptive:    getEnv("TOP_K_MODE", "fixed") == "adaptive",
+		MaxK:        getEnvAsInt("TOP_K_MAX", 8),
+		ScoreGap:    getEnvAsFloat("TOP_K_SCORE_GAP", 0.15),
+		TokenBudget: getEnvAsInt("CONTEXT_TOKEN_BUDGET", 2000),
+		Profile:     getEnv("ACCURACY_PROFILE", accuracyBalanced),
+		MaxPerDoc:   getEnvAsInt("MAX_CHUNKS_PER_DOC", 2),
+		Order:       getEnv("CONTEXT_ORDER", orderByID),
+	}
+}
+
+// 检索回答问题所用的分块；会话中的追问与最近的一次检索相同或相近时复用其结果
+func (r *RAGSystem) retrieve(ctx context.Context, question string, opts searchOptions) ([]SearchResult, error) {
+	scope := opts.sessionScope()
+	results, vector, ok := r.sessions.ReusableRetrieval(ctx, opts.Session, question, scope)
+	if ok {
+		fmt.Printf("♻️  复用会话最近的检索结果: %d 个分块\n", len(results))
+		return results, nil
+	}
+	results, err := r.retrieveFresh(ctx, question, opts)
+	// 降级的检索结果不保存
+	if err == nil && len(opts.Degraded.Tiers()) == 0 {
+		r.sessions.SaveRetrieval(opts.Session, question, scope, vector, results)
+	}
+	return results, err
+}
+
+// 影响检索结果的条件，条件相同的会话检索才能复用
+func (o searchOptions) sessionScope() string {
+	return fmt.Sprint(o.Category, "|", o.Entity, "|", o.exclusionKey(), "|", o.Period.key(), "|", o.LanguageFilter, "|",
+		o.Profile, "|", o.EF, "|", o.NProbe, "|", o.NumCandidates, "|", o.TopK)
+}
+
+func (r *RAGSystem) retrieveFresh(ctx context.Context, question string, opts searchOptions) ([]SearchResult, error) {
+	config := r.settings().Retrieval
+	if opts.TopK > 0 {
+		return r.searchTopK(ctx, question, opts.TopK, opts)
+	}
+	if !config.Adaptive {
+		return r.searchTopK(ctx, question, config.TopK, opts)
+	}
+
+	candidates, results, used, err := r.searchCandidates(ctx, question, config.MaxK, opts)
+	if err != nil {
+		return nil, err
+	}
+	selected := selectAdaptive(results, config.ScoreGap, config.TokenBudget, r.tokens)
+	fmt.Printf("🎯 自适应检索: 候选 %d 个分块，使用 %d 个\n", len(results), len(selected))
+	r.recordTrace(question, used, "adaptive", config.MaxK, candidates, selected)
+	return selected, nil
+}
+
+// 检索topK个分块并记录检索轨迹
+func (r *RAGSystem) searchTopK(ctx context.Context, question string, topK int, opts searchOptions) ([]SearchResult, error) {
+	candidates, results, used, err := r.searchCandidates(ctx, question, topK, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.recordTrace(question, used, "fixed", topK, candidates, results)
+	return results, nil
+}
+
+// 检索候选分块并选出topK个，附上可信度、许可和原文链接；限制了单文档分块数时多取候选，超额的分块让给其他文档。
+// 返回按可信度加权排序后的全部候选、选出的分块和检索实际使用的条件
+func (r *RAGSystem) searchCandidates(ctx context.Context, question string, topK int, opts searchOptions) ([]SearchResult, []SearchResult, searchOptions, error) {
+	maxPerDoc := r.settings().Retrieval.MaxPerDoc
+	if opts.Features == nil {
+		opts.Features = r.resolveFeatures(question, opts.Category)
+	}
+	// 按文档限额、重排和多样性选取都需要更多候选
+	limit := topK
+	if maxPerDoc > 0 || opts.Features[featureRerank] || opts.Features[featureMMR] {
+		limit = topK * 3
+	}
+	results, used, err := r.searchDocuments(ctx, question, limit, opts)
+	if err != nil {
+		return nil, nil, used, err
+	}
+	applyTrust(results, r.config.Trust)
+	// applyLicense原地过滤，候选需要单独保留一份
+	candidates := append([]SearchResult(nil), results...)
+	results = applyLicense(results, r.config.License)
+	results = dropExpired(results, time.Now())
+	results = r.refineResults(ctx, question, results, opts.Features)
+	results = r.applyRules(ctx, question, results, opts)
+	results = capPerDocument(results, maxPerDoc, topK)
+	r.linkOriginals(results)
+	r.linkLocations(results)
+	return candidates, results, used, nil
+}
+
+// 软配额：按分数顺序每个文档最多取maxPerDoc个分块，其他文档的分块不够填满topK时再用超额的分块补足
+func capPerDocument(results []SearchResult, maxPerDoc, topK int) []SearchResult {
+	if maxPerDoc <= 0 {
+		if len(results) > topK {
+			results = results[:topK]
+		}
+		return results
+	}
+
+	counts := make(map[string]int)
+	selected := make([]bool, len(results))
+	picked := 0
+	for i, result := range results {
+		if picked == topK {
+			break
+		}
+		if counts[result.DocID] < maxPerDoc {
+			counts[result.DocID]++
+			selected[i] = true
+			picked++
+		}
+	}
+	for i := range results {
+		if picked == topK {
+			break
+		}
+		if !selected[i] {
+			selected[i] = true
+			picked++
+		}
+	}
+
+	capped := make([]SearchResult, 0, picked)
+	for i, result := range results {
+		if selected[i] {
+			capped = append(capped, result)
+		}
+	}
+	return capped
+}
+
+// 按分数从高到低依次纳入分块，分数出现断崖或超出token预算时停止；至少保留一个分块
+func selectAdaptive(results []SearchResult, scoreGap float64, tokenBudget int, tokens tokenCounter) []SearchResult {
+	if len(results) == 0 {
+		return results
+	}
+
+	used := tokens.Count(results[0].Content)
+	selected := results[:1]
+	for i := 1; i < len(results); i++ {
+		if results[i-1].Score-results[i].Score > scoreGap {
+			break
+		}
+		used += tokens.Count(results[i].Content)
+		if used > tokenBudget {
+			break
+		}
+		selected = results[:i+1]
+	}
+	return selected
+}
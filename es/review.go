@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 人工审核配置：HTTP问答中最相关分块的分数低于Threshold的回答先进入待审核队列，
+// 审核人批准（可修改回答）后才发布给提问者，批准时可以加入FAQ，之后相同或相近的问题直接使用审核过的回答
+type ReviewConfig struct {
+	Threshold     float64 // <=0时不开启人工审核
+	DB            string
+	FAQSimilarity float64 // FAQ语义命中的余弦相似度阈值，<=0时只做精确匹配
+}
+
+func loadReviewConfig() ReviewConfig {
+	return ReviewConfig{
+		Threshold:     getEnvAsFloat("REVIEW_THRESHOLD", 0),
+		DB:            getEnv("REVIEW_DB", ".review.db"),
+		FAQSimilarity: getEnvAsFloat("FAQ_SIMILARITY", 0.92),
+	}
+}
+
+const (
+	reviewPending  = "pending"
+	reviewApproved = "approved"
+	reviewRejected = "rejected"
+)
+
+// 回答进入人工审核时返回给提问者的提示
+const reviewPendingNotice = "这个问题的回答正在人工审核，审核通过后可通过 GET /review/item 查询。"
+
+// 一条待审核或已审核的回答
+type reviewItem struct {
+	ID         int64          `json:"id"`
+	Question   string         `json:"question"`
+	Draft      string         `json:"draft"`            // 模型生成的回答
+	Answer     string         `json:"answer,omitempty"` // 发布给提问者的回答，批准时可能经过修改，驳回时为回复提问者的说明
+	Score      float64        `json:"score"`            // 最相关分块的分数
+	Sources    []SearchResult `json:"sources,omitempty"`
+	Status     string         `json:"status"` // pending、approved、rejected
+	Reviewer   string         `json:"reviewer,omitempty"`
+	FAQ        bool           `json:"faq,omitempty"`    // 已加入FAQ
+	DocID      string         `json:"doc_id,omitempty"` // 已加入知识库的文档ID
+	CreatedAt  time.Time      `json:"created_at"`
+	ReviewedAt *time.Time     `json:"reviewed_at,omitempty"`
+}
+
+// FAQ中的一个问答，由审核通过的回答加入
+type faqEntry struct {
+	Question string
+	Answer   string
+	Sources  []SearchResult
+	vector   []float32
+}
+
+// 人工审核队列和FAQ，存储在SQLite中；FAQ启动时全部加载到内存
+type reviewQueue struct {
+	db       *sql.DB
+	config   ReviewConfig
+	embedder embedder
+	cipher   *fieldCipher // 加密问题、回答、引用和FAQ，未配置时为nil
+
+	mu    sync.RWMutex
+	faqs  []*faqEntry
+	exact map[string]*faqEntry
+}
+
+var errReviewNotFound = errors.New("审核记录不存在")
+
+func openReviewQueue(config ReviewConfig, e embedder, c *fieldCipher) (*reviewQueue, error) {
+	db, err := sql.Open("sqlite3", config.DB)
+	if err != nil {
+		return nil, fmt.Errorf("打开审核库失败: %w", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS reviews (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		question TEXT NOT NULL,
+		draft TEXT NOT NULL,
+		answer TEXT NOT NULL DEFAULT '',
+		score REAL NOT NULL,
+		sources TEXT NOT NULL,
+		status TEXT NOT NULL,
+		reviewer TEXT NOT NULL DEFAULT '',
+		faq INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL,
+		reviewed_at INTEGER
+	);
+	CREATE TABLE IF NOT EXISTS faqs (
+		question TEXT PRIMARY KEY,
+		answer TEXT NOT NULL,
+		sources TEXT NOT NULL,
+		vector TEXT NOT NULL,
+		review_id INTEGER NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS review_documents (
+		review_id INTEGER PRIMARY KEY,
+		doc_id TEXT NOT NULL,
+		promoted_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化审核库失败: %w", err)
+	}
+	q := &reviewQueue{db: db, config: config, embedder: e, cipher: c, exact: make(map[string]*faqEntry)}
+	if err := q.loadFAQs(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *reviewQueue) Close() error {
+	if q == nil {
+		return nil
+	}
+	return q.db.Close()
+}
+
+func (q *reviewQueue) loadFAQs() error {
+	rows, err := q.db.Query(`SELECT question, answer, sources, vector FROM faqs ORDER BY created_at`)
+	if err != nil {
+		return fmt.Errorf("读取FAQ失败: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry faqEntry
+		var sources, vector string
+		if err := rows.Scan(&entry.Question, &entry.Answer, &sources, &vector); err != nil {
+			return fmt.Errorf("读取FAQ失败: %w", err)
+		}
+		if err := q.cipher.OpenAll(&entry.Question, &entry.Answer, &sources, &vector); err != nil {
+			return fmt.Errorf("读取FAQ失败: %w", err)
+		}
+		_ = json.Unmarshal([]byte(sources), &entry.Sources)
+		_ = json.Unmarshal([]byte(vector), &entry.vector)
+		q.addFAQ(&entry)
+	}
+	return rows.Err()
+}
+
+func (q *reviewQueue) addFAQ(entry *faqEntry) {
+	key := normalizeQuestion(entry.Question)
+	if old, ok := q.exact[key]; ok {
+		for i, e := range q.faqs {
+			if e == old {
+				q.faqs = append(q.faqs[:i], q.faqs[i+1:]...)
+				break
+			}
+		}
+	}
+	q.exact[key] = entry
+	q.faqs = append(q.faqs, entry)
+}
+
+// 检索结果是否需要人工审核：没有检索到分块或最相关分块的分数低于阈值
+func (q *reviewQueue) needsReview(results []SearchResult) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.RLock()
+	threshold := q.config.Threshold
+	q.mu.RUnlock()
+	return len(results) == 0 || bestScore(results) < threshold
+}
+
+// 热更新审核阈值，未开启人工审核时忽略
+func (q *reviewQueue) SetThreshold(threshold float64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.config.Threshold = threshold
+}
+
+// 回答放入待审核队列
+func (q *reviewQueue) Park(question, draft string, sources []SearchResult) (*reviewItem, error) {
+	data, err := json.Marshal(sources)
+	if err != nil {
+		return nil, err
+	}
+	item := &reviewItem{Question: question, Draft: draft, Sources: sources, Status: reviewPending, CreatedAt: time.Now()}
+	if len(sources) > 0 {
+		item.Score = bestScore(sources)
+	}
+	res, err := q.db.Exec(
+		`INSERT INTO reviews (question, draft, score, sources, status, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		q.cipher.Seal(question), q.cipher.Seal(draft), item.Score, q.cipher.Seal(string(data)), reviewPending, item.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("写入审核队列失败: %w", err)
+	}
+	if item.ID, err = res.LastInsertId(); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+const reviewColumns = `id, question, draft, answer, score, sources, status, reviewer, faq, COALESCE(doc_id, ''), created_at, reviewed_at`
+
+// 审核记录关联加入知识库的文档
+const reviewTables = `reviews LEFT JOIN review_documents ON review_documents.review_id = reviews.id`
+
+// 按状态列出审核记录，status为空时列出全部，最新的在前
+func (q *reviewQueue) List(status string, limit int) ([]reviewItem, error) {
+	rows, err := q.db.Query(
+		`SELECT `+reviewColumns+` FROM `+reviewTables+` WHERE ? = '' OR status = ? ORDER BY id DESC LIMIT ?`,
+		status, status, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询审核队列失败: %w", err)
+	}
+	defer rows.Close()
+
+	items := []reviewItem{}
+	for rows.Next() {
+		item, err := q.scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+func (q *reviewQueue) Get(id int64) (*reviewItem, error) {
+	item, err := q.scanItem(q.db.QueryRow(`SELECT `+reviewColumns+` FROM `+reviewTables+` WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errReviewNotFound
+	}
+	return item, err
+}
+
+func (q *reviewQueue) scanItem(row interface{ Scan(...interface{}) error }) (*reviewItem, error) {
+	var item reviewItem
+	var sources string
+	var createdAt int64
+	var reviewedAt sql.NullInt64
+	err := row.Scan(&item.ID, &item.Question, &item.Draft, &item.Answer, &item.Score, &sources, &item.Status, &item.Reviewer, &item.FAQ, &item.DocID, &createdAt, &reviewedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取审核记录失败: %w", err)
+	}
+	if err := q.cipher.OpenAll(&item.Question, &item.Draft, &item.Answer, &sources); err != nil {
+		return nil, fmt.Errorf("读取审核记录 %d 失败: %w", item.ID, err)
+	}
+	_ = json.Unmarshal([]byte(sources), &item.Sources)
+	item.CreatedAt = time.Unix(createdAt, 0)
+	if reviewedAt.Valid {
+		t := time.Unix(reviewedAt.Int64, 0)
+		item.ReviewedAt = &t
+	}
+	return &item, nil
+}
+
+// 审核一条待审核的回答。批准时answer为空则发布原回答，faq为true时加入FAQ；驳回时answer为回复提问者的说明
+func (q *reviewQueue) Decide(ctx context.Context, id int64, approve bool, answer, reviewer string, faq bool) (*reviewItem, error) {
+	item, err := q.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Status != reviewPending {
+		return nil, fmt.Errorf("审核记录 %d 已处理: %s", id, item.Status)
+	}
+
+	item.Status = reviewRejected
+	if approve {
+		item.Status = reviewApproved
+		if answer == "" {
+			answer = item.Draft
+		}
+	} else {
+		faq = false
+		if answer == "" {
+			answer = defaultRefuseTemplate
+		}
+	}
+	if faq {
+		if err := q.promote(ctx, item, answer); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	res, err := q.db.Exec(
+		`UPDATE reviews SET status = ?, answer = ?, reviewer = ?, faq = ?, reviewed_at = ? WHERE id = ? AND status = ?`,
+		item.Status, q.cipher.Seal(answer), reviewer, faq, now.Unix(), id, reviewPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("更新审核记录失败: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("审核记录 %d 已被他人处理", id)
+	}
+	item.Answer, item.Reviewer, item.FAQ, item.ReviewedAt = answer, reviewer, faq, &now
+	return item, nil
+}
+
+// 审核通过的回答加入FAQ，同一问题以最新的回答为准
+func (q *reviewQueue) promote(ctx context.Context, item *reviewItem, answer string) error {
+	entry := &faqEntry{Question: item.Question, Answer: answer, Sources: item.Sources}
+	if q.config.FAQSimilarity > 0 {
+		vector, err := q.embedder.Embed(ctx, normalizeQuestion(item.Question))
+		if err != nil {
+			return fmt.Errorf("FAQ问题向量化失败: %w", err)
+		}
+		entry.vector = vector
+	}
+	sources, err := json.Marshal(entry.Sources)
+	if err != nil {
+		return err
+	}
+	vector, err := json.Marshal(entry.vector)
+	if err != nil {
+		return err
+	}
+	_, err = q.db.Exec(
+		`INSERT OR REPLACE INTO faqs (question, answer, sources, vector, review_id, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		q.cipher.SealDeterministic(item.Question), q.cipher.Seal(answer), q.cipher.Seal(string(sources)), q.cipher.Seal(string(vector)), item.ID, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("写入FAQ失败: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.addFAQ(entry)
+	return nil
+}
+
+// 记录审核记录加入知识库后的文档ID
+func (q *reviewQueue) recordDocument(reviewID int64, docID string) error {
+	_, err := q.db.Exec(
+		`INSERT OR REPLACE INTO review_documents (review_id, doc_id, promoted_at) VALUES (?, ?, ?)`,
+		reviewID, docID, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("记录加入知识库的文档失败: %w", err)
+	}
+	return nil
+}
+
+// 加入知识库的文档的来源，可在SOURCE_TRUST、SOURCE_LICENSE中按来源配置
+const reviewSource = "人工审核"
+
+// 审核通过的回答作为新文档加入知识库，之后的检索可以直接命中；元数据记录原问题、审核记录和回答依据的文档。
+// 文档ID由审核记录ID生成，重复加入时替换
+func (r *RAGSystem) promoteReview(item *reviewItem, title string) error {
+	if item.Status != reviewApproved {
+		return fmt.Errorf("审核记录 %d 未通过审核: %s", item.ID, item.Status)
+	}
+	if title == "" {
+		title = item.Question
+	}
+	if runes := []rune(title); len(runes) > maxTitleLen {
+		title = string(runes[:maxTitleLen])
+	}
+	// 发布的回答末尾可能带有署名，文档正文只保留回答本身
+	content, _, _ := strings.Cut(item.Answer, attributionHeader)
+
+	meta := map[string]interface{}{
+		"source":    reviewSource,
+		"review_id": float64(item.ID),
+		"question":  item.Question,
+	}
+	if item.Reviewer != "" {
+		meta["reviewer"] = item.Reviewer
+	}
+	if item.ReviewedAt != nil {
+		meta["reviewed_at"] = item.ReviewedAt.Format(time.RFC3339)
+	}
+	var basedOn []interface{}
+	seen := make(map[string]bool)
+	for _, source := range item.Sources {
+		if source.DocID != "" && !seen[source.DocID] {
+			seen[source.DocID] = true
+			basedOn = append(basedOn, source.DocID)
+		}
+	}
+	if len(basedOn) > 0 {
+		meta["based_on"] = basedOn
+		if category := metaString(item.Sources[0].Meta, "category"); category != "" {
+			meta["category"] = category
+		}
+	}
+
+	documents, err := r.config.DocLimits.validate([]documentPayload{{
+		ID:      fmt.Sprintf("review:%d", item.ID),
+		Title:   title,
+		Content: content,
+		Meta:    meta,
+	}}, r.categoryVectorDim)
+	if err != nil {
+		return err
+	}
+	if err := r.replaceDocumentsWith(documents, r.config.Consistency); err != nil {
+		return err
+	}
+	if err := r.reviews.recordDocument(item.ID, documents[0].ID); err != nil {
+		return err
+	}
+	item.DocID = documents[0].ID
+	return nil
+}
+
+// 查找FAQ：问题完全相同直接命中，否则按问题向量的余弦相似度查找最相近的问题
+func (q *reviewQueue) LookupFAQ(ctx context.Context, question string) (*faqEntry, float64, bool) {
+	if q == nil {
+		return nil, 0, false
+	}
+	key := normalizeQuestion(question)
+	q.mu.RLock()
+	entry, ok := q.exact[key]
+	empty := len(q.faqs) == 0
+	q.mu.RUnlock()
+	if ok {
+		return entry, 1, true
+	}
+	if empty || q.config.FAQSimilarity <= 0 {
+		return nil, 0, false
+	}
+
+	vector, err := q.embedder.Embed(ctx, key)
+	if err != nil {
+		fmt.Printf("⚠️  FAQ向量化失败: %v\n", err)
+		return nil, 0, false
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	var best *faqEntry
+	bestSimilarity := q.config.FAQSimilarity
+	for _, entry := range q.faqs {
+		if similarity := cosineSimilarity(vector, entry.vector); similarity >= bestSimilarity {
+			best, bestSimilarity = entry, similarity
+		}
+	}
+	return best, bestSimilarity, best != nil
+}
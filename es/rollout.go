@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+)
+
+// 发布配置：把提示词、对话模型、embedding模型和集合/索引版本绑定为命名的profile，
+// 新profile与旧profile并行部署，按百分比逐步切流，可随时回滚。serve运行中修改发布配置文件随配置热更新生效
+type deployProfile struct {
+	Index          string `json:"index"`                     // 集合/索引
+	Model          string `json:"model,omitempty"`           // 对话模型，不填时使用DEEPSEEK_MODEL
+	SystemPrompt   string `json:"system_prompt,omitempty"`   // 系统提示词，不填时使用RAG_SYSTEM_PROMPT
+	EmbeddingModel string `json:"embedding_model,omitempty"` // 向量化模型，同时生成检索向量和问题向量，不填时使用默认配置
+	EmbeddingDim   int    `json:"embedding_dim,omitempty"`   // embedding_model输出的维度，与集合/索引的vector字段一致
+}
+
+type rolloutState struct {
+	Alias     string                   `json:"alias,omitempty"` // 指向active集合/索引的别名，供不感知profile的调用方使用
+	Profiles  map[string]deployProfile `json:"profiles"`
+	Active    string                   `json:"active"`
+	Candidate string                   `json:"candidate,omitempty"`
+	Percent   int                      `json:"percent,omitempty"`  // 切给candidate的流量百分比
+	Previous  string                   `json:"previous,omitempty"` // 上次promote前的active，用于回滚
+}
+
+// 读取发布配置，文件不存在时返回nil，表示不启用profile
+func loadRollout(path string) (*rolloutState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取发布配置失败: %w", err)
+	}
+	var state rolloutState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("解析发布配置失败: %w", err)
+	}
+	if err := state.validate(); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (s *rolloutState) validate() error {
+	for name, profile := range s.Profiles {
+		if profile.Index == "" {
+			return fmt.Errorf("profile %s 未指定index", name)
+		}
+		if profile.EmbeddingModel != "" && profile.EmbeddingDim <= 0 {
+			return fmt.Errorf("profile %s 指定了embedding_model，需填写embedding_dim", name)
+		}
+	}
+	if _, ok := s.Profiles[s.Active]; !ok {
+		return fmt.Errorf("active profile不存在: %q", s.Active)
+	}
+	if s.Candidate != "" {
+		if _, ok := s.Profiles[s.Candidate]; !ok {
+			return fmt.Errorf("candidate profile不存在: %q", s.Candidate)
+		}
+	}
+	if s.Percent < 0 || s.Percent > 100 {
+		return fmt.Errorf("percent应在0-100之间，当前为 %d", s.Percent)
+	}
+	return nil
+}
+
+func (s *rolloutState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("保存发布配置失败: %w", err)
+	}
+	return nil
+}
+
+// 按profile覆盖配置：检索只查询profile的集合/索引，写入仍使用默认集合/索引。
+// 指定了embedding_model时问题和检索向量都由该模型生成，集合/索引需用同样的EMBEDDING_MODEL和EMBEDDING_VECTOR_DIM构建
+func (s *rolloutState) configFor(base Config, name string) Config {
+	return s.Profiles[name].apply(base)
+}
+
+func (profile deployProfile) apply(base Config) Config {
+	config := base
+	config.Federation = []FederatedIndex{{Name: profile.Index, Weight: 1}}
+	if profile.Model != "" {
+		config.DeepSeekModel = profile.Model
+	}
+	if profile.SystemPrompt != "" {
+		config.SystemPrompt = profile.SystemPrompt
+	}
+	if profile.EmbeddingModel != "" {
+		config.Embedding.Model = profile.EmbeddingModel
+		config.Embedding.VectorDim = profile.EmbeddingDim
+	}
+	return config
+}
+
+// 分流状态的简要描述，用于配置审计
+func (s *rolloutState) summary() string {
+	if s.Candidate == "" {
+		return "active=" + s.Active
+	}
+	return fmt.Sprintf("active=%s candidate=%s percent=%d", s.Active, s.Candidate, s.Percent)
+}
+
+// 同一问题总是落在同一个profile，缓存和用户看到的回答保持稳定
+func (s *rolloutState) route(key string) string {
+	if s.Candidate == "" || s.Percent == 0 {
+		return s.Active
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	if int(h.Sum32()%100) < s.Percent {
+		return s.Candidate
+	}
+	return s.Active
+}
+
+// serve按发布配置分流，每个参与分流的profile一个RAGSystem
+type rolloutRouter struct {
+	path    string
+	base    Config
+	prepare func(*RAGSystem) // 新建的RAGSystem共享serve的规则和固定回答
+
+	mu      sync.RWMutex
+	state   *rolloutState
+	systems map[string]*RAGSystem
+	built   map[string]deployProfile // 创建各RAGSystem时的profile定义
+	retired []*RAGSystem             // 热更新后不再分流的RAGSystem，处理中的请求可能仍在使用，退出时关闭
+}
+
+func newRolloutRouter(path string, state *rolloutState, base Config, prepare func(*RAGSystem)) (*rolloutRouter, error) {
+	router := &rolloutRouter{path: path, base: base, prepare: prepare}
+	systems, built, err := router.build(state)
+	if err != nil {
+		return nil, err
+	}
+	router.state, router.systems, router.built = state, systems, built
+	return router, nil
+}
+
+// 为参与分流的profile准备RAGSystem：定义没有变化的沿用现有的，其他新建；失败时关闭已新建的
+func (rr *rolloutRouter) build(state *rolloutState) (map[string]*RAGSystem, map[string]deployProfile, error) {
+	systems := make(map[string]*RAGSystem)
+	built := make(map[string]deployProfile)
+	var created []*RAGSystem
+	for _, name := range []string{state.Active, state.Candidate} {
+		if name == "" || systems[name] != nil {
+			continue
+		}
+		profile := state.Profiles[name]
+		if rag, ok := rr.systems[name]; ok && rr.built[name] == profile {
+			systems[name], built[name] = rag, profile
+			continue
+		}
+		rag, err := NewRAGSystem(profile.apply(rr.base))
+		if err != nil {
+			for _, rag := range created {
+				rag.Close()
+			}
+			return nil, nil, fmt.Errorf("初始化profile %s 失败: %w", name, err)
+		}
+		if rr.prepare != nil {
+			rr.prepare(rag)
+		}
+		created = append(created, rag)
+		systems[name], built[name] = rag, profile
+	}
+	return systems, built, nil
+}
+
+// 重新读取发布配置，新的RAGSystem全部创建成功后整体切换；读取或校验失败时保持原配置
+func (rr *rolloutRouter) reload() (old, state *rolloutState, err error) {
+	state, err = loadRollout(rr.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state == nil {
+		return nil, nil, fmt.Errorf("发布配置 %s 已删除，停用分流需重启", rr.path)
+	}
+	rr.mu.RLock()
+	systems, built, err := rr.build(state)
+	rr.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	for name, rag := range rr.systems {
+		if systems[name] != rag {
+			rr.retired = append(rr.retired, rag)
+		}
+	}
+	old = rr.state
+	rr.state, rr.systems, rr.built = state, systems, built
+	return old, state, nil
+}
+
+func (rr *rolloutRouter) pick(key string) (string, *RAGSystem) {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	name := rr.state.route(key)
+	return name, rr.systems[name]
+}
+
+func (rr *rolloutRouter) active() *RAGSystem {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	return rr.systems[rr.state.Active]
+}
+
+// 对当前参与分流的每个RAGSystem执行fn
+func (rr *rolloutRouter) each(fn func(*RAGSystem)) {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	for _, rag := range rr.systems {
+		fn(rag)
+	}
+}
+
+func (rr *rolloutRouter) Close() {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	for _, rag := range rr.systems {
+		rag.Close()
+	}
+	for _, rag := range rr.retired {
+		rag.Close()
+	}
+}
+
+// 问答和检索使用的RAGSystem：启用发布配置时按问题分流，否则使用默认配置
+func (s *apiServer) ragFor(question string) (string, *RAGSystem) {
+	if s.rollout == nil {
+		return "", s.rag
+	}
+	return s.rollout.pick(question)
+}
+
+// 管理发布配置：查看状态、设置candidate和切流比例、promote、回滚
+func runRollout(args []string) error {
+	fs := flag.NewFlagSet("rollout", flag.ExitOnError)
+	path := fs.String("file", getEnv("ROLLOUT_FILE", "rollout.json"), "发布配置文件")
+	candidate := fs.String("candidate", "", "并行部署的新profile")
+	percent := fs.Int("percent", -1, "切给candidate的流量百分比")
+	promote := fs.Bool("promote", false, "candidate转为active，别名指向其集合/索引")
+	rollback := fs.Bool("rollback", false, "有candidate时撤下candidate，否则切回上一个active")
+	_ = fs.Parse(args)
+
+	state, err := loadRollout(*path)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("发布配置 %s 不存在", *path)
+	}
+
+	switch {
+	case *promote && *rollback:
+		return fmt.Errorf("-promote 和 -rollback 不能同时使用")
+	case *promote:
+		if state.Candidate == "" {
+			return fmt.Errorf("没有candidate可以promote")
+		}
+		state.Previous, state.Active = state.Active, state.Candidate
+		state.Candidate, state.Percent = "", 0
+		if err := pointRolloutAlias(state); err != nil {
+			return err
+		}
+		fmt.Printf("🚀 %s 已成为active\n", state.Active)
+	case *rollback:
+		if state.Candidate != "" {
+			fmt.Printf("↩️  已撤下candidate %s，流量全部回到 %s\n", state.Candidate, state.Active)
+			state.Candidate, state.Percent = "", 0
+			break
+		}
+		if state.Previous == "" {
+			return fmt.Errorf("没有可以回滚的profile")
+		}
+		if _, ok := state.Profiles[state.Previous]; !ok {
+			return fmt.Errorf("profile %s 已不存在，无法回滚", state.Previous)
+		}
+		state.Active, state.Previous = state.Previous, state.Active
+		if err := pointRolloutAlias(state); err != nil {
+			return err
+		}
+		fmt.Printf("↩️  已回滚到 %s\n", state.Active)
+	default:
+		if *candidate != "" {
+			state.Candidate = *candidate
+		}
+		if *percent >= 0 {
+			state.Percent = *percent
+		}
+	}
+
+	if err := state.validate(); err != nil {
+		return err
+	}
+	if err := state.save(*path); err != nil {
+		return err
+	}
+	printRollout(state)
+	return nil
+}
+
+// 别名指向active的集合/索引，未配置别名时跳过
+func pointRolloutAlias(state *rolloutState) error {
+	if state.Alias == "" {
+		return nil
+	}
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+	index := state.Profiles[state.Active].Index
+	if err := rag.pointAlias(context.Background(), state.Alias, index); err != nil {
+		return fmt.Errorf("切换别名 %s 失败: %w", state.Alias, err)
+	}
+	fmt.Printf("🔀 别名 %s -> %s\n", state.Alias, index)
+	return nil
+}
+
+func printRollout(state *rolloutState) {
+	names := make([]string, 0, len(state.Profiles))
+	for name := range state.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("📦 发布配置:")
+	for _, name := range names {
+		share := 0
+		switch name {
+		case state.Candidate:
+			share = state.Percent
+		case state.Active:
+			share = 100
+			if state.Candidate != "" {
+				share -= state.Percent
+			}
+		}
+		fmt.Printf("  - %s: %s，流量 %d%%\n", name, state.Profiles[name].Index, share)
+	}
+}
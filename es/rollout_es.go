@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/updatealiases"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// 在一次_aliases请求中把别名从所有索引上移除并加到indexName，切换是原子的
+func (r *RAGSystem) pointAlias(ctx context.Context, alias, indexName string) error {
+	all, mustExist := "*", false
+	_, err := r.typedClient.Indices.UpdateAliases().Request(&updatealiases.Request{
+		Actions: []types.IndicesAction{
+			{Remove: &types.RemoveAction{Index: &all, Alias: &alias, MustExist: &mustExist}},
+			{Add: &types.AddAction{Index: &indexName, Alias: &alias}},
+		},
+	}).Do(ctx)
+	return err
+}
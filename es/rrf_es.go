@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// RRF的平滑参数k，与Milvus版HYBRID_RRF_K的默认值一致
+const esRRFK = 60.0
+
+// ES版没有混合检索配置，rrf开关未配置时关闭
+func (r *RAGSystem) featureDefault(name string) bool {
+	return false
+}
+
+// 开启rrf时在应用内融合：向量检索与带相同过滤条件的文本检索各取topK，按各路1/(k+名次)之和重新排序。
+// 文本检索失败时告警并只用向量检索的结果
+func (r *RAGSystem) fuseTextResults(ctx context.Context, indexName, query string, topK int, filters []types.Query, vectorResults []SearchResult) []SearchResult {
+	req := chunkSearchRequest(topK)
+	req.Query = &types.Query{Bool: &types.BoolQuery{Must: []types.Query{*multiMatchQuery(query, "title", "content")}, Filter: filters}}
+	esClient, primary := r.readClient()
+	textResults, err := searchChunks(ctx, esClient, indexName, req, 100, nil)
+	if err != nil {
+		r.recordRead(primary, readFailure(err))
+		fmt.Printf("⚠️  RRF的文本检索失败，只使用向量检索结果: %v\n", err)
+		return vectorResults
+	}
+	r.recordRead(primary, nil)
+	return fuseRRF(topK, vectorResults, textResults)
+}
+
+// 按RRF融合多路结果，分数归一化到0-1：各路都排第一时为1
+func fuseRRF(topK int, lists ...[]SearchResult) []SearchResult {
+	scores := make(map[string]float64)
+	byID := make(map[string]SearchResult)
+	var order []string
+	for _, list := range lists {
+		for rank, result := range list {
+			if _, ok := byID[result.ID]; !ok {
+				byID[result.ID] = result
+				order = append(order, result.ID)
+			}
+			scores[result.ID] += 1 / (esRRFK + float64(rank+1))
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+	if len(order) > topK {
+		order = order[:topK]
+	}
+	fused := make([]SearchResult, len(order))
+	for i, id := range order {
+		fused[i] = byID[id]
+		fused[i].Score = min(scores[id]*(esRRFK+1)/float64(len(lists)), 1)
+	}
+	return fused
+}
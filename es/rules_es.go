@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// 读取文档的全部分块，用于置顶检索结果中没有的文档
+func (r *RAGSystem) documentChunks(ctx context.Context, docID string) ([]SearchResult, error) {
+	req := chunkSearchRequest(10000)
+	req.Query = &types.Query{Bool: &types.BoolQuery{Filter: []types.Query{termQuery("doc_id", docID)}}}
+	esClient, primary := r.readClient()
+	results, err := searchChunks(ctx, esClient, r.config.IndexName, req, 1, nil)
+	if err != nil {
+		r.recordRead(primary, readFailure(err))
+		return nil, storeError(ctx, "读取置顶文档失败", err)
+	}
+	r.recordRead(primary, nil)
+	return results, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3/MinIO连接配置
+type S3Config struct {
+	Endpoint  string // 例如 s3.amazonaws.com、localhost:9000
+	AccessKey string
+	SecretKey string
+	Region    string
+	UseSSL    bool
+}
+
+func loadS3Config() S3Config {
+	return S3Config{
+		Endpoint:  getEnv("S3_ENDPOINT", "s3.amazonaws.com"),
+		AccessKey: getEnv("S3_ACCESS_KEY", ""),
+		SecretKey: getEnv("S3_SECRET_KEY", ""),
+		Region:    getEnv("S3_REGION", ""),
+		UseSSL:    getEnvAsBool("S3_USE_SSL", true),
+	}
+}
+
+func newS3Client(config S3Config) (*minio.Client, error) {
+	s3Client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建S3客户端失败: %w", err)
+	}
+	return s3Client, nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 需要对比的分析组件
+var analysisSections = []string{"analyzer", "char_filter", "tokenizer"}
+
+// 对比索引mapping和分析设置。ES可以给已有索引追加字段和子字段（例如新的元数据字段），
+// 字段类型、分析器、向量维度以及分析组件的变更都需要新建索引重新写入
+func (r *RAGSystem) inspectSchema(ctx context.Context) (string, []string, []schemaDiff, error) {
+	indexName := r.config.IndexName
+	currentMappings, err := r.currentMappings(ctx)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	currentAnalysis, err := r.currentAnalysis(ctx)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	expected := r.indexMapping()
+	expectedMappings := expected["mappings"].(map[string]interface{})
+	expectedAnalysis := normalizeSetting(expected["settings"].(map[string]interface{})["analysis"]).(map[string]interface{})
+
+	currentProperties, _ := currentMappings["properties"].(map[string]interface{})
+	var lines []string
+	listProperties("", currentProperties, &lines)
+	for _, section := range analysisSections {
+		components, _ := currentAnalysis[section].(map[string]interface{})
+		for _, name := range sortedKeys(components) {
+			lines = append(lines, fmt.Sprintf("%-24s %s", "analysis."+section+"."+name, describeSetting(components[name])))
+		}
+	}
+	currentSource := sourceExcludes(currentMappings)
+	if currentSource != "" {
+		lines = append(lines, fmt.Sprintf("%-24s %s", "_source.excludes", currentSource))
+	}
+
+	// 追加的字段只能引用索引中已有的分析器
+	analyzers := make(map[string]bool)
+	if components, ok := currentAnalysis["analyzer"].(map[string]interface{}); ok {
+		for name := range components {
+			analyzers[name] = true
+		}
+	}
+	custom, _ := expectedAnalysis["analyzer"].(map[string]interface{})
+	available := func(property map[string]interface{}) bool {
+		analyzer, _ := property["analyzer"].(string)
+		_, isCustom := custom[analyzer]
+		return !isCustom || analyzers[analyzer]
+	}
+
+	var diffs []schemaDiff
+	expectedProperties := expectedMappings["properties"].(map[string]interface{})
+	diffProperties("", currentProperties, expectedProperties, available, &diffs)
+	for _, name := range sortedKeys(currentProperties) {
+		if _, ok := expectedProperties[name]; !ok {
+			diffs = append(diffs, schemaDiff{Path: name, Kind: schemaExtra, Current: describeProperty(currentProperties[name].(map[string]interface{}))})
+		}
+	}
+
+	if expectedSource := sourceExcludes(expectedMappings); currentSource != expectedSource {
+		diffs = append(diffs, schemaDiff{Path: "_source.excludes", Kind: schemaChanged, Current: currentSource, Expected: expectedSource})
+	}
+
+	for _, section := range analysisSections {
+		have, _ := currentAnalysis[section].(map[string]interface{})
+		want, _ := expectedAnalysis[section].(map[string]interface{})
+		for _, name := range sortedKeys(want) {
+			path := "analysis." + section + "." + name
+			if _, ok := have[name]; !ok {
+				diffs = append(diffs, schemaDiff{Path: path, Kind: schemaMissing, Expected: describeSetting(want[name])})
+			} else if !reflect.DeepEqual(have[name], want[name]) {
+				diffs = append(diffs, schemaDiff{Path: path, Kind: schemaChanged, Current: describeSetting(have[name]), Expected: describeSetting(want[name])})
+			}
+		}
+	}
+	return indexName, lines, diffs, nil
+}
+
+// 逐个追加缺少的字段。已有文档不会自动写入新字段，需要重新入库或执行 _update_by_query
+func (r *RAGSystem) applySchema(ctx context.Context, diffs []schemaDiff) error {
+	properties := r.indexMapping()["mappings"].(map[string]interface{})["properties"].(map[string]interface{})
+	for _, diff := range diffs {
+		patch, err := mappingPatch(properties, strings.Split(diff.Path, "."))
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(patch)
+		if err != nil {
+			return fmt.Errorf("序列化mapping失败: %w", err)
+		}
+		res, err := r.elasticClient.Indices.PutMapping(
+			[]string{r.config.IndexName},
+			bytes.NewReader(body),
+			r.elasticClient.Indices.PutMapping.WithContext(ctx),
+		)
+		if err != nil {
+			return fmt.Errorf("追加字段%s失败: %w", diff.Path, err)
+		}
+		res.Body.Close()
+		if res.IsError() {
+			return fmt.Errorf("追加字段%s错误: %s", diff.Path, res.String())
+		}
+		fmt.Printf("➕ 已追加字段 %s\n", diff.Path)
+	}
+	fmt.Println("💡 已有文档不会自动写入新字段，需要重新入库，或执行 _update_by_query 按新mapping重新索引；新增的向量字段用 go run ./es reembed 补写")
+	return nil
+}
+
+// 错误提示中运行子命令的方式
+const commandPrefix = "go run ./es"
+
+// 需要重建时的迁移步骤
+func schemaReindexGuide(config Config) string {
+	return fmt.Sprintf(`  已有字段的类型、分析器和索引的分析设置不能在线修改，需要按当前配置新建索引：
+  1. 使用新的索引名重新初始化，例如 INDEX_NAME=%s_v2 go run ./es，再重新入库（bootstrap、sitemap等同步命令）；
+     或在新索引创建后复制旧索引的文档：POST _reindex {"source":{"index":"%s"},"dest":{"index":"%s_v2"}}
+  2. 用 go run ./es diff -base %s -candidate %s_v2 对比新旧索引的回答
+  3. 确认无误后把 INDEX_NAME 切换为新索引（或使用 rollout 命令逐步切换），再删除旧索引
+  演示环境也可以直接 go run ./es 重新初始化当前索引（会删除已有数据）`,
+		config.IndexName, config.IndexName, config.IndexName, config.IndexName, config.IndexName)
+}
+
+// 读取索引当前的mappings
+func (r *RAGSystem) currentMappings(ctx context.Context) (map[string]interface{}, error) {
+	res, err := r.elasticClient.Indices.GetMapping(
+		r.elasticClient.Indices.GetMapping.WithContext(ctx),
+		r.elasticClient.Indices.GetMapping.WithIndex(r.config.IndexName),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询mapping失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("查询mapping错误: %s", res.String())
+	}
+	var response map[string]struct {
+		Mappings map[string]interface{} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("解析mapping失败: %w", err)
+	}
+	for _, index := range response {
+		return index.Mappings, nil
+	}
+	return nil, fmt.Errorf("索引 %s 不存在", r.config.IndexName)
+}
+
+// 读取索引当前的分析设置
+func (r *RAGSystem) currentAnalysis(ctx context.Context) (map[string]interface{}, error) {
+	res, err := r.elasticClient.Indices.GetSettings(
+		r.elasticClient.Indices.GetSettings.WithContext(ctx),
+		r.elasticClient.Indices.GetSettings.WithIndex(r.config.IndexName),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询索引设置失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("查询索引设置错误: %s", res.String())
+	}
+	var response map[string]struct {
+		Settings struct {
+			Index struct {
+				Analysis map[string]interface{} `json:"analysis"`
+			} `json:"index"`
+		} `json:"settings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("解析索引设置失败: %w", err)
+	}
+	for _, index := range response {
+		return normalizeSetting(index.Settings.Index.Analysis).(map[string]interface{}), nil
+	}
+	return map[string]interface{}{}, nil
+}
+
+// 递归对比字段，对象字段对比properties，多字段对比fields
+func diffProperties(prefix string, current, expected map[string]interface{}, available func(map[string]interface{}) bool, diffs *[]schemaDiff) {
+	for _, name := range sortedKeys(expected) {
+		path := prefix + name
+		want := expected[name].(map[string]interface{})
+		have, ok := current[name].(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, schemaDiff{Path: path, Kind: schemaMissing, Expected: describeProperty(want), Safe: available(want)})
+			continue
+		}
+		if describeProperty(have) != describeProperty(want) {
+			*diffs = append(*diffs, schemaDiff{Path: path, Kind: schemaChanged, Current: describeProperty(have), Expected: describeProperty(want)})
+			continue
+		}
+		for _, nested := range []string{"properties", "fields"} {
+			if children, ok := want[nested].(map[string]interface{}); ok {
+				existing, _ := have[nested].(map[string]interface{})
+				childPrefix := path + "."
+				if nested == "fields" {
+					childPrefix = path + ".fields."
+				}
+				diffProperties(childPrefix, existing, children, available, diffs)
+			}
+		}
+	}
+}
+
+// 把字段路径转换为PUT _mapping的请求体，例如 meta.people -> {"properties":{"meta":{"properties":{"people":{...}}}}}；
+// 追加多字段时需要带上所属字段的完整定义
+func mappingPatch(properties map[string]interface{}, path []string) (map[string]interface{}, error) {
+	property, ok := properties[path[0]].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("预期mapping中没有字段: %s", path[0])
+	}
+	if len(path) == 1 || path[1] == "fields" {
+		return map[string]interface{}{"properties": map[string]interface{}{path[0]: property}}, nil
+	}
+	children, _ := property["properties"].(map[string]interface{})
+	child, err := mappingPatch(children, path[1:])
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"properties": map[string]interface{}{path[0]: child}}, nil
+}
+
+// 列出当前字段，包括多字段和对象的子字段
+func listProperties(prefix string, properties map[string]interface{}, lines *[]string) {
+	for _, name := range sortedKeys(properties) {
+		property, _ := properties[name].(map[string]interface{})
+		*lines = append(*lines, fmt.Sprintf("%-24s %s", prefix+name, describeProperty(property)))
+		if children, ok := property["properties"].(map[string]interface{}); ok {
+			listProperties(prefix+name+".", children, lines)
+		}
+		if fields, ok := property["fields"].(map[string]interface{}); ok {
+			listProperties(prefix+name+".fields.", fields, lines)
+		}
+	}
+}
+
+// 字段类型和影响索引的参数，例如 text analyzer=rag_text、dense_vector dims=4 similarity=cosine
+func describeProperty(property map[string]interface{}) string {
+	kind, _ := property["type"].(string)
+	if kind == "" {
+		kind = "object"
+	}
+	desc := kind
+	analyzer, _ := property["analyzer"].(string)
+	if kind == "text" && analyzer == "" {
+		analyzer = "standard"
+	}
+	if analyzer != "" {
+		desc += " analyzer=" + analyzer
+	}
+	for _, key := range []string{"dims", "similarity"} {
+		if value, ok := property[key]; ok {
+			desc += fmt.Sprintf(" %s=%v", key, value)
+		}
+	}
+	return desc
+}
+
+// 分析组件的简要说明，较长的列表（例如字符映射规则）只显示条数
+func describeSetting(value interface{}) string {
+	setting, _ := value.(map[string]interface{})
+	var parts []string
+	for _, key := range sortedKeys(setting) {
+		switch v := setting[key].(type) {
+		case []interface{}:
+			if len(v) > 3 {
+				parts = append(parts, fmt.Sprintf("%s=[%d项]", key, len(v)))
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s=%v", key, v))
+		default:
+			parts = append(parts, fmt.Sprintf("%s=%v", key, v))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ES返回的设置值都是字符串，对比前把预期值也统一转换为字符串
+func normalizeSetting(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeSetting(item)
+		}
+		return normalized
+	case []string:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = item
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeSetting(item)
+		}
+		return normalized
+	case nil:
+		return map[string]interface{}{}
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// _source排除的字段，未设置时为空
+func sourceExcludes(mappings map[string]interface{}) string {
+	source, _ := mappings["_source"].(map[string]interface{})
+	var excludes []string
+	switch v := source["excludes"].(type) {
+	case []string:
+		excludes = v
+	case []interface{}:
+		for _, item := range v {
+			excludes = append(excludes, fmt.Sprint(item))
+		}
+	}
+	sort.Strings(excludes)
+	return strings.Join(excludes, ",")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
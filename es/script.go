@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 中文繁简统一：入库和检索时把文本统一转换为同一种字形再计算向量、关键词词项（Milvus）和ES分词，
+// 用户输入繁体也能命中简体语料。只影响匹配，不改动存储和展示的原文
+const (
+	scriptNone        = "none"
+	scriptSimplified  = "simplified"
+	scriptTraditional = "traditional"
+)
+
+// 常用繁体字和对应的简体字，每项前一个字为繁体。一简对多繁时转繁体取第一个出现的繁体字，
+// 入库和检索使用同一张表，个别字转换不准确不影响匹配
+const traditionalPairs = "萬万 與与 後后 醜丑 專专 業业 叢丛 東东 絲丝 兩两 嚴严 喪丧 個个 豐丰 臨临 為为 麗丽 舉举 義义 烏乌 樂乐 喬乔 習习 鄉乡 書书 買买 亂乱 爭争 於于 虧亏 雲云 亞亚 產产 畝亩 親亲 億亿 僅仅 從从 侖仑 倉仓 儀仪 們们 價价 眾众 優优 夥伙 會会 傘伞 偉伟 傳传 傷伤 倫伦 偽伪 體体 傭佣 僉佥 俠侠 侶侣 僥侥 偵侦 側侧 僑侨 儈侩 儂侬 俁俣 係系 儲储 兒儿 兌兑 黨党 蘭兰 關关 興兴 茲兹 養养 獸兽 內内 岡冈 冊册 寫写 軍军 農农 馮冯 衝冲 決决 況况 凍冻 淨净 準准 涼凉 減减 湊凑 凜凛 幾几 鳳凤 憑凭 凱凯 擊击 鑿凿 芻刍 劃划 劉刘 則则 剛刚 創创 刪删 別别 劊刽 劑剂 劍剑 剝剥 劇剧 勸劝 辦办 務务 動动 勵励 勁劲 勞劳 勢势 勳勋 勻匀 匯汇 區区 醫医 華华 協协 單单 賣卖 盧卢 衛卫 卻却 廠厂 廳厅 歷历 厲厉 壓压 厭厌 廁厕 參参 雙双 發发 變变 敘叙 疊叠 號号 嘆叹 嘰叽 嚇吓 呂吕 嗎吗 啟启 吳吴 員员 嗚呜 響响 啞哑 喲哟 嘩哗 喚唤 嘯啸 團团 園园 圍围 圖图 國国 圓圆 聖圣 場场 壞坏 塊块 堅坚 壇坛 壩坝 墳坟 墜坠 壘垒 埡垭 墊垫 塹堑 墮堕 壯壮 聲声 殼壳 壺壶 處处 備备 復复 夠够 頭头 誇夸 夾夹 奪夺 奮奋 獎奖 婦妇 媽妈 嫵妩 嬌娇 孫孙 學学 寧宁 寶宝 實实 寵宠 審审 憲宪 宮宫 寬宽 賓宾 寢寝 對对 尋寻 導导 壽寿 將将 爾尔 塵尘 嘗尝 層层 屆届 屬属 歲岁 豈岂 嶼屿 島岛 嶺岭 崗岗 峽峡 幣币 帥帅 師师 帳帐 帶带 幫帮 幹干 廣广 莊庄 慶庆 廬庐 庫库 應应 廟庙 廢废 開开 異异 棄弃 張张 彌弥 彎弯 彈弹 強强 歸归 當当 錄录 彥彦 徹彻 徑径 徵征 憶忆 懷怀 態态 憐怜 總总 戀恋 懇恳 惡恶 惱恼 悅悦 懸悬 驚惊 慘惨 慣惯 憤愤 願愿 懶懒 戲戏 戰战 戶户 撲扑 執执 擴扩 掃扫 揚扬 擾扰 撫抚 搶抢 護护 報报 擔担 擬拟 攏拢 揀拣 擁拥 攔拦 擰拧 撥拨 擇择 掛挂 摯挚 攣挛 揮挥 撓挠 擋挡 擠挤 損损 換换 據据 擄掳 擲掷 撣掸 攙搀 擱搁 攝摄 擺摆 搖摇 攜携 攪搅 數数 斂敛 斃毙 斕斓 鬥斗 斬斩 斷断 無无 舊旧 時时 曠旷 昇升 顯显 晉晋 曬晒 曉晓 暈晕 暫暂 曆历 術术 樸朴 機机 殺杀 雜杂 權权 條条 來来 楊杨 傑杰 極极 構构 槍枪 楓枫 櫃柜 檸柠 棟栋 欄栏 樹树 樣样 橋桥 樁桩 夢梦 檢检 樓楼 槳桨 標标 橫横 櫻樱 歡欢 歐欧 殘残 殲歼 毀毁 氣气 漢汉 湯汤 溝沟 沒没 滬沪 淚泪 潑泼 澤泽 潔洁 灑洒 濃浓 濤涛 潤润 漲涨 漁渔 漸渐 溫温 灣湾 濕湿 滿满 滅灭 濾滤 濫滥 潛潜 瀏浏 燈灯 災灾 燦灿 煉炼 爐炉 熱热 煩烦 燒烧 營营 愛爱 爺爷 牆墙 牽牵 犧牺 狀状 猶犹 獨独 獄狱 獲获 貓猫 獻献 現现 環环 瑪玛 瓏珑 電电 畫画 暢畅 疇畴 療疗 瘡疮 癢痒 瘋疯 癡痴 盤盘 蓋盖 盡尽 監监 盞盏 睜睁 瞞瞒 矯矫 礦矿 碼码 確确 礎础 禮礼 禍祸 離离 禿秃 種种 積积 稱称 穩稳 窮穷 竊窃 竅窍 豎竖 競竞 筆笔 築筑 節节 範范 簡简 籃篮 類类 糧粮 糾纠 紅红 紀纪 約约 級级 紋纹 純纯 紙纸 紛纷 組组 細细 終终 絕绝 給给 絡络 統统 經经 結结 綁绑 綜综 綠绿 維维 網网 緊紧 緒绪 線线 練练 緣缘 編编 緩缓 縣县 績绩 織织 繞绕 繼继 續续 纖纤 纜缆 罷罢 羅罗 聞闻 聯联 聰聪 職职 聽听 肅肃 脅胁 腦脑 膠胶 臉脸 膚肤 腳脚 臟脏 臺台 艦舰 藝艺 蘋苹 萊莱 葉叶 著着 藍蓝 蔣蒋 薦荐 藥药 蘇苏 蟲虫 虛虚 蝦虾 補补 裝装 裡里 製制 襪袜 見见 規规 覓觅 視视 覽览 覺觉 觀观 觸触 計计 訂订 認认 討讨 讓让 訓训 議议 訊讯 記记 講讲 許许 論论 設设 訪访 證证 評评 識识 詞词 試试 詩诗 話话 該该 詳详 誠诚 誤误 說说 請请 諸诸 讀读 課课 誰谁 調调 談谈 謝谢 謎谜 譯译 譽誉 讚赞 貝贝 負负 財财 貢贡 貨货 販贩 貧贫 購购 貴贵 費费 貼贴 貿贸 資资 賦赋 質质 賴赖 贏赢 賽赛 贈赠 趕赶 趨趋 躍跃 跡迹 踐践 蹤踪 軌轨 車车 軟软 轉转 輕轻 載载 較较 輔辅 輛辆 輪轮 輯辑 輸输 辭辞 辯辩 邊边 遼辽 達达 遷迁 過过 邁迈 運运 還还 這这 進进 遠远 違违 連连 遲迟 適适 選选 遞递 遺遗 遙遥 郵邮 鄰邻 鄭郑 醬酱 釋释 裏里 針针 釣钓 鈕钮 鉛铅 銀银 銳锐 銷销 鋼钢 錯错 錢钱 鍵键 鎖锁 鏡镜 鐘钟 鐵铁 鑰钥 長长 門门 閃闪 閉闭 問问 閒闲 間间 悶闷 閱阅 闊阔 陣阵 陰阴 陸陆 陳陈 陽阳 隊队 階阶 際际 隨随 險险 隱隐 雞鸡 難难 雖虽 霧雾 靈灵 靜静 韓韩 頁页 頂顶 項项 順顺 須须 預预 領领 頻频 題题 額额 顏颜 顧顾 風风 飛飞 飯饭 飲饮 餓饿 餘余 館馆 馬马 駕驾 驗验 騎骑 驅驱 髮发 鬆松 魚鱼 鮮鲜 鳥鸟 鳴鸣 麥麦 黃黄 點点 齊齐 齒齿 龍龙 龜龟 麼么 檔档 鏈链 語语 簽签 賬账 測测 閆闫 閻阎 訴诉 謀谋 謂谓 謹谨 譜谱 贊赞 隸隶 啓启 鍾钟 勝胜 湧涌 濱滨 鑑鉴 屍尸 鹽盐 簾帘 蠟蜡 誌志 趙赵 蕭萧 鄧邓 譚谭 賈贾 鄒邹 龔龚 聶聂 韋韦 閔闵 魯鲁 鄔邬"
+
+// 繁简转换器，nil表示不转换
+type scriptConverter struct {
+	table map[rune]rune
+}
+
+func newScriptConverter(target string) (*scriptConverter, error) {
+	switch target {
+	case "", scriptNone:
+		return nil, nil
+	case scriptSimplified, scriptTraditional:
+	default:
+		return nil, fmt.Errorf("未知的CHINESE_SCRIPT: %s，可选 simplified、traditional、none", target)
+	}
+
+	c := &scriptConverter{table: make(map[rune]rune)}
+	for _, pair := range strings.Fields(traditionalPairs) {
+		chars := []rune(pair)
+		traditional, simplified := chars[0], chars[1]
+		if target == scriptSimplified {
+			c.table[traditional] = simplified
+		} else if _, ok := c.table[simplified]; !ok {
+			c.table[simplified] = traditional
+		}
+	}
+	return c, nil
+}
+
+// 转换为目标字形
+func (c *scriptConverter) Convert(text string) string {
+	if c == nil {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if to, ok := c.table[r]; ok {
+			return to
+		}
+		return r
+	}, text)
+}
+
+// ES mapping字符过滤器的映射规则，例如 "國 => 国"
+func (c *scriptConverter) charMappings() []string {
+	mappings := make([]string, 0, len(c.table))
+	for from, to := range c.table {
+		mappings = append(mappings, fmt.Sprintf("%c => %c", from, to))
+	}
+	sort.Strings(mappings)
+	return mappings
+}
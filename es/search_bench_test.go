@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+)
+
+// 生成n条命中的检索响应，每4个分块有1个以content_zstd压缩保存
+func benchSearchResponse(n int) []byte {
+	hits := make([]map[string]interface{}, n)
+	for i := range hits {
+		source := map[string]interface{}{
+			"doc_id": fmt.Sprintf("doc_%04d", i/5),
+			"title":  fmt.Sprintf("文档标题 %d", i/5),
+			"meta":   map[string]interface{}{"category": "技术", "source": "bench", "date": "2026-10-01", "revision": float64(i % 7)},
+		}
+		content := strings.Repeat(fmt.Sprintf("分块%d的正文内容，用于检索响应解析基准。", i), 12)
+		if i%4 == 0 {
+			source["content_zstd"] = compressChunk(content)
+		} else {
+			source["content"] = content
+		}
+		hits[i] = map[string]interface{}{
+			"_index":  "rag_demo",
+			"_id":     fmt.Sprintf("doc_%04d#%d", i/5, i%5),
+			"_score":  1.9 - float64(i)/float64(n),
+			"_source": source,
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"took":      12,
+		"timed_out": false,
+		"_shards":   map[string]interface{}{"total": 1, "successful": 1, "skipped": 0, "failed": 0},
+		"hits": map[string]interface{}{
+			"total":     map[string]interface{}{"value": n, "relation": "eq"},
+			"max_score": 1.9,
+			"hits":      hits,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// 改为类型化解析之前的写法：解析为map后逐层类型断言
+func decodeSearchMap(data []byte, scoreScale float64) ([]SearchResult, error) {
+	var searchResponse map[string]interface{}
+	if err := json.Unmarshal(data, &searchResponse); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	hits, ok := searchResponse["hits"].(map[string]interface{})
+	if !ok {
+		return results, nil
+	}
+	hitsList, ok := hits["hits"].([]interface{})
+	if !ok {
+		return results, nil
+	}
+	for _, hit := range hitsList {
+		hitMap, ok := hit.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		score, _ := hitMap["_score"].(float64)
+		source, ok := hitMap["_source"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := hitMap["_id"].(string)
+		docID, _ := source["doc_id"].(string)
+		if docID == "" {
+			docID = id
+		}
+		title, _ := source["title"].(string)
+		content, _ := source["content"].(string)
+		if encoded, ok := source["content_zstd"].(string); ok && encoded != "" {
+			compressed, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return results, err
+			}
+			if content, err = decompressChunk(compressed); err != nil {
+				return results, err
+			}
+		}
+		meta, _ := source["meta"].(map[string]interface{})
+		results = append(results, SearchResult{ID: id, DocID: docID, Title: title, Content: content, Score: min(score/scoreScale, 1.0), Meta: meta})
+	}
+	return results, nil
+}
+
+// 完整的typedapi解析：响应解码为search.Response，_source再解码为esChunkSource
+func decodeSearchTyped(data []byte, scoreScale float64) ([]SearchResult, error) {
+	var res search.Response
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		converted := esHit{}
+		if hit.Id_ != nil {
+			converted.ID = *hit.Id_
+		}
+		if hit.Score_ != nil {
+			score := float64(*hit.Score_)
+			converted.Score = &score
+		}
+		if err := json.Unmarshal(hit.Source_, &converted.Source); err != nil {
+			return results, err
+		}
+		result, err := chunkResult(converted, scoreScale)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// 当前的解析：只解码命中用到的字段
+func decodeSearchHits(data []byte, scoreScale float64) ([]SearchResult, error) {
+	return decodeSearchResults(bytes.NewReader(data), scoreScale, nil)
+}
+
+// 解析1000条命中的检索响应：go test -run ^$ -bench SearchDecode -benchmem ./es
+// map为最初的写法，typedapi为search.Response完整解析（耗时约为map的3倍、内存约3.4倍），hits为当前的写法
+func BenchmarkSearchDecode(b *testing.B) {
+	data := benchSearchResponse(1000)
+	for _, bench := range []struct {
+		name   string
+		decode func([]byte, float64) ([]SearchResult, error)
+	}{
+		{"map", decodeSearchMap},
+		{"typedapi", decodeSearchTyped},
+		{"hits", decodeSearchHits},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				results, err := bench.decode(data, 2.0)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(results) != 1000 {
+					b.Fatalf("解析出 %d 条结果", len(results))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// 密钥引用：环境变量（或.env）的取值写成引用时，读取配置时从密钥服务获取，.env中不必保存明文：
+//
+//	vault:<挂载点>/<路径>#<字段>  HashiCorp Vault KV，VAULT_ADDR、VAULT_TOKEN（或Vault Agent写入的VAULT_TOKEN_FILE）、VAULT_NAMESPACE，
+//	                            VAULT_KV_VERSION默认2；只有一个字段时可以省略#<字段>
+//	secret-file:<路径>           挂载的密钥文件：Kubernetes Secret、Secrets Store CSI挂载的云密钥管理服务密钥、Docker secrets
+//	secret-cmd:<命令>            命令的标准输出，用于云厂商KMS解密或密钥管理服务的CLI，例如
+//	                            aws secretsmanager get-secret-value --secret-id rag/deepseek --query SecretString --output text
+//
+// 取到的值缓存SECRETS_CACHE_SECONDS秒（0为不缓存），过期后重新获取以支持轮换，获取失败时继续使用上次的值并告警。
+// DeepSeek、本地模型和向量接口的API key以及ElasticSearch凭据每次请求时取当前值，轮换后无需重启，返回401时立即重新获取；
+// 其他密钥（Telegram、IMAP、S3等）在命令启动时读取。`go run . secrets` 检查所有引用能否取到
+type SecretsConfig struct {
+	VaultAddr      string
+	VaultToken     string
+	VaultTokenFile string // 每次访问Vault时重新读取，配合Vault Agent自动续期
+	VaultNamespace string
+	VaultKVVersion int
+	CacheTTL       time.Duration
+	Timeout        time.Duration
+}
+
+// 密钥服务自身的配置不支持引用，直接读取环境变量
+func loadSecretsConfig() SecretsConfig {
+	return SecretsConfig{
+		VaultAddr:      strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"),
+		VaultToken:     os.Getenv("VAULT_TOKEN"),
+		VaultTokenFile: os.Getenv("VAULT_TOKEN_FILE"),
+		VaultNamespace: os.Getenv("VAULT_NAMESPACE"),
+		VaultKVVersion: getEnvAsInt("VAULT_KV_VERSION", 2),
+		CacheTTL:       time.Duration(getEnvAsInt("SECRETS_CACHE_SECONDS", 300)) * time.Second,
+		Timeout:        time.Duration(getEnvAsInt("SECRETS_TIMEOUT_SECONDS", 10)) * time.Second,
+	}
+}
+
+const (
+	secretVaultPrefix = "vault:"
+	secretFilePrefix  = "secret-file:"
+	secretCmdPrefix   = "secret-cmd:"
+)
+
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretVaultPrefix) || strings.HasPrefix(value, secretFilePrefix) || strings.HasPrefix(value, secretCmdPrefix)
+}
+
+// 缓存的密钥，值只保存在内存中，不写日志
+type cachedSecret struct {
+	value      string
+	fetched    time.Time
+	refreshing bool // 正在重新获取，其他请求先使用旧值
+}
+
+type secretStore struct {
+	once    sync.Once // .env加载后第一次读取引用时加载配置
+	config  SecretsConfig
+	client  *http.Client
+	mu      sync.Mutex
+	entries map[string]*cachedSecret
+}
+
+var secrets = &secretStore{entries: make(map[string]*cachedSecret)}
+
+// 环境变量的取值是引用时换成密钥，获取失败时告警并返回空值，由各配置的非空校验报错
+func resolveSecret(key, value string) string {
+	if !isSecretRef(value) {
+		return value
+	}
+	secret, err := secrets.get(value)
+	if err != nil {
+		fmt.Printf("⚠️  读取 %s 的密钥失败: %v\n", key, err)
+	}
+	return secret
+}
+
+func (s *secretStore) get(ref string) (string, error) {
+	s.once.Do(func() {
+		s.config = loadSecretsConfig()
+		s.client = &http.Client{Timeout: s.config.Timeout}
+	})
+	s.mu.Lock()
+	entry, ok := s.entries[ref]
+	if ok && (entry.refreshing || time.Since(entry.fetched) < s.config.CacheTTL) {
+		s.mu.Unlock()
+		return entry.value, nil
+	}
+	if ok {
+		entry.refreshing = true
+	}
+	s.mu.Unlock()
+
+	value, err := s.fetch(ref)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !ok {
+		if err != nil {
+			return "", err
+		}
+		s.entries[ref] = &cachedSecret{value: value, fetched: time.Now()}
+		return value, nil
+	}
+	entry.refreshing = false
+	entry.fetched = time.Now()
+	if err != nil {
+		// 轮换期间密钥服务不可用时不中断服务，下个缓存周期再重试
+		fmt.Printf("⚠️  刷新密钥失败，继续使用缓存的值: %v\n", err)
+		return entry.value, nil
+	}
+	entry.value = value
+	return value, nil
+}
+
+// 丢弃缓存，下次读取时重新获取（保留旧值，获取失败时仍可使用）
+func (s *secretStore) invalidate(ref string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[ref]; ok {
+		entry.fetched = time.Time{}
+	}
+}
+
+func (s *secretStore) fetch(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretVaultPrefix):
+		return s.fetchVault(strings.TrimPrefix(ref, secretVaultPrefix))
+	case strings.HasPrefix(ref, secretFilePrefix):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, secretFilePrefix))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", strings.TrimPrefix(ref, secretCmdPrefix))
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("执行密钥命令失败: %w", err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+}
+
+// 读取Vault KV中的字段，KV v2的路径在挂载点后插入data/
+func (s *secretStore) fetchVault(ref string) (string, error) {
+	if s.config.VaultAddr == "" {
+		return "", fmt.Errorf("VAULT_ADDR不能为空")
+	}
+	path, field, _ := strings.Cut(ref, "#")
+	mount, rest, ok := strings.Cut(strings.Trim(path, "/"), "/")
+	if !ok {
+		return "", fmt.Errorf("Vault路径应为<挂载点>/<路径>: %s", path)
+	}
+	if s.config.VaultKVVersion == 2 {
+		path = mount + "/data/" + rest
+	}
+	token := s.config.VaultToken
+	if s.config.VaultTokenFile != "" {
+		data, err := os.ReadFile(s.config.VaultTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("读取VAULT_TOKEN_FILE失败: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.config.VaultAddr+"/v1/"+(&url.URL{Path: path}).EscapedPath(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if s.config.VaultNamespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.config.VaultNamespace)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("访问Vault失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("读取Vault密钥 %s 失败: %s", path, resp.Status)
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("解析Vault响应失败: %w", err)
+	}
+	data := body.Data
+	if s.config.VaultKVVersion == 2 {
+		data, _ = body.Data["data"].(map[string]interface{})
+	}
+	if field == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("Vault密钥 %s 有 %d 个字段，需用#指定字段", path, len(data))
+		}
+		for name := range data {
+			field = name
+		}
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("Vault密钥 %s 没有字段 %s", path, field)
+	}
+	return value, nil
+}
+
+// 环境变量key是密钥引用时包装传输层：每次请求按当前密钥设置Authorization，返回401时丢弃缓存；不是引用时原样返回base
+func rotatingAuth(base http.RoundTripper, key string, header func(secret string) string) http.RoundTripper {
+	if !isSecretRef(os.Getenv(key)) {
+		return base
+	}
+	return &secretAuthTransport{base: base, key: key, header: header}
+}
+
+func bearerAuth(secret string) string {
+	return "Bearer " + secret
+}
+
+func basicAuth(username string) func(password string) string {
+	return func(password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+}
+
+type secretAuthTransport struct {
+	base   http.RoundTripper
+	key    string
+	header func(secret string) string
+}
+
+func (t *secretAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if secret := getEnv(t.key, ""); secret != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", t.header(secret))
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		secrets.invalidate(os.Getenv(t.key))
+	}
+	return resp, err
+}
+
+// secrets命令：检查环境变量和.env中的密钥引用能否取到，只输出长度，不输出密钥
+func runSecrets(args []string) error {
+	fs := flag.NewFlagSet("secrets", flag.ExitOnError)
+	_ = fs.Parse(args)
+	godotenv.Load()
+
+	var keys []string
+	for _, item := range os.Environ() {
+		if key, value, ok := strings.Cut(item, "="); ok && isSecretRef(value) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		fmt.Println("没有使用密钥引用的配置")
+		return nil
+	}
+	sort.Strings(keys)
+	failed := 0
+	for _, key := range keys {
+		ref := os.Getenv(key)
+		source, _, _ := strings.Cut(ref, ":")
+		value, err := secrets.get(ref)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("❌ %s（%s）: %v\n", key, source, err)
+		case value == "":
+			failed++
+			fmt.Printf("❌ %s（%s）: 取到空值\n", key, source)
+		default:
+			fmt.Printf("✅ %s（%s）: %d 个字符\n", key, source, len(value))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个密钥引用读取失败", failed)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import "net/http"
+
+// ElasticSearch凭据是密钥引用时每次请求按当前值认证，轮换密码或API key后无需重建客户端；
+// 没有使用引用时返回nil，由客户端按Config中的凭据认证
+func elasticAuthTransport(config Config) http.RoundTripper {
+	var transport http.RoundTripper
+	if config.ElasticAPIKey != "" {
+		transport = rotatingAuth(http.DefaultTransport, "ELASTIC_API_KEY", func(key string) string { return "ApiKey " + key })
+	} else if config.ElasticUsername != "" {
+		transport = rotatingAuth(http.DefaultTransport, "ELASTIC_PASSWORD", basicAuth(config.ElasticUsername))
+	}
+	if transport == http.DefaultTransport {
+		return nil
+	}
+	return transport
+}
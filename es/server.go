@@ -0,0 +1,881 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP服务
+type apiServer struct {
+	rag         *RAGSystem
+	rollout     *rolloutRouter // 配置了发布profile时，问答和检索按比例分流
+	history     *evalHistory
+	queries     *queryLog   // 查询日志，未配置QUERY_LOG_DB时为nil
+	slo         *sloTracker // 接口耗时和错误的SLO统计，未配置SLOS时为nil
+	cancels     cancelCounter
+	idempotency *idempotencyStore // 幂等键和首次请求的响应，IDEMPOTENCY_TTL_MINUTES=0时为nil
+}
+
+// 客户端中途断开的请求数，按接口统计
+type cancelCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (c *cancelCounter) add(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	c.counts[path]++
+}
+
+func (c *cancelCounter) Snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := make(map[string]int64, len(c.counts))
+	for path, count := range c.counts {
+		snapshot[path] = count
+	}
+	return snapshot
+}
+
+// serve命令：启动HTTP服务
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", getEnv("SERVER_ADDR", ":8080"), "监听地址")
+	_ = fs.Parse(args)
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+	// 启动时发现向量维度与索引不一致，避免写入和检索中途才报错
+	if err := rag.checkVectorDim(context.Background()); err != nil {
+		return err
+	}
+
+	history, err := openEvalHistory(rag.config.EvalSchedule.HistoryDB)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+	if rag.config.EvalSchedule.Time != "" {
+		if err := rag.startEvalSchedule(history); err != nil {
+			return err
+		}
+		fmt.Printf("🧪 定时评测已启用: 每天 %s\n", rag.config.EvalSchedule.Time)
+	}
+	if rag.config.Review.Threshold > 0 {
+		reviews, err := openReviewQueue(rag.config.Review, rag.embedder, rag.encryption)
+		if err != nil {
+			return err
+		}
+		defer reviews.Close()
+		rag.reviews = reviews
+		fmt.Printf("🧑‍⚖️ 人工审核已启用: 分数低于 %.2f 的回答需审核后发布，审核页面: /review\n", rag.config.Review.Threshold)
+	}
+	rules, err := openRuleStore(rag.config.Rules)
+	if err != nil {
+		return err
+	}
+	defer rules.Close()
+	if rules != nil {
+		rag.rules = rules
+		fmt.Printf("📌 检索规则已启用: %d 条规则，通过 /admin/rules 管理\n", len(rules.List()))
+	}
+	overrides, err := openOverrideStore(rag.config.Overrides, rag.embedder)
+	if err != nil {
+		return err
+	}
+	defer overrides.Close()
+	if overrides != nil {
+		rag.overrides = overrides
+		fmt.Printf("📜 固定回答已启用: %d 条，通过 /admin/overrides 管理\n", len(overrides.List()))
+	}
+	if rag.startWarmer() {
+		fmt.Printf("🔥 热门问题预生成已启用: 每 %s 刷新前 %d 个问题\n", rag.config.Warm.Interval, rag.config.Warm.TopN)
+	}
+	if rag.config.Maintenance.Schedule != "" {
+		if err := rag.startMaintenanceSchedule(); err != nil {
+			return err
+		}
+		fmt.Printf("🧹 定时存储维护已启用: 每天 %s 运行%s，最近 %s 内入库过时推迟\n", rag.config.Maintenance.Schedule, maintenanceAction, rag.config.Maintenance.Quiet)
+	}
+	if rag.startExpirySweeper() {
+		fmt.Printf("⌛ 过期文档清理已启用: 每 %s 检查一次，处理方式 %s\n", rag.config.Expiry.Interval, rag.config.Expiry.Action)
+	}
+
+	queries, err := openQueryLog(rag.config.QueryLog, rag.encryption)
+	if err != nil {
+		return err
+	}
+	defer queries.Close()
+
+	server := &apiServer{rag: rag, history: history, queries: queries, slo: newSLOTracker(rag.config.SLO), idempotency: newIdempotencyStore(rag.config.Idempotency)}
+	if server.slo != nil {
+		server.slo.start()
+		defer server.slo.Close()
+		fmt.Printf("🎯 SLO跟踪已启用: %d 个目标，统计窗口 %s\n", len(rag.config.SLO.Objectives), rag.config.SLO.Window)
+	}
+	rolloutFile := getEnv("ROLLOUT_FILE", "rollout.json")
+	state, err := loadRollout(rolloutFile)
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		share := func(system *RAGSystem) { system.rules, system.overrides = rules, overrides }
+		if server.rollout, err = newRolloutRouter(rolloutFile, state, rag.config, share); err != nil {
+			return err
+		}
+		defer server.rollout.Close()
+		printRollout(state)
+	}
+	if rag.startConfigWatcher(server.rollout) {
+		fmt.Printf("🔄 配置热更新已启用: 每 %s 检查 %s、术语表、回答策略和发布配置\n", rag.config.Reload.Interval, rag.config.Reload.EnvFile)
+	}
+	handler := server.routes()
+	if rag.config.Profiling {
+		handler = withPprof(handler)
+		fmt.Println("📈 已开启性能分析: /debug/pprof/")
+	}
+	fmt.Printf("🌐 HTTP服务已启动: %s，接口文档: /docs\n", *addr)
+	return http.ListenAndServe(*addr, handler)
+}
+
+// 一个REST接口。OpenAPI文档和Go客户端都由接口表生成，新增接口只需在apiRoutes中登记
+type apiRoute struct {
+	Method     string
+	Path       string
+	Name       string // 客户端方法名
+	Tag        string
+	Summary    string
+	Query      []apiParam  // 查询参数，均为字符串
+	Headers    []apiParam  // 请求头参数，均为字符串
+	Request    interface{} // 请求体类型的零值，nil表示没有请求体
+	Response   interface{} // 响应体类型的零值
+	Idempotent bool        // 接受Idempotency-Key请求头，重试时返回首次请求的响应
+	Admin      bool        // 管理接口，需带ADMIN_TOKEN
+	handle     func(s *apiServer, w http.ResponseWriter, req *http.Request)
+}
+
+type apiParam struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// 错误响应
+type errorResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`                // 稳定的错误码，例如 store_unavailable、llm_rate_limited、no_relevant_docs
+	Retryable bool   `json:"retryable,omitempty"` // 稍后重试可能成功
+}
+
+var apiRoutes = []apiRoute{
+	{
+		Method: http.MethodPost, Path: "/ask", Name: "Ask", Tag: "ask", Idempotent: true,
+		Summary:  "RAG问答，支持答案缓存",
+		Request:  askRequest{},
+		Response: askResponse{},
+		handle:   (*apiServer).handleAsk,
+	},
+	{
+		Method: http.MethodPost, Path: "/retrieve", Name: "Retrieve", Tag: "retrieve",
+		Summary:  "只检索不生成，返回相关分块",
+		Request:  retrieveRequest{},
+		Response: retrieveResponse{},
+		handle:   (*apiServer).handleRetrieve,
+	},
+	{
+		Method: http.MethodPost, Path: "/ingest", Name: "Ingest", Tag: "ingest", Idempotent: true,
+		Summary:  "写入文档，已存在的同ID文档会被替换",
+		Request:  ingestRequest{},
+		Response: ingestResponse{},
+		handle:   (*apiServer).handleIngest,
+	},
+	{
+		Method: http.MethodPost, Path: "/documents", Name: "PushDocuments", Tag: "documents", Idempotent: true,
+		Summary:  "推送文档：校验标题、正文、元数据和可选的预计算向量后分块入库，同ID文档会被替换",
+		Request:  documentsRequest{},
+		Response: documentsResponse{},
+		handle:   (*apiServer).handleDocuments,
+	},
+	{
+		Method: http.MethodPut, Path: "/documents/item", Name: "UpdateDocument", Tag: "documents", Idempotent: true,
+		Summary: "更新单个文档，已存在的文档需在If-Match中带上ETag，文档已被他人修改时返回412",
+		Query: []apiParam{
+			{Name: "id", Description: "文档ID", Required: true},
+			{Name: "consistency", Description: "eventual、strong，不填时使用WRITE_CONSISTENCY"},
+		},
+		Headers:  []apiParam{{Name: "If-Match", Description: "读取时得到的ETag，创建新文档时不填"}},
+		Request:  documentPayload{},
+		Response: documentVersionResponse{},
+		handle:   (*apiServer).handleUpdateDocument,
+	},
+	{
+		Method: http.MethodGet, Path: "/documents/version", Name: "DocumentVersion", Tag: "documents",
+		Summary:  "文档当前的修订号和ETag",
+		Query:    []apiParam{{Name: "id", Description: "文档ID", Required: true}},
+		Response: documentVersionResponse{},
+		handle:   (*apiServer).handleDocumentVersion,
+	},
+	{
+		Method: http.MethodPost, Path: "/feedback", Name: "Feedback", Tag: "ask", Idempotent: true,
+		Summary:  "反馈回答是否有帮助，需配置查询日志（QUERY_LOG_DB），gaps命令据此挖掘知识缺口",
+		Request:  feedbackRequest{},
+		Response: feedbackResponse{},
+		handle:   (*apiServer).handleFeedback,
+	},
+	{
+		Method: http.MethodGet, Path: "/analytics", Name: "Analytics", Tag: "admin", Admin: true,
+		Summary:  "对文档元数据执行只读的SQL统计查询",
+		Query:    []apiParam{{Name: "q", Description: "SQL查询，例如 SELECT category, COUNT(*) FROM documents GROUP BY category", Required: true}},
+		Response: analyticsResult{},
+		handle:   (*apiServer).handleAnalytics,
+	},
+	{
+		Method: http.MethodGet, Path: "/admin/stats", Name: "Stats", Tag: "admin", Admin: true,
+		Summary:  "知识库文档数和分块数",
+		Response: statsResponse{},
+		handle:   (*apiServer).handleStats,
+	},
+	{
+		Method: http.MethodPost, Path: "/admin/gc", Name: "GC", Tag: "admin", Admin: true, Idempotent: true,
+		Summary:  "清理孤儿分块",
+		Request:  gcRequest{},
+		Response: gcResponse{},
+		handle:   (*apiServer).handleGC,
+	},
+	{
+		Method: http.MethodPost, Path: "/admin/maintenance", Name: "Maintenance", Tag: "admin", Admin: true, Idempotent: true,
+		Summary:  "在后台运行存储维护（Milvus落盘和压缩，ES强制合并）；正在入库或刚入库过时跳过，force为true时强制运行",
+		Request:  maintenanceRequest{},
+		Response: maintenanceResponse{},
+		handle:   (*apiServer).handleMaintenance,
+	},
+	{
+		Method: http.MethodGet, Path: "/admin/maintenance/status", Name: "MaintenanceStatus", Tag: "admin", Admin: true,
+		Summary:  "存储维护是否正在运行和最近一次的结果",
+		Response: maintenanceResponse{},
+		handle:   (*apiServer).handleMaintenanceStatus,
+	},
+	{
+		Method: http.MethodGet, Path: "/admin/slo", Name: "SLO", Tag: "admin", Admin: true,
+		Summary:  "各SLO目标在统计窗口内的达标率、实际值和错误预算消耗速度，需配置SLOS",
+		Response: sloResponse{},
+		handle:   (*apiServer).handleSLO,
+	},
+	{
+		Method: http.MethodGet, Path: "/admin/rules", Name: "Rules", Tag: "admin", Admin: true,
+		Summary:  "检索规则列表，需配置RULES_DB",
+		Response: rulesResponse{},
+		handle:   (*apiServer).handleRules,
+	},
+	{
+		Method: http.MethodPost, Path: "/admin/rules/add", Name: "AddRule", Tag: "admin", Admin: true, Idempotent: true,
+		Summary:  "新增检索规则：pin在问题包含keyword时置顶文档，boost按元数据取值调整分数",
+		Request:  retrievalRule{},
+		Response: retrievalRule{},
+		handle:   (*apiServer).handleAddRule,
+	},
+	{
+		Method: http.MethodPost, Path: "/admin/rules/delete", Name: "DeleteRule", Tag: "admin", Admin: true, Idempotent: true,
+		Summary:  "删除检索规则",
+		Request:  deleteRuleRequest{},
+		Response: retrievalRule{},
+		handle:   (*apiServer).handleDeleteRule,
+	},
+	{
+		Method: http.MethodGet, Path: "/admin/overrides", Name: "Overrides", Tag: "admin", Admin: true,
+		Summary:  "固定回答列表，需配置OVERRIDES_DB",
+		Response: overridesResponse{},
+		handle:   (*apiServer).handleOverrides,
+	},
+	{
+		Method: http.MethodPost, Path: "/admin/overrides/add", Name: "AddOverride", Tag: "admin", Admin: true, Idempotent: true,
+		Summary:  "新增固定回答：问题按exact、regex或semantic匹配pattern时原样返回answer，不调用大模型",
+		Request:  answerOverride{},
+		Response: answerOverride{},
+		handle:   (*apiServer).handleAddOverride,
+	},
+	{
+		Method: http.MethodPost, Path: "/admin/overrides/delete", Name: "DeleteOverride", Tag: "admin", Admin: true, Idempotent: true,
+		Summary:  "删除固定回答",
+		Request:  deleteOverrideRequest{},
+		Response: answerOverride{},
+		handle:   (*apiServer).handleDeleteOverride,
+	},
+	{
+		Method: http.MethodGet, Path: "/eval/history", Name: "EvalHistory", Tag: "admin", Admin: true,
+		Summary:  "评测指标历史和周环比",
+		Query:    []apiParam{{Name: "days", Description: "查询最近多少天，默认30"}},
+		Response: evalHistoryResponse{},
+		handle:   (*apiServer).handleEvalHistory,
+	},
+	{
+		Method: http.MethodGet, Path: "/review/pending", Name: "ReviewQueue", Tag: "review", Admin: true,
+		Summary: "人工审核队列，需开启REVIEW_THRESHOLD",
+		Query: []apiParam{
+			{Name: "status", Description: "pending（默认）、approved、rejected、all"},
+			{Name: "limit", Description: "最多返回多少条，默认100"},
+		},
+		Response: reviewListResponse{},
+		handle:   (*apiServer).handleReviewQueue,
+	},
+	{
+		Method: http.MethodGet, Path: "/review/item", Name: "ReviewResult", Tag: "review",
+		Summary:  "提问者查询审核结果，审核通过后返回发布的回答",
+		Query:    []apiParam{{Name: "id", Description: "问答接口返回的review_id", Required: true}},
+		Response: reviewResult{},
+		handle:   (*apiServer).handleReviewResult,
+	},
+	{
+		Method: http.MethodPost, Path: "/review/approve", Name: "ApproveReview", Tag: "review", Admin: true, Idempotent: true,
+		Summary:  "批准待审核的回答，可修改后发布，可加入FAQ和知识库",
+		Request:  reviewDecision{},
+		Response: reviewItem{},
+		handle:   (*apiServer).handleApproveReview,
+	},
+	{
+		Method: http.MethodPost, Path: "/review/reject", Name: "RejectReview", Tag: "review", Admin: true, Idempotent: true,
+		Summary:  "驳回待审核的回答，answer为回复提问者的说明",
+		Request:  reviewDecision{},
+		Response: reviewItem{},
+		handle:   (*apiServer).handleRejectReview,
+	},
+	{
+		Method: http.MethodPost, Path: "/review/promote", Name: "PromoteReview", Tag: "review", Admin: true, Idempotent: true,
+		Summary:  "把审核通过的回答作为新文档加入知识库，元数据记录原问题和审核记录",
+		Request:  reviewPromoteRequest{},
+		Response: reviewItem{},
+		handle:   (*apiServer).handlePromoteReview,
+	},
+	{
+		Method: http.MethodPost, Path: "/provenance/verify", Name: "VerifyProvenance", Tag: "ask",
+		Summary:  "校验/ask返回的溯源清单的签名，可同时核对存档的回答原文",
+		Request:  verifyProvenanceRequest{},
+		Response: verifyProvenanceResponse{},
+		handle:   (*apiServer).handleVerifyProvenance,
+	},
+	{
+		Method: http.MethodPost, Path: "/debug/embeddings", Name: "DebugEmbeddings", Tag: "admin", Admin: true,
+		Summary:  "向量调试：对任意文本生成向量，返回两两余弦相似度和最相近的分块，排查问题匹配不到预期文档的原因",
+		Request:  debugEmbeddingsRequest{},
+		Response: debugEmbeddingsResponse{},
+		handle:   (*apiServer).handleDebugEmbeddings,
+	},
+	{
+		Method: http.MethodGet, Path: "/documents/original", Name: "Original", Tag: "documents",
+		Summary:  "查看原始文档，需配置原文存储（BLOB_STORE）",
+		Query:    []apiParam{{Name: "id", Description: "文档ID", Required: true}},
+		Response: ingestDocument{},
+		handle:   (*apiServer).handleOriginal,
+	},
+	{
+		Method: http.MethodGet, Path: "/chunks/context", Name: "ChunkContext", Tag: "documents",
+		Summary: "分块上下文：返回分块、同一文档中前后相邻的分块和文档元数据，用于查看更多上下文",
+		Query: []apiParam{
+			{Name: "id", Description: "分块ID，格式为 文档ID#序号，#需编码为%23", Required: true},
+			{Name: "window", Description: "前后各返回的分块数，0到10，默认1"},
+		},
+		Response: chunkContextResponse{},
+		handle:   (*apiServer).handleChunkContext,
+	},
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range apiRoutes {
+		route := route
+		mux.HandleFunc(route.Path, func(w http.ResponseWriter, req *http.Request) {
+			if req.Method != route.Method {
+				writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("只支持%s请求", route.Method))
+				return
+			}
+			if route.Admin && !s.authorizeAdmin(w, req) {
+				return
+			}
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			if route.Idempotent {
+				s.serveIdempotent(recorder, req, route)
+			} else {
+				route.handle(s, recorder, req)
+			}
+			s.slo.Observe(route.Path, time.Since(start), recorder.status)
+			// 处理结束前请求上下文已取消，说明客户端中途断开，检索和生成已随之中止
+			if errors.Is(req.Context().Err(), context.Canceled) {
+				s.cancels.add(route.Path)
+			}
+		})
+	}
+	// SSE不是JSON响应，不在接口表中，生成的客户端不包含该接口
+	mux.HandleFunc("/ask/stream", s.handleAskStream)
+	mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("/docs", s.handleDocs)
+	mux.HandleFunc("/review", s.handleReviewPage)
+	return mux
+}
+
+// 解析JSON请求体
+func decodeBody(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("解析请求失败: %w", err))
+		return false
+	}
+	return true
+}
+
+type askRequest struct {
+	Question     string        `json:"question"`
+	Fresh        bool          `json:"fresh"`                       // 跳过答案缓存，强制重新生成
+	Accuracy     searchOptions `json:"accuracy,omitempty"`          // 检索精度档位和参数
+	Category     string        `json:"category,omitempty"`          // 只检索该分类的文档
+	Entity       string        `json:"entity,omitempty"`            // 只检索提及该人物、机构或日期的分块，日期格式为2024、2024-05、2024-05-01
+	Model        string        `json:"model,omitempty"`             // 生成回答的模型，需在LLM_MODELS允许列表中，不填时使用DEEPSEEK_MODEL
+	Reasoning    bool          `json:"include_reasoning,omitempty"` // 返回推理模型的思考过程，用于调试
+	Mode         string        `json:"mode,omitempty"`              // generate（默认）；extractive：不调用大模型，摘出检索结果中与问题最相近的句子；summarize：分批总结大量分块后综合回答；direct：不检索知识库直接回答
+	Exclude      []string      `json:"exclude,omitempty"`           // 排除命中这些元数据取值的分块，格式为 字段:值，例如 category:archive
+	ExcludeDocs  []string      `json:"exclude_docs,omitempty"`      // 排除这些文档
+	Session      string        `json:"session,omitempty"`           // 会话ID，由客户端生成；服务端记录该会话展示过的来源和问答，之后的提问可以引用之前的回答
+	ExcludeShown bool          `json:"exclude_shown,omitempty"`     // 排除本会话展示过的文档，用于"还有别的吗"这类追问，需要session
+	Provenance   bool          `json:"provenance,omitempty"`        // 返回签名的溯源清单，需配置PROVENANCE_SIGNING_KEY
+	Language     string        `json:"language_filter,omitempty"`   // 按问题语言过滤分块：off、prefer、require，不填时使用LANGUAGE_FILTER
+	Length       string        `json:"length,omitempty"`            // 回答长度档位：short、standard、detailed，不填时使用渠道的默认档位
+	Format       string        `json:"format,omitempty"`            // 另外返回按平台格式转换的回答和来源：markdown、plain（微信等纯文本渠道）、telegram（MarkdownV2）、slack（mrkdwn和Block Kit）
+	Forwarded    bool          `json:"forwarded,omitempty"`         // 由对等实例转发的请求，不再转发给其他实例
+}
+
+type askResponse struct {
+	Answer     string              `json:"answer"`
+	Sources    []SearchResult      `json:"sources"`
+	Cached     bool                `json:"cached"`
+	Truncated  bool                `json:"truncated,omitempty"` // 续写次数用完后回答仍被长度上限截断
+	Profile    string              `json:"profile,omitempty"`   // 启用发布配置时处理该请求的profile
+	Model      string              `json:"model"`
+	Reasoning  string              `json:"reasoning,omitempty"`  // 推理模型的思考过程，仅在请求include_reasoning时返回
+	Degraded   []string            `json:"degraded,omitempty"`   // 服务降级时使用的档位：keyword、llm_only、extractive；按回答策略处理时为refused、escalated、direct，转发到对等实例时为peer
+	ReviewID   int64               `json:"review_id,omitempty"`  // 回答进入人工审核时的审核ID，answer为等待提示，通过 GET /review/item 查询审核后的回答
+	QueryID    int64               `json:"query_id,omitempty"`   // 开启查询日志时的记录ID，用于 POST /feedback 反馈回答是否有帮助
+	Provenance *provenanceManifest `json:"provenance,omitempty"` // 签名的溯源清单，仅在请求provenance时返回，通过 POST /provenance/verify 校验
+	Formatted  *formattedReply     `json:"formatted,omitempty"`  // 按请求的format转换的回答，仅在请求format时返回
+	Elapsed    float64             `json:"elapsed"`
+}
+
+func (s *apiServer) handleAsk(w http.ResponseWriter, req *http.Request) {
+	var body askRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if body.Question == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("question不能为空"))
+		return
+	}
+	question, operators, err := s.parseOperators(body.Question)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	body.Question = question
+
+	opts, err := body.Accuracy.resolve(s.rag.settings().Retrieval.Profile)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts.Category = body.Category
+	opts.Entity = body.Entity
+	if err := validLanguageFilter(body.Language); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts.LanguageFilter = body.Language
+	if err := s.applyExclusions(&opts, body.Exclude, body.ExcludeDocs, body.Session, body.ExcludeShown); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts.Session = body.Session
+	opts.Forwarded = body.Forwarded
+	opts.History = s.rag.recallHistory(req.Context(), body.Session, body.Question)
+
+	profile, rag := s.ragFor(body.Question)
+	if opts.Model, err = rag.resolveModel(body.Model); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Reasoning {
+		opts.Reasoning = &strings.Builder{}
+	}
+	switch body.Mode {
+	case "", answerModeGenerate:
+	case answerModeExtractive:
+		opts.Extractive = true
+	case answerModeSummarize:
+		opts.Summarize = true
+	case answerModeDirect:
+		opts.Direct = true
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("未知的mode: %s，可选 generate、extractive、summarize、direct", body.Mode))
+		return
+	}
+	operators.apply(&opts)
+	if err := validAnswerLength(body.Length); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts.Length = s.rag.config.AnswerLength.resolve(body.Length, channelAsk)
+	if err := validReplyFormat(body.Format); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Provenance {
+		if s.rag.config.Provenance.SigningKey == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("未配置PROVENANCE_SIGNING_KEY，无法导出溯源清单"))
+			return
+		}
+		opts.Prompts = &promptLog{}
+	}
+	opts.Degraded = &degradation{}
+	answer, elapsed, sources, cached, err := rag.AnswerQuestion(req.Context(), body.Question, body.Fresh, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.rag.sessions.MarkShown(body.Session, sources)
+	// 返回的回答和查询日志中的问题按命名空间的屏蔽词表处理，固定回答原样返回
+	words, namespace := s.rag.settings().lexicon, answerNamespace(body.Category, sources)
+	resp := askResponse{Answer: answer, Sources: sources, Cached: cached, Truncated: isTruncated(answer), Profile: profile, Model: opts.Model, Degraded: opts.Degraded.Tiers(), Elapsed: elapsed}
+	if !containsString(resp.Degraded, tierOverride) {
+		resp.Answer = words.apply(namespace, answer)
+	}
+	if opts.Reasoning != nil {
+		resp.Reasoning = words.apply(namespace, opts.Reasoning.String())
+	}
+	// 低置信度的回答先进入人工审核，提问者凭review_id查询审核后的回答
+	if !cached && len(resp.Degraded) == 0 && s.rag.reviews.needsReview(sources) {
+		item, err := s.rag.reviews.Park(body.Question, answer, sources)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		fmt.Printf("🧑‍⚖️ 回答进入人工审核 #%d: %s\n", item.ID, body.Question)
+		resp.Answer, resp.Truncated, resp.Reasoning, resp.ReviewID = reviewPendingNotice, false, "", item.ID
+	}
+	// 审核中和降级的回答不作为对话历史
+	if resp.ReviewID == 0 && len(resp.Degraded) == 0 {
+		if err := s.rag.sessions.AddTurn(req.Context(), body.Session, body.Question, answer); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+	}
+	// 记录查询日志失败不影响回答
+	if resp.QueryID, err = s.queries.Record(words.apply(namespace, body.Question), body.Category, sources, cached, resp.Degraded); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	if body.Provenance {
+		if resp.Provenance, err = newProvenanceManifest(s.rag.config.Provenance, body.Question, body.Mode, resp, opts.Prompts.Hashes()); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if body.Format != "" {
+		resp.Formatted = formatReply(body.Format, resp.Answer, sources)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type retrieveRequest struct {
+	Question     string        `json:"question"`
+	TopK         int           `json:"top_k,omitempty"`           // 不填时使用服务端的检索配置
+	Accuracy     searchOptions `json:"accuracy,omitempty"`        // 检索精度档位和参数
+	Category     string        `json:"category,omitempty"`        // 只检索该分类的文档
+	Entity       string        `json:"entity,omitempty"`          // 只检索提及该人物、机构或日期的分块
+	From         int           `json:"from,omitempty"`            // 分页检索的起始位置
+	Size         int           `json:"size,omitempty"`            // 分页检索的每页条数，大于0时按检索结果的原始排序分页
+	Cursor       string        `json:"cursor,omitempty"`          // 上一页返回的游标，优先于from
+	Exclude      []string      `json:"exclude,omitempty"`         // 排除命中这些元数据取值的分块，格式为 字段:值，例如 category:archive
+	ExcludeDocs  []string      `json:"exclude_docs,omitempty"`    // 排除这些文档
+	Session      string        `json:"session,omitempty"`         // 会话ID，由客户端生成；服务端记录该会话展示过的来源
+	ExcludeShown bool          `json:"exclude_shown,omitempty"`   // 排除本会话展示过的文档，需要session
+	Language     string        `json:"language_filter,omitempty"` // 按问题语言过滤分块：off、prefer、require，不填时使用LANGUAGE_FILTER
+}
+
+type retrieveResponse struct {
+	Results  []SearchResult `json:"results"`
+	Profile  string         `json:"profile,omitempty"`
+	Cursor   string         `json:"cursor,omitempty"`   // 分页检索时下一页的游标，没有更多结果时为空
+	Degraded []string       `json:"degraded,omitempty"` // 向量检索失败改用关键词检索时为keyword
+}
+
+func (s *apiServer) handleRetrieve(w http.ResponseWriter, req *http.Request) {
+	var body retrieveRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if body.Question == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("question不能为空"))
+		return
+	}
+	question, operators, err := s.parseOperators(body.Question)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	body.Question = question
+
+	opts, err := body.Accuracy.resolve(s.rag.settings().Retrieval.Profile)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts.Category = body.Category
+	opts.Entity = body.Entity
+	if err := validLanguageFilter(body.Language); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts.LanguageFilter = body.Language
+	if err := s.applyExclusions(&opts, body.Exclude, body.ExcludeDocs, body.Session, body.ExcludeShown); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts.Degraded = &degradation{}
+	operators.apply(&opts)
+	if opts.TopK > 0 {
+		body.TopK = opts.TopK
+	}
+
+	profile, rag := s.ragFor(body.Question)
+	if body.Size > 0 || body.From > 0 || body.Cursor != "" {
+		page, err := rag.newSearchPage(body.From, body.Size, body.Cursor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		results, cursor, err := rag.searchPage(req.Context(), body.Question, body.Size, page, opts)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.rag.sessions.MarkShown(body.Session, results)
+		writeJSON(w, http.StatusOK, retrieveResponse{Results: results, Profile: profile, Cursor: cursor})
+		return
+	}
+
+	var results []SearchResult
+	if body.TopK > 0 {
+		results, err = rag.searchTopK(req.Context(), body.Question, body.TopK, opts)
+	} else {
+		results, err = rag.retrieve(req.Context(), body.Question, opts)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.rag.sessions.MarkShown(body.Session, results)
+	writeJSON(w, http.StatusOK, retrieveResponse{Results: results, Profile: profile, Degraded: opts.Degraded.Tiers()})
+}
+
+type ingestDocument struct {
+	ID      string                 `json:"id"`
+	Title   string                 `json:"title"`
+	Content string                 `json:"content"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+}
+
+type ingestRequest struct {
+	Documents   []ingestDocument `json:"documents"`
+	Consistency string           `json:"consistency,omitempty"` // eventual、strong，不填时使用WRITE_CONSISTENCY
+}
+
+type ingestResponse struct {
+	Documents int `json:"documents"`
+}
+
+func (s *apiServer) handleIngest(w http.ResponseWriter, req *http.Request) {
+	var body ingestRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	consistency, err := resolveConsistency(body.Consistency, s.rag.config.Consistency)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	documents := make([]Document, 0, len(body.Documents))
+	for _, doc := range body.Documents {
+		if doc.ID == "" || doc.Content == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("文档的id和content不能为空"))
+			return
+		}
+		documents = append(documents, Document{ID: doc.ID, Title: doc.Title, Content: doc.Content, Meta: doc.Meta})
+	}
+	if err := s.rag.replaceDocumentsWith(documents, consistency); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ingestResponse{Documents: len(documents)})
+}
+
+// GET /analytics?q=SELECT ...
+func (s *apiServer) handleAnalytics(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("缺少查询参数q"))
+		return
+	}
+
+	parsed, err := parseAnalyticsQuery(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	result, err := s.rag.runAnalytics(parsed)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// GET /documents/original?id=doc_001
+func (s *apiServer) handleOriginal(w http.ResponseWriter, req *http.Request) {
+	docID := req.URL.Query().Get("id")
+	if docID == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("缺少查询参数id"))
+		return
+	}
+
+	doc, err := s.rag.loadOriginal(req.Context(), docID)
+	if errors.Is(err, errBlobNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+type statsResponse struct {
+	Documents int64            `json:"documents"`
+	Chunks    int64            `json:"chunks"`
+	Memory    memoryStats      `json:"memory"`    // 运行时内存和入库缓冲区统计
+	Cancelled map[string]int64 `json:"cancelled"` // 客户端中途断开而中止的请求数，按接口统计
+	Coalesced int64            `json:"coalesced"` // 合并到进行中的相同问题的/ask请求数
+	Joined    int64            `json:"joined"`    // 订阅进行中的相同问题流式回答的请求数（/ask/stream、Telegram）
+	Usage     tokenUsage       `json:"usage"`     // 服务启动以来大模型调用的累计token用量
+}
+
+func (s *apiServer) handleStats(w http.ResponseWriter, req *http.Request) {
+	documents, err := s.rag.runAnalytics(analyticsQuery{Documents: true, Limit: defaultAnalyticsLimit})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	chunks, err := s.rag.runAnalytics(analyticsQuery{Limit: defaultAnalyticsLimit})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statsResponse{Documents: documents.Total, Chunks: chunks.Total, Memory: currentMemoryStats(), Cancelled: s.cancels.Snapshot(), Coalesced: s.rag.flights.Coalesced(), Joined: s.rag.broadcasts.Joined(), Usage: s.rag.usage.Snapshot()})
+}
+
+type gcRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+type gcResponse struct {
+	Orphans []orphanChunk `json:"orphans"`
+	Deleted int           `json:"deleted"`
+}
+
+func (s *apiServer) handleGC(w http.ResponseWriter, req *http.Request) {
+	var body gcRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+
+	orphans, err := s.rag.FindOrphanChunks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	response := gcResponse{Orphans: orphans}
+	if !body.DryRun && len(orphans) > 0 {
+		ids := make([]string, 0, len(orphans))
+		for _, orphan := range orphans {
+			ids = append(ids, orphan.ID)
+		}
+		if err := s.rag.DeleteChunks(ids); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Deleted = len(ids)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+type evalHistoryResponse struct {
+	Runs  []evalRun `json:"runs"`
+	Trend evalTrend `json:"trend"`
+}
+
+// GET /eval/history?days=30
+func (s *apiServer) handleEvalHistory(w http.ResponseWriter, req *http.Request) {
+	days := 30
+	if value := req.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("days必须是正整数"))
+			return
+		}
+		days = parsed
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+	if days < 14 {
+		since = now.AddDate(0, 0, -14)
+	}
+	runs, err := s.history.Since(since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	trend := weekOverWeek(runs, now, s.rag.config.EvalSchedule.AlertDrop)
+
+	cutoff := now.AddDate(0, 0, -days)
+	filtered := []evalRun{}
+	for _, run := range runs {
+		if !run.RunAt.Before(cutoff) {
+			filtered = append(filtered, run)
+		}
+	}
+	writeJSON(w, http.StatusOK, evalHistoryResponse{Runs: filtered, Trend: trend})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// 输出错误响应；已分类的错误使用分类对应的状态码，其他错误使用调用方给出的状态码
+func writeError(w http.ResponseWriter, status int, err error) {
+	kind := classifyError(status, err)
+	writeJSON(w, kind.status, errorResponse{Error: err.Error(), Code: kind.code, Retryable: kind.retryable})
+}
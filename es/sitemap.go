@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// sitemap中的一个条目（页面或子sitemap）
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// 同时兼容urlset和sitemapindex两种根节点
+type sitemapDocument struct {
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// sitemap同步状态，记录上次同步时间和已入库的页面
+type sitemapState struct {
+	LastSync time.Time         `json:"last_sync"`
+	Pages    map[string]string `json:"pages"` // URL -> 文档ID
+}
+
+// sitemap同步状态文件路径
+func sitemapStatePath() string {
+	return getEnv("SITEMAP_STATE_FILE", ".sitemap_state_es.json")
+}
+
+// 读取同步状态，文件不存在时返回空状态
+func loadSitemapState(path string) (*sitemapState, error) {
+	state := &sitemapState{Pages: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("解析同步状态失败: %w", err)
+	}
+	if state.Pages == nil {
+		state.Pages = make(map[string]string)
+	}
+	return state, nil
+}
+
+func saveSitemapState(path string, state *sitemapState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// sitemap命令：只抓取上次同步后有更新的页面并写入知识库
+func runSitemap(args []string) error {
+	fs := flag.NewFlagSet("sitemap", flag.ExitOnError)
+	sitemapURL := fs.String("url", getEnv("SITEMAP_URL", ""), "sitemap.xml或sitemap索引地址")
+	since := fs.String("since", "", "只同步该日期之后更新的页面（默认使用上次同步时间）")
+	statePath := fs.String("state", sitemapStatePath(), "同步状态文件")
+	dryRun := fs.Bool("dry-run", false, "只列出需要同步的页面")
+	_ = fs.Parse(args)
+
+	if *sitemapURL == "" {
+		return fmt.Errorf("请通过 -url 或 SITEMAP_URL 指定sitemap地址")
+	}
+
+	state, err := loadSitemapState(*statePath)
+	if err != nil {
+		return err
+	}
+	cutoff := state.LastSync
+	if *since != "" {
+		if cutoff, err = parseLastMod(*since); err != nil {
+			return fmt.Errorf("since格式错误: %w", err)
+		}
+	}
+
+	config := loadConfig()
+	u, err := url.Parse(*sitemapURL)
+	if err != nil {
+		return fmt.Errorf("sitemap地址无效: %w", err)
+	}
+	crawlerConfig := config.Crawler
+	crawlerConfig.SeedURLs = []string{u.Scheme + "://" + u.Host + "/"}
+	c, err := newCrawler(crawlerConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	syncStart := time.Now()
+	pages, err := c.collectSitemap(ctx, *sitemapURL, cutoff, 0)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🗺️  sitemap中有 %d 个页面在 %s 之后更新\n", len(pages), cutoff.Format(time.RFC3339))
+
+	if *dryRun {
+		for _, page := range pages {
+			fmt.Printf("  - %s (lastmod: %s)\n", page.Loc, page.LastMod)
+		}
+		return nil
+	}
+	if len(pages) == 0 {
+		return nil
+	}
+
+	links := make([]string, 0, len(pages))
+	for _, page := range pages {
+		if c.allowed(page.Loc) {
+			links = append(links, page.Loc)
+		}
+	}
+
+	var documents []Document
+	for _, page := range c.fetchAll(ctx, links) {
+		doc, err := loadDocument(page.URL, page.Body)
+		if err != nil {
+			fmt.Printf("⚠️  跳过页面 %s: %v\n", page.URL, err)
+			continue
+		}
+		delete(doc.Meta, "source_path")
+		doc.Meta["source_url"] = page.URL
+		documents = append(documents, doc)
+	}
+
+	rag, err := NewRAGSystem(config)
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	if err := rag.ReplaceDocuments(documents); err != nil {
+		return err
+	}
+
+	for _, doc := range documents {
+		state.Pages[doc.Meta["source_url"].(string)] = doc.ID
+	}
+	state.LastSync = syncStart
+	if err := saveSitemapState(*statePath, state); err != nil {
+		return fmt.Errorf("保存同步状态失败: %w", err)
+	}
+	fmt.Printf("✅ 同步了 %d 个页面\n", len(documents))
+	return nil
+}
+
+// 递归读取sitemap及sitemap索引，返回cutoff之后更新的页面
+func (c *crawler) collectSitemap(ctx context.Context, sitemapURL string, cutoff time.Time, depth int) ([]sitemapEntry, error) {
+	if depth > 3 {
+		return nil, fmt.Errorf("sitemap索引嵌套过深: %s", sitemapURL)
+	}
+
+	u, err := url.Parse(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.waitTurn(ctx, u.Host); err != nil {
+		return nil, err
+	}
+	body, _, err := c.get(ctx, sitemapURL)
+	if err != nil {
+		return nil, fmt.Errorf("获取sitemap %s 失败: %w", sitemapURL, err)
+	}
+
+	// 支持gzip压缩的sitemap.xml.gz
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("解析sitemap %s 失败: %w", sitemapURL, err)
+	}
+
+	var pages []sitemapEntry
+	for _, entry := range doc.URLs {
+		if changedSince(entry, cutoff) {
+			entry.Loc = strings.TrimSpace(entry.Loc)
+			pages = append(pages, entry)
+		}
+	}
+	for _, child := range doc.Sitemaps {
+		// 子sitemap本身未更新时，其中的页面也不会更新
+		if !changedSince(child, cutoff) {
+			continue
+		}
+		childPages, err := c.collectSitemap(ctx, strings.TrimSpace(child.Loc), cutoff, depth+1)
+		if err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+			continue
+		}
+		pages = append(pages, childPages...)
+	}
+	return pages, nil
+}
+
+// 条目是否在cutoff之后更新，没有lastmod时视为已更新
+func changedSince(entry sitemapEntry, cutoff time.Time) bool {
+	if entry.LastMod == "" || cutoff.IsZero() {
+		return true
+	}
+	lastMod, err := parseLastMod(entry.LastMod)
+	if err != nil {
+		return true
+	}
+	return lastMod.After(cutoff)
+}
+
+// 解析W3C日期格式的lastmod
+func parseLastMod(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析日期: %s", value)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SLO配置：SLOS=/ask:p95<4s,/ask:errors<1%,/retrieve:p99<800ms。serve按接口记录每个请求的耗时和是否出错（5xx），
+// 统计窗口内的达标率；错误预算的消耗速度在长窗口（SLO_WINDOW_MINUTES）和短窗口（长窗口的1/12）都超过SLO_BURN_RATE时告警，
+// 恢复后再通知一次
+type SLOConfig struct {
+	Objectives []sloObjective
+	Window     time.Duration
+	BurnRate   float64
+	Interval   time.Duration // 检查间隔
+	Webhook    string        // 告警推送地址（可选），POST {"text": "..."}
+}
+
+func loadSLOConfig() SLOConfig {
+	config := SLOConfig{
+		Window:   time.Duration(getEnvAsInt("SLO_WINDOW_MINUTES", 60)) * time.Minute,
+		BurnRate: getEnvAsFloat("SLO_BURN_RATE", 2),
+		Interval: time.Duration(getEnvAsInt("SLO_CHECK_SECONDS", 60)) * time.Second,
+		Webhook:  getEnv("SLO_ALERT_WEBHOOK", ""),
+	}
+	for _, item := range splitEnvList("SLOS") {
+		objective, err := parseSLO(item)
+		if err != nil {
+			fmt.Printf("⚠️  忽略SLO %s: %v\n", item, err)
+			continue
+		}
+		config.Objectives = append(config.Objectives, objective)
+	}
+	return config
+}
+
+// 配置了SLOS时检查间隔必须大于0
+func (c SLOConfig) validate() error {
+	if len(c.Objectives) > 0 && c.Interval <= 0 {
+		return fmt.Errorf("SLO_CHECK_SECONDS必须大于0")
+	}
+	return nil
+}
+
+// 一个服务等级目标：耗时分位数低于阈值，或错误率低于上限
+type sloObjective struct {
+	Route      string
+	Percentile float64       // 耗时目标的分位数，例如95；错误率目标为0
+	Threshold  time.Duration // 耗时目标的阈值
+	Budget     float64       // 允许的坏请求比例，p95为0.05，errors<1%为0.01
+}
+
+func (o sloObjective) String() string {
+	if o.Percentile == 0 {
+		return fmt.Sprintf("%s errors<%s%%", o.Route, strconv.FormatFloat(o.Budget*100, 'f', -1, 64))
+	}
+	return fmt.Sprintf("%s p%s<%s", o.Route, strconv.FormatFloat(o.Percentile, 'f', -1, 64), o.Threshold)
+}
+
+// 解析 /ask:p95<4s 或 /ask:errors<1%
+func parseSLO(spec string) (sloObjective, error) {
+	route, target, ok := strings.Cut(spec, ":")
+	if !ok || !strings.HasPrefix(route, "/") {
+		return sloObjective{}, fmt.Errorf("格式应为 接口:p95<4s 或 接口:errors<1%%")
+	}
+	metric, limit, ok := strings.Cut(target, "<")
+	if !ok {
+		return sloObjective{}, fmt.Errorf("缺少 <")
+	}
+	objective := sloObjective{Route: route}
+	if metric == "errors" {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(limit, "%"), 64)
+		if err != nil || percent <= 0 || percent >= 100 {
+			return sloObjective{}, fmt.Errorf("错误率应为0到100之间的百分比")
+		}
+		objective.Budget = percent / 100
+		return objective, nil
+	}
+	percentile, err := strconv.ParseFloat(strings.TrimPrefix(metric, "p"), 64)
+	if !strings.HasPrefix(metric, "p") || err != nil || percentile <= 0 || percentile >= 100 {
+		return sloObjective{}, fmt.Errorf("未知的指标 %s，可选 p50、p95、p99 等分位数或 errors", metric)
+	}
+	if objective.Threshold, err = time.ParseDuration(limit); err != nil || objective.Threshold <= 0 {
+		return sloObjective{}, fmt.Errorf("耗时阈值应为正的时长，例如 4s、800ms")
+	}
+	objective.Percentile = percentile
+	objective.Budget = (100 - percentile) / 100
+	return objective, nil
+}
+
+// 请求是否违反目标
+func (o sloObjective) bad(sample requestSample) bool {
+	if o.Percentile == 0 {
+		return sample.failed
+	}
+	return sample.latency > o.Threshold
+}
+
+// 短窗口内请求数不足时不判断消耗速度，避免个别慢请求触发告警
+const sloMinRequests = 10
+
+type requestSample struct {
+	at      time.Time
+	route   string
+	latency time.Duration
+	failed  bool
+}
+
+// 一个目标在统计窗口内的达标情况
+type sloStatus struct {
+	Objective     string  `json:"objective"`
+	Requests      int     `json:"requests"`        // 长窗口内的请求数
+	Compliance    float64 `json:"compliance"`      // 长窗口内达标请求的比例
+	Observed      float64 `json:"observed"`        // 长窗口内实际的耗时分位数（毫秒）或错误率
+	BurnRate      float64 `json:"burn_rate"`       // 长窗口的错误预算消耗速度，1表示恰好在窗口结束时用完
+	ShortBurnRate float64 `json:"short_burn_rate"` // 短窗口的错误预算消耗速度
+	Burning       bool    `json:"burning"`         // 正在告警
+}
+
+// 按接口记录请求耗时和错误，只保留配置了目标的接口在长窗口内的样本
+type sloTracker struct {
+	config  SLOConfig
+	routes  map[string]bool
+	mu      sync.Mutex
+	samples []requestSample
+	burning map[string]bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// 未配置SLOS时返回nil
+func newSLOTracker(config SLOConfig) *sloTracker {
+	if len(config.Objectives) == 0 {
+		return nil
+	}
+	t := &sloTracker{config: config, routes: make(map[string]bool), burning: make(map[string]bool), stop: make(chan struct{}), done: make(chan struct{})}
+	for _, objective := range config.Objectives {
+		t.routes[objective.Route] = true
+	}
+	return t
+}
+
+// 记录一个请求，5xx视为出错
+func (t *sloTracker) Observe(route string, latency time.Duration, status int) {
+	if t == nil || !t.routes[route] {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.samples = append(t.samples, requestSample{at: now, route: route, latency: latency, failed: status >= 500})
+	t.trim(now)
+}
+
+func (t *sloTracker) trim(now time.Time) {
+	cutoff := now.Add(-t.config.Window)
+	i := sort.Search(len(t.samples), func(i int) bool { return t.samples[i].at.After(cutoff) })
+	if i > 0 {
+		t.samples = append(t.samples[:0], t.samples[i:]...)
+	}
+}
+
+// 各目标当前的达标情况
+func (t *sloTracker) Report() []sloStatus {
+	if t == nil {
+		return []sloStatus{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.trim(now)
+	shortCutoff := now.Add(-t.config.Window / 12)
+
+	statuses := make([]sloStatus, 0, len(t.config.Objectives))
+	for _, objective := range t.config.Objectives {
+		status := sloStatus{Objective: objective.String(), Burning: t.burning[objective.String()]}
+		var latencies []time.Duration
+		var bad, shortRequests, shortBad, failed int
+		for _, sample := range t.samples {
+			if sample.route != objective.Route {
+				continue
+			}
+			status.Requests++
+			latencies = append(latencies, sample.latency)
+			if sample.failed {
+				failed++
+			}
+			isBad := objective.bad(sample)
+			if isBad {
+				bad++
+			}
+			if sample.at.After(shortCutoff) {
+				shortRequests++
+				if isBad {
+					shortBad++
+				}
+			}
+		}
+		if status.Requests > 0 {
+			status.Compliance = 1 - float64(bad)/float64(status.Requests)
+			status.BurnRate = float64(bad) / float64(status.Requests) / objective.Budget
+			if objective.Percentile == 0 {
+				status.Observed = float64(failed) / float64(status.Requests)
+			} else {
+				status.Observed = float64(latencyPercentile(latencies, objective.Percentile)) / float64(time.Millisecond)
+			}
+		}
+		if shortRequests >= sloMinRequests {
+			status.ShortBurnRate = float64(shortBad) / float64(shortRequests) / objective.Budget
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// 耗时的分位数（最近秩法）
+func latencyPercentile(latencies []time.Duration, percentile float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(percentile / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// 启动定时检查：长短窗口的消耗速度都超过阈值时告警，回落到阈值以下时通知恢复；Close停止
+func (t *sloTracker) start() {
+	if t == nil {
+		return
+	}
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(t.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				t.check()
+			}
+		}
+	}()
+}
+
+// 停止定时检查并等待退出，只能在start之后调用一次
+func (t *sloTracker) Close() {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	<-t.done
+}
+
+func (t *sloTracker) check() {
+	for _, status := range t.Report() {
+		burning := status.BurnRate >= t.config.BurnRate && status.ShortBurnRate >= t.config.BurnRate
+		t.mu.Lock()
+		changed := t.burning[status.Objective] != burning
+		t.burning[status.Objective] = burning
+		t.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if burning {
+			t.alert(fmt.Sprintf("🔥 SLO %s 错误预算消耗过快：%s内达标率 %.1f%%，消耗速度 %.1fx（短窗口 %.1fx）",
+				status.Objective, t.config.Window, status.Compliance*100, status.BurnRate, status.ShortBurnRate))
+		} else {
+			t.alert(fmt.Sprintf("✅ SLO %s 已恢复：%s内达标率 %.1f%%，消耗速度 %.1fx",
+				status.Objective, t.config.Window, status.Compliance*100, status.BurnRate))
+		}
+	}
+}
+
+// 发出SLO告警，配置了webhook时同时推送
+func (t *sloTracker) alert(message string) {
+	log.Printf("🚨 [slo] %s", message)
+	if t.config.Webhook == "" {
+		return
+	}
+	if err := postAlert(t.config.Webhook, message); err != nil {
+		log.Printf("⚠️  推送SLO告警失败: %v", err)
+	}
+}
+
+// 记录响应状态码，用于统计出错的请求
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+type sloResponse struct {
+	Objectives []sloStatus `json:"objectives"`
+}
+
+func (s *apiServer) handleSLO(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, sloResponse{Objectives: s.slo.Report()})
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/segmentio/kafka-go"
+)
+
+// 数据表行到文档的映射：标题和正文用text/template渲染，模板中以 {{.列名}} 引用列值
+type sqlRowMapping struct {
+	Table      string
+	IDColumn   string
+	Title      *template.Template
+	Content    *template.Template
+	MetaFields []string // 写入元数据的列
+}
+
+func newSQLRowMapping(table, idColumn, title, content, meta string) (*sqlRowMapping, error) {
+	titleTemplate, err := template.New("title").Option("missingkey=zero").Parse(title)
+	if err != nil {
+		return nil, fmt.Errorf("解析标题模板失败: %w", err)
+	}
+	contentTemplate, err := template.New("content").Option("missingkey=zero").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("解析正文模板失败: %w", err)
+	}
+	return &sqlRowMapping{
+		Table:      table,
+		IDColumn:   idColumn,
+		Title:      titleTemplate,
+		Content:    contentTemplate,
+		MetaFields: splitEnvList(meta),
+	}, nil
+}
+
+// 行对应的文档ID
+func (m *sqlRowMapping) documentID(id interface{}) string {
+	return fmt.Sprintf("sql_%s_%v", m.Table, id)
+}
+
+// 把一行数据渲染为文档
+func (m *sqlRowMapping) document(row map[string]interface{}) (Document, error) {
+	id, ok := row[m.IDColumn]
+	if !ok || id == nil {
+		return Document{}, fmt.Errorf("行中缺少主键列 %s", m.IDColumn)
+	}
+
+	var title, content bytes.Buffer
+	if err := m.Title.Execute(&title, row); err != nil {
+		return Document{}, fmt.Errorf("渲染标题失败: %w", err)
+	}
+	if err := m.Content.Execute(&content, row); err != nil {
+		return Document{}, fmt.Errorf("渲染正文失败: %w", err)
+	}
+
+	meta := map[string]interface{}{
+		"source":    m.Table,
+		"sql_table": m.Table,
+		"sql_id":    fmt.Sprint(id),
+	}
+	for _, field := range m.MetaFields {
+		if value, ok := row[field]; ok && value != nil {
+			meta[field] = value
+		}
+	}
+	return Document{
+		ID:      m.documentID(id),
+		Title:   strings.TrimSpace(title.String()),
+		Content: strings.TrimSpace(content.String()),
+		Meta:    meta,
+	}, nil
+}
+
+// SQL同步状态：记录已同步到的更新时间和已入库的行
+type sqlSyncState struct {
+	Table       string            `json:"table"`
+	LastUpdated string            `json:"last_updated"`
+	Rows        map[string]string `json:"rows"` // 主键 -> 文档ID
+}
+
+// SQL同步状态文件路径
+func sqlSyncStatePath() string {
+	return getEnv("SQL_SYNC_STATE_FILE", ".sqlsync_state_es.json")
+}
+
+// 读取同步状态，文件不存在时返回空状态
+func loadSQLSyncState(path string) (*sqlSyncState, error) {
+	state := &sqlSyncState{Rows: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("解析同步状态失败: %w", err)
+	}
+	if state.Rows == nil {
+		state.Rows = make(map[string]string)
+	}
+	return state, nil
+}
+
+func saveSQLSyncState(path string, state *sqlSyncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// sqlsync命令：把数据表同步为知识库文档。默认按更新时间列轮询增量，并对比主键删除已不存在的行；
+// 指定 -debezium-topic 时改为消费Debezium的变更事件
+func runSQLSync(args []string) error {
+	fs := flag.NewFlagSet("sqlsync", flag.ExitOnError)
+	driver := fs.String("driver", getEnv("SQL_SYNC_DRIVER", "sqlite3"), "数据库驱动: sqlite3、postgres、mysql")
+	dsn := fs.String("dsn", getEnv("SQL_SYNC_DSN", ""), "数据库连接串")
+	table := fs.String("table", getEnv("SQL_SYNC_TABLE", ""), "同步的数据表")
+	idColumn := fs.String("id", getEnv("SQL_SYNC_ID_COLUMN", "id"), "主键列")
+	updatedColumn := fs.String("updated", getEnv("SQL_SYNC_UPDATED_COLUMN", "updated_at"), "更新时间列，用于增量同步")
+	title := fs.String("title", getEnv("SQL_SYNC_TITLE", "{{.title}}"), "标题模板")
+	content := fs.String("content", getEnv("SQL_SYNC_CONTENT", "{{.content}}"), "正文模板")
+	meta := fs.String("meta", getEnv("SQL_SYNC_META", ""), "写入元数据的列，逗号分隔")
+	interval := fs.Duration("interval", 0, "轮询间隔，为0时只同步一次")
+	statePath := fs.String("state", sqlSyncStatePath(), "同步状态文件")
+	debeziumTopic := fs.String("debezium-topic", getEnv("SQL_SYNC_DEBEZIUM_TOPIC", ""), "Debezium变更事件topic（连接参数见KAFKA_*）")
+	_ = fs.Parse(args)
+
+	if *table == "" {
+		return fmt.Errorf("请通过 -table 或 SQL_SYNC_TABLE 指定数据表")
+	}
+	mapping, err := newSQLRowMapping(*table, *idColumn, *title, *content, *meta)
+	if err != nil {
+		return err
+	}
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *debeziumTopic != "" {
+		return rag.consumeDebezium(ctx, *debeziumTopic, mapping)
+	}
+
+	if *dsn == "" {
+		return fmt.Errorf("请通过 -dsn 或 SQL_SYNC_DSN 指定数据库连接串")
+	}
+	db, err := sql.Open(*driver, *dsn)
+	if err != nil {
+		return fmt.Errorf("连接数据库失败: %w", err)
+	}
+	defer db.Close()
+
+	syncer := &sqlSyncer{rag: rag, db: db, driver: *driver, mapping: mapping, updatedColumn: *updatedColumn, statePath: *statePath}
+	for {
+		if err := syncer.sync(ctx); err != nil {
+			if *interval == 0 {
+				return err
+			}
+			fmt.Printf("⚠️  同步失败: %v\n", err)
+		}
+		if *interval == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// 轮询同步器
+type sqlSyncer struct {
+	rag           *RAGSystem
+	db            *sql.DB
+	driver        string
+	mapping       *sqlRowMapping
+	updatedColumn string
+	statePath     string
+}
+
+// 同步一轮：写入更新时间晚于上次同步的行，删除主键已不存在的行
+func (s *sqlSyncer) sync(ctx context.Context) error {
+	state, err := loadSQLSyncState(s.statePath)
+	if err != nil {
+		return err
+	}
+	if state.Table != s.mapping.Table {
+		state = &sqlSyncState{Table: s.mapping.Table, Rows: make(map[string]string)}
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s", s.mapping.Table)
+	var args []interface{}
+	if state.LastUpdated != "" {
+		placeholder := "?"
+		if s.driver == "postgres" {
+			placeholder = "$1"
+		}
+		query += fmt.Sprintf(" WHERE %s > %s", s.updatedColumn, placeholder)
+		args = append(args, state.LastUpdated)
+	}
+	query += " ORDER BY " + s.updatedColumn
+
+	rows, err := queryRows(ctx, s.db, query, args...)
+	if err != nil {
+		return err
+	}
+
+	var documents []Document
+	lastUpdated := state.LastUpdated
+	for _, row := range rows {
+		doc, err := s.mapping.document(row)
+		if err != nil {
+			fmt.Printf("⚠️  跳过行: %v\n", err)
+			continue
+		}
+		if doc.Content == "" {
+			fmt.Printf("⚠️  跳过行 %s: 正文为空\n", doc.Meta["sql_id"])
+			continue
+		}
+		documents = append(documents, doc)
+		if updated, ok := row[s.updatedColumn]; ok && updated != nil {
+			lastUpdated = fmt.Sprint(updated)
+		}
+	}
+
+	// 硬删除的行只能通过对比主键发现
+	idRows, err := queryRows(ctx, s.db, fmt.Sprintf("SELECT %s FROM %s", s.mapping.IDColumn, s.mapping.Table))
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(idRows))
+	for _, row := range idRows {
+		existing[fmt.Sprint(row[s.mapping.IDColumn])] = true
+	}
+	var removed []string
+	for id, docID := range state.Rows {
+		if !existing[id] {
+			removed = append(removed, docID)
+			delete(state.Rows, id)
+		}
+	}
+
+	if err := s.rag.ReplaceDocuments(documents); err != nil {
+		return err
+	}
+	if err := s.rag.DeleteDocuments(removed); err != nil {
+		return err
+	}
+
+	for _, doc := range documents {
+		state.Rows[doc.Meta["sql_id"].(string)] = doc.ID
+	}
+	state.LastUpdated = lastUpdated
+	if err := saveSQLSyncState(s.statePath, state); err != nil {
+		return fmt.Errorf("保存同步状态失败: %w", err)
+	}
+	if len(documents) > 0 || len(removed) > 0 {
+		fmt.Printf("🔄 %s: 同步 %d 行，删除 %d 行\n", s.mapping.Table, len(documents), len(removed))
+	}
+	return nil
+}
+
+// 执行查询，每行转换为列名到值的映射
+func queryRows(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询数据表失败: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("读取数据行失败: %w", err)
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = normalizeSQLValue(values[i])
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// 驱动返回的[]byte转为字符串，时间统一为数据库可比较的格式
+func normalizeSQLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999")
+	}
+	return value
+}
+
+// Debezium变更事件，兼容带schema的信封格式
+type debeziumEvent struct {
+	Payload *debeziumEvent         `json:"payload"`
+	Op      string                 `json:"op"` // c创建、u更新、d删除、r快照读取
+	Before  map[string]interface{} `json:"before"`
+	After   map[string]interface{} `json:"after"`
+}
+
+// 消费Debezium事件，复用kafka命令的重试和死信队列处理
+func (r *RAGSystem) consumeDebezium(ctx context.Context, topic string, mapping *sqlRowMapping) error {
+	brokerList := splitEnvList(getEnv("KAFKA_BROKERS", "localhost:9092"))
+	group := getEnv("KAFKA_GROUP", "rag-demo")
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: brokerList, Topic: topic, GroupID: group})
+	defer reader.Close()
+	var dlq *kafka.Writer
+	if dlqTopic := getEnv("KAFKA_DLQ_TOPIC", ""); dlqTopic != "" {
+		dlq = &kafka.Writer{Addr: kafka.TCP(brokerList...), Topic: dlqTopic, AllowAutoTopicCreation: true}
+		defer dlq.Close()
+	}
+
+	fmt.Printf("📨 开始消费Debezium事件 %s（数据表 %s）...\n", topic, mapping.Table)
+	return r.consumeEvents(ctx, reader, dlq, getEnvAsInt("KAFKA_MAX_RETRIES", 3), func(value []byte) (*documentEvent, error) {
+		return parseDebeziumEvent(value, mapping)
+	})
+}
+
+// 把Debezium事件转换为文档事件，墓碑消息返回nil
+func parseDebeziumEvent(value []byte, mapping *sqlRowMapping) (*documentEvent, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	var event debeziumEvent
+	if err := decoder.Decode(&event); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidEvent, err)
+	}
+	if event.Payload != nil {
+		event = *event.Payload
+	}
+
+	switch event.Op {
+	case "c", "u", "r":
+		doc, err := mapping.document(event.After)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidEvent, err)
+		}
+		return &documentEvent{Op: documentEventUpdate, ID: doc.ID, Title: doc.Title, Content: doc.Content, Meta: doc.Meta}, nil
+	case "d":
+		id, ok := event.Before[mapping.IDColumn]
+		if !ok {
+			return nil, fmt.Errorf("%w: 删除事件中缺少主键列 %s", errInvalidEvent, mapping.IDColumn)
+		}
+		return &documentEvent{Op: documentEventDelete, ID: mapping.documentID(id)}, nil
+	default:
+		return nil, fmt.Errorf("%w: 未知的Debezium op: %s", errInvalidEvent, event.Op)
+	}
+}
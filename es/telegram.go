@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Telegram单条消息最大长度
+const telegramMaxMessageLen = 4096
+
+// Telegram Bot API客户端
+type telegramClient struct {
+	token  string
+	client *http.Client
+	format string // 回复格式：telegram（MarkdownV2）、plain或markdown（原文）
+}
+
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageID int64  `json:"message_id"`
+	Text      string `json:"text"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+// 调用Bot API方法
+func (t *telegramClient) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("https://api.telegram.org/bot%s/%s", t.token, method), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("解析Telegram响应失败: %w", err)
+	}
+	if !envelope.OK {
+		return fmt.Errorf("Telegram %s 失败: %s", method, envelope.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(envelope.Result, result)
+	}
+	return nil
+}
+
+func (t *telegramClient) sendMessage(ctx context.Context, chatID int64, text string) (int64, error) {
+	var message telegramMessage
+	err := t.call(ctx, "sendMessage", t.withParseMode(map[string]interface{}{"chat_id": chatID, "text": text}), &message)
+	return message.MessageID, err
+}
+
+func (t *telegramClient) editMessage(ctx context.Context, chatID, messageID int64, text string) error {
+	return t.call(ctx, "editMessageText", t.withParseMode(map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"text":       text,
+	}), nil)
+}
+
+// MarkdownV2格式的消息需指定parse_mode，文本须已按MarkdownV2转义
+func (t *telegramClient) withParseMode(params map[string]interface{}) map[string]interface{} {
+	if t.format == replyFormatTelegram {
+		params["parse_mode"] = "MarkdownV2"
+	}
+	return params
+}
+
+// telegram命令：以长轮询方式运行Telegram机器人，流式回复RAG答案
+func runTelegram(args []string) error {
+	fs := flag.NewFlagSet("telegram", flag.ExitOnError)
+	mode := fs.String("mode", getEnv("TELEGRAM_STREAM_MODE", "edit"), "流式回复方式: edit（编辑同一条消息）或 chunk（分多条消息发送）")
+	interval := fs.Duration("interval", time.Duration(getEnvAsInt("TELEGRAM_EDIT_INTERVAL_MS", 1000))*time.Millisecond, "两次编辑消息的最小间隔")
+	format := fs.String("format", getEnv("TELEGRAM_FORMAT", replyFormatTelegram), "回复格式: telegram（MarkdownV2）、plain（纯文本）或 markdown（原文）")
+	_ = fs.Parse(args)
+	if err := validReplyFormat(*format); err != nil || *format == replyFormatSlack {
+		return fmt.Errorf("TELEGRAM_FORMAT应为 telegram、plain 或 markdown: %s", *format)
+	}
+
+	token := getEnv("TELEGRAM_BOT_TOKEN", "")
+	if token == "" {
+		return fmt.Errorf("TELEGRAM_BOT_TOKEN不能为空")
+	}
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	bot := &telegramClient{token: token, client: &http.Client{Timeout: 60 * time.Second}, format: *format}
+	ctx := context.Background()
+	fmt.Println("🤖 Telegram机器人已启动，等待消息...")
+
+	var offset int64
+	for {
+		var updates []telegramUpdate
+		err := bot.call(ctx, "getUpdates", map[string]interface{}{"offset": offset, "timeout": 30}, &updates)
+		if err != nil {
+			fmt.Printf("⚠️  获取消息失败: %v\n", err)
+			time.Sleep(3 * time.Second)
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil || strings.TrimSpace(update.Message.Text) == "" {
+				continue
+			}
+			// 按顺序处理，保证同一会话内回复顺序与提问一致
+			bot.answer(ctx, rag, update.Message, *mode, *interval)
+		}
+	}
+}
+
+// 回答一条消息
+func (t *telegramClient) answer(ctx context.Context, rag *RAGSystem, message *telegramMessage, mode string, interval time.Duration) {
+	chatID := message.Chat.ID
+
+	var sink replySink
+	if mode == "chunk" {
+		sink = newChunkingSink(func(text string) error {
+			_, err := t.sendMessage(ctx, chatID, text)
+			return err
+		}, telegramMaxMessageLen)
+	} else {
+		sink = newEditingSink(
+			func(text string) (int64, error) { return t.sendMessage(ctx, chatID, text) },
+			func(messageID int64, text string) error { return t.editMessage(ctx, chatID, messageID, text) },
+			telegramMaxMessageLen, interval,
+		)
+	}
+	sink = &formattingSink{sink: sink, format: t.format}
+
+	// "/fresh 问题" 跳过答案缓存，强制重新生成
+	question := message.Text
+	fresh := strings.HasPrefix(question, "/fresh ")
+	if fresh {
+		question = strings.TrimSpace(strings.TrimPrefix(question, "/fresh "))
+	}
+
+	answer, sources, reviewID, err := rag.StreamRAGAnswer(ctx, question, fresh, rag.config.AnswerLength.resolve("", channelTelegram), sink)
+	if err != nil {
+		_, _ = t.sendMessage(ctx, chatID, convertMarkdown("❌ 回答失败: "+err.Error(), t.format))
+		return
+	}
+	// 进入人工审核的回答只发送等待提示和审核ID，不附术语和来源
+	if reviewID != 0 {
+		_, _ = t.sendMessage(ctx, chatID, convertMarkdown(fmt.Sprintf("审核ID: %d", reviewID), t.format))
+		return
+	}
+
+	if terms := rag.settings().glossary.Match(question, answer); len(terms) > 0 {
+		_, _ = t.sendMessage(ctx, chatID, convertMarkdown("📖 术语解释:\n"+formatGlossary(terms), t.format))
+	}
+
+	if len(sources) > 0 {
+		var builder strings.Builder
+		builder.WriteString(formatSourceList(sources))
+
+		if rag.config.FollowUps {
+			if followUps, err := rag.SuggestFollowUps(ctx, question, answer, sources); err == nil && len(followUps) > 0 {
+				builder.WriteString("\n💡 你可能还想问:\n")
+				for i, followUp := range followUps {
+					builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, followUp))
+				}
+			}
+		}
+		_, _ = t.sendMessage(ctx, chatID, convertMarkdown(builder.String(), t.format))
+	}
+}
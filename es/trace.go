@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 检索轨迹配置：TRACE_DIR不为空时把每次检索的候选和最终选用的分块追加写入JSONL文件，
+// 按天和文件大小滚动，供离线分析排序效果；不记录问题原文，只记录哈希
+type TraceConfig struct {
+	Dir      string
+	MaxBytes int64 // 单个文件的大小上限
+}
+
+func loadTraceConfig() TraceConfig {
+	return TraceConfig{
+		Dir:      getEnv("TRACE_DIR", ""),
+		MaxBytes: int64(getEnvAsInt("TRACE_MAX_MB", 100)) << 20,
+	}
+}
+
+// 一条检索轨迹
+type retrievalTrace struct {
+	Time          time.Time        `json:"time"`
+	QueryHash     string           `json:"query_hash"`     // 归一化问题的哈希，相同问题可关联
+	EmbeddingHash string           `json:"embedding_hash"` // 查询向量的哈希
+	Mode          string           `json:"mode"`           // fixed、adaptive
+	Profile       string           `json:"profile,omitempty"`
+	Category      string           `json:"category,omitempty"`
+	TopK          int              `json:"top_k"`
+	Features      []string         `json:"features,omitempty"` // 按特性开关开启的检索特性，用于对比放量效果
+	Candidates    []traceCandidate `json:"candidates"`
+	Selected      []string         `json:"selected"` // 最终放入上下文的分块ID
+}
+
+type traceCandidate struct {
+	ID    string  `json:"id"`
+	DocID string  `json:"doc_id"`
+	Score float64 `json:"score"`
+	Trust float64 `json:"trust"`
+}
+
+// 滚动写入的轨迹文件
+type traceWriter struct {
+	config TraceConfig
+	mu     sync.Mutex
+	file   *os.File
+	day    string
+	seq    int
+	size   int64
+}
+
+// 未配置TRACE_DIR时返回nil
+func newTraceWriter(config TraceConfig) (*traceWriter, error) {
+	if config.Dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建轨迹目录失败: %w", err)
+	}
+	return &traceWriter{config: config}, nil
+}
+
+// 追加一条轨迹，跨天或超过大小上限时换新文件
+func (w *traceWriter) Write(trace retrievalTrace) error {
+	if w == nil {
+		return nil
+	}
+	line, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	day := trace.Time.Format("20060102")
+	if w.file == nil || day != w.day || w.size+int64(len(line)) > w.config.MaxBytes {
+		if err := w.rotate(day); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// 打开当天下一个未写满的文件
+func (w *traceWriter) rotate(day string) error {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	if day != w.day {
+		w.day, w.seq = day, 0
+	}
+	for {
+		w.seq++
+		path := filepath.Join(w.config.Dir, fmt.Sprintf("traces-%s-%03d.jsonl", day, w.seq))
+		info, err := os.Stat(path)
+		if err == nil && info.Size() >= w.config.MaxBytes {
+			continue
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("打开轨迹文件失败: %w", err)
+		}
+		w.file, w.size = file, 0
+		if info != nil {
+			w.size = info.Size()
+		}
+		return nil
+	}
+}
+
+func (w *traceWriter) Close() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+}
+
+// 记录一次检索，opts为检索实际使用的条件（解析后的档位、特性和路由到的分类）；写入失败只告警
+func (r *RAGSystem) recordTrace(question string, opts searchOptions, mode string, topK int, candidates, selected []SearchResult) {
+	if r.traces == nil {
+		return
+	}
+	trace := retrievalTrace{
+		Time:          time.Now(),
+		QueryHash:     hashText(strings.ToLower(strings.Join(strings.Fields(question), " "))),
+		EmbeddingHash: hashVector(r.generateSimpleVector(question)),
+		Mode:          mode,
+		Profile:       opts.Profile,
+		Category:      opts.Category,
+		TopK:          topK,
+		Features:      opts.Features.names(),
+		Candidates:    make([]traceCandidate, 0, len(candidates)),
+		Selected:      make([]string, 0, len(selected)),
+	}
+	for _, candidate := range candidates {
+		trace.Candidates = append(trace.Candidates, traceCandidate{ID: candidate.ID, DocID: candidate.DocID, Score: candidate.Score, Trust: candidate.Trust})
+	}
+	for _, result := range selected {
+		trace.Selected = append(trace.Selected, result.ID)
+	}
+	if err := r.traces.Write(trace); err != nil {
+		fmt.Printf("⚠️  写入检索轨迹失败: %v\n", err)
+	}
+}
+
+func hashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+func hashVector(vector []float32) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DeepSeek价格（元/百万tokens），命中上下文缓存的输入token按缓存价计费
+type PricingConfig struct {
+	CacheHit  float64
+	CacheMiss float64
+	Output    float64
+}
+
+func loadPricingConfig() PricingConfig {
+	return PricingConfig{
+		CacheHit:  getEnvAsFloat("DEEPSEEK_PRICE_CACHE_HIT", 0.2),
+		CacheMiss: getEnvAsFloat("DEEPSEEK_PRICE_CACHE_MISS", 2),
+		Output:    getEnvAsFloat("DEEPSEEK_PRICE_OUTPUT", 3),
+	}
+}
+
+// token用量；go-openai的Usage没有缓存字段，这里直接解析DeepSeek返回的usage
+type tokenUsage struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	CacheHitTokens   int `json:"prompt_cache_hit_tokens"`
+	CacheMissTokens  int `json:"prompt_cache_miss_tokens"`
+	ReasoningTokens  int `json:"reasoning_tokens"` // 推理模型的思考tokens，已包含在CompletionTokens中，按输出计费
+}
+
+func (u *tokenUsage) add(other tokenUsage) {
+	u.Calls += other.Calls
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.CacheHitTokens += other.CacheHitTokens
+	u.CacheMissTokens += other.CacheMissTokens
+	u.ReasoningTokens += other.ReasoningTokens
+}
+
+// 相对于之前某个时刻的累计用量增加了多少
+func (u tokenUsage) since(before tokenUsage) tokenUsage {
+	return tokenUsage{
+		Calls:            u.Calls - before.Calls,
+		PromptTokens:     u.PromptTokens - before.PromptTokens,
+		CompletionTokens: u.CompletionTokens - before.CompletionTokens,
+		CacheHitTokens:   u.CacheHitTokens - before.CacheHitTokens,
+		CacheMissTokens:  u.CacheMissTokens - before.CacheMissTokens,
+		ReasoningTokens:  u.ReasoningTokens - before.ReasoningTokens,
+	}
+}
+
+// 缓存命中率
+func (u tokenUsage) CacheHitRate() float64 {
+	if u.PromptTokens == 0 {
+		return 0
+	}
+	return float64(u.CacheHitTokens) / float64(u.PromptTokens)
+}
+
+// 按价格估算费用（元）
+func (u tokenUsage) Cost(pricing PricingConfig) float64 {
+	miss := u.CacheMissTokens
+	if u.CacheHitTokens+miss == 0 {
+		miss = u.PromptTokens // 不支持缓存统计的服务按未命中计费
+	}
+	return (float64(u.CacheHitTokens)*pricing.CacheHit +
+		float64(miss)*pricing.CacheMiss +
+		float64(u.CompletionTokens)*pricing.Output) / 1e6
+}
+
+// 累计所有DeepSeek调用的token用量
+type usageTracker struct {
+	mu    sync.Mutex
+	total tokenUsage
+}
+
+func (t *usageTracker) record(usage tokenUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage.Calls = 1
+	t.total.add(usage)
+}
+
+func (t *usageTracker) Snapshot() tokenUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
+
+// 包装HTTP传输层，从响应中读取usage
+type usageTransport struct {
+	base    http.RoundTripper
+	tracker *usageTracker
+}
+
+func newUsageHTTPClient(tracker *usageTracker) *http.Client {
+	return &http.Client{Transport: &usageTransport{base: &reasoningTransport{base: http.DefaultTransport}, tracker: tracker}}
+}
+
+func (t *usageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// 流式响应在最后一个数据块中携带usage，读完后再解析
+		resp.Body = &usageStreamBody{ReadCloser: resp.Body, tracker: t.tracker}
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if usage, ok := parseUsage(body); ok {
+		t.tracker.record(usage)
+	}
+	return resp, nil
+}
+
+// 边读边保留流式响应内容，关闭时查找usage
+type usageStreamBody struct {
+	io.ReadCloser
+	tracker *usageTracker
+	buf     bytes.Buffer
+}
+
+func (b *usageStreamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *usageStreamBody) Close() error {
+	var last tokenUsage
+	found := false
+	scanner := bufio.NewScanner(&b.buf)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "data: ")
+		if usage, ok := parseUsage([]byte(line)); ok {
+			last, found = usage, true
+		}
+	}
+	if found {
+		b.tracker.record(last)
+	}
+	return b.ReadCloser.Close()
+}
+
+func parseUsage(body []byte) (tokenUsage, bool) {
+	var payload struct {
+		Usage *struct {
+			tokenUsage
+			Details struct {
+				ReasoningTokens int `json:"reasoning_tokens"`
+			} `json:"completion_tokens_details"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Usage == nil {
+		return tokenUsage{}, false
+	}
+	usage := payload.Usage.tokenUsage
+	usage.ReasoningTokens = payload.Usage.Details.ReasoningTokens
+	return usage, true
+}
+
+// 输出成本报告
+func printCostReport(usage tokenUsage, pricing PricingConfig) {
+	if usage.Calls == 0 {
+		return
+	}
+	fmt.Println("\n💰 成本报告:")
+	fmt.Printf("  - 调用次数: %d\n", usage.Calls)
+	fmt.Printf("  - 输入tokens: %d（缓存命中 %d，命中率 %.1f%%）\n", usage.PromptTokens, usage.CacheHitTokens, usage.CacheHitRate()*100)
+	if usage.ReasoningTokens > 0 {
+		fmt.Printf("  - 输出tokens: %d（其中思考过程 %d）\n", usage.CompletionTokens, usage.ReasoningTokens)
+	} else {
+		fmt.Printf("  - 输出tokens: %d\n", usage.CompletionTokens)
+	}
+	fmt.Printf("  - 预估费用: ¥%.4f\n", usage.Cost(pricing))
+}
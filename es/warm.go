@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 热门问题预生成配置：统计问题出现的次数，serve定期为最热门的问题重新生成回答写入答案缓存，
+// 高峰期这些问题直接命中缓存。TopN或刷新间隔为0时不开启
+type WarmConfig struct {
+	TopN     int
+	Interval time.Duration // 刷新间隔，应小于ANSWER_CACHE_TTL_SECONDS，缓存过期前完成刷新
+	MinCount int           // 一个统计周期内至少出现的次数
+}
+
+func loadWarmConfig() WarmConfig {
+	return WarmConfig{
+		TopN:     getEnvAsInt("WARM_TOP_N", 0),
+		Interval: time.Duration(getEnvAsInt("WARM_INTERVAL_SECONDS", 600)) * time.Second,
+		MinCount: getEnvAsInt("WARM_MIN_COUNT", 3),
+	}
+}
+
+// 最多统计的不同问题数，超出后新问题要等下次衰减腾出位置
+const maxTrackedQuestions = 10000
+
+// 问题频次统计，每个刷新周期计数减半，长期不再出现的问题逐渐移出
+type questionTracker struct {
+	mu        sync.Mutex
+	counts    map[string]int
+	questions map[string]string // 规范化问题 -> 最近一次的原始问题
+}
+
+func newQuestionTracker(config WarmConfig) *questionTracker {
+	if config.TopN <= 0 || config.Interval <= 0 {
+		return nil
+	}
+	return &questionTracker{counts: make(map[string]int), questions: make(map[string]string)}
+}
+
+func (t *questionTracker) Record(question string) {
+	if t == nil {
+		return
+	}
+	key := normalizeQuestion(question)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.counts[key]; !ok && len(t.counts) >= maxTrackedQuestions {
+		return
+	}
+	t.counts[key]++
+	t.questions[key] = question
+}
+
+// 取出次数不少于minCount的前n个问题，然后所有计数减半
+func (t *questionTracker) Trending(n, minCount int) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keys := make([]string, 0, len(t.counts))
+	for key, count := range t.counts {
+		if count >= minCount {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if t.counts[keys[i]] != t.counts[keys[j]] {
+			return t.counts[keys[i]] > t.counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	trending := make([]string, 0, len(keys))
+	for _, key := range keys {
+		trending = append(trending, t.questions[key])
+	}
+
+	for key := range t.counts {
+		if t.counts[key] /= 2; t.counts[key] == 0 {
+			delete(t.counts, key)
+			delete(t.questions, key)
+		}
+	}
+	return trending
+}
+
+// 启动热门问题预生成，未开启频次统计或答案缓存、WARM_INTERVAL_SECONDS=0时不启动
+func (r *RAGSystem) startWarmer() bool {
+	if r.trending == nil || r.answers == nil || r.config.Warm.Interval <= 0 {
+		return false
+	}
+	go func() {
+		ticker := time.NewTicker(r.config.Warm.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			r.warmTrending()
+		}
+	}()
+	return true
+}
+
+// 为热门问题重新生成回答并写入缓存，和用户请求一样经过请求合并
+func (r *RAGSystem) warmTrending() {
+	questions := r.trending.Trending(r.config.Warm.TopN, r.config.Warm.MinCount)
+	if len(questions) == 0 {
+		return
+	}
+	warmed := 0
+	for _, question := range questions {
+		opts, _ := searchOptions{Degraded: &degradation{}}.resolve(r.settings().Retrieval.Profile)
+		ctx, cancel := context.WithTimeout(context.Background(), r.config.Warm.Interval)
+		answer, _, sources, err := r.flights.do(ctx, question, opts, func(ctx context.Context, opts searchOptions) (string, float64, []SearchResult, error) {
+			return r.GetRAGAnswer(ctx, question, opts)
+		})
+		if err != nil {
+			log.Printf("⚠️  预生成热门问题失败: %s: %v", question, err)
+		} else if len(opts.Degraded.Tiers()) == 0 && !r.reviews.needsReview(sources) {
+			// 需要人工审核的回答不预生成，和 /ask 一样不写入缓存
+			r.answers.Store(ctx, question, answer, sources)
+			warmed++
+		}
+		cancel()
+	}
+	log.Printf("🔥 已预生成 %d/%d 个热门问题的回答", warmed, len(questions))
+}
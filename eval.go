@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 生成评测问题时，分块至少需要的字符数
+const minEvalChunkRunes = 30
+
+// 评测用例：由某个分块合成的问题和参考答案
+type evalCase struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	DocID    string `json:"doc_id"`
+	ChunkID  string `json:"chunk_id"`
+}
+
+// 评测集
+type evalSet struct {
+	CreatedAt time.Time  `json:"created_at"`
+	Cases     []evalCase `json:"cases"`
+}
+
+// 评测结果
+type evalReport struct {
+	Holdout      bool    `json:"holdout"`
+	Cases        int     `json:"cases"`
+	Failed       int     `json:"failed"`
+	DocHitRate   float64 `json:"doc_hit_rate"`   // 检索结果包含用例所属文档的比例
+	ChunkHitRate float64 `json:"chunk_hit_rate"` // 检索结果包含出题分块的比例，留出模式下恒为0
+	AnswerRecall float64 `json:"answer_recall"`  // 参考答案中的词在回答中出现的平均比例
+
+	Results []evalCaseResult `json:"-"` // 逐题结果，用于导出报告
+}
+
+// 单个评测用例的结果
+type evalCaseResult struct {
+	Question string
+	DocID    string
+	ChunkID  string
+	DocHit   bool
+	ChunkHit bool
+	Recall   float64
+	Sources  []string // 回答引用的文档
+	Answer   string
+	Err      string
+}
+
+func loadEvalSet(path string) (*evalSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取评测集失败: %w", err)
+	}
+	var set evalSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("解析评测集失败: %w", err)
+	}
+	return &set, nil
+}
+
+func saveEvalSet(path string, set *evalSet) error {
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// eval命令：-generate 从知识库分块合成评测问题，否则运行评测；-holdout 评测时把出题分块从索引中留出；-record 记录到评测历史；
+// -order 依次用多种上下文排列方式评测并对比；-report-dir 导出CSV和Markdown报告
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	setPath := fs.String("set", getEnv("EVAL_SET", "eval_set.json"), "评测集文件")
+	generate := fs.Int("generate", 0, "从知识库随机抽取分块合成指定数量的评测问题")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "抽样随机种子")
+	holdout := fs.Bool("holdout", false, "留出模式：评测期间从索引中移除出题分块，衡量泛化而不是对原文的记忆")
+	record := fs.Bool("record", false, "把结果写入评测历史库（EVAL_HISTORY_DB），留出模式的结果不记录")
+	orders := fs.String("order", "", "对比的上下文排列方式，逗号分隔，例如 id,relevance_last,document；对比时不记录评测历史")
+	reportDir := fs.String("report-dir", "", "把逐题结果（CSV）和汇总报告（Markdown）写入该目录，文件名带运行时间")
+	_ = fs.Parse(args)
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	if *generate > 0 {
+		set, err := rag.GenerateEvalSet(context.Background(), *generate, *seed)
+		if err != nil {
+			return err
+		}
+		if err := saveEvalSet(*setPath, set); err != nil {
+			return fmt.Errorf("保存评测集失败: %w", err)
+		}
+		fmt.Printf("✅ 已生成 %d 个评测问题，保存到 %s\n", len(set.Cases), *setPath)
+		return nil
+	}
+
+	set, err := loadEvalSet(*setPath)
+	if err != nil {
+		return err
+	}
+	runAt := time.Now()
+	if *orders != "" {
+		names := strings.Split(*orders, ",")
+		reports, err := rag.compareContextOrders(set, *holdout, names)
+		if err != nil || *reportDir == "" {
+			return err
+		}
+		return exportReports(*reportDir, "eval-compare", runAt, compareCSV(names, reports), compareMarkdown(*setPath, runAt, names, reports))
+	}
+	report, err := rag.RunEval(set, *holdout)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n📊 评测结果:")
+	fmt.Printf("  - 用例数: %d（失败 %d）\n", report.Cases, report.Failed)
+	fmt.Printf("  - 文档命中率: %.1f%%\n", report.DocHitRate*100)
+	if report.Holdout {
+		fmt.Println("  - 分块命中率: -（留出模式）")
+	} else {
+		fmt.Printf("  - 分块命中率: %.1f%%\n", report.ChunkHitRate*100)
+	}
+	fmt.Printf("  - 答案召回率: %.1f%%\n", report.AnswerRecall*100)
+	printCostReport(rag.usage.Snapshot(), rag.config.Pricing)
+	if *reportDir != "" {
+		if err := exportReports(*reportDir, "eval", runAt, evalCSV(report), evalMarkdown(*setPath, runAt, report)); err != nil {
+			return err
+		}
+	}
+
+	if *record && !report.Holdout {
+		history, err := openEvalHistory(rag.config.EvalSchedule.HistoryDB)
+		if err != nil {
+			return err
+		}
+		defer history.Close()
+		if err := history.Record(time.Now(), report); err != nil {
+			return err
+		}
+		fmt.Printf("✅ 已记录到评测历史: %s\n", rag.config.EvalSchedule.HistoryDB)
+	}
+	return nil
+}
+
+// 从源文档的分块中随机抽样，为每个分块合成一个可由该分块回答的问题
+func (r *RAGSystem) GenerateEvalSet(ctx context.Context, n int, seed int64) (*evalSet, error) {
+	documents, err := r.sourceDocuments()
+	if err != nil {
+		return nil, fmt.Errorf("加载源文档失败: %w", err)
+	}
+
+	var chunks []Chunk
+	for _, doc := range documents {
+		for _, chunk := range splitDocument(doc, r.config.ChunkSize) {
+			if len([]rune(chunk.Content)) >= minEvalChunkRunes {
+				chunks = append(chunks, chunk)
+			}
+		}
+	}
+	rand.New(rand.NewSource(seed)).Shuffle(len(chunks), func(i, j int) {
+		chunks[i], chunks[j] = chunks[j], chunks[i]
+	})
+
+	set := &evalSet{CreatedAt: time.Now()}
+	for _, chunk := range chunks {
+		if len(set.Cases) == n {
+			break
+		}
+		question, answer, err := r.synthesizeQuestion(ctx, chunk)
+		if err != nil {
+			fmt.Printf("⚠️  分块 %s 生成问题失败: %v\n", chunk.ID, err)
+			continue
+		}
+		set.Cases = append(set.Cases, evalCase{Question: question, Answer: answer, DocID: chunk.DocID, ChunkID: chunk.ID})
+		fmt.Printf("📝 %s: %s\n", chunk.ID, question)
+	}
+	return set, nil
+}
+
+// 让模型基于单个分块出一道问答题
+func (r *RAGSystem) synthesizeQuestion(ctx context.Context, chunk Chunk) (string, string, error) {
+	if err := r.faults.inject(ctx, faultTargetLLM, "DeepSeek合成评测问题"); err != nil {
+		return "", "", err
+	}
+	resp, err := r.openAIClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: r.config.DeepSeekModel,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "你负责为知识库问答系统出评测题。根据给定片段提出一个只能依靠该片段回答的具体问题，并给出简短的参考答案。只输出JSON对象，例如{\"question\":\"问题\",\"answer\":\"答案\"}。",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("标题：%s\n片段：%s", chunk.Title, chunk.Content),
+			},
+		},
+		Temperature: 0.5,
+		MaxTokens:   300,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", "", fmt.Errorf("未收到回答")
+	}
+
+	var qa struct {
+		Question string `json:"question"`
+		Answer   string `json:"answer"`
+	}
+	if err := json.Unmarshal([]byte(trimCodeFence(resp.Choices[0].Message.Content)), &qa); err != nil {
+		return "", "", fmt.Errorf("解析问题失败: %w", err)
+	}
+	if qa.Question == "" || qa.Answer == "" {
+		return "", "", fmt.Errorf("问题或答案为空")
+	}
+	return qa.Question, qa.Answer, nil
+}
+
+// 运行评测；留出模式下先删除所有出题分块，评测结束后重新写入所属文档
+func (r *RAGSystem) RunEval(set *evalSet, holdout bool) (*evalReport, error) {
+	report := &evalReport{Holdout: holdout, Cases: len(set.Cases)}
+	if len(set.Cases) == 0 {
+		return report, nil
+	}
+
+	if holdout {
+		restore, err := r.holdOutChunks(set.Cases)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := restore(); err != nil {
+				fmt.Printf("⚠️  恢复留出分块失败: %v\n", err)
+			}
+		}()
+	}
+
+	var docHits, chunkHits int
+	var recall float64
+	for i, c := range set.Cases {
+		result := evalCaseResult{Question: c.Question, DocID: c.DocID, ChunkID: c.ChunkID}
+		answer, _, sources, err := r.GetRAGAnswer(context.Background(), c.Question, searchOptions{})
+		if err != nil {
+			fmt.Printf("❌ [%d/%d] %s: %v\n", i+1, len(set.Cases), c.Question, err)
+			report.Failed++
+			result.Err = err.Error()
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		docHit, chunkHit := false, false
+		for _, source := range sources {
+			docHit = docHit || source.DocID == c.DocID
+			chunkHit = chunkHit || source.ID == c.ChunkID
+		}
+		if docHit {
+			docHits++
+		}
+		if chunkHit {
+			chunkHits++
+		}
+		caseRecall := answerRecall(c.Answer, answer)
+		recall += caseRecall
+		result.DocHit, result.ChunkHit, result.Recall = docHit, chunkHit, caseRecall
+		result.Sources, result.Answer = sourceDocIDs(sources), answer
+		report.Results = append(report.Results, result)
+
+		mark := "✅"
+		if !docHit {
+			mark = "⚠️ "
+		}
+		fmt.Printf("%s [%d/%d] %s（答案召回 %.0f%%）\n", mark, i+1, len(set.Cases), c.Question, caseRecall*100)
+	}
+
+	total := float64(len(set.Cases))
+	report.DocHitRate = float64(docHits) / total
+	report.ChunkHitRate = float64(chunkHits) / total
+	report.AnswerRecall = recall / total
+	return report, nil
+}
+
+// 依次用每种上下文排列方式运行评测，排列只影响生成，对比答案召回率；返回与orders对应的评测结果
+func (r *RAGSystem) compareContextOrders(set *evalSet, holdout bool, orders []string) ([]*evalReport, error) {
+	for i, order := range orders {
+		orders[i] = strings.TrimSpace(order)
+		if err := validateContextOrder(orders[i]); err != nil {
+			return nil, err
+		}
+	}
+	configured := r.settings()
+	defer r.live.Store(configured)
+
+	reports := make([]*evalReport, 0, len(orders))
+	for _, order := range orders {
+		fmt.Printf("\n🔀 上下文排列方式: %s\n", order)
+		settings := *configured
+		settings.Retrieval.Order = order
+		r.live.Store(&settings)
+		report, err := r.RunEval(set, holdout)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	fmt.Println("\n📊 上下文排列方式对比:")
+	for i, report := range reports {
+		mark := "  "
+		if orders[i] == configured.Retrieval.Order {
+			mark = "👉"
+		}
+		fmt.Printf("  %s %-15s 答案召回率 %.1f%%  文档命中率 %.1f%%  失败 %d\n", mark, orders[i], report.AnswerRecall*100, report.DocHitRate*100, report.Failed)
+	}
+	printCostReport(r.usage.Snapshot(), r.config.Pricing)
+	return reports, nil
+}
+
+// 从索引中删除评测用例的出题分块，返回恢复函数
+func (r *RAGSystem) holdOutChunks(cases []evalCase) (func() error, error) {
+	chunkIDs := make([]string, 0, len(cases))
+	docIDs := make(map[string]bool)
+	for _, c := range cases {
+		chunkIDs = append(chunkIDs, c.ChunkID)
+		docIDs[c.DocID] = true
+	}
+
+	if err := r.DeleteChunks(chunkIDs); err != nil {
+		return nil, fmt.Errorf("留出评测分块失败: %w", err)
+	}
+	fmt.Printf("🙈 已从索引中留出 %d 个评测分块\n", len(chunkIDs))
+
+	return func() error {
+		documents, err := r.sourceDocuments()
+		if err != nil {
+			return err
+		}
+		var affected []Document
+		for _, doc := range documents {
+			if docIDs[doc.ID] {
+				affected = append(affected, doc)
+			}
+		}
+		return r.ReplaceDocuments(affected)
+	}, nil
+}
+
+// 参考答案中的词（中文按字，其他按单词）在回答中出现的比例
+func answerRecall(reference, answer string) float64 {
+	refTokens := recallTokens(reference)
+	if len(refTokens) == 0 {
+		return 0
+	}
+	answerTokens := recallTokens(answer)
+	matched := 0
+	for token := range refTokens {
+		if answerTokens[token] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(refTokens))
+}
+
+func recallTokens(text string) map[string]bool {
+	tokens := make(map[string]bool)
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens[strings.ToLower(word.String())] = true
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			tokens[string(r)] = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 定时评测配置：每天在Time时刻对线上索引运行评测集，结果写入SQLite历史库，周环比下降超过AlertDrop时告警
+type EvalScheduleConfig struct {
+	Time         string // HH:MM，为空时不启动定时评测
+	SetPath      string
+	HistoryDB    string
+	AlertDrop    float64 // 指标周环比下降的告警阈值（绝对值，0.05即5个百分点）
+	AlertWebhook string  // 告警推送地址（可选），POST {"text": "..."}
+}
+
+func loadEvalScheduleConfig() EvalScheduleConfig {
+	return EvalScheduleConfig{
+		Time:         getEnv("EVAL_SCHEDULE", ""),
+		SetPath:      getEnv("EVAL_SET", "eval_set.json"),
+		HistoryDB:    getEnv("EVAL_HISTORY_DB", ".eval_history.db"),
+		AlertDrop:    getEnvAsFloat("EVAL_ALERT_DROP", 0.05),
+		AlertWebhook: getEnv("EVAL_ALERT_WEBHOOK", ""),
+	}
+}
+
+// 一次评测记录
+type evalRun struct {
+	RunAt        time.Time `json:"run_at"`
+	Cases        int       `json:"cases"`
+	Failed       int       `json:"failed"`
+	DocHitRate   float64   `json:"doc_hit_rate"`
+	ChunkHitRate float64   `json:"chunk_hit_rate"`
+	AnswerRecall float64   `json:"answer_recall"`
+}
+
+// 评测历史，存储在SQLite中
+type evalHistory struct {
+	db *sql.DB
+}
+
+func openEvalHistory(path string) (*evalHistory, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("打开评测历史库失败: %w", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS eval_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_at INTEGER NOT NULL,
+		cases INTEGER NOT NULL,
+		failed INTEGER NOT NULL,
+		doc_hit_rate REAL NOT NULL,
+		chunk_hit_rate REAL NOT NULL,
+		answer_recall REAL NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化评测历史库失败: %w", err)
+	}
+	return &evalHistory{db: db}, nil
+}
+
+func (h *evalHistory) Close() error {
+	return h.db.Close()
+}
+
+// 记录一次评测结果
+func (h *evalHistory) Record(runAt time.Time, report *evalReport) error {
+	_, err := h.db.Exec(
+		`INSERT INTO eval_runs (run_at, cases, failed, doc_hit_rate, chunk_hit_rate, answer_recall) VALUES (?, ?, ?, ?, ?, ?)`,
+		runAt.Unix(), report.Cases, report.Failed, report.DocHitRate, report.ChunkHitRate, report.AnswerRecall,
+	)
+	if err != nil {
+		return fmt.Errorf("写入评测历史失败: %w", err)
+	}
+	return nil
+}
+
+// 查询since之后的评测记录，按时间升序
+func (h *evalHistory) Since(since time.Time) ([]evalRun, error) {
+	rows, err := h.db.Query(
+		`SELECT run_at, cases, failed, doc_hit_rate, chunk_hit_rate, answer_recall FROM eval_runs WHERE run_at >= ? ORDER BY run_at`,
+		since.Unix(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询评测历史失败: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []evalRun{}
+	for rows.Next() {
+		var run evalRun
+		var runAt int64
+		if err := rows.Scan(&runAt, &run.Cases, &run.Failed, &run.DocHitRate, &run.ChunkHitRate, &run.AnswerRecall); err != nil {
+			return nil, fmt.Errorf("读取评测历史失败: %w", err)
+		}
+		run.RunAt = time.Unix(runAt, 0)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// 周环比：最近7天与之前7天各指标的平均值
+type evalTrend struct {
+	Current  *evalRun `json:"current,omitempty"`  // 最近7天平均
+	Previous *evalRun `json:"previous,omitempty"` // 之前7天平均
+	Drops    []string `json:"drops"`              // 下降超过阈值的指标说明
+}
+
+func weekOverWeek(runs []evalRun, now time.Time, threshold float64) evalTrend {
+	weekAgo := now.AddDate(0, 0, -7)
+	twoWeeksAgo := now.AddDate(0, 0, -14)
+	var current, previous []evalRun
+	for _, run := range runs {
+		switch {
+		case !run.RunAt.Before(weekAgo):
+			current = append(current, run)
+		case !run.RunAt.Before(twoWeeksAgo):
+			previous = append(previous, run)
+		}
+	}
+
+	trend := evalTrend{Current: averageRuns(current), Previous: averageRuns(previous), Drops: []string{}}
+	if trend.Current == nil || trend.Previous == nil {
+		return trend
+	}
+	metrics := []struct {
+		name              string
+		current, previous float64
+	}{
+		{"文档命中率", trend.Current.DocHitRate, trend.Previous.DocHitRate},
+		{"分块命中率", trend.Current.ChunkHitRate, trend.Previous.ChunkHitRate},
+		{"答案召回率", trend.Current.AnswerRecall, trend.Previous.AnswerRecall},
+	}
+	for _, m := range metrics {
+		if m.previous-m.current > threshold {
+			trend.Drops = append(trend.Drops, fmt.Sprintf("%s %.1f%% → %.1f%%", m.name, m.previous*100, m.current*100))
+		}
+	}
+	return trend
+}
+
+func averageRuns(runs []evalRun) *evalRun {
+	if len(runs) == 0 {
+		return nil
+	}
+	avg := &evalRun{RunAt: runs[len(runs)-1].RunAt}
+	for _, run := range runs {
+		avg.Cases += run.Cases
+		avg.Failed += run.Failed
+		avg.DocHitRate += run.DocHitRate
+		avg.ChunkHitRate += run.ChunkHitRate
+		avg.AnswerRecall += run.AnswerRecall
+	}
+	n := float64(len(runs))
+	avg.Cases /= len(runs)
+	avg.Failed /= len(runs)
+	avg.DocHitRate /= n
+	avg.ChunkHitRate /= n
+	avg.AnswerRecall /= n
+	return avg
+}
+
+// 距离下一个HH:MM时刻的时长
+func untilNextRun(at string, now time.Time) (time.Duration, error) {
+	t, err := time.ParseInLocation("15:04", at, now.Location())
+	if err != nil {
+		return 0, fmt.Errorf("EVAL_SCHEDULE格式应为HH:MM: %w", err)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Sub(now), nil
+}
+
+// 启动定时评测：每天在配置的时刻运行评测集，记录结果并检查周环比
+func (r *RAGSystem) startEvalSchedule(history *evalHistory) error {
+	config := r.config.EvalSchedule
+	if _, err := untilNextRun(config.Time, time.Now()); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			wait, _ := untilNextRun(config.Time, time.Now())
+			time.Sleep(wait)
+			if err := r.scheduledEval(history); err != nil {
+				log.Printf("⚠️  定时评测失败: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// 运行一次评测并记录；线上索引不能留出分块，定时评测总是非留出模式
+func (r *RAGSystem) scheduledEval(history *evalHistory) error {
+	config := r.config.EvalSchedule
+	set, err := loadEvalSet(config.SetPath)
+	if err != nil {
+		return err
+	}
+	log.Printf("🧪 开始定时评测: %d 个用例", len(set.Cases))
+	report, err := r.RunEval(set, false)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := history.Record(now, report); err != nil {
+		return err
+	}
+	log.Printf("📊 定时评测完成: 文档命中率 %.1f%%，答案召回率 %.1f%%", report.DocHitRate*100, report.AnswerRecall*100)
+
+	runs, err := history.Since(now.AddDate(0, 0, -14))
+	if err != nil {
+		return err
+	}
+	if trend := weekOverWeek(runs, now, config.AlertDrop); len(trend.Drops) > 0 {
+		evalAlert(config.AlertWebhook, "评测指标周环比下降: "+strings.Join(trend.Drops, "，"))
+	}
+	return nil
+}
+
+// 发出评测告警，配置了webhook时同时推送
+func evalAlert(webhook, message string) {
+	log.Printf("🚨 [eval] %s", message)
+	if webhook == "" {
+		return
+	}
+	if err := postAlert(webhook, message); err != nil {
+		log.Printf("⚠️  推送评测告警失败: %v", err)
+	}
+}
+
+// 推送告警：POST {"text": "..."}
+func postAlert(webhook, message string) error {
+	body, _ := json.Marshal(map[string]string{"text": message})
+	resp, err := http.Post(webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
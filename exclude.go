@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 排除一个元数据取值：字段为字符串时取值相等，为列表时包含该取值
+type metaExclusion struct {
+	Field string
+	Value string
+}
+
+var metaFieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 解析请求的排除条件，格式为 字段:值，例如 category:archive；doc_id:xxx 等同于exclude_docs
+func parseExclusions(exclude, docs []string) ([]metaExclusion, []string, error) {
+	var exclusions []metaExclusion
+	docIDs := append([]string(nil), docs...)
+	for _, item := range exclude {
+		field, value, ok := strings.Cut(item, ":")
+		field, value = strings.TrimSpace(field), strings.TrimSpace(value)
+		if !ok || value == "" || !metaFieldPattern.MatchString(field) {
+			return nil, nil, fmt.Errorf("排除条件格式应为 字段:值，例如 category:archive: %s", item)
+		}
+		if field == "doc_id" {
+			docIDs = append(docIDs, value)
+			continue
+		}
+		exclusions = append(exclusions, metaExclusion{Field: field, Value: value})
+	}
+	return exclusions, docIDs, nil
+}
+
+// 是否有排除条件；有排除条件时回答取决于排除了哪些内容，不读写答案缓存
+func (o searchOptions) hasExclusions() bool {
+	return len(o.Exclude) > 0 || len(o.ExcludeDocs) > 0
+}
+
+// 排除条件的规范形式，用于检索缓存和请求合并的键
+func (o searchOptions) exclusionKey() string {
+	if !o.hasExclusions() {
+		return ""
+	}
+	keys := make([]string, 0, len(o.Exclude)+len(o.ExcludeDocs))
+	for _, exclusion := range o.Exclude {
+		keys = append(keys, exclusion.Field+":"+exclusion.Value)
+	}
+	for _, docID := range o.ExcludeDocs {
+		keys = append(keys, "doc_id:"+docID)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// 检索结果是否命中排除条件，用于无法在存储端过滤的检索路径
+func (o searchOptions) excludes(result SearchResult) bool {
+	if containsString(o.ExcludeDocs, result.DocID) {
+		return true
+	}
+	for _, exclusion := range o.Exclude {
+		switch value := result.Meta[exclusion.Field].(type) {
+		case string:
+			if value == exclusion.Value {
+				return true
+			}
+		case []interface{}:
+			for _, item := range value {
+				if item == exclusion.Value {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// 去掉命中排除条件的检索结果
+func (o searchOptions) dropExcluded(results []SearchResult) []SearchResult {
+	if !o.hasExclusions() {
+		return results
+	}
+	kept := results[:0]
+	for _, result := range results {
+		if !o.excludes(result) {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+// 按请求设置排除条件；excludeShown时再排除会话展示过的来源
+func (s *apiServer) applyExclusions(opts *searchOptions, exclude, docs []string, sessionID string, excludeShown bool) error {
+	var err error
+	if opts.Exclude, opts.ExcludeDocs, err = parseExclusions(exclude, docs); err != nil {
+		return err
+	}
+	if excludeShown {
+		if sessionID == "" {
+			return fmt.Errorf("exclude_shown需要同时提供session")
+		}
+		opts.ExcludeDocs = append(opts.ExcludeDocs, s.rag.sessions.Shown(sessionID)...)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 查询设置了expires_at且已过期的文档；Milvus的JSON字段按字符串比较，过期判断在应用内完成
+func (r *RAGSystem) findExpired(ctx context.Context, now time.Time) ([]expiredDocument, error) {
+	collectionName := r.config.CollectionName
+	if err := r.milvusClient.LoadCollection(ctx, collectionName, false); err != nil {
+		return nil, fmt.Errorf("加载集合失败: %w", err)
+	}
+	resultSet, err := r.milvusClient.Query(ctx, collectionName, nil, fmt.Sprintf("meta[%q] != \"\"", expiresAtKey),
+		[]string{"id", "doc_id", "title", "content", "meta"})
+	if err != nil {
+		return nil, fmt.Errorf("查询过期分块失败: %w", err)
+	}
+
+	idCol, ok := resultSet.GetColumn("id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("ID列类型错误")
+	}
+	docIDCol, ok := resultSet.GetColumn("doc_id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("doc_id列类型错误")
+	}
+	titleCol, ok := resultSet.GetColumn("title").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("title列类型错误")
+	}
+	contentCol, ok := resultSet.GetColumn("content").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("content列类型错误")
+	}
+	metaCol, ok := resultSet.GetColumn("meta").(*entity.ColumnJSONBytes)
+	if !ok {
+		return nil, fmt.Errorf("meta列类型错误")
+	}
+
+	chunks := make([]SearchResult, 0, len(idCol.Data()))
+	for i, id := range idCol.Data() {
+		var meta map[string]interface{}
+		if err := json.Unmarshal(metaCol.Data()[i], &meta); err != nil {
+			continue
+		}
+		content, err := decodeCompressed(contentCol.Data()[i])
+		if err != nil {
+			return nil, fmt.Errorf("分块 %s: %w", id, err)
+		}
+		chunks = append(chunks, SearchResult{ID: id, DocID: docIDCol.Data()[i], Title: titleCol.Data()[i], Content: content, Meta: meta})
+	}
+	return groupExpired(chunks, now), nil
+}
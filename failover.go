@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 主备切换配置
+type FailoverConfig struct {
+	ReplicaHost      string
+	ReplicaPort      int
+	FailureThreshold int           // 主节点连续失败多少次后切换到备节点
+	ProbeInterval    time.Duration // 切换后探测主节点健康的间隔
+}
+
+// 读请求主备切换：主节点持续失败时切到备节点，探测到主节点恢复后切回
+type failover struct {
+	config    FailoverConfig
+	probe     func(ctx context.Context) error // 主节点健康探测
+	mu        sync.Mutex
+	failures  int
+	onReplica bool
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// 加载主备切换配置，前缀区分存储（MILVUS、ELASTIC）
+func loadFailoverConfig(prefix string, defaultPort int) FailoverConfig {
+	return FailoverConfig{
+		ReplicaHost:      getEnv(prefix+"_REPLICA_HOST", ""),
+		ReplicaPort:      getEnvAsInt(prefix+"_REPLICA_PORT", defaultPort),
+		FailureThreshold: getEnvAsInt("FAILOVER_THRESHOLD", 3),
+		ProbeInterval:    time.Duration(getEnvAsInt("FAILOVER_PROBE_SECONDS", 10)) * time.Second,
+	}
+}
+
+// 配置了备节点时探测间隔必须大于0，切换后靠探测切回主节点
+func (c FailoverConfig) validate() error {
+	if c.ReplicaHost != "" && c.ProbeInterval <= 0 {
+		return fmt.Errorf("FAILOVER_PROBE_SECONDS必须大于0")
+	}
+	return nil
+}
+
+// 创建主备切换器并启动后台健康探测
+func newFailover(config FailoverConfig, probe func(ctx context.Context) error) *failover {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	f := &failover{
+		config: config,
+		probe:  probe,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go f.probeLoop()
+	return f
+}
+
+// 当前读请求是否应该走备节点
+func (f *failover) useReplica() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.onReplica
+}
+
+// 记录一次主节点读请求的结果，客户端取消的请求不能说明节点状态，不计入
+func (f *failover) record(err error) {
+	if f == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		f.failures = 0
+		return
+	}
+
+	f.failures++
+	if !f.onReplica && f.failures >= f.config.FailureThreshold {
+		f.onReplica = true
+		f.alert("主节点连续失败 %d 次，读请求切换到备节点 %s:%d，最后错误: %v",
+			f.failures, f.config.ReplicaHost, f.config.ReplicaPort, err)
+	}
+}
+
+// 定期探测主节点，恢复后切回
+func (f *failover) probeLoop() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.config.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			if !f.useReplica() {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), f.config.ProbeInterval)
+			err := f.probe(ctx)
+			cancel()
+			if err != nil {
+				continue
+			}
+
+			f.mu.Lock()
+			f.onReplica = false
+			f.failures = 0
+			f.mu.Unlock()
+			f.alert("主节点健康探测恢复，读请求切回主节点")
+		}
+	}
+}
+
+// 发出告警
+func (f *failover) alert(format string, args ...interface{}) {
+	log.Printf("🚨 [failover] "+format, args...)
+}
+
+// 停止健康探测
+func (f *failover) Close() {
+	if f == nil {
+		return
+	}
+	close(f.stop)
+	<-f.done
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 故障注入目标
+const (
+	faultTargetStore = "store"
+	faultTargetLLM   = "llm"
+)
+
+// 注入的故障错误，便于调用方用errors.Is识别
+var ErrInjectedFault = errors.New("故障注入")
+
+// 故障注入配置（仅开发环境生效）
+type FaultConfig struct {
+	Enabled   bool
+	ErrorRate float64 // 注入错误的概率 0-1
+	DelayRate float64 // 注入延迟的概率 0-1
+	Delay     time.Duration
+	Seed      int64    // 随机种子，固定种子可复现同样的故障序列
+	Targets   []string // 注入目标：store、llm
+}
+
+// 故障注入器，随机延迟或失败存储与LLM调用，用于验证重试、降级和超时逻辑
+type faultInjector struct {
+	config FaultConfig
+	mu     sync.Mutex
+	rng    *rand.Rand
+}
+
+// 加载故障注入配置，只有APP_ENV=dev时才允许开启
+func loadFaultConfig() FaultConfig {
+	config := FaultConfig{
+		Enabled:   getEnv("APP_ENV", "prod") == "dev" && getEnvAsBool("FAULT_INJECTION", false),
+		ErrorRate: getEnvAsFloat("FAULT_ERROR_RATE", 0.1),
+		DelayRate: getEnvAsFloat("FAULT_DELAY_RATE", 0.1),
+		Delay:     time.Duration(getEnvAsInt("FAULT_DELAY_MS", 2000)) * time.Millisecond,
+		Seed:      int64(getEnvAsInt("FAULT_SEED", 42)),
+	}
+	for _, target := range strings.Split(getEnv("FAULT_TARGETS", "store,llm"), ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			config.Targets = append(config.Targets, target)
+		}
+	}
+	return config
+}
+
+// 创建故障注入器，未开启时返回nil
+func newFaultInjector(config FaultConfig) *faultInjector {
+	if !config.Enabled {
+		return nil
+	}
+	fmt.Printf("⚠️  故障注入已开启: 错误率=%.2f, 延迟率=%.2f, 延迟=%s, 目标=%v\n",
+		config.ErrorRate, config.DelayRate, config.Delay, config.Targets)
+	return &faultInjector{
+		config: config,
+		rng:    rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+// 在调用前注入故障：可能延迟，也可能直接返回错误
+func (f *faultInjector) inject(ctx context.Context, target, op string) error {
+	if f == nil || !f.hasTarget(target) {
+		return nil
+	}
+
+	// 串行取随机数，保证同一种子下故障序列可复现
+	f.mu.Lock()
+	delay := f.rng.Float64() < f.config.DelayRate
+	fail := f.rng.Float64() < f.config.ErrorRate
+	f.mu.Unlock()
+
+	if delay {
+		fmt.Printf("⚠️  故障注入: %s 延迟 %s\n", op, f.config.Delay)
+		select {
+		case <-time.After(f.config.Delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if fail {
+		fmt.Printf("⚠️  故障注入: %s 调用失败\n", op)
+		return fmt.Errorf("%w: %s", ErrInjectedFault, op)
+	}
+	return nil
+}
+
+func (f *faultInjector) hasTarget(target string) bool {
+	for _, t := range f.config.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
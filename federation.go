@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 联合检索的一个索引（ES索引或Milvus集合）及其权重
+type FederatedIndex struct {
+	Name   string
+	Weight float64
+}
+
+// 多索引联合检索：FEDERATED_INDICES=rag_documents=1.0,personal_notes=0.8，未配置时只检索默认索引
+func loadFederationConfig() []FederatedIndex {
+	var indices []FederatedIndex
+	for _, item := range splitEnvList("FEDERATED_INDICES") {
+		name, value, ok := strings.Cut(item, "=")
+		weight := 1.0
+		if ok {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		indices = append(indices, FederatedIndex{Name: strings.TrimSpace(name), Weight: weight})
+	}
+	return indices
+}
+
+// 并发检索所有索引，分数乘以索引权重后合并取前topK；部分索引失败时使用其余索引的结果
+func (r *RAGSystem) federatedSearch(ctx context.Context, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	var mu sync.Mutex
+	var merged []SearchResult
+	var failures []string
+
+	var wg sync.WaitGroup
+	for _, index := range r.config.Federation {
+		wg.Add(1)
+		go func(index FederatedIndex) {
+			defer wg.Done()
+			results, err := r.searchIndex(ctx, index.Name, query, topK, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", index.Name, err))
+				return
+			}
+			for _, result := range results {
+				result.Score *= index.Weight
+				result.Index = index.Name
+				merged = append(merged, result)
+			}
+		}(index)
+	}
+	wg.Wait()
+
+	if len(failures) == len(r.config.Federation) {
+		return nil, fmt.Errorf("%w: 联合检索失败: %s", ErrStoreUnavailable, strings.Join(failures, "; "))
+	}
+	for _, failure := range failures {
+		fmt.Printf("⚠️  联合检索跳过索引 %s\n", failure)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+	if len(merged) > topK {
+		merged = merged[:topK]
+	}
+	return merged, nil
+}
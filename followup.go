@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 最多生成的追问建议数
+const maxFollowUps = 3
+
+// 基于检索到的文档生成2-3个可继续追问的问题
+func (r *RAGSystem) SuggestFollowUps(ctx context.Context, question, answer string, results []SearchResult) ([]string, error) {
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	var contextBuilder strings.Builder
+	for i, result := range results {
+		contextBuilder.WriteString(fmt.Sprintf("文档%d: %s\n内容: %s\n\n", i+1, result.Title, result.Content))
+	}
+
+	if err := r.faults.inject(ctx, faultTargetLLM, "DeepSeek追问建议"); err != nil {
+		return nil, err
+	}
+	resp, err := r.openAIClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: r.config.DeepSeekModel,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "你负责为知识库问答生成追问建议。只能提出文档中确实有答案的问题，不要重复用户已经问过的问题。只输出JSON字符串数组，例如[\"问题1\",\"问题2\"]。",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("文档：\n%s\n用户问题：%s\n回答：%s\n\n请给出2到3个追问建议：", contextBuilder.String(), question, answer),
+			},
+		},
+		Temperature: 0.3,
+		MaxTokens:   200,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("未收到追问建议")
+	}
+	return parseFollowUps(resp.Choices[0].Message.Content), nil
+}
+
+// 解析模型输出的追问列表，兼容代码块包裹和逐行列出两种格式
+func parseFollowUps(content string) []string {
+	content = trimCodeFence(content)
+
+	var questions []string
+	if err := json.Unmarshal([]byte(content), &questions); err != nil {
+		questions = nil
+		for _, line := range strings.Split(content, "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(line, "-*0123456789.、) "))
+			if line != "" {
+				questions = append(questions, line)
+			}
+		}
+	}
+
+	var cleaned []string
+	for _, q := range questions {
+		if q = strings.TrimSpace(q); q != "" {
+			cleaned = append(cleaned, q)
+		}
+		if len(cleaned) == maxFollowUps {
+			break
+		}
+	}
+	return cleaned
+}
+
+// 去掉模型输出外层的```json代码块标记
+func trimCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 一组相近的知识缺口问题
+type gapCluster struct {
+	Records    []queryRecord
+	Topic      string // 主题概括，未开启-summarize时为代表问题
+	Suggestion string // 建议补充的内容，未开启-summarize时为空
+	centroid   []float32
+}
+
+// 去重后的问题，按提问次数降序
+func (c *gapCluster) questions() []string {
+	counts := make(map[string]int)
+	var questions []string
+	for _, record := range c.Records {
+		key := normalizeQuestion(record.Question)
+		if counts[key] == 0 {
+			questions = append(questions, record.Question)
+		}
+		counts[key]++
+	}
+	sort.SliceStable(questions, func(i, j int) bool {
+		return counts[normalizeQuestion(questions[i])] > counts[normalizeQuestion(questions[j])]
+	})
+	return questions
+}
+
+func (c *gapCluster) negatives() int {
+	n := 0
+	for _, record := range c.Records {
+		if record.Feedback < 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *gapCluster) averageScore() float64 {
+	var sum float64
+	for _, record := range c.Records {
+		sum += record.BestScore
+	}
+	return sum / float64(len(c.Records))
+}
+
+// 出现次数最多的前n个值
+func topValues(values []string, n int) []string {
+	counts := make(map[string]int)
+	var distinct []string
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if counts[value] == 0 {
+			distinct = append(distinct, value)
+		}
+		counts[value]++
+	}
+	sort.SliceStable(distinct, func(i, j int) bool { return counts[distinct[i]] > counts[distinct[j]] })
+	if len(distinct) > n {
+		distinct = distinct[:n]
+	}
+	return distinct
+}
+
+// gaps命令：从查询日志中找出检索分数低或收到负面反馈的问题，按问题向量聚类，生成知识库缺失内容的报告
+func runGaps(args []string) error {
+	fs := flag.NewFlagSet("gaps", flag.ExitOnError)
+	days := fs.Int("days", 30, "分析最近多少天的查询日志")
+	minScore := fs.Float64("min-score", 0.5, "最相关分块的分数低于该值视为知识库没有覆盖")
+	similarity := fs.Float64("similarity", 0.8, "问题向量余弦相似度不低于该值时归为同一主题")
+	minSize := fs.Int("min-size", 2, "提问次数少于该值的主题不列入报告")
+	limit := fs.Int("limit", 20, "报告最多列出的主题数")
+	summarize := fs.Bool("summarize", false, "调用大模型概括每个主题并给出补充建议")
+	out := fs.String("out", "knowledge_gaps.md", "Markdown报告")
+	_ = fs.Parse(args)
+
+	config := loadConfig()
+	if config.QueryLog.DB == "" {
+		return fmt.Errorf("需要配置查询日志QUERY_LOG_DB")
+	}
+	rag, err := NewRAGSystem(config)
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+	queries, err := openQueryLog(config.QueryLog, rag.encryption)
+	if err != nil {
+		return err
+	}
+	defer queries.Close()
+
+	until := time.Now()
+	since := until.AddDate(0, 0, -*days)
+	records, err := queries.Gaps(since, *minScore)
+	if err != nil {
+		return err
+	}
+	// 知识库不可用时降级回答的问题分数也为0，但不是内容缺失，除非用户给了负面反馈
+	kept := records[:0]
+	for _, record := range records {
+		if record.Feedback < 0 || !containsString(record.Degraded, tierLLMOnly) {
+			kept = append(kept, record)
+		}
+	}
+	records = kept
+	if len(records) == 0 {
+		fmt.Printf("✅ 最近 %d 天没有低分或负面反馈的问答\n", *days)
+		return nil
+	}
+
+	ctx := context.Background()
+	fmt.Printf("🔍 对 %d 条低分或负面反馈的问答聚类...\n", len(records))
+	clusters, err := rag.clusterGaps(ctx, records, *similarity)
+	if err != nil {
+		return err
+	}
+	var reported []*gapCluster
+	for _, cluster := range clusters {
+		if len(cluster.Records) >= *minSize {
+			reported = append(reported, cluster)
+		}
+	}
+	if len(reported) > *limit {
+		reported = reported[:*limit]
+	}
+
+	for i, cluster := range reported {
+		cluster.Topic = cluster.questions()[0]
+		if *summarize {
+			if err := rag.summarizeGap(ctx, cluster); err != nil {
+				fmt.Printf("⚠️  概括主题失败: %v\n", err)
+			}
+		}
+		fmt.Printf("  %d. %s（%d 次，负面反馈 %d，平均最高分 %.2f）\n", i+1, cluster.Topic, len(cluster.Records), cluster.negatives(), cluster.averageScore())
+	}
+
+	report := formatGapReport(reported, len(records), len(clusters), since, until, *minScore)
+	if err := os.WriteFile(*out, []byte(report), 0o644); err != nil {
+		return fmt.Errorf("保存知识缺口报告失败: %w", err)
+	}
+	fmt.Printf("📄 知识缺口报告: %s\n", *out)
+	return nil
+}
+
+// 按问题向量贪心聚类：每个问题归入质心最相近且相似度不低于阈值的主题，否则新建主题；结果按提问次数和负面反馈数降序
+func (r *RAGSystem) clusterGaps(ctx context.Context, records []queryRecord, similarity float64) ([]*gapCluster, error) {
+	vectors := make(map[string][]float32)
+	var clusters []*gapCluster
+	for _, record := range records {
+		key := normalizeQuestion(record.Question)
+		vector, ok := vectors[key]
+		if !ok {
+			var err error
+			if vector, err = r.embedder.Embed(ctx, key); err != nil {
+				return nil, fmt.Errorf("问题向量化失败: %w", err)
+			}
+			vectors[key] = vector
+		}
+
+		var best *gapCluster
+		bestSimilarity := similarity
+		for _, cluster := range clusters {
+			if s := cosineSimilarity(vector, cluster.centroid); s >= bestSimilarity {
+				best, bestSimilarity = cluster, s
+			}
+		}
+		if best == nil {
+			clusters = append(clusters, &gapCluster{Records: []queryRecord{record}, centroid: append([]float32(nil), vector...)})
+			continue
+		}
+		// 质心取成员向量的平均值
+		n := float32(len(best.Records))
+		for i := range best.centroid {
+			best.centroid[i] = (best.centroid[i]*n + vector[i]) / (n + 1)
+		}
+		best.Records = append(best.Records, record)
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if len(clusters[i].Records) != len(clusters[j].Records) {
+			return len(clusters[i].Records) > len(clusters[j].Records)
+		}
+		return clusters[i].negatives() > clusters[j].negatives()
+	})
+	return clusters, nil
+}
+
+// 调用大模型概括主题，并建议知识库需要补充的内容
+func (r *RAGSystem) summarizeGap(ctx context.Context, cluster *gapCluster) error {
+	questions := cluster.questions()
+	if len(questions) > 10 {
+		questions = questions[:10]
+	}
+	var comments []string
+	for _, record := range cluster.Records {
+		if record.Comment != "" {
+			comments = append(comments, record.Comment)
+		}
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("以下是用户提出、但知识库没有很好回答的一组相近问题：\n")
+	for _, question := range questions {
+		prompt.WriteString("- " + question + "\n")
+	}
+	if len(comments) > 0 {
+		prompt.WriteString("\n用户反馈：\n")
+		for _, comment := range topValues(comments, 5) {
+			prompt.WriteString("- " + comment + "\n")
+		}
+	}
+	prompt.WriteString("\n请按以下格式输出两行，不要输出其他内容：\n主题：用一句话概括这些问题的共同主题\n补充：知识库需要补充的文档内容\n")
+
+	answer, err := r.completeAnswer(ctx, openai.ChatCompletionRequest{
+		Model:       r.config.DeepSeekModel,
+		Messages:    []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: prompt.String()}},
+		Temperature: 0.1,
+	})
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(answer, "\n") {
+		line = strings.TrimSpace(line)
+		if topic, ok := cutLabel(line, "主题"); ok && topic != "" {
+			cluster.Topic = topic
+		} else if suggestion, ok := cutLabel(line, "补充"); ok {
+			cluster.Suggestion = suggestion
+		}
+	}
+	return nil
+}
+
+// 去掉"主题："这类前缀，中英文冒号都可以
+func cutLabel(line, label string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, label)
+	if !ok {
+		return "", false
+	}
+	for _, colon := range []string{"：", ":"} {
+		if value, ok := strings.CutPrefix(rest, colon); ok {
+			return strings.TrimSpace(value), true
+		}
+	}
+	return "", false
+}
+
+// 生成Markdown知识缺口报告
+func formatGapReport(clusters []*gapCluster, records, total int, since, until time.Time, minScore float64) string {
+	var b strings.Builder
+	b.WriteString("# 知识缺口报告\n\n")
+	fmt.Fprintf(&b, "统计区间：%s ~ %s，最相关分块分数低于 %.2f 或收到负面反馈的问答 %d 条，归为 %d 个主题，以下列出 %d 个。\n",
+		since.Format("2006-01-02"), until.Format("2006-01-02"), minScore, records, total, len(clusters))
+
+	for i, cluster := range clusters {
+		fmt.Fprintf(&b, "\n## %d. %s\n\n", i+1, cluster.Topic)
+		questions := cluster.questions()
+		fmt.Fprintf(&b, "- 提问次数：%d（不同问题 %d 个），负面反馈：%d，平均最高分：%.2f\n",
+			len(cluster.Records), len(questions), cluster.negatives(), cluster.averageScore())
+
+		var categories, docIDs, comments []string
+		for _, record := range cluster.Records {
+			categories = append(categories, record.Category)
+			docIDs = append(docIDs, record.DocIDs...)
+			if record.Comment != "" {
+				comments = append(comments, record.Comment)
+			}
+		}
+		if top := topValues(categories, 3); len(top) > 0 {
+			fmt.Fprintf(&b, "- 分类：%s\n", strings.Join(top, "、"))
+		}
+		if top := topValues(docIDs, 5); len(top) > 0 {
+			fmt.Fprintf(&b, "- 检索到但不足以回答的文档：%s\n", strings.Join(top, "、"))
+		}
+		if cluster.Suggestion != "" {
+			fmt.Fprintf(&b, "- 建议补充：%s\n", cluster.Suggestion)
+		}
+
+		b.WriteString("\n典型问题：\n\n")
+		if len(questions) > 5 {
+			questions = questions[:5]
+		}
+		for _, question := range questions {
+			b.WriteString("- " + question + "\n")
+		}
+		if top := topValues(comments, 3); len(top) > 0 {
+			b.WriteString("\n用户反馈：\n\n")
+			for _, comment := range top {
+				b.WriteString("- " + comment + "\n")
+			}
+		}
+	}
+	return b.String()
+}
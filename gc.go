@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 孤儿分块：所属文档已不存在或源文件已消失
+type orphanChunk struct {
+	ID     string `json:"id"`
+	DocID  string `json:"doc_id"`
+	Reason string `json:"reason"`
+}
+
+// gc命令：清理孤儿分块
+func runGC(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "只列出孤儿分块，不删除")
+	_ = fs.Parse(args)
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	orphans, err := rag.FindOrphanChunks()
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		fmt.Println("✅ 未发现孤儿分块")
+		return nil
+	}
+
+	fmt.Printf("🔍 发现 %d 个孤儿分块:\n", len(orphans))
+	for _, orphan := range orphans {
+		fmt.Printf("  - %s (文档: %s, 原因: %s)\n", orphan.ID, orphan.DocID, orphan.Reason)
+	}
+
+	if *dryRun {
+		fmt.Println("💡 dry-run模式，未删除任何分块")
+		return nil
+	}
+
+	ids := make([]string, 0, len(orphans))
+	for _, orphan := range orphans {
+		ids = append(ids, orphan.ID)
+	}
+	if err := rag.DeleteChunks(ids); err != nil {
+		return err
+	}
+	fmt.Printf("🗑️  已删除 %d 个孤儿分块\n", len(ids))
+	return nil
+}
+
+// 查找所属文档已不存在或源文件已消失的分块
+func (r *RAGSystem) FindOrphanChunks() ([]orphanChunk, error) {
+	ctx := context.Background()
+	collectionName := r.config.CollectionName
+
+	documents, err := r.sourceDocuments()
+	if err != nil {
+		return nil, fmt.Errorf("加载源文档失败: %w", err)
+	}
+	known := make(map[string]bool, len(documents))
+	for _, doc := range documents {
+		known[doc.ID] = true
+	}
+
+	// sitemap增量同步写入的页面
+	state, err := loadSitemapState(sitemapStatePath())
+	if err != nil {
+		return nil, fmt.Errorf("加载sitemap同步状态失败: %w", err)
+	}
+	for _, docID := range state.Pages {
+		known[docID] = true
+	}
+
+	// S3增量同步写入的对象
+	s3State, err := loadS3SyncState(s3SyncStatePath())
+	if err != nil {
+		return nil, fmt.Errorf("加载S3同步状态失败: %w", err)
+	}
+	for _, object := range s3State.Objects {
+		known[object.DocID] = true
+	}
+
+	// 数据表同步写入的行
+	sqlState, err := loadSQLSyncState(sqlSyncStatePath())
+	if err != nil {
+		return nil, fmt.Errorf("加载SQL同步状态失败: %w", err)
+	}
+	for _, docID := range sqlState.Rows {
+		known[docID] = true
+	}
+
+	// 邮箱同步写入的邮件
+	mailState, err := loadIMAPState(imapStatePath())
+	if err != nil {
+		return nil, fmt.Errorf("加载邮箱同步状态失败: %w", err)
+	}
+	for _, docID := range mailState.Documents {
+		known[docID] = true
+	}
+
+	if err := r.milvusClient.LoadCollection(ctx, collectionName, false); err != nil {
+		return nil, fmt.Errorf("加载集合失败: %w", err)
+	}
+
+	resultSet, err := r.milvusClient.Query(ctx, collectionName, nil, `id != ""`, []string{"id", "doc_id", "source_path"})
+	if err != nil {
+		return nil, fmt.Errorf("查询分块失败: %w", err)
+	}
+
+	idCol, ok := resultSet.GetColumn("id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("ID列类型错误")
+	}
+	docIDCol, ok := resultSet.GetColumn("doc_id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("doc_id列类型错误")
+	}
+	sourcePathCol, ok := resultSet.GetColumn("source_path").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("source_path列类型错误")
+	}
+
+	var orphans []orphanChunk
+	for i, id := range idCol.Data() {
+		docID := docIDCol.Data()[i]
+		if !known[docID] {
+			orphans = append(orphans, orphanChunk{ID: id, DocID: docID, Reason: "源文档不存在"})
+			continue
+		}
+		if path := sourcePathCol.Data()[i]; path != "" {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				orphans = append(orphans, orphanChunk{ID: id, DocID: docID, Reason: "源文件已消失: " + path})
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// 按分块ID删除
+func (r *RAGSystem) DeleteChunks(ids []string) error {
+	defer r.retrievals.Clear()
+	quoted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		quoted = append(quoted, fmt.Sprintf("%q", id))
+	}
+	expr := fmt.Sprintf("id in [%s]", strings.Join(quoted, ", "))
+
+	if err := r.milvusClient.Delete(context.Background(), r.config.CollectionName, "", expr); err != nil {
+		return fmt.Errorf("删除分块失败: %w", err)
+	}
+	return nil
+}
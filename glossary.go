@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 术语表条目
+type GlossaryEntry struct {
+	Term       string   `json:"term"`
+	Definition string   `json:"definition"`
+	Aliases    []string `json:"aliases,omitempty"`
+}
+
+// 领域术语表
+type glossary struct {
+	entries []GlossaryEntry
+}
+
+// 从JSON文件加载术语表，文件不存在时返回空术语表
+func loadGlossary(path string) (*glossary, error) {
+	g := &glossary{}
+	if path == "" {
+		return g, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &g.entries); err != nil {
+		return nil, fmt.Errorf("解析术语表 %s 失败: %w", path, err)
+	}
+	return g, nil
+}
+
+// 找出文本中出现的术语，按首次出现的位置排序，忽略大小写
+func (g *glossary) Match(texts ...string) []GlossaryEntry {
+	if g == nil || len(g.entries) == 0 {
+		return nil
+	}
+	text := strings.ToLower(strings.Join(texts, "\n"))
+
+	type hit struct {
+		entry    GlossaryEntry
+		position int
+	}
+	var hits []hit
+	for _, entry := range g.entries {
+		position := -1
+		for _, name := range append([]string{entry.Term}, entry.Aliases...) {
+			if name == "" {
+				continue
+			}
+			if i := strings.Index(text, strings.ToLower(name)); i >= 0 && (position < 0 || i < position) {
+				position = i
+			}
+		}
+		if position >= 0 {
+			hits = append(hits, hit{entry: entry, position: position})
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].position < hits[j].position })
+	matched := make([]GlossaryEntry, 0, len(hits))
+	for _, h := range hits {
+		matched = append(matched, h.entry)
+	}
+	return matched
+}
+
+// 将术语格式化为简短的释义列表
+func formatGlossary(entries []GlossaryEntry) string {
+	var builder strings.Builder
+	for _, entry := range entries {
+		builder.WriteString(fmt.Sprintf("- %s：%s\n", entry.Term, entry.Definition))
+	}
+	return builder.String()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 混合检索配置：开启后集合增加词频稀疏向量字段sparse，检索时稠密向量和稀疏向量各召回一路，
+// 由Milvus按RRF或加权融合。需要Milvus 2.4及以上，开启或关闭后需重新初始化集合
+type HybridConfig struct {
+	Enabled     bool
+	Ranker      string  // rrf、weighted
+	RRFK        float64 // RRF的平滑参数k
+	DenseWeight float64 // weighted融合时稠密向量一路的权重，稀疏一路为1-DenseWeight
+}
+
+func loadHybridConfig() HybridConfig {
+	return HybridConfig{
+		Enabled:     getEnvAsBool("HYBRID_SEARCH", false),
+		Ranker:      getEnv("HYBRID_RANKER", "rrf"),
+		RRFK:        getEnvAsFloat("HYBRID_RRF_K", 60),
+		DenseWeight: getEnvAsFloat("HYBRID_DENSE_WEIGHT", 0.5),
+	}
+}
+
+// 没有配置rrf开关时，开启混合检索即按RRF融合
+func (r *RAGSystem) featureDefault(name string) bool {
+	return name == featureRRF && r.config.Hybrid.Enabled
+}
+
+// 融合策略
+func (h HybridConfig) reranker() client.Reranker {
+	if h.Ranker == "weighted" {
+		return client.NewWeightedReranker([]float64{h.DenseWeight, 1 - h.DenseWeight})
+	}
+	return client.NewRRFReranker().WithK(h.RRFK)
+}
+
+// 融合分数归一化到0-1：RRF分数为各路1/(k+名次)之和，两路都排第一时最大；
+// weighted融合前Milvus已把各路分数归一化到0-1，权重之和为1
+func (h HybridConfig) normalize(score float32) float64 {
+	if h.Ranker == "weighted" {
+		return float64(score)
+	}
+	return min(float64(score)*(h.RRFK+1)/2, 1)
+}
+
+// 词频权重的参数，沿用BM25的词频饱和k1和长度归一化b
+const (
+	termFreqK1 = 1.2
+	termFreqB  = 0.75
+)
+
+// 没有词项的分块使用的占位维度，Milvus不接受空的稀疏向量；词项不会哈希到该维度
+const emptySparsePosition = 0
+
+// 分块的词频稀疏向量：按BM25的方式对词频做饱和和长度归一化，但不含IDF，常见词和罕见词权重相同，不是完整的BM25。
+// Milvus 2.4没有内置BM25函数，也没有语料的文档频率，由客户端计算；查询侧每个词权重为1，内积即为各命中词的词频权重之和；
+// 平均长度取配置的分块大小
+func termFreqDocVector(text string, avgLen float64) entity.SparseEmbedding {
+	terms := lexicalTerms(text)
+	if len(terms) == 0 {
+		vector, _ := entity.NewSliceSparseEmbedding([]uint32{emptySparsePosition}, []float32{1e-6})
+		return vector
+	}
+	counts := make(map[uint32]float64)
+	for _, term := range terms {
+		counts[termPosition(term)]++
+	}
+	norm := termFreqK1 * (1 - termFreqB + termFreqB*float64(len(terms))/max(avgLen, 1))
+	positions := make([]uint32, 0, len(counts))
+	values := make([]float32, 0, len(counts))
+	for position, tf := range counts {
+		positions = append(positions, position)
+		values = append(values, float32(tf*(termFreqK1+1)/(tf+norm)))
+	}
+	vector, _ := entity.NewSliceSparseEmbedding(positions, values)
+	return vector
+}
+
+// 问题的稀疏向量，没有词项时返回nil
+func termFreqQueryVector(text string) entity.SparseEmbedding {
+	seen := make(map[uint32]bool)
+	var positions []uint32
+	var values []float32
+	for _, term := range lexicalTerms(text) {
+		position := termPosition(term)
+		if seen[position] {
+			continue
+		}
+		seen[position] = true
+		positions = append(positions, position)
+		values = append(values, 1)
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+	vector, _ := entity.NewSliceSparseEmbedding(positions, values)
+	return vector
+}
+
+// 切分词项：英文和数字按词，小写；连续汉字按相邻两字切分，单个汉字单独成词
+func lexicalTerms(text string) []string {
+	var terms []string
+	var word strings.Builder
+	var han []rune
+	flushWord := func() {
+		if word.Len() > 0 {
+			terms = append(terms, strings.ToLower(word.String()))
+			word.Reset()
+		}
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			terms = append(terms, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			terms = append(terms, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word.WriteRune(r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return terms
+}
+
+// 词项哈希到稀疏向量的维度，Milvus要求维度小于2^32-1
+func termPosition(term string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(term))
+	if position := h.Sum32() % math.MaxUint32; position != emptySparsePosition {
+		return position
+	}
+	return emptySparsePosition + 1
+}
+
+// 稠密向量（field字段）和词频稀疏向量两路召回后融合
+func (r *RAGSystem) hybridSearch(ctx context.Context, milvusClient client.Client, collectionName, field string, dense []float32, sparse entity.SparseEmbedding, expr string, topK int, sp entity.SearchParam) ([]client.SearchResult, error) {
+	sparseParam, _ := entity.NewIndexSparseInvertedSearchParam(0)
+	requests := []*client.ANNSearchRequest{
+		client.NewANNSearchRequest(field, entity.L2, expr, []entity.Vector{entity.FloatVector(dense)}, sp, topK),
+		client.NewANNSearchRequest("sparse", entity.IP, expr, []entity.Vector{sparse}, sparseParam, topK),
+	}
+	return milvusClient.HybridSearch(ctx, collectionName, nil, topK, []string{"doc_id", "title", "content", "meta"}, r.config.Hybrid.reranker(), requests)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 少于该字符数的分块视为近空分块
+const nearEmptyChunkRunes = 10
+
+// 常见的乱码特征（GBK/UTF-8互相误读产生）
+var mojibakeMarkers = []string{"锟斤拷", "烫烫烫", "屯屯屯", "Ã", "â€", "ï»¿"}
+
+// 单个文档的入库质量报告
+type DocumentReport struct {
+	DocID          string
+	Title          string
+	ChunkCount     int
+	AvgChunkTokens float64
+	EmptyChunks    int     // 空或近空分块数
+	EncodingIssues int     // 疑似编码问题数
+	OCRConfidence  float64 // OCR置信度，-1表示非OCR来源
+}
+
+// 是否存在需要人工检查的问题
+func (d DocumentReport) HasIssues() bool {
+	return d.EmptyChunks > 0 || d.EncodingIssues > 0 || (d.OCRConfidence >= 0 && d.OCRConfidence < 0.8)
+}
+
+// 根据文档和分块生成入库质量报告
+func buildIngestReport(documents []Document, chunks []Chunk, tokens tokenCounter) []DocumentReport {
+	chunksByDoc := make(map[string][]Chunk)
+	for _, chunk := range chunks {
+		chunksByDoc[chunk.DocID] = append(chunksByDoc[chunk.DocID], chunk)
+	}
+
+	reports := make([]DocumentReport, 0, len(documents))
+	for _, doc := range documents {
+		report := DocumentReport{
+			DocID:          doc.ID,
+			Title:          doc.Title,
+			EncodingIssues: countEncodingIssues(doc.Content),
+			OCRConfidence:  -1,
+		}
+		if confidence, ok := doc.Meta["ocr_confidence"].(float64); ok {
+			report.OCRConfidence = confidence
+		}
+
+		docChunks := chunksByDoc[doc.ID]
+		report.ChunkCount = len(docChunks)
+		totalTokens := 0
+		for _, chunk := range docChunks {
+			totalTokens += tokens.Count(chunk.Content)
+			if utf8.RuneCountInString(strings.TrimSpace(chunk.Content)) < nearEmptyChunkRunes {
+				report.EmptyChunks++
+			}
+		}
+		if len(docChunks) > 0 {
+			report.AvgChunkTokens = float64(totalTokens) / float64(len(docChunks))
+		}
+
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// 统计文本中的疑似编码问题：非法UTF-8、替换字符和常见乱码
+func countEncodingIssues(text string) int {
+	issues := 0
+	if !utf8.ValidString(text) {
+		issues++
+	}
+	issues += strings.Count(text, "\uFFFD")
+	for _, marker := range mojibakeMarkers {
+		issues += strings.Count(text, marker)
+	}
+	return issues
+}
+
+// 输出入库质量报告
+func printIngestReport(reports []DocumentReport) {
+	fmt.Println("\n📋 入库质量报告:")
+	fmt.Printf("  %-12s %-6s %-10s %-8s %-8s %-8s %s\n", "文档ID", "分块数", "平均tokens", "空分块", "编码问题", "OCR置信度", "标题")
+	for _, report := range reports {
+		ocr := "-"
+		if report.OCRConfidence >= 0 {
+			ocr = fmt.Sprintf("%.2f", report.OCRConfidence)
+		}
+		flag := "  "
+		if report.HasIssues() {
+			flag = "⚠️"
+		}
+		fmt.Printf("%s%-12s %-6d %-10.1f %-8d %-8d %-8s %s\n", flag, report.DocID, report.ChunkCount,
+			report.AvgChunkTokens, report.EmptyChunks, report.EncodingIssues, ocr, report.Title)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+const benchBackend = "Milvus"
+
+func (r *RAGSystem) benchTargetName() string {
+	return r.config.CollectionName + "_ingestbench"
+}
+
+// 按当前schema重建临时集合，只写入不检索，不建索引
+func (r *RAGSystem) createBenchTarget(ctx context.Context, name string) error {
+	if err := r.dropBenchTarget(ctx, name); err != nil {
+		return err
+	}
+	schema := r.collectionSchema()
+	schema.CollectionName = name
+	if err := r.milvusClient.CreateCollection(ctx, schema, 2); err != nil {
+		return fmt.Errorf("创建临时集合失败: %w", err)
+	}
+	return nil
+}
+
+func (r *RAGSystem) dropBenchTarget(ctx context.Context, name string) error {
+	exists, err := r.milvusClient.HasCollection(ctx, name)
+	if err != nil || !exists {
+		return err
+	}
+	if err := r.milvusClient.DropCollection(ctx, name); err != nil {
+		return fmt.Errorf("删除临时集合失败: %w", err)
+	}
+	return nil
+}
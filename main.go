@@ -2,25 +2,94 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 	"github.com/sashabaranov/go-openai"
+	"golang.org/x/sync/errgroup"
 )
 
 // 配置结构体
 type Config struct {
-	MilvusHost     string
-	MilvusPort     int
-	DeepSeekAPIKey string
-	DeepSeekModel  string
-	CollectionName string
+	MilvusHost         string
+	MilvusPort         int
+	DeepSeekAPIKey     string
+	DeepSeekModel      string
+	Models             []chatModel // 请求可以指定的模型
+	SystemPrompt       string      // RAG系统提示词，发布profile可覆盖
+	CollectionName     string
+	ChunkSize          int
+	IndexBatch         int  // 每批写入的分块数
+	IndexWorkers       int  // 同时写入的批次数
+	Profiling          bool // 输出入库分阶段的耗时和内存分配，serve挂载pprof接口
+	Trace              TraceConfig
+	Classify           ClassifyConfig
+	Entity             EntityConfig
+	Compression        bool // 分块正文以zstd压缩存储，检索时透明解压
+	Retrieval          RetrievalConfig
+	Tokenizer          TokenizerConfig
+	ChineseScript      string // 匹配前统一的中文字形：simplified、traditional、none
+	CrossLingual       CrossLingualConfig
+	Hybrid             HybridConfig
+	Trust              TrustConfig
+	License            LicenseConfig
+	Federation         []FederatedIndex
+	Peers              PeerConfig
+	Blob               BlobStoreConfig
+	DocLimits          DocumentLimits
+	Consistency        string // 写入接口默认的一致性：eventual、strong
+	DocsDir            string
+	BootstrapFile      string // bootstrap命令保存的数据集，存在时替换内置示例文档
+	Crawler            CrawlerConfig
+	FollowUps          bool // 回答后生成追问建议
+	GlossaryFile       string
+	PolicyFile         string // 低置信度和超出范围时的回答策略
+	LexiconFile        string // 屏蔽词和替换词表
+	OversizedLog       string // 超出上下文预算的分块记录，由rechunk命令汇总
+	Calculator         bool   // 需要数值计算的问题交给计算器工具
+	Continuations      int    // 回答因长度上限被截断时最多自动续写的次数
+	Extractive         int    // 抽取式回答选取的句子数
+	Coalescing         bool   // 合并同一问题的并发回答请求
+	Reasoning          ReasoningConfig
+	Degrade            DegradeConfig
+	Summarize          SummarizeConfig
+	Provenance         ProvenanceConfig
+	Features           FeatureConfig
+	Rules              RulesConfig
+	Overrides          OverrideConfig
+	Maintenance        MaintenanceConfig
+	NamespaceEmbedding NamespaceEmbeddingConfig
+	LanguageFilter     LanguageFilterConfig
+	AnswerLength       AnswerLengthConfig
+	Dates              DateConfig
+	Embedding          EmbeddingConfig
+	AnswerCache        AnswerCacheConfig
+	RetrievalCache     RetrievalCacheConfig
+	DeepLink           DeepLinkConfig
+	Warm               WarmConfig
+	Pricing            PricingConfig
+	EvalSchedule       EvalScheduleConfig
+	Review             ReviewConfig
+	QueryLog           QueryLogConfig
+	Encryption         EncryptionConfig
+	SLO                SLOConfig
+	Session            SessionConfig
+	Expiry             ExpiryConfig
+	Reload             ReloadConfig
+	Idempotency        IdempotencyConfig
+	Admin              AdminConfig
+	Fault              FaultConfig
+	Failover           FailoverConfig
 }
 
 // 文档结构体
@@ -29,23 +98,70 @@ type Document struct {
 	Title   string
 	Content string
 	Vector  []float32
+	Meta    map[string]interface{}
 }
 
 // 搜索结果
 type SearchResult struct {
-	Title   string
-	Content string
-	Score   float32
+	ID       string                 `json:"id"`     // 分块ID
+	DocID    string                 `json:"doc_id"` // 所属文档ID
+	Title    string                 `json:"title"`
+	Content  string                 `json:"content"`
+	Score    float64                `json:"score"`
+	Trust    float64                `json:"trust"`               // 来源可信度
+	License  string                 `json:"license,omitempty"`   // 内容许可
+	Index    string                 `json:"index,omitempty"`     // 多索引联合检索时的来源索引
+	Link     string                 `json:"link,omitempty"`      // 查看原文的链接
+	DeepLink string                 `json:"deep_link,omitempty"` // 定位到原文具体位置的链接：标题锚点、网页文本片段或页面链接
+	Meta     map[string]interface{} `json:"meta,omitempty"`
 }
 
 // RAG系统
 type RAGSystem struct {
-	milvusClient client.Client
-	openAIClient *openai.Client
-	config       Config
+	milvusClient        client.Client
+	replicaClient       client.Client // 备节点，仅用于读请求
+	openAIClient        *openai.Client
+	localClients        map[string]*openai.Client // LLM_MODELS中的本地模型
+	config              Config
+	faults              *faultInjector
+	failover            *failover
+	tokens              tokenCounter     // 按对话模型的分词器估算token数
+	script              *scriptConverter // 繁简统一，CHINESE_SCRIPT=none时为nil
+	encryption          *fieldCipher     // 查询日志、审核库的静态加密，未配置ENCRYPTION_KEY时为nil
+	usage               *usageTracker
+	answers             *answerCache
+	retrievals          *retrievalCache              // 检索结果缓存，关闭时为nil
+	embedder            embedder                     // 问题向量化，用于答案缓存和抽取式回答
+	vectorEmbedder      embedder                     // 生成vector字段的向量，未配置EMBEDDING_VECTOR_DIM时为nil
+	translations        translationCache             // 跨语言检索的问题译文
+	flights             *answerFlights               // 进行中的回答，未开启请求合并时为nil
+	broadcasts          *answerBroadcasts            // 进行中的流式回答，未开启请求合并时为nil
+	trending            *questionTracker             // 问题频次，未开启热门问题预生成时为nil
+	blobs               blobStore                    // 原文存储，未配置时为nil
+	classifier          *classifier                  // 文档分类，未配置分类体系时为nil
+	peers               *peerInstances               // 负责其他分类的对等实例，未配置时为nil
+	entities            *entityExtractor             // 实体抽取，关闭时为nil
+	traces              *traceWriter                 // 检索轨迹，未配置TRACE_DIR时为nil
+	reviews             *reviewQueue                 // 人工审核队列和FAQ，serve开启REVIEW_THRESHOLD时设置
+	rules               *ruleStore                   // 检索规则，serve配置RULES_DB时设置
+	overrides           *overrideStore               // 固定回答，serve配置OVERRIDES_DB时设置
+	maintenance         maintenanceState             // 存储维护的运行状态
+	namespaceEmbeddings *namespaceEmbeddings         // 命名空间单独配置的向量模型，没有配置时为nil
+	sessions            *sessionStore                // 会话展示过的来源
+	oversized           *oversizedLog                // 超出上下文预算的分块
+	live                atomic.Pointer[liveSettings] // 可热更新的配置：提示词、检索参数、术语表和回答策略
+	revisionMu          sync.Mutex                   // 串行化修订号的读取和写入：带版本校验的更新和ReplaceDocuments
 }
 
 func main() {
+	// 维护类子命令，例如: go run . gc -dry-run
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("执行命令失败: %v", err)
+		}
+		return
+	}
+
 	fmt.Println("🚀 RAG简易Demo启动...")
 
 	// 加载配置
@@ -71,41 +187,80 @@ func main() {
 		"闫同学是谁？",
 		"介绍一下扯编程的淡公众号",
 	}
+	// bootstrap数据集替换了示例文档时，改用数据集的演示问题
+	if dataset, err := loadBootstrapDataset(config.BootstrapFile); err == nil && dataset != nil && len(dataset.Questions) > 0 {
+		testQuestions = dataset.Questions
+	}
 
 	// 运行对比测试
+	runComparison(rag, testQuestions)
+}
+
+// 逐个问题对比纯DeepSeek回答和RAG增强回答
+func runComparison(rag *RAGSystem, questions []string) {
 	fmt.Println("\n" + strings.Repeat("=", 50))
 	fmt.Println("🧪 开始对比测试")
 	fmt.Println(strings.Repeat("=", 50))
 
-	for i, question := range testQuestions {
-		fmt.Printf("\n📝 测试 %d/%d\n", i+1, len(testQuestions))
+	for i, question := range questions {
+		fmt.Printf("\n📝 测试 %d/%d\n", i+1, len(questions))
 		fmt.Printf("❓ 问题: %s\n", question)
 
-		// 获取直接答案
-		fmt.Println("\n🔍 获取纯DeepSeek回答：")
-		directAnswer, directTime, err := rag.GetDirectAnswer(question)
-		if err != nil {
-			fmt.Printf("❌ 获取直接答案失败: %v\n", err)
+		// 并行获取纯DeepSeek回答和RAG增强回答，输出顺序保持不变
+		var (
+			directAnswer, ragAnswer string
+			directTime, ragTime     float64
+			sources                 []SearchResult
+		)
+		start := time.Now()
+		var g errgroup.Group
+		g.Go(func() error {
+			var err error
+			directAnswer, directTime, err = rag.GetDirectAnswer(question)
+			if err != nil {
+				return fmt.Errorf("获取直接答案失败: %w", err)
+			}
+			return nil
+		})
+		g.Go(func() error {
+			var err error
+			ragAnswer, ragTime, sources, err = rag.GetRAGAnswer(context.Background(), question, searchOptions{})
+			if err != nil {
+				return fmt.Errorf("获取RAG答案失败: %w", err)
+			}
+			return nil
+		})
+		if err := g.Wait(); err != nil {
+			fmt.Printf("❌ %v\n", err)
 			continue
 		}
+		wallTime := time.Since(start).Seconds()
+
+		fmt.Println("\n🔍 纯DeepSeek回答：")
 		fmt.Printf("⏱️  响应时间: %.2f秒\n", directTime)
 		fmt.Printf("💬 回答: %s\n", directAnswer)
 
-		// 获取RAG答案
-		fmt.Println("\n🔍 获取RAG增强回答：")
-		ragAnswer, ragTime, sources, err := rag.GetRAGAnswer(question)
-		if err != nil {
-			fmt.Printf("❌ 获取RAG答案失败: %v\n", err)
-			continue
-		}
+		fmt.Println("\n🔍 RAG增强回答：")
 		fmt.Printf("⏱️  响应时间: %.2f秒\n", ragTime)
 		fmt.Printf("💬 回答: %s\n", ragAnswer)
 
+		// 问题和回答中出现的术语
+		if terms := rag.settings().glossary.Match(question, ragAnswer); len(terms) > 0 {
+			fmt.Println("\n📖 术语解释:")
+			fmt.Print(formatGlossary(terms))
+		}
+
 		// 显示检索到的文档
 		if len(sources) > 0 {
 			fmt.Println("\n📄 检索到的相关文档:")
 			for j, source := range sources {
-				fmt.Printf("  %d. [相似度: %.2f] %s\n", j+1, source.Score, source.Title)
+				fmt.Printf("  %d. [相似度: %.2f] %s（%s）\n", j+1, source.Score, source.Title, citationSource(source))
+				if source.Link != "" {
+					fmt.Printf("     原文: %s\n", source.Link)
+				}
+				if source.DeepLink != "" {
+					fmt.Printf("     位置: %s\n", source.DeepLink)
+				}
 				if j == 0 { // 只显示最相关文档的片段
 					content := source.Content
 					if len(content) > 100 {
@@ -116,16 +271,32 @@ func main() {
 			}
 		}
 
+		// 追问建议
+		if rag.config.FollowUps {
+			followUps, err := rag.SuggestFollowUps(context.Background(), question, ragAnswer, sources)
+			if err != nil {
+				fmt.Printf("⚠️  生成追问建议失败: %v\n", err)
+			} else if len(followUps) > 0 {
+				fmt.Println("\n💡 你可能还想问:")
+				for j, followUp := range followUps {
+					fmt.Printf("  %d. %s\n", j+1, followUp)
+				}
+			}
+		}
+
 		// 简单对比分析
 		fmt.Println("\n📊 对比分析:")
 		fmt.Printf("  - 时间开销: RAG比纯DeepSeek慢 %.2f秒\n", ragTime-directTime)
+		fmt.Printf("  - 并行耗时: %.2f秒，比顺序执行节省 %.2f秒\n", wallTime, directTime+ragTime-wallTime)
 		fmt.Printf("  - 信息质量: RAG基于 %d 个相关文档生成\n", len(sources))
 
-		if i < len(testQuestions)-1 {
+		if i < len(questions)-1 {
 			fmt.Println("\n" + strings.Repeat("-", 50))
 		}
 	}
 
+	printCostReport(rag.usage.Snapshot(), rag.config.Pricing)
+
 	fmt.Println("\n" + strings.Repeat("=", 50))
 	fmt.Println("🎉 测试完成!")
 	fmt.Println("💡 总结: RAG在需要最新、具体信息的场景表现更好")
@@ -138,17 +309,81 @@ func loadConfig() Config {
 	godotenv.Load()
 
 	return Config{
-		MilvusHost:     getEnv("MILVUS_HOST", "localhost"),
-		MilvusPort:     getEnvAsInt("MILVUS_PORT", 19530),
-		DeepSeekAPIKey: getEnv("DEEPSEEK_API_KEY", ""),
-		DeepSeekModel:  getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
-		CollectionName: getEnv("COLLECTION_NAME", "rag_demo"),
+		MilvusHost:         getEnv("MILVUS_HOST", "localhost"),
+		MilvusPort:         getEnvAsInt("MILVUS_PORT", 19530),
+		DeepSeekAPIKey:     getEnv("DEEPSEEK_API_KEY", ""),
+		DeepSeekModel:      getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
+		Models:             loadChatModels(),
+		SystemPrompt:       getEnv("RAG_SYSTEM_PROMPT", ragSystemPrompt),
+		CollectionName:     getEnv("COLLECTION_NAME", "rag_demo"),
+		ChunkSize:          getEnvAsInt("CHUNK_SIZE", 500),
+		IndexBatch:         getEnvAsInt("INDEX_BATCH_SIZE", 500),
+		IndexWorkers:       getEnvAsInt("INDEX_WORKERS", 4),
+		Profiling:          getEnvAsBool("PROFILING", false),
+		Trace:              loadTraceConfig(),
+		Classify:           loadClassifyConfig(),
+		Entity:             loadEntityConfig(),
+		Compression:        getEnv("CHUNK_COMPRESSION", "none") == "zstd",
+		Retrieval:          loadRetrievalConfig(),
+		Tokenizer:          loadTokenizerConfig(),
+		ChineseScript:      getEnv("CHINESE_SCRIPT", scriptSimplified),
+		CrossLingual:       loadCrossLingualConfig(),
+		Hybrid:             loadHybridConfig(),
+		Trust:              loadTrustConfig(),
+		License:            loadLicenseConfig(),
+		Federation:         loadFederationConfig(),
+		Peers:              loadPeerConfig(),
+		Blob:               loadBlobStoreConfig(),
+		DocLimits:          loadDocumentLimits(),
+		Consistency:        getEnv("WRITE_CONSISTENCY", consistencyEventual),
+		DocsDir:            getEnv("DOCS_DIR", ""),
+		BootstrapFile:      getEnv("BOOTSTRAP_FILE", ".bootstrap_dataset.json"),
+		Crawler:            loadCrawlerConfig(),
+		FollowUps:          getEnvAsBool("FOLLOW_UP_SUGGESTIONS", true),
+		GlossaryFile:       getEnv("GLOSSARY_FILE", "glossary.json"),
+		PolicyFile:         getEnv("ANSWER_POLICY_FILE", "policy.json"),
+		LexiconFile:        getEnv("LEXICON_FILE", "lexicon.json"),
+		OversizedLog:       getEnv("OVERSIZED_CHUNK_LOG", "oversized_chunks.jsonl"),
+		Calculator:         getEnvAsBool("CALCULATOR_TOOL", true),
+		Continuations:      getEnvAsInt("ANSWER_MAX_CONTINUATIONS", 2),
+		Extractive:         getEnvAsInt("EXTRACTIVE_SENTENCES", 3),
+		Coalescing:         getEnvAsBool("REQUEST_COALESCING", true),
+		Reasoning:          loadReasoningConfig(),
+		Degrade:            loadDegradeConfig(),
+		Summarize:          loadSummarizeConfig(),
+		Provenance:         loadProvenanceConfig(),
+		Features:           loadFeatureConfig(),
+		Rules:              loadRulesConfig(),
+		Overrides:          loadOverrideConfig(),
+		Maintenance:        loadMaintenanceConfig(),
+		NamespaceEmbedding: loadNamespaceEmbeddingConfig(),
+		LanguageFilter:     loadLanguageFilterConfig(),
+		AnswerLength:       loadAnswerLengthConfig(),
+		Dates:              loadDateConfig(),
+		Embedding:          loadEmbeddingConfig(),
+		AnswerCache:        loadAnswerCacheConfig(),
+		RetrievalCache:     loadRetrievalCacheConfig(),
+		DeepLink:           loadDeepLinkConfig(),
+		Warm:               loadWarmConfig(),
+		Pricing:            loadPricingConfig(),
+		EvalSchedule:       loadEvalScheduleConfig(),
+		Review:             loadReviewConfig(),
+		QueryLog:           loadQueryLogConfig(),
+		Encryption:         loadEncryptionConfig(),
+		SLO:                loadSLOConfig(),
+		Session:            loadSessionConfig(),
+		Expiry:             loadExpiryConfig(),
+		Reload:             loadReloadConfig(),
+		Idempotency:        loadIdempotencyConfig(),
+		Admin:              loadAdminConfig(),
+		Fault:              loadFaultConfig(),
+		Failover:           loadFailoverConfig("MILVUS", 19530),
 	}
 }
 
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
-		return value
+		return resolveSecret(key, value)
 	}
 	return defaultValue
 }
@@ -163,6 +398,30 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return result
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
 // 创建RAG系统
 func NewRAGSystem(config Config) (*RAGSystem, error) {
 	// 验证配置
@@ -170,6 +429,60 @@ func NewRAGSystem(config Config) (*RAGSystem, error) {
 		return nil, fmt.Errorf("DEEPSEEK_API_KEY不能为空")
 	}
 
+	// 问题向量化，用于答案缓存的语义匹配
+	questionEmbedder, err := newEmbedder(config.Embedding)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := newTokenCounter(config.Tokenizer, config.DeepSeekModel)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateContextOrder(config.Retrieval.Order); err != nil {
+		return nil, err
+	}
+	if config.Expiry.Action != expiryDelete && config.Expiry.Action != expiryArchive {
+		return nil, fmt.Errorf("未知的EXPIRY_ACTION: %s，可选 delete、archive", config.Expiry.Action)
+	}
+	if err := config.Failover.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.SLO.validate(); err != nil {
+		return nil, err
+	}
+	script, err := newScriptConverter(config.ChineseScript)
+	if err != nil {
+		return nil, err
+	}
+	encryption, err := newFieldCipher(config.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	// 原文存储（可选）
+	blobs, err := newBlobStore(config.Blob)
+	if err != nil {
+		return nil, err
+	}
+
+	// 文档分类（可选）
+	docClassifier, err := newClassifier(config.Classify)
+	if err != nil {
+		return nil, err
+	}
+
+	// 实体抽取（可选）
+	entities, err := newEntityExtractor(config.Entity)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检索轨迹（可选）
+	traces, err := newTraceWriter(config.Trace)
+	if err != nil {
+		return nil, err
+	}
+
 	// 连接Milvus
 	milvusClient, err := client.NewClient(context.Background(), client.Config{
 		Address: fmt.Sprintf("%s:%d", config.MilvusHost, config.MilvusPort),
@@ -178,14 +491,107 @@ func NewRAGSystem(config Config) (*RAGSystem, error) {
 		return nil, fmt.Errorf("连接Milvus失败: %w", err)
 	}
 
+	// 连接Milvus备节点（可选）
+	var replicaClient client.Client
+	var fo *failover
+	if config.Failover.ReplicaHost != "" {
+		replicaClient, err = client.NewClient(context.Background(), client.Config{
+			Address: fmt.Sprintf("%s:%d", config.Failover.ReplicaHost, config.Failover.ReplicaPort),
+		})
+		if err != nil {
+			_ = milvusClient.Close()
+			return nil, fmt.Errorf("连接Milvus备节点失败: %w", err)
+		}
+		fo = newFailover(config.Failover, func(ctx context.Context) error {
+			_, err := milvusClient.ListCollections(ctx)
+			return err
+		})
+	}
+
+	// 加载术语表
+	terms, err := loadGlossary(config.GlossaryFile)
+	if err != nil {
+		fo.Close()
+		if replicaClient != nil {
+			_ = replicaClient.Close()
+		}
+		_ = milvusClient.Close()
+		return nil, err
+	}
+
+	// 加载回答策略
+	policies, err := loadAnswerPolicies(config.PolicyFile)
+	if err != nil {
+		fo.Close()
+		if replicaClient != nil {
+			_ = replicaClient.Close()
+		}
+		_ = milvusClient.Close()
+		return nil, err
+	}
+
+	// 加载屏蔽词和替换词表
+	words, err := loadLexicon(config.LexiconFile)
+	if err != nil {
+		fo.Close()
+		if replicaClient != nil {
+			_ = replicaClient.Close()
+		}
+		_ = milvusClient.Close()
+		return nil, err
+	}
+
+	// 加载检索特性开关
+	flags, err := loadFeatureFlags(config.Features)
+	if err != nil {
+		fo.Close()
+		if replicaClient != nil {
+			_ = replicaClient.Close()
+		}
+		_ = milvusClient.Close()
+		return nil, err
+	}
+
 	conf := openai.DefaultConfig(config.DeepSeekAPIKey)
 	conf.BaseURL = "https://api.deepseek.com"
-
-	return &RAGSystem{
-		milvusClient: milvusClient,
-		openAIClient: openai.NewClientWithConfig(conf),
-		config:       config,
-	}, nil
+	usage := &usageTracker{}
+	conf.HTTPClient = newUsageHTTPClient(usage)
+	conf.HTTPClient.Transport = rotatingAuth(conf.HTTPClient.Transport, "DEEPSEEK_API_KEY", bearerAuth)
+
+	r := &RAGSystem{
+		milvusClient:  milvusClient,
+		replicaClient: replicaClient,
+		openAIClient:  openai.NewClientWithConfig(conf),
+		localClients:  newLocalChatClients(config.Models),
+		config:        config,
+		faults:        newFaultInjector(config.Fault),
+		failover:      fo,
+		tokens:        tokens,
+		script:        script,
+		encryption:    encryption,
+		usage:         usage,
+		answers:       newAnswerCache(config.AnswerCache, questionEmbedder),
+		retrievals:    newRetrievalCache(config.RetrievalCache),
+		embedder:      questionEmbedder,
+		flights:       newAnswerFlights(config.Coalescing),
+		broadcasts:    newAnswerBroadcasts(config.Coalescing),
+		trending:      newQuestionTracker(config.Warm),
+		blobs:         blobs,
+		classifier:    docClassifier,
+		peers:         newPeerInstances(config.Peers),
+		entities:      entities,
+		traces:        traces,
+		sessions:      newSessionStore(config.Session, questionEmbedder),
+		oversized:     newOversizedLog(config.OversizedLog),
+	}
+	if r.namespaceEmbeddings, err = loadNamespaceEmbeddings(config.NamespaceEmbedding, config.Embedding); err != nil {
+		return nil, err
+	}
+	if r.vectorEmbedder, err = newVectorEmbedder(config.Embedding); err != nil {
+		return nil, err
+	}
+	r.live.Store(&liveSettings{SystemPrompt: config.SystemPrompt, Retrieval: config.Retrieval, MMRLambda: config.Features.MMRLambda, glossary: terms, policies: policies, lexicon: words, flags: flags})
+	return r, nil
 }
 
 // 初始化知识库
@@ -208,8 +614,31 @@ func (r *RAGSystem) InitializeKnowledgeBase() error {
 	}
 
 	// 创建集合
-	err = r.milvusClient.CreateCollection(ctx, &entity.Schema{
-		CollectionName: collectionName,
+	err = r.milvusClient.CreateCollection(ctx, r.collectionSchema(), 2) // 分片数为2
+	if err != nil {
+		return fmt.Errorf("创建集合失败: %w", err)
+	}
+
+	// 插入示例文档
+	err = r.insertSampleDocuments()
+	if err != nil {
+		return fmt.Errorf("插入文档失败: %w", err)
+	}
+
+	// 创建索引
+	for _, field := range r.indexedFields() {
+		if err := r.createIndex(ctx, collectionName, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 当前配置下集合应有的schema
+func (r *RAGSystem) collectionSchema() *entity.Schema {
+	schema := &entity.Schema{
+		CollectionName: r.config.CollectionName,
 		Description:    "RAG演示知识库",
 		Fields: []*entity.Field{
 			{
@@ -221,6 +650,20 @@ func (r *RAGSystem) InitializeKnowledgeBase() error {
 					"max_length": "100",
 				},
 			},
+			{
+				Name:     "doc_id",
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					"max_length": "100",
+				},
+			},
+			{
+				Name:     "source_path",
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					"max_length": "1000",
+				},
+			},
 			{
 				Name:     "title",
 				DataType: entity.FieldTypeVarChar,
@@ -235,98 +678,354 @@ func (r *RAGSystem) InitializeKnowledgeBase() error {
 					"max_length": "10000",
 				},
 			},
+			{
+				Name:     "meta",
+				DataType: entity.FieldTypeJSON,
+			},
 			{
 				Name:     "vector",
 				DataType: entity.FieldTypeFloatVector,
 				TypeParams: map[string]string{
-					"dim": "4", // 简化版，使用4维向量
+					"dim": strconv.Itoa(r.embeddingDim()),
 				},
 			},
 		},
 		EnableDynamicField: false,
-	}, 2) // 分片数为2
-	if err != nil {
-		return fmt.Errorf("创建集合失败: %w", err)
 	}
+	// 命名空间模型的向量按各自的维度单独建字段
+	for _, field := range r.vectorFields()[1:] {
+		schema.Fields = append(schema.Fields, &entity.Field{
+			Name:       field.Name,
+			DataType:   entity.FieldTypeFloatVector,
+			TypeParams: map[string]string{"dim": strconv.Itoa(field.Dim)},
+		})
+	}
+	// 混合检索需要词频稀疏向量字段
+	if r.config.Hybrid.Enabled {
+		schema.Fields = append(schema.Fields, &entity.Field{
+			Name:     "sparse",
+			DataType: entity.FieldTypeSparseVector,
+		})
+	}
+	return schema
+}
 
-	// 插入示例文档
-	err = r.insertSampleDocuments()
-	if err != nil {
-		return fmt.Errorf("插入文档失败: %w", err)
+// 需要建索引的向量字段
+func (r *RAGSystem) indexedFields() []string {
+	var fields []string
+	for _, field := range r.vectorFields() {
+		fields = append(fields, field.Name)
+	}
+	if r.config.Hybrid.Enabled {
+		fields = append(fields, "sparse")
 	}
+	return fields
+}
 
-	// 创建索引
-	index, err := entity.NewIndexHNSW(entity.L2, 8, 64)
-	if err != nil {
-		return fmt.Errorf("创建索引失败: %w", err)
+// 字段的预期索引：稠密向量使用HNSW，词频稀疏向量使用倒排索引
+func expectedIndex(field string) (entity.Index, error) {
+	if field == "sparse" {
+		return entity.NewIndexSparseInverted(entity.IP, 0)
 	}
+	return entity.NewIndexHNSW(entity.L2, 8, 64)
+}
 
-	err = r.milvusClient.CreateIndex(ctx, collectionName, "vector", index, false)
+func (r *RAGSystem) createIndex(ctx context.Context, collectionName, field string) error {
+	index, err := expectedIndex(field)
 	if err != nil {
-		return fmt.Errorf("创建向量索引失败: %w", err)
+		return fmt.Errorf("创建%s索引失败: %w", field, err)
+	}
+	if err := r.milvusClient.CreateIndex(ctx, collectionName, field, index, false); err != nil {
+		return fmt.Errorf("创建%s字段索引失败: %w", field, err)
 	}
-
 	return nil
 }
 
-// 插入示例文档
-func (r *RAGSystem) insertSampleDocuments() error {
-	ctx := context.Background()
-
-	// 示例文档数据（包含最新信息）
-	documents := []Document{
+// 示例文档数据（包含最新信息）
+func sampleDocuments() []Document {
+	return []Document{
 		{
 			ID:      "doc_001",
 			Title:   "闫同学人物介绍",
 			Content: "闫同学，男，来自中国，26岁，天蝎座，是知名技术博主、摄影博主、技术爱好者，擅长写Go语言，喜欢打羽毛球。",
+			Meta: map[string]interface{}{
+				"source": "闫同学人物介绍",
+				"date":   "2026-02-04",
+			},
 		},
 		{
 			ID:      "doc_002",
 			Title:   "扯编程的淡公众号介绍",
 			Content: "扯编程的淡，科技领域知名微信公众号，由闫同学运营，内容多为技术博客，日常生活感想，截止2026年1月，已有粉丝2000+。",
+			Meta: map[string]interface{}{
+				"source": "扯编程的淡公众号介绍",
+				"date":   "2026-02-04",
+			},
 		},
 	}
+}
+
+// 知识库中应当存在的源文档：示例文档（或bootstrap数据集）、DOCS_DIR目录中的文件和抓取的网页
+func (r *RAGSystem) sourceDocuments() ([]Document, error) {
+	dataset, err := loadBootstrapDataset(r.config.BootstrapFile)
+	if err != nil {
+		return nil, err
+	}
+	documents := sampleDocuments()
+	if dataset != nil {
+		documents = dataset.documents()
+	}
+	if r.config.DocsDir != "" {
+		loaded, err := loadDocumentsFromDir(r.config.DocsDir)
+		if err != nil {
+			return nil, fmt.Errorf("加载目录 %s 失败: %w", r.config.DocsDir, err)
+		}
+		documents = append(documents, loaded...)
+	}
+	if len(r.config.Crawler.SeedURLs) > 0 {
+		c, err := newCrawler(r.config.Crawler)
+		if err != nil {
+			return nil, err
+		}
+		crawled, err := c.Crawl(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("抓取网页失败: %w", err)
+		}
+		documents = append(documents, crawled...)
+	}
+	return documents, nil
+}
+
+// 插入示例文档
+func (r *RAGSystem) insertSampleDocuments() error {
+	documents, err := r.sourceDocuments()
+	if err != nil {
+		return err
+	}
+	return r.IndexDocuments(documents)
+}
 
-	// 为每个文档生成向量并插入
-	var ids []string
-	var titles []string
-	var contents []string
-	var vectors [][]float32
+// 替换文档：先删除文档已有的分块再重新写入，用于增量同步。修订号在已存储的修订号上加一，
+// 读取修订号到写入完成之间持有revisionMu，与带版本校验的更新互斥
+func (r *RAGSystem) ReplaceDocuments(documents []Document) error {
+	if len(documents) == 0 {
+		return nil
+	}
+	r.revisionMu.Lock()
+	defer r.revisionMu.Unlock()
+	if err := r.stampRevisions(context.Background(), documents); err != nil {
+		return err
+	}
+	return r.rewriteDocuments(documents)
+}
 
+// 删除文档已有的分块后按documents重新写入，修订号由调用方确定
+func (r *RAGSystem) rewriteDocuments(documents []Document) error {
+	docIDs := make([]string, 0, len(documents))
 	for _, doc := range documents {
-		// 生成简化向量（4维）
-		vector := r.generateSimpleVector(doc.Content)
+		docIDs = append(docIDs, doc.ID)
+	}
+	if err := r.DeleteDocuments(docIDs); err != nil {
+		return err
+	}
+	return r.IndexDocuments(documents)
+}
 
-		ids = append(ids, doc.ID)
-		titles = append(titles, doc.Title)
-		contents = append(contents, doc.Content)
-		vectors = append(vectors, vector)
+// 落盘并重新加载集合，使刚写入的分块对检索可见
+func (r *RAGSystem) makeVisible(ctx context.Context) error {
+	if err := r.milvusClient.Flush(ctx, r.config.CollectionName, false); err != nil {
+		return fmt.Errorf("刷新集合失败: %w", err)
 	}
+	if err := r.milvusClient.LoadCollection(ctx, r.config.CollectionName, false); err != nil {
+		return fmt.Errorf("加载集合失败: %w", err)
+	}
+	return nil
+}
 
-	// 插入数据
-	idColumn := entity.NewColumnVarChar("id", ids)
-	titleColumn := entity.NewColumnVarChar("title", titles)
-	contentColumn := entity.NewColumnVarChar("content", contents)
-	vectorColumn := entity.NewColumnFloatVector("vector", 4, vectors)
+// 按文档ID删除文档的全部分块
+func (r *RAGSystem) DeleteDocuments(docIDs []string) error {
+	if len(docIDs) == 0 {
+		return nil
+	}
+	defer r.retrievals.Clear()
 
-	_, err := r.milvusClient.Insert(ctx, r.config.CollectionName, "", idColumn, titleColumn, contentColumn, vectorColumn)
+	quoted := make([]string, 0, len(docIDs))
+	for _, docID := range docIDs {
+		quoted = append(quoted, fmt.Sprintf("%q", docID))
+	}
+	expr := fmt.Sprintf("doc_id in [%s]", strings.Join(quoted, ", "))
+	if err := r.milvusClient.Delete(context.Background(), r.config.CollectionName, "", expr); err != nil {
+		return fmt.Errorf("删除旧分块失败: %w", err)
+	}
+	return nil
+}
 
+// 将文档分块后写入知识库，每INDEX_BATCH_SIZE个分块插入一次，最多INDEX_WORKERS批同时插入
+func (r *RAGSystem) IndexDocuments(documents []Document) error {
+	ctx := context.Background()
+	defer trackIngest()()
+	profiler := newStageProfiler(r.config.Profiling)
+	defer profiler.Finish()
+	// 写入后缓存的检索结果可能已过时，无论成功与否都清空
+	defer r.retrievals.Clear()
+
+	// 写入前确认向量维度与集合一致
+	if err := r.checkVectorDim(ctx); err != nil {
+		return err
+	}
+
+	// 没有分类的文档由大模型自动分类，元数据中的日期解析为时间戳
+	r.classifyDocuments(documents)
+	r.config.Dates.normalizeDocuments(documents)
+	profiler.Mark("分类")
+
+	// 完整原文存入原文存储，向量库只保存分块和元数据
+	if err := r.storeOriginals(documents); err != nil {
+		return err
+	}
+	profiler.Mark("原文存储")
+
+	chunks, err := r.writeChunks(ctx, r.config.CollectionName, documents, r.config.IndexBatch, r.config.IndexWorkers, profiler)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("✅ 插入了 %d 个文档到知识库\n", len(documents))
+	fmt.Printf("✅ 插入了 %d 个文档（%d 个分块）到知识库\n", len(documents), len(chunks))
+	printIngestReport(buildIngestReport(documents, chunks, r.tokens))
 	return nil
 }
 
-// 生成简化向量（4维向量）
+// 分块、编码并批量插入collectionName，返回写入的分块。列缓冲同步插入时在批次间复用，
+// 并发插入时每批换新，避免大批量入库时内存膨胀
+func (r *RAGSystem) writeChunks(ctx context.Context, collectionName string, documents []Document, batchSize, workers int, profiler *stageProfiler) ([]Chunk, error) {
+	batchSize = max(batchSize, 1)
+	var (
+		ids, docIDs, sourcePaths, titles, contents []string
+		metas                                      [][]byte
+		models                                     []string
+		vectors                                    [][]float32
+		sparseVectors                              []entity.SparseEmbedding
+	)
+	newBatch := func() {
+		ids = make([]string, 0, batchSize)
+		docIDs = make([]string, 0, batchSize)
+		sourcePaths = make([]string, 0, batchSize)
+		titles = make([]string, 0, batchSize)
+		contents = make([]string, 0, batchSize)
+		metas = make([][]byte, 0, batchSize)
+		models = make([]string, 0, batchSize)
+		vectors = make([][]float32, 0, batchSize)
+		if r.config.Hybrid.Enabled {
+			sparseVectors = make([]entity.SparseEmbedding, 0, batchSize)
+		}
+	}
+	newBatch()
+	writer := newIndexWriter(workers)
+	defer writer.Wait()
+	defer func() { ingestCounters.chunksHeld.Add(-int64(len(ids))) }()
+
+	flush := func() error {
+		if len(ids) == 0 {
+			return nil
+		}
+		profiler.Mark("分块编码")
+		idColumn := entity.NewColumnVarChar("id", ids)
+		docIDColumn := entity.NewColumnVarChar("doc_id", docIDs)
+		sourcePathColumn := entity.NewColumnVarChar("source_path", sourcePaths)
+		titleColumn := entity.NewColumnVarChar("title", titles)
+		contentColumn := entity.NewColumnVarChar("content", contents)
+		metaColumn := entity.NewColumnJSONBytes("meta", metas)
+		columns := []entity.Column{idColumn, docIDColumn, sourcePathColumn, titleColumn, contentColumn, metaColumn}
+		columns = append(columns, r.vectorColumns(models, vectors)...)
+		if r.config.Hybrid.Enabled {
+			columns = append(columns, entity.NewColumnSparseVectors("sparse", sparseVectors))
+		}
+
+		held := int64(len(ids))
+		err := writer.Go(func() error {
+			defer ingestCounters.chunksHeld.Add(-held)
+			if err := r.faults.inject(ctx, faultTargetStore, "Milvus插入"); err != nil {
+				return err
+			}
+			_, err := r.milvusClient.Insert(ctx, collectionName, "", columns...)
+			return err
+		})
+		profiler.Mark("批量写入")
+
+		if writer.concurrent() {
+			newBatch()
+		} else {
+			ids, docIDs, sourcePaths, titles = ids[:0], docIDs[:0], sourcePaths[:0], titles[:0]
+			contents, metas, models, vectors, sparseVectors = contents[:0], metas[:0], models[:0], vectors[:0], sparseVectors[:0]
+		}
+		return err
+	}
+
+	// 将文档分块，为每个分块生成向量并插入
+	var chunks []Chunk
+	for _, doc := range documents {
+		sourcePath, _ := doc.Meta["source_path"].(string)
+		model := r.tagEmbeddingModel(&doc)
+		for _, chunk := range splitDocument(doc, r.config.ChunkSize) {
+			meta, err := json.Marshal(r.entities.enrich(withLanguage(withHeading(doc.Meta, chunk.Heading), chunk.Content), chunk.Title+"\n"+chunk.Content))
+			if err != nil {
+				return nil, fmt.Errorf("序列化文档 %s 元数据失败: %w", doc.ID, err)
+			}
+
+			// 按分类的向量模型生成向量（默认为内置简化向量），文档自带预计算向量时直接使用
+			vector := doc.Vector
+			if vector == nil {
+				if vector, err = r.embedWith(ctx, model, chunk.Content); err != nil {
+					return nil, fmt.Errorf("文档 %s 向量化失败: %w", doc.ID, err)
+				}
+			} else if dim := r.vectorDim(model); len(vector) != dim {
+				return nil, fmt.Errorf("%w: 文档 %s 的预计算向量为 %d 维，%s字段为 %d 维", ErrDimensionMismatch, doc.ID, len(vector), embeddingField(model), dim)
+			}
+
+			chunks = append(chunks, chunk)
+			ids = append(ids, chunk.ID)
+			docIDs = append(docIDs, chunk.DocID)
+			sourcePaths = append(sourcePaths, sourcePath)
+			titles = append(titles, chunk.Title)
+			content := chunk.Content
+			if r.config.Compression {
+				content = encodeCompressed(content)
+			}
+			contents = append(contents, content)
+			metas = append(metas, meta)
+			models = append(models, model)
+			vectors = append(vectors, vector)
+			if r.config.Hybrid.Enabled {
+				sparseVectors = append(sparseVectors, termFreqDocVector(r.script.Convert(chunk.Title+"\n"+chunk.Content), float64(r.config.ChunkSize)))
+			}
+			ingestCounters.chunksHeld.Add(1)
+
+			if len(ids) >= batchSize {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if err := writer.Wait(); err != nil {
+		return nil, err
+	}
+	profiler.Mark("批量写入")
+	return chunks, nil
+}
+
+// 生成内置简化向量（simpleVectorDim维）
 func (r *RAGSystem) generateSimpleVector(text string) []float32 {
-	// 创建4维向量
-	vector := make([]float32, 4)
+	// 繁简统一后计算
+	text = r.script.Convert(text)
+	vector := make([]float32, simpleVectorDim)
 
 	// 基于文本内容生成简单的向量表示
 	// 这里只是示例，实际应用中应该使用embedding模型
-	for i := 0; i < 4; i++ {
+	for i := 0; i < simpleVectorDim; i++ {
 		// 简单的哈希函数生成伪随机向量值
 		hash := float32(0)
 		for j, ch := range text {
@@ -357,7 +1056,10 @@ func (r *RAGSystem) GetDirectAnswer(question string) (string, float64, error) {
 	start := time.Now()
 
 	ctx := context.Background()
-	resp, err := r.openAIClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+	if err := r.faults.inject(ctx, faultTargetLLM, "DeepSeek直接回答"); err != nil {
+		return "", 0, err
+	}
+	answer, err := r.completeAnswer(ctx, openai.ChatCompletionRequest{
 		Model: r.config.DeepSeekModel,
 		Messages: []openai.ChatCompletionMessage{
 			{
@@ -377,100 +1079,360 @@ func (r *RAGSystem) GetDirectAnswer(question string) (string, float64, error) {
 		return "", 0, err
 	}
 
-	elapsed := time.Since(start).Seconds()
-
-	if len(resp.Choices) == 0 {
-		return "", elapsed, fmt.Errorf("未收到回答")
-	}
-
-	return resp.Choices[0].Message.Content, elapsed, nil
+	return answer, time.Since(start).Seconds(), nil
 }
 
 // 获取RAG增强答案
-func (r *RAGSystem) GetRAGAnswer(question string) (string, float64, []SearchResult, error) {
+func (r *RAGSystem) GetRAGAnswer(ctx context.Context, question string, opts searchOptions) (string, float64, []SearchResult, error) {
 	start := time.Now()
+	ctx = withPromptLog(ctx, opts.Prompts)
 
-	// 1. 检索相关文档
-	results, err := r.SearchDocuments(question, 3)
+	// 指定直接回答时不检索知识库
+	if opts.Direct {
+		answer, err := r.answerDirect(ctx, question, opts)
+		return answer, time.Since(start).Seconds(), nil, err
+	}
+
+	// 问题由对等实例负责时转发给对方，merge模式下对方的分块与本地检索结果合并
+	peerAnswer, remote, delegated := r.delegateToPeer(ctx, question, &opts)
+	if delegated {
+		return peerAnswer, time.Since(start).Seconds(), remote, nil
+	}
+
+	// 范围很广的问题分批总结后综合回答，大模型不可用时降级为分块摘录
+	if answer, results, handled, err := r.answerBySummaries(ctx, question, opts); handled {
+		if err != nil && len(results) > 0 {
+			answer, err = r.answerExtractive(ctx, results, opts, err)
+		}
+		return answer, time.Since(start).Seconds(), results, err
+	}
+
+	// 1. 检索相关文档，知识库不可用时按配置降级为大模型直接回答
+	results, err := r.retrieve(ctx, question, opts)
 	if err != nil {
-		return "", 0, nil, err
+		answer, err := r.answerWithoutRetrieval(ctx, question, opts, err)
+		return answer, time.Since(start).Seconds(), nil, err
+	}
+	results = mergePeerResults(results, remote)
+	// 会话中相关的历史问答作为额外的来源
+	results = append(results, opts.History...)
+
+	// 低置信度或超出知识库范围时按回答策略拒答、转人工或直接回答
+	if answer, handled, err := r.applyPolicy(ctx, question, opts, results); handled {
+		return answer, time.Since(start).Seconds(), nil, err
+	}
+	// 没有可参考的内容时不调用大模型
+	if len(results) == 0 {
+		return "", time.Since(start).Seconds(), nil, ErrNoRelevantDocs
+	}
+
+	// 2. 抽取式回答不调用大模型，向量化服务不可用时降级为分块摘录
+	if opts.Extractive {
+		answer, err := r.answerBySentences(ctx, question, results)
+		if err != nil {
+			answer, err = r.answerExtractive(ctx, results, opts, err)
+		}
+		return answer, time.Since(start).Seconds(), results, err
+	}
+
+	// 超出上下文预算的分块只把相关片段放进提示词
+	prompted := r.fitOversized(ctx, question, results)
+
+	// 3. 需要数值计算时走计算器工具，避免模型心算出错
+	if r.useCalculator(opts.Model) && needsCalculation(question, prompted) {
+		answer, steps, err := r.answerWithCalculator(ctx, question, prompted, opts.Model)
+		if err != nil {
+			answer, err := r.answerExtractive(ctx, results, opts, err)
+			return answer, time.Since(start).Seconds(), results, err
+		}
+		return appendAttribution(appendCalcSteps(answer, steps), results), time.Since(start).Seconds(), results, nil
+	}
+
+	// 4. 调用DeepSeek生成答案，推理模型的思考过程收集到opts.Reasoning；大模型不可用时降级为分块摘录
+	ctx = withReasoning(ctx, opts.Reasoning)
+	var answer string
+	err = llmError(ctx, r.faults.inject(ctx, faultTargetLLM, "DeepSeek RAG回答"))
+	if err == nil {
+		answer, err = r.completeWithLength(ctx, r.ragChatRequest(question, prompted, opts.Model), opts.Length)
 	}
 
-	// 2. 构建上下文
+	elapsed := time.Since(start).Seconds()
+
+	if err != nil {
+		answer, err := r.answerExtractive(ctx, results, opts, err)
+		return answer, elapsed, results, err
+	}
+
+	return appendAttribution(answer, results), elapsed, results, nil
+}
+
+// 默认的RAG系统提示词，保持不变以便命中上下文缓存
+const ragSystemPrompt = "你是一个严谨的AI助手，必须严格基于提供的上下文信息回答问题。如果上下文信息不足，请如实告知。不要编造上下文之外的信息。" +
+	"每个文档标注了来源和可信度，信息冲突时以可信度高的来源为准，引用可信度低的来源时需说明。"
+
+// 构建RAG请求：将检索到的文档作为上下文，model为空时使用DEEPSEEK_MODEL
+func (r *RAGSystem) ragChatRequest(question string, results []SearchResult, model string) openai.ChatCompletionRequest {
+	// DeepSeek按前缀命中上下文缓存：固定的系统提示词在前，上下文默认按分块ID排序（CONTEXT_ORDER），
+	// 同一批检索结果总能生成相同的前缀，随问题变化的内容放在最后
+	ordered := orderContext(results, r.settings().Retrieval.Order)
+
 	var contextBuilder strings.Builder
 	contextBuilder.WriteString("以下是相关文档信息：\n\n")
 
-	for i, result := range results {
-		contextBuilder.WriteString(fmt.Sprintf("文档%d: %s\n", i+1, result.Title))
+	for i, result := range ordered {
+		contextBuilder.WriteString(fmt.Sprintf("文档%d: %s（%s）\n", i+1, result.Title, citationSource(result)))
 		contextBuilder.WriteString(fmt.Sprintf("内容: %s\n\n", result.Content))
 	}
 
-	contextStr := contextBuilder.String()
+	// 问题中出现的术语附上释义，帮助模型理解行话
+	if terms := r.settings().glossary.Match(question); len(terms) > 0 {
+		contextBuilder.WriteString("术语表：\n")
+		contextBuilder.WriteString(formatGlossary(terms))
+	}
 
-	// 3. 调用DeepSeek生成答案
-	ctx := context.Background()
-	resp, err := r.openAIClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: r.config.DeepSeekModel,
+	if model == "" {
+		model = r.config.DeepSeekModel
+	}
+
+	return openai.ChatCompletionRequest{
+		Model: model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
-				Content: "你是一个严谨的AI助手，必须严格基于提供的上下文信息回答问题。如果上下文信息不足，请如实告知。不要编造上下文之外的信息。",
+				Content: r.settings().SystemPrompt,
 			},
 			{
 				Role:    openai.ChatMessageRoleUser,
-				Content: fmt.Sprintf("上下文信息：\n%s\n\n问题：%s\n\n请基于上述上下文信息回答问题：", contextStr, question),
+				Content: fmt.Sprintf("上下文信息：\n%s\n\n问题：%s%s\n\n请基于上述上下文信息回答问题：", contextBuilder.String(), question, r.config.Dates.questionNote(question, time.Now())),
 			},
 		},
 		Temperature: 0.1,
 		MaxTokens:   500,
-	})
+	}
+}
 
-	elapsed := time.Since(start).Seconds()
+// 精度档位对应的HNSW ef（搜索时的候选队列大小），越大召回率越高、延迟越高
+var milvusProfileEf = map[string]int{
+	accuracyFast:     16,
+	accuracyBalanced: 32,
+	accuracyAccurate: 128,
+}
+
+// 搜索相关文档；配置了多索引联合检索时跨集合检索并按权重合并
+func (r *RAGSystem) SearchDocuments(ctx context.Context, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	results, _, err := r.searchDocuments(ctx, query, topK, opts)
+	return results, err
+}
 
+// 同SearchDocuments，另外返回实际使用的检索条件：档位、特性、路由到的分类和实体
+func (r *RAGSystem) searchDocuments(ctx context.Context, query string, topK int, opts searchOptions) ([]SearchResult, searchOptions, error) {
+	opts, err := opts.resolve(r.settings().Retrieval.Profile)
 	if err != nil {
-		return "", elapsed, results, err
+		return nil, opts, err
 	}
+	// 相对时间按原问题解析，改写后的问题可能已不含原来的表述
+	if opts.Period == nil {
+		if opts.Period = r.config.Dates.relativePeriod(query, time.Now()); opts.Period != nil {
+			fmt.Printf("🗓️  按时间范围检索: %s（%s）\n", opts.Period.Phrase, opts.Period)
+		}
+	}
+	if opts.Features == nil {
+		opts.Features = r.resolveFeatures(query, opts.Category)
+	}
+	query = r.searchQuery(ctx, query)
+	if opts.Category == "" {
+		route := opts.Routed
+		if route == "" {
+			route = r.routeQuestion(ctx, query)
+		}
+		if opts.Category = route; opts.Category != "" {
+			results, err := r.searchScope(ctx, query, topK, opts)
+			if err != nil || len(results) > 0 {
+				return results, opts, err
+			}
+			// 路由到的分类下没有命中，退回全库检索
+			opts.Category = ""
+		}
+	}
+	if opts.Entity == "" {
+		if opts.Entity = r.entities.route(query); opts.Entity != "" {
+			results, err := r.searchScope(ctx, query, topK, opts)
+			if err != nil || len(results) > 0 {
+				return results, opts, err
+			}
+			// 没有分块提及问题中的实体，不按实体过滤
+			opts.Entity = ""
+		}
+	}
+	results, err := r.searchScope(ctx, query, topK, opts)
+	return results, opts, err
+}
 
-	if len(resp.Choices) == 0 {
-		return "", elapsed, results, fmt.Errorf("未收到回答")
+// 在配置的集合或联合索引中检索
+func (r *RAGSystem) searchTargets(ctx context.Context, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	if len(r.config.Federation) > 0 {
+		return r.federatedSearch(ctx, query, topK, opts)
 	}
+	return r.searchIndex(ctx, r.config.CollectionName, query, topK, opts)
+}
 
-	return resp.Choices[0].Message.Content, elapsed, results, nil
+// 搜索参数：集合创建的是HNSW索引，只指定nprobe时按IVF索引搜索
+func milvusSearchParam(opts searchOptions, topK int) entity.SearchParam {
+	if opts.NProbe > 0 && opts.EF == 0 {
+		sp, _ := entity.NewIndexIvfFlatSearchParam(opts.NProbe)
+		return sp
+	}
+	ef := opts.EF
+	if ef == 0 {
+		ef = milvusProfileEf[opts.Profile]
+	}
+	sp, _ := entity.NewIndexHNSWSearchParam(max(ef, topK)) // ef不能小于topK
+	return sp
 }
 
-// 搜索相关文档 - 使用最新的Milvus SDK API
-func (r *RAGSystem) SearchDocuments(query string, topK int) ([]SearchResult, error) {
-	ctx := context.Background()
-	collectionName := r.config.CollectionName
+// 在单个集合的一个向量空间中搜索，相同的查询向量和过滤条件在RETRIEVAL_CACHE_TTL_SECONDS内直接使用缓存的结果。
+// 分页检索不缓存；混合检索的关键词召回取决于查询文本，此时查询文本也计入缓存键
+func (r *RAGSystem) searchSpace(ctx context.Context, collectionName, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	if r.retrievals == nil || opts.Page != nil {
+		return r.searchStore(ctx, collectionName, query, topK, opts)
+	}
+	text := ""
+	if r.config.Hybrid.Enabled {
+		text = r.script.Convert(query)
+	}
+	key := retrievalKey(collectionName, r.generateSimpleVector(query), text, topK, opts)
+	if results, ok := r.retrievals.Get(key); ok {
+		fmt.Printf("⚡ 命中检索缓存: %s（%d条）\n", collectionName, len(results))
+		return results, nil
+	}
+	results, err := r.searchStore(ctx, collectionName, query, topK, opts)
+	if err == nil {
+		r.retrievals.Put(key, results)
+	}
+	return results, err
+}
+
+// 向量空间的Milvus表达式：命名空间模型只比较该模型写入的分块，内置向量排除各命名空间模型写入的分块
+func milvusEmbeddingConditions(model string, models []string) []string {
+	if model != "" {
+		return []string{fmt.Sprintf("meta[%q] == %q", embeddingModelKey, model)}
+	}
+	var conditions []string
+	for _, name := range models {
+		conditions = append(conditions, fmt.Sprintf("not (meta[%q] == %q)", embeddingModelKey, name))
+	}
+	return conditions
+}
+
+// 排除条件的Milvus表达式。用not包住比较，元数据中没有该字段的分块不会被排除；实体字段是列表，按包含判断
+func milvusExclusions(opts searchOptions) []string {
+	var conditions []string
+	for _, exclusion := range opts.Exclude {
+		switch exclusion.Field {
+		case entityPerson, entityOrganization, entityDate:
+			conditions = append(conditions, fmt.Sprintf("not json_contains(meta[%q], %q)", exclusion.Field, exclusion.Value))
+		default:
+			conditions = append(conditions, fmt.Sprintf("not (meta[%q] == %q)", exclusion.Field, exclusion.Value))
+		}
+	}
+	if len(opts.ExcludeDocs) > 0 {
+		quoted := make([]string, len(opts.ExcludeDocs))
+		for i, docID := range opts.ExcludeDocs {
+			quoted[i] = fmt.Sprintf("%q", docID)
+		}
+		conditions = append(conditions, fmt.Sprintf("doc_id not in [%s]", strings.Join(quoted, ", ")))
+	}
+	return conditions
+}
+
+// 在单个集合中搜索 - 使用最新的Milvus SDK API
+func (r *RAGSystem) searchStore(ctx context.Context, collectionName, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	// 按主备状态选择读节点
+	milvusClient, primary := r.readClient()
 
 	// 加载集合
-	err := r.milvusClient.LoadCollection(ctx, collectionName, false)
+	err := milvusClient.LoadCollection(ctx, collectionName, false)
 	if err != nil {
-		return nil, fmt.Errorf("加载集合失败: %w", err)
+		r.recordRead(primary, err)
+		return nil, storeError(ctx, "加载集合失败", err)
 	}
 
 	// 生成查询向量
-	queryVector := r.generateSimpleVector(query)
+	queryVector, err := r.embedWith(ctx, opts.EmbeddingModel, query)
+	if err != nil {
+		return nil, fmt.Errorf("问题向量化失败: %w", err)
+	}
+
+	// 分页检索时跳过前offset条，ef需覆盖offset+topK
+	offset := 0
+	if opts.Page != nil {
+		offset = opts.Page.Offset
+	}
 
 	// 搜索参数
-	sp, _ := entity.NewIndexHNSWSearchParam(32)
-
-	// 执行搜索 - 根据最新SDK修正
-	searchResults, err := r.milvusClient.Search(
-		ctx,
-		collectionName,
-		nil,                          // 分区列表
-		"",                           // 表达式
-		[]string{"title", "content"}, // 输出字段
-		[]entity.Vector{entity.FloatVector(queryVector)}, // 查询向量
-		"vector",  // 向量字段名
-		entity.L2, // 距离度量
-		topK,      // topK
-		sp,        // 搜索参数
-	)
+	sp := milvusSearchParam(opts, offset+topK)
+
+	if err := r.faults.inject(ctx, faultTargetStore, "Milvus搜索"); err != nil {
+		r.recordRead(primary, err)
+		return nil, storeError(ctx, "搜索失败", err)
+	}
+
+	// 按分类、实体和时间范围过滤，去掉排除的元数据取值和文档
+	var conditions []string
+	if opts.Category != "" {
+		conditions = append(conditions, fmt.Sprintf("meta[\"category\"] == %q", opts.Category))
+	}
+	if opts.Entity != "" {
+		conditions = append(conditions, fmt.Sprintf("(json_contains(meta[%q], %[2]q) || json_contains(meta[%q], %[2]q) || json_contains(meta[%q], %[2]q))",
+			entityPerson, opts.Entity, entityOrganization, entityDate))
+	}
+	if opts.Period != nil {
+		conditions = append(conditions, fmt.Sprintf("meta[%q] >= %d && meta[%q] < %d", dateTimestampKey, opts.Period.From.Unix(), dateTimestampKey, opts.Period.To.Unix()))
+	}
+	if opts.Language != "" {
+		conditions = append(conditions, fmt.Sprintf("meta[%q] == %q", languageKey, opts.Language))
+	}
+	conditions = append(conditions, milvusExclusions(opts)...)
+	conditions = append(conditions, milvusEmbeddingConditions(opts.EmbeddingModel, r.namespaceEmbeddings.names())...)
+	expr := strings.Join(conditions, " && ")
+
+	// 查询向量所属模型的向量字段
+	field := embeddingField(opts.EmbeddingModel)
+
+	// L2距离转换为0-1的相似度分数
+	scoreOf := func(distance float32) float64 { return float64(1.0 / (1.0 + distance)) }
+
+	var searchResults []client.SearchResult
+	skip := 0 // 结果中需要丢弃的前几条
+	if sparse := termFreqQueryVector(r.script.Convert(query)); r.config.Hybrid.Enabled && opts.Features[featureRRF] && sparse != nil {
+		// 稠密向量和词频稀疏向量两路召回，由Milvus融合排序；两路各自的offset会改变融合结果，分页时多取后丢弃
+		searchResults, err = r.hybridSearch(ctx, milvusClient, collectionName, field, queryVector, sparse, expr, offset+topK, sp)
+		scoreOf = r.config.Hybrid.normalize
+		skip = offset
+	} else {
+		// 执行搜索 - 根据最新SDK修正
+		searchResults, err = milvusClient.Search(
+			ctx,
+			collectionName,
+			nil,  // 分区列表
+			expr, // 表达式
+			[]string{"doc_id", "title", "content", "meta"},   // 输出字段
+			[]entity.Vector{entity.FloatVector(queryVector)}, // 查询向量
+			field,     // 向量字段名
+			entity.L2, // 距离度量
+			topK,      // topK
+			sp,        // 搜索参数
+			client.WithOffset(int64(offset)),
+		)
+	}
+	// gRPC返回的取消错误不是context.Canceled，请求已取消时直接返回，不计入主节点故障
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	r.recordRead(primary, err)
 
 	if err != nil {
-		return nil, fmt.Errorf("搜索失败: %w", err)
+		return nil, storeError(ctx, "搜索失败", err)
 	}
 
 	var results []SearchResult
@@ -495,15 +1457,24 @@ func (r *RAGSystem) SearchDocuments(query string, topK int) ([]SearchResult, err
 		fields := searchResult.Fields
 
 		// 遍历所有结果
-		for i := 0; i < searchResult.ResultCount; i++ {
+		for i := skip; i < searchResult.ResultCount; i++ {
 			// 获取ID、分数
 			id := idCol.Data()[i]
-			score := float64(1.0 / (1.0 + scores[i]))
+			score := scoreOf(scores[i])
 
 			// 获取标题和内容
-			var title, content string
+			var docID, title, content string
+			var meta map[string]interface{}
 			for _, field := range fields {
 				switch field.Name() {
+				case "meta":
+					if col, ok := field.(*entity.ColumnJSONBytes); ok {
+						_ = json.Unmarshal(col.Data()[i], &meta)
+					}
+				case "doc_id":
+					if col, ok := field.(*entity.ColumnVarChar); ok {
+						docID = col.Data()[i]
+					}
 				case "title":
 					if col, ok := field.(*entity.ColumnVarChar); ok {
 						title = col.Data()[i]
@@ -514,12 +1485,19 @@ func (r *RAGSystem) SearchDocuments(query string, topK int) ([]SearchResult, err
 					}
 				}
 			}
+			content, err := decodeCompressed(content)
+			if err != nil {
+				return results, fmt.Errorf("分块 %s: %w", id, err)
+			}
 
 			// 添加到结果列表
 			results = append(results, SearchResult{
+				ID:      id,
+				DocID:   docID,
 				Title:   title,
 				Content: content,
-				Score:   float32(score),
+				Score:   score,
+				Meta:    meta,
 			})
 
 			// 调试输出
@@ -530,7 +1508,29 @@ func (r *RAGSystem) SearchDocuments(query string, topK int) ([]SearchResult, err
 	return results, nil
 }
 
+// 选择读请求使用的客户端，第二个返回值表示是否为主节点
+func (r *RAGSystem) readClient() (client.Client, bool) {
+	if r.replicaClient != nil && r.failover.useReplica() {
+		return r.replicaClient, false
+	}
+	return r.milvusClient, true
+}
+
+// 记录主节点读请求结果，用于判断是否切换
+func (r *RAGSystem) recordRead(primary bool, err error) {
+	if primary {
+		r.failover.record(err)
+	}
+}
+
 func (r *RAGSystem) Close() {
+	r.failover.Close()
+	r.traces.Close()
+	if r.replicaClient != nil {
+		if err := r.replicaClient.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}
 	if r.milvusClient != nil {
 		if err := r.milvusClient.Close(); err != nil {
 			fmt.Println(err)
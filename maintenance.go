@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 存储维护配置：Milvus先落盘再压缩集合（合并小段、清除已删除的数据），ES对索引强制合并段（合并到FORCE_MERGE_SEGMENTS段）。
+// MAINTENANCE_SCHEDULE=HH:MM时serve每天在该时刻运行一次，也可以通过 POST /admin/maintenance 或 maintain 命令手动运行。
+// 本进程正在入库、最近MAINTENANCE_QUIET_MINUTES分钟内入库过，或存储端有进行中的写入（其他进程的入库）时跳过
+// （定时任务之后每隔该时长重试，手动运行可强制），同一时间只运行一次，超过MAINTENANCE_TIMEOUT_MINUTES分钟放弃等待
+type MaintenanceConfig struct {
+	Schedule    string
+	Quiet       time.Duration
+	Timeout     time.Duration
+	MaxSegments int
+}
+
+func loadMaintenanceConfig() MaintenanceConfig {
+	return MaintenanceConfig{
+		Schedule:    getEnv("MAINTENANCE_SCHEDULE", ""),
+		Quiet:       time.Duration(getEnvAsInt("MAINTENANCE_QUIET_MINUTES", 10)) * time.Minute,
+		Timeout:     time.Duration(getEnvAsInt("MAINTENANCE_TIMEOUT_MINUTES", 60)) * time.Minute,
+		MaxSegments: getEnvAsInt("FORCE_MERGE_SEGMENTS", 1),
+	}
+}
+
+// 定时维护因入库跳过后的最多重试次数
+const maintenanceRetries = 6
+
+// 一次存储维护的结果
+type maintenanceReport struct {
+	Target    string    `json:"target"`            // 集合或索引
+	Action    string    `json:"action"`            // 执行的维护操作
+	Skipped   string    `json:"skipped,omitempty"` // 跳过的原因
+	Detail    string    `json:"detail,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Seconds   float64   `json:"seconds"`
+}
+
+// 存储维护的运行状态
+type maintenanceState struct {
+	running atomic.Bool
+	mu      sync.Mutex
+	last    *maintenanceReport
+}
+
+var errMaintenanceRunning = errors.New("存储维护正在运行")
+
+func (s *maintenanceState) record(report maintenanceReport) {
+	s.mu.Lock()
+	s.last = &report
+	s.mu.Unlock()
+}
+
+func (s *maintenanceState) Last() *maintenanceReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// 正在入库时返回原因：本进程的入库计数只能看到serve自己的入库，再查询存储端进行中的写入，覆盖其他进程的入库
+func (r *RAGSystem) ingestBusy(ctx context.Context, now time.Time) (string, error) {
+	quiet := r.config.Maintenance.Quiet
+	if active := ingestCounters.active.Load(); active > 0 {
+		return fmt.Sprintf("%d 个入库正在进行", active), nil
+	}
+	if last := ingestCounters.lastIngest.Load(); last > 0 {
+		if since := now.Sub(time.Unix(0, last)); since < quiet {
+			return fmt.Sprintf("%s前刚入库，%s内不运行", since.Round(time.Second), quiet), nil
+		}
+	}
+	return r.storeActivity(ctx)
+}
+
+// 运行一次存储维护；force为false时避开入库，跳过时返回的结果带有Skipped
+func (r *RAGSystem) runMaintenance(ctx context.Context, force bool) (maintenanceReport, error) {
+	report := maintenanceReport{Target: r.storeTarget(), Action: maintenanceAction, StartedAt: time.Now()}
+	if !force {
+		reason, err := r.ingestBusy(ctx, report.StartedAt)
+		if err != nil {
+			return report, err
+		}
+		if reason != "" {
+			report.Skipped = reason
+			return report, nil
+		}
+	}
+	if !r.maintenance.running.CompareAndSwap(false, true) {
+		return report, errMaintenanceRunning
+	}
+	return r.maintain(ctx, report)
+}
+
+// 执行已抢到运行状态的维护，完成后释放运行状态并记录结果
+func (r *RAGSystem) maintain(ctx context.Context, report maintenanceReport) (maintenanceReport, error) {
+	defer r.maintenance.running.Store(false)
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.Maintenance.Timeout)
+	defer cancel()
+	detail, err := r.optimizeStore(ctx)
+	report.Detail, report.Seconds = detail, time.Since(report.StartedAt).Seconds()
+	if err != nil {
+		report.Error = err.Error()
+	}
+	r.maintenance.record(report)
+	return report, err
+}
+
+// 启动定时维护：每天在配置的时刻运行，因入库跳过时每隔MAINTENANCE_QUIET_MINUTES重试
+func (r *RAGSystem) startMaintenanceSchedule() error {
+	config := r.config.Maintenance
+	if _, err := time.Parse("15:04", config.Schedule); err != nil {
+		return fmt.Errorf("MAINTENANCE_SCHEDULE格式应为HH:MM: %w", err)
+	}
+	go func() {
+		for {
+			wait, _ := untilNextRun(config.Schedule, time.Now())
+			time.Sleep(wait)
+			for attempt := 0; ; attempt++ {
+				report, err := r.runMaintenance(context.Background(), false)
+				if err != nil {
+					log.Printf("⚠️  存储维护失败: %v", err)
+					break
+				}
+				if report.Skipped == "" {
+					log.Printf("🧹 存储维护完成（%s，%.1fs）: %s", report.Action, report.Seconds, report.Detail)
+					break
+				}
+				if attempt >= maintenanceRetries {
+					log.Printf("⚠️  存储维护多次因入库跳过，今天不再运行: %s", report.Skipped)
+					break
+				}
+				log.Printf("⏸️  存储维护跳过（%s），%s后重试", report.Skipped, config.Quiet)
+				time.Sleep(max(config.Quiet, time.Minute))
+			}
+		}
+	}()
+	return nil
+}
+
+type maintenanceRequest struct {
+	Force bool `json:"force"` // 不检查入库，立即运行
+}
+
+type maintenanceResponse struct {
+	Started bool               `json:"started"`
+	Skipped string             `json:"skipped,omitempty"` // 没有启动的原因
+	Running bool               `json:"running"`
+	Last    *maintenanceReport `json:"last,omitempty"` // 最近一次完成的维护
+}
+
+// 在后台启动维护，立即返回；进度和结果通过 GET /admin/maintenance/status 查看
+func (s *apiServer) handleMaintenance(w http.ResponseWriter, req *http.Request) {
+	var body maintenanceRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	report := maintenanceReport{Target: s.rag.storeTarget(), Action: maintenanceAction, StartedAt: time.Now()}
+	if !body.Force {
+		reason, err := s.rag.ingestBusy(req.Context(), report.StartedAt)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if reason != "" {
+			writeJSON(w, http.StatusOK, maintenanceResponse{Skipped: reason, Running: s.rag.maintenance.running.Load(), Last: s.rag.maintenance.Last()})
+			return
+		}
+	}
+	// 在返回前抢占运行状态，并发的请求只有一个启动
+	if !s.rag.maintenance.running.CompareAndSwap(false, true) {
+		writeError(w, http.StatusConflict, errMaintenanceRunning)
+		return
+	}
+	go func() {
+		report, err := s.rag.maintain(context.Background(), report)
+		if err != nil {
+			fmt.Printf("⚠️  存储维护失败: %v\n", err)
+			return
+		}
+		fmt.Printf("🧹 存储维护完成（%s，%.1fs）: %s\n", report.Action, report.Seconds, report.Detail)
+	}()
+	writeJSON(w, http.StatusAccepted, maintenanceResponse{Started: true, Running: true, Last: s.rag.maintenance.Last()})
+}
+
+func (s *apiServer) handleMaintenanceStatus(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, maintenanceResponse{Running: s.rag.maintenance.running.Load(), Last: s.rag.maintenance.Last()})
+}
+
+// maintain命令：运行一次存储维护；命令行进程看不到serve的入库计数，只检查存储端进行中的写入，-force不检查直接运行
+func runMaintain(args []string) error {
+	fs := flag.NewFlagSet("maintain", flag.ExitOnError)
+	force := fs.Bool("force", false, "不检查入库，立即运行")
+	_ = fs.Parse(args)
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	fmt.Printf("🧹 开始存储维护: %s %s\n", maintenanceAction, rag.storeTarget())
+	report, err := rag.runMaintenance(context.Background(), *force)
+	if err != nil {
+		return err
+	}
+	if report.Skipped != "" {
+		fmt.Printf("⏸️  存储维护跳过: %s（加 -force 强制运行）\n", report.Skipped)
+		return nil
+	}
+	fmt.Printf("✅ 存储维护完成，耗时 %.1fs: %s\n", report.Seconds, report.Detail)
+	return nil
+}
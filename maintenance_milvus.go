@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+const maintenanceAction = "flush+compact"
+
+func (r *RAGSystem) storeTarget() string {
+	return r.config.CollectionName
+}
+
+// 集合中尚未落盘的段说明正在或刚刚有写入（包括其他进程的入库），此时不压缩
+func (r *RAGSystem) storeActivity(ctx context.Context) (string, error) {
+	segments, err := r.milvusClient.GetPersistentSegmentInfo(ctx, r.config.CollectionName)
+	if err != nil {
+		return "", fmt.Errorf("查询集合分段失败: %w", err)
+	}
+	writing := 0
+	for _, segment := range segments {
+		if !segment.Flushed() && segment.NumRows > 0 {
+			writing++
+		}
+	}
+	if writing > 0 {
+		return fmt.Sprintf("%d 个段尚未落盘，存储端正在写入", writing), nil
+	}
+	return "", nil
+}
+
+// 落盘后手动压缩集合，等待压缩完成
+func (r *RAGSystem) optimizeStore(ctx context.Context) (string, error) {
+	name := r.config.CollectionName
+	if err := r.milvusClient.Flush(ctx, name, false); err != nil {
+		return "", fmt.Errorf("集合落盘失败: %w", err)
+	}
+	id, err := r.milvusClient.ManualCompaction(ctx, name, 0)
+	if err != nil {
+		return "", fmt.Errorf("触发集合压缩失败: %w", err)
+	}
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		state, plans, err := r.milvusClient.GetCompactionStateWithPlans(ctx, id)
+		if err != nil {
+			return "", fmt.Errorf("查询压缩任务 %d 失败: %w", id, err)
+		}
+		if state == entity.CompactionStateCompleted {
+			return fmt.Sprintf("压缩任务 %d 已完成，合并计划 %d 个", id, len(plans)), nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return "", fmt.Errorf("等待压缩任务 %d 完成超时: %w", id, ctx.Err())
+		}
+	}
+}
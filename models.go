@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 允许请求指定的对话模型：LLM_MODELS=deepseek-chat,deepseek-reasoner,qwen2.5:7b=http://localhost:11434/v1，
+// 带地址的为兼容OpenAI接口的本地模型，其余走DeepSeek；DEEPSEEK_MODEL总是允许
+type chatModel struct {
+	Name    string
+	BaseURL string // 为空时使用DeepSeek
+}
+
+func loadChatModels() []chatModel {
+	var models []chatModel
+	for _, item := range splitEnvList("LLM_MODELS") {
+		name, baseURL, _ := strings.Cut(item, "=")
+		models = append(models, chatModel{Name: strings.TrimSpace(name), BaseURL: strings.TrimSpace(baseURL)})
+	}
+	if len(models) == 0 {
+		models = []chatModel{{Name: "deepseek-chat"}, {Name: "deepseek-reasoner"}}
+	}
+	return models
+}
+
+// 本地模型各自一个客户端，LLM_LOCAL_API_KEY为空时不带密钥，不会把DeepSeek的密钥发给本地服务
+func newLocalChatClients(models []chatModel) map[string]*openai.Client {
+	clients := make(map[string]*openai.Client)
+	for _, model := range models {
+		if model.BaseURL == "" {
+			continue
+		}
+		conf := openai.DefaultConfig(getEnv("LLM_LOCAL_API_KEY", ""))
+		conf.BaseURL = model.BaseURL
+		conf.HTTPClient = &http.Client{Transport: rotatingAuth(&reasoningTransport{base: http.DefaultTransport}, "LLM_LOCAL_API_KEY", bearerAuth), Timeout: 5 * time.Minute}
+		clients[model.Name] = openai.NewClientWithConfig(conf)
+	}
+	return clients
+}
+
+// 校验请求指定的模型，不指定时使用DEEPSEEK_MODEL
+func (r *RAGSystem) resolveModel(requested string) (string, error) {
+	if requested == "" || requested == r.config.DeepSeekModel {
+		return r.config.DeepSeekModel, nil
+	}
+	names := []string{r.config.DeepSeekModel}
+	for _, model := range r.config.Models {
+		if model.Name == requested {
+			return requested, nil
+		}
+		names = append(names, model.Name)
+	}
+	return "", fmt.Errorf("模型 %s 不在允许列表中，可用: %s", requested, strings.Join(names, ", "))
+}
+
+// 模型对应的客户端
+func (r *RAGSystem) chatClient(model string) *openai.Client {
+	if client, ok := r.localClients[model]; ok {
+		return client
+	}
+	return r.openAIClient
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// 按命名空间的向量模型：EMBEDDING_NAMESPACES_FILE（JSON）为分类（命名空间）指定写入和检索使用的向量模型，
+// 例如国际wiki用多语言模型、公众号内容用中文优化的模型，没有配置的分类使用内置向量。入库时按文档分类选择模型，
+// 模型名记录在元数据embedding_model中；检索时查询向量只与同一模型写入的分块比较：请求指定或路由到分类时用该分类的模型，
+// 否则内置向量和各命名空间模型分别检索后按分数合并（分页检索只检索内置向量）。每个模型的向量按原生维度写入单独的字段vector_<模型名>，
+// 新增模型后需运行 schema 命令（go run . schema 或 go run ./es schema）补上字段（Milvus需要重建集合），再用 reembed 命令重新向量化
+type NamespaceEmbeddingConfig struct {
+	File string
+}
+
+func loadNamespaceEmbeddingConfig() NamespaceEmbeddingConfig {
+	return NamespaceEmbeddingConfig{File: getEnv("EMBEDDING_NAMESPACES_FILE", "embedding_namespaces.json")}
+}
+
+const embeddingModelKey = "embedding_model"
+
+// 文件中一个命名空间的向量模型，未填写的字段沿用EMBEDDING_*配置
+type namespaceModel struct {
+	Provider  string `json:"provider"` // hash、openai
+	Model     string `json:"model,omitempty"`
+	BaseURL   string `json:"base_url,omitempty"`
+	APIKeyEnv string `json:"api_key_env,omitempty"` // 读取API key的环境变量，默认EMBEDDING_API_KEY
+	Dim       int    `json:"dim,omitempty"`         // 模型输出的向量维度，hash默认EMBEDDING_DIM，openai必填
+}
+
+// 记录在元数据中的模型名，相同模型的命名空间共用一个向量空间
+func (m namespaceModel) name() string {
+	if m.Provider == "hash" {
+		return "hash"
+	}
+	return m.Provider + ":" + m.Model
+}
+
+// 命名空间的向量模型，同名模型共用一个向量化客户端
+type namespaceEmbeddings struct {
+	namespaces map[string]string   // 分类 -> 模型名
+	models     map[string]embedder // 模型名 -> 向量化
+	dims       map[string]int      // 模型名 -> 向量维度
+}
+
+// 加载命名空间的向量模型，文件不存在或为空时返回nil，全部使用内置向量
+func loadNamespaceEmbeddings(config NamespaceEmbeddingConfig, base EmbeddingConfig) (*namespaceEmbeddings, error) {
+	if config.File == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(config.File)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取命名空间向量模型 %s 失败: %w", config.File, err)
+	}
+	var file map[string]namespaceModel
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析命名空间向量模型 %s 失败: %w", config.File, err)
+	}
+	if len(file) == 0 {
+		return nil, nil
+	}
+
+	e := &namespaceEmbeddings{namespaces: make(map[string]string), models: make(map[string]embedder), dims: make(map[string]int)}
+	fields := make(map[string]string) // 字段名 -> 模型名
+	for _, namespace := range sortedNamespaces(file) {
+		model := file[namespace]
+		if model.Model == "" {
+			model.Model = base.Model
+		}
+		if model.Dim == 0 && model.Provider == "hash" {
+			model.Dim = base.Dim
+		}
+		if model.Dim <= 0 {
+			return nil, fmt.Errorf("命名空间 %s 的向量模型需填写dim（模型输出的向量维度）", namespace)
+		}
+		name := model.name()
+		e.namespaces[namespace] = name
+		if dim, ok := e.dims[name]; ok {
+			if dim != model.Dim {
+				return nil, fmt.Errorf("向量模型 %s 在不同命名空间中的dim不一致: %d、%d", name, dim, model.Dim)
+			}
+			continue
+		}
+		field := embeddingField(name)
+		if other, ok := fields[field]; ok {
+			return nil, fmt.Errorf("向量模型 %s 与 %s 的字段名都是 %s，请调整模型名", name, other, field)
+		}
+		fields[field] = name
+		e.dims[name] = model.Dim
+		config := EmbeddingConfig{Provider: model.Provider, BaseURL: model.BaseURL, APIKey: base.APIKey, APIKeyEnv: base.APIKeyEnv, Model: model.Model, Dim: model.Dim}
+		if config.BaseURL == "" {
+			config.BaseURL = base.BaseURL
+		}
+		if model.APIKeyEnv != "" {
+			config.APIKey, config.APIKeyEnv = getEnv(model.APIKeyEnv, ""), model.APIKeyEnv
+		}
+		if e.models[name], err = newEmbedder(config); err != nil {
+			return nil, fmt.Errorf("命名空间 %s 的向量模型: %w", namespace, err)
+		}
+	}
+	return e, nil
+}
+
+// 分类使用的模型名，使用内置向量时为空
+func (e *namespaceEmbeddings) modelOf(namespace string) string {
+	if e == nil {
+		return ""
+	}
+	return e.namespaces[namespace]
+}
+
+// 全部命名空间模型名，按名称排序
+func (e *namespaceEmbeddings) names() []string {
+	if e == nil {
+		return nil
+	}
+	names := make([]string, 0, len(e.models))
+	for name := range e.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e *namespaceEmbeddings) embed(ctx context.Context, model, text string) ([]float32, error) {
+	vector, err := e.models[model].Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if dim := e.dims[model]; len(vector) != dim {
+		return nil, fmt.Errorf("%w: 向量模型 %s 返回 %d 维，配置的dim为 %d", ErrDimensionMismatch, model, len(vector), dim)
+	}
+	return vector, nil
+}
+
+// 按名称排序的命名空间，加载时的报错与文件中的顺序无关
+func sortedNamespaces(file map[string]namespaceModel) []string {
+	namespaces := make([]string, 0, len(file))
+	for namespace := range file {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+var fieldNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// 模型向量写入的字段：内置向量为vector，命名空间模型为vector_<模型名>，模型名中字段名不支持的字符换成_
+func embeddingField(model string) string {
+	if model == "" {
+		return "vector"
+	}
+	return "vector_" + fieldNameUnsafe.ReplaceAllString(model, "_")
+}
+
+// 一个向量空间的字段
+type vectorField struct {
+	Name  string
+	Model string // 命名空间模型名，内置向量为空
+	Dim   int
+}
+
+// 内置向量和各命名空间模型的向量字段，命名空间模型按名称排序
+func (r *RAGSystem) vectorFields() []vectorField {
+	fields := []vectorField{{Name: embeddingField(""), Dim: r.embeddingDim()}}
+	for _, model := range r.namespaceEmbeddings.names() {
+		fields = append(fields, vectorField{Name: embeddingField(model), Model: model, Dim: r.namespaceEmbeddings.dims[model]})
+	}
+	return fields
+}
+
+// 模型的向量维度，模型为空时为内置向量的维度
+func (r *RAGSystem) vectorDim(model string) int {
+	if model == "" {
+		return r.embeddingDim()
+	}
+	return r.namespaceEmbeddings.dims[model]
+}
+
+// 分类的文档写入的向量维度
+func (r *RAGSystem) categoryVectorDim(category string) int {
+	return r.vectorDim(r.namespaceEmbeddings.modelOf(category))
+}
+
+// 按文档分类在元数据中记录向量模型，返回模型名；使用内置向量时去掉之前记录的模型
+func (r *RAGSystem) tagEmbeddingModel(doc *Document) string {
+	category, _ := doc.Meta["category"].(string)
+	model := r.namespaceEmbeddings.modelOf(category)
+	switch {
+	case model != "" && doc.Meta == nil:
+		doc.Meta = map[string]interface{}{embeddingModelKey: model}
+	case model != "":
+		doc.Meta[embeddingModelKey] = model
+	default:
+		delete(doc.Meta, embeddingModelKey)
+	}
+	return model
+}
+
+// 用模型向量化文本，模型为空时生成vector字段的向量：EMBEDDING_VECTOR_DIM模型或内置简化向量
+func (r *RAGSystem) embedWith(ctx context.Context, model, text string) ([]float32, error) {
+	if model == "" && r.vectorEmbedder == nil {
+		return r.generateSimpleVector(text), nil
+	}
+	if model == "" {
+		vector, err := r.vectorEmbedder.Embed(ctx, r.script.Convert(text))
+		if err != nil {
+			return nil, err
+		}
+		if dim := r.embeddingDim(); len(vector) != dim {
+			return nil, fmt.Errorf("%w: 向量模型 %s 返回 %d 维，EMBEDDING_VECTOR_DIM为 %d", ErrDimensionMismatch, r.config.Embedding.Model, len(vector), dim)
+		}
+		return vector, nil
+	}
+	return r.namespaceEmbeddings.embed(ctx, model, r.script.Convert(text))
+}
+
+// 检索单个集合/索引：没有配置命名空间模型、指定了分类或分页检索时只检索一个向量空间，
+// 否则内置向量和各命名空间模型分别检索，按分数合并后取前topK；命名空间模型检索失败时告警并忽略
+func (r *RAGSystem) searchIndex(ctx context.Context, name, query string, topK int, opts searchOptions) ([]SearchResult, error) {
+	models := r.namespaceEmbeddings.names()
+	if len(models) == 0 || opts.Category != "" || opts.Page != nil {
+		opts.EmbeddingModel = r.namespaceEmbeddings.modelOf(opts.Category)
+		return r.searchSpace(ctx, name, query, topK, opts)
+	}
+	results, err := r.searchSpace(ctx, name, query, topK, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, model := range models {
+		opts.EmbeddingModel = model
+		spaceResults, err := r.searchSpace(ctx, name, query, topK, opts)
+		if err != nil {
+			fmt.Printf("⚠️  向量模型 %s 检索失败: %v\n", model, err)
+			continue
+		}
+		results = append(results, spaceResults...)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import "github.com/milvus-io/milvus-sdk-go/v2/entity"
+
+// 按分块的向量模型拆成各向量字段的列：分块的向量写入所属模型的字段，其他向量字段填占位向量。
+// Milvus插入时每个向量字段都必须有值；各向量空间检索时按embedding_model过滤，占位向量不会被检索到
+func (r *RAGSystem) vectorColumns(models []string, vectors [][]float32) []entity.Column {
+	fields := r.vectorFields()
+	columns := make([]entity.Column, 0, len(fields))
+	for _, field := range fields {
+		placeholder := make([]float32, field.Dim)
+		placeholder[0] = 1
+		data := make([][]float32, len(vectors))
+		for i, vector := range vectors {
+			data[i] = placeholder
+			if models[i] == field.Model {
+				data[i] = vector
+			}
+		}
+		columns = append(columns, entity.NewColumnFloatVector(field.Name, field.Dim, data))
+	}
+	return columns
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 文档的修订号保存在元数据revision中，对外表示为ETag。所有替换文档的写入（PUT /documents/item、/ingest、/documents、
+// 各类同步和审核入库）都在已存储的修订号上加一，ETag随任何写入变化。更新时在If-Match中带上读到的ETag，
+// 文档在此期间已被他人修改时返回412，避免互相覆盖。
+// 同一服务实例内，所有写入从读取修订号到写入完成都持有revisionMu，按顺序加一；带版本校验的更新在写入前，
+// ES再以首个分块的_seq_no和_primary_term作条件写入，跨实例有效；Milvus没有条件写入，多个实例同时写入同一文档时仍可能互相覆盖
+const revisionKey = "revision"
+
+// 校验之后文档又被其他实例或写入方修改
+var errRevisionConflict = errors.New("文档已被修改")
+
+func metaRevision(meta map[string]interface{}) int {
+	switch v := meta[revisionKey].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+func formatETag(revision int) string {
+	return strconv.Quote(strconv.Itoa(revision))
+}
+
+// If-Match中任一ETag与当前修订号相同即匹配，*匹配任何已存在的文档
+func matchETag(ifMatch string, revision int) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || strings.Trim(tag, `"`) == strconv.Itoa(revision) {
+			return true
+		}
+	}
+	return false
+}
+
+// 写入前为每篇文档记录新的修订号：已存储的修订号加一，新文档为1。元数据复制后修改，不影响调用方的map
+func (r *RAGSystem) stampRevisions(ctx context.Context, documents []Document) error {
+	docIDs := make([]string, len(documents))
+	for i, doc := range documents {
+		docIDs[i] = doc.ID
+	}
+	current, err := r.currentRevisions(ctx, docIDs)
+	if err != nil {
+		return err
+	}
+	for i := range documents {
+		meta := make(map[string]interface{}, len(documents[i].Meta)+1)
+		for key, value := range documents[i].Meta {
+			meta[key] = value
+		}
+		meta[revisionKey] = current[documents[i].ID] + 1
+		documents[i].Meta = meta
+	}
+	return nil
+}
+
+type documentVersionResponse struct {
+	ID       string `json:"id"`
+	Exists   bool   `json:"exists"`
+	Revision int    `json:"revision"`
+	ETag     string `json:"etag"` // 更新时放在If-Match请求头中
+}
+
+// GET /documents/version?id=doc_001
+func (s *apiServer) handleDocumentVersion(w http.ResponseWriter, req *http.Request) {
+	docID := req.URL.Query().Get("id")
+	if docID == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("缺少查询参数id"))
+		return
+	}
+	revision, exists, err := s.rag.currentRevision(req.Context(), docID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if exists {
+		w.Header().Set("ETag", formatETag(revision))
+	}
+	writeJSON(w, http.StatusOK, documentVersionResponse{ID: docID, Exists: exists, Revision: revision, ETag: formatETag(revision)})
+}
+
+// PUT /documents/item?id=doc_001：更新已存在的文档必须带If-Match，不存在的文档直接创建。
+// 读取修订号到写入完成之间持有revisionMu，与同一服务内的其他更新和ReplaceDocuments按顺序校验；
+// 写入前由claimRevision按存储的条件写入再确认一次
+func (s *apiServer) handleUpdateDocument(w http.ResponseWriter, req *http.Request) {
+	docID := req.URL.Query().Get("id")
+	if docID == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("缺少查询参数id"))
+		return
+	}
+	var payload documentPayload
+	if !s.decodeDocumentsBody(w, req, &payload) {
+		return
+	}
+	if payload.ID != "" && payload.ID != docID {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("请求体中的id与查询参数id不一致"))
+		return
+	}
+	payload.ID = docID
+	consistency, err := resolveConsistency(req.URL.Query().Get("consistency"), s.rag.config.Consistency)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	documents, err := s.rag.config.DocLimits.validate([]documentPayload{payload}, s.rag.categoryVectorDim)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.rag.revisionMu.Lock()
+	defer s.rag.revisionMu.Unlock()
+
+	revision, exists, err := s.rag.currentRevision(req.Context(), docID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ifMatch := req.Header.Get("If-Match")
+	switch {
+	case exists && ifMatch == "":
+		w.Header().Set("ETag", formatETag(revision))
+		writeError(w, http.StatusPreconditionRequired, fmt.Errorf("文档 %s 已存在，更新时需要在If-Match中带上ETag", docID))
+		return
+	case exists && !matchETag(ifMatch, revision):
+		w.Header().Set("ETag", formatETag(revision))
+		writeError(w, http.StatusPreconditionFailed, fmt.Errorf("文档 %s 已被修改，当前ETag为 %s", docID, formatETag(revision)))
+		return
+	case !exists && ifMatch != "":
+		writeError(w, http.StatusPreconditionFailed, fmt.Errorf("文档 %s 不存在", docID))
+		return
+	}
+
+	if err := s.rag.claimRevision(req.Context(), docID, revision, exists); errors.Is(err, errRevisionConflict) {
+		writeError(w, http.StatusPreconditionFailed, fmt.Errorf("文档 %s 已被修改，请重新读取ETag", docID))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	doc := &documents[0]
+	meta := make(map[string]interface{}, len(doc.Meta)+1)
+	for key, value := range doc.Meta {
+		meta[key] = value
+	}
+	meta[revisionKey] = revision + 1
+	doc.Meta = meta
+	// 修订号已确定，不再经过ReplaceDocuments加一
+	if err := s.rag.rewriteDocuments(documents); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := s.rag.awaitVisible(consistency); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("ETag", formatETag(revision+1))
+	writeJSON(w, http.StatusOK, documentVersionResponse{ID: docID, Exists: true, Revision: revision + 1, ETag: formatETag(revision + 1)})
+}
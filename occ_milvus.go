@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 读取文档任一分块元数据中的修订号；强一致性读，刚写入的分块也能读到
+func (r *RAGSystem) currentRevision(ctx context.Context, docID string) (int, bool, error) {
+	collectionName := r.config.CollectionName
+	if err := r.milvusClient.LoadCollection(ctx, collectionName, false); err != nil {
+		return 0, false, fmt.Errorf("加载集合失败: %w", err)
+	}
+	resultSet, err := r.milvusClient.Query(ctx, collectionName, nil, fmt.Sprintf("doc_id == %q", docID), []string{"meta"},
+		client.WithLimit(1), client.WithSearchQueryConsistencyLevel(entity.ClStrong))
+	if err != nil {
+		return 0, false, fmt.Errorf("查询文档修订号失败: %w", err)
+	}
+	metaCol, ok := resultSet.GetColumn("meta").(*entity.ColumnJSONBytes)
+	if !ok || metaCol.Len() == 0 {
+		return 0, false, nil
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal(metaCol.Data()[0], &meta); err != nil {
+		return 0, false, fmt.Errorf("解析文档 %s 元数据失败: %w", docID, err)
+	}
+	return metaRevision(meta), true, nil
+}
+
+// 批量读取文档的修订号：按首个分块的主键查询，强一致性读；不存在的文档不在结果中
+func (r *RAGSystem) currentRevisions(ctx context.Context, docIDs []string) (map[string]int, error) {
+	revisions := make(map[string]int, len(docIDs))
+	if len(docIDs) == 0 {
+		return revisions, nil
+	}
+	collectionName := r.config.CollectionName
+	if err := r.milvusClient.LoadCollection(ctx, collectionName, false); err != nil {
+		return nil, fmt.Errorf("加载集合失败: %w", err)
+	}
+	quoted := make([]string, len(docIDs))
+	for i, docID := range docIDs {
+		quoted[i] = fmt.Sprintf("%q", docID+"#0")
+	}
+	resultSet, err := r.milvusClient.Query(ctx, collectionName, nil, fmt.Sprintf("id in [%s]", strings.Join(quoted, ", ")), []string{"doc_id", "meta"},
+		client.WithSearchQueryConsistencyLevel(entity.ClStrong))
+	if err != nil {
+		return nil, fmt.Errorf("查询文档修订号失败: %w", err)
+	}
+	docIDCol, ok := resultSet.GetColumn("doc_id").(*entity.ColumnVarChar)
+	if !ok {
+		return revisions, nil
+	}
+	metaCol, ok := resultSet.GetColumn("meta").(*entity.ColumnJSONBytes)
+	if !ok {
+		return nil, fmt.Errorf("meta列类型错误")
+	}
+	for i, docID := range docIDCol.Data() {
+		var meta map[string]interface{}
+		if err := json.Unmarshal(metaCol.Data()[i], &meta); err != nil {
+			return nil, fmt.Errorf("解析文档 %s 元数据失败: %w", docID, err)
+		}
+		revisions[docID] = metaRevision(meta)
+	}
+	return revisions, nil
+}
+
+// Milvus没有条件写入，校验之后的并发写入只靠revisionMu在同一实例内排除
+func (r *RAGSystem) claimRevision(ctx context.Context, docID string, revision int, exists bool) error {
+	return nil
+}
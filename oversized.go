@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 超出上下文预算的分块：单个分块的token数就超过CONTEXT_TOKEN_BUDGET，多半是入库时分块出了问题（例如推送了没有分块的长文）。
+// 生成回答时把这类分块按CHUNK_SIZE重新切分，只把与问题最相关的片段放进提示词；同时告警并把分块追加到OVERSIZED_CHUNK_LOG，
+// 由rechunk命令汇总为需要重新分块的文档
+type oversizedChunk struct {
+	ChunkID string    `json:"chunk_id"`
+	DocID   string    `json:"doc_id"`
+	Title   string    `json:"title"`
+	Tokens  int       `json:"tokens"`
+	Budget  int       `json:"budget"`
+	SeenAt  time.Time `json:"seen_at"`
+}
+
+// 超大分块记录，同一进程内每个分块只告警和记录一次
+type oversizedLog struct {
+	path string
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newOversizedLog(path string) *oversizedLog {
+	return &oversizedLog{path: path, seen: make(map[string]bool)}
+}
+
+func (l *oversizedLog) flag(chunk oversizedChunk) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seen[chunk.ChunkID] {
+		return
+	}
+	l.seen[chunk.ChunkID] = true
+	fmt.Printf("⚠️  分块 %s 有 %d tokens，超出上下文预算 %d，已临时重新切分；文档 %s 需要重新分块\n", chunk.ChunkID, chunk.Tokens, chunk.Budget, chunk.DocID)
+	if l.path == "" {
+		return
+	}
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		fmt.Printf("⚠️  记录超大分块失败: %v\n", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		fmt.Printf("⚠️  记录超大分块失败: %v\n", err)
+	}
+}
+
+// 放进提示词的分块：超出上下文预算的分块换成重新切分后与问题最相关的片段，每个这样的分块最多占预算的1/len(results)。
+// 返回副本，检索结果本身（回答的来源）不变
+func (r *RAGSystem) fitOversized(ctx context.Context, question string, results []SearchResult) []SearchResult {
+	budget := r.settings().Retrieval.TokenBudget
+	var fitted []SearchResult
+	for i, result := range results {
+		tokens := r.tokens.Count(result.Content)
+		if tokens <= budget {
+			continue
+		}
+		if fitted == nil {
+			fitted = append([]SearchResult(nil), results...)
+		}
+		// 对话历史不需要重新分块
+		if result.DocID != historyDocID {
+			r.oversized.flag(oversizedChunk{ChunkID: result.ID, DocID: result.DocID, Title: result.Title, Tokens: tokens, Budget: budget, SeenAt: time.Now()})
+		}
+		fitted[i].Content = r.resplitChunk(ctx, question, result.Content, budget/len(results))
+	}
+	if fitted == nil {
+		return results
+	}
+	return fitted
+}
+
+// 按CHUNK_SIZE重新切分，按与问题的相似度选取片段直到用完预算，至少保留一个片段；选出的片段按原文顺序拼接。
+// 向量化失败时按原文顺序选取
+func (r *RAGSystem) resplitChunk(ctx context.Context, question, content string, budget int) string {
+	pieces := splitDocument(Document{Content: content}, r.config.ChunkSize)
+	scores := make([]float64, len(pieces))
+	if questionVector, err := r.embedder.Embed(ctx, question); err == nil {
+		for i, piece := range pieces {
+			vector, err := r.embedder.Embed(ctx, piece.Content)
+			if err != nil {
+				scores = make([]float64, len(pieces))
+				break
+			}
+			scores[i] = cosineSimilarity(questionVector, vector)
+		}
+	}
+	order := make([]int, len(pieces))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	var selected []int
+	used := 0
+	for _, i := range order {
+		tokens := r.tokens.Count(pieces[i].Content)
+		if len(selected) > 0 && used+tokens > budget {
+			continue
+		}
+		selected = append(selected, i)
+		used += tokens
+	}
+	sort.Ints(selected)
+	parts := make([]string, len(selected))
+	for i, index := range selected {
+		parts[i] = pieces[index].Content
+	}
+	return strings.Join(parts, "\n……\n")
+}
+
+// 需要重新分块的文档
+type rechunkDocument struct {
+	DocID     string
+	Title     string
+	Chunks    []string
+	MaxTokens int
+	LastSeen  time.Time
+}
+
+// 读取超大分块记录并按文档汇总，按最大token数从大到小排列
+func loadOversizedDocuments(path string) ([]rechunkDocument, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取超大分块记录失败: %w", err)
+	}
+	defer file.Close()
+
+	index := make(map[string]int)
+	var docs []rechunkDocument
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var chunk oversizedChunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			continue
+		}
+		i, ok := index[chunk.DocID]
+		if !ok {
+			i = len(docs)
+			index[chunk.DocID] = i
+			docs = append(docs, rechunkDocument{DocID: chunk.DocID, Title: chunk.Title})
+		}
+		doc := &docs[i]
+		if !containsString(doc.Chunks, chunk.ChunkID) {
+			doc.Chunks = append(doc.Chunks, chunk.ChunkID)
+		}
+		doc.MaxTokens = max(doc.MaxTokens, chunk.Tokens)
+		if chunk.SeenAt.After(doc.LastSeen) {
+			doc.LastSeen = chunk.SeenAt
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取超大分块记录失败: %w", err)
+	}
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].MaxTokens > docs[j].MaxTokens })
+	return docs, nil
+}
+
+// 从记录中去掉已重新分块的文档
+func removeOversizedDocuments(path string, docIDs []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var kept []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var chunk oversizedChunk
+		if json.Unmarshal([]byte(line), &chunk) == nil && containsString(docIDs, chunk.DocID) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if len(kept) == 0 {
+		return os.Remove(path)
+	}
+	return os.WriteFile(path, []byte(strings.Join(kept, "\n")+"\n"), 0o644)
+}
+
+// rechunk命令：列出分块超出上下文预算、需要重新分块的文档；-apply 从原文存储读取原文按当前CHUNK_SIZE重新入库
+func runRechunk(args []string) error {
+	fs := flag.NewFlagSet("rechunk", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "从原文存储（BLOB_STORE）读取原文，按当前CHUNK_SIZE重新分块入库")
+	_ = fs.Parse(args)
+
+	config := loadConfig()
+	docs, err := loadOversizedDocuments(config.OversizedLog)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		fmt.Println("✅ 没有超出上下文预算的分块")
+		return nil
+	}
+	fmt.Printf("📏 %d 篇文档的分块超出上下文预算（CONTEXT_TOKEN_BUDGET=%d）:\n", len(docs), config.Retrieval.TokenBudget)
+	for _, doc := range docs {
+		fmt.Printf("  - %s %s（%d 个分块，最大 %d tokens，最近出现 %s）\n", doc.DocID, doc.Title, len(doc.Chunks), doc.MaxTokens, doc.LastSeen.Format(time.RFC3339))
+	}
+	if !*apply {
+		fmt.Println("💡 使用 -apply 从原文存储重新分块，没有原文的文档需要重新推送")
+		return nil
+	}
+
+	rag, err := NewRAGSystem(config)
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	ctx := context.Background()
+	var done []string
+	for _, doc := range docs {
+		original, err := rag.loadOriginal(ctx, doc.DocID)
+		if err != nil {
+			fmt.Printf("⏭️  %s 无法读取原文，需要重新推送: %v\n", doc.DocID, err)
+			continue
+		}
+		if err := rag.ReplaceDocuments([]Document{{ID: doc.DocID, Title: original.Title, Content: original.Content, Meta: original.Meta}}); err != nil {
+			fmt.Printf("❌ %s 重新分块失败: %v\n", doc.DocID, err)
+			continue
+		}
+		done = append(done, doc.DocID)
+		fmt.Printf("✂️  %s 已重新分块\n", doc.DocID)
+	}
+	if len(done) > 0 {
+		if err := removeOversizedDocuments(config.OversizedLog, done); err != nil {
+			return fmt.Errorf("更新超大分块记录失败: %w", err)
+		}
+	}
+	fmt.Printf("📊 已重新分块 %d 篇，剩余 %d 篇\n", len(done), len(docs)-len(done))
+	return nil
+}
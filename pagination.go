@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 分页检索的单页上限，以及from+size的上限（ES默认的max_result_window，Milvus要求offset+limit不超过16384）
+const (
+	maxPageSize   = 100
+	maxPageWindow = 10000
+)
+
+// 分页检索的位置：按检索结果的原始排序翻页，不做自适应截取和单文档配额，保证各页不重不漏。
+// ES精确检索按(_score, id)排序，带After时用search_after续翻，其余情况按Offset跳过
+type searchPage struct {
+	Offset int           `json:"offset"`
+	After  []interface{} `json:"after,omitempty"` // 上一页最后一条的排序值
+	next   []interface{} // 本页最后一条的排序值，由ES检索填写
+}
+
+// 游标是searchPage的base64编码，调用方原样传回即可
+func encodeCursor(page searchPage) string {
+	data, _ := json.Marshal(page)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (searchPage, error) {
+	var page searchPage
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return page, fmt.Errorf("无效的cursor")
+	}
+	if err := json.Unmarshal(data, &page); err != nil || page.Offset < 0 {
+		return page, fmt.Errorf("无效的cursor")
+	}
+	return page, nil
+}
+
+// 校验分页参数，cursor优先于from
+func (r *RAGSystem) newSearchPage(from, size int, cursor string) (*searchPage, error) {
+	if len(r.config.Federation) > 0 {
+		return nil, fmt.Errorf("联合检索不支持分页")
+	}
+	if size <= 0 || size > maxPageSize {
+		return nil, fmt.Errorf("分页检索的size需在1到%d之间", maxPageSize)
+	}
+	if from < 0 {
+		return nil, fmt.Errorf("from不能为负数")
+	}
+	page := searchPage{Offset: from}
+	if cursor != "" {
+		var err error
+		if page, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+	if page.Offset+size > maxPageWindow {
+		return nil, fmt.Errorf("分页检索最多翻到第%d条结果", maxPageWindow)
+	}
+	return &page, nil
+}
+
+// 检索一页分块，返回下一页的游标，没有更多结果时为空。
+// 分数保持检索原值，只标注可信度不按可信度重排，否则各页之间的顺序会错乱
+func (r *RAGSystem) searchPage(ctx context.Context, question string, size int, page *searchPage, opts searchOptions) ([]SearchResult, string, error) {
+	opts.Page = page
+	results, err := r.searchScope(ctx, r.searchQuery(ctx, question), size, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(results) == size {
+		next = encodeCursor(searchPage{Offset: page.Offset + size, After: page.next})
+	}
+	for i := range results {
+		results[i].Trust = r.config.Trust.weight(results[i].Meta)
+	}
+	results = applyLicense(results, r.config.License)
+	results = dropExpired(results, time.Now())
+	r.linkOriginals(results)
+	r.linkLocations(results)
+	return results, next, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 批量写入的缓冲区超过该大小时不放回池中，避免一次超大入库后长期占用内存
+const maxPooledBuffer = 16 << 20
+
+// 入库内存计数
+var ingestCounters struct {
+	chunksHeld atomic.Int64 // 已编码、尚未写入存储的分块数
+	bufferNew  atomic.Int64 // 新分配的批量缓冲区数
+	bufferGets atomic.Int64 // 从池中取缓冲区的次数
+	active     atomic.Int64 // 进行中的入库数
+	lastIngest atomic.Int64 // 最近一次入库结束的时间（UnixNano）
+}
+
+// 标记一次入库开始，返回的函数标记结束；存储维护据此避开入库
+func trackIngest() func() {
+	ingestCounters.active.Add(1)
+	return func() {
+		ingestCounters.active.Add(-1)
+		ingestCounters.lastIngest.Store(time.Now().UnixNano())
+	}
+}
+
+var bulkBufferPool = sync.Pool{
+	New: func() interface{} {
+		ingestCounters.bufferNew.Add(1)
+		return new(bytes.Buffer)
+	},
+}
+
+func getBulkBuffer() *bytes.Buffer {
+	ingestCounters.bufferGets.Add(1)
+	buffer := bulkBufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	return buffer
+}
+
+func putBulkBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBuffer {
+		return
+	}
+	bulkBufferPool.Put(buffer)
+}
+
+// 入库的一个阶段的耗时和内存分配
+type stageProfile struct {
+	Stage      string  `json:"stage"`
+	Seconds    float64 `json:"seconds"`
+	AllocBytes uint64  `json:"alloc_bytes"` // 累计分配的字节数
+	Mallocs    uint64  `json:"mallocs"`     // 累计分配的对象数
+}
+
+// 分阶段统计，同名阶段（如多个批次的编码和写入）累加；PROFILING关闭时不读取内存统计
+type stageProfiler struct {
+	enabled bool
+	stages  []stageProfile
+	last    time.Time
+	memory  runtime.MemStats
+}
+
+func newStageProfiler(enabled bool) *stageProfiler {
+	p := &stageProfiler{enabled: enabled}
+	if enabled {
+		p.last = time.Now()
+		runtime.ReadMemStats(&p.memory)
+	}
+	return p
+}
+
+// 记录上一个标记点到现在的开销，归入stage
+func (p *stageProfiler) Mark(stage string) {
+	if !p.enabled {
+		return
+	}
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	now := time.Now()
+	elapsed, alloc, mallocs := now.Sub(p.last).Seconds(), memory.TotalAlloc-p.memory.TotalAlloc, memory.Mallocs-p.memory.Mallocs
+	p.last, p.memory = now, memory
+
+	for i := range p.stages {
+		if p.stages[i].Stage == stage {
+			p.stages[i].Seconds += elapsed
+			p.stages[i].AllocBytes += alloc
+			p.stages[i].Mallocs += mallocs
+			return
+		}
+	}
+	p.stages = append(p.stages, stageProfile{Stage: stage, Seconds: elapsed, AllocBytes: alloc, Mallocs: mallocs})
+}
+
+// 最近一次入库的分阶段统计，供 /admin/stats 查看
+var lastIngestProfile struct {
+	sync.Mutex
+	stages []stageProfile
+}
+
+// 输出并保存分阶段统计
+func (p *stageProfiler) Finish() {
+	if !p.enabled || len(p.stages) == 0 {
+		return
+	}
+	fmt.Println("📈 入库分阶段开销:")
+	for _, stage := range p.stages {
+		fmt.Printf("  - %s: %.2fs，分配 %s（%d 个对象）\n", stage.Stage, stage.Seconds, formatBytes(int64(stage.AllocBytes)), stage.Mallocs)
+	}
+	lastIngestProfile.Lock()
+	lastIngestProfile.stages = p.stages
+	lastIngestProfile.Unlock()
+}
+
+// 运行时内存状态
+type memoryStats struct {
+	HeapAlloc  uint64         `json:"heap_alloc"`
+	HeapInuse  uint64         `json:"heap_inuse"`
+	NumGC      uint32         `json:"num_gc"`
+	Goroutines int            `json:"goroutines"`
+	ChunksHeld int64          `json:"chunks_held"`
+	BufferNew  int64          `json:"buffer_new"`
+	BufferGets int64          `json:"buffer_gets"`
+	LastIngest []stageProfile `json:"last_ingest,omitempty"`
+}
+
+func currentMemoryStats() memoryStats {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	lastIngestProfile.Lock()
+	defer lastIngestProfile.Unlock()
+	return memoryStats{
+		HeapAlloc:  memory.HeapAlloc,
+		HeapInuse:  memory.HeapInuse,
+		NumGC:      memory.NumGC,
+		Goroutines: runtime.NumGoroutine(),
+		ChunksHeld: ingestCounters.chunksHeld.Load(),
+		BufferNew:  ingestCounters.bufferNew.Load(),
+		BufferGets: ingestCounters.bufferGets.Load(),
+		LastIngest: lastIngestProfile.stages,
+	}
+}
+
+// 挂载pprof接口，只应在内网或开发环境开启
+func withPprof(handler http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
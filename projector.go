@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 向量空间中的一个分块
+type embeddingPoint struct {
+	ID       string
+	DocID    string
+	Title    string
+	Category string
+	Vector   []float32
+}
+
+// 导出的可视化数据，供网页端按坐标绘制散点图
+type projection struct {
+	Method     string               `json:"method"`              // pca、none
+	Dims       int                  `json:"dims"`                // 坐标维数，none时为原始向量维数
+	Explained  []float64            `json:"explained,omitempty"` // 各主成分解释的方差比例
+	Categories []projectionCategory `json:"categories"`
+	Points     []projectionPoint    `json:"points"`
+}
+
+type projectionCategory struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	Count int    `json:"count"`
+}
+
+type projectionPoint struct {
+	ID       string    `json:"id"`
+	DocID    string    `json:"doc_id"`
+	Title    string    `json:"title"`
+	Category string    `json:"category"`
+	Color    string    `json:"color"`
+	Coords   []float64 `json:"coords"`
+	Distance float64   `json:"distance"` // 与所属分类向量中心的余弦距离，越大越可能是离群点
+}
+
+// 没有分类的分块归入的分类名
+const uncategorized = "(未分类)"
+
+// 分类颜色，按分块数从多到少依次分配，超出后循环使用
+var projectionPalette = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+// projector命令：导出分块向量用于可视化检查聚类和离群点。-method pca 在本地降到-dims维，
+// -method none 导出原始向量，由TensorBoard Projector计算PCA、UMAP或t-SNE。-format json写出embeddings.json
+// （坐标、分类和颜色，供网页端绘制），tsv写出TensorBoard Projector可加载的vectors.tsv和metadata.tsv。
+// 不同向量模型的分块不可比较，只导出一个向量空间：不指定-category时为内置向量，否则为该分类使用的模型
+func runProjector(args []string) error {
+	fs := flag.NewFlagSet("projector", flag.ExitOnError)
+	out := fs.String("out", "projector", "输出目录")
+	format := fs.String("format", "json", "输出格式: json（网页端）或 tsv（TensorBoard Projector）")
+	method := fs.String("method", "pca", "降维方式: pca 或 none（导出原始向量）")
+	dims := fs.Int("dims", 2, "PCA降到的维数: 2 或 3")
+	category := fs.String("category", "", "只导出该分类的分块")
+	limit := fs.Int("limit", 5000, "最多导出的分块数")
+	outliers := fs.Int("outliers", 10, "打印离所属分类中心最远的分块数")
+	_ = fs.Parse(args)
+
+	if *format != "json" && *format != "tsv" {
+		return fmt.Errorf("未知的format: %s，可选 json、tsv", *format)
+	}
+	if *method != "pca" && *method != "none" {
+		return fmt.Errorf("未知的method: %s，可选 pca、none", *method)
+	}
+	if *dims != 2 && *dims != 3 {
+		return fmt.Errorf("dims应为2或3")
+	}
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	points, err := rag.embeddingPoints(context.Background(), *category, *limit)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("没有可导出的分块")
+	}
+	fmt.Printf("🧭 读取 %d 个分块的向量（%d 维）\n", len(points), len(points[0].Vector))
+
+	result := buildProjection(points, *method, *dims)
+	if len(result.Explained) > 0 {
+		fmt.Printf("📐 PCA前%d个主成分解释的方差: %s\n", len(result.Explained), formatExplained(result.Explained))
+	}
+	printOutliers(result.Points, *outliers)
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	if *format == "tsv" {
+		return writeProjectorTSV(*out, result)
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(*out, "embeddings.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("保存 %s 失败: %w", path, err)
+	}
+	fmt.Printf("✅ 已导出: %s\n", path)
+	return nil
+}
+
+// 降维并按分类着色，计算每个分块与所属分类中心的距离
+func buildProjection(points []embeddingPoint, method string, dims int) *projection {
+	vectors := make([][]float32, len(points))
+	for i, point := range points {
+		vectors[i] = point.Vector
+	}
+	result := &projection{Method: method}
+	var coords [][]float64
+	if method == "pca" {
+		coords, result.Explained = pca(vectors, dims)
+		result.Dims = len(result.Explained)
+	} else {
+		coords = make([][]float64, len(vectors))
+		for i, vector := range vectors {
+			coords[i] = make([]float64, len(vector))
+			for j, v := range vector {
+				coords[i][j] = float64(v)
+			}
+		}
+		result.Dims = len(vectors[0])
+	}
+
+	counts := make(map[string]int)
+	members := make(map[string][][]float32)
+	for _, point := range points {
+		name := pointCategory(point)
+		counts[name]++
+		members[name] = append(members[name], point.Vector)
+	}
+	colors := make(map[string]string)
+	centroids := make(map[string][]float64)
+	for name, count := range counts {
+		result.Categories = append(result.Categories, projectionCategory{Name: name, Count: count})
+		centroids[name] = centroid(members[name])
+	}
+	sort.Slice(result.Categories, func(i, j int) bool {
+		a, b := result.Categories[i], result.Categories[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Name < b.Name)
+	})
+	for i := range result.Categories {
+		result.Categories[i].Color = projectionPalette[i%len(projectionPalette)]
+		colors[result.Categories[i].Name] = result.Categories[i].Color
+	}
+
+	result.Points = make([]projectionPoint, len(points))
+	for i, point := range points {
+		name := pointCategory(point)
+		result.Points[i] = projectionPoint{
+			ID: point.ID, DocID: point.DocID, Title: point.Title, Category: name, Color: colors[name],
+			Coords: coords[i], Distance: 1 - cosine(point.Vector, centroids[name]),
+		}
+	}
+	return result
+}
+
+func pointCategory(point embeddingPoint) string {
+	if point.Category == "" {
+		return uncategorized
+	}
+	return point.Category
+}
+
+func centroid(vectors [][]float32) []float64 {
+	sum := make([]float64, len(vectors[0]))
+	for _, vector := range vectors {
+		for j, v := range vector {
+			sum[j] += float64(v)
+		}
+	}
+	for j := range sum {
+		sum[j] /= float64(len(vectors))
+	}
+	return sum
+}
+
+func cosine(a []float32, b []float64) float64 {
+	var product, na, nb float64
+	for i := range a {
+		product += float64(a[i]) * b[i]
+		na += float64(a[i]) * float64(a[i])
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return product / math.Sqrt(na*nb)
+}
+
+// 主成分分析：中心化后用幂迭代依次求前dims个主成分，每求出一个就从数据中减去该方向的分量；
+// 返回各点在主成分上的坐标和各主成分解释的方差比例
+func pca(vectors [][]float32, dims int) ([][]float64, []float64) {
+	n, d := len(vectors), len(vectors[0])
+	dims = min(dims, d)
+	mean := centroid(vectors)
+	data := make([][]float64, n)
+	var total float64
+	for i, vector := range vectors {
+		data[i] = make([]float64, d)
+		for j, v := range vector {
+			data[i][j] = float64(v) - mean[j]
+			total += data[i][j] * data[i][j]
+		}
+	}
+
+	coords := make([][]float64, n)
+	for i := range coords {
+		coords[i] = make([]float64, dims)
+	}
+	explained := make([]float64, dims)
+	random := rand.New(rand.NewSource(1))
+	for k := 0; k < dims; k++ {
+		component := make([]float64, d)
+		for j := range component {
+			component[j] = random.Float64() - 0.5
+		}
+		normalizeUnit(component)
+		for iter := 0; iter < 200; iter++ {
+			next := make([]float64, d)
+			for _, row := range data {
+				projected := dot(row, component)
+				for j, v := range row {
+					next[j] += projected * v
+				}
+			}
+			if normalizeUnit(next) == 0 {
+				break
+			}
+			converged := math.Abs(dot(next, component)) > 1-1e-10
+			component = next
+			if converged {
+				break
+			}
+		}
+
+		var variance float64
+		for i, row := range data {
+			projected := dot(row, component)
+			coords[i][k] = projected
+			variance += projected * projected
+			for j := range row {
+				row[j] -= projected * component[j]
+			}
+		}
+		if total > 0 {
+			explained[k] = variance / total
+		}
+	}
+	return coords, explained
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// 归一化为单位向量，返回原来的长度
+func normalizeUnit(v []float64) float64 {
+	norm := math.Sqrt(dot(v, v))
+	if norm == 0 {
+		return 0
+	}
+	for i := range v {
+		v[i] /= norm
+	}
+	return norm
+}
+
+func formatExplained(explained []float64) string {
+	parts := make([]string, len(explained))
+	for i, ratio := range explained {
+		parts[i] = fmt.Sprintf("PC%d %s", i+1, percent(ratio))
+	}
+	return strings.Join(parts, "，")
+}
+
+// 打印离所属分类中心最远的分块
+func printOutliers(points []projectionPoint, n int) {
+	if n <= 0 {
+		return
+	}
+	sorted := append([]projectionPoint(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Distance > sorted[j].Distance })
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	fmt.Println("🔍 离所属分类中心最远的分块:")
+	for _, point := range sorted {
+		fmt.Printf("  %.3f  %s  [%s] %s\n", point.Distance, point.ID, point.Category, point.Title)
+	}
+}
+
+// 写出TensorBoard Projector的向量和元数据文件，元数据第一行为表头，可按category着色
+func writeProjectorTSV(dir string, result *projection) error {
+	var vectors, metadata strings.Builder
+	metadata.WriteString("id\tdoc_id\ttitle\tcategory\tdistance\n")
+	for _, point := range result.Points {
+		values := make([]string, len(point.Coords))
+		for i, v := range point.Coords {
+			values[i] = strconv.FormatFloat(v, 'g', 6, 64)
+		}
+		vectors.WriteString(strings.Join(values, "\t") + "\n")
+		fields := []string{point.ID, point.DocID, point.Title, point.Category, formatRatio(point.Distance)}
+		for i, field := range fields {
+			fields[i] = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(field)
+		}
+		metadata.WriteString(strings.Join(fields, "\t") + "\n")
+	}
+	for name, content := range map[string]string{"vectors.tsv": vectors.String(), "metadata.tsv": metadata.String()} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			return fmt.Errorf("保存 %s 失败: %w", name, err)
+		}
+	}
+	fmt.Printf("✅ 已导出: %s、%s\n", filepath.Join(dir, "vectors.tsv"), filepath.Join(dir, "metadata.tsv"))
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 读取一个向量空间中最多limit个分块的向量，指定分类时只读取该分类
+func (r *RAGSystem) embeddingPoints(ctx context.Context, category string, limit int) ([]embeddingPoint, error) {
+	if err := r.milvusClient.LoadCollection(ctx, r.config.CollectionName, false); err != nil {
+		return nil, fmt.Errorf("加载集合失败: %w", err)
+	}
+	conditions := []string{`id != ""`}
+	if category != "" {
+		conditions = append(conditions, fmt.Sprintf("meta[\"category\"] == %q", category))
+	}
+	model := r.namespaceEmbeddings.modelOf(category)
+	conditions = append(conditions, milvusEmbeddingConditions(model, r.namespaceEmbeddings.names())...)
+	field := embeddingField(model)
+	resultSet, err := r.milvusClient.Query(ctx, r.config.CollectionName, nil, strings.Join(conditions, " && "),
+		[]string{"id", "doc_id", "title", "meta", field}, client.WithLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("读取向量失败: %w", err)
+	}
+	idCol, ok := resultSet.GetColumn("id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("ID列类型错误")
+	}
+	docIDCol, ok := resultSet.GetColumn("doc_id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("doc_id列类型错误")
+	}
+	titleCol, ok := resultSet.GetColumn("title").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("title列类型错误")
+	}
+	metaCol, ok := resultSet.GetColumn("meta").(*entity.ColumnJSONBytes)
+	if !ok {
+		return nil, fmt.Errorf("meta列类型错误")
+	}
+	vectorCol, ok := resultSet.GetColumn(field).(*entity.ColumnFloatVector)
+	if !ok {
+		return nil, fmt.Errorf("%s列类型错误", field)
+	}
+
+	points := make([]embeddingPoint, idCol.Len())
+	for i, id := range idCol.Data() {
+		var meta map[string]interface{}
+		_ = json.Unmarshal(metaCol.Data()[i], &meta)
+		points[i] = embeddingPoint{ID: id, DocID: docIDCol.Data()[i], Title: titleCol.Data()[i], Vector: vectorCol.Data()[i]}
+		points[i].Category, _ = meta["category"].(string)
+	}
+	return points, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 查询日志配置：QUERY_LOG_DB不为空时serve把每次问答的问题、最相关分块的分数、引用的文档和用户反馈写入SQLite，
+// 供gaps命令挖掘知识缺口。与检索轨迹不同，查询日志保存问题原文，默认关闭
+type QueryLogConfig struct {
+	DB string
+}
+
+func loadQueryLogConfig() QueryLogConfig {
+	return QueryLogConfig{DB: getEnv("QUERY_LOG_DB", "")}
+}
+
+// 一次问答的记录
+type queryRecord struct {
+	ID        int64
+	AskedAt   time.Time
+	Question  string
+	Category  string
+	BestScore float64  // 最相关分块的分数，没有检索到分块时为0
+	DocIDs    []string // 引用的文档
+	Cached    bool
+	Degraded  []string
+	Feedback  int // 1有帮助，-1没有帮助，0未反馈
+	Comment   string
+}
+
+// 查询日志，存储在SQLite中
+type queryLog struct {
+	db     *sql.DB
+	cipher *fieldCipher // 加密问题和反馈说明，未配置时为nil
+}
+
+var errQueryNotFound = errors.New("查询记录不存在")
+
+// 未配置QUERY_LOG_DB时返回nil
+func openQueryLog(config QueryLogConfig, c *fieldCipher) (*queryLog, error) {
+	if config.DB == "" {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite3", config.DB)
+	if err != nil {
+		return nil, fmt.Errorf("打开查询日志失败: %w", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS queries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		asked_at INTEGER NOT NULL,
+		question TEXT NOT NULL,
+		category TEXT NOT NULL,
+		best_score REAL NOT NULL,
+		doc_ids TEXT NOT NULL,
+		cached INTEGER NOT NULL,
+		degraded TEXT NOT NULL,
+		feedback INTEGER NOT NULL DEFAULT 0,
+		comment TEXT NOT NULL DEFAULT ''
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化查询日志失败: %w", err)
+	}
+	return &queryLog{db: db, cipher: c}, nil
+}
+
+func (l *queryLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.db.Close()
+}
+
+// 记录一次问答，返回记录ID；未开启查询日志时返回0
+func (l *queryLog) Record(question, category string, sources []SearchResult, cached bool, degraded []string) (int64, error) {
+	if l == nil {
+		return 0, nil
+	}
+	var best float64
+	if len(sources) > 0 {
+		best = bestScore(sources)
+	}
+	docIDs, err := json.Marshal(sourceDocIDs(sources))
+	if err != nil {
+		return 0, err
+	}
+	res, err := l.db.Exec(
+		`INSERT INTO queries (asked_at, question, category, best_score, doc_ids, cached, degraded) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().Unix(), l.cipher.Seal(question), category, best, string(docIDs), cached, strings.Join(degraded, ","),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("写入查询日志失败: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// 记录用户对回答的反馈，重复反馈时以最后一次为准
+func (l *queryLog) Feedback(id int64, helpful bool, comment string) error {
+	feedback := -1
+	if helpful {
+		feedback = 1
+	}
+	res, err := l.db.Exec(`UPDATE queries SET feedback = ?, comment = ? WHERE id = ?`, feedback, l.cipher.Seal(comment), id)
+	if err != nil {
+		return fmt.Errorf("写入反馈失败: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errQueryNotFound
+	}
+	return nil
+}
+
+// since之后分数低于minScore或收到负面反馈的问答，按时间升序
+func (l *queryLog) Gaps(since time.Time, minScore float64) ([]queryRecord, error) {
+	rows, err := l.db.Query(
+		`SELECT id, asked_at, question, category, best_score, doc_ids, cached, degraded, feedback, comment FROM queries
+		WHERE asked_at >= ? AND (best_score < ? OR feedback < 0) ORDER BY asked_at`,
+		since.Unix(), minScore,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询查询日志失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []queryRecord
+	for rows.Next() {
+		var record queryRecord
+		var askedAt int64
+		var docIDs, degraded string
+		if err := rows.Scan(&record.ID, &askedAt, &record.Question, &record.Category, &record.BestScore, &docIDs, &record.Cached, &degraded, &record.Feedback, &record.Comment); err != nil {
+			return nil, fmt.Errorf("读取查询日志失败: %w", err)
+		}
+		if err := l.cipher.OpenAll(&record.Question, &record.Comment); err != nil {
+			return nil, fmt.Errorf("读取查询日志 %d 失败: %w", record.ID, err)
+		}
+		record.AskedAt = time.Unix(askedAt, 0)
+		_ = json.Unmarshal([]byte(docIDs), &record.DocIDs)
+		if degraded != "" {
+			record.Degraded = strings.Split(degraded, ",")
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// 检索结果引用的文档ID，按首次出现的顺序去重
+func sourceDocIDs(sources []SearchResult) []string {
+	docIDs := []string{}
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		if source.DocID != "" && !seen[source.DocID] {
+			seen[source.DocID] = true
+			docIDs = append(docIDs, source.DocID)
+		}
+	}
+	return docIDs
+}
+
+type feedbackRequest struct {
+	QueryID int64  `json:"query_id"` // 问答接口返回的query_id
+	Helpful bool   `json:"helpful"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type feedbackResponse struct {
+	QueryID int64 `json:"query_id"`
+	Helpful bool  `json:"helpful"`
+}
+
+func (s *apiServer) handleFeedback(w http.ResponseWriter, req *http.Request) {
+	if s.queries == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("未开启查询日志，需配置QUERY_LOG_DB"))
+		return
+	}
+	var body feedbackRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	err := s.queries.Feedback(body.QueryID, body.Helpful, body.Comment)
+	if errors.Is(err, errQueryNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, feedbackResponse{QueryID: body.QueryID, Helpful: body.Helpful})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 断点所属的存储和集合
+func (r *RAGSystem) reembedTarget() string {
+	return "milvus_" + r.config.CollectionName
+}
+
+// 集合中全部分块所属的文档ID，按ID排序。单次查询最多返回16384行，用QueryIterator按主键分批读取
+func (r *RAGSystem) corpusDocIDs(ctx context.Context) ([]string, error) {
+	collectionName := r.config.CollectionName
+	if err := r.milvusClient.LoadCollection(ctx, collectionName, false); err != nil {
+		return nil, fmt.Errorf("加载集合失败: %w", err)
+	}
+	iterator, err := r.milvusClient.QueryIterator(ctx, client.NewQueryIteratorOption(collectionName).
+		WithExpr(`id != ""`).WithOutputFields("doc_id").WithBatchSize(corpusQueryBatch))
+	if err != nil {
+		return nil, fmt.Errorf("查询分块失败: %w", err)
+	}
+	seen := make(map[string]bool)
+	var docIDs []string
+	for {
+		resultSet, err := iterator.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("查询分块失败: %w", err)
+		}
+		docIDCol, ok := resultSet.GetColumn("doc_id").(*entity.ColumnVarChar)
+		if !ok {
+			return nil, fmt.Errorf("doc_id列类型错误")
+		}
+		for _, docID := range docIDCol.Data() {
+			if !seen[docID] {
+				seen[docID] = true
+				docIDs = append(docIDs, docID)
+			}
+		}
+	}
+	sort.Strings(docIDs)
+	return docIDs, nil
+}
+
+// 读取全部文档ID时每批的分块数
+const corpusQueryBatch = 5000
+
+// 查询文档的全部分块，fields为输出字段
+func (r *RAGSystem) queryDocChunks(ctx context.Context, docIDs []string, fields []string) (client.ResultSet, error) {
+	quoted := make([]string, len(docIDs))
+	for i, docID := range docIDs {
+		quoted[i] = fmt.Sprintf("%q", docID)
+	}
+	expr := fmt.Sprintf("doc_id in [%s]", strings.Join(quoted, ", "))
+	resultSet, err := r.milvusClient.Query(ctx, r.config.CollectionName, nil, expr, fields)
+	if err != nil {
+		return nil, fmt.Errorf("查询分块失败: %w", err)
+	}
+	return resultSet, nil
+}
+
+// 读取文档的分块正文，压缩存储的正文解压后返回
+func (r *RAGSystem) chunkContents(ctx context.Context, docIDs []string) ([]reembedChunk, error) {
+	resultSet, err := r.queryDocChunks(ctx, docIDs, []string{"id", "doc_id", "content", "meta"})
+	if err != nil {
+		return nil, err
+	}
+	return decodeReembedChunks(resultSet)
+}
+
+func decodeReembedChunks(resultSet client.ResultSet) ([]reembedChunk, error) {
+	idCol, ok := resultSet.GetColumn("id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("ID列类型错误")
+	}
+	docIDCol, ok := resultSet.GetColumn("doc_id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("doc_id列类型错误")
+	}
+	contentCol, ok := resultSet.GetColumn("content").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("content列类型错误")
+	}
+	metaCol, ok := resultSet.GetColumn("meta").(*entity.ColumnJSONBytes)
+	if !ok {
+		return nil, fmt.Errorf("meta列类型错误")
+	}
+	chunks := make([]reembedChunk, idCol.Len())
+	for i, id := range idCol.Data() {
+		content, err := decodeCompressed(contentCol.Data()[i])
+		if err != nil {
+			return nil, fmt.Errorf("分块 %s: %w", id, err)
+		}
+		var meta map[string]interface{}
+		_ = json.Unmarshal(metaCol.Data()[i], &meta)
+		model, _ := meta[embeddingModelKey].(string)
+		chunks[i] = reembedChunk{ID: id, DocID: docIDCol.Data()[i], Content: content, Model: model}
+	}
+	return chunks, nil
+}
+
+// 重新生成文档全部分块的向量：读出除稠密向量字段外的所有字段，换上新向量后upsert，返回处理的分块数
+func (r *RAGSystem) reembedDocuments(ctx context.Context, docIDs []string) (int, error) {
+	var fields []string
+	for _, field := range r.collectionSchema().Fields {
+		if field.DataType != entity.FieldTypeFloatVector {
+			fields = append(fields, field.Name)
+		}
+	}
+	resultSet, err := r.queryDocChunks(ctx, docIDs, fields)
+	if err != nil {
+		return 0, err
+	}
+	chunks, err := decodeReembedChunks(resultSet)
+	if err != nil || len(chunks) == 0 {
+		return 0, err
+	}
+	models := make([]string, len(chunks))
+	vectors := make([][]float32, len(chunks))
+	for i, chunk := range chunks {
+		models[i] = chunk.Model
+		if vectors[i], err = r.embedWith(ctx, chunk.Model, chunk.Content); err != nil {
+			return 0, fmt.Errorf("分块 %s 向量化失败: %w", chunk.ID, err)
+		}
+	}
+	columns := make([]entity.Column, 0, len(fields)+1)
+	for _, field := range fields {
+		column := resultSet.GetColumn(field)
+		if column == nil {
+			return 0, fmt.Errorf("查询结果缺少%s列", field)
+		}
+		columns = append(columns, column)
+	}
+	columns = append(columns, r.vectorColumns(models, vectors)...)
+
+	if err := r.faults.inject(ctx, faultTargetStore, "Milvus更新向量"); err != nil {
+		return 0, err
+	}
+	if _, err := r.milvusClient.Upsert(ctx, r.config.CollectionName, "", columns...); err != nil {
+		return 0, fmt.Errorf("更新向量失败: %w", err)
+	}
+	r.retrievals.Clear()
+	return len(chunks), nil
+}
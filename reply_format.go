@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 回复格式适配：回答统一以Markdown生成，各渠道发送前转换为平台格式，不重复生成逻辑。
+// markdown：原样返回；plain：纯文本（微信公众号、企业微信等不渲染Markdown的渠道），去掉标记，链接写成"文字（网址）"；
+// telegram：Telegram MarkdownV2，转义特殊字符；slack：Slack mrkdwn，同时给出Block Kit的section块。
+// 来源列表按编号附在回答之后
+const (
+	replyFormatMarkdown = "markdown"
+	replyFormatPlain    = "plain"
+	replyFormatTelegram = "telegram"
+	replyFormatSlack    = "slack"
+)
+
+func validReplyFormat(format string) error {
+	switch format {
+	case "", replyFormatMarkdown, replyFormatPlain, replyFormatTelegram, replyFormatSlack:
+		return nil
+	}
+	return fmt.Errorf("未知的format: %s，可选 markdown、plain、telegram、slack", format)
+}
+
+// 平台的标记写法，参数均为未转义的原文
+type markupStyle struct {
+	text      func(s string) string // 普通文本
+	bold      func(s string) string
+	code      func(s string) string
+	link      func(text, url string) string
+	codeBlock func(lang, code string) string
+	bullet    string // 无序列表的项目符号
+}
+
+var replyStyles = map[string]markupStyle{
+	replyFormatPlain: {
+		text: func(s string) string { return s },
+		bold: func(s string) string { return s },
+		code: func(s string) string { return s },
+		link: func(text, url string) string {
+			if text == url {
+				return url
+			}
+			return text + "（" + url + "）"
+		},
+		codeBlock: func(lang, code string) string { return code },
+		bullet:    "• ",
+	},
+	replyFormatTelegram: {
+		text: telegramEscape,
+		bold: func(s string) string { return "*" + telegramEscape(s) + "*" },
+		code: func(s string) string { return "`" + telegramCodeEscape(s) + "`" },
+		link: func(text, url string) string {
+			return "[" + telegramEscape(text) + "](" + strings.NewReplacer(`\`, `\\`, ")", `\)`).Replace(url) + ")"
+		},
+		codeBlock: func(lang, code string) string { return "```" + lang + "\n" + telegramCodeEscape(code) + "\n```" },
+		bullet:    "• ",
+	},
+	replyFormatSlack: {
+		text: slackEscape,
+		bold: func(s string) string { return "*" + slackEscape(s) + "*" },
+		code: func(s string) string { return "`" + slackEscape(s) + "`" },
+		link: func(text, url string) string {
+			if text == url {
+				return "<" + url + ">"
+			}
+			return "<" + url + "|" + slackEscape(text) + ">"
+		},
+		codeBlock: func(lang, code string) string { return "```\n" + slackEscape(code) + "\n```" },
+		bullet:    "• ",
+	},
+}
+
+// MarkdownV2中普通文本须转义的字符
+var telegramEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+	">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+func telegramEscape(s string) string {
+	return telegramEscaper.Replace(s)
+}
+
+// 代码和代码块中只转义`和\
+func telegramCodeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(s)
+}
+
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+var (
+	headingLine = regexp.MustCompile(`^#{1,6}\s+(.*)$`)
+	bulletLine  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	// 行内代码、链接、粗体
+	inlineMarkup = regexp.MustCompile("`([^`\n]+)`|\\[([^\\]\n]+)\\]\\(([^)\\s]+)\\)|\\*\\*([^*\n]+)\\*\\*|__([^_\n]+)__")
+)
+
+// 把Markdown转换为平台格式：标题转为粗体，无序列表换成项目符号，代码块、行内代码、链接和粗体按平台写法，其余文本按平台转义
+func convertMarkdown(markdown, format string) string {
+	style, ok := replyStyles[format]
+	if !ok {
+		return markdown
+	}
+	var out, code []string
+	inCode, lang := false, ""
+	for _, line := range strings.Split(markdown, "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") {
+			if inCode {
+				out = append(out, style.codeBlock(lang, strings.Join(code, "\n")))
+				code = nil
+			} else {
+				lang = strings.TrimPrefix(trimmed, "```")
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			code = append(code, line)
+			continue
+		}
+		if m := headingLine.FindStringSubmatch(line); m != nil {
+			out = append(out, style.bold(strings.ReplaceAll(m[1], "**", "")))
+		} else if m := bulletLine.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1]+style.bullet+convertInline(m[2], style))
+		} else {
+			out = append(out, convertInline(line, style))
+		}
+	}
+	// 未闭合的代码块按代码块输出
+	if inCode {
+		out = append(out, style.codeBlock(lang, strings.Join(code, "\n")))
+	}
+	return strings.Join(out, "\n")
+}
+
+func convertInline(line string, style markupStyle) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range inlineMarkup.FindAllStringSubmatchIndex(line, -1) {
+		b.WriteString(style.text(line[last:m[0]]))
+		switch {
+		case m[2] >= 0:
+			b.WriteString(style.code(line[m[2]:m[3]]))
+		case m[4] >= 0:
+			b.WriteString(style.link(line[m[4]:m[5]], line[m[6]:m[7]]))
+		case m[8] >= 0:
+			b.WriteString(style.bold(line[m[8]:m[9]]))
+		default:
+			b.WriteString(style.bold(line[m[10]:m[11]]))
+		}
+		last = m[1]
+	}
+	b.WriteString(style.text(line[last:]))
+	return b.String()
+}
+
+// 编号的来源列表（Markdown），附原文和定位链接；没有来源时为空
+func formatSourceList(sources []SearchResult) string {
+	if len(sources) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("📄 参考文档:\n")
+	for i, source := range sources {
+		fmt.Fprintf(&b, "%d. %s（%s）\n", i+1, source.Title, citationSource(source))
+		if source.Link != "" {
+			b.WriteString("   原文: " + source.Link + "\n")
+		}
+		if source.DeepLink != "" {
+			b.WriteString("   位置: " + source.DeepLink + "\n")
+		}
+	}
+	return b.String()
+}
+
+// 按平台格式转换后的回答
+type formattedReply struct {
+	Format string       `json:"format"`
+	Text   string       `json:"text"`             // 回答和编号的来源列表
+	Blocks []slackBlock `json:"blocks,omitempty"` // slack的Block Kit消息块，可直接作为chat.postMessage的blocks
+}
+
+type slackBlock struct {
+	Type string     `json:"type"` // section、divider
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"` // mrkdwn
+	Text string `json:"text"`
+}
+
+// Slack单个section块的文本长度上限
+const slackMaxSectionLen = 3000
+
+// 把回答和来源转换为平台格式
+func formatReply(format, answer string, sources []SearchResult) *formattedReply {
+	if format == "" {
+		format = replyFormatMarkdown
+	}
+	body := convertMarkdown(answer, format)
+	list := convertMarkdown(formatSourceList(sources), format)
+	reply := &formattedReply{Format: format, Text: body}
+	if list != "" {
+		reply.Text += "\n\n" + list
+	}
+	if format == replyFormatSlack {
+		reply.Blocks = slackSections(body)
+		if list != "" {
+			reply.Blocks = append(append(reply.Blocks, slackBlock{Type: "divider"}), slackSections(list)...)
+		}
+	}
+	return reply
+}
+
+// 按长度上限把文本分成多个section块
+func slackSections(text string) []slackBlock {
+	var blocks []slackBlock
+	for runes := []rune(strings.TrimSpace(text)); len(runes) > 0; {
+		cut := splitPoint(runes, slackMaxSectionLen)
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: string(runes[:cut])}})
+		runes = runes[cut:]
+	}
+	return blocks
+}
+
+// 发送前转换格式的流式回复：生成中的部分回答标记可能不完整，只按普通文本转义，完成后再整体转换
+type formattingSink struct {
+	sink   replySink
+	format string
+}
+
+func (s *formattingSink) Update(text string) error {
+	if style, ok := replyStyles[s.format]; ok {
+		text = style.text(text)
+	}
+	return s.sink.Update(text)
+}
+
+func (s *formattingSink) Finish(text string) error {
+	return s.sink.Finish(convertMarkdown(text, s.format))
+}
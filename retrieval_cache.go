@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// 检索结果缓存配置：按查询向量的哈希和过滤条件缓存向量库返回的原始检索结果，与答案缓存相互独立。
+// 调整提示词、模型等实验时重复的检索不再访问向量库；TTL较短，入库和删除时整体清空
+type RetrievalCacheConfig struct {
+	Enabled    bool
+	TTL        time.Duration
+	MaxEntries int
+}
+
+func loadRetrievalCacheConfig() RetrievalCacheConfig {
+	return RetrievalCacheConfig{
+		Enabled:    getEnvAsBool("RETRIEVAL_CACHE", true),
+		TTL:        time.Duration(getEnvAsInt("RETRIEVAL_CACHE_TTL_SECONDS", 60)) * time.Second,
+		MaxEntries: getEnvAsInt("RETRIEVAL_CACHE_SIZE", 500),
+	}
+}
+
+type cachedRetrieval struct {
+	key       string
+	results   []SearchResult
+	createdAt time.Time
+}
+
+// 检索结果缓存，写满后淘汰最早的条目
+type retrievalCache struct {
+	mu      sync.Mutex
+	config  RetrievalCacheConfig
+	entries map[string]*cachedRetrieval
+	order   []*cachedRetrieval
+}
+
+func newRetrievalCache(config RetrievalCacheConfig) *retrievalCache {
+	if !config.Enabled || config.TTL <= 0 {
+		return nil
+	}
+	return &retrievalCache{config: config, entries: make(map[string]*cachedRetrieval)}
+}
+
+// 缓存键：索引名、查询向量、影响检索的查询文本（关键词检索、拼音等，不涉及时为空）、过滤条件和搜索参数
+func retrievalKey(index string, vector []float32, text string, topK int, opts searchOptions) string {
+	h := sha256.New()
+	for _, v := range vector {
+		_ = binary.Write(h, binary.LittleEndian, math.Float32bits(v))
+	}
+	fmt.Fprintf(h, "|%s|%s|%s|%s|%s|%s|%s|%d|%s|%d|%d|%d", index, text, opts.Category, opts.Entity, opts.exclusionKey(), opts.Period.key(), opts.EmbeddingModel+"|"+opts.Language, topK, opts.Profile, opts.EF, opts.NProbe, opts.NumCandidates)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// 查找缓存的检索结果，返回副本，调用方可以修改
+func (c *retrievalCache) Get(key string) ([]SearchResult, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.order) > 0 && time.Since(c.order[0].createdAt) > c.config.TTL {
+		c.remove(c.order[0])
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return append([]SearchResult(nil), entry.results...), true
+}
+
+func (c *retrievalCache) Put(key string, results []SearchResult) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries[key]; ok {
+		c.remove(old)
+	}
+	entry := &cachedRetrieval{key: key, results: append([]SearchResult(nil), results...), createdAt: time.Now()}
+	c.entries[key] = entry
+	c.order = append(c.order, entry)
+	for len(c.order) > c.config.MaxEntries {
+		c.remove(c.order[0])
+	}
+}
+
+// 知识库内容变化后清空
+func (c *retrievalCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*cachedRetrieval)
+	c.order = nil
+}
+
+func (c *retrievalCache) remove(entry *cachedRetrieval) {
+	for i, e := range c.order {
+		if e == entry {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	delete(c.entries, entry.key)
+}
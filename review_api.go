@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type reviewListResponse struct {
+	Items []reviewItem `json:"items"`
+}
+
+// 提问者看到的审核结果，待审核时不返回模型生成的回答
+type reviewResult struct {
+	ID         int64          `json:"id"`
+	Question   string         `json:"question"`
+	Status     string         `json:"status"`           // pending、approved、rejected
+	Answer     string         `json:"answer,omitempty"` // 审核后发布的回答或驳回说明
+	Sources    []SearchResult `json:"sources,omitempty"`
+	ReviewedAt *time.Time     `json:"reviewed_at,omitempty"`
+}
+
+type reviewDecision struct {
+	ID       int64  `json:"id"`
+	Answer   string `json:"answer,omitempty"`   // 批准时为修改后的回答，不填时发布原回答；驳回时为回复提问者的说明
+	Reviewer string `json:"reviewer,omitempty"` // 审核人
+	FAQ      bool   `json:"faq,omitempty"`      // 批准时加入FAQ，相同或相近的问题直接使用该回答
+	Corpus   bool   `json:"corpus,omitempty"`   // 批准时把回答作为新文档加入知识库，标题为原问题
+}
+
+type reviewPromoteRequest struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title,omitempty"` // 文档标题，不填时使用原问题
+}
+
+var errReviewDisabled = errors.New("未开启人工审核，需配置REVIEW_THRESHOLD")
+
+func (s *apiServer) handleReviewQueue(w http.ResponseWriter, req *http.Request) {
+	if s.rag.reviews == nil {
+		writeError(w, http.StatusNotFound, errReviewDisabled)
+		return
+	}
+	status := req.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = reviewPending
+	case "all":
+		status = ""
+	case reviewPending, reviewApproved, reviewRejected:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("未知的status: %s，可选 pending、approved、rejected、all", status))
+		return
+	}
+	limit := 100
+	if value := req.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("limit必须是正整数"))
+			return
+		}
+		limit = parsed
+	}
+
+	items, err := s.rag.reviews.List(status, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reviewListResponse{Items: items})
+}
+
+func (s *apiServer) handleReviewResult(w http.ResponseWriter, req *http.Request) {
+	if s.rag.reviews == nil {
+		writeError(w, http.StatusNotFound, errReviewDisabled)
+		return
+	}
+	id, err := strconv.ParseInt(req.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("id必须是整数"))
+		return
+	}
+	item, err := s.rag.reviews.Get(id)
+	if errors.Is(err, errReviewNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// 发布的回答与直接返回的回答一样按命名空间的屏蔽词表处理
+	answer := s.rag.settings().lexicon.apply(answerNamespace("", item.Sources), item.Answer)
+	result := reviewResult{ID: item.ID, Question: item.Question, Status: item.Status, Answer: answer, ReviewedAt: item.ReviewedAt}
+	if item.Status == reviewApproved {
+		result.Sources = item.Sources
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *apiServer) handleApproveReview(w http.ResponseWriter, req *http.Request) {
+	s.decideReview(w, req, true)
+}
+
+func (s *apiServer) handleRejectReview(w http.ResponseWriter, req *http.Request) {
+	s.decideReview(w, req, false)
+}
+
+func (s *apiServer) decideReview(w http.ResponseWriter, req *http.Request, approve bool) {
+	if s.rag.reviews == nil {
+		writeError(w, http.StatusNotFound, errReviewDisabled)
+		return
+	}
+	var body reviewDecision
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	item, err := s.rag.reviews.Decide(req.Context(), body.ID, approve, body.Answer, body.Reviewer, body.FAQ)
+	if errors.Is(err, errReviewNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	fmt.Printf("🧑‍⚖️ 审核 #%d: %s\n", item.ID, item.Status)
+	if approve && body.Corpus {
+		if err := s.rag.promoteReview(item, ""); err != nil {
+			// 审核结果已保存，加入知识库失败时可以稍后通过 /review/promote 重试
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("审核已通过，加入知识库失败: %w", err))
+			return
+		}
+		fmt.Printf("📚 审核 #%d 已加入知识库: %s\n", item.ID, item.DocID)
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *apiServer) handlePromoteReview(w http.ResponseWriter, req *http.Request) {
+	if s.rag.reviews == nil {
+		writeError(w, http.StatusNotFound, errReviewDisabled)
+		return
+	}
+	var body reviewPromoteRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	item, err := s.rag.reviews.Get(body.ID)
+	if errors.Is(err, errReviewNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if item.Status != reviewApproved {
+		writeError(w, http.StatusConflict, fmt.Errorf("审核记录 %d 未通过审核: %s", item.ID, item.Status))
+		return
+	}
+	if err := s.rag.promoteReview(item, body.Title); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	fmt.Printf("📚 审核 #%d 已加入知识库: %s\n", item.ID, item.DocID)
+	writeJSON(w, http.StatusOK, item)
+}
+
+// 审核页面：列出待审核的回答，可以修改后批准、加入FAQ和知识库，或驳回。页面本身不含数据，
+// 审核接口需要ADMIN_TOKEN，由审核人在页面中填写，保存在浏览器的localStorage中
+func (s *apiServer) handleReviewPage(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>回答审核</title>
+  <style>
+    body { font-family: sans-serif; max-width: 960px; margin: 2em auto; }
+    .item { border: 1px solid #ddd; border-radius: 6px; padding: 1em; margin-bottom: 1em; }
+    .meta { color: #888; font-size: 0.9em; }
+    textarea { width: 100%; min-height: 8em; box-sizing: border-box; }
+    li { margin: 0.3em 0; }
+  </style>
+</head>
+<body>
+  <h1>待审核的回答</h1>
+  <p>审核人 <input id="reviewer" placeholder="姓名"> 管理令牌 <input id="token" type="password" placeholder="ADMIN_TOKEN" onchange="saveToken()"></p>
+  <div id="items">加载中...</div>
+  <script>
+    const esc = s => String(s).replace(/[&<>"]/g, c => ({"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"}[c]));
+    const tokenInput = document.getElementById("token");
+    tokenInput.value = localStorage.getItem("adminToken") || "";
+    function saveToken() { localStorage.setItem("adminToken", tokenInput.value); load(); }
+    const auth = () => ({"Authorization": "Bearer " + tokenInput.value});
+    async function load() {
+      const resp = await fetch("/review/pending", {headers: auth()});
+      const data = await resp.json();
+      const root = document.getElementById("items");
+      if (!resp.ok) { root.textContent = data.error; return; }
+      if (data.items.length === 0) { root.textContent = "没有待审核的回答"; return; }
+      root.innerHTML = data.items.map(item => `+"`"+`
+        <div class="item" id="item-${item.id}">
+          <div class="meta">#${item.id} · 最高分 ${item.score.toFixed(3)} · ${new Date(item.created_at).toLocaleString()}</div>
+          <h3>${esc(item.question)}</h3>
+          <textarea>${esc(item.draft)}</textarea>
+          <ul>${(item.sources || []).map(s => `+"`"+`<li>${esc(s.title)}（${s.score.toFixed(3)}）${s.deep_link ? `+"`"+` <a href="${esc(s.deep_link)}" target="_blank">原文</a>`+"`"+` : ""}</li>`+"`"+`).join("")}</ul>
+          <label><input type="checkbox" name="faq"> 加入FAQ</label>
+          <label><input type="checkbox" name="corpus"> 加入知识库</label>
+          <button onclick="decide(${item.id}, 'approve')">批准</button>
+          <button onclick="decide(${item.id}, 'reject')">驳回</button>
+        </div>`+"`"+`).join("");
+    }
+    async function decide(id, action) {
+      const el = document.getElementById("item-" + id);
+      const body = {
+        id: id,
+        reviewer: document.getElementById("reviewer").value,
+        answer: action === "approve" ? el.querySelector("textarea").value : "",
+        faq: action === "approve" && el.querySelector("input[name=faq]").checked,
+        corpus: action === "approve" && el.querySelector("input[name=corpus]").checked,
+      };
+      const resp = await fetch("/review/" + action, {method: "POST", headers: {...auth(), "Content-Type": "application/json"}, body: JSON.stringify(body)});
+      if (!resp.ok) { alert((await resp.json()).error); }
+      load();
+    }
+    load();
+  </script>
+</body>
+</html>`)
+}
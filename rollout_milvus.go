@@ -0,0 +1,13 @@
+package main
+
+import (
+	"context"
+)
+
+// 别名改为指向collectionName；别名尚不存在时AlterAlias失败，改为创建
+func (r *RAGSystem) pointAlias(ctx context.Context, alias, collectionName string) error {
+	if err := r.milvusClient.AlterAlias(ctx, collectionName, alias); err == nil {
+		return nil
+	}
+	return r.milvusClient.CreateAlias(ctx, collectionName, alias)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 检索规则配置：RULES_DB不为空时serve从SQLite的rules表加载管理员定义的规则，检索后按规则调整结果，用于关键问题的编辑控制。
+// pin：问题包含keyword时把文档doc_id置顶，检索结果中没有该文档时读取其与问题最相关的分块（分数记为1）；
+// boost：问题包含keyword（为空时对所有问题）时，field等于value的分块分数乘以weight后重新排序，weight小于1即为降权。
+// 规则全部加载到内存，通过 /admin/rules 增删后立即生效并清空答案缓存
+type RulesConfig struct {
+	DB string
+}
+
+func loadRulesConfig() RulesConfig {
+	return RulesConfig{DB: getEnv("RULES_DB", "")}
+}
+
+const (
+	ruleActionPin   = "pin"
+	ruleActionBoost = "boost"
+)
+
+// 一条检索规则
+type retrievalRule struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`            // pin、boost
+	Keyword   string    `json:"keyword,omitempty"` // 问题包含该词时生效，不区分大小写；pin必填，boost为空时对所有问题生效
+	DocID     string    `json:"doc_id,omitempty"`  // pin置顶的文档
+	Field     string    `json:"field,omitempty"`   // boost匹配的元数据字段，doc_id表示文档ID
+	Value     string    `json:"value,omitempty"`   // boost匹配的取值，列表字段（如人物）包含即可
+	Weight    float64   `json:"weight,omitempty"`  // boost的分数倍数
+	Note      string    `json:"note,omitempty"`    // 备注，例如规则的来由
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (rule retrievalRule) validate() error {
+	switch rule.Action {
+	case ruleActionPin:
+		if rule.Keyword == "" || rule.DocID == "" {
+			return fmt.Errorf("pin规则需要keyword和doc_id")
+		}
+	case ruleActionBoost:
+		if rule.Field == "" || rule.Value == "" {
+			return fmt.Errorf("boost规则需要field和value")
+		}
+		if rule.Weight <= 0 {
+			return fmt.Errorf("boost规则的weight必须大于0")
+		}
+	default:
+		return fmt.Errorf("未知的action: %s，可选 pin、boost", rule.Action)
+	}
+	return nil
+}
+
+func (rule retrievalRule) matches(question string) bool {
+	return strings.Contains(strings.ToLower(question), strings.ToLower(rule.Keyword))
+}
+
+// 分块是否命中boost规则的字段和取值
+func (rule retrievalRule) selects(result SearchResult) bool {
+	if rule.Field == "doc_id" {
+		return result.DocID == rule.Value
+	}
+	switch value := result.Meta[rule.Field].(type) {
+	case string:
+		return value == rule.Value
+	case []interface{}:
+		for _, item := range value {
+			if item == rule.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 检索规则，存储在SQLite中，启动时全部加载到内存
+type ruleStore struct {
+	db *sql.DB
+
+	mu    sync.RWMutex
+	rules []retrievalRule
+}
+
+var errRuleNotFound = errors.New("规则不存在")
+
+// 未配置RULES_DB时返回nil
+func openRuleStore(config RulesConfig) (*ruleStore, error) {
+	if config.DB == "" {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite3", config.DB)
+	if err != nil {
+		return nil, fmt.Errorf("打开规则库失败: %w", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action TEXT NOT NULL,
+		keyword TEXT NOT NULL DEFAULT '',
+		doc_id TEXT NOT NULL DEFAULT '',
+		field TEXT NOT NULL DEFAULT '',
+		value TEXT NOT NULL DEFAULT '',
+		weight REAL NOT NULL DEFAULT 0,
+		note TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化规则库失败: %w", err)
+	}
+	s := &ruleStore{db: db}
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *ruleStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *ruleStore) load() error {
+	rows, err := s.db.Query(`SELECT id, action, keyword, doc_id, field, value, weight, note, created_at FROM rules ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("读取规则失败: %w", err)
+	}
+	defer rows.Close()
+	var rules []retrievalRule
+	for rows.Next() {
+		var rule retrievalRule
+		var createdAt int64
+		if err := rows.Scan(&rule.ID, &rule.Action, &rule.Keyword, &rule.DocID, &rule.Field, &rule.Value, &rule.Weight, &rule.Note, &createdAt); err != nil {
+			return fmt.Errorf("读取规则失败: %w", err)
+		}
+		rule.CreatedAt = time.Unix(createdAt, 0)
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取规则失败: %w", err)
+	}
+	s.mu.Lock()
+	s.rules = rules
+	s.mu.Unlock()
+	return nil
+}
+
+// 全部规则，按ID排列
+func (s *ruleStore) List() []retrievalRule {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]retrievalRule(nil), s.rules...)
+}
+
+// 新增规则，返回带ID的规则
+func (s *ruleStore) Add(rule retrievalRule) (retrievalRule, error) {
+	if err := rule.validate(); err != nil {
+		return rule, err
+	}
+	rule.CreatedAt = time.Now()
+	res, err := s.db.Exec(
+		`INSERT INTO rules (action, keyword, doc_id, field, value, weight, note, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.Action, rule.Keyword, rule.DocID, rule.Field, rule.Value, rule.Weight, rule.Note, rule.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return rule, fmt.Errorf("写入规则失败: %w", err)
+	}
+	if rule.ID, err = res.LastInsertId(); err != nil {
+		return rule, err
+	}
+	s.mu.Lock()
+	s.rules = append(s.rules, rule)
+	s.mu.Unlock()
+	return rule, nil
+}
+
+// 删除规则，返回被删除的规则
+func (s *ruleStore) Delete(id int64) (retrievalRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.rules {
+		if rule.ID != id {
+			continue
+		}
+		if _, err := s.db.Exec(`DELETE FROM rules WHERE id = ?`, id); err != nil {
+			return rule, fmt.Errorf("删除规则失败: %w", err)
+		}
+		s.rules = append(s.rules[:i:i], s.rules[i+1:]...)
+		return rule, nil
+	}
+	return retrievalRule{}, errRuleNotFound
+}
+
+// 按规则调整检索结果：先按boost调整分数并重新排序，再把pin的文档置顶；多条pin规则按规则ID顺序排列。
+// 请求排除的文档不置顶
+func (r *RAGSystem) applyRules(ctx context.Context, question string, results []SearchResult, opts searchOptions) []SearchResult {
+	var boosts, pins []retrievalRule
+	for _, rule := range r.rules.List() {
+		switch {
+		case rule.Action == ruleActionBoost && (rule.Keyword == "" || rule.matches(question)):
+			boosts = append(boosts, rule)
+		case rule.Action == ruleActionPin && rule.matches(question) && !containsString(opts.ExcludeDocs, rule.DocID):
+			pins = append(pins, rule)
+		}
+	}
+	if len(boosts) > 0 {
+		for i := range results {
+			for _, rule := range boosts {
+				if rule.selects(results[i]) {
+					results[i].Score *= rule.Weight
+				}
+			}
+		}
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	}
+
+	var pinned, rest []SearchResult
+	for _, rule := range pins {
+		found := false
+		for _, result := range results {
+			if result.DocID == rule.DocID {
+				pinned = append(pinned, result)
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		chunk, err := r.pinnedChunk(ctx, question, rule.DocID)
+		if err != nil {
+			fmt.Printf("⚠️  规则 %d 置顶文档 %s 失败: %v\n", rule.ID, rule.DocID, err)
+			continue
+		}
+		if chunk != nil {
+			pinned = append(pinned, *chunk)
+		}
+	}
+	if len(pinned) == 0 {
+		return results
+	}
+	fmt.Printf("📌 按规则置顶 %d 个分块\n", len(pinned))
+	for _, result := range results {
+		if !containsResult(pinned, result.ID) {
+			rest = append(rest, result)
+		}
+	}
+	return append(pinned, rest...)
+}
+
+func containsResult(results []SearchResult, id string) bool {
+	for _, result := range results {
+		if result.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// 置顶文档中与问题最相关的分块，许可或有效期不允许引用时返回nil；向量化失败时取第一个分块
+func (r *RAGSystem) pinnedChunk(ctx context.Context, question, docID string) (*SearchResult, error) {
+	chunks, err := r.documentChunks(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	chunks = dropExpired(applyLicense(chunks, r.config.License), time.Now())
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(chunks, func(i, j int) bool { return chunkSeq(chunks[i].ID) < chunkSeq(chunks[j].ID) })
+	best := 0
+	if questionVector, err := r.embedder.Embed(ctx, question); err == nil {
+		if vectors, err := r.embedResults(ctx, chunks); err == nil {
+			for i, vector := range vectors {
+				if cosineSimilarity(questionVector, vector) > cosineSimilarity(questionVector, vectors[best]) {
+					best = i
+				}
+			}
+		}
+	}
+	chunk := chunks[best]
+	chunk.Score = 1
+	chunk.Trust = r.config.Trust.weight(chunk.Meta)
+	return &chunk, nil
+}
+
+type rulesResponse struct {
+	Rules []retrievalRule `json:"rules"`
+}
+
+type deleteRuleRequest struct {
+	ID int64 `json:"id"`
+}
+
+var errRulesDisabled = errors.New("未开启检索规则，需配置RULES_DB")
+
+func (s *apiServer) handleRules(w http.ResponseWriter, req *http.Request) {
+	if s.rag.rules == nil {
+		writeError(w, http.StatusNotFound, errRulesDisabled)
+		return
+	}
+	rules := s.rag.rules.List()
+	if rules == nil {
+		rules = []retrievalRule{}
+	}
+	writeJSON(w, http.StatusOK, rulesResponse{Rules: rules})
+}
+
+func (s *apiServer) handleAddRule(w http.ResponseWriter, req *http.Request) {
+	if s.rag.rules == nil {
+		writeError(w, http.StatusNotFound, errRulesDisabled)
+		return
+	}
+	var body retrievalRule
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if err := body.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	rule, err := s.rag.rules.Add(body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.clearAnswerCaches()
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *apiServer) handleDeleteRule(w http.ResponseWriter, req *http.Request) {
+	if s.rag.rules == nil {
+		writeError(w, http.StatusNotFound, errRulesDisabled)
+		return
+	}
+	var body deleteRuleRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	rule, err := s.rag.rules.Delete(body.ID)
+	if errors.Is(err, errRuleNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.clearAnswerCaches()
+	writeJSON(w, http.StatusOK, rule)
+}
+
+// 规则变化后缓存的回答可能已不符合规则，清空各profile的答案缓存
+func (s *apiServer) clearAnswerCaches() {
+	s.rag.answers.Clear()
+	if s.rollout != nil {
+		s.rollout.each(func(rag *RAGSystem) { rag.answers.Clear() })
+	}
+}
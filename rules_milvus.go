@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 读取文档的全部分块，用于置顶检索结果中没有的文档
+func (r *RAGSystem) documentChunks(ctx context.Context, docID string) ([]SearchResult, error) {
+	if err := r.milvusClient.LoadCollection(ctx, r.config.CollectionName, false); err != nil {
+		return nil, fmt.Errorf("加载集合失败: %w", err)
+	}
+	resultSet, err := r.queryDocChunks(ctx, []string{docID}, []string{"id", "doc_id", "title", "content", "meta"})
+	if err != nil {
+		return nil, err
+	}
+	idCol, ok := resultSet.GetColumn("id").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("ID列类型错误")
+	}
+	titleCol, ok := resultSet.GetColumn("title").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("title列类型错误")
+	}
+	contentCol, ok := resultSet.GetColumn("content").(*entity.ColumnVarChar)
+	if !ok {
+		return nil, fmt.Errorf("content列类型错误")
+	}
+	metaCol, ok := resultSet.GetColumn("meta").(*entity.ColumnJSONBytes)
+	if !ok {
+		return nil, fmt.Errorf("meta列类型错误")
+	}
+
+	chunks := make([]SearchResult, 0, idCol.Len())
+	for i, id := range idCol.Data() {
+		var meta map[string]interface{}
+		_ = json.Unmarshal(metaCol.Data()[i], &meta)
+		content, err := decodeCompressed(contentCol.Data()[i])
+		if err != nil {
+			return nil, fmt.Errorf("分块 %s: %w", id, err)
+		}
+		chunks = append(chunks, SearchResult{ID: id, DocID: docID, Title: titleCol.Data()[i], Content: content, Meta: meta})
+	}
+	return chunks, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// S3同步状态中的一个对象
+type s3SyncObject struct {
+	ETag  string `json:"etag"`
+	DocID string `json:"doc_id"`
+}
+
+// S3同步状态，记录已入库对象的ETag，ETag未变的对象不再下载
+type s3SyncState struct {
+	Bucket  string                  `json:"bucket"`
+	Prefix  string                  `json:"prefix"`
+	Objects map[string]s3SyncObject `json:"objects"` // 对象键 -> ETag和文档ID
+}
+
+// S3同步状态文件路径
+func s3SyncStatePath() string {
+	return getEnv("S3_SYNC_STATE_FILE", ".s3sync_state.json")
+}
+
+// 读取同步状态，文件不存在时返回空状态
+func loadS3SyncState(path string) (*s3SyncState, error) {
+	state := &s3SyncState{Objects: make(map[string]s3SyncObject)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("解析同步状态失败: %w", err)
+	}
+	if state.Objects == nil {
+		state.Objects = make(map[string]s3SyncObject)
+	}
+	return state, nil
+}
+
+func saveS3SyncState(path string, state *s3SyncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// s3sync命令：按前缀列出存储桶中的对象，只下载ETag有变化的文件写入知识库，并删除已从存储桶移除的文档
+func runS3Sync(args []string) error {
+	fs := flag.NewFlagSet("s3sync", flag.ExitOnError)
+	bucket := fs.String("bucket", getEnv("S3_SYNC_BUCKET", ""), "存储桶")
+	prefix := fs.String("prefix", getEnv("S3_SYNC_PREFIX", ""), "对象键前缀")
+	extensions := fs.String("ext", getEnv("S3_SYNC_EXTENSIONS", ".txt,.md,.markdown,.html,.htm"), "同步的文件扩展名，逗号分隔")
+	statePath := fs.String("state", s3SyncStatePath(), "同步状态文件")
+	dryRun := fs.Bool("dry-run", false, "只列出需要同步的对象")
+	_ = fs.Parse(args)
+
+	if *bucket == "" {
+		return fmt.Errorf("请通过 -bucket 或 S3_SYNC_BUCKET 指定存储桶")
+	}
+
+	state, err := loadS3SyncState(*statePath)
+	if err != nil {
+		return err
+	}
+	// 换了存储桶或前缀时旧状态不再适用
+	if state.Bucket != *bucket || state.Prefix != *prefix {
+		state.Bucket, state.Prefix = *bucket, *prefix
+		state.Objects = make(map[string]s3SyncObject)
+	}
+
+	config := loadConfig()
+	s3Client, err := newS3Client(config.Blob.S3)
+	if err != nil {
+		return err
+	}
+
+	allowed := make(map[string]bool)
+	for _, ext := range splitEnvList(*extensions) {
+		allowed[strings.ToLower(ext)] = true
+	}
+
+	ctx := context.Background()
+	seen := make(map[string]bool)
+	var changed []minio.ObjectInfo
+	for object := range s3Client.ListObjects(ctx, *bucket, minio.ListObjectsOptions{Prefix: *prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("列出对象失败: %w", object.Err)
+		}
+		name := path.Base(object.Key)
+		if strings.HasSuffix(object.Key, "/") || strings.HasPrefix(name, ".") || !allowed[strings.ToLower(path.Ext(name))] {
+			continue
+		}
+		seen[object.Key] = true
+		if state.Objects[object.Key].ETag != object.ETag {
+			changed = append(changed, object)
+		}
+	}
+
+	var removed []string
+	for key := range state.Objects {
+		if !seen[key] {
+			removed = append(removed, key)
+		}
+	}
+	fmt.Printf("🪣 s3://%s/%s 中有 %d 个对象，%d 个新增或更新，%d 个已删除\n", *bucket, *prefix, len(seen), len(changed), len(removed))
+
+	if *dryRun {
+		for _, object := range changed {
+			fmt.Printf("  + %s (etag: %s)\n", object.Key, object.ETag)
+		}
+		for _, key := range removed {
+			fmt.Printf("  - %s\n", key)
+		}
+		return nil
+	}
+	if len(changed) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	var documents []Document
+	etags := make(map[string]string)
+	for _, object := range changed {
+		data, err := downloadS3Object(ctx, s3Client, *bucket, object.Key)
+		if err != nil {
+			fmt.Printf("⚠️  跳过对象 %s: %v\n", object.Key, err)
+			continue
+		}
+		sourceURL := "s3://" + *bucket + "/" + object.Key
+		doc, err := loadDocument(sourceURL, data)
+		if err != nil {
+			fmt.Printf("⚠️  跳过对象 %s: %v\n", object.Key, err)
+			continue
+		}
+		delete(doc.Meta, "source_path")
+		doc.Meta["source_url"] = sourceURL
+		documents = append(documents, doc)
+		etags[object.Key] = object.ETag
+	}
+
+	rag, err := NewRAGSystem(config)
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	if err := rag.ReplaceDocuments(documents); err != nil {
+		return err
+	}
+	removedIDs := make([]string, 0, len(removed))
+	for _, key := range removed {
+		removedIDs = append(removedIDs, state.Objects[key].DocID)
+	}
+	if err := rag.DeleteDocuments(removedIDs); err != nil {
+		return err
+	}
+
+	for _, doc := range documents {
+		key := strings.TrimPrefix(doc.Meta["source_url"].(string), "s3://"+*bucket+"/")
+		state.Objects[key] = s3SyncObject{ETag: etags[key], DocID: doc.ID}
+	}
+	for _, key := range removed {
+		delete(state.Objects, key)
+	}
+	if err := saveS3SyncState(*statePath, state); err != nil {
+		return fmt.Errorf("保存同步状态失败: %w", err)
+	}
+	fmt.Printf("✅ 同步了 %d 个对象，删除了 %d 个文档\n", len(documents), len(removedIDs))
+	return nil
+}
+
+func downloadS3Object(ctx context.Context, s3Client *minio.Client, bucket, key string) ([]byte, error) {
+	object, err := s3Client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	return io.ReadAll(object)
+}
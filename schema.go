@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 结构差异的类型
+const (
+	schemaMissing = "missing" // 当前结构缺少的字段、子字段或索引
+	schemaChanged = "changed" // 类型或参数与预期不一致
+	schemaExtra   = "extra"   // 当前结构多出的字段，不影响检索，只提示
+)
+
+// 当前索引/集合结构与流水线预期结构的一处差异
+type schemaDiff struct {
+	Path     string
+	Kind     string
+	Current  string
+	Expected string
+	Safe     bool // 可以在线追加，不需要重建
+}
+
+// schema命令：查看当前索引mapping或集合schema，与当前配置下流水线预期的结构对比，
+// 在线追加安全的变更（新的元数据字段、缺少的索引），需要重建时给出迁移步骤
+func runSchema(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "应用可以在线追加的变更，应用前逐项确认")
+	yes := fs.Bool("yes", false, "配合-apply使用，不确认直接应用")
+	_ = fs.Parse(args)
+
+	rag, err := NewRAGSystem(loadConfig())
+	if err != nil {
+		return err
+	}
+	defer rag.Close()
+
+	ctx := context.Background()
+	name, current, diffs, err := rag.inspectSchema(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📐 %s 当前结构:\n", name)
+	for _, line := range current {
+		fmt.Printf("  %s\n", line)
+	}
+
+	if len(diffs) == 0 {
+		fmt.Println("\n✅ 结构与当前配置一致")
+		return nil
+	}
+	fmt.Printf("\n🔍 与当前配置相比有 %d 处差异:\n", len(diffs))
+	var safe []schemaDiff
+	breaking := false
+	for _, diff := range diffs {
+		mark := "⚠️ "
+		switch {
+		case diff.Kind == schemaExtra:
+			mark = "ℹ️ "
+		case diff.Safe:
+			mark = "➕"
+			safe = append(safe, diff)
+		default:
+			breaking = true
+		}
+		fmt.Printf("  %s %-8s %s: %s -> %s\n", mark, diff.Kind, diff.Path, orDash(diff.Current), orDash(diff.Expected))
+	}
+
+	if len(safe) > 0 {
+		if !*apply {
+			fmt.Printf("\n➕ 其中 %d 处可以在线追加，使用 -apply 应用\n", len(safe))
+		} else {
+			var confirmed []schemaDiff
+			stdin := bufio.NewReader(os.Stdin)
+			for _, diff := range safe {
+				if *yes || confirm(stdin, fmt.Sprintf("追加 %s (%s)？[y/N] ", diff.Path, diff.Expected)) {
+					confirmed = append(confirmed, diff)
+				}
+			}
+			if len(confirmed) > 0 {
+				if err := rag.applySchema(ctx, confirmed); err != nil {
+					return err
+				}
+				fmt.Printf("✅ 已应用 %d 处变更\n", len(confirmed))
+			}
+		}
+	}
+	if breaking {
+		fmt.Printf("\n⚠️  存在需要重建才能生效的变更:\n%s\n", schemaReindexGuide(rag.config))
+	}
+	return nil
+}
+
+// 从终端读取确认，只有y或yes视为同意
+func confirm(stdin *bufio.Reader, prompt string) bool {
+	fmt.Print(prompt)
+	line, _ := stdin.ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
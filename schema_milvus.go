@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// 索引差异的路径前缀，例如 index/vector
+const schemaIndexPrefix = "index/"
+
+// 对比集合schema和向量索引。Milvus 2.4不支持给已有集合追加字段，缺少字段或字段参数变化都需要重建；
+// 元数据（分类、实体等）保存在JSON字段meta中，新增元数据字段不需要改schema。缺少的索引可以在线创建
+func (r *RAGSystem) inspectSchema(ctx context.Context) (string, []string, []schemaDiff, error) {
+	collectionName := r.config.CollectionName
+	collection, err := r.milvusClient.DescribeCollection(ctx, collectionName)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("查询集合信息失败: %w", err)
+	}
+
+	current := make(map[string]*entity.Field)
+	var lines []string
+	for _, field := range collection.Schema.Fields {
+		current[field.Name] = field
+		line := fmt.Sprintf("%-12s %s", field.Name, describeField(field))
+		if field.PrimaryKey {
+			line += " 主键"
+		}
+		lines = append(lines, line)
+	}
+
+	var diffs []schemaDiff
+	expected := make(map[string]bool)
+	for _, want := range r.collectionSchema().Fields {
+		expected[want.Name] = true
+		have, ok := current[want.Name]
+		if !ok {
+			diffs = append(diffs, schemaDiff{Path: want.Name, Kind: schemaMissing, Expected: describeField(want)})
+			continue
+		}
+		if describeField(have) != describeField(want) || have.PrimaryKey != want.PrimaryKey {
+			diffs = append(diffs, schemaDiff{Path: want.Name, Kind: schemaChanged, Current: describeField(have), Expected: describeField(want)})
+		}
+	}
+	for _, field := range collection.Schema.Fields {
+		if !expected[field.Name] {
+			diffs = append(diffs, schemaDiff{Path: field.Name, Kind: schemaExtra, Current: describeField(field)})
+		}
+	}
+
+	for _, field := range r.indexedFields() {
+		if _, ok := current[field]; !ok {
+			continue // 字段本身缺少，重建集合时一并创建索引
+		}
+		want, err := expectedIndex(field)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		path := schemaIndexPrefix + field
+		indexes, err := r.milvusClient.DescribeIndex(ctx, collectionName, field)
+		if err != nil && !indexNotFound(err) {
+			return "", nil, nil, fmt.Errorf("查询%s字段索引失败: %w", field, err)
+		}
+		if len(indexes) == 0 {
+			lines = append(lines, fmt.Sprintf("%-12s 无", path))
+			diffs = append(diffs, schemaDiff{Path: path, Kind: schemaMissing, Expected: describeIndex(want.Params()), Safe: true})
+			continue
+		}
+		have := describeIndex(indexes[0].Params())
+		lines = append(lines, fmt.Sprintf("%-12s %s", path, have))
+		if have != describeIndex(want.Params()) {
+			diffs = append(diffs, schemaDiff{Path: path, Kind: schemaChanged, Current: have, Expected: describeIndex(want.Params())})
+		}
+	}
+	return collectionName, lines, diffs, nil
+}
+
+// 创建缺少的索引。集合需要先释放，创建后重新加载
+func (r *RAGSystem) applySchema(ctx context.Context, diffs []schemaDiff) error {
+	collectionName := r.config.CollectionName
+	if err := r.milvusClient.ReleaseCollection(ctx, collectionName); err != nil {
+		return fmt.Errorf("释放集合失败: %w", err)
+	}
+	for _, diff := range diffs {
+		field, ok := strings.CutPrefix(diff.Path, schemaIndexPrefix)
+		if !ok {
+			return fmt.Errorf("不支持在线变更: %s", diff.Path)
+		}
+		if err := r.createIndex(ctx, collectionName, field); err != nil {
+			return err
+		}
+		fmt.Printf("➕ 已创建索引 %s\n", diff.Path)
+	}
+	if err := r.milvusClient.LoadCollection(ctx, collectionName, false); err != nil {
+		return fmt.Errorf("加载集合失败: %w", err)
+	}
+	return nil
+}
+
+// 错误提示中运行子命令的方式
+const commandPrefix = "go run ."
+
+// 需要重建时的迁移步骤
+func schemaReindexGuide(config Config) string {
+	return fmt.Sprintf(`  Milvus集合创建后不能追加或修改字段，需要按当前配置新建集合并重新入库：
+  1. 使用新的集合名重新初始化并入库，例如 COLLECTION_NAME=%s_v2 go run .（或 bootstrap、sitemap等同步命令）
+  2. 用 go run . diff -base %s -candidate %s_v2 对比新旧集合的回答
+  3. 确认无误后把 COLLECTION_NAME 切换为新集合（或使用 rollout 命令逐步切换），再删除旧集合
+  演示环境也可以直接 go run . 重新初始化当前集合（会删除已有数据）`, config.CollectionName, config.CollectionName, config.CollectionName)
+}
+
+// 字段类型和参数，例如 VarChar(max_length=100)
+func describeField(field *entity.Field) string {
+	name := field.DataType.Name()
+	if field.DataType == entity.FieldTypeSparseVector {
+		name = "SparseFloatVector"
+	}
+	var params []string
+	for _, key := range []string{"dim", "max_length"} {
+		if value, ok := field.TypeParams[key]; ok {
+			params = append(params, key+"="+value)
+		}
+	}
+	if len(params) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(params, ","))
+}
+
+// 索引类型、度量方式和构建参数，例如 HNSW/L2 M=8 efConstruction=64
+func describeIndex(params map[string]string) string {
+	build := make(map[string]string)
+	// 构建参数在params中以JSON保存，服务端也可能展开为单独的键
+	var nested map[string]interface{}
+	if err := json.Unmarshal([]byte(params["params"]), &nested); err == nil {
+		for key, value := range nested {
+			build[key] = fmt.Sprint(value)
+		}
+	}
+	for key, value := range params {
+		if key != "params" {
+			build[key] = value
+		}
+	}
+	delete(build, "index_type")
+	delete(build, "metric_type")
+	keys := make([]string, 0, len(build))
+	for key := range build {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	desc := params["index_type"] + "/" + params["metric_type"]
+	for _, key := range keys {
+		desc += " " + key + "=" + build[key]
+	}
+	return desc
+}
+
+func indexNotFound(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "index not found") || strings.Contains(message, "index not exist")
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 会话配置：请求带session时，服务端在内存中记录该会话展示过的来源，追问"还有别的吗"时可以用exclude_shown排除，
+// 让回答引用新的内容。会话SESSION_TTL_MINUTES内没有请求即过期，超过SESSION_MAX个时淘汰最久未使用的会话。
+// 会话中的问答也按向量保存，之后的提问从中检索与问题最相近的SESSION_HISTORY_TOP_K轮作为上下文，
+// 长对话可以引用之前的回答，而不必把全部历史塞进每次的提示词；SESSION_HISTORY_TOP_K=0时关闭。
+// SESSION_RETRIEVAL_TTL_SECONDS>0时会话还保留最近几次的检索结果，之后的提问归一化后与其中一次相同，或向量相似度
+// 不低于SESSION_RETRIEVAL_SIMILARITY，且分类、实体、排除等检索条件相同时，在TTL内直接复用，不再检索
+type SessionConfig struct {
+	TTL                 time.Duration
+	MaxSessions         int
+	HistoryTopK         int
+	HistoryMinScore     float64 // 历史问答与问题的余弦相似度不低于该值才作为上下文
+	MaxTurns            int     // 每个会话保留的问答轮数，超出时丢弃最早的
+	RetrievalTTL        time.Duration
+	RetrievalSimilarity float64 // 复用检索结果的余弦相似度阈值，0时只复用归一化后相同的问题
+}
+
+func loadSessionConfig() SessionConfig {
+	return SessionConfig{
+		TTL:                 time.Duration(getEnvAsInt("SESSION_TTL_MINUTES", 30)) * time.Minute,
+		MaxSessions:         getEnvAsInt("SESSION_MAX", 10000),
+		HistoryTopK:         getEnvAsInt("SESSION_HISTORY_TOP_K", 2),
+		HistoryMinScore:     getEnvAsFloat("SESSION_HISTORY_MIN_SCORE", 0.6),
+		MaxTurns:            getEnvAsInt("SESSION_MAX_TURNS", 50),
+		RetrievalTTL:        time.Duration(getEnvAsInt("SESSION_RETRIEVAL_TTL_SECONDS", 0)) * time.Second,
+		RetrievalSimilarity: getEnvAsFloat("SESSION_RETRIEVAL_SIMILARITY", 0.92),
+	}
+}
+
+// 每个会话保留的检索结果数，超出时丢弃最早的
+const maxSessionRetrievals = 5
+
+// 历史问答作为检索结果时的文档ID和来源，可通过SOURCE_TRUST设置可信度
+const (
+	historyDocID  = "session"
+	historySource = "对话历史"
+)
+
+// 会话中的一轮问答
+type sessionTurn struct {
+	question string
+	answer   string
+	askedAt  time.Time
+	vector   []float32
+}
+
+// 会话中的一次检索
+type sessionRetrieval struct {
+	question string // 归一化后的问题
+	scope    string // 检索条件
+	vector   []float32
+	results  []SearchResult
+	at       time.Time
+}
+
+type session struct {
+	shown      []string // 展示过的来源文档，按首次展示的顺序
+	turns      []sessionTurn
+	retrievals []sessionRetrieval
+	seq        int // 已记录的问答轮数，用作历史分块的序号
+	lastUsed   time.Time
+}
+
+// 内存中的会话状态，服务重启后丢失
+type sessionStore struct {
+	config   SessionConfig
+	embedder embedder
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func newSessionStore(config SessionConfig, embedder embedder) *sessionStore {
+	return &sessionStore{config: config, embedder: embedder, sessions: make(map[string]*session)}
+}
+
+// 会话展示过的来源文档，会话不存在或已过期时为空
+func (s *sessionStore) Shown(id string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.get(id, false)
+	if sess == nil {
+		return nil
+	}
+	return append([]string(nil), sess.shown...)
+}
+
+// 记录本次展示的来源文档
+func (s *sessionStore) MarkShown(id string, sources []SearchResult) {
+	if id == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.get(id, true)
+	for _, docID := range sourceDocIDs(sources) {
+		if docID != historyDocID && !containsString(sess.shown, docID) {
+			sess.shown = append(sess.shown, docID)
+		}
+	}
+}
+
+// 保存一轮问答，未开启历史检索时不保存
+func (s *sessionStore) AddTurn(ctx context.Context, id, question, answer string) error {
+	if id == "" || s.config.HistoryTopK <= 0 {
+		return nil
+	}
+	// 署名是根据来源生成的，不属于回答内容
+	answer, _, _ = strings.Cut(answer, attributionHeader)
+	vector, err := s.embedder.Embed(ctx, formatTurn(question, answer))
+	if err != nil {
+		return fmt.Errorf("对话历史向量化失败: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.get(id, true)
+	sess.seq++
+	sess.turns = append(sess.turns, sessionTurn{question: question, answer: answer, askedAt: time.Now(), vector: vector})
+	if len(sess.turns) > s.config.MaxTurns {
+		sess.turns = sess.turns[len(sess.turns)-s.config.MaxTurns:]
+	}
+	return nil
+}
+
+// 会话中与问题最相近的几轮问答，转换为检索结果
+func (s *sessionStore) Recall(ctx context.Context, id, question string) ([]SearchResult, error) {
+	if id == "" || s.config.HistoryTopK <= 0 {
+		return nil, nil
+	}
+	s.mu.Lock()
+	sess := s.get(id, false)
+	var turns []sessionTurn
+	first := 0
+	if sess != nil {
+		turns = append(turns, sess.turns...)
+		first = sess.seq - len(sess.turns) + 1
+	}
+	s.mu.Unlock()
+	if len(turns) == 0 {
+		return nil, nil
+	}
+
+	vector, err := s.embedder.Embed(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("问题向量化失败: %w", err)
+	}
+	var results []SearchResult
+	for i, turn := range turns {
+		score := cosineSimilarity(vector, turn.vector)
+		if score < s.config.HistoryMinScore {
+			continue
+		}
+		results = append(results, SearchResult{
+			ID:      fmt.Sprintf("%s#%d", historyDocID, first+i),
+			DocID:   historyDocID,
+			Title:   historySource + "：" + turn.question,
+			Content: formatTurn(turn.question, turn.answer),
+			Score:   score,
+			Meta:    map[string]interface{}{"source": historySource, "date": turn.askedAt.Format(time.RFC3339)},
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > s.config.HistoryTopK {
+		results = results[:s.config.HistoryTopK]
+	}
+	return results, nil
+}
+
+// 会话最近的检索中可以复用的结果，返回结果的副本；没有命中时返回问题向量供SaveRetrieval使用（未开启相似匹配时为nil）
+func (s *sessionStore) ReusableRetrieval(ctx context.Context, id, question, scope string) ([]SearchResult, []float32, bool) {
+	if id == "" || s.config.RetrievalTTL <= 0 {
+		return nil, nil, false
+	}
+	key := normalizeQuestion(question)
+	s.mu.Lock()
+	var retrievals []sessionRetrieval
+	if sess := s.get(id, false); sess != nil {
+		retrievals = append(retrievals, sess.retrievals...)
+	}
+	s.mu.Unlock()
+
+	var fresh []sessionRetrieval
+	for _, retrieval := range retrievals {
+		if retrieval.scope != scope || time.Since(retrieval.at) > s.config.RetrievalTTL {
+			continue
+		}
+		if retrieval.question == key {
+			return append([]SearchResult(nil), retrieval.results...), nil, true
+		}
+		fresh = append(fresh, retrieval)
+	}
+	if s.config.RetrievalSimilarity <= 0 {
+		return nil, nil, false
+	}
+	vector, err := s.embedder.Embed(ctx, key)
+	if err != nil {
+		fmt.Printf("⚠️  匹配会话检索结果时问题向量化失败: %v\n", err)
+		return nil, nil, false
+	}
+	var best *sessionRetrieval
+	bestSimilarity := s.config.RetrievalSimilarity
+	for i := range fresh {
+		if similarity := cosineSimilarity(vector, fresh[i].vector); similarity >= bestSimilarity {
+			best, bestSimilarity = &fresh[i], similarity
+		}
+	}
+	if best == nil {
+		return nil, vector, false
+	}
+	return append([]SearchResult(nil), best.results...), nil, true
+}
+
+// 保存本次检索的结果，供会话之后的提问复用
+func (s *sessionStore) SaveRetrieval(id, question, scope string, vector []float32, results []SearchResult) {
+	if id == "" || s.config.RetrievalTTL <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.get(id, true)
+	sess.retrievals = append(sess.retrievals, sessionRetrieval{
+		question: normalizeQuestion(question),
+		scope:    scope,
+		vector:   vector,
+		results:  append([]SearchResult(nil), results...),
+		at:       time.Now(),
+	})
+	if len(sess.retrievals) > maxSessionRetrievals {
+		sess.retrievals = sess.retrievals[len(sess.retrievals)-maxSessionRetrievals:]
+	}
+}
+
+func formatTurn(question, answer string) string {
+	return "问：" + question + "\n答：" + answer
+}
+
+// 检索会话历史作为回答的上下文，分数与知识库的检索结果一样按可信度加权；失败时只告警
+func (r *RAGSystem) recallHistory(ctx context.Context, sessionID, question string) []SearchResult {
+	results, err := r.sessions.Recall(ctx, sessionID, question)
+	if err != nil {
+		fmt.Printf("⚠️  检索对话历史失败: %v\n", err)
+		return nil
+	}
+	for i := range results {
+		results[i].Trust = r.config.Trust.weight(results[i].Meta)
+		results[i].Score *= results[i].Trust
+	}
+	return results
+}
+
+// 取会话并刷新最近使用时间，create为true时不存在则新建；调用方需持有锁
+func (s *sessionStore) get(id string, create bool) *session {
+	now := time.Now()
+	sess, ok := s.sessions[id]
+	if ok && now.Sub(sess.lastUsed) > s.config.TTL {
+		delete(s.sessions, id)
+		sess, ok = nil, false
+	}
+	if !ok {
+		if !create {
+			return nil
+		}
+		s.evict(now)
+		sess = &session{}
+		s.sessions[id] = sess
+	}
+	sess.lastUsed = now
+	return sess
+}
+
+// 清理过期会话，仍然超出上限时淘汰最久未使用的
+func (s *sessionStore) evict(now time.Time) {
+	if len(s.sessions) < s.config.MaxSessions {
+		return
+	}
+	var oldestID string
+	var oldest time.Time
+	for id, sess := range s.sessions {
+		if now.Sub(sess.lastUsed) > s.config.TTL {
+			delete(s.sessions, id)
+			continue
+		}
+		if oldestID == "" || sess.lastUsed.Before(oldest) {
+			oldestID, oldest = id, sess.lastUsed
+		}
+	}
+	if len(s.sessions) >= s.config.MaxSessions {
+		delete(s.sessions, oldestID)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 流式获取RAG增强答案，每收到一段增量内容就把当前完整答案交给sink；fresh为true时跳过答案缓存，
+// length为回答长度档位，不是ANSWER_LENGTH或问题含相对时间时不读写答案缓存。同一问题正在流式生成时订阅该生成过程，不重复生成。
+// 输出和返回的答案都已按屏蔽词表处理，固定回答原样输出。低置信度的回答与 /ask 一样进入人工审核，输出等待提示并返回审核ID
+func (r *RAGSystem) StreamRAGAnswer(ctx context.Context, question string, fresh bool, length string, sink replySink) (string, []SearchResult, int64, error) {
+	words := r.settings().lexicon
+	if override, ok := r.overrides.Lookup(ctx, question); ok {
+		fmt.Printf("📜 命中固定回答 #%d: %s\n", override.ID, question)
+		return override.Answer, nil, 0, sink.Finish(override.Answer)
+	}
+	if !fresh && r.config.AnswerLength.cacheable(length) && !r.config.Dates.relativeQuestion(question) {
+		if hit, ok := r.answers.Lookup(ctx, question); ok {
+			answer := words.apply(answerNamespace("", hit.Sources), hit.Answer)
+			return answer, hit.Sources, 0, sink.Finish(answer)
+		}
+	}
+	return r.broadcasts.do(ctx, flightKey(question, searchOptions{Length: length}), sink, func(ctx context.Context, sink replySink) (string, []SearchResult, int64, error) {
+		filtered := &lexiconSink{sink: sink, lexicon: words}
+		answer, results, reviewID, err := r.generateStream(ctx, question, length, filtered)
+		return words.apply(filtered.namespace, answer), results, reviewID, err
+	})
+}
+
+// 检索并流式生成答案，检索后按结果确定屏蔽词表的命名空间；需要人工审核的回答不输出，放入审核队列
+func (r *RAGSystem) generateStream(ctx context.Context, question, length string, sink *lexiconSink) (string, []SearchResult, int64, error) {
+	// 1. 检索相关文档，降级的回答一次性输出且不写入缓存
+	opts := searchOptions{Degraded: &degradation{}, Length: length}
+	cacheable := r.config.AnswerLength.cacheable(length) && !r.config.Dates.relativeQuestion(question)
+	// 问题由对等实例负责时转发，对方的回答一次性输出；merge模式下对方的分块与本地检索结果合并
+	peerAnswer, remote, delegated := r.delegateToPeer(ctx, question, &opts)
+	if delegated {
+		sink.namespace = answerNamespace("", remote)
+		return peerAnswer, remote, 0, sink.Finish(peerAnswer)
+	}
+	// 分批总结的回答一次性输出
+	if answer, results, handled, err := r.answerBySummaries(ctx, question, opts); handled {
+		sink.namespace = answerNamespace("", results)
+		if err != nil && len(results) > 0 {
+			return r.finishExtractive(ctx, results, opts, err, sink)
+		}
+		if err != nil {
+			return "", nil, 0, err
+		}
+		return r.finishStream(ctx, question, answer, results, opts, cacheable && len(results) > 0, sink)
+	}
+	results, err := r.retrieve(ctx, question, opts)
+	if err != nil {
+		answer, err := r.answerWithoutRetrieval(ctx, question, opts, err)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		return answer, nil, 0, sink.Finish(answer)
+	}
+	results = mergePeerResults(results, remote)
+	sink.namespace = answerNamespace("", results)
+	if answer, handled, err := r.applyPolicy(ctx, question, opts, results); handled {
+		if err != nil {
+			return "", nil, 0, err
+		}
+		return answer, nil, 0, sink.Finish(answer)
+	}
+	if len(results) == 0 {
+		return "", nil, 0, ErrNoRelevantDocs
+	}
+
+	prompted := r.fitOversized(ctx, question, results)
+
+	// 2. 需要数值计算时走计算器工具，计算完成后一次性输出
+	if r.useCalculator("") && needsCalculation(question, prompted) {
+		answer, steps, err := r.answerWithCalculator(ctx, question, prompted, "")
+		if err != nil {
+			return r.finishExtractive(ctx, results, opts, err, sink)
+		}
+		answer = appendAttribution(appendCalcSteps(answer, steps), results)
+		return r.finishStream(ctx, question, answer, results, opts, cacheable, sink)
+	}
+
+	// 3. 流式调用DeepSeek生成答案，需要人工审核时不输出增量，生成完再放入审核队列
+	if err := r.faults.inject(ctx, faultTargetLLM, "DeepSeek RAG流式回答"); err != nil {
+		return r.finishExtractive(ctx, results, opts, llmError(ctx, err), sink)
+	}
+	review := r.reviews.needsReview(results)
+	var output replySink = sink
+	if review {
+		output = discardSink{}
+	}
+	answer, err := r.streamAnswer(withAnswerLength(ctx, length), r.config.AnswerLength.apply(r.ragChatRequest(question, prompted, ""), length), output)
+	if err != nil {
+		// 已经输出了部分回答时不再改为摘录
+		if answer == "" || review {
+			return r.finishExtractive(ctx, results, opts, err, sink)
+		}
+		return answer, results, 0, err
+	}
+
+	if answer == "" {
+		return "", results, 0, fmt.Errorf("%w: 未收到回答", ErrLLMUnavailable)
+	}
+	return r.finishStream(ctx, question, appendAttribution(answer, results), results, opts, cacheable, sink)
+}
+
+// 输出生成完的回答：与 /ask 一样，低置信度且未降级的回答放入审核队列，输出等待提示；其余回答可缓存时写入答案缓存
+func (r *RAGSystem) finishStream(ctx context.Context, question, answer string, results []SearchResult, opts searchOptions, cacheable bool, sink replySink) (string, []SearchResult, int64, error) {
+	degraded := len(opts.Degraded.Tiers()) > 0
+	if !degraded && r.reviews.needsReview(results) {
+		item, err := r.reviews.Park(question, answer, results)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		fmt.Printf("🧑‍⚖️ 回答进入人工审核 #%d: %s\n", item.ID, question)
+		return reviewPendingNotice, results, item.ID, sink.Finish(reviewPendingNotice)
+	}
+	if cacheable && !degraded {
+		r.answers.Store(ctx, question, answer, results)
+	}
+	return answer, results, 0, sink.Finish(answer)
+}
+
+// 不输出任何内容，用于需要人工审核、不能边生成边输出的回答
+type discardSink struct{}
+
+func (discardSink) Update(string) error { return nil }
+func (discardSink) Finish(string) error { return nil }
+
+// 流式生成失败时输出分块摘录
+func (r *RAGSystem) finishExtractive(ctx context.Context, results []SearchResult, opts searchOptions, err error, sink replySink) (string, []SearchResult, int64, error) {
+	answer, err := r.answerExtractive(ctx, results, opts, err)
+	if err != nil {
+		return "", results, 0, err
+	}
+	return answer, results, 0, sink.Finish(answer)
+}
+
+// 流式回复的输出端：Update接收到目前为止的完整答案，Finish在生成结束时调用一次
+type replySink interface {
+	Update(text string) error
+	Finish(text string) error
+}
+
+// 支持编辑已发送消息的平台：先发一条消息，之后按间隔编辑它；超过单条长度上限时续发新消息
+type editingSink struct {
+	send      func(text string) (messageID int64, err error)
+	edit      func(messageID int64, text string) error
+	maxLen    int           // 单条消息最大字符数
+	interval  time.Duration // 两次编辑的最小间隔，避免触发平台限流
+	messageID int64
+	sent      bool
+	offset    int    // 已经定稿到前面消息中的字符数
+	lastText  string // 当前消息最后一次发出的内容
+	lastEdit  time.Time
+}
+
+func newEditingSink(send func(string) (int64, error), edit func(int64, string) error, maxLen int, interval time.Duration) *editingSink {
+	return &editingSink{send: send, edit: edit, maxLen: maxLen, interval: interval}
+}
+
+func (s *editingSink) Update(text string) error {
+	if time.Since(s.lastEdit) < s.interval {
+		return nil
+	}
+	return s.flush(text, false)
+}
+
+func (s *editingSink) Finish(text string) error {
+	return s.flush(text, true)
+}
+
+func (s *editingSink) flush(text string, final bool) error {
+	runes := []rune(text)
+	for {
+		current := runes[s.offset:]
+		if len(current) <= s.maxLen {
+			return s.render(string(current))
+		}
+
+		// 当前消息已写满：定稿后从下一条消息继续
+		cut := splitPoint(current, s.maxLen)
+		if err := s.render(string(current[:cut])); err != nil {
+			return err
+		}
+		s.offset += cut
+		s.sent = false
+		s.lastText = ""
+		if !final {
+			return nil
+		}
+	}
+}
+
+// 发送或编辑当前消息
+func (s *editingSink) render(text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" || text == s.lastText {
+		return nil
+	}
+	s.lastEdit = time.Now()
+	s.lastText = text
+	if !s.sent {
+		id, err := s.send(text)
+		if err != nil {
+			return err
+		}
+		s.messageID, s.sent = id, true
+		return nil
+	}
+	return s.edit(s.messageID, text)
+}
+
+// 不支持编辑消息的平台：答案每攒满一段就按顺序发出一条消息
+type chunkingSink struct {
+	send   func(text string) error
+	maxLen int
+	offset int // 已发送的字符数
+}
+
+func newChunkingSink(send func(string) error, maxLen int) *chunkingSink {
+	return &chunkingSink{send: send, maxLen: maxLen}
+}
+
+func (s *chunkingSink) Update(text string) error {
+	runes := []rune(text)
+	for len(runes)-s.offset > s.maxLen {
+		if err := s.emit(runes, splitPoint(runes[s.offset:], s.maxLen)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *chunkingSink) Finish(text string) error {
+	if err := s.Update(text); err != nil {
+		return err
+	}
+	runes := []rune(text)
+	if s.offset < len(runes) {
+		return s.emit(runes, len(runes)-s.offset)
+	}
+	return nil
+}
+
+func (s *chunkingSink) emit(runes []rune, n int) error {
+	part := strings.TrimSpace(string(runes[s.offset : s.offset+n]))
+	s.offset += n
+	if part == "" {
+		return nil
+	}
+	return s.send(part)
+}
+
+// 在不超过limit的范围内寻找合适的切分位置：优先段落，其次句子，最后硬切
+func splitPoint(runes []rune, limit int) int {
+	if len(runes) <= limit {
+		return len(runes)
+	}
+	window := string(runes[:limit])
+	for _, sep := range []string{"\n\n", "\n", "。", "！", "？", ". "} {
+		if i := strings.LastIndex(window, sep); i > 0 {
+			cut := utf8.RuneCountInString(window[:i+len(sep)])
+			if cut >= limit/2 {
+				return cut
+			}
+		}
+	}
+	return limit
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// token计数配置：generic按tiktoken类分词器估算，deepseek按DeepSeek分词器的字符比例估算，
+// auto根据DEEPSEEK_MODEL选择；比例可按实际计费的prompt_tokens校准后覆盖
+type TokenizerConfig struct {
+	Name       string  // auto、deepseek、generic
+	CJKRatio   float64 // 每个中日韩字符的token数，0表示使用分词器的默认值
+	OtherRatio float64 // 每个其他非空白字符的token数，0表示使用分词器的默认值
+}
+
+func loadTokenizerConfig() TokenizerConfig {
+	return TokenizerConfig{
+		Name:       getEnv("TOKENIZER", "auto"),
+		CJKRatio:   getEnvAsFloat("TOKENIZER_CJK_RATIO", 0),
+		OtherRatio: getEnvAsFloat("TOKENIZER_OTHER_RATIO", 0),
+	}
+}
+
+// token计数，用于上下文预算和入库报告
+type tokenCounter interface {
+	Count(text string) int
+}
+
+// 各分词器每类字符对应的token数
+var tokenizerRatios = map[string]charRatioTokenizer{
+	// tiktoken类分词器：汉字多为1个token，英文约4个字符1个token
+	"generic": {cjk: 1, other: 0.25},
+	// DeepSeek官方换算：1个中文字符约0.6个token，1个英文字符约0.3个token
+	"deepseek": {cjk: 0.6, other: 0.3},
+}
+
+func newTokenCounter(config TokenizerConfig, model string) (tokenCounter, error) {
+	name := config.Name
+	if name == "auto" {
+		name = "generic"
+		if strings.HasPrefix(model, "deepseek") {
+			name = "deepseek"
+		}
+	}
+	counter, ok := tokenizerRatios[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的TOKENIZER: %s", config.Name)
+	}
+	if config.CJKRatio > 0 {
+		counter.cjk = config.CJKRatio
+	}
+	if config.OtherRatio > 0 {
+		counter.other = config.OtherRatio
+	}
+	return counter, nil
+}
+
+// 按字符类别加权估算：中日韩字符和其他非空白字符分别乘以比例后向上取整
+type charRatioTokenizer struct {
+	cjk   float64
+	other float64
+}
+
+func (t charRatioTokenizer) Count(text string) int {
+	cjk, other := 0, 0
+	for _, ch := range text {
+		switch {
+		case unicode.Is(unicode.Han, ch) || unicode.In(ch, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		case !unicode.IsSpace(ch):
+			other++
+		}
+	}
+	return int(math.Ceil(float64(cjk)*t.cjk + float64(other)*t.other))
+}
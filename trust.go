@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 来源可信度配置：SOURCE_TRUST=官方文档=1.0,社区=0.6，键可以是元数据中的source、category或来源网址的域名
+type TrustConfig struct {
+	Default float64
+	Weights map[string]float64
+}
+
+func loadTrustConfig() TrustConfig {
+	config := TrustConfig{
+		Default: getEnvAsFloat("SOURCE_TRUST_DEFAULT", 1.0),
+		Weights: make(map[string]float64),
+	}
+	for _, item := range splitEnvList("SOURCE_TRUST") {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			continue
+		}
+		config.Weights[strings.TrimSpace(key)] = weight
+	}
+	return config
+}
+
+// 计算分块的可信度：元数据中的trust优先，其次按source、category、来源域名查配置
+func (c TrustConfig) weight(meta map[string]interface{}) float64 {
+	switch trust := meta["trust"].(type) {
+	case float64:
+		return trust
+	case string:
+		if value, err := strconv.ParseFloat(trust, 64); err == nil {
+			return value
+		}
+	}
+
+	for _, key := range []string{"source", "category"} {
+		if value, ok := meta[key].(string); ok {
+			if weight, ok := c.Weights[value]; ok {
+				return weight
+			}
+		}
+	}
+	if rawURL, ok := meta["source_url"].(string); ok {
+		if u, err := url.Parse(rawURL); err == nil {
+			if weight, ok := c.Weights[u.Hostname()]; ok {
+				return weight
+			}
+		}
+	}
+	return c.Default
+}
+
+// 将可信度计入排序分数并重新排序
+func applyTrust(results []SearchResult, config TrustConfig) {
+	for i := range results {
+		results[i].Trust = config.weight(results[i].Meta)
+		results[i].Score *= results[i].Trust
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+}
+
+// 可信度等级，用于引用说明
+func trustLabel(trust float64) string {
+	switch {
+	case trust >= 0.9:
+		return "高"
+	case trust >= 0.7:
+		return "中"
+	default:
+		return "低"
+	}
+}
+
+// 引用中的来源说明，例如"来源: 官方文档，可信度: 高(1.0)"，联合检索时附上来源索引
+func citationSource(result SearchResult) string {
+	source, _ := result.Meta["source"].(string)
+	if source == "" {
+		source, _ = result.Meta["source_url"].(string)
+	}
+	if source == "" {
+		source = result.Title
+	}
+	citation := fmt.Sprintf("来源: %s，可信度: %s(%.1f)", source, trustLabel(result.Trust), result.Trust)
+	if result.Index != "" {
+		citation += "，索引: " + result.Index
+	}
+	return citation
+}